@@ -1,35 +1,58 @@
 package config
 
 import (
-	"os"
+	"io"
+	"shared/envconfig"
 )
 
+// jwtSecretMinLength is the shortest JWT secret accepted outside the dev profile
+const jwtSecretMinLength = 32
+
+// Config is the API gateway configuration
 type Config struct {
-	Port              string
-	UserServiceURL    string
-	ProjectServiceURL string
-	OrderServiceURL   string
-	UserJWTSecret     string
-	AdminJWTSecret    string
-	RateLimitPerMin   int
+	Profile           string `env:"APP_ENV" default:"production" validate:"oneof=dev test staging production"`
+	Port              int    `env:"PORT" default:"8000" validate:"min=1,max=65535"`
+	UserServiceURL    string `env:"USER_SERVICE_URL" default:"http://localhost:8082" validate:"required,url"`
+	ProjectServiceURL string `env:"PROJECT_SERVICE_URL" default:"http://localhost:8081" validate:"required,url"`
+	OrderServiceURL   string `env:"ORDER_SERVICE_URL" default:"http://localhost:8080" validate:"required,url"`
+	UserJWTSecret     string `env:"USER_JWT_SECRET" default:"user-secret-key" secret:"true" validate:"required"`
+	AdminJWTSecret    string `env:"ADMIN_JWT_SECRET" default:"admin-secret-key" secret:"true" validate:"required"`
+	RateLimitPerMin   int    `env:"RATE_LIMIT_PER_MIN" default:"100" validate:"min=1"`
 }
 
-func LoadConfig() *Config {
-	return &Config{
-		Port:              getEnv("PORT", "8000"),
-		UserServiceURL:    getEnv("USER_SERVICE_URL", "http://localhost:8082"),
-		ProjectServiceURL: getEnv("PROJECT_SERVICE_URL", "http://localhost:8081"),
-		OrderServiceURL:   getEnv("ORDER_SERVICE_URL", "http://localhost:8080"),
-		UserJWTSecret:     getEnv("USER_JWT_SECRET", "user-secret-key"),
-		AdminJWTSecret:    getEnv("ADMIN_JWT_SECRET", "admin-secret-key"),
-		RateLimitPerMin:   100,
+// Load reads the configuration from the environment and the optional config
+// file and validates it. The config is returned even when it is invalid so
+// it can still be printed.
+func Load() (*Config, error) {
+	src, err := envconfig.NewSource()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	problems := src.Load(cfg)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, &envconfig.Error{Problems: problems}
 	}
+	return cfg, nil
 }
 
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
+func (c *Config) validate() []string {
+	problems := envconfig.Check(c)
+	if c.Profile == envconfig.ProfileDev {
+		return problems
 	}
-	return value
+
+	problems = append(problems, envconfig.CheckSecret("USER_JWT_SECRET", c.UserJWTSecret, jwtSecretMinLength)...)
+	problems = append(problems, envconfig.CheckSecret("ADMIN_JWT_SECRET", c.AdminJWTSecret, jwtSecretMinLength)...)
+	if c.UserJWTSecret != "" && c.UserJWTSecret == c.AdminJWTSecret {
+		problems = append(problems, "USER_JWT_SECRET and ADMIN_JWT_SECRET must differ")
+	}
+	return problems
+}
+
+// RunCommand runs a "config" subcommand and returns the process exit code
+func RunCommand(args []string, stdout, stderr io.Writer) int {
+	return envconfig.RunCommand(args, stdout, stderr, Load)
 }
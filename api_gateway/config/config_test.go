@@ -0,0 +1,75 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"shared/envconfig"
+	"strings"
+	"testing"
+)
+
+// withFile points CONFIG_FILE at a temporary file with the given contents
+func withFile(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.env")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func TestLoadDevDefaults(t *testing.T) {
+	withFile(t, "APP_ENV=dev\nRATE_LIMIT_PER_MIN=250\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected dev defaults to be accepted: %v", err)
+	}
+	if cfg.Port != 8000 || cfg.RateLimitPerMin != 250 || cfg.UserServiceURL != "http://localhost:8082" {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+}
+
+func TestLoadRejectsInsecureSecretsOutsideDev(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"defaults", "", "USER_JWT_SECRET uses an insecure default"},
+		{"placeholder", "ADMIN_JWT_SECRET=your-admin-jwt-secret-key-here\n", "ADMIN_JWT_SECRET uses an insecure default"},
+		{"short", "USER_JWT_SECRET=abc123\n", "USER_JWT_SECRET must be at least 32 characters"},
+		{"shared", "USER_JWT_SECRET=0123456789abcdef0123456789abcdef\nADMIN_JWT_SECRET=0123456789abcdef0123456789abcdef\n",
+			"must differ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFile(t, tt.file)
+
+			_, err := Load()
+			var cfgErr *envconfig.Error
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("expected a configuration error, got %v", err)
+			}
+			if problems := strings.Join(cfgErr.Problems, "\n"); !strings.Contains(problems, tt.want) {
+				t.Errorf("expected %q, got:\n%s", tt.want, problems)
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	withFile(t, "APP_ENV=dev\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := RunCommand([]string{"print"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	if strings.Contains(out, "secret-key") || !strings.Contains(out, "ADMIN_JWT_SECRET=<redacted>") {
+		t.Errorf("expected JWT secrets to be redacted:\n%s", out)
+	}
+}
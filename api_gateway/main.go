@@ -3,13 +3,11 @@ package main
 import (
 	"api_gateway/config"
 	_ "api_gateway/docs"
-	"api_gateway/middleware"
-	"api_gateway/proxy"
+	"api_gateway/server"
 	"log"
+	"os"
+	"strconv"
 
-	"github.com/joho/godotenv"
-	"github.com/labstack/echo/v4"
-	echomiddleware "github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
 
@@ -38,44 +36,30 @@ import (
 // @description Admin JWT token (format: Bearer <token>)
 
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(config.RunCommand(os.Args[2:], os.Stdout, os.Stderr))
 	}
 
-	// Load configuration
-	cfg := config.LoadConfig()
-
-	// Create Echo instance
-	e := echo.New()
-
-	// Basic middleware
-	e.Use(echomiddleware.Recover())
-	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{echo.GET, echo.POST, echo.PUT, echo.DELETE, echo.PATCH, echo.OPTIONS},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
-	}))
-	e.Use(middleware.CustomLogger())
-
-	// Rate limiting middleware
-	rateLimiter := middleware.NewRateLimiter(cfg.RateLimitPerMin)
-	e.Use(rateLimiter.Middleware())
+	// Load and validate configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Setup routes (proxy to backend services)
-	proxy.SetupRoutes(e, cfg)
+	// Create the gateway with middleware and proxy routes
+	e := server.New(cfg)
 
 	// Swagger documentation route
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	// Start server
-	log.Printf("API Gateway starting on port %s", cfg.Port)
+	log.Printf("API Gateway starting on port %d (profile %s)", cfg.Port, cfg.Profile)
 	log.Printf("User Service URL: %s", cfg.UserServiceURL)
 	log.Printf("Project Service URL: %s", cfg.ProjectServiceURL)
 	log.Printf("Order Service URL: %s", cfg.OrderServiceURL)
 	log.Printf("Rate Limit: %d requests/minute", cfg.RateLimitPerMin)
 
-	if err := e.Start(":" + cfg.Port); err != nil {
+	if err := e.Start(":" + strconv.Itoa(cfg.Port)); err != nil {
 		log.Fatal("Failed to start API Gateway:", err)
 	}
 }
@@ -3,6 +3,7 @@ package middleware
 import (
 	"api_gateway/config"
 	"net/http"
+	"shared/problem"
 
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
@@ -23,10 +24,7 @@ func UserJWTMiddleware(cfg *config.Config) echo.MiddlewareFunc {
 			return new(JwtCustomClaims)
 		},
 		ErrorHandler: func(c echo.Context, err error) error {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"error":   "Unauthorized",
-				"message": "Invalid or missing user authentication token",
-			})
+			return problem.Unauthorized("Invalid or missing user authentication token").Wrap(err)
 		},
 	})
 }
@@ -39,10 +37,7 @@ func AdminJWTMiddleware(cfg *config.Config) echo.MiddlewareFunc {
 			return new(JwtCustomClaims)
 		},
 		ErrorHandler: func(c echo.Context, err error) error {
-			return c.JSON(http.StatusUnauthorized, map[string]string{
-				"error":   "Unauthorized",
-				"message": "Invalid or missing admin authentication token",
-			})
+			return problem.Unauthorized("Invalid or missing admin authentication token").Wrap(err)
 		},
 	})
 }
@@ -55,10 +50,7 @@ func RoleMiddleware(requiredRole string) echo.MiddlewareFunc {
 			claims := user.Claims.(*JwtCustomClaims)
 
 			if claims.Role != requiredRole {
-				return c.JSON(http.StatusForbidden, map[string]string{
-					"error":   "Forbidden",
-					"message": "You don't have permission to access this resource",
-				})
+				return problem.New(http.StatusForbidden, "You don't have permission to access this resource")
 			}
 
 			return next(c)
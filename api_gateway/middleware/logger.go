@@ -13,8 +13,11 @@ func CustomLogger() echo.MiddlewareFunc {
 		return func(c echo.Context) error {
 			start := time.Now()
 
-			// Process request
+			// Process request, rendering errors now so the logged status is final
 			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
 
 			// Log request details
 			req := c.Request()
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"shared/problem"
 	"sync"
 	"time"
 
@@ -61,10 +62,7 @@ func (rl *RateLimiter) Middleware() echo.MiddlewareFunc {
 			limiter := rl.getLimiter(ip)
 
 			if !limiter.Allow() {
-				return c.JSON(http.StatusTooManyRequests, map[string]string{
-					"error":   "Rate Limit Exceeded",
-					"message": "Too many requests. Please try again later.",
-				})
+				return problem.New(http.StatusTooManyRequests, "Too many requests. Please try again later.")
 			}
 
 			return next(c)
@@ -2,9 +2,12 @@ package proxy
 
 import (
 	"bytes"
-	"fmt"
+	"context"
+	"errors"
 	"io"
 	"net/http"
+	"os"
+	"shared/problem"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -43,10 +46,7 @@ func (sp *ServiceProxy) ProxyRequest(targetURL string) echo.HandlerFunc {
 		// Create a new request
 		proxyReq, err := http.NewRequest(req.Method, fullURL, bytes.NewBuffer(bodyBytes))
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error":   "Gateway Error",
-				"message": "Failed to create proxy request",
-			})
+			return problem.Internal("Failed to create proxy request", err)
 		}
 
 		// Copy headers from original request
@@ -56,27 +56,32 @@ func (sp *ServiceProxy) ProxyRequest(targetURL string) echo.HandlerFunc {
 			}
 		}
 
+		// Forward the request ID so backend problems carry the same one
+		if requestID := c.Response().Header().Get(echo.HeaderXRequestID); requestID != "" {
+			proxyReq.Header.Set(echo.HeaderXRequestID, requestID)
+		}
+
 		// Execute the request
 		resp, err := sp.client.Do(proxyReq)
 		if err != nil {
-			return c.JSON(http.StatusBadGateway, map[string]string{
-				"error":   "Service Unavailable",
-				"message": fmt.Sprintf("Failed to connect to backend service: %v", err),
-			})
+			if errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err) {
+				return problem.New(http.StatusGatewayTimeout, "Backend service did not respond in time").Wrap(err)
+			}
+			return problem.New(http.StatusBadGateway, "Failed to connect to backend service").Wrap(err)
 		}
 		defer resp.Body.Close()
 
 		// Read response body
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return c.JSON(http.StatusInternalServerError, map[string]string{
-				"error":   "Gateway Error",
-				"message": "Failed to read response from backend service",
-			})
+			return problem.New(http.StatusBadGateway, "Failed to read response from backend service").Wrap(err)
 		}
 
-		// Copy response headers
+		// Copy response headers, keeping the gateway's own request ID
 		for key, values := range resp.Header {
+			if key == echo.HeaderXRequestID {
+				continue
+			}
 			for _, value := range values {
 				c.Response().Header().Add(key, value)
 			}
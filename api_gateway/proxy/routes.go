@@ -8,6 +8,144 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+// Backend services the gateway proxies to
+const (
+	UserService    = "user_service"
+	ProjectService = "project_service"
+	OrderService   = "order_service"
+)
+
+// Authentication required by a gateway route. Developers sign in with user
+// tokens that carry the developer role.
+const (
+	Public        = ""
+	UserAuth      = "user"
+	AdminAuth     = "admin"
+	DeveloperAuth = "developer"
+)
+
+// Route is a single gateway route and the backend service it is forwarded to.
+// The path is forwarded unchanged, so it must also exist on that service.
+type Route struct {
+	Method  string
+	Path    string
+	Service string
+	Auth    string
+}
+
+// Routes returns every route the gateway proxies to a backend service
+func Routes() []Route {
+	return []Route{
+		// ===== USER SERVICE ROUTES =====
+		// Public user routes (no auth required)
+		{http.MethodPost, "/api/users/register", UserService, Public},
+		{http.MethodPost, "/api/users/login", UserService, Public},
+
+		// Protected user routes (require user JWT)
+		{http.MethodGet, "/api/users/profile", UserService, UserAuth},
+
+		// Admin user management routes
+		{http.MethodPost, "/api/admin/users/register", UserService, Public},
+		{http.MethodPost, "/api/admin/users/login", UserService, Public},
+		{http.MethodGet, "/api/admin/users", UserService, AdminAuth},
+		{http.MethodPost, "/api/admin/users", UserService, AdminAuth},
+		{http.MethodGet, "/api/admin/users/:id", UserService, AdminAuth},
+		{http.MethodPut, "/api/admin/users/:id", UserService, AdminAuth},
+		{http.MethodDelete, "/api/admin/users/:id", UserService, AdminAuth},
+
+		// ===== PROJECT SERVICE ROUTES =====
+		// Public project routes (no auth required)
+		{http.MethodGet, "/api/v1/projects", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/:id", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/:id/vintages", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/:id/pricing", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/:id/quote", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/:id/documents", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/:id/documents/:documentID/download", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/:id/mrv", ProjectService, Public},
+		{http.MethodPost, "/api/v1/projects/search", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/geojson", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/categories", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/serials/orders/:orderID", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/serials/:serial", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/regions", ProjectService, Public},
+		{http.MethodGet, "/api/v1/projects/countries", ProjectService, Public},
+
+		// Admin project routes (require admin JWT)
+		{http.MethodPost, "/api/v1/projects/admin", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id", ProjectService, AdminAuth},
+		{http.MethodPut, "/api/v1/projects/admin/:id", ProjectService, AdminAuth},
+		{http.MethodPatch, "/api/v1/projects/admin/:id", ProjectService, AdminAuth},
+		{http.MethodDelete, "/api/v1/projects/admin/:id", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/:id/transitions", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/transitions", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/revisions", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/as-of", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/:id/revisions/:revisionID/revert", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/import", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/export", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/search/reindex", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/search/consistency", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/:id/vintages", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/vintages/:vintageID", ProjectService, AdminAuth},
+		{http.MethodPut, "/api/v1/projects/admin/:id/vintages/:vintageID", ProjectService, AdminAuth},
+		{http.MethodDelete, "/api/v1/projects/admin/:id/vintages/:vintageID", ProjectService, AdminAuth},
+		{http.MethodPut, "/api/v1/projects/admin/:id/pricing", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/:id/pricing/schedules", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/pricing/schedules", ProjectService, AdminAuth},
+		{http.MethodDelete, "/api/v1/projects/admin/:id/pricing/schedules/:scheduleID", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/pricing/history", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/:id/documents", ProjectService, AdminAuth},
+		{http.MethodDelete, "/api/v1/projects/admin/:id/documents/:documentID", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/:id/serials", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/serials", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/serials/allocations", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/:id/monitoring", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/monitoring", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/reservations", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/reservations/commit", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/reservations/:id", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/reservations/:id/commit", ProjectService, AdminAuth},
+		{http.MethodPost, "/api/v1/projects/admin/reservations/:id/release", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/ledger", ProjectService, AdminAuth},
+		{http.MethodGet, "/api/v1/projects/admin/:id/sales", ProjectService, AdminAuth},
+
+		// Developer portal routes (require a user JWT with the developer role)
+		{http.MethodGet, "/api/v1/projects/developer", ProjectService, DeveloperAuth},
+		{http.MethodPost, "/api/v1/projects/developer", ProjectService, DeveloperAuth},
+		{http.MethodGet, "/api/v1/projects/developer/:id", ProjectService, DeveloperAuth},
+		{http.MethodPut, "/api/v1/projects/developer/:id", ProjectService, DeveloperAuth},
+		{http.MethodPost, "/api/v1/projects/developer/:id/transitions", ProjectService, DeveloperAuth},
+		{http.MethodGet, "/api/v1/projects/developer/:id/transitions", ProjectService, DeveloperAuth},
+		{http.MethodPost, "/api/v1/projects/developer/:id/documents", ProjectService, DeveloperAuth},
+		{http.MethodGet, "/api/v1/projects/developer/:id/documents", ProjectService, DeveloperAuth},
+		{http.MethodGet, "/api/v1/projects/developer/:id/sales", ProjectService, DeveloperAuth},
+		{http.MethodPost, "/api/v1/projects/developer/:id/monitoring", ProjectService, DeveloperAuth},
+		{http.MethodGet, "/api/v1/projects/developer/:id/monitoring", ProjectService, DeveloperAuth},
+
+		// ===== ORDER SERVICE ROUTES =====
+		// Cart routes (protected - require user JWT)
+		{http.MethodPost, "/api/v1/cart/:userID/items", OrderService, UserAuth},
+		{http.MethodGet, "/api/v1/cart/:userID", OrderService, UserAuth},
+		{http.MethodPut, "/api/v1/cart/:userID/items/:projectID", OrderService, UserAuth},
+		{http.MethodDelete, "/api/v1/cart/:userID/items/:projectID", OrderService, UserAuth},
+		{http.MethodDelete, "/api/v1/cart/:userID", OrderService, UserAuth},
+
+		// Order routes (protected - require user JWT)
+		{http.MethodPost, "/api/v1/orders/:userID/checkout", OrderService, UserAuth},
+		{http.MethodGet, "/api/v1/orders/:userID/history", OrderService, UserAuth},
+		{http.MethodGet, "/api/v1/orders/:orderID", OrderService, UserAuth},
+		{http.MethodPost, "/api/v1/orders/:orderID/cancel", OrderService, UserAuth},
+		{http.MethodPost, "/api/v1/orders/:orderID/confirm", OrderService, UserAuth},
+		{http.MethodGet, "/api/v1/orders/:userID/certificates", OrderService, UserAuth},
+
+		// Admin order routes (require admin JWT)
+		{http.MethodGet, "/api/v1/admin/reports/monthly", OrderService, AdminAuth},
+		{http.MethodGet, "/api/v1/admin/orders/date-range", OrderService, AdminAuth},
+		{http.MethodGet, "/api/v1/admin/statistics", OrderService, AdminAuth},
+	}
+}
+
 func SetupRoutes(e *echo.Echo, cfg *config.Config) {
 	proxy := NewServiceProxy()
 
@@ -32,70 +170,18 @@ func SetupRoutes(e *echo.Echo, cfg *config.Config) {
 		})
 	})
 
-	// API version group
-	api := e.Group("/api")
-
-	// ===== USER SERVICE ROUTES =====
-	// Public user routes (no auth required)
-	api.POST("/users/register", proxy.ProxyRequest(cfg.UserServiceURL))
-	api.POST("/users/login", proxy.ProxyRequest(cfg.UserServiceURL))
-
-	// Protected user routes (require user JWT)
-	userProtected := api.Group("/users")
-	userProtected.Use(middleware.UserJWTMiddleware(cfg))
-	userProtected.GET("/profile", proxy.ProxyRequest(cfg.UserServiceURL))
-
-	// Admin user management routes
-	api.POST("/admin/users/register", proxy.ProxyRequest(cfg.UserServiceURL))
-	api.POST("/admin/users/login", proxy.ProxyRequest(cfg.UserServiceURL))
-
-	adminUsers := api.Group("/admin")
-	adminUsers.Use(middleware.AdminJWTMiddleware(cfg))
-	adminUsers.GET("/users", proxy.ProxyRequest(cfg.UserServiceURL))
-	adminUsers.POST("/users", proxy.ProxyRequest(cfg.UserServiceURL))
-	adminUsers.GET("/users/:id", proxy.ProxyRequest(cfg.UserServiceURL))
-	adminUsers.PUT("/users/:id", proxy.ProxyRequest(cfg.UserServiceURL))
-	adminUsers.DELETE("/users/:id", proxy.ProxyRequest(cfg.UserServiceURL))
-
-	// ===== PROJECT SERVICE ROUTES =====
-	// Public project routes (no auth required)
-	projects := api.Group("/v1/projects")
-	projects.GET("", proxy.ProxyRequest(cfg.ProjectServiceURL))
-	projects.GET("/:id", proxy.ProxyRequest(cfg.ProjectServiceURL))
-	projects.POST("/search", proxy.ProxyRequest(cfg.ProjectServiceURL))
-	projects.GET("/categories", proxy.ProxyRequest(cfg.ProjectServiceURL))
-	projects.GET("/regions", proxy.ProxyRequest(cfg.ProjectServiceURL))
-	projects.GET("/countries", proxy.ProxyRequest(cfg.ProjectServiceURL))
-
-	// Admin project routes (require admin JWT)
-	adminProjects := projects.Group("/admin")
-	adminProjects.Use(middleware.AdminJWTMiddleware(cfg))
-	adminProjects.POST("", proxy.ProxyRequest(cfg.ProjectServiceURL))
-	adminProjects.PUT("/:id", proxy.ProxyRequest(cfg.ProjectServiceURL))
-	adminProjects.DELETE("/:id", proxy.ProxyRequest(cfg.ProjectServiceURL))
-
-	// ===== ORDER SERVICE ROUTES =====
-	// Cart routes (protected - require user JWT)
-	cart := api.Group("/v1/cart")
-	cart.Use(middleware.UserJWTMiddleware(cfg))
-	cart.POST("/:userID/items", proxy.ProxyRequest(cfg.OrderServiceURL))
-	cart.GET("/:userID", proxy.ProxyRequest(cfg.OrderServiceURL))
-	cart.PUT("/:userID/items/:projectID", proxy.ProxyRequest(cfg.OrderServiceURL))
-	cart.DELETE("/:userID/items/:projectID", proxy.ProxyRequest(cfg.OrderServiceURL))
-	cart.DELETE("/:userID", proxy.ProxyRequest(cfg.OrderServiceURL))
-
-	// Order routes (protected - require user JWT)
-	orders := api.Group("/v1/orders")
-	orders.Use(middleware.UserJWTMiddleware(cfg))
-	orders.POST("/:userID/checkout", proxy.ProxyRequest(cfg.OrderServiceURL))
-	orders.GET("/:userID/history", proxy.ProxyRequest(cfg.OrderServiceURL))
-	orders.GET("/:orderID", proxy.ProxyRequest(cfg.OrderServiceURL))
-	orders.GET("/:userID/certificates", proxy.ProxyRequest(cfg.OrderServiceURL))
-
-	// Admin order routes (require admin JWT)
-	adminOrders := api.Group("/v1/admin")
-	adminOrders.Use(middleware.AdminJWTMiddleware(cfg))
-	adminOrders.GET("/reports/monthly", proxy.ProxyRequest(cfg.OrderServiceURL))
-	adminOrders.GET("/orders/date-range", proxy.ProxyRequest(cfg.OrderServiceURL))
-	adminOrders.GET("/statistics", proxy.ProxyRequest(cfg.OrderServiceURL))
+	serviceURLs := map[string]string{
+		UserService:    cfg.UserServiceURL,
+		ProjectService: cfg.ProjectServiceURL,
+		OrderService:   cfg.OrderServiceURL,
+	}
+	authMiddleware := map[string][]echo.MiddlewareFunc{
+		UserAuth:      {middleware.UserJWTMiddleware(cfg)},
+		AdminAuth:     {middleware.AdminJWTMiddleware(cfg)},
+		DeveloperAuth: {middleware.UserJWTMiddleware(cfg), middleware.RoleMiddleware(DeveloperAuth)},
+	}
+
+	for _, route := range Routes() {
+		e.Add(route.Method, route.Path, proxy.ProxyRequest(serviceURLs[route.Service]), authMiddleware[route.Auth]...)
+	}
 }
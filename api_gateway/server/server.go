@@ -0,0 +1,38 @@
+package server
+
+import (
+	"api_gateway/config"
+	"api_gateway/middleware"
+	"api_gateway/proxy"
+	"shared/problem"
+
+	"github.com/labstack/echo/v4"
+	echomiddleware "github.com/labstack/echo/v4/middleware"
+)
+
+// New builds the gateway with its middleware stack and proxy routes
+func New(cfg *config.Config) *echo.Echo {
+	e := echo.New()
+
+	// Errors raised by the gateway itself are rendered as problem+json
+	problem.Install(e)
+
+	// Basic middleware
+	e.Use(echomiddleware.Recover())
+	e.Use(echomiddleware.CORSWithConfig(echomiddleware.CORSConfig{
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{echo.GET, echo.POST, echo.PUT, echo.DELETE, echo.PATCH, echo.OPTIONS},
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, echo.HeaderXRequestID},
+		ExposeHeaders: []string{echo.HeaderXRequestID},
+	}))
+	e.Use(middleware.CustomLogger())
+
+	// Rate limiting middleware
+	rateLimiter := middleware.NewRateLimiter(cfg.RateLimitPerMin)
+	e.Use(rateLimiter.Middleware())
+
+	// Setup routes (proxy to backend services)
+	proxy.SetupRoutes(e, cfg)
+
+	return e
+}
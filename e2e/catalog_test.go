@@ -0,0 +1,41 @@
+package e2e
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCatalogImportAndExport(t *testing.T) {
+	h := Start(t)
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+
+	file := "external_ref,title,description,category,region,country,verification_standard,price_per_tonne\n" +
+		"GS-501,Rwanda Cookstoves,Efficient stoves,energy,Africa,Rwanda,Gold Standard,9\n" +
+		"GS-502,Untitled,,energy,Africa,Rwanda,Gold Standard,9\n"
+
+	dryRun := h.ImportProjects(admin, "text/csv", file, true)
+	if dryRun.Created != 1 || dryRun.Failed != 1 || dryRun.Results[1].Errors[0].Field != "description" {
+		t.Fatalf("unexpected dry run report: %+v", dryRun)
+	}
+	if exported := h.Expect(http.StatusOK, http.MethodGet, "/api/v1/projects/admin/export", nil, admin.Token); strings.Contains(string(exported.Body), "GS-501") {
+		t.Fatal("expected a dry run to import nothing")
+	}
+
+	if report := h.ImportProjects(admin, "text/csv", file, false); report.Created != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	update := `{"external_ref":"GS-501","title":"Rwanda Cookstoves","description":"Efficient stoves","category":"energy","region":"Africa","country":"Rwanda","verification_standard":"Gold Standard","price_per_tonne":10}`
+	if report := h.ImportProjects(admin, "application/x-ndjson", update, false); report.Updated != 1 {
+		t.Fatalf("expected the reference to update the project, got %+v", report)
+	}
+
+	exported := h.Expect(http.StatusOK, http.MethodGet, "/api/v1/projects/admin/export?format=ndjson", nil, admin.Token)
+	if lines := strings.Split(strings.TrimSpace(string(exported.Body)), "\n"); len(lines) != 1 ||
+		!strings.Contains(lines[0], `"price_per_tonne":10`) || !strings.Contains(lines[0], `"status":"draft"`) {
+		t.Errorf("expected the one imported draft exported, got %q", exported.Body)
+	}
+
+	// The export is for admins only
+	h.Expect(http.StatusUnauthorized, http.MethodGet, "/api/v1/projects/admin/export", nil, "")
+}
@@ -0,0 +1,148 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	projectmodels "project_service/models"
+)
+
+func TestDeveloperPortal(t *testing.T) {
+	h := Start(t)
+
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+	developer := h.RegisterDeveloper(admin, "Mangrove Co", "dev@example.com", "d3velopass")
+	rival := h.RegisterDeveloper(admin, "Rival Co", "rival@example.com", "r1valpass")
+	buyer := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+
+	// Only developer tokens reach the portal
+	h.Expect(http.StatusForbidden, http.MethodGet, "/api/v1/projects/developer", nil, buyer.Token)
+	h.Expect(http.StatusUnauthorized, http.MethodGet, "/api/v1/projects/developer", nil, admin.Token)
+
+	var project projectmodels.Project
+	h.Expect(http.StatusCreated, http.MethodPost, "/api/v1/projects/developer", projectmodels.CreateProjectRequest{
+		Title:                "Mangrove Restoration",
+		Description:          "Replanting coastal mangroves",
+		Category:             "forestry",
+		Region:               "Asia",
+		Country:              "Indonesia",
+		VerificationStandard: "VCS",
+		PricePerTonne:        15,
+		TotalCapacity:        100,
+		AvailableCapacity:    100,
+	}, developer.Token).Decode(t, &project)
+	if project.Status != projectmodels.StatusDraft || project.DeveloperID == nil || *project.DeveloperID != developer.ID {
+		t.Fatalf("expected a draft owned by the developer, got %+v", project)
+	}
+
+	var listed struct {
+		Projects []projectmodels.Project `json:"projects"`
+		Total    int64                   `json:"total"`
+	}
+	h.Expect(http.StatusOK, http.MethodGet, "/api/v1/projects/developer", nil, developer.Token).Decode(t, &listed)
+	if listed.Total != 1 || listed.Projects[0].ID != project.ID {
+		t.Fatalf("expected the developer's draft listed, got %+v", listed)
+	}
+
+	// Another developer cannot see or touch the project
+	own := fmt.Sprintf("/api/v1/projects/developer/%d", project.ID)
+	h.Expect(http.StatusNotFound, http.MethodGet, own, nil, rival.Token)
+	h.Expect(http.StatusNotFound, http.MethodPut, own, map[string]string{"description": "Hijacked"}, rival.Token)
+	h.Expect(http.StatusNotFound, http.MethodPost, own+"/transitions",
+		map[string]string{"action": projectmodels.ActionSubmit}, rival.Token)
+
+	// The developer edits the description and media, but nothing else
+	var updated projectmodels.Project
+	h.Expect(http.StatusOK, http.MethodPut, own, map[string]interface{}{
+		"description":     "Replanting 400 hectares of coastal mangroves",
+		"image_url":       "https://example.com/mangroves.jpg",
+		"price_per_tonne": 1,
+	}, developer.Token).Decode(t, &updated)
+	if updated.Description != "Replanting 400 hectares of coastal mangroves" || updated.ImageURL == "" || updated.PricePerTonne != 15 {
+		t.Fatalf("expected only the description and image to change, got %+v", updated)
+	}
+
+	report := []byte("%PDF-1.7\nmonitoring report\n")
+	if res := h.Upload(developer, own+"/documents", projectmodels.DocumentMonitoring, "monitoring.pdf", report); res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected the monitoring report uploaded, got %d: %s", res.StatusCode, res.Body)
+	}
+	if res := h.Upload(developer, own+"/documents", projectmodels.DocumentVerification, "verification.pdf", report); res.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected a verification report from the developer to be refused, got %d: %s", res.StatusCode, res.Body)
+	}
+
+	// The developer submits for review, which only an admin may approve
+	h.Expect(http.StatusOK, http.MethodPost, own+"/transitions", map[string]string{"action": projectmodels.ActionSubmit}, developer.Token)
+	h.Expect(http.StatusForbidden, http.MethodPost, own+"/transitions", map[string]string{"action": projectmodels.ActionApprove}, developer.Token)
+	adminPath := fmt.Sprintf("/api/v1/projects/admin/%d/transitions", project.ID)
+	h.Expect(http.StatusOK, http.MethodPost, adminPath, map[string]string{"action": projectmodels.ActionApprove}, admin.Token)
+	h.Expect(http.StatusOK, http.MethodPost, adminPath, map[string]string{"action": projectmodels.ActionPublish}, admin.Token)
+
+	// A committed reservation is a sale the developer sees
+	var reservation projectmodels.Reservation
+	h.Expect(http.StatusCreated, http.MethodPost, "/api/v1/projects/admin/reservations",
+		map[string]interface{}{"project_id": project.ID, "tonnes": 12}, admin.Token).Decode(t, &reservation)
+	h.Expect(http.StatusOK, http.MethodPost, "/api/v1/projects/admin/reservations/"+reservation.ID+"/commit", nil, admin.Token)
+
+	var sales projectmodels.ProjectSales
+	h.Expect(http.StatusOK, http.MethodGet, own+"/sales", nil, developer.Token).Decode(t, &sales)
+	if sales.TonnesSold != 12 || sales.SaleCount != 1 || len(sales.Sales) != 1 {
+		t.Fatalf("expected the committed reservation as a sale, got %+v", sales)
+	}
+	h.Expect(http.StatusNotFound, http.MethodGet, own+"/sales", nil, rival.Token)
+}
+
+func TestUnreleasedProjectRoutes(t *testing.T) {
+	h := Start(t)
+
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+	reviewer := h.RegisterAdmin("Project Reviewer", "reviewer@example.com", "rev1ewpass")
+	developer := h.RegisterDeveloper(admin, "Mangrove Co", "dev@example.com", "d3velopass")
+	rival := h.RegisterDeveloper(admin, "Rival Co", "rival@example.com", "r1valpass")
+	buyer := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+	project := h.CreateProject(admin, projectmodels.CreateProjectRequest{
+		Title:                "Mangrove Restoration",
+		Description:          "Replanting coastal mangroves",
+		Category:             "forestry",
+		Region:               "Asia",
+		Country:              "Indonesia",
+		VerificationStandard: "VCS",
+		PricePerTonne:        15,
+		TotalCapacity:        100,
+		AvailableCapacity:    100,
+		DeveloperID:          &developer.ID,
+	})
+	base := fmt.Sprintf("/api/v1/projects/%d", project.ID)
+	var vintage projectmodels.Vintage
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/projects/admin/%d/vintages", project.ID),
+		projectmodels.CreateVintageRequest{Year: 2024, PricePerTonne: 15}, admin.Token).Decode(t, &vintage)
+	document := h.UploadDocument(admin, project.ID, projectmodels.DocumentDesign, "design.pdf", []byte("%PDF-1.7\nproject design\n"))
+
+	routes := []string{base + "/vintages", base + "/pricing", base + "/documents"}
+	quote := base + "/quote?tonnes=5"
+
+	// The draft, its vintages, prices and documents are hidden from the
+	// public, buyers and other developers
+	for _, path := range routes {
+		for _, token := range []string{"", buyer.Token, rival.Token, "not-a-token"} {
+			h.Expect(http.StatusNotFound, http.MethodGet, path, nil, token)
+		}
+		h.Expect(http.StatusOK, http.MethodGet, path, nil, admin.Token)
+		h.Expect(http.StatusOK, http.MethodGet, path, nil, developer.Token)
+	}
+	// A signed URL, which only they are given, downloads for anyone
+	for _, token := range []string{"", buyer.Token} {
+		h.Expect(http.StatusOK, http.MethodGet, document.URL, nil, token)
+	}
+	// Nobody can buy a draft, so it is not quoted for anyone
+	for _, token := range []string{"", admin.Token, developer.Token} {
+		h.Expect(http.StatusNotFound, http.MethodGet, quote, nil, token)
+	}
+	h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/projects/admin/%d/vintages/%d", project.ID, vintage.ID), nil, admin.Token)
+	h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/projects/admin/%d/pricing/history", project.ID), nil, admin.Token)
+
+	h.PublishProject(admin, reviewer, project.ID)
+	for _, path := range append(routes, quote) {
+		h.Expect(http.StatusOK, http.MethodGet, path, nil, "")
+	}
+}
@@ -0,0 +1,54 @@
+package e2e
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	projectmodels "project_service/models"
+)
+
+func TestProjectDocuments(t *testing.T) {
+	h := Start(t)
+
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+	project := h.CreateProject(admin, projectmodels.CreateProjectRequest{
+		Title:                "Mangrove Restoration",
+		Description:          "Replanting coastal mangroves",
+		Category:             "forestry",
+		Region:               "Asia",
+		Country:              "Indonesia",
+		VerificationStandard: "VCS",
+		PricePerTonne:        15,
+	})
+
+	report := []byte("%PDF-1.7\nvalidation report\n")
+	uploaded := h.UploadDocument(admin, project.ID, projectmodels.DocumentValidation, "validation.pdf", report)
+	if uploaded.ContentType != "application/pdf" || uploaded.Size != int64(len(report)) || uploaded.URL == "" {
+		t.Fatalf("unexpected document: %+v", uploaded)
+	}
+
+	// The documents of a draft are only listed for an admin
+	documents := fmt.Sprintf("/api/v1/projects/%d/documents", project.ID)
+	h.Expect(http.StatusNotFound, http.MethodGet, documents, nil, "")
+	h.Expect(http.StatusOK, http.MethodGet, documents, nil, admin.Token)
+	reviewer := h.RegisterAdmin("Project Reviewer", "reviewer@example.com", "rev1ewpass")
+	h.PublishProject(admin, reviewer, project.ID)
+
+	var listed struct {
+		Documents []projectmodels.Document `json:"documents"`
+	}
+	h.Expect(http.StatusOK, http.MethodGet, documents, nil, "").Decode(t, &listed)
+	if len(listed.Documents) != 1 || listed.Documents[0].Checksum != uploaded.Checksum {
+		t.Fatalf("expected the uploaded document listed, got %+v", listed.Documents)
+	}
+
+	// The signed URL needs no token, but only works as signed
+	download := h.Expect(http.StatusOK, http.MethodGet, listed.Documents[0].URL, nil, "")
+	if !bytes.Equal(download.Body, report) {
+		t.Errorf("expected the uploaded bytes back, got %q", download.Body)
+	}
+	unsigned := fmt.Sprintf("/api/v1/projects/%d/documents/%d/download", project.ID, uploaded.ID)
+	h.Expect(http.StatusForbidden, http.MethodGet, unsigned, nil, "")
+}
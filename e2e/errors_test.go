@@ -0,0 +1,72 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"api_gateway/proxy"
+	"shared/problem"
+)
+
+// expectProblem checks that the response is problem+json with the code and
+// the same request ID the gateway returned in its header
+func expectProblem(t *testing.T, res *Response, status int, code string) problem.Problem {
+	t.Helper()
+
+	if res.StatusCode != status {
+		t.Fatalf("expected %d, got %d: %s", status, res.StatusCode, res.Body)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != problem.ContentType {
+		t.Errorf("expected %s, got %q", problem.ContentType, ct)
+	}
+
+	var body problem.Problem
+	res.Decode(t, &body)
+	if body.Status != status || body.Code != code || body.Type != problem.TypeBase+code {
+		t.Errorf("expected %d %s problem, got %+v", status, code, body)
+	}
+	if requestID := res.Header.Get("X-Request-Id"); requestID == "" || body.RequestID != requestID {
+		t.Errorf("expected request ID %q in the problem, got %q", requestID, body.RequestID)
+	}
+	return body
+}
+
+func TestGatewayProblems(t *testing.T) {
+	h := Start(t)
+
+	body := expectProblem(t, h.Do(http.MethodGet, "/api/users/profile", nil, ""), http.StatusUnauthorized, problem.CodeUnauthorized)
+	if body.Instance != "/api/users/profile" {
+		t.Errorf("expected the request path as instance, got %q", body.Instance)
+	}
+
+	expectProblem(t, h.Do(http.MethodGet, "/api/v1/unknown", nil, ""), http.StatusNotFound, problem.CodeNotFound)
+}
+
+func TestServiceProblemsPassThroughGateway(t *testing.T) {
+	h := Start(t)
+	user := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+
+	expectProblem(t, h.Do(http.MethodPost, fmt.Sprintf("/api/v1/orders/%d/checkout", user.ID),
+		map[string]string{"payment_method": "card"}, user.Token), http.StatusBadRequest, "cart_empty")
+
+	body := expectProblem(t, h.Do(http.MethodPost, "/api/users/register",
+		map[string]string{"name": "J", "email": "not-an-email", "password": "short"}, ""),
+		http.StatusUnprocessableEntity, problem.CodeValidationFailed)
+	if len(body.Errors) != 3 {
+		t.Errorf("expected an error for each invalid field, got %+v", body.Errors)
+	}
+
+	expectProblem(t, h.Do(http.MethodGet, "/api/v1/projects/99", nil, ""), http.StatusNotFound, problem.CodeNotFound)
+}
+
+func TestUnreachableServiceProblem(t *testing.T) {
+	h := Start(t)
+	h.servers[proxy.ProjectService].Close()
+
+	body := expectProblem(t, h.Do(http.MethodGet, "/api/v1/projects", nil, ""), http.StatusBadGateway, problem.CodeBadGateway)
+	if strings.Contains(body.Detail, "127.0.0.1") || strings.Contains(body.Detail, "connection refused") {
+		t.Errorf("expected dial errors to stay out of the response, got %q", body.Detail)
+	}
+}
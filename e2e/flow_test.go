@@ -0,0 +1,110 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	ordermodels "order_service/models"
+	projectmodels "project_service/models"
+)
+
+func TestPurchaseFlow(t *testing.T) {
+	h := Start(t)
+
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+	reviewer := h.RegisterAdmin("Project Reviewer", "reviewer@example.com", "rev1ewpass")
+	project := h.CreateProject(admin, projectmodels.CreateProjectRequest{
+		Title:                "Kenya Clean Cookstoves",
+		Description:          "Efficient cookstoves for rural households",
+		Category:             "energy",
+		Region:               "Africa",
+		Country:              "Kenya",
+		VerificationStandard: "Gold Standard",
+		PricePerTonne:        8,
+		TotalCapacity:        500,
+		AvailableCapacity:    500,
+	})
+
+	// A draft stays hidden until it is reviewed and published
+	h.Expect(http.StatusNotFound, http.MethodGet, fmt.Sprintf("/api/v1/projects/%d", project.ID), nil, "")
+	h.PublishProject(admin, reviewer, project.ID)
+
+	// The project is publicly visible and searchable
+	h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/projects/%d", project.ID), nil, "")
+
+	var search struct {
+		Projects []projectmodels.Project `json:"projects"`
+	}
+	h.Expect(http.StatusOK, http.MethodPost, "/api/v1/projects/search",
+		map[string]interface{}{"query": "cookstoves"}, "").Decode(t, &search)
+	if len(search.Projects) != 1 || search.Projects[0].ID != project.ID {
+		t.Fatalf("expected search to find project %d, got %+v", project.ID, search.Projects)
+	}
+
+	user := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+
+	var profile struct {
+		User struct {
+			Email string `json:"email"`
+		} `json:"user"`
+	}
+	h.Expect(http.StatusOK, http.MethodGet, "/api/users/profile", nil, user.Token).Decode(t, &profile)
+	if profile.User.Email != user.Email {
+		t.Errorf("unexpected profile: %+v", profile)
+	}
+
+	h.AddToCart(user, project.ID, 2)
+	h.AddToCart(user, project.ID, 3)
+
+	var cart []ordermodels.CartItemResponse
+	h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/cart/%d", user.ID), nil, user.Token).Decode(t, &cart)
+	if len(cart) != 1 || cart[0].Tonnes != 5 {
+		t.Fatalf("expected one cart line with 5 tonnes, got %+v", cart)
+	}
+
+	// The project service prices the line
+	order := h.Checkout(user)
+	if order.Status != "completed" || order.Tonnes != 5 || order.TotalAmount != 40 ||
+		len(order.Lines) != 1 || order.Lines[0].ProjectID != project.ID || order.Lines[0].PricePerTonne != 8 {
+		t.Fatalf("unexpected order: %+v", order)
+	}
+
+	h.Expect(http.StatusOK, http.MethodGet, "/api/v1/orders/"+order.ID.Hex(), nil, user.Token)
+
+	// The tonnes bought are no longer available, and a completed order
+	// cannot be cancelled
+	var bought projectmodels.Project
+	h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/projects/%d", project.ID), nil, "").Decode(t, &bought)
+	if bought.AvailableCapacity != 495 {
+		t.Errorf("expected 495 tonnes left available, got %v", bought.AvailableCapacity)
+	}
+	h.Expect(http.StatusConflict, http.MethodPost, "/api/v1/orders/"+order.ID.Hex()+"/cancel", nil, user.Token)
+
+	var history []ordermodels.OrderResponse
+	h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/orders/%d/history", user.ID), nil, user.Token).
+		Decode(t, &history)
+	if len(history) != 1 {
+		t.Errorf("expected 1 order in history, got %d", len(history))
+	}
+
+	certificate := h.WaitForCertificates(user, order, 10*time.Second)[0]
+	if certificate.CertificateURL == "" {
+		t.Error("expected the generated certificate to have a URL")
+	}
+
+	var stats map[string]map[string]interface{}
+	h.Expect(http.StatusOK, http.MethodGet, "/api/v1/admin/statistics", nil, admin.Token).Decode(t, &stats)
+	if stats["current_month"]["total_orders"] != float64(1) {
+		t.Errorf("expected the order in admin statistics, got %v", stats["current_month"])
+	}
+}
+
+func TestCheckoutWithEmptyCart(t *testing.T) {
+	h := Start(t)
+	user := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+
+	h.Expect(http.StatusBadRequest, http.MethodPost, fmt.Sprintf("/api/v1/orders/%d/checkout", user.ID),
+		map[string]string{"payment_method": "card"}, user.Token)
+}
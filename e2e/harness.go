@@ -0,0 +1,454 @@
+// Package e2e runs the API gateway and all three services in one process so
+// full user journeys can be tested without docker-compose. Every service is
+// served on an ephemeral port with in-memory repositories and messaging.
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	gatewayconfig "api_gateway/config"
+	"api_gateway/proxy"
+	"api_gateway/server"
+
+	orderhandlers "order_service/handlers"
+	"order_service/messaging"
+	ordermodels "order_service/models"
+	orderrepositories "order_service/repositories"
+	orderroutes "order_service/routes"
+	orderservices "order_service/services"
+	orderproblem "shared/problem"
+
+	projectcatalog "project_service/catalog"
+	projectconfig "project_service/config"
+	projecthandlers "project_service/handlers"
+	projectmodels "project_service/models"
+	projectrepositories "project_service/repositories"
+	projectroutes "project_service/routes"
+	projectstorage "project_service/storage"
+	projectproblem "shared/problem"
+
+	userproblem "shared/problem"
+	userconfigs "user_service/configs"
+	userhandlers "user_service/handlers"
+	userrepositories "user_service/repositories"
+	userroutes "user_service/routes"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	UserJWTSecret    = "e2e-user-secret"
+	AdminJWTSecret   = "e2e-admin-secret"
+	CertificateQueue = "certificate_generation"
+)
+
+// Harness is a running gateway plus its backend services. The in-memory
+// stores are exposed so scenarios can seed data or assert on side effects.
+type Harness struct {
+	t testing.TB
+
+	Gateway *httptest.Server
+	servers map[string]*httptest.Server
+	echos   map[string]*echo.Echo
+
+	Users        *userrepositories.InMemoryUserRepository
+	Projects     *projectrepositories.InMemoryProjectRepository
+	Orders       *orderrepositories.InMemoryOrderRepository
+	Carts        *orderrepositories.InMemoryCartRepository
+	Certificates *orderrepositories.InMemoryCertificateRepository
+	Broker       *messaging.InMemoryBroker
+
+	client *http.Client
+}
+
+// Start boots the services and the gateway and stops them when the test ends
+func Start(t testing.TB) *Harness {
+	t.Helper()
+
+	h := &Harness{
+		t:            t,
+		servers:      make(map[string]*httptest.Server),
+		echos:        make(map[string]*echo.Echo),
+		Users:        userrepositories.NewInMemoryUserRepository(),
+		Projects:     projectrepositories.NewInMemoryProjectRepository(),
+		Orders:       orderrepositories.NewInMemoryOrderRepository(),
+		Carts:        orderrepositories.NewInMemoryCartRepository(),
+		Certificates: orderrepositories.NewInMemoryCertificateRepository(),
+		Broker:       messaging.NewInMemoryBroker(),
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+
+	userEcho := echo.New()
+	userproblem.Install(userEcho)
+	userJWT := userconfigs.JWTConfig{UserSecret: UserJWTSecret, AdminSecret: AdminJWTSecret}
+	userroutes.UserRoute(userEcho, userhandlers.NewUserHandler(h.Users, userJWT), userJWT)
+	h.serve(proxy.UserService, userEcho)
+
+	documents, err := projectstorage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reservations := projectrepositories.NewInMemoryReservationRepository(h.Projects)
+	documentRepo := projectrepositories.NewInMemoryDocumentRepository(h.Projects)
+	serials := projectrepositories.NewInMemorySerialRepository(h.Projects)
+	projectEcho := echo.New()
+	projectproblem.Install(projectEcho)
+	projectroutes.ProjectRoute(projectEcho, projecthandlers.NewProjectHandler(h.Projects),
+		projecthandlers.NewSearchAdminHandler(nil),
+		projecthandlers.NewReservationHandler(reservations, 15*time.Minute),
+		projecthandlers.NewVintageHandler(projectrepositories.NewInMemoryVintageRepository(h.Projects)),
+		projecthandlers.NewPricingHandler(projectrepositories.NewInMemoryPricingRepository(h.Projects)),
+		projecthandlers.NewDocumentHandler(documentRepo, documents,
+			projectstorage.NewURLSigner(AdminJWTSecret, 15*time.Minute), 20<<20),
+		projecthandlers.NewCatalogHandler(h.Projects),
+		projecthandlers.NewSerialHandler(serials),
+		projecthandlers.NewDeveloperHandler(h.Projects),
+		projecthandlers.NewMonitoringHandler(projectrepositories.NewInMemoryMonitoringRepository(h.Projects, documentRepo, reservations, serials)),
+		projectconfig.JWTConfig{AdminSecret: AdminJWTSecret, UserSecret: UserJWTSecret})
+	h.serve(proxy.ProjectService, projectEcho)
+
+	projects := orderservices.NewProjectService(h.servers[proxy.ProjectService].URL, AdminJWTSecret)
+	orderEcho := echo.New()
+	orderproblem.Install(orderEcho)
+	orderroutes.SetupRoutes(orderEcho,
+		orderhandlers.NewCartHandler(h.Carts, projects),
+		orderhandlers.NewOrderHandler(h.Orders, h.Carts, h.Certificates, projects, h.Broker, CertificateQueue),
+		orderhandlers.NewAdminHandler(h.Orders),
+	)
+	h.serve(proxy.OrderService, orderEcho)
+
+	certificates := orderservices.NewCertificateService(h.Certificates, h.Orders, h.Broker, CertificateQueue)
+	certificates.SetSerialAllocator(projects)
+	certificates.StartCertificateConsumer()
+	t.Cleanup(h.Broker.Close)
+
+	cfg := &gatewayconfig.Config{
+		UserServiceURL:    h.servers[proxy.UserService].URL,
+		ProjectServiceURL: h.servers[proxy.ProjectService].URL,
+		OrderServiceURL:   h.servers[proxy.OrderService].URL,
+		UserJWTSecret:     UserJWTSecret,
+		AdminJWTSecret:    AdminJWTSecret,
+		// Scenarios send many requests from one IP
+		RateLimitPerMin: 1000000,
+	}
+	h.Gateway = httptest.NewServer(server.New(cfg))
+	t.Cleanup(h.Gateway.Close)
+
+	return h
+}
+
+func (h *Harness) serve(service string, e *echo.Echo) {
+	e.HideBanner = true
+	srv := httptest.NewServer(e)
+	h.t.Cleanup(srv.Close)
+	h.servers[service] = srv
+	h.echos[service] = e
+}
+
+// ServiceRoutes returns the routes registered on a backend service
+func (h *Harness) ServiceRoutes(service string) []*echo.Route {
+	return h.echos[service].Routes()
+}
+
+var pathParam = regexp.MustCompile(`:[^/]+`)
+
+// RouteDrift lists gateway routes that have no matching route on the service
+// they are proxied to. Path parameter names are ignored.
+func (h *Harness) RouteDrift() []string {
+	var drift []string
+	for _, route := range proxy.Routes() {
+		want := route.Method + " " + pathParam.ReplaceAllString(route.Path, ":")
+
+		found := false
+		for _, served := range h.ServiceRoutes(route.Service) {
+			if served.Method+" "+pathParam.ReplaceAllString(served.Path, ":") == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			drift = append(drift, fmt.Sprintf("%s %s -> %s", route.Method, route.Path, route.Service))
+		}
+	}
+	return drift
+}
+
+// Response is a buffered HTTP response from the gateway
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Decode unmarshals the JSON body into v and fails the test if it cannot
+func (r *Response) Decode(t testing.TB, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", r.Body, err)
+	}
+}
+
+// Do sends a request through the gateway. A non-nil body is sent as JSON and
+// a non-empty token as a bearer token.
+func (h *Harness) Do(method, path string, body interface{}, token string) *Response {
+	h.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("marshal request body: %v", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, h.Gateway.URL+path, reader)
+	if err != nil {
+		h.t.Fatalf("build request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	payload, err := io.ReadAll(res.Body)
+	if err != nil {
+		h.t.Fatalf("read response body: %v", err)
+	}
+	return &Response{StatusCode: res.StatusCode, Header: res.Header, Body: payload}
+}
+
+// UploadDocument attaches a file to a project through the gateway as
+// multipart form data
+func (h *Harness) UploadDocument(admin Account, projectID uint, kind, filename string, content []byte) projectmodels.Document {
+	h.t.Helper()
+
+	res := h.Upload(admin, fmt.Sprintf("/api/v1/projects/admin/%d/documents", projectID), kind, filename, content)
+	if res.StatusCode != http.StatusCreated {
+		h.t.Fatalf("upload %s: expected 201, got %d: %s", filename, res.StatusCode, res.Body)
+	}
+
+	var document projectmodels.Document
+	res.Decode(h.t, &document)
+	return document
+}
+
+// Upload sends a file of a document kind to an upload path through the
+// gateway as multipart form data
+func (h *Harness) Upload(account Account, path, kind, filename string, content []byte) *Response {
+	h.t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("kind", kind)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		h.t.Fatalf("build upload: %v", err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req, err := http.NewRequest(http.MethodPost, h.Gateway.URL+path, &body)
+	if err != nil {
+		h.t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+account.Token)
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		h.t.Fatalf("upload %s: %v", filename, err)
+	}
+	defer res.Body.Close()
+	payload, err := io.ReadAll(res.Body)
+	if err != nil {
+		h.t.Fatalf("read response body: %v", err)
+	}
+	return &Response{StatusCode: res.StatusCode, Header: res.Header, Body: payload}
+}
+
+// ImportProjects sends a catalog file of the content type to the import
+// endpoint through the gateway and returns the report
+func (h *Harness) ImportProjects(admin Account, contentType, file string, dryRun bool) projectcatalog.Report {
+	h.t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/v1/projects/admin/import?dry_run=%t", h.Gateway.URL, dryRun), strings.NewReader(file))
+	if err != nil {
+		h.t.Fatalf("build request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+admin.Token)
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		h.t.Fatalf("import: %v", err)
+	}
+	defer res.Body.Close()
+	payload, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		h.t.Fatalf("import: expected 200, got %d: %s", res.StatusCode, payload)
+	}
+
+	var report projectcatalog.Report
+	if err := json.Unmarshal(payload, &report); err != nil {
+		h.t.Fatalf("decode report: %v", err)
+	}
+	return report
+}
+
+// Expect sends a request and fails the test unless the gateway answers with status
+func (h *Harness) Expect(status int, method, path string, body interface{}, token string) *Response {
+	h.t.Helper()
+
+	res := h.Do(method, path, body, token)
+	if res.StatusCode != status {
+		h.t.Fatalf("%s %s: expected %d, got %d: %s", method, path, status, res.StatusCode, res.Body)
+	}
+	return res
+}
+
+// Account is a registered user and the token issued at login
+type Account struct {
+	ID    uint
+	Email string
+	Token string
+}
+
+// RegisterUser registers and logs in a buyer account
+func (h *Harness) RegisterUser(name, email, password string) Account {
+	h.t.Helper()
+	return h.register("/api/users", name, email, password)
+}
+
+// RegisterAdmin registers and logs in an admin account
+func (h *Harness) RegisterAdmin(name, email, password string) Account {
+	h.t.Helper()
+	return h.register("/api/admin/users", name, email, password)
+}
+
+// RegisterDeveloper registers a user account, has the admin give it the
+// developer role and logs it in again for a token that carries the role
+func (h *Harness) RegisterDeveloper(admin Account, name, email, password string) Account {
+	h.t.Helper()
+
+	account := h.RegisterUser(name, email, password)
+	h.Expect(http.StatusOK, http.MethodPut, fmt.Sprintf("/api/admin/users/%d", account.ID),
+		map[string]string{"name": name, "role": "developer"}, admin.Token)
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	h.Expect(http.StatusOK, http.MethodPost, "/api/users/login",
+		map[string]string{"email": email, "password": password}, "").Decode(h.t, &login)
+	account.Token = login.Token
+	return account
+}
+
+func (h *Harness) register(prefix, name, email, password string) Account {
+	h.t.Helper()
+
+	h.Expect(http.StatusOK, http.MethodPost, prefix+"/register",
+		map[string]string{"name": name, "email": email, "password": password}, "")
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	h.Expect(http.StatusOK, http.MethodPost, prefix+"/login",
+		map[string]string{"email": email, "password": password}, "").Decode(h.t, &login)
+
+	user, err := h.Users.GetUserByEmail(email)
+	if err != nil {
+		h.t.Fatalf("registered user %s not found: %v", email, err)
+	}
+	return Account{ID: user.ID, Email: email, Token: login.Token}
+}
+
+// CreateProject creates a draft project through the admin API
+func (h *Harness) CreateProject(admin Account, project projectmodels.CreateProjectRequest) projectmodels.Project {
+	h.t.Helper()
+
+	var created projectmodels.Project
+	h.Expect(http.StatusCreated, http.MethodPost, "/api/v1/projects/admin", project, admin.Token).Decode(h.t, &created)
+	return created
+}
+
+// PublishProject takes a draft project through review to publication: the
+// author submits and publishes it and the reviewer approves it
+func (h *Harness) PublishProject(author, reviewer Account, projectID uint) projectmodels.Project {
+	h.t.Helper()
+
+	path := fmt.Sprintf("/api/v1/projects/admin/%d/transitions", projectID)
+	h.Expect(http.StatusOK, http.MethodPost, path, map[string]string{"action": projectmodels.ActionSubmit}, author.Token)
+	h.Expect(http.StatusOK, http.MethodPost, path, map[string]string{"action": projectmodels.ActionApprove}, reviewer.Token)
+
+	var published projectmodels.Project
+	h.Expect(http.StatusOK, http.MethodPost, path, map[string]string{"action": projectmodels.ActionPublish}, author.Token).Decode(h.t, &published)
+	return published
+}
+
+// AddToCart adds tonnes of a project to the user's cart
+func (h *Harness) AddToCart(user Account, projectID uint, tonnes float64) {
+	h.t.Helper()
+
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/cart/%d/items", user.ID),
+		map[string]interface{}{"project_id": projectID, "tonnes": tonnes}, user.Token)
+}
+
+// Checkout pays for the user's cart
+func (h *Harness) Checkout(user Account) ordermodels.OrderResponse {
+	h.t.Helper()
+
+	var order ordermodels.OrderResponse
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/orders/%d/checkout", user.ID),
+		map[string]string{"payment_method": "card"}, user.Token).Decode(h.t, &order)
+	return order
+}
+
+// WaitForCertificates polls the user's certificates until those of every
+// line of the order have been generated or the timeout expires, and returns
+// them by line
+func (h *Harness) WaitForCertificates(user Account, order ordermodels.OrderResponse, timeout time.Duration) []ordermodels.CertificateResponse {
+	h.t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var certificates []ordermodels.CertificateResponse
+		h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/orders/%d/certificates", user.ID), nil, user.Token).
+			Decode(h.t, &certificates)
+
+		generated := make([]ordermodels.CertificateResponse, len(order.Lines))
+		count := 0
+		for _, certificate := range certificates {
+			if certificate.OrderID == order.ID && certificate.Status == "generated" && certificate.Line >= 1 && certificate.Line <= len(generated) {
+				generated[certificate.Line-1] = certificate
+				count++
+			}
+		}
+		if count == len(generated) {
+			return generated
+		}
+
+		if time.Now().After(deadline) {
+			h.t.Fatalf("certificates for order %s were not generated within %v", order.ID.Hex(), timeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,94 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	projectmodels "project_service/models"
+)
+
+func TestMRVChartFollowsSalesAndRetirements(t *testing.T) {
+	h := Start(t)
+
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+	reviewer := h.RegisterAdmin("Project Reviewer", "reviewer@example.com", "rev1ewpass")
+	developer := h.RegisterDeveloper(admin, "Peat Co", "dev@example.com", "d3velopass")
+	project := h.CreateProject(admin, projectmodels.CreateProjectRequest{
+		Title:                "Rimba Raya Peatland",
+		Description:          "Protected tropical peat swamp forest",
+		Category:             "forestry",
+		Region:               "Asia",
+		Country:              "Indonesia",
+		VerificationStandard: "Verra",
+		PricePerTonne:        14,
+		TotalCapacity:        1000,
+		AvailableCapacity:    1000,
+		DeveloperID:          &developer.ID,
+	})
+	own := fmt.Sprintf("/api/v1/projects/developer/%d", project.ID)
+	chart := fmt.Sprintf("/api/v1/projects/%d/mrv", project.ID)
+
+	// The developer submits a verified period with its monitoring report
+	res := h.Upload(developer, own+"/documents", projectmodels.DocumentMonitoring, "monitoring-2024.pdf", []byte("%PDF-1.7\nmonitoring report\n"))
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("expected the monitoring report uploaded, got %d: %s", res.StatusCode, res.Body)
+	}
+	var report projectmodels.Document
+	res.Decode(t, &report)
+	h.Expect(http.StatusCreated, http.MethodPost, own+"/monitoring", projectmodels.CreateMonitoringPeriodRequest{
+		PeriodStart:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		MeasuredTonnes: 400,
+		Verifier:       "SCS Global Services",
+		VerifiedAt:     time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC),
+		ReportID:       &report.ID,
+	}, developer.Token)
+	// Overlapping the submitted period is refused, also for admins
+	h.Expect(http.StatusConflict, http.MethodPost, fmt.Sprintf("/api/v1/projects/admin/%d/monitoring", project.ID),
+		projectmodels.CreateMonitoringPeriodRequest{
+			PeriodStart:    time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+			PeriodEnd:      time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+			MeasuredTonnes: 100,
+			Verifier:       "SCS Global Services",
+			VerifiedAt:     time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+		}, admin.Token)
+
+	// The chart is public only once the project is
+	h.Expect(http.StatusNotFound, http.MethodGet, chart, nil, "")
+	h.PublishProject(admin, reviewer, project.ID)
+
+	var vintage projectmodels.Vintage
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/projects/admin/%d/vintages", project.ID),
+		projectmodels.CreateVintageRequest{Year: 2024, PricePerTonne: 14, TotalCapacity: 400, AvailableCapacity: 400}, admin.Token).Decode(t, &vintage)
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/projects/admin/%d/serials", project.ID),
+		projectmodels.IssueSerialsRequest{VintageID: vintage.ID, Registry: "Verra", Prefix: "VCS-674-2024", Start: 1, End: 400}, admin.Token)
+
+	// Checking out sells the tonnes of every line, and certifying the lines
+	// retires their serials
+	user := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+	h.AddToCart(user, project.ID, 5)
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/cart/%d/items", user.ID),
+		map[string]interface{}{"project_id": project.ID, "vintage_id": vintage.ID, "tonnes": 3}, user.Token)
+	order := h.Checkout(user)
+
+	var series projectmodels.MRVSeries
+	h.Expect(http.StatusOK, http.MethodGet, chart, nil, "").Decode(t, &series)
+	if series.Sold != 8 || series.Retired > series.Sold {
+		t.Errorf("expected 8 sold on checkout and no more retired, got %+v", series)
+	}
+	h.WaitForCertificates(user, order, 10*time.Second)
+
+	h.Expect(http.StatusOK, http.MethodGet, chart, nil, "").Decode(t, &series)
+	if len(series.Points) != 1 || series.Points[0].CumulativeIssued != 400 || series.Points[0].ReportID == nil {
+		t.Fatalf("expected the verified period charted with its report, got %+v", series.Points)
+	}
+	// Both came after the verification, so they show in the totals only
+	if series.Points[0].CumulativeSold != 0 || series.Points[0].CumulativeRetired != 0 {
+		t.Errorf("expected nothing sold or retired by the verification date, got %+v", series.Points[0])
+	}
+	if series.Issued != 400 || series.Sold != 8 || series.Retired != 8 {
+		t.Errorf("expected 400 issued and 8 sold and retired, got %+v", series)
+	}
+}
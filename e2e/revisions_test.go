@@ -0,0 +1,83 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	projectmodels "project_service/models"
+)
+
+func TestProjectRevisionsAndRevert(t *testing.T) {
+	h := Start(t)
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+	other := h.RegisterAdmin("Pricing Admin", "pricing@example.com", "pr1cingpass")
+
+	project := h.CreateProject(admin, projectmodels.CreateProjectRequest{
+		Title:                "Kenya Clean Cookstoves",
+		Description:          "Efficient cookstoves for rural households",
+		Category:             "energy",
+		Region:               "Africa",
+		Country:              "Kenya",
+		VerificationStandard: "Gold Standard",
+		PricePerTonne:        8,
+		TotalCapacity:        500,
+		AvailableCapacity:    500,
+	})
+	admin1 := fmt.Sprintf("/api/v1/projects/admin/%d", project.ID)
+	h.Expect(http.StatusOK, http.MethodPut, admin1,
+		map[string]interface{}{"price_per_tonne": 80, "reason": "new registry price"}, other.Token)
+
+	var history struct {
+		Revisions []projectmodels.ProjectRevision `json:"revisions"`
+	}
+	h.Expect(http.StatusOK, http.MethodGet, admin1+"/revisions", nil, admin.Token).Decode(t, &history)
+	if len(history.Revisions) != 2 {
+		t.Fatalf("expected the create and the update, got %+v", history.Revisions)
+	}
+	update, create := history.Revisions[0], history.Revisions[1]
+	if update.ActorID != other.ID || update.Reason != "new registry price" || update.Changes["price_per_tonne"].To != 80.0 {
+		t.Errorf("expected the update attributed to its admin, got %+v", update)
+	}
+	if create.ActorID != admin.ID {
+		t.Errorf("expected the create attributed to its admin, got %+v", create)
+	}
+
+	var reverted projectmodels.Project
+	h.Expect(http.StatusOK, http.MethodPost, fmt.Sprintf("%s/revisions/%d/revert", admin1, create.ID),
+		map[string]string{"reason": "price was a typo"}, admin.Token).Decode(t, &reverted)
+	if reverted.PricePerTonne != 8 {
+		t.Errorf("expected the original price back, got %v", reverted.PricePerTonne)
+	}
+
+	// The change history is for admins only
+	h.Expect(http.StatusUnauthorized, http.MethodGet, admin1+"/revisions", nil, "")
+}
+
+func TestPatchProjectCapacityToZero(t *testing.T) {
+	h := Start(t)
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+
+	project := h.CreateProject(admin, projectmodels.CreateProjectRequest{
+		Title:                "Gujarat Wind Farm",
+		Description:          "Grid-connected wind energy",
+		Category:             "energy",
+		Region:               "Asia",
+		Country:              "India",
+		VerificationStandard: "VCS",
+		PricePerTonne:        5,
+		TotalCapacity:        2000,
+		AvailableCapacity:    1500,
+	})
+	admin1 := fmt.Sprintf("/api/v1/projects/admin/%d", project.ID)
+
+	var patched projectmodels.Project
+	h.Expect(http.StatusOK, http.MethodPatch, admin1+"?reason=sold+out",
+		map[string]interface{}{"available_capacity": 0}, admin.Token).Decode(t, &patched)
+	if patched.AvailableCapacity != 0 || patched.TotalCapacity != 2000 {
+		t.Errorf("expected only the available capacity cleared, got %+v", patched)
+	}
+
+	h.Expect(http.StatusUnprocessableEntity, http.MethodPatch, admin1,
+		map[string]interface{}{"available_capacity": 2500}, admin.Token)
+}
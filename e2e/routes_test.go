@@ -0,0 +1,39 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+
+	"api_gateway/proxy"
+)
+
+func TestGatewayRoutesExistOnServices(t *testing.T) {
+	h := Start(t)
+
+	for _, drift := range h.RouteDrift() {
+		t.Errorf("gateway route has no matching service route: %s", drift)
+	}
+}
+
+func TestGatewayEnforcesRouteAuth(t *testing.T) {
+	h := Start(t)
+	user := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+
+	for _, route := range proxy.Routes() {
+		if route.Auth == proxy.Public {
+			continue
+		}
+
+		t.Run(route.Method+" "+route.Path, func(t *testing.T) {
+			if res := h.Do(route.Method, route.Path, nil, ""); res.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expected 401 without a token, got %d", res.StatusCode)
+			}
+
+			if route.Auth == proxy.AdminAuth {
+				if res := h.Do(route.Method, route.Path, nil, user.Token); res.StatusCode != http.StatusUnauthorized {
+					t.Errorf("expected 401 with a user token, got %d", res.StatusCode)
+				}
+			}
+		})
+	}
+}
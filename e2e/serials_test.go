@@ -0,0 +1,78 @@
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	projectmodels "project_service/models"
+)
+
+func TestCertificateStatesRetiredSerials(t *testing.T) {
+	h := Start(t)
+
+	admin := h.RegisterAdmin("Platform Admin", "admin@example.com", "adm1npass")
+	reviewer := h.RegisterAdmin("Project Reviewer", "reviewer@example.com", "rev1ewpass")
+	project := h.CreateProject(admin, projectmodels.CreateProjectRequest{
+		Title:                "Rimba Raya Peatland",
+		Description:          "Protected tropical peat swamp forest",
+		Category:             "forestry",
+		Region:               "Asia",
+		Country:              "Indonesia",
+		VerificationStandard: "Verra",
+		PricePerTonne:        14,
+		TotalCapacity:        1000,
+		AvailableCapacity:    1000,
+	})
+	h.PublishProject(admin, reviewer, project.ID)
+
+	var vintage projectmodels.Vintage
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/projects/admin/%d/vintages", project.ID),
+		projectmodels.CreateVintageRequest{Year: 2021, PricePerTonne: 14, TotalCapacity: 100, AvailableCapacity: 100}, admin.Token).Decode(t, &vintage)
+	h.Expect(http.StatusCreated, http.MethodPost, fmt.Sprintf("/api/v1/projects/admin/%d/serials", project.ID),
+		projectmodels.IssueSerialsRequest{VintageID: vintage.ID, Registry: "Verra", Prefix: "VCS-674-2021", Start: 1, End: 100}, admin.Token)
+
+	// Part of a tonne cannot be bought, as it would retire no serial
+	user := h.RegisterUser("Jane Doe", "jane@example.com", "s3cretpass")
+	cart := fmt.Sprintf("/api/v1/cart/%d/items", user.ID)
+	h.Expect(http.StatusUnprocessableEntity, http.MethodPost, cart,
+		map[string]interface{}{"project_id": project.ID, "tonnes": 2.5}, user.Token)
+	h.AddToCart(user, project.ID, 5)
+	h.Expect(http.StatusCreated, http.MethodPost, cart,
+		map[string]interface{}{"project_id": project.ID, "vintage_id": vintage.ID, "tonnes": 3}, user.Token)
+	order := h.Checkout(user)
+
+	// Each line retires its own serials
+	certificates := h.WaitForCertificates(user, order, 10*time.Second)
+	for i, want := range []string{"VCS-674-2021-1-5", "VCS-674-2021-6-8"} {
+		if serials := certificates[i].Serials; len(serials) != 1 || serials[0] != want {
+			t.Errorf("expected the certificate of line %d to state %s, got %v", i+1, want, serials)
+		}
+	}
+
+	// Anyone can trace a serial to its order line and a line to its serials
+	for serial, line := range map[string]string{"VCS-674-2021-3": order.ID.Hex() + "-1", "VCS-674-2021-7": order.ID.Hex() + "-2"} {
+		var found struct {
+			OrderID string `json:"order_id"`
+		}
+		h.Expect(http.StatusOK, http.MethodGet, "/api/v1/projects/serials/"+serial, nil, "").Decode(t, &found)
+		if found.OrderID != line {
+			t.Errorf("expected %s to belong to %s, got %q", serial, line, found.OrderID)
+		}
+	}
+	var allocation projectmodels.SerialAllocation
+	h.Expect(http.StatusOK, http.MethodGet, "/api/v1/projects/serials/orders/"+order.ID.Hex(), nil, "").Decode(t, &allocation)
+	if allocation.Quantity != 8 || len(allocation.Blocks) != 2 {
+		t.Errorf("expected the 8 serials of both lines, got %+v", allocation)
+	}
+
+	var serials struct {
+		Available int64 `json:"available"`
+	}
+	h.Expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/api/v1/projects/admin/%d/serials", project.ID), nil, admin.Token).
+		Decode(t, &serials)
+	if serials.Available != 92 {
+		t.Errorf("expected the rest of the block to stay available, got %d", serials.Available)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -14,16 +13,11 @@ import (
 var MongoDBClient *mongo.Client
 var MongoDBDatabase *mongo.Database
 
-func ConnectMongoDB() {
+func ConnectMongoDB(cfg MongoDBConfig) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	mongoURI := os.Getenv("MONGODB_URI")
-	if mongoURI == "" {
-		mongoURI = "mongodb://localhost:27017"
-	}
-
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
 	if err != nil {
 		log.Fatal("Failed to connect to MongoDB:", err)
 	}
@@ -35,13 +29,7 @@ func ConnectMongoDB() {
 	}
 
 	MongoDBClient = client
-
-	// Get database name from environment variable
-	dbName := os.Getenv("MONGODB_DATABASE")
-	if dbName == "" {
-		dbName = "carbon_clear_orders"
-	}
-	MongoDBDatabase = client.Database(dbName)
+	MongoDBDatabase = client.Database(cfg.Database)
 
 	fmt.Println("Connected to MongoDB successfully")
 }
@@ -7,17 +7,18 @@ import (
 
 	"order_service/models"
 	"order_service/repositories"
+	"shared/problem"
 
 	"github.com/labstack/echo/v4"
 )
 
 type AdminHandler struct {
-	orderRepo *repositories.OrderRepository
+	orderRepo repositories.OrderRepository
 }
 
-func NewAdminHandler() *AdminHandler {
+func NewAdminHandler(orderRepo repositories.OrderRepository) *AdminHandler {
 	return &AdminHandler{
-		orderRepo: repositories.NewOrderRepository(),
+		orderRepo: orderRepo,
 	}
 }
 
@@ -31,8 +32,8 @@ func NewAdminHandler() *AdminHandler {
 // @Param year query int false "Year"
 // @Param month query int false "Month (1-12)"
 // @Success 200 {object} models.MonthlyReport "Monthly report"
-// @Failure 400 {object} map[string]string "Invalid year or month"
-// @Failure 500 {object} map[string]string "Failed to generate monthly report"
+// @Failure 400 {object} problem.Problem "Invalid year or month"
+// @Failure 500 {object} problem.Problem "Failed to generate monthly report"
 // @Router /api/v1/admin/reports/monthly [get]
 func (h *AdminHandler) GetMonthlyReport(c echo.Context) error {
 	yearStr := c.QueryParam("year")
@@ -47,21 +48,21 @@ func (h *AdminHandler) GetMonthlyReport(c echo.Context) error {
 
 	year, err := strconv.Atoi(yearStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid year"})
+		return problem.BadRequest("Invalid year")
 	}
 
 	month, err := strconv.Atoi(monthStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid month"})
+		return problem.BadRequest("Invalid month")
 	}
 
 	if month < 1 || month > 12 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Month must be between 1 and 12"})
+		return problem.BadRequest("Month must be between 1 and 12")
 	}
 
 	report, err := h.orderRepo.GetMonthlyReport(year, month)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate monthly report"})
+		return problem.Internal("Failed to generate monthly report", err)
 	}
 
 	return c.JSON(http.StatusOK, report)
@@ -77,30 +78,30 @@ func (h *AdminHandler) GetMonthlyReport(c echo.Context) error {
 // @Param start_date query string true "Start date (YYYY-MM-DD)"
 // @Param end_date query string true "End date (YYYY-MM-DD)"
 // @Success 200 {array} models.OrderResponse "List of orders"
-// @Failure 400 {object} map[string]string "Invalid date format or missing parameters"
-// @Failure 500 {object} map[string]string "Failed to retrieve orders"
+// @Failure 400 {object} problem.Problem "Invalid date format or missing parameters"
+// @Failure 500 {object} problem.Problem "Failed to retrieve orders"
 // @Router /api/v1/admin/orders/date-range [get]
 func (h *AdminHandler) GetOrdersByDateRange(c echo.Context) error {
 	startDateStr := c.QueryParam("start_date")
 	endDateStr := c.QueryParam("end_date")
 
 	if startDateStr == "" || endDateStr == "" {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "start_date and end_date are required"})
+		return problem.BadRequest("start_date and end_date are required")
 	}
 
 	startDate, err := time.Parse("2006-01-02", startDateStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid start_date format. Use YYYY-MM-DD"})
+		return problem.BadRequest("Invalid start_date format. Use YYYY-MM-DD")
 	}
 
 	endDate, err := time.Parse("2006-01-02", endDateStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid end_date format. Use YYYY-MM-DD"})
+		return problem.BadRequest("Invalid end_date format. Use YYYY-MM-DD")
 	}
 
 	orders, err := h.orderRepo.GetOrdersByDateRange(startDate, endDate)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve orders"})
+		return problem.Internal("Failed to retrieve orders", err)
 	}
 
 	var responses []models.OrderResponse
@@ -108,9 +109,8 @@ func (h *AdminHandler) GetOrdersByDateRange(c echo.Context) error {
 		responses = append(responses, models.OrderResponse{
 			ID:             order.ID,
 			UserID:         order.UserID,
-			ProjectID:      order.ProjectID,
+			Lines:          order.Lines,
 			Tonnes:         order.Tonnes,
-			PricePerTonne:  order.PricePerTonne,
 			TotalAmount:    order.TotalAmount,
 			Status:         order.Status,
 			PaymentID:      order.PaymentID,
@@ -131,14 +131,14 @@ func (h *AdminHandler) GetOrdersByDateRange(c echo.Context) error {
 // @Produce json
 // @Security AdminAuth
 // @Success 200 {object} map[string]interface{} "Order statistics"
-// @Failure 500 {object} map[string]string "Failed to generate statistics"
+// @Failure 500 {object} problem.Problem "Failed to generate statistics"
 // @Router /api/v1/admin/statistics [get]
 func (h *AdminHandler) GetOrderStatistics(c echo.Context) error {
 	// Get current month statistics
 	now := time.Now()
 	report, err := h.orderRepo.GetMonthlyReport(now.Year(), int(now.Month()))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate statistics"})
+		return problem.Internal("Failed to generate statistics", err)
 	}
 
 	// Get last month statistics for comparison
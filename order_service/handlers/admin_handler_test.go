@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"order_service/models"
+	"order_service/repositories"
+)
+
+func TestGetMonthlyReport(t *testing.T) {
+	repo := repositories.NewInMemoryOrderRepository()
+	repo.CreateOrder(&models.Order{UserID: 7, Tonnes: 2, TotalAmount: 100, Status: "completed"})
+	repo.CreateOrder(&models.Order{UserID: 7, Tonnes: 3, TotalAmount: 150, Status: "completed"})
+	repo.CreateOrder(&models.Order{UserID: 8, Tonnes: 9, TotalAmount: 450, Status: "pending"})
+	h := NewAdminHandler(repo)
+
+	now := time.Now().UTC()
+	rec := serve(t, h.GetMonthlyReport, http.MethodGet,
+		"/?year="+now.Format("2006")+"&month="+now.Format("1"), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report models.MonthlyReport
+	decode(t, rec, &report)
+	if report.TotalOrders != 2 || report.TotalTonnes != 5 || report.TotalRevenue != 250 {
+		t.Errorf("expected only completed orders to be counted, got %+v", report)
+	}
+}
+
+func TestGetMonthlyReportRejectsInvalidMonth(t *testing.T) {
+	h := NewAdminHandler(repositories.NewInMemoryOrderRepository())
+
+	rec := serve(t, h.GetMonthlyReport, http.MethodGet, "/?year=2024&month=13", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestGetOrdersByDateRange(t *testing.T) {
+	repo := repositories.NewInMemoryOrderRepository()
+	repo.CreateOrder(&models.Order{UserID: 7, Tonnes: 2, Status: "completed"})
+	h := NewAdminHandler(repo)
+
+	today := time.Now().Format("2006-01-02")
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	rec := serve(t, h.GetOrdersByDateRange, http.MethodGet, "/?start_date="+today+"&end_date="+tomorrow, "")
+	var orders []models.OrderResponse
+	decode(t, rec, &orders)
+	if len(orders) != 1 {
+		t.Errorf("expected 1 order in range, got %d", len(orders))
+	}
+
+	rec = serve(t, h.GetOrdersByDateRange, http.MethodGet, "/?start_date=yesterday&end_date="+today, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed date, got %d", rec.Code)
+	}
+}
+
+func TestGetOrderStatistics(t *testing.T) {
+	repo := repositories.NewInMemoryOrderRepository()
+	repo.CreateOrder(&models.Order{UserID: 7, Tonnes: 2, TotalAmount: 100, Status: "completed"})
+	h := NewAdminHandler(repo)
+
+	rec := serve(t, h.GetOrderStatistics, http.MethodGet, "/", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var stats map[string]map[string]interface{}
+	decode(t, rec, &stats)
+	if stats["current_month"]["total_orders"] != float64(1) {
+		t.Errorf("unexpected current month: %v", stats["current_month"])
+	}
+	if stats["growth"]["orders_growth"] != float64(100) {
+		t.Errorf("expected 100%% growth from an empty previous month, got %v", stats["growth"]["orders_growth"])
+	}
+}
@@ -1,64 +1,80 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"order_service/models"
 	"order_service/repositories"
+	"order_service/services"
+	"shared/problem"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 )
 
 type CartHandler struct {
-	cartRepo  *repositories.CartRepository
-	validator *validator.Validate
+	cartRepo repositories.CartRepository
+	projects services.Projects
 }
 
-func NewCartHandler() *CartHandler {
+func NewCartHandler(cartRepo repositories.CartRepository, projects services.Projects) *CartHandler {
 	return &CartHandler{
-		cartRepo:  repositories.NewCartRepository(),
-		validator: validator.New(),
+		cartRepo: cartRepo,
+		projects: projects,
 	}
 }
 
 // AddToCart adds items to the user's cart
 // @Summary Add item to cart
-// @Description Add a project to the user's shopping cart
+// @Description Add tonnes of a project on sale to the user's shopping cart, of a specific vintage of the project when vintage_id is given. Adding the same project and vintage again adds to its line.
 // @Tags cart
 // @Accept json
 // @Produce json
 // @Param userID path int true "User ID"
 // @Param request body models.AddToCartRequest true "Cart item details"
 // @Success 201 {object} map[string]string "Item added to cart successfully"
-// @Failure 400 {object} map[string]string "Invalid user ID or request body"
-// @Failure 500 {object} map[string]string "Failed to add item to cart"
+// @Failure 400 {object} problem.Problem "Invalid user ID or request body"
+// @Failure 422 {object} problem.Problem "Validation failed, or the project or vintage is not on sale"
+// @Failure 500 {object} problem.Problem "Failed to add item to cart"
 // @Router /api/v1/cart/{userID}/items [post]
 func (h *CartHandler) AddToCart(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	var req models.AddToCartRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
 	}
 
-	if err := h.validator.Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+
+	// The project service knows the vintages of a project and whether it is on sale
+	if _, err := h.projects.Price(req.ProjectID, req.VintageID, req.Tonnes); err != nil {
+		switch {
+		case errors.Is(err, services.ErrProjectNotFound):
+			return problem.Validation(problem.FieldError{Field: "project_id", Message: "is not a project on sale"})
+		case errors.Is(err, services.ErrVintageNotFound):
+			return problem.Validation(problem.FieldError{Field: "vintage_id", Message: "is not a vintage of the project"})
+		default:
+			return problem.Internal("Failed to check the project", err)
+		}
 	}
 
 	cartItem := &models.CartItem{
 		UserID:    uint(userID),
 		ProjectID: req.ProjectID,
+		VintageID: req.VintageID,
 		Tonnes:    req.Tonnes,
 	}
 
 	if err := h.cartRepo.AddToCart(cartItem); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to add item to cart"})
+		return problem.Internal("Failed to add item to cart", err)
 	}
 
 	return c.JSON(http.StatusCreated, map[string]string{"message": "Item added to cart successfully"})
@@ -72,19 +88,19 @@ func (h *CartHandler) AddToCart(c echo.Context) error {
 // @Produce json
 // @Param userID path int true "User ID"
 // @Success 200 {array} models.CartItemResponse "List of cart items"
-// @Failure 400 {object} map[string]string "Invalid user ID"
-// @Failure 500 {object} map[string]string "Failed to retrieve cart"
+// @Failure 400 {object} problem.Problem "Invalid user ID"
+// @Failure 500 {object} problem.Problem "Failed to retrieve cart"
 // @Router /api/v1/cart/{userID} [get]
 func (h *CartHandler) GetCart(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	cartItems, err := h.cartRepo.GetCartByUserID(uint(userID))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve cart"})
+		return problem.Internal("Failed to retrieve cart", err)
 	}
 
 	var responses []models.CartItemResponse
@@ -93,6 +109,7 @@ func (h *CartHandler) GetCart(c echo.Context) error {
 			ID:        item.ID,
 			UserID:    item.UserID,
 			ProjectID: item.ProjectID,
+			VintageID: item.VintageID,
 			Tonnes:    item.Tonnes,
 			CreatedAt: item.CreatedAt,
 			UpdatedAt: item.UpdatedAt,
@@ -104,41 +121,48 @@ func (h *CartHandler) GetCart(c echo.Context) error {
 
 // UpdateCartItem updates the quantity of an item in the cart
 // @Summary Update cart item
-// @Description Update the quantity of a specific item in the cart
+// @Description Update the quantity of a specific item in the cart. vintage_id selects the project's line for that vintage; without it, the line with no vintage.
 // @Tags cart
 // @Accept json
 // @Produce json
 // @Param userID path int true "User ID"
 // @Param projectID path int true "Project ID"
+// @Param vintage_id query int false "Vintage ID"
 // @Param request body models.UpdateCartItemRequest true "Updated cart item details"
 // @Success 200 {object} map[string]string "Cart item updated successfully"
-// @Failure 400 {object} map[string]string "Invalid user ID, project ID, or request body"
-// @Failure 500 {object} map[string]string "Failed to update cart item"
+// @Failure 400 {object} problem.Problem "Invalid user ID, project ID, vintage ID, or request body"
+// @Failure 422 {object} problem.Problem "Tonnes not a positive whole number"
+// @Failure 500 {object} problem.Problem "Failed to update cart item"
 // @Router /api/v1/cart/{userID}/items/{projectID} [put]
 func (h *CartHandler) UpdateCartItem(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	projectIDStr := c.Param("projectID")
 	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	vintageID, err := vintageParam(c)
+	if err != nil {
+		return err
 	}
 
 	var req models.UpdateCartItemRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
 	}
 
-	if err := h.validator.Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := validateRequest(&req); err != nil {
+		return err
 	}
 
-	if err := h.cartRepo.UpdateCartItem(uint(userID), uint(projectID), req.Tonnes); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update cart item"})
+	if err := h.cartRepo.UpdateCartItem(uint(userID), uint(projectID), vintageID, req.Tonnes); err != nil {
+		return problem.Internal("Failed to update cart item", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Cart item updated successfully"})
@@ -146,31 +170,37 @@ func (h *CartHandler) UpdateCartItem(c echo.Context) error {
 
 // RemoveFromCart removes an item from the cart
 // @Summary Remove item from cart
-// @Description Remove a specific item from the user's cart
+// @Description Remove a specific item from the user's cart. vintage_id selects the project's line for that vintage; without it, the line with no vintage.
 // @Tags cart
 // @Accept json
 // @Produce json
 // @Param userID path int true "User ID"
 // @Param projectID path int true "Project ID"
+// @Param vintage_id query int false "Vintage ID"
 // @Success 200 {object} map[string]string "Item removed from cart successfully"
-// @Failure 400 {object} map[string]string "Invalid user ID or project ID"
-// @Failure 500 {object} map[string]string "Failed to remove item from cart"
+// @Failure 400 {object} problem.Problem "Invalid user ID, project ID or vintage ID"
+// @Failure 500 {object} problem.Problem "Failed to remove item from cart"
 // @Router /api/v1/cart/{userID}/items/{projectID} [delete]
 func (h *CartHandler) RemoveFromCart(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	projectIDStr := c.Param("projectID")
 	projectID, err := strconv.ParseUint(projectIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+		return problem.BadRequest("Invalid project ID")
 	}
 
-	if err := h.cartRepo.RemoveFromCart(uint(userID), uint(projectID)); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to remove item from cart"})
+	vintageID, err := vintageParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.cartRepo.RemoveFromCart(uint(userID), uint(projectID), vintageID); err != nil {
+		return problem.Internal("Failed to remove item from cart", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Item removed from cart successfully"})
@@ -184,19 +214,33 @@ func (h *CartHandler) RemoveFromCart(c echo.Context) error {
 // @Produce json
 // @Param userID path int true "User ID"
 // @Success 200 {object} map[string]string "Cart cleared successfully"
-// @Failure 400 {object} map[string]string "Invalid user ID"
-// @Failure 500 {object} map[string]string "Failed to clear cart"
+// @Failure 400 {object} problem.Problem "Invalid user ID"
+// @Failure 500 {object} problem.Problem "Failed to clear cart"
 // @Router /api/v1/cart/{userID} [delete]
 func (h *CartHandler) ClearCart(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	if err := h.cartRepo.ClearCart(uint(userID)); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to clear cart"})
+		return problem.Internal("Failed to clear cart", err)
 	}
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Cart cleared successfully"})
 }
+
+// vintageParam parses the optional vintage_id query parameter, zero when
+// it is absent
+func vintageParam(c echo.Context) (uint, error) {
+	value := c.QueryParam("vintage_id")
+	if value == "" {
+		return 0, nil
+	}
+	vintageID, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, problem.BadRequest("Invalid vintage ID")
+	}
+	return uint(vintageID), nil
+}
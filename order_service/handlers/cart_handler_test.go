@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"order_service/models"
+	"order_service/repositories"
+	"shared/problem"
+)
+
+func TestAddToCart(t *testing.T) {
+	repo := repositories.NewInMemoryCartRepository()
+	h := NewCartHandler(repo, newFakeProjects())
+
+	rec := serve(t, h.AddToCart, http.MethodPost, "/", `{"project_id":1,"tonnes":5}`, "userID", "7")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Adding the same project again accumulates tonnes
+	serve(t, h.AddToCart, http.MethodPost, "/", `{"project_id":1,"tonnes":2}`, "userID", "7")
+
+	items, _ := repo.GetCartByUserID(7)
+	if len(items) != 1 || items[0].Tonnes != 7 {
+		t.Fatalf("expected a single item with 7 tonnes, got %+v", items)
+	}
+}
+
+func TestAddToCartRejectsInvalidInput(t *testing.T) {
+	h := NewCartHandler(repositories.NewInMemoryCartRepository(), newFakeProjects())
+
+	tests := []struct {
+		name   string
+		userID string
+		body   string
+		code   int
+		field  string
+	}{
+		{"invalid user id", "abc", `{"project_id":1,"tonnes":5}`, http.StatusBadRequest, ""},
+		{"missing project", "7", `{"tonnes":5}`, http.StatusUnprocessableEntity, "project_id"},
+		{"non-positive tonnes", "7", `{"project_id":1,"tonnes":0}`, http.StatusUnprocessableEntity, "tonnes"},
+		{"part of a tonne", "7", `{"project_id":1,"tonnes":2.5}`, http.StatusUnprocessableEntity, "tonnes"},
+		{"project not on sale", "7", `{"project_id":9,"tonnes":5}`, http.StatusUnprocessableEntity, "project_id"},
+		{"vintage of another project", "7", `{"project_id":2,"vintage_id":4,"tonnes":5}`, http.StatusUnprocessableEntity, "vintage_id"},
+		{"malformed body", "7", `{"project_id":`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h.AddToCart, http.MethodPost, "/", tt.body, "userID", tt.userID)
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+
+			var body problem.Problem
+			decode(t, rec, &body)
+			if body.Status != tt.code {
+				t.Errorf("expected problem status %d, got %+v", tt.code, body)
+			}
+			if tt.field != "" && (len(body.Errors) != 1 || body.Errors[0].Field != tt.field) {
+				t.Errorf("expected a single error on %q, got %+v", tt.field, body.Errors)
+			}
+		})
+	}
+}
+
+func TestGetCart(t *testing.T) {
+	repo := repositories.NewInMemoryCartRepository()
+	repo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 5})
+	repo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 2, Tonnes: 3})
+	repo.AddToCart(&models.CartItem{UserID: 8, ProjectID: 1, Tonnes: 1})
+	h := NewCartHandler(repo, newFakeProjects())
+
+	rec := serve(t, h.GetCart, http.MethodGet, "/", "", "userID", "7")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var items []models.CartItemResponse
+	decode(t, rec, &items)
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items for user 7, got %+v", items)
+	}
+}
+
+func TestUpdateAndRemoveCartItems(t *testing.T) {
+	repo := repositories.NewInMemoryCartRepository()
+	repo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 5})
+	repo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 2, Tonnes: 3})
+	h := NewCartHandler(repo, newFakeProjects())
+
+	rec := serve(t, h.UpdateCartItem, http.MethodPut, "/", `{"tonnes":10}`, "userID", "7", "projectID", "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	items, _ := repo.GetCartByUserID(7)
+	if items[0].Tonnes != 10 {
+		t.Errorf("expected 10 tonnes, got %v", items[0].Tonnes)
+	}
+	if rec := serve(t, h.UpdateCartItem, http.MethodPut, "/", `{"tonnes":1.5}`, "userID", "7", "projectID", "1"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for part of a tonne, got %d", rec.Code)
+	}
+
+	rec = serve(t, h.RemoveFromCart, http.MethodDelete, "/", "", "userID", "7", "projectID", "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if items, _ := repo.GetCartByUserID(7); len(items) != 1 {
+		t.Errorf("expected 1 remaining item, got %d", len(items))
+	}
+
+	rec = serve(t, h.ClearCart, http.MethodDelete, "/", "", "userID", "7")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if items, _ := repo.GetCartByUserID(7); len(items) != 0 {
+		t.Errorf("expected an empty cart, got %d items", len(items))
+	}
+}
+
+func TestCartLinesPerVintage(t *testing.T) {
+	repo := repositories.NewInMemoryCartRepository()
+	h := NewCartHandler(repo, newFakeProjects())
+
+	for _, body := range []string{
+		`{"project_id":1,"vintage_id":4,"tonnes":2}`,
+		`{"project_id":1,"vintage_id":4,"tonnes":3}`,
+		`{"project_id":1,"vintage_id":5,"tonnes":1}`,
+		`{"project_id":1,"tonnes":6}`,
+	} {
+		if rec := serve(t, h.AddToCart, http.MethodPost, "/", body, "userID", "7"); rec.Code != http.StatusCreated {
+			t.Fatalf("expected 201 for %s, got %d", body, rec.Code)
+		}
+	}
+
+	var items []models.CartItemResponse
+	decode(t, serve(t, h.GetCart, http.MethodGet, "/", "", "userID", "7"), &items)
+	if len(items) != 3 || items[0].VintageID != 4 || items[0].Tonnes != 5 {
+		t.Fatalf("expected a line per vintage with vintage 4 merged, got %+v", items)
+	}
+
+	serve(t, h.UpdateCartItem, http.MethodPut, "/?vintage_id=5", `{"tonnes":8}`, "userID", "7", "projectID", "1")
+	serve(t, h.RemoveFromCart, http.MethodDelete, "/", "", "userID", "7", "projectID", "1")
+
+	lines, _ := repo.GetCartByUserID(7)
+	if len(lines) != 2 || lines[0].Tonnes != 5 || lines[1].VintageID != 5 || lines[1].Tonnes != 8 {
+		t.Errorf("expected vintage 5 updated and the line without a vintage removed, got %+v", lines)
+	}
+
+	if rec := serve(t, h.RemoveFromCart, http.MethodDelete, "/?vintage_id=x", "", "userID", "7", "projectID", "1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid vintage ID, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"order_service/services"
+	"shared/problem"
+
+	"github.com/labstack/echo/v4"
+)
+
+// serve runs a single handler against a JSON request. Path params are given
+// as name/value pairs.
+func serve(t *testing.T, handler echo.HandlerFunc, method, target, body string, params ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	var names, values []string
+	for i := 0; i+1 < len(params); i += 2 {
+		names = append(names, params[i])
+		values = append(values, params[i+1])
+	}
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+
+	if err := handler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+	}
+}
+
+// fakeProjects is a fake project service. Projects on sale have a price per
+// tonne by vintage, with vintage 0 for the project's own price, and tonnes
+// available that reservations hold until they are committed or released.
+type fakeProjects struct {
+	prices       map[uint]map[uint]float64
+	available    map[uint]float64
+	reservations map[string]*fakeReservation
+	commitErr    error
+}
+
+type fakeReservation struct {
+	projectID uint
+	vintageID uint
+	tonnes    float64
+	reference string
+	status    string
+}
+
+// newFakeProjects has projects 1, with vintages 4 and 5, and 2 on sale with
+// 100 tonnes each
+func newFakeProjects() *fakeProjects {
+	return &fakeProjects{
+		prices:       map[uint]map[uint]float64{1: {0: 10, 4: 12, 5: 11}, 2: {0: 20}},
+		available:    map[uint]float64{1: 100, 2: 100},
+		reservations: map[string]*fakeReservation{},
+	}
+}
+
+func (p *fakeProjects) Price(projectID, vintageID uint, tonnes float64) (float64, error) {
+	vintages, ok := p.prices[projectID]
+	if !ok {
+		return 0, services.ErrProjectNotFound
+	}
+	price, ok := vintages[vintageID]
+	if !ok {
+		return 0, services.ErrVintageNotFound
+	}
+	return price, nil
+}
+
+func (p *fakeProjects) Reserve(projectID, vintageID uint, tonnes float64, reference string) (string, error) {
+	if _, ok := p.prices[projectID][vintageID]; !ok {
+		return "", services.ErrProjectNotFound
+	}
+	for id, reservation := range p.reservations {
+		if reservation.reference == reference && (reservation.status == "held" || reservation.status == "committed") {
+			return id, nil
+		}
+	}
+	if p.available[projectID] < tonnes {
+		return "", services.ErrInsufficientCapacity
+	}
+	p.available[projectID] -= tonnes
+	id := fmt.Sprintf("res-%d", len(p.reservations)+1)
+	p.reservations[id] = &fakeReservation{projectID: projectID, vintageID: vintageID, tonnes: tonnes, reference: reference, status: "held"}
+	return id, nil
+}
+
+// Commit commits all the reservations or, with commitErr set, none
+func (p *fakeProjects) Commit(reservationIDs []string) error {
+	if p.commitErr != nil {
+		return p.commitErr
+	}
+	for _, id := range reservationIDs {
+		p.reservations[id].status = "committed"
+	}
+	return nil
+}
+
+func (p *fakeProjects) Release(reservationID string) error {
+	reservation := p.reservations[reservationID]
+	switch reservation.status {
+	case "committed":
+		return services.ErrReservationNotHeld
+	case "released":
+		return nil
+	}
+	reservation.status = "released"
+	p.available[reservation.projectID] += reservation.tonnes
+	return nil
+}
+
+// statuses returns the status of each reservation by its reference
+func (p *fakeProjects) statuses() map[string]string {
+	statuses := map[string]string{}
+	for _, reservation := range p.reservations {
+		statuses[reservation.reference] = reservation.status
+	}
+	return statuses
+}
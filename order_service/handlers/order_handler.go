@@ -2,147 +2,174 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 
-	"order_service/config"
+	"order_service/messaging"
 	"order_service/models"
 	"order_service/repositories"
+	"order_service/services"
+	"shared/problem"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
-	"github.com/streadway/amqp"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// Problem codes of checkout
+const (
+	// codeCartEmpty is checking out an empty cart
+	codeCartEmpty = "cart_empty"
+	// codeNotOnSale is checking out a line whose project or vintage is no
+	// longer on sale
+	codeNotOnSale = "not_on_sale"
+	// codeWholeTonnes is checking out a line of part of a tonne, put in the
+	// cart before carts held whole tonnes only
+	codeWholeTonnes = "whole_tonnes"
+	// codeInsufficientCapacity is checking out more tonnes of a project than
+	// it has available
+	codeInsufficientCapacity = "insufficient_capacity"
+	// codeOrderNotPending is cancelling or confirming an order that is no
+	// longer pending
+	codeOrderNotPending = "order_not_pending"
+	// codeReservationExpired is confirming an order whose reservations
+	// expired or were released first; the order is cancelled
+	codeReservationExpired = "reservation_expired"
+	// codeOrderPending is an order whose confirmation failed and may yet
+	// have gone through; it stays pending until confirmed or cancelled
+	codeOrderPending = "order_pending"
+	// codeOrderPaid is cancelling a pending order whose tonnes were
+	// committed by a confirmation that seemed to fail
+	codeOrderPaid = "order_paid"
+)
+
 type OrderHandler struct {
-	orderRepo       *repositories.OrderRepository
-	cartRepo        *repositories.CartRepository
-	certRepo        *repositories.CertificateRepository
-	validator       *validator.Validate
-	rabbitMQChannel *amqp.Channel
+	orderRepo repositories.OrderRepository
+	cartRepo  repositories.CartRepository
+	certRepo  repositories.CertificateRepository
+	projects  services.Projects
+	publisher messaging.Publisher
+	queue     string
 }
 
-func NewOrderHandler() *OrderHandler {
+func NewOrderHandler(
+	orderRepo repositories.OrderRepository,
+	cartRepo repositories.CartRepository,
+	certRepo repositories.CertificateRepository,
+	projects services.Projects,
+	publisher messaging.Publisher,
+	certificateQueue string,
+) *OrderHandler {
 	return &OrderHandler{
-		orderRepo:       repositories.NewOrderRepository(),
-		cartRepo:        repositories.NewCartRepository(),
-		certRepo:        repositories.NewCertificateRepository(),
-		validator:       validator.New(),
-		rabbitMQChannel: config.GetRabbitMQChannel(),
+		orderRepo: orderRepo,
+		cartRepo:  cartRepo,
+		certRepo:  certRepo,
+		projects:  projects,
+		publisher: publisher,
+		queue:     certificateQueue,
 	}
 }
 
 // Checkout processes the user's cart and creates an order
 // @Summary Checkout cart
-// @Description Process checkout and create an order from cart items
+// @Description Create an order with a line per cart item, each priced by the project service: at its vintage's price, or at the project's quote for the tonnes. The lines' tonnes are reserved and committed together once paid; each line gets its own certificate. When the commit fails without an answer the order stays pending, to be confirmed or cancelled.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Param userID path int true "User ID"
 // @Param request body models.CheckoutRequest true "Checkout details"
 // @Success 201 {object} models.OrderResponse "Order created successfully"
-// @Failure 400 {object} map[string]string "Invalid user ID, request body, or empty cart"
-// @Failure 500 {object} map[string]string "Failed to create order"
+// @Failure 400 {object} problem.Problem "Invalid user ID, request body, or empty cart"
+// @Failure 409 {object} problem.Problem "A project or vintage in the cart is no longer on sale or has too little capacity, a line is not of whole tonnes, or the reservations expired"
+// @Failure 500 {object} problem.Problem "Failed to create order"
+// @Failure 503 {object} problem.Problem "Order could not be confirmed and stays pending"
 // @Router /api/v1/orders/{userID}/checkout [post]
 func (h *OrderHandler) Checkout(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	var req models.CheckoutRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
 	}
 
-	if err := h.validator.Struct(req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	if err := validateRequest(&req); err != nil {
+		return err
 	}
 
 	// Get cart items
 	cartItems, err := h.cartRepo.GetCartByUserID(uint(userID))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve cart"})
+		return problem.Internal("Failed to retrieve cart", err)
 	}
 
 	if len(cartItems) == 0 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Cart is empty"})
-	}
-
-	// For now, we'll create a single order for all cart items
-	// In a real implementation, you might want to create separate orders per project
-	var totalAmount float64
-	var totalTonnes float64
-
-	for _, item := range cartItems {
-		// In a real implementation, you would fetch project details to get price per tonne
-		// For now, we'll use a mock price
-		pricePerTonne := 50.0 // This should come from project service
-		itemTotal := item.Tonnes * pricePerTonne
-		totalAmount += itemTotal
-		totalTonnes += item.Tonnes
+		return problem.BadRequest("Cart is empty").WithCode(codeCartEmpty)
 	}
 
-	// Create order
+	// Price every line at the project service's current price
 	order := &models.Order{
-		UserID:        uint(userID),
-		ProjectID:     cartItems[0].ProjectID, // Using first project ID for simplicity
-		Tonnes:        totalTonnes,
-		PricePerTonne: 50.0, // Mock price
-		TotalAmount:   totalAmount,
-		Status:        "pending",
-		PaymentID:     fmt.Sprintf("pay_%d_%d", userID, time.Now().Unix()),
-	}
-
-	if err := h.orderRepo.CreateOrder(order); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create order"})
-	}
-
-	// Process mock payment
-	order.Status = "completed"
-	if err := h.orderRepo.UpdateOrderStatus(order.ID, "completed"); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update order status"})
+		UserID:    uint(userID),
+		Status:    "pending",
+		PaymentID: fmt.Sprintf("pay_%d_%d", userID, time.Now().Unix()),
 	}
-
-	// Clear cart after successful checkout
-	if err := h.cartRepo.ClearCart(uint(userID)); err != nil {
-		// Log error but don't fail the checkout
-		fmt.Printf("Warning: Failed to clear cart for user %d: %v\n", userID, err)
+	for _, item := range cartItems {
+		line := models.OrderLine{
+			ProjectID: item.ProjectID,
+			VintageID: item.VintageID,
+			Tonnes:    item.Tonnes,
+		}
+		// Each tonne is retired with its own serial
+		if line.Tonnes != math.Trunc(line.Tonnes) {
+			return problem.Conflict(fmt.Sprintf("Project %d is in the cart with %g tonnes; only whole tonnes can be bought", line.ProjectID, line.Tonnes)).WithCode(codeWholeTonnes)
+		}
+		pricePerTonne, err := h.projects.Price(line.ProjectID, line.VintageID, line.Tonnes)
+		if err != nil {
+			return orderLineProblem(err, line)
+		}
+		line.PricePerTonne = pricePerTonne
+		line.Amount = line.Tonnes * pricePerTonne
+		order.Lines = append(order.Lines, line)
+		order.Tonnes += line.Tonnes
+		order.TotalAmount += line.Amount
 	}
 
-	// Create certificate record
-	certificate := &models.Certificate{
-		OrderID:   order.ID,
-		UserID:    uint(userID),
-		ProjectID: order.ProjectID,
-		Tonnes:    order.Tonnes,
-		Status:    "pending",
+	// Hold every line's tonnes until the order is paid. The order ID is
+	// chosen up front for the reservations to name its lines.
+	order.ID = primitive.NewObjectID()
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		reservationID, err := h.projects.Reserve(line.ProjectID, line.VintageID, line.Tonnes, models.LineReference(order.ID, i+1))
+		if err != nil {
+			h.releaseReservations(order.Lines[:i])
+			return orderLineProblem(err, *line)
+		}
+		line.ReservationID = reservationID
 	}
 
-	if err := h.certRepo.CreateCertificate(certificate); err != nil {
-		// Log error but don't fail the checkout
-		fmt.Printf("Warning: Failed to create certificate record: %v\n", err)
+	if err := h.orderRepo.CreateOrder(order); err != nil {
+		h.releaseReservations(order.Lines)
+		return problem.Internal("Failed to create order", err)
 	}
 
-	// Send certificate generation message to RabbitMQ
-	if err := h.sendCertificateGenerationMessage(order, certificate); err != nil {
-		// Log error but don't fail the checkout
-		fmt.Printf("Warning: Failed to send certificate generation message: %v\n", err)
+	// Process mock payment, then keep the tonnes paid for
+	if err := h.confirm(order); err != nil {
+		return err
 	}
 
 	// Convert to response
 	orderResponse := models.OrderResponse{
 		ID:             order.ID,
 		UserID:         order.UserID,
-		ProjectID:      order.ProjectID,
+		Lines:          order.Lines,
 		Tonnes:         order.Tonnes,
-		PricePerTonne:  order.PricePerTonne,
 		TotalAmount:    order.TotalAmount,
 		Status:         order.Status,
 		PaymentID:      order.PaymentID,
@@ -162,19 +189,19 @@ func (h *OrderHandler) Checkout(c echo.Context) error {
 // @Produce json
 // @Param userID path int true "User ID"
 // @Success 200 {array} models.OrderResponse "List of orders"
-// @Failure 400 {object} map[string]string "Invalid user ID"
-// @Failure 500 {object} map[string]string "Failed to retrieve order history"
+// @Failure 400 {object} problem.Problem "Invalid user ID"
+// @Failure 500 {object} problem.Problem "Failed to retrieve order history"
 // @Router /api/v1/orders/{userID}/history [get]
 func (h *OrderHandler) GetOrderHistory(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	orders, err := h.orderRepo.GetOrdersByUserID(uint(userID))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve order history"})
+		return problem.Internal("Failed to retrieve order history", err)
 	}
 
 	var responses []models.OrderResponse
@@ -182,9 +209,8 @@ func (h *OrderHandler) GetOrderHistory(c echo.Context) error {
 		responses = append(responses, models.OrderResponse{
 			ID:             order.ID,
 			UserID:         order.UserID,
-			ProjectID:      order.ProjectID,
+			Lines:          order.Lines,
 			Tonnes:         order.Tonnes,
-			PricePerTonne:  order.PricePerTonne,
 			TotalAmount:    order.TotalAmount,
 			Status:         order.Status,
 			PaymentID:      order.PaymentID,
@@ -205,9 +231,9 @@ func (h *OrderHandler) GetOrderHistory(c echo.Context) error {
 // @Produce json
 // @Param orderID path string true "Order ID"
 // @Success 200 {object} models.OrderResponse "Order retrieved successfully"
-// @Failure 400 {object} map[string]string "Invalid order ID"
-// @Failure 404 {object} map[string]string "Order not found"
-// @Failure 500 {object} map[string]string "Failed to retrieve order"
+// @Failure 400 {object} problem.Problem "Invalid order ID"
+// @Failure 404 {object} problem.Problem "Order not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve order"
 // @Router /api/v1/orders/{orderID} [get]
 func (h *OrderHandler) GetOrder(c echo.Context) error {
 	orderIDStr := c.Param("orderID")
@@ -215,25 +241,24 @@ func (h *OrderHandler) GetOrder(c echo.Context) error {
 	// Convert string to MongoDB ObjectID
 	objectID, err := primitive.ObjectIDFromHex(orderIDStr)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid order ID format"})
+		return problem.BadRequest("Invalid order ID format")
 	}
 
 	// Retrieve order from database
 	order, err := h.orderRepo.GetOrderByID(objectID)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Order not found"})
+			return problem.NotFound("Order not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve order"})
+		return problem.Internal("Failed to retrieve order", err)
 	}
 
 	// Convert to response
 	orderResponse := models.OrderResponse{
 		ID:             order.ID,
 		UserID:         order.UserID,
-		ProjectID:      order.ProjectID,
+		Lines:          order.Lines,
 		Tonnes:         order.Tonnes,
-		PricePerTonne:  order.PricePerTonne,
 		TotalAmount:    order.TotalAmount,
 		Status:         order.Status,
 		PaymentID:      order.PaymentID,
@@ -245,6 +270,116 @@ func (h *OrderHandler) GetOrder(c echo.Context) error {
 	return c.JSON(http.StatusOK, orderResponse)
 }
 
+// CancelOrder cancels a pending order
+// @Summary Cancel order
+// @Description Cancel an order that is still pending, such as one whose payment never completed, and return the tonnes it holds to its projects. Completed orders have been paid for and cannot be cancelled, nor can a pending order whose tonnes were committed by a confirmation that seemed to fail; confirm it instead.
+// @Tags orders
+// @Produce json
+// @Param orderID path string true "Order ID"
+// @Success 200 {object} models.OrderResponse "Order cancelled"
+// @Failure 400 {object} problem.Problem "Invalid order ID"
+// @Failure 404 {object} problem.Problem "Order not found"
+// @Failure 409 {object} problem.Problem "Order is not pending or has been paid for"
+// @Failure 500 {object} problem.Problem "Failed to cancel order"
+// @Router /api/v1/orders/{orderID}/cancel [post]
+func (h *OrderHandler) CancelOrder(c echo.Context) error {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("orderID"))
+	if err != nil {
+		return problem.BadRequest("Invalid order ID format")
+	}
+
+	order, err := h.orderRepo.GetOrderByID(objectID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return problem.NotFound("Order not found")
+		}
+		return problem.Internal("Failed to retrieve order", err)
+	}
+	if order.Status != "pending" {
+		return problem.Conflict(fmt.Sprintf("Order is %s, only pending orders can be cancelled", order.Status)).WithCode(codeOrderNotPending)
+	}
+
+	// The lines are committed together, so a committed line means the
+	// order was paid for
+	for _, line := range order.Lines {
+		if line.ReservationID == "" {
+			continue
+		}
+		err := h.projects.Release(line.ReservationID)
+		if errors.Is(err, services.ErrReservationNotHeld) {
+			return problem.Conflict("Order has been paid for; confirm it instead").WithCode(codeOrderPaid)
+		}
+		if err != nil {
+			return problem.Internal("Failed to release the order's tonnes", err)
+		}
+	}
+	if err := h.orderRepo.UpdateOrderStatus(order.ID, "cancelled"); err != nil {
+		return problem.Internal("Failed to cancel order", err)
+	}
+	order.Status = "cancelled"
+
+	return c.JSON(http.StatusOK, models.OrderResponse{
+		ID:             order.ID,
+		UserID:         order.UserID,
+		Lines:          order.Lines,
+		Tonnes:         order.Tonnes,
+		TotalAmount:    order.TotalAmount,
+		Status:         order.Status,
+		PaymentID:      order.PaymentID,
+		CertificateURL: order.CertificateURL,
+		CreatedAt:      order.CreatedAt,
+		UpdatedAt:      order.UpdatedAt,
+	})
+}
+
+// ConfirmOrder confirms a pending order
+// @Summary Confirm order
+// @Description Commit the reservations of an order left pending by a checkout whose confirmation failed, and complete it. Committing is idempotent, so this is safe whether or not the failed confirmation went through. When the reservations expired first the order is cancelled.
+// @Tags orders
+// @Produce json
+// @Param orderID path string true "Order ID"
+// @Success 200 {object} models.OrderResponse "Order completed"
+// @Failure 400 {object} problem.Problem "Invalid order ID"
+// @Failure 404 {object} problem.Problem "Order not found"
+// @Failure 409 {object} problem.Problem "Order is not pending, or its reservations expired"
+// @Failure 500 {object} problem.Problem "Failed to confirm order"
+// @Failure 503 {object} problem.Problem "Order could not be confirmed and stays pending"
+// @Router /api/v1/orders/{orderID}/confirm [post]
+func (h *OrderHandler) ConfirmOrder(c echo.Context) error {
+	objectID, err := primitive.ObjectIDFromHex(c.Param("orderID"))
+	if err != nil {
+		return problem.BadRequest("Invalid order ID format")
+	}
+
+	order, err := h.orderRepo.GetOrderByID(objectID)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return problem.NotFound("Order not found")
+		}
+		return problem.Internal("Failed to retrieve order", err)
+	}
+	if order.Status != "pending" {
+		return problem.Conflict(fmt.Sprintf("Order is %s, only pending orders can be confirmed", order.Status)).WithCode(codeOrderNotPending)
+	}
+
+	if err := h.confirm(order); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.OrderResponse{
+		ID:             order.ID,
+		UserID:         order.UserID,
+		Lines:          order.Lines,
+		Tonnes:         order.Tonnes,
+		TotalAmount:    order.TotalAmount,
+		Status:         order.Status,
+		PaymentID:      order.PaymentID,
+		CertificateURL: order.CertificateURL,
+		CreatedAt:      order.CreatedAt,
+		UpdatedAt:      order.UpdatedAt,
+	})
+}
+
 // GetCertificates retrieves the user's certificates
 // @Summary Get user certificates
 // @Description Retrieve all carbon offset certificates for a user
@@ -253,19 +388,19 @@ func (h *OrderHandler) GetOrder(c echo.Context) error {
 // @Produce json
 // @Param userID path int true "User ID"
 // @Success 200 {array} models.CertificateResponse "List of certificates"
-// @Failure 400 {object} map[string]string "Invalid user ID"
-// @Failure 500 {object} map[string]string "Failed to retrieve certificates"
+// @Failure 400 {object} problem.Problem "Invalid user ID"
+// @Failure 500 {object} problem.Problem "Failed to retrieve certificates"
 // @Router /api/v1/orders/{userID}/certificates [get]
 func (h *OrderHandler) GetCertificates(c echo.Context) error {
 	userIDStr := c.Param("userID")
 	userID, err := strconv.ParseUint(userIDStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+		return problem.BadRequest("Invalid user ID")
 	}
 
 	certificates, err := h.certRepo.GetCertificatesByUserID(uint(userID))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve certificates"})
+		return problem.Internal("Failed to retrieve certificates", err)
 	}
 
 	var responses []models.CertificateResponse
@@ -273,11 +408,14 @@ func (h *OrderHandler) GetCertificates(c echo.Context) error {
 		responses = append(responses, models.CertificateResponse{
 			ID:             cert.ID,
 			OrderID:        cert.OrderID,
+			Line:           cert.Line,
 			UserID:         cert.UserID,
 			ProjectID:      cert.ProjectID,
+			VintageID:      cert.VintageID,
 			Tonnes:         cert.Tonnes,
 			CertificateURL: cert.CertificateURL,
 			Status:         cert.Status,
+			Serials:        cert.Serials,
 			CreatedAt:      cert.CreatedAt,
 			UpdatedAt:      cert.UpdatedAt,
 		})
@@ -286,14 +424,17 @@ func (h *OrderHandler) GetCertificates(c echo.Context) error {
 	return c.JSON(http.StatusOK, responses)
 }
 
-func (h *OrderHandler) sendCertificateGenerationMessage(order *models.Order, certificate *models.Certificate) error {
+func (h *OrderHandler) sendCertificateGenerationMessage(certificate *models.Certificate) error {
 	message := models.CertificateGenerationMessage{
-		OrderID:   order.ID,
-		UserID:    order.UserID,
-		ProjectID: order.ProjectID,
-		Tonnes:    order.Tonnes,
-		UserEmail: "user@example.com", // This should come from user service
-		UserName:  "User Name",        // This should come from user service
+		CertificateID: certificate.ID,
+		OrderID:       certificate.OrderID,
+		Line:          certificate.Line,
+		UserID:        certificate.UserID,
+		ProjectID:     certificate.ProjectID,
+		VintageID:     certificate.VintageID,
+		Tonnes:        certificate.Tonnes,
+		UserEmail:     "user@example.com", // This should come from user service
+		UserName:      "User Name",        // This should come from user service
 	}
 
 	messageBody, err := json.Marshal(message)
@@ -301,22 +442,99 @@ func (h *OrderHandler) sendCertificateGenerationMessage(order *models.Order, cer
 		return err
 	}
 
-	// Get queue name from environment variable
-	queueName := os.Getenv("CERTIFICATE_QUEUE_NAME")
-	if queueName == "" {
-		queueName = "certificate_generation"
+	return h.publisher.Publish(h.queue, messageBody)
+}
+
+// confirm commits the reservations of a pending order's lines, all together,
+// and completes the order: the user's cart is cleared and each line gets a
+// certificate. When the reservations are no longer held none was committed,
+// so the order is cancelled. Any other failure may have committed them, so
+// the order stays pending for ConfirmOrder to retry or CancelOrder to undo.
+func (h *OrderHandler) confirm(order *models.Order) error {
+	var reservationIDs []string
+	for _, line := range order.Lines {
+		if line.ReservationID != "" {
+			reservationIDs = append(reservationIDs, line.ReservationID)
+		}
+	}
+	if len(reservationIDs) > 0 {
+		err := h.projects.Commit(reservationIDs)
+		if errors.Is(err, services.ErrReservationNotHeld) {
+			h.releaseReservations(order.Lines)
+			if err := h.orderRepo.UpdateOrderStatus(order.ID, "cancelled"); err != nil {
+				return problem.Internal("Failed to cancel order", err)
+			}
+			return problem.Conflict("The order's reservations expired before it was paid for; it is cancelled").WithCode(codeReservationExpired)
+		}
+		if err != nil {
+			detail := fmt.Sprintf("Order %s could not be confirmed and stays pending; confirm or cancel it", order.ID.Hex())
+			return problem.New(http.StatusServiceUnavailable, detail).WithCode(codeOrderPending).Wrap(err)
+		}
+	}
+
+	order.Status = "completed"
+	if err := h.orderRepo.UpdateOrderStatus(order.ID, "completed"); err != nil {
+		return problem.Internal("Failed to update order status", err)
 	}
 
-	err = h.rabbitMQChannel.Publish(
-		"",        // exchange
-		queueName, // routing key
-		false,     // mandatory
-		false,     // immediate
-		amqp.Publishing{
-			ContentType: "application/json",
-			Body:        messageBody,
-		},
-	)
+	// Clear cart after successful checkout
+	if err := h.cartRepo.ClearCart(order.UserID); err != nil {
+		// Log error but don't fail the checkout
+		fmt.Printf("Warning: Failed to clear cart for user %d: %v\n", order.UserID, err)
+	}
 
-	return err
+	// Each line gets its own certificate
+	for i, line := range order.Lines {
+		certificate := &models.Certificate{
+			OrderID:   order.ID,
+			Line:      i + 1,
+			UserID:    order.UserID,
+			ProjectID: line.ProjectID,
+			VintageID: line.VintageID,
+			Tonnes:    line.Tonnes,
+			Status:    "pending",
+		}
+		if err := h.certRepo.CreateCertificate(certificate); err != nil {
+			// Log error but don't fail the checkout
+			fmt.Printf("Warning: Failed to create certificate record: %v\n", err)
+			continue
+		}
+
+		// Send certificate generation message to RabbitMQ
+		if err := h.sendCertificateGenerationMessage(certificate); err != nil {
+			// Log error but don't fail the checkout
+			fmt.Printf("Warning: Failed to send certificate generation message: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// releaseReservations returns the held tonnes of order lines. A reservation
+// that cannot be released expires on its own.
+func (h *OrderHandler) releaseReservations(lines []models.OrderLine) {
+	for _, line := range lines {
+		if line.ReservationID == "" {
+			continue
+		}
+		if err := h.projects.Release(line.ReservationID); err != nil {
+			fmt.Printf("Warning: Failed to release reservation %s: %v\n", line.ReservationID, err)
+		}
+	}
+}
+
+// orderLineProblem is the problem for an order line the project service
+// cannot price or reserve
+func orderLineProblem(err error, line models.OrderLine) error {
+	switch {
+	case errors.Is(err, services.ErrProjectNotFound):
+		return problem.Conflict(fmt.Sprintf("Project %d is no longer on sale", line.ProjectID)).WithCode(codeNotOnSale)
+	case errors.Is(err, services.ErrVintageNotFound):
+		return problem.Conflict(fmt.Sprintf("Vintage %d of project %d is no longer on sale", line.VintageID, line.ProjectID)).WithCode(codeNotOnSale)
+	case errors.Is(err, services.ErrInsufficientCapacity) && line.VintageID != 0:
+		return problem.Conflict(fmt.Sprintf("Vintage %d of project %d has fewer than %g tonnes available", line.VintageID, line.ProjectID, line.Tonnes)).WithCode(codeInsufficientCapacity)
+	case errors.Is(err, services.ErrInsufficientCapacity):
+		return problem.Conflict(fmt.Sprintf("Project %d has fewer than %g tonnes available", line.ProjectID, line.Tonnes)).WithCode(codeInsufficientCapacity)
+	default:
+		return problem.Internal("Failed to check out the cart", err)
+	}
 }
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"order_service/messaging"
+	"order_service/models"
+	"order_service/repositories"
+	"order_service/services"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type orderFixture struct {
+	handler   *OrderHandler
+	orderRepo *repositories.InMemoryOrderRepository
+	cartRepo  *repositories.InMemoryCartRepository
+	certRepo  *repositories.InMemoryCertificateRepository
+	projects  *fakeProjects
+	broker    *messaging.InMemoryBroker
+}
+
+func newOrderFixture(t *testing.T) *orderFixture {
+	t.Helper()
+	os.Unsetenv("CERTIFICATE_QUEUE_NAME")
+
+	f := &orderFixture{
+		orderRepo: repositories.NewInMemoryOrderRepository(),
+		cartRepo:  repositories.NewInMemoryCartRepository(),
+		certRepo:  repositories.NewInMemoryCertificateRepository(),
+		projects:  newFakeProjects(),
+		broker:    messaging.NewInMemoryBroker(),
+	}
+	f.handler = NewOrderHandler(f.orderRepo, f.cartRepo, f.certRepo, f.projects, f.broker, "certificate_generation")
+	return f
+}
+
+func TestCheckout(t *testing.T) {
+	f := newOrderFixture(t)
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, VintageID: 4, Tonnes: 2})
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 2, Tonnes: 3})
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Each line keeps its project, vintage and price, and its tonnes are
+	// reserved and committed
+	var order models.OrderResponse
+	decode(t, rec, &order)
+	want := []models.OrderLine{
+		{ProjectID: 1, VintageID: 4, Tonnes: 2, PricePerTonne: 12, Amount: 24, ReservationID: "res-1"},
+		{ProjectID: 2, Tonnes: 3, PricePerTonne: 20, Amount: 60, ReservationID: "res-2"},
+	}
+	if order.Status != "completed" || order.Tonnes != 5 || order.TotalAmount != 84 || !reflect.DeepEqual(order.Lines, want) {
+		t.Errorf("unexpected order: %+v", order)
+	}
+	committed := map[string]string{order.ID.Hex() + "-1": "committed", order.ID.Hex() + "-2": "committed"}
+	if statuses := f.projects.statuses(); !reflect.DeepEqual(statuses, committed) || f.projects.available[1] != 98 || f.projects.available[2] != 97 {
+		t.Errorf("expected both lines committed, got %v with %v available", statuses, f.projects.available)
+	}
+	if vintageID := f.projects.reservations["res-1"].vintageID; vintageID != 4 {
+		t.Errorf("expected the vintage line to reserve vintage 4, got %d", vintageID)
+	}
+
+	if items, _ := f.cartRepo.GetCartByUserID(7); len(items) != 0 {
+		t.Errorf("expected the cart to be cleared, got %d items", len(items))
+	}
+
+	// and gets its own certificate and generation message
+	certificates, _ := f.certRepo.GetCertificatesByUserID(7)
+	if len(certificates) != 2 {
+		t.Fatalf("expected a certificate per line, got %+v", certificates)
+	}
+	queue, _ := f.broker.Consume("certificate_generation")
+	for i, line := range want {
+		certificate := certificates[i]
+		if certificate.OrderID != order.ID || certificate.Line != i+1 || certificate.Status != "pending" ||
+			certificate.ProjectID != line.ProjectID || certificate.VintageID != line.VintageID || certificate.Tonnes != line.Tonnes {
+			t.Errorf("expected a pending certificate for line %d, got %+v", i+1, certificate)
+		}
+
+		select {
+		case body := <-queue:
+			var message models.CertificateGenerationMessage
+			if err := json.Unmarshal(body, &message); err != nil {
+				t.Fatal(err)
+			}
+			if message.CertificateID != certificate.ID || message.OrderID != order.ID || message.Line != i+1 ||
+				message.ProjectID != line.ProjectID || message.VintageID != line.VintageID || message.Tonnes != line.Tonnes {
+				t.Errorf("expected a message for line %d, got %+v", i+1, message)
+			}
+		default:
+			t.Fatalf("expected a certificate generation message for line %d", i+1)
+		}
+	}
+}
+
+func TestCheckoutRejectsLinesNoLongerOnSale(t *testing.T) {
+	f := newOrderFixture(t)
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 2})
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 2, VintageID: 4, Tonnes: 3})
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if body["code"] != "not_on_sale" {
+		t.Errorf("expected not_on_sale problem, got %v", body)
+	}
+	if orders, _ := f.orderRepo.GetOrdersByUserID(7); len(orders) != 0 {
+		t.Errorf("expected no order, got %+v", orders)
+	}
+	if items, _ := f.cartRepo.GetCartByUserID(7); len(items) != 2 {
+		t.Errorf("expected the cart kept, got %+v", items)
+	}
+}
+
+func TestCheckoutReleasesReservationsWithoutCapacity(t *testing.T) {
+	f := newOrderFixture(t)
+	f.projects.available[2] = 1
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 2})
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 2, Tonnes: 3})
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if body["code"] != "insufficient_capacity" {
+		t.Errorf("expected insufficient_capacity problem, got %v", body)
+	}
+	if len(f.projects.reservations) != 1 || f.projects.reservations["res-1"].status != "released" || f.projects.available[1] != 100 {
+		t.Errorf("expected the first line's reservation released, got %v", f.projects.statuses())
+	}
+	if orders, _ := f.orderRepo.GetOrdersByUserID(7); len(orders) != 0 {
+		t.Errorf("expected no order, got %+v", orders)
+	}
+}
+
+func TestCheckoutCancelsOrderWhenReservationsExpire(t *testing.T) {
+	f := newOrderFixture(t)
+	f.projects.commitErr = services.ErrReservationNotHeld
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 2})
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 2, Tonnes: 3})
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if body["code"] != "reservation_expired" {
+		t.Errorf("expected reservation_expired problem, got %v", body)
+	}
+	orders, _ := f.orderRepo.GetOrdersByUserID(7)
+	if len(orders) != 1 || orders[0].Status != "cancelled" {
+		t.Fatalf("expected a cancelled order, got %+v", orders)
+	}
+	if f.projects.available[1] != 100 || f.projects.available[2] != 100 {
+		t.Errorf("expected every reservation released, got %v", f.projects.statuses())
+	}
+	if items, _ := f.cartRepo.GetCartByUserID(7); len(items) != 2 {
+		t.Errorf("expected the cart kept, got %+v", items)
+	}
+}
+
+func TestCheckoutLeavesOrderPendingWhenCommitFails(t *testing.T) {
+	f := newOrderFixture(t)
+	f.projects.commitErr = errors.New("connection reset")
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 2})
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 2, Tonnes: 3})
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if body["code"] != "order_pending" {
+		t.Errorf("expected order_pending problem, got %v", body)
+	}
+	orders, _ := f.orderRepo.GetOrdersByUserID(7)
+	if len(orders) != 1 || orders[0].Status != "pending" {
+		t.Fatalf("expected a pending order, got %+v", orders)
+	}
+	held := map[string]string{orders[0].ID.Hex() + "-1": "held", orders[0].ID.Hex() + "-2": "held"}
+	if statuses := f.projects.statuses(); !reflect.DeepEqual(statuses, held) {
+		t.Errorf("expected the reservations kept, got %v", statuses)
+	}
+	if items, _ := f.cartRepo.GetCartByUserID(7); len(items) != 2 {
+		t.Errorf("expected the cart kept, got %+v", items)
+	}
+}
+
+func TestConfirmOrder(t *testing.T) {
+	f := newOrderFixture(t)
+	f.projects.commitErr = errors.New("connection reset")
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, VintageID: 4, Tonnes: 2})
+	serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	orders, _ := f.orderRepo.GetOrdersByUserID(7)
+	if len(orders) != 1 {
+		t.Fatalf("expected a pending order, got %+v", orders)
+	}
+	id := orders[0].ID.Hex()
+
+	f.projects.commitErr = nil
+	rec := serve(t, f.handler.ConfirmOrder, http.MethodPost, "/", "", "orderID", id)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var order models.OrderResponse
+	decode(t, rec, &order)
+	if order.Status != "completed" || f.projects.reservations["res-1"].status != "committed" {
+		t.Errorf("expected the order completed and its tonnes committed, got %+v and %v", order, f.projects.statuses())
+	}
+	if certificates, _ := f.certRepo.GetCertificatesByUserID(7); len(certificates) != 1 || certificates[0].Line != 1 {
+		t.Errorf("expected a certificate for the line, got %+v", certificates)
+	}
+	if items, _ := f.cartRepo.GetCartByUserID(7); len(items) != 0 {
+		t.Errorf("expected the cart cleared, got %+v", items)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		code int
+	}{
+		{"completed order", id, http.StatusConflict},
+		{"unknown order", primitive.NewObjectID().Hex(), http.StatusNotFound},
+		{"invalid id", "not-an-object-id", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, f.handler.ConfirmOrder, http.MethodPost, "/", "", "orderID", tt.id)
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCancelOrder(t *testing.T) {
+	f := newOrderFixture(t)
+	reservationID, _ := f.projects.Reserve(1, 0, 2, "pending-1")
+	pending := &models.Order{UserID: 7, Lines: []models.OrderLine{{ProjectID: 1, Tonnes: 2, ReservationID: reservationID}}, Tonnes: 2, Status: "pending"}
+	completed := &models.Order{UserID: 7, Lines: []models.OrderLine{{ProjectID: 1, Tonnes: 1}}, Tonnes: 1, Status: "completed"}
+	f.orderRepo.CreateOrder(pending)
+	f.orderRepo.CreateOrder(completed)
+
+	rec := serve(t, f.handler.CancelOrder, http.MethodPost, "/", "", "orderID", pending.ID.Hex())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var order models.OrderResponse
+	decode(t, rec, &order)
+	if order.Status != "cancelled" || f.projects.reservations[reservationID].status != "released" || f.projects.available[1] != 100 {
+		t.Errorf("expected the order cancelled and its tonnes released, got %+v and %v", order, f.projects.statuses())
+	}
+
+	// A confirmation that seemed to fail may have committed the tonnes
+	paidID, _ := f.projects.Reserve(1, 0, 3, "paid-1")
+	f.projects.Commit([]string{paidID})
+	paid := &models.Order{UserID: 7, Lines: []models.OrderLine{{ProjectID: 1, Tonnes: 3, ReservationID: paidID}}, Tonnes: 3, Status: "pending"}
+	f.orderRepo.CreateOrder(paid)
+	rec = serve(t, f.handler.CancelOrder, http.MethodPost, "/", "", "orderID", paid.ID.Hex())
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if rec.Code != http.StatusConflict || body["code"] != "order_paid" {
+		t.Errorf("expected 409 order_paid, got %d %v", rec.Code, body)
+	}
+	if stored, _ := f.orderRepo.GetOrderByID(paid.ID); stored.Status != "pending" {
+		t.Errorf("expected the paid order kept pending, got %s", stored.Status)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		code int
+	}{
+		{"cancelled order", pending.ID.Hex(), http.StatusConflict},
+		{"completed order", completed.ID.Hex(), http.StatusConflict},
+		{"unknown order", primitive.NewObjectID().Hex(), http.StatusNotFound},
+		{"invalid id", "not-an-object-id", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, f.handler.CancelOrder, http.MethodPost, "/", "", "orderID", tt.id)
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCheckoutRejectsPartTonnes(t *testing.T) {
+	f := newOrderFixture(t)
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 2.5})
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if body["code"] != "whole_tonnes" {
+		t.Errorf("expected whole_tonnes problem, got %v", body)
+	}
+}
+
+func TestCheckoutRejectsEmptyCart(t *testing.T) {
+	f := newOrderFixture(t)
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{"payment_method":"card"}`, "userID", "7")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var body map[string]interface{}
+	decode(t, rec, &body)
+	if body["code"] != "cart_empty" {
+		t.Errorf("expected cart_empty problem, got %v", body)
+	}
+}
+
+func TestCheckoutRequiresPaymentMethod(t *testing.T) {
+	f := newOrderFixture(t)
+	f.cartRepo.AddToCart(&models.CartItem{UserID: 7, ProjectID: 1, Tonnes: 2})
+
+	rec := serve(t, f.handler.Checkout, http.MethodPost, "/", `{}`, "userID", "7")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetOrder(t *testing.T) {
+	f := newOrderFixture(t)
+	order := &models.Order{UserID: 7, Lines: []models.OrderLine{{ProjectID: 1, Tonnes: 2}}, Tonnes: 2, Status: "completed"}
+	f.orderRepo.CreateOrder(order)
+
+	tests := []struct {
+		name string
+		id   string
+		code int
+	}{
+		{"existing order", order.ID.Hex(), http.StatusOK},
+		{"unknown order", primitive.NewObjectID().Hex(), http.StatusNotFound},
+		{"invalid id", "not-an-object-id", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, f.handler.GetOrder, http.MethodGet, "/", "", "orderID", tt.id)
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetOrderHistoryAndCertificates(t *testing.T) {
+	f := newOrderFixture(t)
+	f.orderRepo.CreateOrder(&models.Order{UserID: 7, Lines: []models.OrderLine{{ProjectID: 1, Tonnes: 2}}, Tonnes: 2, Status: "completed"})
+	f.orderRepo.CreateOrder(&models.Order{UserID: 8, Lines: []models.OrderLine{{ProjectID: 1, Tonnes: 1}}, Tonnes: 1, Status: "completed"})
+	f.certRepo.CreateCertificate(&models.Certificate{UserID: 7, ProjectID: 1, Tonnes: 2, Status: "pending"})
+
+	rec := serve(t, f.handler.GetOrderHistory, http.MethodGet, "/", "", "userID", "7")
+	var orders []models.OrderResponse
+	decode(t, rec, &orders)
+	if len(orders) != 1 || orders[0].UserID != 7 {
+		t.Errorf("expected only user 7's order, got %+v", orders)
+	}
+
+	rec = serve(t, f.handler.GetCertificates, http.MethodGet, "/", "", "userID", "7")
+	var certificates []models.CertificateResponse
+	decode(t, rec, &certificates)
+	if len(certificates) != 1 {
+		t.Errorf("expected 1 certificate, got %+v", certificates)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"shared/problem"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// whole accepts whole numbers only, such as tonnes that are retired one
+	// serial per tonne
+	v.RegisterValidation("whole", func(fl validator.FieldLevel) bool {
+		value := fl.Field().Float()
+		return value == math.Trunc(value)
+	})
+
+	return v
+}
+
+// validateRequest validates a bound request and returns a 422 problem with
+// field-level details when it is invalid
+func validateRequest(request interface{}) error {
+	err := validate.Struct(request)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return problem.BadRequest("Invalid request body")
+	}
+
+	fieldErrors := make([]problem.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fieldErrors = append(fieldErrors, problem.FieldError{
+			Field:   fe.Field(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+	return problem.Validation(fieldErrors...)
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "whole":
+		return "must be a whole number"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.Join(strings.Fields(fe.Param()), ", "))
+	default:
+		return "is invalid"
+	}
+}
@@ -3,13 +3,17 @@ package main
 import (
 	"log"
 	"os"
+	"strconv"
 
 	"order_service/config"
 	_ "order_service/docs"
+	"order_service/handlers"
+	"order_service/messaging"
+	"order_service/repositories"
 	"order_service/routes"
 	"order_service/services"
+	"shared/problem"
 
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
@@ -40,22 +44,36 @@ import (
 // @description Admin JWT token (format: Bearer <token>)
 
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables")
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(config.RunCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	// Load and validate configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Connect to MongoDB
-	config.ConnectMongoDB()
+	config.ConnectMongoDB(cfg.MongoDB)
 	defer config.CloseMongoDB()
 
 	// Connect to RabbitMQ
-	config.ConnectRabbitMQ()
+	config.ConnectRabbitMQ(cfg.RabbitMQ)
 	defer config.CloseRabbitMQ()
 
+	// Initialize repositories and messaging
+	db := config.GetMongoDB()
+	orderRepo := repositories.NewMongoOrderRepository(db)
+	cartRepo := repositories.NewMongoCartRepository(db)
+	certRepo := repositories.NewMongoCertificateRepository(db)
+	broker := messaging.NewRabbitMQBroker(config.GetRabbitMQChannel())
+	projects := services.NewProjectService(cfg.ProjectService.URL, cfg.ProjectService.AdminSecret)
+
 	// Initialize services
-	certificateService := services.NewCertificateService()
-	schedulerService := services.NewSchedulerService()
+	certificateService := services.NewCertificateService(certRepo, orderRepo, broker, cfg.RabbitMQ.CertificateQueue)
+	certificateService.SetSerialAllocator(projects)
+	schedulerService := services.NewSchedulerService(orderRepo)
 
 	// Start certificate consumer
 	go certificateService.StartCertificateConsumer()
@@ -66,6 +84,7 @@ func main() {
 
 	// Create Echo instance
 	e := echo.New()
+	problem.Install(e)
 
 	// Middleware
 	e.Use(middleware.Logger())
@@ -73,19 +92,18 @@ func main() {
 	e.Use(middleware.CORS())
 
 	// Routes
-	routes.SetupRoutes(e)
+	routes.SetupRoutes(e,
+		handlers.NewCartHandler(cartRepo, projects),
+		handlers.NewOrderHandler(orderRepo, cartRepo, certRepo, projects, broker, cfg.RabbitMQ.CertificateQueue),
+		handlers.NewAdminHandler(orderRepo),
+	)
 
 	// Swagger documentation route
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("Order service starting on port %s", port)
-	if err := e.Start(":" + port); err != nil {
+	log.Printf("Order service starting on port %d (profile %s)", cfg.Port, cfg.Profile)
+	if err := e.Start(":" + strconv.Itoa(cfg.Port)); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
@@ -0,0 +1,112 @@
+package messaging
+
+import (
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// Publisher sends a message body to a named queue
+type Publisher interface {
+	Publish(queue string, body []byte) error
+}
+
+// Consumer delivers message bodies from a named queue
+type Consumer interface {
+	Consume(queue string) (<-chan []byte, error)
+}
+
+// Broker both publishes and consumes messages
+type Broker interface {
+	Publisher
+	Consumer
+}
+
+// RabbitMQBroker is a Broker backed by a RabbitMQ channel
+type RabbitMQBroker struct {
+	channel *amqp.Channel
+}
+
+func NewRabbitMQBroker(channel *amqp.Channel) *RabbitMQBroker {
+	return &RabbitMQBroker{channel: channel}
+}
+
+func (b *RabbitMQBroker) Publish(queue string, body []byte) error {
+	return b.channel.Publish(
+		"",    // exchange
+		queue, // routing key
+		false, // mandatory
+		false, // immediate
+		amqp.Publishing{
+			ContentType: "application/json",
+			Body:        body,
+		},
+	)
+}
+
+func (b *RabbitMQBroker) Consume(queue string) (<-chan []byte, error) {
+	deliveries, err := b.channel.Consume(
+		queue, // queue
+		"",    // consumer
+		true,  // auto-ack
+		false, // exclusive
+		false, // no-local
+		false, // no-wait
+		nil,   // args
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bodies := make(chan []byte)
+	go func() {
+		defer close(bodies)
+		for delivery := range deliveries {
+			bodies <- delivery.Body
+		}
+	}()
+	return bodies, nil
+}
+
+// InMemoryBroker is a Broker that passes messages through buffered channels
+// inside the process
+type InMemoryBroker struct {
+	mu     sync.Mutex
+	queues map[string]chan []byte
+}
+
+func NewInMemoryBroker() *InMemoryBroker {
+	return &InMemoryBroker{queues: make(map[string]chan []byte)}
+}
+
+func (b *InMemoryBroker) Publish(queue string, body []byte) error {
+	b.queue(queue) <- body
+	return nil
+}
+
+func (b *InMemoryBroker) Consume(queue string) (<-chan []byte, error) {
+	return b.queue(queue), nil
+}
+
+// Close closes every queue, ending any running consumers
+func (b *InMemoryBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for name, ch := range b.queues {
+		close(ch)
+		delete(b.queues, name)
+	}
+}
+
+func (b *InMemoryBroker) queue(name string) chan []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch, ok := b.queues[name]
+	if !ok {
+		ch = make(chan []byte, 100)
+		b.queues[name] = ch
+	}
+	return ch
+}
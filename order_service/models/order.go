@@ -1,17 +1,19 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Order is a checked out cart. Tonnes and TotalAmount are the totals of its
+// lines.
 type Order struct {
 	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	UserID         uint               `json:"user_id" bson:"user_id"`
-	ProjectID      uint               `json:"project_id" bson:"project_id"`
+	Lines          []OrderLine        `json:"lines" bson:"lines"`
 	Tonnes         float64            `json:"tonnes" bson:"tonnes"`
-	PricePerTonne  float64            `json:"price_per_tonne" bson:"price_per_tonne"`
 	TotalAmount    float64            `json:"total_amount" bson:"total_amount"`
 	Status         string             `json:"status" bson:"status"` // pending, completed, cancelled
 	PaymentID      string             `json:"payment_id" bson:"payment_id"`
@@ -20,35 +22,60 @@ type Order struct {
 	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
+// OrderLine is what an order bought of one project, of one vintage when
+// VintageID is set, at the price it was checked out at. ReservationID is the
+// project service reservation holding the line's tonnes.
+type OrderLine struct {
+	ProjectID     uint    `json:"project_id" bson:"project_id"`
+	VintageID     uint    `json:"vintage_id,omitempty" bson:"vintage_id"`
+	Tonnes        float64 `json:"tonnes" bson:"tonnes"`
+	PricePerTonne float64 `json:"price_per_tonne" bson:"price_per_tonne"`
+	Amount        float64 `json:"amount" bson:"amount"`
+	ReservationID string  `json:"reservation_id,omitempty" bson:"reservation_id,omitempty"`
+}
+
+// CartItem is a cart line: tonnes of a project, of one vintage when
+// VintageID is set. A cart holds one line per project and vintage.
 type CartItem struct {
 	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	UserID    uint               `json:"user_id" bson:"user_id"`
 	ProjectID uint               `json:"project_id" bson:"project_id"`
+	VintageID uint               `json:"vintage_id,omitempty" bson:"vintage_id"`
 	Tonnes    float64            `json:"tonnes" bson:"tonnes"`
 	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
+// Certificate certifies one line of an order. Line is the line's number in
+// the order, from 1.
 type Certificate struct {
 	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	OrderID        primitive.ObjectID `json:"order_id" bson:"order_id"`
+	Line           int                `json:"line" bson:"line"`
 	UserID         uint               `json:"user_id" bson:"user_id"`
 	ProjectID      uint               `json:"project_id" bson:"project_id"`
+	VintageID      uint               `json:"vintage_id,omitempty" bson:"vintage_id"`
 	Tonnes         float64            `json:"tonnes" bson:"tonnes"`
 	CertificateURL string             `json:"certificate_url" bson:"certificate_url"`
 	Status         string             `json:"status" bson:"status"` // pending, generated, failed
+	Serials        []string           `json:"serials,omitempty" bson:"serials,omitempty"`
 	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
 	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
 }
 
 // Request/Response DTOs
+// AddToCartRequest adds tonnes of a project, of a specific vintage when
+// VintageID is set
 type AddToCartRequest struct {
 	ProjectID uint    `json:"project_id" validate:"required"`
-	Tonnes    float64 `json:"tonnes" validate:"required,gt=0"`
+	VintageID uint    `json:"vintage_id"`
+	Tonnes    float64 `json:"tonnes" validate:"required,gt=0,whole"`
 }
 
+// UpdateCartItemRequest sets the tonnes of a cart line. Carts hold whole
+// tonnes, one registry serial each.
 type UpdateCartItemRequest struct {
-	Tonnes float64 `json:"tonnes" validate:"required,gt=0"`
+	Tonnes float64 `json:"tonnes" validate:"required,gt=0,whole"`
 }
 
 type CheckoutRequest struct {
@@ -58,9 +85,8 @@ type CheckoutRequest struct {
 type OrderResponse struct {
 	ID             primitive.ObjectID `json:"id"`
 	UserID         uint               `json:"user_id"`
-	ProjectID      uint               `json:"project_id"`
+	Lines          []OrderLine        `json:"lines"`
 	Tonnes         float64            `json:"tonnes"`
-	PricePerTonne  float64            `json:"price_per_tonne"`
 	TotalAmount    float64            `json:"total_amount"`
 	Status         string             `json:"status"`
 	PaymentID      string             `json:"payment_id"`
@@ -73,6 +99,7 @@ type CartItemResponse struct {
 	ID        primitive.ObjectID `json:"id"`
 	UserID    uint               `json:"user_id"`
 	ProjectID uint               `json:"project_id"`
+	VintageID uint               `json:"vintage_id,omitempty"`
 	Tonnes    float64            `json:"tonnes"`
 	CreatedAt time.Time          `json:"created_at"`
 	UpdatedAt time.Time          `json:"updated_at"`
@@ -81,11 +108,14 @@ type CartItemResponse struct {
 type CertificateResponse struct {
 	ID             primitive.ObjectID `json:"id"`
 	OrderID        primitive.ObjectID `json:"order_id"`
+	Line           int                `json:"line"`
 	UserID         uint               `json:"user_id"`
 	ProjectID      uint               `json:"project_id"`
+	VintageID      uint               `json:"vintage_id,omitempty"`
 	Tonnes         float64            `json:"tonnes"`
 	CertificateURL string             `json:"certificate_url"`
 	Status         string             `json:"status"`
+	Serials        []string           `json:"serials,omitempty"`
 	CreatedAt      time.Time          `json:"created_at"`
 	UpdatedAt      time.Time          `json:"updated_at"`
 }
@@ -99,11 +129,24 @@ type MonthlyReport struct {
 	Orders       []OrderResponse `json:"orders"`
 }
 
+// LineReference is how the project service knows line n of an order, from
+// 1. Line 0 is an order from before orders had lines, known by its ID.
+func LineReference(orderID primitive.ObjectID, line int) string {
+	if line == 0 {
+		return orderID.Hex()
+	}
+	return fmt.Sprintf("%s-%d", orderID.Hex(), line)
+}
+
+// CertificateGenerationMessage asks for the certificate of one order line
 type CertificateGenerationMessage struct {
-	OrderID   primitive.ObjectID `json:"order_id"`
-	UserID    uint               `json:"user_id"`
-	ProjectID uint               `json:"project_id"`
-	Tonnes    float64            `json:"tonnes"`
-	UserEmail string             `json:"user_email"`
-	UserName  string             `json:"user_name"`
+	CertificateID primitive.ObjectID `json:"certificate_id"`
+	OrderID       primitive.ObjectID `json:"order_id"`
+	Line          int                `json:"line"`
+	UserID        uint               `json:"user_id"`
+	ProjectID     uint               `json:"project_id"`
+	VintageID     uint               `json:"vintage_id,omitempty"`
+	Tonnes        float64            `json:"tonnes"`
+	UserEmail     string             `json:"user_email"`
+	UserName      string             `json:"user_name"`
 }
@@ -4,33 +4,40 @@ import (
 	"context"
 	"time"
 
-	"order_service/config"
 	"order_service/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type CartRepository struct {
+// CartRepository stores the items in each user's shopping cart, one line
+// per project and vintage. A zero vintage ID is the project's line without
+// a vintage.
+type CartRepository interface {
+	AddToCart(cartItem *models.CartItem) error
+	GetCartByUserID(userID uint) ([]models.CartItem, error)
+	RemoveFromCart(userID uint, projectID uint, vintageID uint) error
+	ClearCart(userID uint) error
+	UpdateCartItem(userID uint, projectID uint, vintageID uint, tonnes float64) error
+}
+
+// MongoCartRepository is the MongoDB-backed CartRepository
+type MongoCartRepository struct {
 	collection *mongo.Collection
 }
 
-func NewCartRepository() *CartRepository {
-	db := config.GetMongoDB()
-	return &CartRepository{
+func NewMongoCartRepository(db *mongo.Database) *MongoCartRepository {
+	return &MongoCartRepository{
 		collection: db.Collection("cart_items"),
 	}
 }
 
-func (r *CartRepository) AddToCart(cartItem *models.CartItem) error {
+func (r *MongoCartRepository) AddToCart(cartItem *models.CartItem) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Check if item already exists for this user and project
-	filter := bson.M{
-		"user_id":    cartItem.UserID,
-		"project_id": cartItem.ProjectID,
-	}
+	// Check if item already exists for this user, project and vintage
+	filter := cartLineFilter(cartItem.UserID, cartItem.ProjectID, cartItem.VintageID)
 
 	var existingItem models.CartItem
 	err := r.collection.FindOne(ctx, filter).Decode(&existingItem)
@@ -59,7 +66,7 @@ func (r *CartRepository) AddToCart(cartItem *models.CartItem) error {
 	return err
 }
 
-func (r *CartRepository) GetCartByUserID(userID uint) ([]models.CartItem, error) {
+func (r *MongoCartRepository) GetCartByUserID(userID uint) ([]models.CartItem, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -77,21 +84,15 @@ func (r *CartRepository) GetCartByUserID(userID uint) ([]models.CartItem, error)
 	return cartItems, nil
 }
 
-func (r *CartRepository) RemoveFromCart(userID uint, projectID uint) error {
+func (r *MongoCartRepository) RemoveFromCart(userID uint, projectID uint, vintageID uint) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := r.collection.DeleteOne(
-		ctx,
-		bson.M{
-			"user_id":    userID,
-			"project_id": projectID,
-		},
-	)
+	_, err := r.collection.DeleteOne(ctx, cartLineFilter(userID, projectID, vintageID))
 	return err
 }
 
-func (r *CartRepository) ClearCart(userID uint) error {
+func (r *MongoCartRepository) ClearCart(userID uint) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -99,20 +100,17 @@ func (r *CartRepository) ClearCart(userID uint) error {
 	return err
 }
 
-func (r *CartRepository) UpdateCartItem(userID uint, projectID uint, tonnes float64) error {
+func (r *MongoCartRepository) UpdateCartItem(userID uint, projectID uint, vintageID uint, tonnes float64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if tonnes <= 0 {
-		return r.RemoveFromCart(userID, projectID)
+		return r.RemoveFromCart(userID, projectID, vintageID)
 	}
 
 	_, err := r.collection.UpdateOne(
 		ctx,
-		bson.M{
-			"user_id":    userID,
-			"project_id": projectID,
-		},
+		cartLineFilter(userID, projectID, vintageID),
 		bson.M{
 			"$set": bson.M{
 				"tonnes":     tonnes,
@@ -122,3 +120,17 @@ func (r *CartRepository) UpdateCartItem(userID uint, projectID uint, tonnes floa
 	)
 	return err
 }
+
+// cartLineFilter matches a user's cart line for a project and vintage
+func cartLineFilter(userID uint, projectID uint, vintageID uint) bson.M {
+	filter := bson.M{
+		"user_id":    userID,
+		"project_id": projectID,
+		"vintage_id": vintageID,
+	}
+	if vintageID == 0 {
+		// Lines added before vintages have no vintage_id
+		filter["vintage_id"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	return filter
+}
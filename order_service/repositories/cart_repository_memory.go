@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"order_service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InMemoryCartRepository is a CartRepository kept in process memory
+type InMemoryCartRepository struct {
+	mu    sync.RWMutex
+	items []models.CartItem
+}
+
+func NewInMemoryCartRepository() *InMemoryCartRepository {
+	return &InMemoryCartRepository{}
+}
+
+func (r *InMemoryCartRepository) AddToCart(cartItem *models.CartItem) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		item := &r.items[i]
+		if item.UserID == cartItem.UserID && item.ProjectID == cartItem.ProjectID && item.VintageID == cartItem.VintageID {
+			item.Tonnes += cartItem.Tonnes
+			item.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	cartItem.ID = primitive.NewObjectID()
+	cartItem.CreatedAt = time.Now()
+	cartItem.UpdatedAt = time.Now()
+	r.items = append(r.items, *cartItem)
+	return nil
+}
+
+func (r *InMemoryCartRepository) GetCartByUserID(userID uint) ([]models.CartItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var cartItems []models.CartItem
+	for _, item := range r.items {
+		if item.UserID == userID {
+			cartItems = append(cartItems, item)
+		}
+	}
+	return cartItems, nil
+}
+
+func (r *InMemoryCartRepository) RemoveFromCart(userID uint, projectID uint, vintageID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeWhere(func(item *models.CartItem) bool {
+		return item.UserID == userID && item.ProjectID == projectID && item.VintageID == vintageID
+	})
+	return nil
+}
+
+func (r *InMemoryCartRepository) ClearCart(userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.removeWhere(func(item *models.CartItem) bool { return item.UserID == userID })
+	return nil
+}
+
+func (r *InMemoryCartRepository) UpdateCartItem(userID uint, projectID uint, vintageID uint, tonnes float64) error {
+	if tonnes <= 0 {
+		return r.RemoveFromCart(userID, projectID, vintageID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.items {
+		if r.items[i].UserID == userID && r.items[i].ProjectID == projectID && r.items[i].VintageID == vintageID {
+			r.items[i].Tonnes = tonnes
+			r.items[i].UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryCartRepository) removeWhere(match func(item *models.CartItem) bool) {
+	kept := r.items[:0]
+	for _, item := range r.items {
+		if !match(&item) {
+			kept = append(kept, item)
+		}
+	}
+	r.items = kept
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"time"
 
-	"order_service/config"
 	"order_service/models"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,18 +11,28 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type CertificateRepository struct {
+// CertificateRepository stores the offset certificates issued for orders
+type CertificateRepository interface {
+	CreateCertificate(certificate *models.Certificate) error
+	GetCertificateByOrderID(orderID primitive.ObjectID) (*models.Certificate, error)
+	GetCertificatesByUserID(userID uint) ([]models.Certificate, error)
+	UpdateCertificateStatus(id primitive.ObjectID, status string) error
+	UpdateCertificateURL(id primitive.ObjectID, certificateURL string) error
+	UpdateCertificateSerials(id primitive.ObjectID, serials []string) error
+}
+
+// MongoCertificateRepository is the MongoDB-backed CertificateRepository
+type MongoCertificateRepository struct {
 	collection *mongo.Collection
 }
 
-func NewCertificateRepository() *CertificateRepository {
-	db := config.GetMongoDB()
-	return &CertificateRepository{
+func NewMongoCertificateRepository(db *mongo.Database) *MongoCertificateRepository {
+	return &MongoCertificateRepository{
 		collection: db.Collection("certificates"),
 	}
 }
 
-func (r *CertificateRepository) CreateCertificate(certificate *models.Certificate) error {
+func (r *MongoCertificateRepository) CreateCertificate(certificate *models.Certificate) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -39,7 +48,7 @@ func (r *CertificateRepository) CreateCertificate(certificate *models.Certificat
 	return nil
 }
 
-func (r *CertificateRepository) GetCertificateByOrderID(orderID primitive.ObjectID) (*models.Certificate, error) {
+func (r *MongoCertificateRepository) GetCertificateByOrderID(orderID primitive.ObjectID) (*models.Certificate, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -52,7 +61,7 @@ func (r *CertificateRepository) GetCertificateByOrderID(orderID primitive.Object
 	return &certificate, nil
 }
 
-func (r *CertificateRepository) GetCertificatesByUserID(userID uint) ([]models.Certificate, error) {
+func (r *MongoCertificateRepository) GetCertificatesByUserID(userID uint) ([]models.Certificate, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -70,7 +79,7 @@ func (r *CertificateRepository) GetCertificatesByUserID(userID uint) ([]models.C
 	return certificates, nil
 }
 
-func (r *CertificateRepository) UpdateCertificateStatus(id primitive.ObjectID, status string) error {
+func (r *MongoCertificateRepository) UpdateCertificateStatus(id primitive.ObjectID, status string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -87,7 +96,7 @@ func (r *CertificateRepository) UpdateCertificateStatus(id primitive.ObjectID, s
 	return err
 }
 
-func (r *CertificateRepository) UpdateCertificateURL(id primitive.ObjectID, certificateURL string) error {
+func (r *MongoCertificateRepository) UpdateCertificateURL(id primitive.ObjectID, certificateURL string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -104,3 +113,20 @@ func (r *CertificateRepository) UpdateCertificateURL(id primitive.ObjectID, cert
 	)
 	return err
 }
+
+func (r *MongoCertificateRepository) UpdateCertificateSerials(id primitive.ObjectID, serials []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$set": bson.M{
+				"serials":    serials,
+				"updated_at": time.Now(),
+			},
+		},
+	)
+	return err
+}
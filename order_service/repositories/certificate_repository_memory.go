@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"order_service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InMemoryCertificateRepository is a CertificateRepository kept in process memory
+type InMemoryCertificateRepository struct {
+	mu           sync.RWMutex
+	certificates []models.Certificate
+}
+
+func NewInMemoryCertificateRepository() *InMemoryCertificateRepository {
+	return &InMemoryCertificateRepository{}
+}
+
+func (r *InMemoryCertificateRepository) CreateCertificate(certificate *models.Certificate) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	certificate.ID = primitive.NewObjectID()
+	certificate.CreatedAt = time.Now()
+	certificate.UpdatedAt = time.Now()
+	r.certificates = append(r.certificates, *certificate)
+	return nil
+}
+
+func (r *InMemoryCertificateRepository) GetCertificateByOrderID(orderID primitive.ObjectID) (*models.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, certificate := range r.certificates {
+		if certificate.OrderID == orderID {
+			return &certificate, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *InMemoryCertificateRepository) GetCertificatesByUserID(userID uint) ([]models.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var certificates []models.Certificate
+	for _, certificate := range r.certificates {
+		if certificate.UserID == userID {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return certificates, nil
+}
+
+func (r *InMemoryCertificateRepository) UpdateCertificateStatus(id primitive.ObjectID, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.certificates {
+		if r.certificates[i].ID == id {
+			r.certificates[i].Status = status
+			r.certificates[i].UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryCertificateRepository) UpdateCertificateURL(id primitive.ObjectID, certificateURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.certificates {
+		if r.certificates[i].ID == id {
+			r.certificates[i].CertificateURL = certificateURL
+			r.certificates[i].Status = "generated"
+			r.certificates[i].UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (r *InMemoryCertificateRepository) UpdateCertificateSerials(id primitive.ObjectID, serials []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.certificates {
+		if r.certificates[i].ID == id {
+			r.certificates[i].Serials = serials
+			r.certificates[i].UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
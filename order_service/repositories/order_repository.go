@@ -4,7 +4,6 @@ import (
 	"context"
 	"time"
 
-	"order_service/config"
 	"order_service/models"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,18 +11,30 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
-type OrderRepository struct {
+// OrderRepository stores orders and builds reports over them
+type OrderRepository interface {
+	// CreateOrder stores a new order, under a new ID unless it has one
+	CreateOrder(order *models.Order) error
+	GetOrderByID(id primitive.ObjectID) (*models.Order, error)
+	GetOrdersByUserID(userID uint) ([]models.Order, error)
+	UpdateOrderStatus(id primitive.ObjectID, status string) error
+	UpdateOrderCertificateURL(id primitive.ObjectID, certificateURL string) error
+	GetOrdersByDateRange(startDate, endDate time.Time) ([]models.Order, error)
+	GetMonthlyReport(year int, month int) (*models.MonthlyReport, error)
+}
+
+// MongoOrderRepository is the MongoDB-backed OrderRepository
+type MongoOrderRepository struct {
 	collection *mongo.Collection
 }
 
-func NewOrderRepository() *OrderRepository {
-	db := config.GetMongoDB()
-	return &OrderRepository{
+func NewMongoOrderRepository(db *mongo.Database) *MongoOrderRepository {
+	return &MongoOrderRepository{
 		collection: db.Collection("orders"),
 	}
 }
 
-func (r *OrderRepository) CreateOrder(order *models.Order) error {
+func (r *MongoOrderRepository) CreateOrder(order *models.Order) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -39,7 +50,7 @@ func (r *OrderRepository) CreateOrder(order *models.Order) error {
 	return nil
 }
 
-func (r *OrderRepository) GetOrderByID(id primitive.ObjectID) (*models.Order, error) {
+func (r *MongoOrderRepository) GetOrderByID(id primitive.ObjectID) (*models.Order, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -52,7 +63,7 @@ func (r *OrderRepository) GetOrderByID(id primitive.ObjectID) (*models.Order, er
 	return &order, nil
 }
 
-func (r *OrderRepository) GetOrdersByUserID(userID uint) ([]models.Order, error) {
+func (r *MongoOrderRepository) GetOrdersByUserID(userID uint) ([]models.Order, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -70,7 +81,7 @@ func (r *OrderRepository) GetOrdersByUserID(userID uint) ([]models.Order, error)
 	return orders, nil
 }
 
-func (r *OrderRepository) UpdateOrderStatus(id primitive.ObjectID, status string) error {
+func (r *MongoOrderRepository) UpdateOrderStatus(id primitive.ObjectID, status string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -87,7 +98,7 @@ func (r *OrderRepository) UpdateOrderStatus(id primitive.ObjectID, status string
 	return err
 }
 
-func (r *OrderRepository) UpdateOrderCertificateURL(id primitive.ObjectID, certificateURL string) error {
+func (r *MongoOrderRepository) UpdateOrderCertificateURL(id primitive.ObjectID, certificateURL string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -104,7 +115,7 @@ func (r *OrderRepository) UpdateOrderCertificateURL(id primitive.ObjectID, certi
 	return err
 }
 
-func (r *OrderRepository) GetOrdersByDateRange(startDate, endDate time.Time) ([]models.Order, error) {
+func (r *MongoOrderRepository) GetOrdersByDateRange(startDate, endDate time.Time) ([]models.Order, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -129,11 +140,16 @@ func (r *OrderRepository) GetOrdersByDateRange(startDate, endDate time.Time) ([]
 	return orders, nil
 }
 
-func (r *OrderRepository) GetMonthlyReport(year int, month int) (*models.MonthlyReport, error) {
+func (r *MongoOrderRepository) GetMonthlyReport(year int, month int) (*models.MonthlyReport, error) {
+	return monthlyReport(r, year, month)
+}
+
+// monthlyReport summarizes the completed orders of a calendar month
+func monthlyReport(repo OrderRepository, year int, month int) (*models.MonthlyReport, error) {
 	startDate := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
 	endDate := startDate.AddDate(0, 1, 0).Add(-time.Second)
 
-	orders, err := r.GetOrdersByDateRange(startDate, endDate)
+	orders, err := repo.GetOrdersByDateRange(startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
@@ -167,9 +183,8 @@ func convertToOrderResponses(orders []models.Order) []models.OrderResponse {
 		responses = append(responses, models.OrderResponse{
 			ID:             order.ID,
 			UserID:         order.UserID,
-			ProjectID:      order.ProjectID,
+			Lines:          order.Lines,
 			Tonnes:         order.Tonnes,
-			PricePerTonne:  order.PricePerTonne,
 			TotalAmount:    order.TotalAmount,
 			Status:         order.Status,
 			PaymentID:      order.PaymentID,
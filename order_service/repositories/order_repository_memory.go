@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"sync"
+	"time"
+
+	"order_service/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// InMemoryOrderRepository is an OrderRepository kept in process memory
+type InMemoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders []models.Order
+}
+
+func NewInMemoryOrderRepository() *InMemoryOrderRepository {
+	return &InMemoryOrderRepository{}
+}
+
+func (r *InMemoryOrderRepository) CreateOrder(order *models.Order) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if order.ID.IsZero() {
+		order.ID = primitive.NewObjectID()
+	}
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = time.Now()
+	r.orders = append(r.orders, *order)
+	return nil
+}
+
+func (r *InMemoryOrderRepository) GetOrderByID(id primitive.ObjectID) (*models.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, order := range r.orders {
+		if order.ID == id {
+			return &order, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (r *InMemoryOrderRepository) GetOrdersByUserID(userID uint) ([]models.Order, error) {
+	return r.filter(func(order *models.Order) bool { return order.UserID == userID }), nil
+}
+
+func (r *InMemoryOrderRepository) UpdateOrderStatus(id primitive.ObjectID, status string) error {
+	return r.update(id, func(order *models.Order) { order.Status = status })
+}
+
+func (r *InMemoryOrderRepository) UpdateOrderCertificateURL(id primitive.ObjectID, certificateURL string) error {
+	return r.update(id, func(order *models.Order) { order.CertificateURL = certificateURL })
+}
+
+func (r *InMemoryOrderRepository) GetOrdersByDateRange(startDate, endDate time.Time) ([]models.Order, error) {
+	return r.filter(func(order *models.Order) bool {
+		return !order.CreatedAt.Before(startDate) && !order.CreatedAt.After(endDate)
+	}), nil
+}
+
+func (r *InMemoryOrderRepository) GetMonthlyReport(year int, month int) (*models.MonthlyReport, error) {
+	return monthlyReport(r, year, month)
+}
+
+func (r *InMemoryOrderRepository) filter(match func(order *models.Order) bool) []models.Order {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var orders []models.Order
+	for _, order := range r.orders {
+		if match(&order) {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+func (r *InMemoryOrderRepository) update(id primitive.ObjectID, apply func(order *models.Order)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.orders {
+		if r.orders[i].ID == id {
+			apply(&r.orders[i])
+			r.orders[i].UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
@@ -6,12 +6,7 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func SetupRoutes(e *echo.Echo) {
-	// Initialize handlers
-	cartHandler := handlers.NewCartHandler()
-	orderHandler := handlers.NewOrderHandler()
-	adminHandler := handlers.NewAdminHandler()
-
+func SetupRoutes(e *echo.Echo, cartHandler *handlers.CartHandler, orderHandler *handlers.OrderHandler, adminHandler *handlers.AdminHandler) {
 	// API version group
 	api := e.Group("/api/v1")
 
@@ -28,6 +23,8 @@ func SetupRoutes(e *echo.Echo) {
 	orders.POST("/:userID/checkout", orderHandler.Checkout)
 	orders.GET("/:userID/history", orderHandler.GetOrderHistory)
 	orders.GET("/:orderID", orderHandler.GetOrder)
+	orders.POST("/:orderID/cancel", orderHandler.CancelOrder)
+	orders.POST("/:orderID/confirm", orderHandler.ConfirmOrder)
 	orders.GET("/:userID/certificates", orderHandler.GetCertificates)
 
 	// Admin routes
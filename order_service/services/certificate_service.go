@@ -4,47 +4,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
-	"order_service/config"
+	"order_service/messaging"
 	"order_service/models"
 	"order_service/repositories"
-
-	"github.com/streadway/amqp"
 )
 
 type CertificateService struct {
-	certRepo        *repositories.CertificateRepository
-	orderRepo       *repositories.OrderRepository
-	rabbitMQChannel *amqp.Channel
+	certRepo  repositories.CertificateRepository
+	orderRepo repositories.OrderRepository
+	consumer  messaging.Consumer
+	queue     string
+	serials   SerialAllocator
 }
 
-func NewCertificateService() *CertificateService {
+func NewCertificateService(
+	certRepo repositories.CertificateRepository,
+	orderRepo repositories.OrderRepository,
+	consumer messaging.Consumer,
+	queue string,
+) *CertificateService {
 	return &CertificateService{
-		certRepo:        repositories.NewCertificateRepository(),
-		orderRepo:       repositories.NewOrderRepository(),
-		rabbitMQChannel: config.GetRabbitMQChannel(),
+		certRepo:  certRepo,
+		orderRepo: orderRepo,
+		consumer:  consumer,
+		queue:     queue,
 	}
 }
 
+// SetSerialAllocator makes certificates state the registry serials retired
+// for their order. Without one certificates carry no serials.
+func (s *CertificateService) SetSerialAllocator(serials SerialAllocator) {
+	s.serials = serials
+}
+
 // StartCertificateConsumer starts listening for certificate generation messages
 func (s *CertificateService) StartCertificateConsumer() {
-	// Get queue name from environment variable
-	queueName := os.Getenv("CERTIFICATE_QUEUE_NAME")
-	if queueName == "" {
-		queueName = "certificate_generation"
-	}
-
-	msgs, err := s.rabbitMQChannel.Consume(
-		queueName, // queue
-		"",        // consumer
-		true,      // auto-ack
-		false,     // exclusive
-		false,     // no-local
-		false,     // no-wait
-		nil,       // args
-	)
+	msgs, err := s.consumer.Consume(s.queue)
 	if err != nil {
 		log.Fatal("Failed to register a consumer:", err)
 	}
@@ -52,7 +49,7 @@ func (s *CertificateService) StartCertificateConsumer() {
 	go func() {
 		for msg := range msgs {
 			var certMessage models.CertificateGenerationMessage
-			if err := json.Unmarshal(msg.Body, &certMessage); err != nil {
+			if err := json.Unmarshal(msg, &certMessage); err != nil {
 				log.Printf("Failed to unmarshal certificate message: %v", err)
 				continue
 			}
@@ -67,22 +64,48 @@ func (s *CertificateService) StartCertificateConsumer() {
 }
 
 func (s *CertificateService) generateCertificate(message *models.CertificateGenerationMessage) error {
-	log.Printf("Generating certificate for order %s", message.OrderID.Hex())
+	log.Printf("Generating certificate for line %d of order %s", message.Line, message.OrderID.Hex())
 
 	// Simulate certificate generation process
 	time.Sleep(2 * time.Second)
 
-	// Generate a mock certificate URL
-	certificateURL := fmt.Sprintf("https://certificates.carbonclear.com/cert_%s.pdf", message.OrderID.Hex())
+	// Messages queued before orders had lines name no certificate; their
+	// order has the only one
+	certificateID := message.CertificateID
+	if certificateID.IsZero() {
+		certificate, err := s.certRepo.GetCertificateByOrderID(message.OrderID)
+		if err != nil {
+			return fmt.Errorf("failed to find certificate: %v", err)
+		}
+		certificateID = certificate.ID
+	}
 
-	// Update certificate record
-	if err := s.certRepo.UpdateCertificateURL(message.OrderID, certificateURL); err != nil {
+	// Generate a mock certificate URL
+	certificateURL := fmt.Sprintf("https://certificates.carbonclear.com/cert_%s.pdf", certificateID.Hex())
+
+	// A certificate is still issued when serials cannot be allocated; the
+	// allocation can be made by an admin and looked up by the line's
+	// reference later
+	if s.serials != nil {
+		reference := models.LineReference(message.OrderID, message.Line)
+		serials, err := s.serials.AllocateSerials(reference, message.ProjectID, message.VintageID, message.Tonnes)
+		if err != nil {
+			log.Printf("Failed to allocate serials for %s: %v", reference, err)
+		} else if len(serials) > 0 {
+			if err := s.certRepo.UpdateCertificateSerials(certificateID, serials); err != nil {
+				return fmt.Errorf("failed to update certificate serials: %v", err)
+			}
+		}
+	}
+	if err := s.certRepo.UpdateCertificateURL(certificateID, certificateURL); err != nil {
 		return fmt.Errorf("failed to update certificate URL: %v", err)
 	}
 
-	// Update order with certificate URL
-	if err := s.orderRepo.UpdateOrderCertificateURL(message.OrderID, certificateURL); err != nil {
-		return fmt.Errorf("failed to update order certificate URL: %v", err)
+	// The order links to the certificate of its first line
+	if message.Line <= 1 {
+		if err := s.orderRepo.UpdateOrderCertificateURL(message.OrderID, certificateURL); err != nil {
+			return fmt.Errorf("failed to update order certificate URL: %v", err)
+		}
 	}
 
 	// In a real implementation, you would:
@@ -91,7 +114,7 @@ func (s *CertificateService) generateCertificate(message *models.CertificateGene
 	// 3. Send an email notification to the user
 	// 4. Update the user's profile with the certificate
 
-	log.Printf("Certificate generated successfully for order %s", message.OrderID.Hex())
+	log.Printf("Certificate generated successfully for line %d of order %s", message.Line, message.OrderID.Hex())
 	return nil
 }
 
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrProjectNotFound is returned for a project that does not exist or
+	// is not on sale
+	ErrProjectNotFound = errors.New("project not found")
+	// ErrVintageNotFound is returned for a vintage that is not one of the
+	// project's
+	ErrVintageNotFound = errors.New("vintage not found")
+	// ErrInsufficientCapacity is returned when a project has fewer tonnes
+	// available than asked to reserve
+	ErrInsufficientCapacity = errors.New("insufficient capacity")
+	// ErrReservationNotHeld is returned when committing a reservation that
+	// was released or has expired, or releasing a committed one
+	ErrReservationNotHeld = errors.New("reservation is not held")
+)
+
+// callAttempts is the number of times a call is sent when the project
+// service cannot be reached. Every call is idempotent: reservations are
+// made once per reference and commits and releases repeat as no-ops.
+const callAttempts = 3
+
+// Projects is what orders need of the project service
+type Projects interface {
+	// Price returns the price per tonne of buying tonnes of a project, of
+	// one of its vintages when vintageID is set
+	Price(projectID, vintageID uint, tonnes float64) (float64, error)
+	// Reserve holds tonnes of a project's capacity, and of one of its
+	// vintages when vintageID is set, for the order line with the given
+	// reference and returns the reservation ID. A reference holds its
+	// tonnes once however often it is reserved.
+	Reserve(projectID, vintageID uint, tonnes float64, reference string) (string, error)
+	// Commit keeps the reservations' tonnes out of their projects' capacity
+	// for good, once the order is paid. It commits all of them or none.
+	Commit(reservationIDs []string) error
+	// Release returns a reservation's tonnes to the project's capacity, or
+	// ErrReservationNotHeld when they were committed
+	Release(reservationID string) error
+}
+
+// SerialAllocator allocates registry serials to a line of a completed
+// order, known by its models.LineReference, and returns the serial ranges it
+// got
+type SerialAllocator interface {
+	AllocateSerials(reference string, projectID, vintageID uint, tonnes float64) ([]string, error)
+}
+
+// ProjectService is the project service's HTTP API. Admin calls carry a
+// short-lived admin token signed with the shared admin secret.
+type ProjectService struct {
+	baseURL string
+	secret  []byte
+	client  *http.Client
+}
+
+func NewProjectService(baseURL, adminSecret string) *ProjectService {
+	return &ProjectService{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  []byte(adminSecret),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Price prices a vintage at the vintage's price and a project at its quote
+// for the tonnes, which applies its volume tiers. Only released projects
+// are on sale.
+func (p *ProjectService) Price(projectID, vintageID uint, tonnes float64) (float64, error) {
+	if vintageID == 0 {
+		var quote struct {
+			PricePerTonne float64 `json:"price_per_tonne"`
+		}
+		query := url.Values{"tonnes": {fmt.Sprint(tonnes)}}
+		if err := p.call(http.MethodGet, fmt.Sprintf("/api/v1/projects/%d/quote?%s", projectID, query.Encode()), nil, &quote); err != nil {
+			return 0, err
+		}
+		return quote.PricePerTonne, nil
+	}
+
+	var list struct {
+		Vintages []struct {
+			ID            uint    `json:"id"`
+			PricePerTonne float64 `json:"price_per_tonne"`
+		} `json:"vintages"`
+	}
+	if err := p.call(http.MethodGet, fmt.Sprintf("/api/v1/projects/%d/vintages", projectID), nil, &list); err != nil {
+		return 0, err
+	}
+	for _, vintage := range list.Vintages {
+		if vintage.ID == vintageID {
+			return vintage.PricePerTonne, nil
+		}
+	}
+	return 0, ErrVintageNotFound
+}
+
+// Reserve holds the tonnes for the project service's reservation TTL, so a
+// checkout that never finishes gives them back on its own
+func (p *ProjectService) Reserve(projectID, vintageID uint, tonnes float64, reference string) (string, error) {
+	var reservation struct {
+		ID string `json:"id"`
+	}
+	err := p.call(http.MethodPost, "/api/v1/projects/admin/reservations", map[string]interface{}{
+		"project_id": projectID,
+		"vintage_id": vintageID,
+		"tonnes":     tonnes,
+		"reference":  reference,
+	}, &reservation)
+	if err != nil {
+		return "", err
+	}
+	return reservation.ID, nil
+}
+
+// Commit commits held reservations together
+func (p *ProjectService) Commit(reservationIDs []string) error {
+	return p.call(http.MethodPost, "/api/v1/projects/admin/reservations/commit", map[string]interface{}{
+		"ids": reservationIDs,
+	}, nil)
+}
+
+// Release releases a held reservation
+func (p *ProjectService) Release(reservationID string) error {
+	return p.call(http.MethodPost, "/api/v1/projects/admin/reservations/"+url.PathEscape(reservationID)+"/release", nil, nil)
+}
+
+// AllocateSerials allocates one serial per tonne of an order line. Carts
+// hold whole tonnes; the project service refuses anything else rather than
+// leave part of a line without serials. It allocates at most once per
+// reference, so a redelivered certificate message gets the same serials
+// back.
+func (p *ProjectService) AllocateSerials(reference string, projectID, vintageID uint, tonnes float64) ([]string, error) {
+	var allocation struct {
+		Blocks []struct {
+			Serials string `json:"serials"`
+		} `json:"blocks"`
+	}
+	err := p.call(http.MethodPost, "/api/v1/projects/admin/serials/allocations", map[string]interface{}{
+		"order_id":   reference,
+		"project_id": projectID,
+		"vintage_id": vintageID,
+		"tonnes":     tonnes,
+	}, &allocation)
+	if err != nil {
+		return nil, err
+	}
+	serials := make([]string, 0, len(allocation.Blocks))
+	for _, block := range allocation.Blocks {
+		serials = append(serials, block.Serials)
+	}
+	return serials, nil
+}
+
+// call sends a request to the project service, again when it cannot be
+// reached, and decodes a successful response into out. Admin paths are
+// sent with an admin token. A 404 is ErrProjectNotFound, a lack of
+// capacity ErrInsufficientCapacity and a reservation that is no longer held
+// ErrReservationNotHeld.
+func (p *ProjectService) call(method, path string, in, out interface{}) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	for attempt := 1; attempt <= callAttempts; attempt++ {
+		if err = p.send(method, path, payload, out); !isUnreachable(err) {
+			return err
+		}
+		if attempt < callAttempts {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+	}
+	return err
+}
+
+// unreachableError is a request that got no response, which may or may not
+// have reached the project service
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string { return e.err.Error() }
+func (e *unreachableError) Unwrap() error { return e.err }
+
+func isUnreachable(err error) bool {
+	var unreachable *unreachableError
+	return errors.As(err, &unreachable)
+}
+
+// send sends a request to the project service once
+func (p *ProjectService) send(method, path string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, p.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if strings.HasPrefix(path, "/api/v1/projects/admin/") {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"role": "admin",
+			"exp":  time.Now().Add(time.Minute).Unix(),
+		}).SignedString(p.secret)
+		if err != nil {
+			return fmt.Errorf("failed to sign token: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return &unreachableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrProjectNotFound
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Detail string `json:"detail"`
+			Code   string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		switch failure.Code {
+		case "insufficient_capacity":
+			return ErrInsufficientCapacity
+		case "reservation_not_held", "reservation_expired":
+			return ErrReservationNotHeld
+		}
+		return fmt.Errorf("project service returned %d: %s", resp.StatusCode, failure.Detail)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %v", path, err)
+	}
+	return nil
+}
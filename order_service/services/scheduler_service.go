@@ -10,13 +10,13 @@ import (
 )
 
 type SchedulerService struct {
-	orderRepo *repositories.OrderRepository
+	orderRepo repositories.OrderRepository
 	cron      *cron.Cron
 }
 
-func NewSchedulerService() *SchedulerService {
+func NewSchedulerService(orderRepo repositories.OrderRepository) *SchedulerService {
 	return &SchedulerService{
-		orderRepo: repositories.NewOrderRepository(),
+		orderRepo: orderRepo,
 		cron:      cron.New(cron.WithLocation(time.UTC)),
 	}
 }
@@ -0,0 +1,202 @@
+// Package cache is the project service's response cache. Entries live in
+// versioned namespaces: a write bumps the version of each namespace it
+// affects, so every entry cached under an older version stops being read at
+// once, without scanning keys, and simply expires with its TTL.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/sync/singleflight"
+)
+
+// keyPrefix namespaces every cache key in the shared Redis database
+const keyPrefix = "cache:"
+
+// ErrMiss is returned by a Store for a key it does not hold
+var ErrMiss = errors.New("cache miss")
+
+// Store holds cache entries and namespace versions
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// Cache serves JSON responses from a Store. Concurrent misses for the same
+// entry are collapsed so only one of them loads it. A nil Cache loads every
+// response.
+type Cache struct {
+	store Store
+	group singleflight.Group
+}
+
+func New(store Store) *Cache {
+	return &Cache{store: store}
+}
+
+// Fetch returns the JSON of the entry cached under key in the namespace,
+// loading and caching it on a miss. hit reports whether it came from the
+// cache. Errors from load are returned and never cached; store errors only
+// bypass the cache.
+func (c *Cache) Fetch(ctx context.Context, namespace, key string, ttl time.Duration, load func() (interface{}, error)) (data []byte, hit bool, err error) {
+	if c == nil {
+		data, err = loadJSON(load)
+		return data, false, err
+	}
+
+	version, err := c.version(ctx, namespace)
+	if err != nil {
+		log.Printf("Warning: cache version of %s unavailable: %v", namespace, err)
+		data, err = loadJSON(load)
+		return data, false, err
+	}
+
+	entry := keyPrefix + namespace + ":v" + strconv.FormatInt(version, 10) + ":" + key
+	if data, err := c.store.Get(ctx, entry); err == nil {
+		return data, true, nil
+	} else if !errors.Is(err, ErrMiss) {
+		log.Printf("Warning: cache read of %s failed: %v", entry, err)
+	}
+
+	// The load outlives a caller that gives up, since others may share it
+	storeCtx := context.WithoutCancel(ctx)
+	value, err, _ := c.group.Do(entry, func() (interface{}, error) {
+		data, err := loadJSON(load)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.store.Set(storeCtx, entry, data, ttl); err != nil {
+			log.Printf("Warning: cache write of %s failed: %v", entry, err)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value.([]byte), false, nil
+}
+
+// Invalidate moves each namespace to a new version, orphaning its entries
+func (c *Cache) Invalidate(ctx context.Context, namespaces ...string) error {
+	if c == nil {
+		return nil
+	}
+	for _, namespace := range namespaces {
+		if _, err := c.store.Incr(ctx, versionKey(namespace)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// version is the current version of a namespace, zero until it is first
+// invalidated
+func (c *Cache) version(ctx context.Context, namespace string) (int64, error) {
+	data, err := c.store.Get(ctx, versionKey(namespace))
+	if errors.Is(err, ErrMiss) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+func versionKey(namespace string) string {
+	return keyPrefix + "version:" + namespace
+}
+
+func loadJSON(load func() (interface{}, error)) ([]byte, error) {
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+// RedisStore is a Store in Redis. Namespace versions are kept without
+// expiry; entries expire with their TTL.
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return data, err
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string) (int64, error) {
+	return s.client.Incr(ctx, key).Result()
+}
+
+// MemoryStore is a Store in process memory, for tests and single-instance
+// development
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || (!entry.expires.IsZero() && !s.now().Before(entry.expires)) {
+		return nil, ErrMiss
+	}
+	return entry.value, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expires = s.now().Add(ttl)
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Incr(ctx context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := int64(0)
+	if entry, ok := s.entries[key]; ok {
+		n, _ = strconv.ParseInt(string(entry.value), 10, 64)
+	}
+	n++
+	s.entries[key] = memoryEntry{value: []byte(strconv.FormatInt(n, 10))}
+	return n, nil
+}
@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchCachesUntilInvalidated(t *testing.T) {
+	c := New(NewMemoryStore())
+	ctx := context.Background()
+
+	loads := 0
+	load := func() (interface{}, error) {
+		loads++
+		return map[string]int{"loads": loads}, nil
+	}
+
+	data, hit, err := c.Fetch(ctx, "projects", "list", time.Minute, load)
+	if err != nil || hit || string(data) != `{"loads":1}` {
+		t.Fatalf("expected a miss loading %s, got %s hit=%v err=%v", `{"loads":1}`, data, hit, err)
+	}
+	if data, hit, _ = c.Fetch(ctx, "projects", "list", time.Minute, load); !hit || string(data) != `{"loads":1}` {
+		t.Errorf("expected a hit on the cached entry, got %s hit=%v", data, hit)
+	}
+
+	// Invalidating another namespace leaves the entry alone
+	c.Invalidate(ctx, "project:7")
+	if _, hit, _ = c.Fetch(ctx, "projects", "list", time.Minute, load); !hit {
+		t.Error("expected an unrelated invalidation to keep the entry")
+	}
+
+	c.Invalidate(ctx, "projects")
+	if data, hit, _ = c.Fetch(ctx, "projects", "list", time.Minute, load); hit || string(data) != `{"loads":2}` {
+		t.Errorf("expected a reload after invalidation, got %s hit=%v", data, hit)
+	}
+}
+
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+	c := New(NewMemoryStore())
+	failure := errors.New("database down")
+
+	if _, _, err := c.Fetch(context.Background(), "projects", "list", time.Minute, func() (interface{}, error) {
+		return nil, failure
+	}); err != failure {
+		t.Fatalf("expected the load error, got %v", err)
+	}
+	data, hit, _ := c.Fetch(context.Background(), "projects", "list", time.Minute, func() (interface{}, error) {
+		return "ok", nil
+	})
+	if hit || string(data) != `"ok"` {
+		t.Errorf("expected a fresh load after a failure, got %s hit=%v", data, hit)
+	}
+}
+
+func TestFetchCollapsesConcurrentMisses(t *testing.T) {
+	c := New(NewMemoryStore())
+	release := make(chan struct{})
+	var loads int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Fetch(context.Background(), "projects", "search:abc", time.Minute, func() (interface{}, error) {
+				atomic.AddInt32(&loads, 1)
+				<-release
+				return "result", nil
+			})
+		}()
+	}
+
+	// Let every caller reach the miss before the single load finishes
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("expected one load for concurrent misses, got %d", loads)
+	}
+}
+
+func TestNilCacheLoads(t *testing.T) {
+	var c *Cache
+	data, hit, err := c.Fetch(context.Background(), "projects", "list", time.Minute, func() (interface{}, error) {
+		return []int{1}, nil
+	})
+	if err != nil || hit || string(data) != "[1]" {
+		t.Errorf("expected a direct load, got %s hit=%v err=%v", data, hit, err)
+	}
+	if err := c.Invalidate(context.Background(), "projects"); err != nil {
+		t.Errorf("expected invalidating a nil cache to do nothing, got %v", err)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	store.Set(context.Background(), "k", []byte("v"), time.Minute)
+	if _, err := store.Get(context.Background(), "k"); err != nil {
+		t.Fatalf("expected the entry before expiry, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := store.Get(context.Background(), "k"); err != ErrMiss {
+		t.Errorf("expected a miss after expiry, got %v", err)
+	}
+}
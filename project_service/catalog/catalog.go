@@ -0,0 +1,441 @@
+// Package catalog reads and writes the project catalog as CSV or NDJSON
+// for bulk imports and exports. Both formats carry the same columns, so an
+// export can be edited and imported again.
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"project_service/models"
+	"shared/problem"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// maxLine is the longest NDJSON line read
+const maxLine = 1 << 20
+
+var (
+	// ErrUnknownFormat is returned for a format other than csv or ndjson
+	ErrUnknownFormat = errors.New("format must be csv or ndjson")
+	// ErrUnknownColumn is returned for a CSV header naming a column the
+	// catalog does not have
+	ErrUnknownColumn = errors.New("unknown column")
+)
+
+// Columns are the catalog's columns in file order
+var Columns = []string{
+	"id", "external_ref", "status", "title", "description", "category", "region", "country",
+	"verification_standard", "price_per_tonne", "total_capacity", "available_capacity",
+	"project_developer", "project_url", "image_url", "latitude", "longitude", "methodology_id", "credit_type",
+	"solution_type", "permanence_years", "buffer_pool_percent", "additionality_notes", "sdg_goals",
+	"created_at", "updated_at",
+}
+
+// readOnly are exported columns an import ignores
+var readOnly = map[string]bool{"id": true, "status": true, "created_at": true, "updated_at": true}
+
+// Record is a project as a row of a catalog file. The ID, status and
+// timestamps are only written; imports take the rest, keyed by the
+// external reference. A boundary and the developer account owning the
+// project only travel in NDJSON files; in CSV files SDG goals are separated
+// by semicolons.
+type Record struct {
+	ID     uint   `json:"id,omitempty"`
+	Status string `json:"status,omitempty"`
+	models.CreateProjectRequest
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// RecordOf returns a project's catalog record
+func RecordOf(project *models.Project) *Record {
+	record := &Record{
+		ID:     project.ID,
+		Status: project.Status,
+		CreateProjectRequest: models.CreateProjectRequest{
+			Title:                project.Title,
+			Description:          project.Description,
+			Category:             project.Category,
+			Region:               project.Region,
+			Country:              project.Country,
+			VerificationStandard: project.VerificationStandard,
+			PricePerTonne:        project.PricePerTonne,
+			TotalCapacity:        project.TotalCapacity,
+			AvailableCapacity:    project.AvailableCapacity,
+			ProjectDeveloper:     project.ProjectDeveloper,
+			DeveloperID:          project.DeveloperID,
+			ProjectURL:           project.ProjectURL,
+			ImageURL:             project.ImageURL,
+			Latitude:             project.Latitude,
+			Longitude:            project.Longitude,
+			Boundary:             project.Boundary,
+			MethodologyID:        project.MethodologyID,
+			CreditType:           project.CreditType,
+			SolutionType:         project.SolutionType,
+			PermanenceYears:      project.PermanenceYears,
+			BufferPoolPercent:    project.BufferPoolPercent,
+			AdditionalityNotes:   project.AdditionalityNotes,
+			SDGGoals:             project.SDGGoals,
+		},
+		CreatedAt: &project.CreatedAt,
+		UpdatedAt: &project.UpdatedAt,
+	}
+	if project.ExternalRef != nil {
+		record.ExternalRef = *project.ExternalRef
+	}
+	return record
+}
+
+// FormatOf returns the format of a content type, or "" for any other
+func FormatOf(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return FormatNDJSON
+	}
+	return ""
+}
+
+// ContentType returns the content type of a format
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// RowError is a row that could not be read. The rest of the file can
+// still be.
+type RowError struct {
+	Line   int
+	Errors []problem.FieldError
+}
+
+func (e *RowError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		messages = append(messages, strings.TrimSpace(fe.Field+" "+fe.Message))
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, strings.Join(messages, "; "))
+}
+
+// Reader reads records from a catalog file
+type Reader interface {
+	// Read returns the next record and the line it starts on. It returns
+	// a *RowError for a malformed row and io.EOF after the last one.
+	Read() (*Record, int, error)
+}
+
+// NewReader returns a Reader for a file in the format. A CSV file must
+// start with a header naming its columns, in any order.
+func NewReader(r io.Reader, format string) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+		return &ndjsonReader{scanner: scanner}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	for _, column := range Columns {
+		known[column] = true
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !known[name] {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+		columns[i] = name
+	}
+	return &csvReader{reader: reader, columns: columns}, nil
+}
+
+func (r *csvReader) Read() (*Record, int, error) {
+	row, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, parseErr.StartLine, &RowError{Line: parseErr.StartLine, Errors: []problem.FieldError{{Message: parseErr.Err.Error()}}}
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	line, _ := r.reader.FieldPos(0)
+
+	record := &Record{}
+	var fieldErrors []problem.FieldError
+	for i, value := range row {
+		if err := set(record, r.columns[i], strings.TrimSpace(value)); err != nil {
+			fieldErrors = append(fieldErrors, problem.FieldError{Field: r.columns[i], Message: err.Error()})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, line, &RowError{Line: line, Errors: fieldErrors}
+	}
+	return record, line, nil
+}
+
+// set puts a CSV value into its column of the record
+func set(record *Record, column, value string) error {
+	req := &record.CreateProjectRequest
+	text := map[string]*string{
+		"external_ref":          &req.ExternalRef,
+		"title":                 &req.Title,
+		"description":           &req.Description,
+		"category":              &req.Category,
+		"region":                &req.Region,
+		"country":               &req.Country,
+		"verification_standard": &req.VerificationStandard,
+		"project_developer":     &req.ProjectDeveloper,
+		"project_url":           &req.ProjectURL,
+		"image_url":             &req.ImageURL,
+		"methodology_id":        &req.MethodologyID,
+		"credit_type":           &req.CreditType,
+		"solution_type":         &req.SolutionType,
+		"additionality_notes":   &req.AdditionalityNotes,
+	}
+	floats := map[string]*float64{
+		"price_per_tonne":     &req.PricePerTonne,
+		"total_capacity":      &req.TotalCapacity,
+		"available_capacity":  &req.AvailableCapacity,
+		"buffer_pool_percent": &req.BufferPoolPercent,
+	}
+	// An empty coordinate leaves the project unlocated
+	coordinates := map[string]**float64{
+		"latitude":  &req.Latitude,
+		"longitude": &req.Longitude,
+	}
+
+	switch {
+	case readOnly[column]:
+	case text[column] != nil:
+		*text[column] = value
+	case floats[column] != nil && value != "":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		*floats[column] = f
+	case coordinates[column] != nil && value != "":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		*coordinates[column] = &f
+	case column == "permanence_years" && value != "":
+		years, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		req.PermanenceYears = years
+	case column == "sdg_goals" && value != "":
+		for _, part := range strings.Split(value, ";") {
+			goal, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil {
+				return errors.New("must be goal numbers separated by semicolons")
+			}
+			req.SDGGoals = append(req.SDGGoals, goal)
+		}
+	}
+	return nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Read() (*Record, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := bytes.TrimSpace(r.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.DisallowUnknownFields()
+		var record Record
+		if err := decoder.Decode(&record); err != nil {
+			return nil, r.line, &RowError{Line: r.line, Errors: []problem.FieldError{jsonError(err)}}
+		}
+		return &record, r.line, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return nil, 0, io.EOF
+}
+
+// jsonError describes why a line did not decode, naming the field when
+// it is known
+func jsonError(err error) problem.FieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return problem.FieldError{Field: typeErr.Field, Message: "must be a " + typeErr.Type.String()}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return problem.FieldError{Field: strings.Trim(field, `"`), Message: "is not a catalog column"}
+	}
+	return problem.FieldError{Message: "is not valid JSON"}
+}
+
+// Writer writes records to a catalog file
+type Writer interface {
+	Write(record *Record) error
+	// Flush writes any buffered records
+	Flush() error
+}
+
+// NewWriter returns a Writer for the format. A CSV file starts with a
+// header naming Columns.
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(Columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: writer}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(record *Record) error {
+	timestamp := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	number := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	coordinate := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return number(*f)
+	}
+	goals := make([]string, len(record.SDGGoals))
+	for i, goal := range record.SDGGoals {
+		goals[i] = strconv.Itoa(goal)
+	}
+
+	return w.writer.Write([]string{
+		strconv.FormatUint(uint64(record.ID), 10), record.ExternalRef, record.Status,
+		record.Title, record.Description, record.Category, record.Region, record.Country,
+		record.VerificationStandard, number(record.PricePerTonne), number(record.TotalCapacity),
+		number(record.AvailableCapacity), record.ProjectDeveloper, record.ProjectURL, record.ImageURL,
+		coordinate(record.Latitude), coordinate(record.Longitude), record.MethodologyID, record.CreditType,
+		record.SolutionType, strconv.Itoa(record.PermanenceYears), number(record.BufferPoolPercent),
+		record.AdditionalityNotes, strings.Join(goals, ";"), timestamp(record.CreatedAt), timestamp(record.UpdatedAt),
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(record *Record) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *ndjsonWriter) Flush() error {
+	return nil
+}
+
+// Import row actions
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionError  = "error"
+)
+
+// Report is the outcome of an import: what was, or on a dry run would be,
+// done with each row
+type Report struct {
+	DryRun  bool        `json:"dry_run"`
+	Rows    int         `json:"rows"`
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Failed  int         `json:"failed"`
+	Results []RowResult `json:"results"`
+}
+
+// RowResult is what an import did with a row. ProjectID is set for rows
+// that updated a project or created one outside a dry run.
+type RowResult struct {
+	Line        int                  `json:"line"`
+	ExternalRef string               `json:"external_ref,omitempty"`
+	Action      string               `json:"action"`
+	ProjectID   uint                 `json:"project_id,omitempty"`
+	Errors      []problem.FieldError `json:"errors,omitempty"`
+}
+
+// Add counts a row's result into the report
+func (r *Report) Add(result RowResult) {
+	r.Rows++
+	switch result.Action {
+	case ActionCreate:
+		r.Created++
+	case ActionUpdate:
+		r.Updated++
+	default:
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
@@ -0,0 +1,123 @@
+package catalog
+
+import (
+	"errors"
+	"io"
+	"project_service/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readAll reads every row, keeping row errors by line
+func readAll(t *testing.T, format, file string) ([]*Record, map[int]*RowError) {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(file), format)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []*Record
+	rowErrors := map[int]*RowError{}
+	for {
+		record, line, err := reader.Read()
+		if err == io.EOF {
+			return records, rowErrors
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rowErrors[line] = rowErr
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	file := "\ufeffTitle, External_Ref ,status,price_per_tonne,latitude,longitude\n" +
+		"\"Wind, phase 2\",W-2,published,12.5,22.26,71.19\n" +
+		"Too short,W-3\n" +
+		"Solar,S-1,,cheap,,\n" +
+		"Hydro,H-1,,,,\n"
+
+	records, rowErrors := readAll(t, FormatCSV, file)
+	if len(records) != 2 || records[0].Title != "Wind, phase 2" || records[0].ExternalRef != "W-2" || records[0].PricePerTonne != 12.5 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	if records[0].Status != "" {
+		t.Errorf("expected the status column ignored, got %q", records[0].Status)
+	}
+	if records[1].PricePerTonne != 0 {
+		t.Errorf("expected a blank number read as zero, got %v", records[1].PricePerTonne)
+	}
+	if lat, lon := records[0].Latitude, records[0].Longitude; lat == nil || lon == nil || *lat != 22.26 || *lon != 71.19 {
+		t.Errorf("expected the coordinates read, got %v, %v", lat, lon)
+	}
+	if records[1].Latitude != nil || records[1].Longitude != nil {
+		t.Errorf("expected blank coordinates to leave the project unlocated, got %v, %v", records[1].Latitude, records[1].Longitude)
+	}
+	if rowErrors[3] == nil || rowErrors[4] == nil || rowErrors[4].Errors[0].Field != "price_per_tonne" {
+		t.Errorf("expected lines 3 and 4 to fail, got %v", rowErrors)
+	}
+
+	if _, err := NewReader(strings.NewReader("title,colour\n"), FormatCSV); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("expected an unknown column refused, got %v", err)
+	}
+	if _, err := NewReader(strings.NewReader(""), "xml"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected an unknown format refused, got %v", err)
+	}
+}
+
+func TestReadCSVQuality(t *testing.T) {
+	file := "title,credit_type,permanence_years,buffer_pool_percent,sdg_goals\n" +
+		"Mangroves,removal,40,15,13; 14\n" +
+		"Biochar,removal,a century,,13\n" +
+		"Cookstoves,avoidance,,,3;seven\n"
+
+	records, rowErrors := readAll(t, FormatCSV, file)
+	if len(records) != 1 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	got := records[0]
+	if got.CreditType != "removal" || got.PermanenceYears != 40 || got.BufferPoolPercent != 15 ||
+		!reflect.DeepEqual(got.SDGGoals, models.SDGGoals{13, 14}) {
+		t.Errorf("expected the quality columns read, got %+v", got.CreateProjectRequest)
+	}
+	if rowErrors[3] == nil || rowErrors[3].Errors[0].Field != "permanence_years" ||
+		rowErrors[4] == nil || rowErrors[4].Errors[0].Field != "sdg_goals" {
+		t.Errorf("expected lines 3 and 4 to fail, got %v", rowErrors)
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	file := `{"external_ref":"W-2","title":"Wind","price_per_tonne":12.5}
+
+{"external_ref":"W-3","colour":"green"}
+{"external_ref":"W-4","price_per_tonne":"cheap"}
+not json
+`
+	records, rowErrors := readAll(t, FormatNDJSON, file)
+	if len(records) != 1 || records[0].ExternalRef != "W-2" || records[0].PricePerTonne != 12.5 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	for line, field := range map[int]string{3: "colour", 4: "price_per_tonne", 5: ""} {
+		if rowErrors[line] == nil || rowErrors[line].Errors[0].Field != field {
+			t.Errorf("expected line %d to fail on %q, got %v", line, field, rowErrors[line])
+		}
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	for contentType, want := range map[string]string{
+		"text/csv; charset=utf-8": FormatCSV,
+		"application/x-ndjson":    FormatNDJSON,
+		"application/json":        "",
+	} {
+		if got := FormatOf(contentType); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", contentType, got, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"shared/envconfig"
+	"time"
+)
+
+// jwtSecretMinLength is the shortest JWT secret accepted outside the dev profile
+const jwtSecretMinLength = 32
+
+// Config is the project service configuration
+type Config struct {
+	Profile string `env:"APP_ENV" default:"production" validate:"oneof=dev test staging production"`
+	Port    int    `env:"PORT" default:"8081" validate:"min=1,max=65535"`
+
+	DB            DatabaseConfig
+	Search        SearchConfig
+	Elasticsearch ElasticsearchConfig
+	Redis         RedisConfig
+	JWT           JWTConfig
+	Reservations  ReservationConfig
+	Pricing       PricingConfig
+	Documents     DocumentConfig
+	S3            S3Config
+	RabbitMQ      RabbitMQConfig
+	Outbox        OutboxConfig
+}
+
+// DatabaseConfig is the Postgres connection
+type DatabaseConfig struct {
+	Host     string `env:"DB_HOST" default:"localhost" validate:"required"`
+	Port     int    `env:"DB_PORT" default:"5432" validate:"min=1,max=65535"`
+	User     string `env:"DB_USER" default:"postgres" validate:"required"`
+	Password string `env:"DB_PASSWORD" secret:"true"`
+	Name     string `env:"DB_NAME" default:"carbon_clear_projects" validate:"required"`
+	SSLMode  string `env:"DB_SSLMODE" default:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+}
+
+// DSN returns the connection string for the Postgres driver
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		c.Host, c.User, c.Password, c.Name, c.Port, c.SSLMode)
+}
+
+// Search backends
+const (
+	SearchElasticsearch = "elasticsearch"
+	SearchPostgres      = "postgres"
+	SearchEmbedded      = "embedded"
+)
+
+// SearchConfig selects the search backend. Elasticsearch falls back to
+// Postgres full-text search when the cluster is unreachable; the embedded
+// in-process index needs no external service.
+type SearchConfig struct {
+	Backend string `env:"SEARCH_BACKEND" default:"elasticsearch" validate:"oneof=elasticsearch postgres embedded"`
+}
+
+// ElasticsearchConfig is the optional search cluster
+type ElasticsearchConfig struct {
+	URL      string `env:"ELASTICSEARCH_URL" default:"http://localhost:9200" validate:"required,url"`
+	Username string `env:"ELASTICSEARCH_USERNAME"`
+	Password string `env:"ELASTICSEARCH_PASSWORD" secret:"true"`
+}
+
+// RedisConfig is the optional cache
+type RedisConfig struct {
+	Addr     string `env:"REDIS_ADDR" default:"localhost:6379" validate:"required,hostname_port"`
+	Password string `env:"REDIS_PASSWORD" secret:"true"`
+	DB       int    `env:"REDIS_DB" default:"0" validate:"min=0"`
+}
+
+// ReservationConfig sets how long capacity reservations are held when the
+// caller gives no TTL, and how often expired reservations are swept
+type ReservationConfig struct {
+	TTL           time.Duration `env:"RESERVATION_TTL" default:"15m" validate:"min=1s,max=24h"`
+	SweepInterval time.Duration `env:"RESERVATION_SWEEP_INTERVAL" default:"1m" validate:"min=1s"`
+}
+
+// PricingConfig sets how often due price schedules are applied
+type PricingConfig struct {
+	SweepInterval time.Duration `env:"PRICE_SCHEDULE_SWEEP_INTERVAL" default:"1m" validate:"min=1s"`
+}
+
+// Document stores
+const (
+	DocumentStorageLocal = "local"
+	DocumentStorageS3    = "s3"
+)
+
+// DocumentConfig sets where uploaded project documents are kept, how large
+// they may be, and how long their signed download URLs last. URLBase
+// prefixes the URLs when clients reach the service through another host.
+type DocumentConfig struct {
+	Storage   string        `env:"DOCUMENT_STORAGE" default:"local" validate:"oneof=local s3"`
+	Dir       string        `env:"DOCUMENT_DIR" default:"./data/documents" validate:"required"`
+	MaxMB     int           `env:"DOCUMENT_MAX_MB" default:"20" validate:"min=1,max=1024"`
+	URLSecret string        `env:"DOCUMENT_URL_SECRET" default:"document-url-secret" secret:"true" validate:"required"`
+	URLTTL    time.Duration `env:"DOCUMENT_URL_TTL" default:"15m" validate:"min=1s,max=168h"`
+	URLBase   string        `env:"DOCUMENT_URL_BASE" validate:"omitempty,url"`
+}
+
+// S3Config is the S3-compatible bucket documents are kept in when
+// DOCUMENT_STORAGE is s3
+type S3Config struct {
+	Endpoint  string `env:"S3_ENDPOINT" default:"http://localhost:9000" validate:"required,url"`
+	Region    string `env:"S3_REGION" default:"us-east-1" validate:"required"`
+	Bucket    string `env:"S3_BUCKET" default:"project-documents" validate:"required"`
+	AccessKey string `env:"S3_ACCESS_KEY"`
+	SecretKey string `env:"S3_SECRET_KEY" secret:"true"`
+}
+
+// RabbitMQConfig is the broker project events are published to, on a
+// topic exchange routed by event type. Events are not published when the
+// URL is empty; they still keep the search index in sync.
+type RabbitMQConfig struct {
+	URL      string `env:"RABBITMQ_URL" secret:"true" validate:"omitempty,url"`
+	Exchange string `env:"PROJECT_EVENTS_EXCHANGE" default:"project.events" validate:"required"`
+}
+
+// OutboxConfig sets how often the outbox of project events is relayed and
+// how long published events are kept
+type OutboxConfig struct {
+	RelayInterval time.Duration `env:"OUTBOX_RELAY_INTERVAL" default:"1s" validate:"min=100ms"`
+	Retention     time.Duration `env:"OUTBOX_RETENTION" default:"168h" validate:"min=1h"`
+}
+
+// JWTConfig holds the secrets that verify admin tokens and the user tokens
+// developers sign in with. They must match the user service and the API
+// gateway.
+type JWTConfig struct {
+	AdminSecret string `env:"ADMIN_JWT_SECRET" default:"admin-secret-key" secret:"true" validate:"required"`
+	UserSecret  string `env:"USER_JWT_SECRET" default:"user-secret-key" secret:"true" validate:"required"`
+}
+
+// Load reads the configuration from the environment and the optional config
+// file and validates it. The config is returned even when it is invalid so
+// it can still be printed.
+func Load() (*Config, error) {
+	src, err := envconfig.NewSource()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	problems := src.Load(cfg)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, &envconfig.Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() []string {
+	problems := envconfig.Check(c)
+	if c.Profile == envconfig.ProfileDev {
+		return problems
+	}
+
+	problems = append(problems, envconfig.CheckSecret("ADMIN_JWT_SECRET", c.JWT.AdminSecret, jwtSecretMinLength)...)
+	problems = append(problems, envconfig.CheckSecret("USER_JWT_SECRET", c.JWT.UserSecret, jwtSecretMinLength)...)
+	if c.JWT.UserSecret != "" && c.JWT.UserSecret == c.JWT.AdminSecret {
+		problems = append(problems, "USER_JWT_SECRET and ADMIN_JWT_SECRET must differ")
+	}
+	problems = append(problems, envconfig.CheckSecret("DB_PASSWORD", c.DB.Password, 1)...)
+	problems = append(problems, envconfig.CheckSecret("DOCUMENT_URL_SECRET", c.Documents.URLSecret, jwtSecretMinLength)...)
+	if c.Documents.Storage == DocumentStorageS3 {
+		if c.S3.AccessKey == "" {
+			problems = append(problems, "S3_ACCESS_KEY must be set outside the dev profile")
+		}
+		problems = append(problems, envconfig.CheckSecret("S3_SECRET_KEY", c.S3.SecretKey, 1)...)
+	}
+	if c.Redis.Password != "" {
+		problems = append(problems, envconfig.CheckSecret("REDIS_PASSWORD", c.Redis.Password, 1)...)
+	}
+	if c.Elasticsearch.Password != "" {
+		problems = append(problems, envconfig.CheckSecret("ELASTICSEARCH_PASSWORD", c.Elasticsearch.Password, 1)...)
+	}
+	problems = append(problems, envconfig.CheckURLCredentials("RABBITMQ_URL", c.RabbitMQ.URL)...)
+	return problems
+}
+
+// RunCommand runs a "config" subcommand and returns the process exit code
+func RunCommand(args []string, stdout, stderr io.Writer) int {
+	return envconfig.RunCommand(args, stdout, stderr, Load)
+}
@@ -2,34 +2,15 @@ package config
 
 import (
 	"fmt"
-	"log"
-	"os"
 	"project_service/models"
+	"project_service/repositories"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
-
-func InitDB() (*gorm.DB, error) {
-	var err error
-
-	// Initiate secrets and credentials (optional - only for local development)
-	err = godotenv.Load()
-	if err != nil {
-		log.Println("No .env file found, using environment variables from system")
-	}
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func InitDB(cfg DatabaseConfig) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
@@ -46,12 +27,38 @@ func InitDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	DB = db
-
-	err = db.AutoMigrate(&models.Project{})
+	err = db.AutoMigrate(&models.Project{}, &models.ProjectTransition{}, &models.ProjectRevision{}, &models.Vintage{}, &models.PriceSchedule{}, &models.PriceChange{}, &models.Document{}, &models.SerialBlock{}, &models.Reservation{}, &models.CapacityLedgerEntry{}, &models.OutboxEvent{}, &models.MonitoringPeriod{})
 	if err != nil {
 		return nil, err
 	}
 
-	return DB, nil
+	// Projects from before the lifecycle were either active or inactive
+	for legacy, status := range map[string]string{"active": models.StatusPublished, "inactive": models.StatusRetired} {
+		if err := db.Model(&models.Project{}).Where("status = ?", legacy).Update("status", status).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Revisions are written once and never changed
+	for _, migration := range repositories.RevisionMigrations {
+		if err := db.Exec(migration).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Full-text search column and index for the Postgres search backend
+	for _, migration := range repositories.SearchVectorMigrations {
+		if err := db.Exec(migration).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Spatial index for the geo search filters
+	for _, migration := range repositories.LocationMigrations {
+		if err := db.Exec(migration).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return db, nil
 }
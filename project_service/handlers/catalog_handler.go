@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"project_service/cache"
+	"project_service/catalog"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxImportRows is the most rows one import takes
+	maxImportRows = 10000
+	// maxImportSize is the largest file one import takes
+	maxImportSize = 32 << 20
+	// exportBatch is the number of projects read at a time while exporting
+	exportBatch = 500
+)
+
+// ImportReason is the reason recorded on the revisions an import makes
+const ImportReason = "bulk import"
+
+var (
+	// ErrTooManyRows is returned for an import file with more than
+	// maxImportRows rows
+	ErrTooManyRows = fmt.Errorf("an import takes at most %d rows", maxImportRows)
+	// errImportFailed wraps repository failures during an import, as
+	// opposed to problems with the file
+	errImportFailed = errors.New("import failed")
+)
+
+// CatalogHandler imports and exports the project catalog in bulk
+type CatalogHandler struct {
+	repo  repositories.ProjectRepository
+	cache *cache.Cache
+}
+
+func NewCatalogHandler(repo repositories.ProjectRepository) *CatalogHandler {
+	return &CatalogHandler{repo: repo}
+}
+
+// SetCache caches responses
+func (h *CatalogHandler) SetCache(responses *cache.Cache) {
+	h.cache = responses
+}
+
+// ImportProjects creates or updates projects from a CSV or NDJSON file
+// @Summary Import projects
+// @Description Import projects from a CSV file with a header row or from NDJSON, one project per line, with the columns of the export (admin only). Every row is validated as a create request and needs an external_ref: a row whose reference is new creates a draft project, one whose reference exists updates that project, keeping its status and its available capacity, which only reservations and sales move, so a file exported before a sale does not undo it. Blank optional fields leave the existing values. Invalid rows are reported and skipped; the rest are applied unless dry_run is set. The format comes from the format parameter or else the content type.
+// @Tags projects
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Security AdminAuth
+// @Param format query string false "File format" Enums(csv, ndjson)
+// @Param dry_run query bool false "Only validate and report what would be done"
+// @Success 200 {object} catalog.Report "What was done with each row"
+// @Failure 400 {object} problem.Problem "Unreadable file, unknown column or invalid parameter"
+// @Failure 413 {object} problem.Problem "Too many rows or file too large"
+// @Failure 415 {object} problem.Problem "Unknown format"
+// @Failure 500 {object} problem.Problem "Failed to import projects"
+// @Router /api/v1/projects/admin/import [post]
+func (h *CatalogHandler) ImportProjects(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = catalog.FormatOf(c.Request().Header.Get(echo.HeaderContentType))
+	}
+	if format != catalog.FormatCSV && format != catalog.FormatNDJSON {
+		return problem.New(http.StatusUnsupportedMediaType, "Send text/csv or application/x-ndjson, or set format to csv or ndjson")
+	}
+
+	var dryRun bool
+	if value := c.QueryParam("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return problem.BadRequest("dry_run must be true or false")
+		}
+	}
+
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxImportSize)
+	report, err := h.Import(c.Request().Context(), body, format, dryRun, models.Change{Actor: actor, Reason: ImportReason})
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.Is(err, ErrTooManyRows):
+		return problem.New(http.StatusRequestEntityTooLarge, err.Error())
+	case errors.As(err, &tooLarge):
+		return problem.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("An import file may be at most %d MB", maxImportSize>>20))
+	case errors.Is(err, catalog.ErrUnknownColumn):
+		return problem.BadRequest(err.Error())
+	case errors.Is(err, errImportFailed):
+		return problem.Internal("Failed to import projects", err)
+	case err != nil:
+		return problem.BadRequest("Could not read the file: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// ExportProjects streams the whole catalog as CSV or NDJSON
+// @Summary Export projects
+// @Description Stream every project, in any status, as CSV with a header row or as NDJSON (admin only). The file can be edited and imported again.
+// @Tags projects
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Security AdminAuth
+// @Param format query string false "File format, csv by default" Enums(csv, ndjson)
+// @Success 200 {file} file "The catalog"
+// @Failure 400 {object} problem.Problem "Unknown format"
+// @Router /api/v1/projects/admin/export [get]
+func (h *CatalogHandler) ExportProjects(c echo.Context) error {
+	format := c.QueryParam("format")
+	if format == "" {
+		format = catalog.FormatCSV
+	}
+	if format != catalog.FormatCSV && format != catalog.FormatNDJSON {
+		return problem.BadRequest(catalog.ErrUnknownFormat.Error())
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, catalog.ContentType(format))
+	res.Header().Set(echo.HeaderContentDisposition, "attachment; filename=projects."+format)
+	res.WriteHeader(http.StatusOK)
+
+	// The status is sent, so a failure can only cut the file short
+	if err := h.Export(res, format); err != nil {
+		log.Printf("Warning: project export failed: %v", err)
+	}
+	return nil
+}
+
+// importRow is a row read from an import file and what to do with it
+type importRow struct {
+	result   catalog.RowResult
+	req      *models.CreateProjectRequest
+	existing *models.Project
+}
+
+// Import reads a catalog file and creates or updates a project for each
+// valid row, or only reports what it would do on a dry run. The whole file
+// is read and checked before anything is written, so a file that cannot
+// be read changes nothing. Each write is a revision of its project made by
+// the change.
+func (h *CatalogHandler) Import(ctx context.Context, r io.Reader, format string, dryRun bool, change models.Change) (*catalog.Report, error) {
+	reader, err := catalog.NewReader(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []importRow
+	seen := map[string]int{}
+	for {
+		record, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == maxImportRows {
+			return nil, ErrTooManyRows
+		}
+
+		var rowErr *catalog.RowError
+		switch {
+		case errors.As(err, &rowErr):
+			rows = append(rows, importRow{result: catalog.RowResult{Line: line, Action: catalog.ActionError, Errors: rowErr.Errors}})
+		case err != nil:
+			return nil, err
+		default:
+			row, err := h.plan(&record.CreateProjectRequest, line, seen)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	report := &catalog.Report{DryRun: dryRun, Results: []catalog.RowResult{}}
+	namespaces := []string{}
+	for _, row := range rows {
+		if !dryRun && row.result.Action != catalog.ActionError {
+			h.apply(&row, change)
+			if row.result.ProjectID != 0 {
+				namespaces = append(namespaces, projectNamespace(uint64(row.result.ProjectID)))
+			}
+		}
+		report.Add(row.result)
+	}
+
+	if len(namespaces) > 0 {
+		namespaces = append(namespaces, catalogNamespace)
+		if err := h.cache.Invalidate(ctx, namespaces...); err != nil {
+			log.Printf("Warning: failed to invalidate cache %v: %v", namespaces, err)
+		}
+	}
+	return report, nil
+}
+
+// plan validates a row and decides whether it creates or updates a project
+func (h *CatalogHandler) plan(req *models.CreateProjectRequest, line int, seen map[string]int) (importRow, error) {
+	row := importRow{result: catalog.RowResult{Line: line, ExternalRef: req.ExternalRef}, req: req}
+
+	fieldErrors, err := fieldErrorsOf(req)
+	if err != nil {
+		return row, err
+	}
+	if req.ExternalRef == "" {
+		fieldErrors = append([]problem.FieldError{{Field: "external_ref", Message: "is required"}}, fieldErrors...)
+	} else if first, ok := seen[req.ExternalRef]; ok {
+		fieldErrors = append(fieldErrors, problem.FieldError{Field: "external_ref", Message: fmt.Sprintf("repeats line %d", first)})
+	} else {
+		seen[req.ExternalRef] = line
+	}
+	if len(fieldErrors) > 0 {
+		row.result.Action, row.result.Errors = catalog.ActionError, fieldErrors
+		return row, nil
+	}
+
+	existing, err := h.repo.GetByExternalRef(req.ExternalRef)
+	switch {
+	case err == nil:
+		row.result.Action, row.result.ProjectID, row.existing = catalog.ActionUpdate, existing.ID, existing
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		row.result.Action = catalog.ActionCreate
+	default:
+		return row, fmt.Errorf("%w: %v", errImportFailed, err)
+	}
+	return row, nil
+}
+
+// apply creates or updates a row's project, turning the row into an error
+// if it cannot be saved
+func (h *CatalogHandler) apply(row *importRow, change models.Change) {
+	var err error
+	if row.existing == nil {
+		project := newProject(row.req)
+		if err = h.repo.Create(project, change); err == nil {
+			row.result.ProjectID = project.ID
+		}
+	} else {
+		update := &models.UpdateProjectRequest{
+			Title:                row.req.Title,
+			Description:          row.req.Description,
+			Category:             row.req.Category,
+			Region:               row.req.Region,
+			Country:              row.req.Country,
+			VerificationStandard: row.req.VerificationStandard,
+			TotalCapacity:        row.req.TotalCapacity,
+			ProjectDeveloper:     row.req.ProjectDeveloper,
+			DeveloperID:          row.req.DeveloperID,
+			ProjectURL:           row.req.ProjectURL,
+			ImageURL:             row.req.ImageURL,
+			Latitude:             row.req.Latitude,
+			Longitude:            row.req.Longitude,
+			Boundary:             row.req.Boundary,
+			MethodologyID:        row.req.MethodologyID,
+			CreditType:           row.req.CreditType,
+			SolutionType:         row.req.SolutionType,
+			PermanenceYears:      row.req.PermanenceYears,
+			BufferPoolPercent:    row.req.BufferPoolPercent,
+			AdditionalityNotes:   row.req.AdditionalityNotes,
+			SDGGoals:             row.req.SDGGoals,
+		}
+		// The available capacity is left out: sales since the export would
+		// otherwise be given back. An unchanged price stays out of the
+		// price history.
+		if row.req.PricePerTonne != row.existing.PricePerTonne {
+			update.PricePerTonne = row.req.PricePerTonne
+		}
+		err = h.repo.Update(row.existing.ID, update, change)
+	}
+
+	if err != nil {
+		log.Printf("Warning: failed to import line %d: %v", row.result.Line, err)
+		row.result.Action = catalog.ActionError
+		row.result.ProjectID = 0
+		row.result.Errors = []problem.FieldError{{Message: "could not be saved"}}
+		if errors.Is(err, repositories.ErrCapacityExceedsTotal) {
+			row.result.Errors = []problem.FieldError{{Field: "total_capacity", Message: "is below the available capacity"}}
+		}
+	}
+}
+
+// Export writes every project to w in the format, in batches, flushing
+// after each one when w can be flushed
+func (h *CatalogHandler) Export(w io.Writer, format string) error {
+	writer, err := catalog.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	var afterID uint
+	for {
+		projects, err := h.repo.GetAfter(afterID, exportBatch)
+		if err != nil {
+			return err
+		}
+		for i := range projects {
+			if err := writer.Write(catalog.RecordOf(&projects[i])); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		if len(projects) < exportBatch {
+			return nil
+		}
+		afterID = projects[len(projects)-1].ID
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"net/http"
+	"project_service/catalog"
+	"project_service/models"
+	"project_service/repositories"
+	"strings"
+	"testing"
+	"time"
+)
+
+const importCSV = `external_ref,title,description,category,region,country,verification_standard,price_per_tonne,total_capacity
+VCS-1001,Andes Reforestation,Native species planting,forestry,South America,Peru,VCS,14,300
+VCS-1002,Mekong Biogas,Household digesters,energy,Asia,Vietnam,Gold Standard,9,200
+VCS-1003,Missing Price,No price given,energy,Asia,India,VCS,,100
+VCS-1004,Bad Capacity,Capacity is not a number,energy,Asia,India,VCS,6,lots
+VCS-1001,Andes Again,Repeats the first reference,forestry,South America,Peru,VCS,14,300
+`
+
+func newTestCatalogHandler(t *testing.T) (*CatalogHandler, *repositories.InMemoryProjectRepository) {
+	t.Helper()
+	_, repo := newTestHandler()
+	seedProjects(t, repo)
+	return NewCatalogHandler(repo), repo
+}
+
+func TestImportProjectsReportsEveryRow(t *testing.T) {
+	h, repo := newTestCatalogHandler(t)
+
+	var report catalog.Report
+	rec := serve(t, h.ImportProjects, http.MethodPost, "/?format=csv", importCSV, asAdmin(1, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	decode(t, rec, &report)
+	if report.Rows != 5 || report.Created != 2 || report.Failed != 3 || report.DryRun {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	failed := map[int]string{}
+	for _, result := range report.Results {
+		if result.Action == catalog.ActionError {
+			failed[result.Line] = result.Errors[0].Field + " " + result.Errors[0].Message
+		}
+	}
+	for line, want := range map[int]string{4: "price_per_tonne is required", 5: "total_capacity must be a number", 6: "external_ref repeats line 2"} {
+		if failed[line] != want {
+			t.Errorf("expected line %d to fail with %q, got %q", line, want, failed[line])
+		}
+	}
+
+	project, err := repo.GetByExternalRef("VCS-1002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Title != "Mekong Biogas" || project.Status != models.StatusDraft || project.PricePerTonne != 9 {
+		t.Errorf("expected the row imported as a draft, got %+v", project)
+	}
+}
+
+func TestImportProjectsUpsertsByExternalRef(t *testing.T) {
+	h, repo := newTestCatalogHandler(t)
+	serve(t, h.ImportProjects, http.MethodPost, "/?format=csv", importCSV, asAdmin(1, nil))
+	created, _ := repo.GetByExternalRef("VCS-1001")
+
+	update := `{"external_ref":"VCS-1001","title":"Andes Reforestation II","description":"Native species planting","category":"forestry","region":"South America","country":"Peru","verification_standard":"VCS","price_per_tonne":14}
+{"external_ref":"VCS-2001","title":"Sahel Solar","description":"Mini-grids","category":"energy","region":"Africa","country":"Mali","verification_standard":"VCS","price_per_tonne":7}`
+
+	// A dry run reports what would happen and changes nothing
+	var report catalog.Report
+	decode(t, serve(t, h.ImportProjects, http.MethodPost, "/?format=ndjson&dry_run=true", update, asAdmin(1, nil)), &report)
+	if !report.DryRun || report.Updated != 1 || report.Created != 1 || report.Results[0].ProjectID != created.ID {
+		t.Fatalf("unexpected dry run report: %+v", report)
+	}
+	if _, err := repo.GetByExternalRef("VCS-2001"); err == nil {
+		t.Error("expected a dry run to create nothing")
+	}
+
+	decode(t, serve(t, h.ImportProjects, http.MethodPost, "/?format=ndjson", update, asAdmin(1, nil)), &report)
+	if report.Updated != 1 || report.Created != 1 || report.Failed != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	updated, _ := repo.GetByID(created.ID)
+	if updated.Title != "Andes Reforestation II" || updated.TotalCapacity != 300 {
+		t.Errorf("expected the title updated and blank fields kept, got %+v", updated)
+	}
+	if history, _ := repositories.NewInMemoryPricingRepository(repo).History(created.ID, 10, 0); len(history) != 1 {
+		t.Errorf("expected an unchanged price kept out of the history, got %d changes", len(history))
+	}
+}
+
+func TestImportProjectsKeepsAvailableCapacity(t *testing.T) {
+	h, repo := newTestCatalogHandler(t)
+	ref := "VCS-3001"
+	project := models.Project{ExternalRef: &ref, Title: "Borneo Peatland", Description: "Peat rewetting", Category: "forestry",
+		Region: "Asia", Country: "Indonesia", VerificationStandard: "VCS", PricePerTonne: 10,
+		TotalCapacity: 300, AvailableCapacity: 300, Status: models.StatusPublished}
+	if err := repo.Create(&project, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	exported := serve(t, h.ExportProjects, http.MethodGet, "/?format=ndjson", "", asAdmin(1, nil)).Body.String()
+
+	// 40 tonnes are sold after the export
+	reservations := repositories.NewInMemoryReservationRepository(repo)
+	reservation, err := reservations.Reserve(project.ID, 0, 40, "order-1-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reservations.CommitAll([]string{reservation.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	var report catalog.Report
+	decode(t, serve(t, h.ImportProjects, http.MethodPost, "/?format=ndjson", exported, asAdmin(1, nil)), &report)
+	if report.Updated != 1 {
+		t.Fatalf("expected the project updated, got %+v", report)
+	}
+	updated, _ := repo.GetByID(project.ID)
+	if updated.AvailableCapacity != 260 || updated.TotalCapacity != 300 {
+		t.Errorf("expected the sale kept out of the available capacity, got %v of %v", updated.AvailableCapacity, updated.TotalCapacity)
+	}
+
+	// A total below what is still available cannot be saved
+	lowered := `{"external_ref":"VCS-3001","title":"Borneo Peatland","description":"Peat rewetting","category":"forestry","region":"Asia","country":"Indonesia","verification_standard":"VCS","price_per_tonne":10,"total_capacity":200}`
+	decode(t, serve(t, h.ImportProjects, http.MethodPost, "/?format=ndjson", lowered, asAdmin(1, nil)), &report)
+	if report.Failed != 1 || report.Results[0].Errors[0].Field != "total_capacity" {
+		t.Errorf("expected the lowered total refused, got %+v", report)
+	}
+}
+
+func TestImportProjectsRefusesUnreadableFiles(t *testing.T) {
+	h, _ := newTestCatalogHandler(t)
+
+	for _, tt := range []struct {
+		name   string
+		target string
+		body   string
+		status int
+	}{
+		{"unknown format", "/?format=xml", "<projects/>", http.StatusUnsupportedMediaType},
+		{"no format", "/", importCSV, http.StatusUnsupportedMediaType},
+		{"unknown column", "/?format=csv", "external_ref,colour\nVCS-1,green\n", http.StatusBadRequest},
+		{"empty file", "/?format=csv", "", http.StatusBadRequest},
+		{"invalid dry run", "/?format=csv&dry_run=maybe", importCSV, http.StatusBadRequest},
+		{"too many rows", "/?format=csv", "external_ref\n" + strings.Repeat("x\n", maxImportRows+1), http.StatusRequestEntityTooLarge},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, h.ImportProjects, http.MethodPost, tt.target, tt.body, asAdmin(1, nil)); rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestExportProjectsRoundTrips(t *testing.T) {
+	h, _ := newTestCatalogHandler(t)
+	serve(t, h.ImportProjects, http.MethodPost, "/?format=csv", importCSV, asAdmin(1, nil))
+
+	for _, format := range []string{catalog.FormatCSV, catalog.FormatNDJSON} {
+		rec := serve(t, h.ExportProjects, http.MethodGet, "/?format="+format, "", asAdmin(1, nil))
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Disposition") != "attachment; filename=projects."+format {
+			t.Fatalf("expected a %s attachment, got %d %v", format, rec.Code, rec.Header())
+		}
+
+		// Drafts are exported with the published projects, and the file
+		// imports again as updates of the projects with a reference
+		var report catalog.Report
+		decode(t, serve(t, h.ImportProjects, http.MethodPost, "/?dry_run=true&format="+format, rec.Body.String(), asAdmin(1, nil)), &report)
+		if report.Rows != 5 || report.Updated != 2 || report.Failed != 3 {
+			t.Errorf("expected the %s export to import again, got %+v", format, report)
+		}
+	}
+
+	if rec := serve(t, h.ExportProjects, http.MethodGet, "/?format=xml", "", asAdmin(1, nil)); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown format, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// DeveloperHandler serves the developer portal: a developer's own projects,
+// which they draft, describe and submit for review. The routes of a single
+// project are scoped to its owner by RequireOwner.
+type DeveloperHandler struct {
+	repo  repositories.ProjectRepository
+	cache *cache.Cache
+}
+
+func NewDeveloperHandler(repo repositories.ProjectRepository) *DeveloperHandler {
+	return &DeveloperHandler{repo: repo}
+}
+
+// SetCache caches responses
+func (h *DeveloperHandler) SetCache(responses *cache.Cache) {
+	h.cache = responses
+}
+
+// RequireDeveloper lets only developer tokens through
+func (h *DeveloperHandler) RequireDeveloper(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		actor, err := actorOf(c)
+		if err != nil {
+			return err
+		}
+		if actor.Role != models.RoleDeveloper {
+			return problem.Forbidden("Only developer accounts may use the developer portal")
+		}
+		return next(c)
+	}
+}
+
+// RequireOwner lets a developer through to a project only when it is
+// linked to their account. Anyone else's project is not found, so the
+// portal does not tell which project IDs exist.
+func (h *DeveloperHandler) RequireOwner(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return problem.BadRequest("Invalid project ID")
+		}
+		actor, err := actorOf(c)
+		if err != nil {
+			return err
+		}
+
+		project, err := h.repo.GetByID(uint(id))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return problem.NotFound("Project not found")
+			}
+			return problem.Internal("Failed to retrieve project", err)
+		}
+		if project.DeveloperID == nil || *project.DeveloperID != actor.ID {
+			return problem.NotFound("Project not found")
+		}
+		return next(c)
+	}
+}
+
+// ListProjects returns the caller's projects
+// @Summary List developer projects
+// @Description List the projects linked to the calling developer's account in every status, newest first, and the total across all pages
+// @Tags developer
+// @Produce json
+// @Security DeveloperAuth
+// @Param limit query int false "Limit number of results (max 100)" default(50)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} map[string]interface{} "Projects of the developer"
+// @Failure 403 {object} problem.Problem "Caller is not a developer"
+// @Failure 500 {object} problem.Problem "Failed to retrieve projects"
+// @Router /api/v1/projects/developer [get]
+func (h *DeveloperHandler) ListProjects(c echo.Context) error {
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	page, err := h.repo.GetByDeveloper(actor.ID, limit, offset)
+	if err != nil {
+		return problem.Internal("Failed to retrieve projects", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"projects": page.Projects,
+		"total":    page.Total,
+		"limit":    limit,
+		"offset":   offset,
+		"count":    len(page.Projects),
+	})
+}
+
+// CreateProject drafts a project owned by the caller
+// @Summary Create developer project
+// @Description Draft a new project linked to the calling developer's account. It stays private until the developer submits it for review and an admin approves and publishes it. Any developer_id sent is replaced by the caller's.
+// @Tags developer
+// @Accept json
+// @Produce json
+// @Security DeveloperAuth
+// @Param request body models.CreateProjectRequest true "Project details"
+// @Success 201 {object} models.Project "Project created successfully"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 403 {object} problem.Problem "Caller is not a developer"
+// @Failure 409 {object} problem.Problem "External reference already used"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to create project"
+// @Router /api/v1/projects/developer [post]
+func (h *DeveloperHandler) CreateProject(c echo.Context) error {
+	var req models.CreateProjectRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	if req.ExternalRef != "" {
+		if _, err := h.repo.GetByExternalRef(req.ExternalRef); err == nil {
+			return problem.Conflict("A project with this external reference already exists")
+		}
+	}
+
+	project := newProject(&req)
+	project.DeveloperID = &actor.ID
+	if err := h.repo.Create(project, models.Change{Actor: actor}); err != nil {
+		return problem.Internal("Failed to create project", err)
+	}
+
+	invalidate(c, h.cache, catalogNamespace)
+
+	return c.JSON(http.StatusCreated, project)
+}
+
+// UpdateProject changes the description and media of one of the caller's
+// projects
+// @Summary Update developer project
+// @Description Change the description, image or project URL of a project linked to the calling developer's account. Empty values leave their fields unchanged; everything else is edited by admins. The change is recorded as a revision with the caller, a field-level diff and the optional reason.
+// @Tags developer
+// @Accept json
+// @Produce json
+// @Security DeveloperAuth
+// @Param id path int true "Project ID"
+// @Param request body models.DeveloperUpdateRequest true "Description and media"
+// @Success 200 {object} models.Project "Project after the update"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 403 {object} problem.Problem "Caller is not a developer"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to update project"
+// @Router /api/v1/projects/developer/{id} [put]
+func (h *DeveloperHandler) UpdateProject(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	var req models.DeveloperUpdateRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	change := models.Change{Actor: actor, Reason: strings.TrimSpace(req.Reason)}
+	if err := h.repo.Update(uint(id), req.ProjectUpdate(), change); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		return problem.Internal("Failed to update project", err)
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(id))
+
+	project, err := h.repo.GetByID(uint(id))
+	if err != nil {
+		return problem.Internal("Failed to retrieve project", err)
+	}
+	return c.JSON(http.StatusOK, project)
+}
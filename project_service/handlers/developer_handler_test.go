@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"project_service/models"
+	"project_service/repositories"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// asDeveloper runs setup and authenticates the request as the developer
+// with the user ID
+func asDeveloper(userID uint, setup func(c echo.Context)) func(c echo.Context) {
+	return func(c echo.Context) {
+		if setup != nil {
+			setup(c)
+		}
+		c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{UserID: userID, Role: models.RoleDeveloper}))
+	}
+}
+
+func newTestDeveloperHandler(t *testing.T) (*DeveloperHandler, *repositories.InMemoryProjectRepository) {
+	t.Helper()
+	_, repo := newTestHandler()
+	seedProjects(t, repo)
+	return NewDeveloperHandler(repo), repo
+}
+
+// reached is a handler that answers 204, to tell a middleware let the
+// request through
+func reached(c echo.Context) error {
+	return c.NoContent(http.StatusNoContent)
+}
+
+func TestRequireDeveloper(t *testing.T) {
+	h, _ := newTestDeveloperHandler(t)
+
+	if rec := serve(t, h.RequireDeveloper(reached), http.MethodGet, "/", "", asDeveloper(7, nil)); rec.Code != http.StatusNoContent {
+		t.Errorf("expected a developer let through, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(t, h.RequireDeveloper(reached), http.MethodGet, "/", "", asAdmin(1, nil)); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an admin token, got %d", rec.Code)
+	}
+	if rec := serve(t, h.RequireDeveloper(reached), http.MethodGet, "/", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+}
+
+func TestRequireOwner(t *testing.T) {
+	h, repo := newTestDeveloperHandler(t)
+	developer := uint(7)
+	if err := repo.Update(2, &models.UpdateProjectRequest{DeveloperID: &developer}, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		userID uint
+		id     string
+		status int
+	}{
+		{"owner", 7, "2", http.StatusNoContent},
+		{"another developer", 8, "2", http.StatusNotFound},
+		{"unowned project", 7, "1", http.StatusNotFound},
+		{"missing project", 7, "99", http.StatusNotFound},
+		{"invalid ID", 7, "abc", http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h.RequireOwner(reached), http.MethodGet, "/", "", asDeveloper(tt.userID, withID(tt.id)))
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeveloperCreateAndListProjects(t *testing.T) {
+	h, _ := newTestDeveloperHandler(t)
+
+	// The caller owns the draft whatever developer_id says
+	body := `{"title":"Peatland Rewetting","description":"Blocking drainage ditches","category":"wetlands",
+		"region":"Europe","country":"Ireland","verification_standard":"VCS","price_per_tonne":20,"developer_id":8}`
+	rec := serve(t, h.CreateProject, http.MethodPost, "/", body, asDeveloper(7, nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var project models.Project
+	decode(t, rec, &project)
+	if project.Status != models.StatusDraft || project.DeveloperID == nil || *project.DeveloperID != 7 {
+		t.Fatalf("expected a draft owned by developer 7, got %+v", project)
+	}
+	if rec := serve(t, h.CreateProject, http.MethodPost, "/", `{"title":"Incomplete"}`, asDeveloper(7, nil)); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an incomplete project, got %d", rec.Code)
+	}
+
+	var listed struct {
+		Projects []models.Project `json:"projects"`
+		Total    int64            `json:"total"`
+	}
+	decode(t, serve(t, h.ListProjects, http.MethodGet, "/", "", asDeveloper(7, nil)), &listed)
+	if listed.Total != 1 || listed.Projects[0].ID != project.ID {
+		t.Errorf("expected only the developer's draft, got %+v", listed)
+	}
+	decode(t, serve(t, h.ListProjects, http.MethodGet, "/", "", asDeveloper(8, nil)), &listed)
+	if listed.Total != 0 || len(listed.Projects) != 0 {
+		t.Errorf("expected no projects for another developer, got %+v", listed)
+	}
+}
+
+func TestDeveloperUpdateProject(t *testing.T) {
+	h, repo := newTestDeveloperHandler(t)
+
+	body := `{"description":"Restoring 400 hectares","image_url":"https://example.com/forest.jpg","title":"Renamed","price_per_tonne":1,"reason":"New survey"}`
+	rec := serve(t, h.UpdateProject, http.MethodPut, "/", body, asDeveloper(7, withID("1")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var project models.Project
+	decode(t, rec, &project)
+	if project.Description != "Restoring 400 hectares" || project.ImageURL != "https://example.com/forest.jpg" {
+		t.Errorf("expected the description and image updated, got %+v", project)
+	}
+	if project.Title != "Amazon Rainforest Protection" || project.PricePerTonne != 12 {
+		t.Errorf("expected the other fields unchanged, got %+v", project)
+	}
+
+	revisions, _ := repo.Revisions(1, 1, 0)
+	if len(revisions) != 1 || revisions[0].ActorRole != models.RoleDeveloper || revisions[0].Reason != "New survey" {
+		t.Errorf("expected the update recorded as the developer's, got %+v", revisions)
+	}
+
+	if rec := serve(t, h.UpdateProject, http.MethodPut, "/", `{"image_url":"not a url"}`, asDeveloper(7, withID("1"))); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an invalid image URL, got %d", rec.Code)
+	}
+}
+
+func TestDeveloperTransitions(t *testing.T) {
+	projects, repo := newTestHandler()
+	project := &models.Project{Title: "Peatland Rewetting", Status: models.StatusDraft}
+	if err := repo.Create(project, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	path := withID("1")
+
+	if rec := serve(t, projects.TransitionProject, http.MethodPost, "/", `{"action":"submit"}`, asDeveloper(7, path)); rec.Code != http.StatusOK {
+		t.Fatalf("expected the developer to submit, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(t, projects.TransitionProject, http.MethodPost, "/", `{"action":"approve"}`, asDeveloper(7, path)); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for a developer approving, got %d", rec.Code)
+	}
+	if rec := serve(t, projects.TransitionProject, http.MethodPost, "/", `{"action":"reject","reason":"Missing baseline"}`, asAdmin(1, path)); rec.Code != http.StatusOK {
+		t.Fatalf("expected the admin to reject, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(t, projects.TransitionProject, http.MethodPost, "/", `{"action":"revise"}`, asDeveloper(7, path)); rec.Code != http.StatusOK {
+		t.Errorf("expected the developer to revise, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"project_service/models"
+	"project_service/repositories"
+	"project_service/storage"
+	"shared/problem"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Problem codes of signed download URLs
+const (
+	CodeDownloadURLExpired = "download_url_expired"
+	CodeDownloadURLInvalid = "download_url_invalid"
+)
+
+// multipartMemory is how much of a multipart form is held in memory before
+// the rest is spooled to disk
+const multipartMemory = 8 << 20
+
+type DocumentHandler struct {
+	repo    repositories.DocumentRepository
+	store   storage.Store
+	signer  *storage.URLSigner
+	maxSize int64
+	baseURL string
+}
+
+func NewDocumentHandler(repo repositories.DocumentRepository, store storage.Store, signer *storage.URLSigner, maxSize int64) *DocumentHandler {
+	return &DocumentHandler{repo: repo, store: store, signer: signer, maxSize: maxSize}
+}
+
+// SetBaseURL prefixes signed download links, for clients that reach the
+// service through another host
+func (h *DocumentHandler) SetBaseURL(baseURL string) {
+	h.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// UploadDocument attaches a file to a project
+// @Summary Upload project document
+// @Description Upload a project design document, validation, verification or monitoring report (PDF) or a gallery image (JPEG, PNG or WebP) as multipart form data (admin only; a project's developer uploads images and monitoring reports through /api/v1/projects/developer/{id}/documents). The content type is detected from the file itself. The file's SHA-256 checksum is recorded, and checked against checksum_sha256 when one is sent. The response carries a signed download URL.
+// @Tags documents
+// @Accept multipart/form-data
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param file formData file true "File to upload"
+// @Param kind formData string true "Document kind" Enums(project_design, validation_report, verification_report, monitoring_report, image)
+// @Param title formData string false "Title"
+// @Param checksum_sha256 formData string false "Expected hex SHA-256 of the file"
+// @Success 201 {object} models.Document "Document uploaded"
+// @Failure 400 {object} problem.Problem "Invalid project ID or form"
+// @Failure 403 {object} problem.Problem "Caller may not upload the kind"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 413 {object} problem.Problem "File too large"
+// @Failure 415 {object} problem.Problem "Content type not accepted for the kind"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to store document"
+// @Router /api/v1/projects/admin/{id}/documents [post]
+func (h *DocumentHandler) UploadDocument(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	// Leave room for the form fields around the file
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, h.maxSize+1<<20)
+	if err := req.ParseMultipartForm(multipartMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return h.tooLarge()
+		}
+		return problem.BadRequest("Invalid multipart form")
+	}
+	defer req.MultipartForm.RemoveAll()
+
+	form := models.UploadDocumentRequest{Kind: req.FormValue("kind"), Title: req.FormValue("title")}
+	if err := validateRequest(&form); err != nil {
+		return err
+	}
+	if actor.Role == models.RoleDeveloper && !slices.Contains(models.DeveloperDocuments, form.Kind) {
+		return problem.Forbidden("Developers may only upload " + strings.Join(models.DeveloperDocuments, " and ") + " documents")
+	}
+	checksum := strings.ToLower(strings.TrimSpace(req.FormValue("checksum_sha256")))
+	if checksum != "" {
+		if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+			return problem.Validation(problem.FieldError{Field: "checksum_sha256", Message: "must be a hex SHA-256"})
+		}
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return problem.Validation(problem.FieldError{Field: "file", Message: "is required"})
+	}
+	if header.Size > h.maxSize {
+		return h.tooLarge()
+	}
+	if header.Size == 0 {
+		return problem.Validation(problem.FieldError{Field: "file", Message: "is empty"})
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return problem.Internal("Failed to read upload", err)
+	}
+	defer file.Close()
+
+	// Trust the file's own bytes over the declared content type
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	contentType := http.DetectContentType(sniff[:n])
+	if !accepts(form.Kind, contentType) {
+		return problem.New(http.StatusUnsupportedMediaType, fmt.Sprintf("A %s must be %s, not %s",
+			form.Kind, strings.Join(models.DocumentContentTypes[form.Kind], " or "), contentType))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return problem.Internal("Failed to read upload", err)
+	}
+
+	key, err := storageKey(projectID)
+	if err != nil {
+		return problem.Internal("Failed to store document", err)
+	}
+	hash := sha256.New()
+	ctx := req.Context()
+	if err := h.store.Put(ctx, key, io.TeeReader(file, hash), header.Size, contentType); err != nil {
+		return problem.Internal("Failed to store document", err)
+	}
+
+	document := &models.Document{
+		Kind:        form.Kind,
+		Title:       form.Title,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        header.Size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  key,
+		UploadedBy:  actor.ID,
+	}
+	if checksum != "" && checksum != document.Checksum {
+		h.discard(c, key)
+		return problem.Validation(problem.FieldError{Field: "checksum_sha256", Message: "does not match the uploaded file"})
+	}
+
+	if err := h.repo.Create(uint(projectID), document); err != nil {
+		h.discard(c, key)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		return problem.Internal("Failed to store document", err)
+	}
+
+	h.sign(document)
+	return c.JSON(http.StatusCreated, document)
+}
+
+// ListDocuments returns a project's documents
+// @Summary List project documents
+// @Description List the documents and images attached to a project, oldest first, each with a signed download URL that expires. Unreleased projects are not found unless the caller sends the token of an admin or the project's developer.
+// @Tags documents
+// @Accept json
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param kind query string false "Only documents of this kind" Enums(project_design, validation_report, verification_report, monitoring_report, image)
+// @Success 200 {object} map[string]interface{} "Documents of the project"
+// @Failure 400 {object} problem.Problem "Invalid project ID or kind"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve documents"
+// @Router /api/v1/projects/{id}/documents [get]
+func (h *DocumentHandler) ListDocuments(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+	kind := c.QueryParam("kind")
+	if _, ok := models.DocumentContentTypes[kind]; kind != "" && !ok {
+		return problem.BadRequest("Invalid document kind")
+	}
+
+	documents, err := h.repo.List(uint(projectID), kind)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		return problem.Internal("Failed to retrieve documents", err)
+	}
+	for i := range documents {
+		h.sign(&documents[i])
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"documents": documents,
+		"count":     len(documents),
+	})
+}
+
+// DownloadDocument serves a document's file through a signed URL
+// @Summary Download project document
+// @Description Download a document's file. The expires and signature come from the document's signed URL; an expired or altered URL is refused. A valid signed URL needs no token, even for an unreleased project, since only an admin or the project's developer can list it.
+// @Tags documents
+// @Produce octet-stream
+// @Param id path int true "Project ID"
+// @Param documentID path int true "Document ID"
+// @Param expires query int true "Expiry of the signed URL, in Unix seconds"
+// @Param signature query string true "Signature of the URL"
+// @Success 200 {file} file "The document"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 403 {object} problem.Problem "Signed URL expired or invalid"
+// @Failure 404 {object} problem.Problem "Document not found"
+// @Failure 500 {object} problem.Problem "Failed to read document"
+// @Router /api/v1/projects/{id}/documents/{documentID}/download [get]
+func (h *DocumentHandler) DownloadDocument(c echo.Context) error {
+	projectID, documentID, err := documentIDs(c)
+	if err != nil {
+		return err
+	}
+
+	err = h.signer.Verify(downloadPath(projectID, documentID), c.QueryParam("expires"), c.QueryParam("signature"))
+	switch {
+	case errors.Is(err, storage.ErrURLExpired):
+		return problem.Forbidden("The download URL has expired").WithCode(CodeDownloadURLExpired)
+	case err != nil:
+		return problem.Forbidden("The download URL is invalid").WithCode(CodeDownloadURLInvalid)
+	}
+
+	document, err := h.repo.Get(projectID, documentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Document not found")
+		}
+		return problem.Internal("Failed to read document", err)
+	}
+	blob, err := h.store.Get(c.Request().Context(), document.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return problem.NotFound("Document not found")
+		}
+		return problem.Internal("Failed to read document", err)
+	}
+	defer blob.Close()
+
+	disposition := "attachment"
+	if document.Kind == models.DocumentImage {
+		disposition = "inline"
+	}
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType(disposition, map[string]string{"filename": document.Filename}))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(document.Size, 10))
+	header.Set("ETag", `"`+document.Checksum+`"`)
+	header.Set("Cache-Control", "private")
+	return c.Stream(http.StatusOK, document.ContentType, blob)
+}
+
+// DeleteDocument removes a document and its file
+// @Summary Delete project document
+// @Description Remove a document from a project and delete its file (admin only)
+// @Tags documents
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param documentID path int true "Document ID"
+// @Success 200 {object} map[string]string "Document deleted successfully"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 404 {object} problem.Problem "Document not found"
+// @Failure 500 {object} problem.Problem "Failed to delete document"
+// @Router /api/v1/projects/admin/{id}/documents/{documentID} [delete]
+func (h *DocumentHandler) DeleteDocument(c echo.Context) error {
+	projectID, documentID, err := documentIDs(c)
+	if err != nil {
+		return err
+	}
+
+	document, err := h.repo.Delete(projectID, documentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Document not found")
+		}
+		return problem.Internal("Failed to delete document", err)
+	}
+	h.discard(c, document.StorageKey)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Document deleted successfully"})
+}
+
+// sign sets a document's signed download URL
+func (h *DocumentHandler) sign(document *models.Document) {
+	path, expires := h.signer.Sign(downloadPath(document.ProjectID, document.ID))
+	document.URL = h.baseURL + path
+	document.URLExpiresAt = &expires
+}
+
+// discard deletes a file whose record is gone or was never written. A
+// failure only leaves an orphaned blob, so it is logged.
+func (h *DocumentHandler) discard(c echo.Context, key string) {
+	if err := h.store.Delete(c.Request().Context(), key); err != nil {
+		log.Printf("Warning: failed to delete document file %s: %v", key, err)
+	}
+}
+
+func (h *DocumentHandler) tooLarge() error {
+	return problem.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("Files may be at most %d bytes", h.maxSize))
+}
+
+// documentIDs parses the project and document IDs of a document route
+func documentIDs(c echo.Context) (projectID, documentID uint, err error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, 0, problem.BadRequest("Invalid project ID")
+	}
+	did, err := strconv.ParseUint(c.Param("documentID"), 10, 32)
+	if err != nil {
+		return 0, 0, problem.BadRequest("Invalid document ID")
+	}
+	return uint(id), uint(did), nil
+}
+
+// accepts reports whether a kind of document may have the content type
+func accepts(kind, contentType string) bool {
+	for _, accepted := range models.DocumentContentTypes[kind] {
+		if accepted == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func downloadPath(projectID, documentID uint) string {
+	return fmt.Sprintf("/api/v1/projects/%d/documents/%d/download", projectID, documentID)
+}
+
+// storageKey returns a new random key for a project's file, so names
+// chosen by uploaders never reach the store
+func storageKey(projectID uint64) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("projects/%d/%s", projectID, hex.EncodeToString(random)), nil
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"project_service/models"
+	"project_service/repositories"
+	"project_service/storage"
+	"shared/problem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	pdfFile = []byte("%PDF-1.7\n1 0 obj << /Type /Catalog >> endobj\n")
+	pngFile = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+)
+
+func newTestDocumentHandler(t *testing.T) *DocumentHandler {
+	t.Helper()
+	_, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewDocumentHandler(repositories.NewInMemoryDocumentRepository(repo), store,
+		storage.NewURLSigner("document-signing-secret", time.Minute), 1024)
+}
+
+func withDocument(id, documentID string) func(c echo.Context) {
+	return func(c echo.Context) {
+		c.SetParamNames("id", "documentID")
+		c.SetParamValues(id, documentID)
+	}
+}
+
+// upload posts a file and form fields as the admin with ID 1
+func upload(t *testing.T, h *DocumentHandler, projectID, filename string, content []byte, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	return uploadAs(t, h, asAdmin(1, withID(projectID)), filename, content, fields)
+}
+
+// uploadAs posts a file and form fields, authenticated by setup
+func uploadAs(t *testing.T, h *DocumentHandler, setup func(c echo.Context), filename string, content []byte, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if content != nil {
+		part, _ := form.CreateFormFile("file", filename)
+		part.Write(content)
+	}
+	form.Close()
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	setup(c)
+
+	if err := h.UploadDocument(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+// download follows a signed URL
+func download(t *testing.T, h *DocumentHandler, signedURL string) *httptest.ResponseRecorder {
+	t.Helper()
+	path, _, _ := strings.Cut(signedURL, "?")
+	parts := strings.Split(path, "/")
+	return serve(t, h.DownloadDocument, http.MethodGet, signedURL, "", withDocument(parts[4], parts[6]))
+}
+
+func TestUploadAndDownloadDocument(t *testing.T) {
+	h := newTestDocumentHandler(t)
+	h.SetBaseURL("https://api.example.com/")
+
+	sum := sha256.Sum256(pdfFile)
+	checksum := hex.EncodeToString(sum[:])
+	rec := upload(t, h, "1", "design.pdf", pdfFile, map[string]string{
+		"kind": models.DocumentDesign, "title": "Project design", "checksum_sha256": checksum,
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var document models.Document
+	decode(t, rec, &document)
+	if document.Checksum != checksum || document.Size != int64(len(pdfFile)) || document.ContentType != "application/pdf" ||
+		document.UploadedBy != 1 || document.URLExpiresAt == nil {
+		t.Errorf("unexpected document: %+v", document)
+	}
+	if !strings.HasPrefix(document.URL, "https://api.example.com/api/v1/projects/1/documents/1/download?") {
+		t.Fatalf("expected a signed URL on the base, got %q", document.URL)
+	}
+
+	rec = download(t, h, strings.TrimPrefix(document.URL, "https://api.example.com"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !bytes.Equal(rec.Body.Bytes(), pdfFile) || rec.Header().Get("ETag") != `"`+checksum+`"` ||
+		rec.Header().Get(echo.HeaderContentDisposition) != "attachment; filename=design.pdf" {
+		t.Errorf("unexpected download: %v %q", rec.Header(), rec.Body.String())
+	}
+
+	// A signature is bound to its document
+	tampered := strings.Replace(strings.TrimPrefix(document.URL, "https://api.example.com"), "/documents/1/", "/documents/2/", 1)
+	var p problem.Problem
+	rec = download(t, h, tampered)
+	decode(t, rec, &p)
+	if rec.Code != http.StatusForbidden || p.Code != CodeDownloadURLInvalid {
+		t.Errorf("expected 403 %s, got %d %q", CodeDownloadURLInvalid, rec.Code, p.Code)
+	}
+}
+
+func TestUploadDocumentValidation(t *testing.T) {
+	h := newTestDocumentHandler(t)
+
+	for _, tt := range []struct {
+		name    string
+		project string
+		content []byte
+		fields  map[string]string
+		status  int
+	}{
+		{"missing kind", "1", pdfFile, nil, http.StatusUnprocessableEntity},
+		{"unknown kind", "1", pdfFile, map[string]string{"kind": "brochure"}, http.StatusUnprocessableEntity},
+		{"missing file", "1", nil, map[string]string{"kind": models.DocumentDesign}, http.StatusUnprocessableEntity},
+		{"image as a report", "1", pngFile, map[string]string{"kind": models.DocumentValidation}, http.StatusUnsupportedMediaType},
+		{"text as an image", "1", []byte("just some text"), map[string]string{"kind": models.DocumentImage}, http.StatusUnsupportedMediaType},
+		{"too large", "1", bytes.Repeat([]byte("%PDF"), 300), map[string]string{"kind": models.DocumentDesign}, http.StatusRequestEntityTooLarge},
+		{"wrong checksum", "1", pdfFile, map[string]string{"kind": models.DocumentDesign, "checksum_sha256": strings.Repeat("0", 64)}, http.StatusUnprocessableEntity},
+		{"missing project", "9", pdfFile, map[string]string{"kind": models.DocumentDesign}, http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := upload(t, h, tt.project, "file", tt.content, tt.fields); rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// Refused uploads leave nothing behind
+	var listed struct {
+		Count int `json:"count"`
+	}
+	decode(t, serve(t, h.ListDocuments, http.MethodGet, "/", "", withID("1")), &listed)
+	if listed.Count != 0 {
+		t.Errorf("expected no documents, got %d", listed.Count)
+	}
+}
+
+func TestDeveloperUploadKinds(t *testing.T) {
+	h := newTestDocumentHandler(t)
+
+	for _, tt := range []struct {
+		kind    string
+		content []byte
+		status  int
+	}{
+		{models.DocumentMonitoring, pdfFile, http.StatusCreated},
+		{models.DocumentImage, pngFile, http.StatusCreated},
+		{models.DocumentVerification, pdfFile, http.StatusForbidden},
+		{models.DocumentDesign, pdfFile, http.StatusForbidden},
+	} {
+		t.Run(tt.kind, func(t *testing.T) {
+			rec := uploadAs(t, h, asDeveloper(7, withID("1")), "file", tt.content, map[string]string{"kind": tt.kind})
+			if rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	// Admins upload every kind, monitoring reports included
+	if rec := upload(t, h, "1", "report.pdf", pdfFile, map[string]string{"kind": models.DocumentMonitoring}); rec.Code != http.StatusCreated {
+		t.Errorf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestListAndDeleteDocuments(t *testing.T) {
+	h := newTestDocumentHandler(t)
+	upload(t, h, "1", "design.pdf", pdfFile, map[string]string{"kind": models.DocumentDesign})
+	upload(t, h, "1", "site.png", pngFile, map[string]string{"kind": models.DocumentImage})
+	upload(t, h, "2", "report.pdf", pdfFile, map[string]string{"kind": models.DocumentValidation})
+
+	var listed struct {
+		Documents []models.Document `json:"documents"`
+		Count     int               `json:"count"`
+	}
+	decode(t, serve(t, h.ListDocuments, http.MethodGet, "/?kind=image", "", withID("1")), &listed)
+	if listed.Count != 1 || listed.Documents[0].Filename != "site.png" || listed.Documents[0].URL == "" {
+		t.Fatalf("expected the project's image with a URL, got %+v", listed.Documents)
+	}
+	rec := download(t, h, listed.Documents[0].URL)
+	if rec.Code != http.StatusOK || rec.Header().Get(echo.HeaderContentType) != "image/png" ||
+		!strings.HasPrefix(rec.Header().Get(echo.HeaderContentDisposition), "inline") {
+		t.Errorf("expected the image served inline, got %d %v", rec.Code, rec.Header())
+	}
+
+	if rec := serve(t, h.ListDocuments, http.MethodGet, "/?kind=brochure", "", withID("1")); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown kind, got %d", rec.Code)
+	}
+	if rec := serve(t, h.DeleteDocument, http.MethodDelete, "/", "", withDocument("2", "1")); rec.Code != http.StatusNotFound {
+		t.Errorf("expected another project's document not found, got %d", rec.Code)
+	}
+	if rec := serve(t, h.DeleteDocument, http.MethodDelete, "/", "", withDocument("1", "2")); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if rec := download(t, h, listed.Documents[0].URL); rec.Code != http.StatusNotFound {
+		t.Errorf("expected a deleted document's URL to stop working, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CodePeriodOverlap is the problem code for a monitoring period sharing
+// time with one the project already has
+const CodePeriodOverlap = "period_overlap"
+
+// MonitoringHandler serves the monitoring, reporting and verification (MRV)
+// history of projects: the verified monitoring periods admins and
+// developers submit, and the chart of them buyers see. The chart is
+// computed on each request, since sales and serial allocations move it
+// too.
+type MonitoringHandler struct {
+	repo repositories.MonitoringRepository
+}
+
+func NewMonitoringHandler(repo repositories.MonitoringRepository) *MonitoringHandler {
+	return &MonitoringHandler{repo: repo}
+}
+
+// SubmitPeriod adds a verified monitoring period to a project
+// @Summary Submit monitoring period
+// @Description Record the tonnes a project measured over a period, the verifier and verification date, and optionally the monitoring report document covering it (admin, or the project's developer through /api/v1/projects/developer/{id}/monitoring). The period ends after it starts, is verified no earlier than its end and may not overlap another period of the project.
+// @Tags monitoring
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param request body models.CreateMonitoringPeriodRequest true "Monitoring period"
+// @Success 201 {object} models.MonitoringPeriod "Monitoring period submitted"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 409 {object} problem.Problem "The period overlaps one already submitted"
+// @Failure 422 {object} problem.Problem "Validation failed or the report is not a monitoring report of the project"
+// @Failure 500 {object} problem.Problem "Failed to submit monitoring period"
+// @Router /api/v1/projects/admin/{id}/monitoring [post]
+func (h *MonitoringHandler) SubmitPeriod(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	var req models.CreateMonitoringPeriodRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	period := &models.MonitoringPeriod{
+		PeriodStart:    req.PeriodStart,
+		PeriodEnd:      req.PeriodEnd,
+		MeasuredTonnes: req.MeasuredTonnes,
+		Verifier:       strings.TrimSpace(req.Verifier),
+		VerifiedAt:     req.VerifiedAt,
+		ReportID:       req.ReportID,
+		SubmittedBy:    actor.ID,
+		SubmitterRole:  actor.Role,
+	}
+	if err := h.repo.Create(uint(projectID), period); err != nil {
+		return monitoringProblem(err, "Failed to submit monitoring period")
+	}
+	return c.JSON(http.StatusCreated, period)
+}
+
+// ListPeriods lists the monitoring periods of a project
+// @Summary List monitoring periods
+// @Description List a project's monitoring periods in any status of the project, earliest first, with who submitted each (admin, or the project's developer through /api/v1/projects/developer/{id}/monitoring)
+// @Tags monitoring
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Success 200 {object} map[string]interface{} "Monitoring periods"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve monitoring periods"
+// @Router /api/v1/projects/admin/{id}/monitoring [get]
+func (h *MonitoringHandler) ListPeriods(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	periods, err := h.repo.List(uint(projectID))
+	if err != nil {
+		return monitoringProblem(err, "Failed to retrieve monitoring periods")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"periods": periods})
+}
+
+// GetMRVSeries returns the chart data of a project's MRV history
+// @Summary Get project MRV chart
+// @Description Chart what a project has delivered: a point per verified monitoring period in order of verification date, with the tonnes measured and the cumulative tonnes issued, sold and retired by that date, and the totals to date. Issued tonnes are the verified measurements, sold tonnes the committed reservations and retired tonnes the serials allocated to orders.
+// @Tags monitoring
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} models.MRVSeries "MRV chart data"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve MRV series"
+// @Router /api/v1/projects/{id}/mrv [get]
+func (h *MonitoringHandler) GetMRVSeries(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	series, err := h.repo.Series(uint(projectID))
+	if err != nil {
+		return monitoringProblem(err, "Failed to retrieve MRV series")
+	}
+	return c.JSON(http.StatusOK, series)
+}
+
+func monitoringProblem(err error, detail string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.NotFound("Project not found")
+	case errors.Is(err, repositories.ErrMonitoringReport):
+		return problem.Validation(problem.FieldError{Field: "report_id", Message: "must be a monitoring report of the project"})
+	case errors.Is(err, repositories.ErrPeriodOverlap):
+		return problem.Conflict("The period overlaps a monitoring period already submitted").WithCode(CodePeriodOverlap)
+	default:
+		return problem.Internal(detail, err)
+	}
+}
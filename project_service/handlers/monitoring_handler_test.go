@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"testing"
+	"time"
+)
+
+// newTestMonitoringHandler returns a monitoring handler over the seeded
+// projects and the reservations it charts sales from
+func newTestMonitoringHandler(t *testing.T) (*MonitoringHandler, *repositories.InMemoryProjectRepository, *repositories.InMemoryReservationRepository) {
+	t.Helper()
+	_, repo := newTestHandler()
+	seedProjects(t, repo)
+	reservations := repositories.NewInMemoryReservationRepository(repo)
+	monitoring := repositories.NewInMemoryMonitoringRepository(repo, repositories.NewInMemoryDocumentRepository(repo),
+		reservations, repositories.NewInMemorySerialRepository(repo))
+	return NewMonitoringHandler(monitoring), repo, reservations
+}
+
+const firstPeriod = `{"period_start":"2025-01-01T00:00:00Z","period_end":"2026-01-01T00:00:00Z",
+	"measured_tonnes":120,"verifier":"SCS Global","verification_date":"2026-03-01T00:00:00Z"}`
+
+func TestSubmitMonitoringPeriod(t *testing.T) {
+	h, _, _ := newTestMonitoringHandler(t)
+
+	rec := serve(t, h.SubmitPeriod, http.MethodPost, "/", firstPeriod, asDeveloper(7, withID("1")))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var period models.MonitoringPeriod
+	decode(t, rec, &period)
+	if period.ProjectID != 1 || period.MeasuredTonnes != 120 || period.SubmittedBy != 7 || period.SubmitterRole != models.RoleDeveloper {
+		t.Errorf("unexpected period: %+v", period)
+	}
+
+	var p problem.Problem
+	rec = serve(t, h.SubmitPeriod, http.MethodPost, "/", `{"period_start":"2025-06-01T00:00:00Z","period_end":"2026-06-01T00:00:00Z",
+		"measured_tonnes":10,"verifier":"SCS Global","verification_date":"2026-07-01T00:00:00Z"}`, asAdmin(1, withID("1")))
+	decode(t, rec, &p)
+	if rec.Code != http.StatusConflict || p.Code != CodePeriodOverlap {
+		t.Errorf("expected 409 %s, got %d %q", CodePeriodOverlap, rec.Code, p.Code)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		project string
+		body    string
+		status  int
+	}{
+		{"ends before it starts", "1", `{"period_start":"2026-01-01T00:00:00Z","period_end":"2025-01-01T00:00:00Z",
+			"measured_tonnes":10,"verifier":"SCS Global","verification_date":"2026-03-01T00:00:00Z"}`, http.StatusUnprocessableEntity},
+		{"verified before it ends", "1", `{"period_start":"2026-01-01T00:00:00Z","period_end":"2026-07-01T00:00:00Z",
+			"measured_tonnes":10,"verifier":"SCS Global","verification_date":"2026-06-01T00:00:00Z"}`, http.StatusUnprocessableEntity},
+		{"no tonnes", "1", `{"period_start":"2026-01-01T00:00:00Z","period_end":"2026-07-01T00:00:00Z",
+			"verifier":"SCS Global","verification_date":"2026-08-01T00:00:00Z"}`, http.StatusUnprocessableEntity},
+		{"unknown report", "1", `{"period_start":"2026-01-01T00:00:00Z","period_end":"2026-07-01T00:00:00Z",
+			"measured_tonnes":10,"verifier":"SCS Global","verification_date":"2026-08-01T00:00:00Z","report_id":5}`, http.StatusUnprocessableEntity},
+		{"missing project", "99", firstPeriod, http.StatusNotFound},
+		{"invalid ID", "abc", firstPeriod, http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, h.SubmitPeriod, http.MethodPost, "/", tt.body, asAdmin(1, withID(tt.project))); rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	var listed struct {
+		Periods []models.MonitoringPeriod `json:"periods"`
+	}
+	decode(t, serve(t, h.ListPeriods, http.MethodGet, "/", "", withID("1")), &listed)
+	if len(listed.Periods) != 1 || listed.Periods[0].ID != period.ID {
+		t.Errorf("expected the submitted period listed, got %+v", listed.Periods)
+	}
+}
+
+func TestGetMRVSeries(t *testing.T) {
+	h, repo, reservations := newTestMonitoringHandler(t)
+	if rec := serve(t, h.SubmitPeriod, http.MethodPost, "/", firstPeriod, asAdmin(1, withID("1"))); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	reservation, err := reservations.Reserve(1, 0, 40, "order-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reservations.Commit(reservation.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := serve(t, h.GetMRVSeries, http.MethodGet, "/", "", withID("1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var series models.MRVSeries
+	decode(t, rec, &series)
+	if len(series.Points) != 1 || series.Points[0].CumulativeIssued != 120 || series.Points[0].CumulativeSold != 0 {
+		t.Errorf("expected the period charted before the sale, got %+v", series.Points)
+	}
+	if series.Issued != 120 || series.Sold != 40 || series.Retired != 0 {
+		t.Errorf("unexpected totals: %+v", series)
+	}
+
+	draft := &models.Project{Title: "Peatland Rewetting", Status: models.StatusDraft}
+	if err := repo.Create(draft, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serve(t, h.GetMRVSeries, http.MethodGet, "/", "", withID("4")); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a draft, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CodeScheduleNotPending is the problem code for cancelling a price
+// schedule that has already taken effect
+const CodeScheduleNotPending = "schedule_not_pending"
+
+type PricingHandler struct {
+	repo  repositories.PricingRepository
+	cache *cache.Cache
+}
+
+func NewPricingHandler(repo repositories.PricingRepository) *PricingHandler {
+	return &PricingHandler{repo: repo}
+}
+
+// SetCache caches project pricing and invalidates the project's cached
+// responses when its prices change
+func (h *PricingHandler) SetCache(responses *cache.Cache) {
+	h.cache = responses
+}
+
+// GetPricing returns a project's prices
+// @Summary Get project pricing
+// @Description Get a project's base price per tonne, its volume tiers and the price changes scheduled for it. Unreleased projects are not found unless the caller sends the token of an admin or the project's developer.
+// @Tags pricing
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} models.ProjectPricing "Project pricing"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve pricing"
+// @Router /api/v1/projects/{id}/pricing [get]
+func (h *PricingHandler) GetPricing(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	return cached(c, h.cache, projectNamespace(projectID), "pricing", projectTTL, func() (interface{}, error) {
+		pricing, err := h.repo.Pricing(uint(projectID))
+		if err != nil {
+			return nil, pricingProblem(err, "Failed to retrieve pricing")
+		}
+		return pricing, nil
+	})
+}
+
+// GetQuote prices a tonnage of a project
+// @Summary Get price quote
+// @Description Get the exact price of a tonnage of a project at a moment, now unless given. The price per tonne is the highest volume tier the tonnage reaches, or the base price below every tier. Future moments take scheduled price changes into account; past moments use the price history. Unreleased projects are not found unless the caller sends the token of an admin or the project's developer.
+// @Tags pricing
+// @Produce json
+// @Param id path int true "Project ID"
+// @Param tonnes query number true "Tonnes to price"
+// @Param at query string false "Moment to price at (RFC 3339)"
+// @Success 200 {object} models.Quote "Quote"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 422 {object} problem.Problem "Invalid tonnes or moment"
+// @Failure 500 {object} problem.Problem "Failed to quote"
+// @Router /api/v1/projects/{id}/quote [get]
+func (h *PricingHandler) GetQuote(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	tonnes, err := strconv.ParseFloat(c.QueryParam("tonnes"), 64)
+	if err != nil || tonnes <= 0 {
+		return problem.Validation(problem.FieldError{Field: "tonnes", Message: "must be a number greater than 0"})
+	}
+	at := time.Now()
+	if value := c.QueryParam("at"); value != "" {
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			return problem.Validation(problem.FieldError{Field: "at", Message: "must be an RFC 3339 timestamp"})
+		}
+	}
+
+	quote, err := h.repo.Quote(uint(projectID), tonnes, at)
+	if err != nil {
+		return pricingProblem(err, "Failed to quote")
+	}
+	return c.JSON(http.StatusOK, quote)
+}
+
+// SetPricing changes a project's tiers and base price
+// @Summary Set project pricing
+// @Description Replace a project's volume tiers, and its base price when given, straight away. The change is recorded in the price history.
+// @Tags pricing
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param request body models.SetPricingRequest true "Base price and tiers"
+// @Success 200 {object} models.ProjectPricing "Project pricing"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to set pricing"
+// @Router /api/v1/projects/admin/{id}/pricing [put]
+func (h *PricingHandler) SetPricing(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	var req models.SetPricingRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateTiers(&req, req.Tiers); err != nil {
+		return err
+	}
+
+	pricing, err := h.repo.SetPricing(uint(projectID), req.PricePerTonne, req.Tiers)
+	if err != nil {
+		return pricingProblem(err, "Failed to set pricing")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(projectID))
+	return c.JSON(http.StatusOK, pricing)
+}
+
+// SchedulePrice schedules a price change
+// @Summary Schedule price change
+// @Description Schedule a new base price and volume tiers for a project, taking effect at effective_from. The service applies due schedules in the background.
+// @Tags pricing
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param request body models.CreatePriceScheduleRequest true "Scheduled prices"
+// @Success 201 {object} models.PriceSchedule "Price change scheduled"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to schedule price change"
+// @Router /api/v1/projects/admin/{id}/pricing/schedules [post]
+func (h *PricingHandler) SchedulePrice(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	var req models.CreatePriceScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateTiers(&req, req.Tiers); err != nil {
+		return err
+	}
+
+	schedule := &models.PriceSchedule{
+		PricePerTonne: req.PricePerTonne,
+		Tiers:         req.Tiers,
+		EffectiveFrom: req.EffectiveFrom,
+	}
+	if err := h.repo.Schedule(uint(projectID), schedule); err != nil {
+		return pricingProblem(err, "Failed to schedule price change")
+	}
+
+	// Quotes are not cached, but the pricing listing shows pending schedules
+	invalidate(c, h.cache, projectNamespace(projectID))
+	return c.JSON(http.StatusCreated, schedule)
+}
+
+// ListPriceSchedules lists a project's price schedules
+// @Summary List price schedules
+// @Description List every price change scheduled for a project, pending, applied or cancelled, by effective time
+// @Tags pricing
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Success 200 {object} map[string]interface{} "Price schedules"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve price schedules"
+// @Router /api/v1/projects/admin/{id}/pricing/schedules [get]
+func (h *PricingHandler) ListPriceSchedules(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	schedules, err := h.repo.Schedules(uint(projectID))
+	if err != nil {
+		return pricingProblem(err, "Failed to retrieve price schedules")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"schedules": schedules})
+}
+
+// CancelPriceSchedule cancels a pending price schedule
+// @Summary Cancel price schedule
+// @Description Cancel a price change that has not taken effect yet. Cancelling it again is a no-op.
+// @Tags pricing
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param scheduleID path int true "Schedule ID"
+// @Success 200 {object} models.PriceSchedule "Price schedule cancelled"
+// @Failure 400 {object} problem.Problem "Invalid project or schedule ID"
+// @Failure 404 {object} problem.Problem "Project or schedule not found"
+// @Failure 409 {object} problem.Problem "The schedule has already taken effect"
+// @Failure 500 {object} problem.Problem "Failed to cancel price schedule"
+// @Router /api/v1/projects/admin/{id}/pricing/schedules/{scheduleID} [delete]
+func (h *PricingHandler) CancelPriceSchedule(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+	scheduleID, err := strconv.ParseUint(c.Param("scheduleID"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid schedule ID")
+	}
+
+	schedule, err := h.repo.CancelSchedule(uint(projectID), uint(scheduleID))
+	if err != nil {
+		return pricingProblem(err, "Failed to cancel price schedule")
+	}
+
+	invalidate(c, h.cache, projectNamespace(projectID))
+	return c.JSON(http.StatusOK, schedule)
+}
+
+// GetPriceHistory lists a project's price changes
+// @Summary Get price history
+// @Description List every change to a project's base price and tiers, newest first, each from the moment it took effect
+// @Tags pricing
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param limit query int false "Limit number of results (max 100)" default(50)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} map[string]interface{} "Price changes"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve price history"
+// @Router /api/v1/projects/admin/{id}/pricing/history [get]
+func (h *PricingHandler) GetPriceHistory(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	changes, err := h.repo.History(uint(projectID), limit, offset)
+	if err != nil {
+		return pricingProblem(err, "Failed to retrieve price history")
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"changes": changes,
+		"limit":   limit,
+		"offset":  offset,
+		"count":   len(changes),
+	})
+}
+
+// ApplyDue applies the price schedules whose time has come, dropping the
+// cached responses of their projects, and returns how many it applied.
+// Schedules are counted even when a later one fails.
+func (h *PricingHandler) ApplyDue(ctx context.Context, now time.Time) (int, error) {
+	applied, err := h.repo.ApplyDue(now)
+	if len(applied) > 0 {
+		namespaces := []string{catalogNamespace}
+		for _, schedule := range applied {
+			namespaces = append(namespaces, projectNamespace(uint64(schedule.ProjectID)))
+		}
+		if err := h.cache.Invalidate(ctx, namespaces...); err != nil {
+			log.Printf("Warning: failed to invalidate cache %v: %v", namespaces, err)
+		}
+	}
+	return len(applied), err
+}
+
+// validateTiers validates a pricing request and sorts its tiers, which must
+// each start at a different tonnage
+func validateTiers(request interface{}, tiers []models.PriceTier) error {
+	if err := validateRequest(request); err != nil {
+		return err
+	}
+	if !models.SortTiers(tiers) {
+		return problem.Validation(problem.FieldError{Field: "tiers", Message: "must each have a different min_tonnes"})
+	}
+	return nil
+}
+
+// pricingProblem maps a pricing repository error to a problem
+func pricingProblem(err error, detail string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.NotFound("Project or price schedule not found")
+	case errors.Is(err, repositories.ErrScheduleInPast):
+		return problem.Validation(problem.FieldError{Field: "effective_from", Message: "must be in the future"})
+	case errors.Is(err, repositories.ErrScheduleNotPending):
+		return problem.Conflict("The price schedule has already taken effect").WithCode(CodeScheduleNotPending)
+	case errors.Is(err, repositories.ErrNoPriceAt):
+		return problem.Validation(problem.FieldError{Field: "at", Message: "is before the project's first recorded price"})
+	default:
+		return problem.Internal(detail, err)
+	}
+}
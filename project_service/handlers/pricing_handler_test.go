@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newTestPricingHandler(t *testing.T) (*PricingHandler, *ProjectHandler) {
+	t.Helper()
+	projects, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	responses := cache.New(cache.NewMemoryStore())
+	projects.SetCache(responses)
+	h := NewPricingHandler(repositories.NewInMemoryPricingRepository(repo))
+	h.SetCache(responses)
+	return h, projects
+}
+
+func withSchedule(id, scheduleID string) func(c echo.Context) {
+	return func(c echo.Context) {
+		c.SetParamNames("id", "scheduleID")
+		c.SetParamValues(id, scheduleID)
+	}
+}
+
+func quoteOf(t *testing.T, h *PricingHandler, query string) (*models.Quote, int) {
+	t.Helper()
+	rec := serve(t, h.GetQuote, http.MethodGet, "/?"+query, "", withID("1"))
+	if rec.Code != http.StatusOK {
+		return nil, rec.Code
+	}
+	var quote models.Quote
+	decode(t, rec, &quote)
+	return &quote, rec.Code
+}
+
+func TestSetPricing(t *testing.T) {
+	h, projects := newTestPricingHandler(t)
+	// Warm the cached project to check the new tiers invalidate it
+	serve(t, projects.GetProject, http.MethodGet, "/", "", withID("1"))
+
+	body := `{"tiers":[{"min_tonnes":1000,"price_per_tonne":10},{"min_tonnes":100,"price_per_tonne":11}]}`
+	rec := serve(t, h.SetPricing, http.MethodPut, "/", body, withID("1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var pricing models.ProjectPricing
+	decode(t, rec, &pricing)
+	if pricing.PricePerTonne != 12 || len(pricing.Tiers) != 2 || pricing.Tiers[0].MinTonnes != 100 {
+		t.Errorf("expected the base price kept and the tiers sorted, got %+v", pricing)
+	}
+
+	var project models.Project
+	rec = serve(t, projects.GetProject, http.MethodGet, "/", "", withID("1"))
+	decode(t, rec, &project)
+	if rec.Header().Get("X-Cache") != "MISS" || len(project.PriceTiers) != 2 {
+		t.Errorf("expected the project with its tiers after invalidation, got %+v from %s", project.PriceTiers, rec.Header().Get("X-Cache"))
+	}
+
+	for _, tt := range []struct {
+		query string
+		price float64
+		total float64
+	}{
+		{"tonnes=50", 12, 600},
+		{"tonnes=100", 11, 1100},
+		{"tonnes=2500", 10, 25000},
+	} {
+		quote, code := quoteOf(t, h, tt.query)
+		if code != http.StatusOK || quote.PricePerTonne != tt.price || quote.Total != tt.total {
+			t.Errorf("%s: expected %v per tonne, %v in total, got %d %+v", tt.query, tt.price, tt.total, code, quote)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"duplicate tiers", `{"tiers":[{"min_tonnes":100,"price_per_tonne":11},{"min_tonnes":100,"price_per_tonne":10}]}`, http.StatusUnprocessableEntity},
+		{"tier without price", `{"tiers":[{"min_tonnes":100}]}`, http.StatusUnprocessableEntity},
+		{"negative price", `{"price_per_tonne":-1}`, http.StatusUnprocessableEntity},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, h.SetPricing, http.MethodPut, "/", tt.body, withID("1")); rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if rec := serve(t, h.SetPricing, http.MethodPut, "/", `{"tiers":[]}`, withID("9")); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing project, got %d", rec.Code)
+	}
+}
+
+func TestPriceSchedules(t *testing.T) {
+	h, projects := newTestPricingHandler(t)
+
+	from := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+	body := `{"price_per_tonne":15,"effective_from":"` + from.Format(time.RFC3339) + `"}`
+	rec := serve(t, h.SchedulePrice, http.MethodPost, "/", body, withID("1"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var schedule models.PriceSchedule
+	decode(t, rec, &schedule)
+	if schedule.Status != models.SchedulePending || !schedule.EffectiveFrom.Equal(from) {
+		t.Errorf("expected a pending schedule from %v, got %+v", from, schedule)
+	}
+
+	past := `{"price_per_tonne":15,"effective_from":"2020-01-01T00:00:00Z"}`
+	if rec := serve(t, h.SchedulePrice, http.MethodPost, "/", past, withID("1")); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a schedule in the past, got %d", rec.Code)
+	}
+
+	var pricing models.ProjectPricing
+	decode(t, serve(t, h.GetPricing, http.MethodGet, "/", "", withID("1")), &pricing)
+	if len(pricing.Schedules) != 1 || pricing.PricePerTonne != 12 {
+		t.Errorf("expected the current price and one pending schedule, got %+v", pricing)
+	}
+
+	at := url.QueryEscape(from.Add(time.Hour).Format(time.RFC3339))
+	if quote, _ := quoteOf(t, h, "tonnes=10&at="+at); quote == nil || quote.PricePerTonne != 15 || quote.Total != 150 {
+		t.Errorf("expected the scheduled price in the future, got %+v", quote)
+	}
+
+	// Warm the cached project to check applying the schedule invalidates it
+	serve(t, projects.GetProject, http.MethodGet, "/", "", withID("1"))
+	applied, err := h.ApplyDue(context.Background(), from)
+	if err != nil || applied != 1 {
+		t.Fatalf("expected one schedule applied, got %d, %v", applied, err)
+	}
+	var project models.Project
+	rec = serve(t, projects.GetProject, http.MethodGet, "/", "", withID("1"))
+	decode(t, rec, &project)
+	if rec.Header().Get("X-Cache") != "MISS" || project.PricePerTonne != 15 {
+		t.Errorf("expected the new price after invalidation, got %v from %s", project.PricePerTonne, rec.Header().Get("X-Cache"))
+	}
+
+	var p problem.Problem
+	rec = serve(t, h.CancelPriceSchedule, http.MethodDelete, "/", "", withSchedule("1", "1"))
+	decode(t, rec, &p)
+	if rec.Code != http.StatusConflict || p.Code != CodeScheduleNotPending {
+		t.Errorf("expected 409 %s, got %d %q", CodeScheduleNotPending, rec.Code, p.Code)
+	}
+
+	var history struct {
+		Changes []models.PriceChange `json:"changes"`
+		Count   int                  `json:"count"`
+	}
+	decode(t, serve(t, h.GetPriceHistory, http.MethodGet, "/", "", withID("1")), &history)
+	if history.Count != 2 || history.Changes[0].Source != models.PriceChangeSchedule || history.Changes[0].PricePerTonne != 15 {
+		t.Errorf("expected the schedule over the creation in the history, got %+v", history.Changes)
+	}
+}
+
+func TestGetQuoteValidation(t *testing.T) {
+	h, _ := newTestPricingHandler(t)
+
+	for _, query := range []string{"", "tonnes=0", "tonnes=many", "tonnes=5&at=tomorrow", "tonnes=5&at=2000-01-01T00:00:00Z"} {
+		if _, code := quoteOf(t, h, query); code != http.StatusUnprocessableEntity {
+			t.Errorf("%q: expected 422, got %d", query, code)
+		}
+	}
+	if rec := serve(t, h.GetQuote, http.MethodGet, "/?tonnes=5", "", withID("9")); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing project, got %d", rec.Code)
+	}
+}
@@ -1,130 +1,234 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"project_service/cache"
 	"project_service/models"
 	"project_service/repositories"
+	"shared/problem"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
+// Problem codes of lifecycle transitions
+const (
+	CodeInvalidTransition = "invalid_transition"
+	CodeSelfReview        = "self_review"
+)
+
+// Cache namespaces. Listings, searches and the facet value lists share the
+// catalog namespace, which every write invalidates; each project's detail
+// has its own.
+const catalogNamespace = "projects"
+
+func projectNamespace(id uint64) string {
+	return "project:" + strconv.FormatUint(id, 10)
+}
+
+// Cache lifetimes
+const (
+	projectTTL = time.Hour
+	listTTL    = 30 * time.Minute
+	searchTTL  = 15 * time.Minute
+	valuesTTL  = time.Hour
+)
+
+type JwtCustomClaims struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+	jwt.RegisteredClaims
+}
+
 type ProjectHandler struct {
-	repo  *repositories.ProjectRepository
-	redis *redis.Client
+	repo  repositories.ProjectRepository
+	cache *cache.Cache
 }
 
-func NewProjectHandler() *ProjectHandler {
+func NewProjectHandler(repo repositories.ProjectRepository) *ProjectHandler {
 	return &ProjectHandler{
-		repo: repositories.NewProjectRepository(),
+		repo: repo,
 	}
 }
 
-// SetRedisClient sets the Redis client for caching
-func (h *ProjectHandler) SetRedisClient(client *redis.Client) {
-	h.redis = client
+// SetCache caches responses
+func (h *ProjectHandler) SetCache(responses *cache.Cache) {
+	h.cache = responses
 }
 
 // CreateProject creates a new project
 // @Summary Create a new project
-// @Description Create a new carbon offset project (admin only)
+// @Description Create a new carbon offset project as a draft (admin only). It is not public until it is submitted, approved and published. The buffer pool's share of the total capacity is withheld from the available capacity. A developer_id links the project to the developer account that owns it. The creation is the project's first revision.
 // @Tags projects
 // @Accept json
 // @Produce json
 // @Security AdminAuth
 // @Param request body models.CreateProjectRequest true "Project details"
 // @Success 201 {object} models.Project "Project created successfully"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 500 {object} map[string]string "Failed to create project"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 409 {object} problem.Problem "External reference already used"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to create project"
 // @Router /api/v1/projects/admin [post]
 func (h *ProjectHandler) CreateProject(c echo.Context) error {
 	var req models.CreateProjectRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
 	}
 
-	// Convert request to project model
-	project := &models.Project{
-		Title:                req.Title,
-		Description:          req.Description,
-		Category:             req.Category,
-		Region:               req.Region,
-		Country:              req.Country,
-		VerificationStandard: req.VerificationStandard,
-		PricePerTonne:        req.PricePerTonne,
-		TotalCapacity:        req.TotalCapacity,
-		AvailableCapacity:    req.AvailableCapacity,
-		ProjectDeveloper:     req.ProjectDeveloper,
-		ProjectURL:           req.ProjectURL,
-		ImageURL:             req.ImageURL,
-		Status:               "active",
+	if req.ExternalRef != "" {
+		if _, err := h.repo.GetByExternalRef(req.ExternalRef); err == nil {
+			return problem.Conflict("A project with this external reference already exists")
+		}
 	}
 
-	if err := h.repo.Create(project); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create project"})
+	project := newProject(&req)
+	if err := h.repo.Create(project, models.Change{Actor: actor}); err != nil {
+		return problem.Internal("Failed to create project", err)
 	}
 
-	// Invalidate cache
-	h.invalidateProjectCache()
+	invalidate(c, h.cache, catalogNamespace)
 
 	return c.JSON(http.StatusCreated, project)
 }
 
 // GetProject retrieves a project by ID
 // @Summary Get project by ID
-// @Description Retrieve a specific project by its ID
+// @Description Retrieve a specific project by its ID. Projects that have never been published are not found.
 // @Tags projects
 // @Accept json
 // @Produce json
 // @Param id path int true "Project ID"
 // @Success 200 {object} models.Project "Project details"
-// @Failure 400 {object} map[string]string "Invalid project ID"
-// @Failure 404 {object} map[string]string "Project not found"
-// @Failure 500 {object} map[string]string "Failed to retrieve project"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve project"
 // @Router /api/v1/projects/{id} [get]
 func (h *ProjectHandler) GetProject(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+		return problem.BadRequest("Invalid project ID")
 	}
 
-	// Try to get from cache first
-	cacheKey := "project:" + idStr
-	if h.redis != nil {
-		if cached, err := h.getFromCache(cacheKey); err == nil {
-			return c.JSON(http.StatusOK, cached)
+	return cached(c, h.cache, projectNamespace(id), "detail", projectTTL, func() (interface{}, error) {
+		project, err := h.repo.GetByID(uint(id))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, problem.NotFound("Project not found")
+			}
+			return nil, problem.Internal("Failed to retrieve project", err)
 		}
+		if models.Unreleased(project.Status) {
+			return nil, problem.NotFound("Project not found")
+		}
+		return project, nil
+	})
+}
+
+// GetProjectAdmin retrieves a project by ID in any status
+// @Summary Get project by ID (admin)
+// @Description Retrieve a project by its ID whatever its lifecycle status (admin only)
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Success 200 {object} models.Project "Project details"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve project"
+// @Router /api/v1/projects/admin/{id} [get]
+func (h *ProjectHandler) GetProjectAdmin(c echo.Context) error {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
 	}
 
 	project, err := h.repo.GetByID(uint(id))
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Project not found"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve project"})
+		return problem.Internal("Failed to retrieve project", err)
 	}
 
-	// Cache the result
-	if h.redis != nil {
-		h.setCache(cacheKey, project, 3600) // Cache for 1 hour
+	return c.JSON(http.StatusOK, project)
+}
+
+// RequireReleased hides the routes under a project that has never been
+// published, as GetProject hides the project itself, unless the caller is
+// an admin or the project's developer
+func (h *ProjectHandler) RequireReleased(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			return problem.BadRequest("Invalid project ID")
+		}
+
+		project, err := h.repo.GetByID(uint(id))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return problem.NotFound("Project not found")
+			}
+			return problem.Internal("Failed to retrieve project", err)
+		}
+		if models.Unreleased(project.Status) && !maySeeUnreleased(c, project) {
+			return problem.NotFound("Project not found")
+		}
+		return next(c)
 	}
+}
 
-	return c.JSON(http.StatusOK, project)
+// maySeeUnreleased reports whether the caller is an admin or the developer
+// of the project
+func maySeeUnreleased(c echo.Context, project *models.Project) bool {
+	actor, err := actorOf(c)
+	if err != nil {
+		return false
+	}
+	switch actor.Role {
+	case models.RoleAdmin:
+		return true
+	case models.RoleDeveloper:
+		return project.DeveloperID != nil && *project.DeveloperID == actor.ID
+	default:
+		return false
+	}
 }
 
 // GetAllProjects retrieves all projects with pagination
 // @Summary Get all projects
-// @Description Retrieve all carbon offset projects with pagination
+// @Description Retrieve a sorted page of published carbon offset projects and the total across all pages. Pass next_cursor back as cursor to page with a keyset that stays stable while projects are edited; a cursor takes the place of offset.
 // @Tags projects
 // @Accept json
 // @Produce json
+// @Param sort query string false "Sort order" Enums(newest, price_asc, price_desc, capacity_asc, capacity_desc) default(newest)
+// @Param cursor query string false "Cursor from the previous page's next_cursor"
 // @Param limit query int false "Limit number of results (max 100)" default(10)
 // @Param offset query int false "Offset for pagination" default(0)
 // @Success 200 {object} map[string]interface{} "List of projects"
-// @Failure 500 {object} map[string]string "Failed to retrieve projects"
+// @Failure 400 {object} problem.Problem "Invalid sort or cursor"
+// @Failure 500 {object} problem.Problem "Failed to retrieve projects"
 // @Router /api/v1/projects [get]
 func (h *ProjectHandler) GetAllProjects(c echo.Context) error {
 	// Parse pagination parameters
@@ -146,35 +250,40 @@ func (h *ProjectHandler) GetAllProjects(c echo.Context) error {
 		}
 	}
 
-	// Try to get from cache first
-	cacheKey := "projects:all:" + strconv.Itoa(limit) + ":" + strconv.Itoa(offset)
-	if h.redis != nil {
-		if cached, err := h.getFromCache(cacheKey); err == nil {
-			return c.JSON(http.StatusOK, cached)
-		}
-	}
-
-	projects, err := h.repo.GetAll(limit, offset)
+	order, err := resolveSort(c.QueryParam("sort"), models.ListSorts)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve projects"})
+		return err
 	}
-
-	// Cache the result
-	if h.redis != nil {
-		h.setCache(cacheKey, projects, 1800) // Cache for 30 minutes
+	cursor := c.QueryParam("cursor")
+	if cursor != "" {
+		offset = 0
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"projects": projects,
-		"limit":    limit,
-		"offset":   offset,
-		"count":    len(projects),
+	listReq := &models.ProjectListRequest{Sort: order, Cursor: cursor, Limit: limit, Offset: offset}
+	return cached(c, h.cache, catalogNamespace, cacheKey("list", listReq), listTTL, func() (interface{}, error) {
+		page, err := h.repo.GetAll(listReq)
+		if err != nil {
+			if errors.Is(err, repositories.ErrInvalidCursor) {
+				return nil, problem.BadRequest("Invalid cursor")
+			}
+			return nil, problem.Internal("Failed to retrieve projects", err)
+		}
+
+		return map[string]interface{}{
+			"projects":    page.Projects,
+			"total":       page.Total,
+			"sort":        order,
+			"next_cursor": page.NextCursor,
+			"limit":       limit,
+			"offset":      offset,
+			"count":       len(page.Projects),
+		}, nil
 	})
 }
 
 // UpdateProject updates a project
 // @Summary Update project
-// @Description Update a project's information (admin only)
+// @Description Update a project's information (admin only). Zero and empty values leave their fields unchanged; use PATCH to set or clear them. The available capacity may not exceed the total nor what the buffer pool leaves sellable; a buffer change without a new available capacity moves the withheld tonnes out of or back into it. A developer_id links the project to another developer account. The change is recorded as a revision with the caller, a field-level diff and the optional reason.
 // @Tags projects
 // @Accept json
 // @Produce json
@@ -182,121 +291,494 @@ func (h *ProjectHandler) GetAllProjects(c echo.Context) error {
 // @Param id path int true "Project ID"
 // @Param request body models.UpdateProjectRequest true "Project update details"
 // @Success 200 {object} map[string]string "Project updated successfully"
-// @Failure 400 {object} map[string]string "Invalid project ID or request body"
-// @Failure 404 {object} map[string]string "Project not found"
-// @Failure 500 {object} map[string]string "Failed to update project"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to update project"
 // @Router /api/v1/projects/admin/{id} [put]
 func (h *ProjectHandler) UpdateProject(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+		return problem.BadRequest("Invalid project ID")
 	}
 
 	var req models.UpdateProjectRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
 	}
 
-	if err := h.repo.Update(uint(id), &req); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Project not found"})
+	change := models.Change{Actor: actor, Reason: strings.TrimSpace(req.Reason)}
+	if err := h.repo.Update(uint(id), &req, change); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		if errors.Is(err, repositories.ErrCapacityExceedsTotal) {
+			return capacityProblem()
 		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to update project"})
+		return problem.Internal("Failed to update project", err)
 	}
 
-	// Invalidate cache
-	h.invalidateProjectCache()
-	h.invalidateProjectCacheByID(idStr)
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(id))
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Project updated successfully"})
 }
 
-// DeleteProject soft deletes a project
+// PatchProject applies a JSON Merge Patch to a project
+// @Summary Patch project
+// @Description Change a project's fields with a JSON Merge Patch (RFC 7396, admin only): a member sets its field, including to zero or an empty string, a null member clears it and an absent one leaves it unchanged. Object fields such as the boundary merge member by member. The status only changes through transitions and the external reference cannot be patched. The patched project must pass the same rules as a new one, and its available capacity may not exceed the total. The change is recorded as a revision with the caller, a field-level diff and the optional reason.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param reason query string false "Why the project is changed"
+// @Param request body object true "Merge patch of project fields"
+// @Success 200 {object} models.Project "Project after the patch"
+// @Failure 400 {object} problem.Problem "Invalid project ID or patch document"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to patch project"
+// @Router /api/v1/projects/admin/{id} [patch]
+func (h *ProjectHandler) PatchProject(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	// Decoded by hand: Bind does not accept application/merge-patch+json
+	var patch models.ProjectPatch
+	if err := json.NewDecoder(c.Request().Body).Decode(&patch); err != nil || patch == nil {
+		return problem.BadRequest("The patch must be a JSON object")
+	}
+	reason := strings.TrimSpace(c.QueryParam("reason"))
+	if len(reason) > 2000 {
+		return problem.Validation(problem.FieldError{Field: "reason", Message: "must be at most 2000"})
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	// The patched project is validated before it is written; the capacity
+	// rule is checked again under the row lock
+	current, err := h.repo.GetByID(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		return problem.Internal("Failed to patch project", err)
+	}
+	if err := patch.Apply(current); err != nil {
+		return patchProblem(err)
+	}
+	fields := models.FieldsOf(current)
+	if err := validateRequest(&fields); err != nil {
+		return err
+	}
+
+	project, err := h.repo.Patch(uint(id), patch, models.Change{Actor: actor, Reason: reason})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		return patchProblem(err)
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(id))
+
+	return c.JSON(http.StatusOK, project)
+}
+
+// DeleteProject retires a project
 // @Summary Delete project
-// @Description Delete a project (admin only)
+// @Description Retire a project, taking it off sale for good (admin only). The retirement is recorded in the project's transition history and as a revision.
 // @Tags projects
 // @Accept json
 // @Produce json
 // @Security AdminAuth
 // @Param id path int true "Project ID"
+// @Param reason query string false "Why the project is retired"
 // @Success 200 {object} map[string]string "Project deleted successfully"
-// @Failure 400 {object} map[string]string "Invalid project ID"
-// @Failure 404 {object} map[string]string "Project not found"
-// @Failure 500 {object} map[string]string "Failed to delete project"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 409 {object} problem.Problem "Project already retired"
+// @Failure 500 {object} problem.Problem "Failed to delete project"
 // @Router /api/v1/projects/admin/{id} [delete]
 func (h *ProjectHandler) DeleteProject(c echo.Context) error {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid project ID"})
+		return problem.BadRequest("Invalid project ID")
 	}
 
-	if err := h.repo.Delete(uint(id)); err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return c.JSON(http.StatusNotFound, map[string]string{"error": "Project not found"})
-		}
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete project"})
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
 	}
 
-	// Invalidate cache
-	h.invalidateProjectCache()
-	h.invalidateProjectCacheByID(idStr)
+	reason := strings.TrimSpace(c.QueryParam("reason"))
+	if len(reason) > 2000 {
+		return problem.Validation(problem.FieldError{Field: "reason", Message: "must be at most 2000 characters"})
+	}
+	if _, err := h.repo.Transition(uint(id), models.Transitions[models.ActionRetire], actor, reason); err != nil {
+		return transitionProblem(err, "Failed to delete project")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(id))
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Project deleted successfully"})
 }
 
+// TransitionProject moves a project through its lifecycle
+// @Summary Transition project
+// @Description Move a project through its lifecycle: submit a draft for review, approve or reject it, revise a rejected project, publish an approved one, pause, resume, mark it sold out or retire it (admin only; a project's developer submits and revises it through /api/v1/projects/developer/{id}/transitions). Approval and rejection must come from someone other than the submitter, and a rejection must give a reason.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param request body models.TransitionProjectRequest true "Lifecycle action"
+// @Success 200 {object} models.Project "Project after the transition"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 403 {object} problem.Problem "Caller may not make the transition"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 409 {object} problem.Problem "Action not allowed from the project's status"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to transition project"
+// @Router /api/v1/projects/admin/{id}/transitions [post]
+func (h *ProjectHandler) TransitionProject(c echo.Context) error {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	var req models.TransitionProjectRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	transition := models.Transitions[req.Action]
+	req.Reason = strings.TrimSpace(req.Reason)
+	if transition.RequiresReason && req.Reason == "" {
+		return problem.Validation(problem.FieldError{Field: "reason", Message: "is required to " + req.Action})
+	}
+
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+	if !transition.Permits(actor) {
+		return problem.Forbidden("Your role may not " + req.Action + " projects")
+	}
+
+	project, err := h.repo.Transition(uint(id), transition, actor, req.Reason)
+	if err != nil {
+		return transitionProblem(err, "Failed to transition project")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(id))
+
+	return c.JSON(http.StatusOK, project)
+}
+
+// GetProjectTransitions returns a project's lifecycle history
+// @Summary Get project transitions
+// @Description Get every lifecycle transition of a project, oldest first, with who made it and why (admin only)
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Success 200 {object} map[string]interface{} "Transitions of the project"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve transitions"
+// @Router /api/v1/projects/admin/{id}/transitions [get]
+func (h *ProjectHandler) GetProjectTransitions(c echo.Context) error {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	transitions, err := h.repo.Transitions(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		return problem.Internal("Failed to retrieve transitions", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"transitions": transitions,
+		"count":       len(transitions),
+	})
+}
+
+// GetProjectRevisions lists a project's revisions
+// @Summary Get project revisions
+// @Description List every recorded write to a project, newest first: who made it, when and why, the fields it changed and the project's fields after it (admin only)
+// @Tags projects
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param limit query int false "Limit number of results (max 100)" default(50)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} map[string]interface{} "Revisions of the project"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve revisions"
+// @Router /api/v1/projects/admin/{id}/revisions [get]
+func (h *ProjectHandler) GetProjectRevisions(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	revisions, err := h.repo.Revisions(uint(id), limit, offset)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found")
+		}
+		return problem.Internal("Failed to retrieve revisions", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"revisions": revisions,
+		"limit":     limit,
+		"offset":    offset,
+		"count":     len(revisions),
+	})
+}
+
+// GetProjectAsOf returns a project as it was at a moment
+// @Summary Get project as of a time
+// @Description Get a project's fields as they were at a moment, from the latest revision made at or before it (admin only)
+// @Tags projects
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param at query string true "Moment in RFC 3339, such as 2024-05-01T12:00:00Z"
+// @Success 200 {object} map[string]interface{} "The project's fields and the revision they come from"
+// @Failure 400 {object} problem.Problem "Invalid project ID or time"
+// @Failure 404 {object} problem.Problem "Project not found, or no revision from before the time"
+// @Failure 500 {object} problem.Problem "Failed to retrieve revision"
+// @Router /api/v1/projects/admin/{id}/as-of [get]
+func (h *ProjectHandler) GetProjectAsOf(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+	at, err := time.Parse(time.RFC3339, c.QueryParam("at"))
+	if err != nil {
+		return problem.BadRequest("at must be an RFC 3339 time, such as 2024-05-01T12:00:00Z")
+	}
+
+	revision, err := h.repo.RevisionAt(uint(id), at)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("Project not found, or it has no revision from before then")
+		}
+		return problem.Internal("Failed to retrieve revision", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"project_id":  revision.ProjectID,
+		"as_of":       at,
+		"revision_id": revision.ID,
+		"revised_at":  revision.CreatedAt,
+		"project":     revision.Snapshot,
+	})
+}
+
+// RevertProject restores a project's fields to a revision
+// @Summary Revert project
+// @Description Restore a project's fields to how a revision left them (admin only). The status is not reverted; it only moves through the lifecycle. Nor is the available capacity, so tonnes sold or held since stay sold or held; it only shrinks to what the restored capacity leaves, which is recorded in the capacity ledger. The revert is recorded as a new revision, so it can itself be reverted.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param revisionID path int true "Revision ID"
+// @Param request body models.RevertProjectRequest false "Why the project is reverted"
+// @Success 200 {object} models.Project "Project after the revert"
+// @Failure 400 {object} problem.Problem "Invalid ID or request body"
+// @Failure 404 {object} problem.Problem "Project or revision not found"
+// @Failure 409 {object} problem.Problem "The revision has less capacity than is sold or held"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to revert project"
+// @Router /api/v1/projects/admin/{id}/revisions/{revisionID}/revert [post]
+func (h *ProjectHandler) RevertProject(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+	revisionID, err := strconv.ParseUint(c.Param("revisionID"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid revision ID")
+	}
+
+	var req models.RevertProjectRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	actor, err := actorOf(c)
+	if err != nil {
+		return err
+	}
+
+	project, err := h.repo.Revert(uint(id), uint(revisionID), models.Change{Actor: actor, Reason: strings.TrimSpace(req.Reason)})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return problem.NotFound("Project or revision not found")
+		case errors.Is(err, repositories.ErrCapacityTaken):
+			return problem.Conflict("The revision has less capacity than the project has sold or holds").WithCode(CodeInsufficientCapacity)
+		}
+		return problem.Internal("Failed to revert project", err)
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(id))
+
+	return c.JSON(http.StatusOK, project)
+}
+
 // SearchProjects searches projects with filters
 // @Summary Search projects
-// @Description Search and filter carbon offset projects
+// @Description Search and filter carbon offset projects. near keeps the projects located within radius_km of a point and bbox those inside a bounding box, by their location and not their boundary. A box crosses the antimeridian when min_lon is greater than max_lon; projects without a location match neither. With Elasticsearch, text matches are ranked by relevance and carry a score and highlighted title and description fragments. The quality filters match the methodology, credit type, solution type and any of the SDG goals, a minimum permanence and a maximum buffer pool percentage. Results carry facet counts for category, region, country, verification standard, methodology, credit and solution type and SDG goals, a price per tonne histogram and available capacity, permanence and buffer pool ranges; each facet is counted with every filter except its own. Sort by relevance (the default), newest, price or available capacity, and pass next_cursor back as cursor to page with a keyset that stays stable while projects are edited.
 // @Tags projects
 // @Accept json
 // @Produce json
 // @Param request body models.ProjectSearchRequest true "Search filters"
 // @Success 200 {object} map[string]interface{} "Search results"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 500 {object} map[string]string "Search failed"
+// @Failure 400 {object} problem.Problem "Invalid request body, sort or cursor"
+// @Failure 422 {object} problem.Problem "Invalid geo filter"
+// @Failure 500 {object} problem.Problem "Search failed"
 // @Router /api/v1/projects/search [post]
 func (h *ProjectHandler) SearchProjects(c echo.Context) error {
 	var req models.ProjectSearchRequest
 	if err := c.Bind(&req); err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
 	}
 
 	// Set defaults
 	if req.Limit <= 0 || req.Limit > 100 {
 		req.Limit = 10
 	}
-	if req.Offset < 0 {
+	if req.Offset < 0 || req.Cursor != "" {
 		req.Offset = 0
 	}
+	order, err := resolveSort(req.Sort, models.SearchSorts)
+	if err != nil {
+		return err
+	}
+	req.Sort = order
 
-	// Try to get from cache first
-	cacheKey := h.generateSearchCacheKey(&req)
-	if h.redis != nil {
-		if cached, err := h.getFromCache(cacheKey); err == nil {
-			return c.JSON(http.StatusOK, cached)
+	return cached(c, h.cache, catalogNamespace, searchCacheKey(&req), searchTTL, func() (interface{}, error) {
+		found, err := h.repo.Search(&req)
+		if err != nil {
+			if errors.Is(err, repositories.ErrInvalidCursor) {
+				return nil, problem.BadRequest("Invalid cursor")
+			}
+			return nil, problem.Internal("Search failed", err)
 		}
-	}
 
-	projects, err := h.repo.Search(&req)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Search failed"})
-	}
+		return map[string]interface{}{
+			"projects":    found.Hits,
+			"total":       found.Total,
+			"sort":        req.Sort,
+			"next_cursor": found.NextCursor,
+			"limit":       req.Limit,
+			"offset":      req.Offset,
+			"count":       len(found.Hits),
+			"facets":      found.Facets,
+		}, nil
+	})
+}
 
-	result := map[string]interface{}{
-		"projects": projects,
-		"limit":    req.Limit,
-		"offset":   req.Offset,
-		"count":    len(projects),
+// GetProjectsGeoJSON serves the located projects as GeoJSON for maps
+// @Summary Get projects as GeoJSON
+// @Description Published projects with a location as a GeoJSON FeatureCollection for map clients. Each feature is a Point at the project's location, or its boundary when boundaries is true and it has one, with the id and the title, category, country, verification standard, price and available capacity as properties. bbox, in the RFC 7946 order min_lon,min_lat,max_lon,max_lat, and lat, lon and radius_km narrow the features like the search filters, as do category, country and verification_standard. Features are ordered by ID, up to limit at a time; pass next_cursor back as cursor for the rest.
+// @Tags projects
+// @Produce json
+// @Param bbox query string false "Bounding box as min_lon,min_lat,max_lon,max_lat"
+// @Param lat query number false "Latitude of the radius filter's centre"
+// @Param lon query number false "Longitude of the radius filter's centre"
+// @Param radius_km query number false "Radius in kilometres"
+// @Param category query []string false "Categories" collectionFormat(multi)
+// @Param country query []string false "Countries" collectionFormat(multi)
+// @Param verification_standard query []string false "Verification standards" collectionFormat(multi)
+// @Param boundaries query bool false "Draw projects with a boundary as their boundary"
+// @Param cursor query string false "Cursor from the previous page's next_cursor"
+// @Param limit query int false "Features per page (max 1000)" default(1000)
+// @Success 200 {object} models.FeatureCollection "Projects as GeoJSON features"
+// @Failure 400 {object} problem.Problem "Invalid bbox, radius filter or cursor"
+// @Failure 422 {object} problem.Problem "Geo filter out of range"
+// @Failure 500 {object} problem.Problem "Failed to retrieve projects"
+// @Router /api/v1/projects/geojson [get]
+func (h *ProjectHandler) GetProjectsGeoJSON(c echo.Context) error {
+	req := models.ProjectSearchRequest{
+		Category:             c.QueryParams()["category"],
+		Country:              c.QueryParams()["country"],
+		VerificationStandard: c.QueryParams()["verification_standard"],
+		Located:              true,
+		Cursor:               c.QueryParam("cursor"),
+		Limit:                models.MaxFeatures,
 	}
-
-	// Cache the result
-	if h.redis != nil {
-		h.setCache(cacheKey, result, 900) // Cache for 15 minutes
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= models.MaxFeatures {
+		req.Limit = l
+	}
+	if err := geoFilters(c, &req); err != nil {
+		return err
 	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	req.Sort = models.SearchSorts[0]
+	boundaries := c.QueryParam("boundaries") == "true"
 
-	return c.JSON(http.StatusOK, result)
+	key := "geojson:" + strconv.FormatBool(boundaries) + ":" + searchCacheKey(&req)
+	return cached(c, h.cache, catalogNamespace, key, searchTTL, func() (interface{}, error) {
+		found, err := h.repo.Search(&req)
+		if err != nil {
+			if errors.Is(err, repositories.ErrInvalidCursor) {
+				return nil, problem.BadRequest("Invalid cursor")
+			}
+			return nil, problem.Internal("Failed to retrieve projects", err)
+		}
+
+		features := make([]models.Feature, len(found.Hits))
+		for i := range found.Hits {
+			features[i] = models.FeatureOf(&found.Hits[i].Project, boundaries)
+		}
+		return models.FeatureCollection{Type: "FeatureCollection", Features: features, NextCursor: found.NextCursor}, nil
+	})
 }
 
 // GetProjectCategories retrieves all available categories
@@ -306,28 +788,16 @@ func (h *ProjectHandler) SearchProjects(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]interface{} "List of categories"
-// @Failure 500 {object} map[string]string "Failed to retrieve categories"
+// @Failure 500 {object} problem.Problem "Failed to retrieve categories"
 // @Router /api/v1/projects/categories [get]
 func (h *ProjectHandler) GetProjectCategories(c echo.Context) error {
-	// Try to get from cache first
-	cacheKey := "project:categories"
-	if h.redis != nil {
-		if cached, err := h.getFromCache(cacheKey); err == nil {
-			return c.JSON(http.StatusOK, cached)
+	return cached(c, h.cache, catalogNamespace, "categories", valuesTTL, func() (interface{}, error) {
+		categories, err := h.repo.GetCategories()
+		if err != nil {
+			return nil, problem.Internal("Failed to retrieve categories", err)
 		}
-	}
-
-	categories, err := h.repo.GetCategories()
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve categories"})
-	}
-
-	// Cache the result
-	if h.redis != nil {
-		h.setCache(cacheKey, categories, 3600) // Cache for 1 hour
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{"categories": categories})
+		return map[string]interface{}{"categories": categories}, nil
+	})
 }
 
 // GetProjectRegions retrieves all available regions
@@ -337,28 +807,16 @@ func (h *ProjectHandler) GetProjectCategories(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]interface{} "List of regions"
-// @Failure 500 {object} map[string]string "Failed to retrieve regions"
+// @Failure 500 {object} problem.Problem "Failed to retrieve regions"
 // @Router /api/v1/projects/regions [get]
 func (h *ProjectHandler) GetProjectRegions(c echo.Context) error {
-	// Try to get from cache first
-	cacheKey := "project:regions"
-	if h.redis != nil {
-		if cached, err := h.getFromCache(cacheKey); err == nil {
-			return c.JSON(http.StatusOK, cached)
+	return cached(c, h.cache, catalogNamespace, "regions", valuesTTL, func() (interface{}, error) {
+		regions, err := h.repo.GetRegions()
+		if err != nil {
+			return nil, problem.Internal("Failed to retrieve regions", err)
 		}
-	}
-
-	regions, err := h.repo.GetRegions()
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve regions"})
-	}
-
-	// Cache the result
-	if h.redis != nil {
-		h.setCache(cacheKey, regions, 3600) // Cache for 1 hour
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{"regions": regions})
+		return map[string]interface{}{"regions": regions}, nil
+	})
 }
 
 // GetProjectCountries retrieves all available countries
@@ -368,62 +826,214 @@ func (h *ProjectHandler) GetProjectRegions(c echo.Context) error {
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]interface{} "List of countries"
-// @Failure 500 {object} map[string]string "Failed to retrieve countries"
+// @Failure 500 {object} problem.Problem "Failed to retrieve countries"
 // @Router /api/v1/projects/countries [get]
 func (h *ProjectHandler) GetProjectCountries(c echo.Context) error {
-	// Try to get from cache first
-	cacheKey := "project:countries"
-	if h.redis != nil {
-		if cached, err := h.getFromCache(cacheKey); err == nil {
-			return c.JSON(http.StatusOK, cached)
+	return cached(c, h.cache, catalogNamespace, "countries", valuesTTL, func() (interface{}, error) {
+		countries, err := h.repo.GetCountries()
+		if err != nil {
+			return nil, problem.Internal("Failed to retrieve countries", err)
 		}
+		return map[string]interface{}{"countries": countries}, nil
+	})
+}
+
+// ForgetProject drops the cached responses a change to a project makes
+// stale. The outbox relay calls it once the change is in the search index,
+// so searches cached in between do not outlive it.
+func (h *ProjectHandler) ForgetProject(ctx context.Context, id uint) error {
+	return h.cache.Invalidate(ctx, catalogNamespace, projectNamespace(uint64(id)))
+}
+
+// actorOf reads who is making a request from its token
+func actorOf(c echo.Context) (models.Actor, error) {
+	token, ok := c.Get("user").(*jwt.Token)
+	if !ok {
+		return models.Actor{}, problem.Unauthorized("Missing token")
+	}
+	claims, ok := token.Claims.(*JwtCustomClaims)
+	if !ok {
+		return models.Actor{}, problem.Unauthorized("Invalid token claims")
 	}
+	return models.Actor{ID: claims.UserID, Role: claims.Role}, nil
+}
 
-	countries, err := h.repo.GetCountries()
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to retrieve countries"})
+// transitionProblem maps a lifecycle transition error to a problem
+func transitionProblem(err error, detail string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.NotFound("Project not found")
+	case errors.Is(err, repositories.ErrInvalidTransition):
+		return problem.Conflict("The action is not allowed from the project's status").WithCode(CodeInvalidTransition)
+	case errors.Is(err, repositories.ErrSelfReview):
+		return problem.Forbidden("A project may not be reviewed by whoever submitted it").WithCode(CodeSelfReview)
+	default:
+		return problem.Internal(detail, err)
+	}
+}
+
+// patchProblem maps an error applying a patch to a problem
+func patchProblem(err error) error {
+	var patchErr *models.PatchError
+	switch {
+	case errors.As(err, &patchErr):
+		return problem.Validation(problem.FieldError{Field: patchErr.Field, Message: patchErr.Message})
+	case errors.Is(err, repositories.ErrCapacityExceedsTotal):
+		return capacityProblem()
+	default:
+		return problem.Internal("Failed to patch project", err)
 	}
+}
 
-	// Cache the result
-	if h.redis != nil {
-		h.setCache(cacheKey, countries, 3600) // Cache for 1 hour
+// capacityProblem is the validation problem of a write that leaves more
+// capacity available than there is in total
+func capacityProblem() error {
+	return problem.Validation(problem.FieldError{Field: "available_capacity", Message: "must not exceed total_capacity"})
+}
+
+// cached writes the cached JSON response for key in the namespace, loading
+// and caching it on a miss. X-Cache tells whether it was a hit.
+func cached(c echo.Context, responses *cache.Cache, namespace, key string, ttl time.Duration, load func() (interface{}, error)) error {
+	data, hit, err := responses.Fetch(c.Request().Context(), namespace, key, ttl, load)
+	if err != nil {
+		return err
 	}
+	if responses != nil {
+		status := "MISS"
+		if hit {
+			status = "HIT"
+		}
+		c.Response().Header().Set("X-Cache", status)
+	}
+	return c.JSONBlob(http.StatusOK, data)
+}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{"countries": countries})
+// invalidate drops the cached responses of the namespaces after a write.
+// The write has already succeeded, so a failure is only logged and stale
+// entries are left to expire.
+func invalidate(c echo.Context, responses *cache.Cache, namespaces ...string) {
+	if err := responses.Invalidate(c.Request().Context(), namespaces...); err != nil {
+		log.Printf("Warning: failed to invalidate cache %v: %v", namespaces, err)
+	}
 }
 
-// Cache helper methods
-func (h *ProjectHandler) getFromCache(key string) (interface{}, error) {
-	if h.redis == nil {
-		return nil, redis.Nil
+// newProject returns the draft project a create request describes
+func newProject(req *models.CreateProjectRequest) *models.Project {
+	return &models.Project{
+		ExternalRef:          externalRef(req.ExternalRef),
+		Title:                req.Title,
+		Description:          req.Description,
+		Category:             req.Category,
+		Region:               req.Region,
+		Country:              req.Country,
+		VerificationStandard: req.VerificationStandard,
+		PricePerTonne:        req.PricePerTonne,
+		TotalCapacity:        req.TotalCapacity,
+		AvailableCapacity:    req.AvailableCapacity,
+		ProjectDeveloper:     req.ProjectDeveloper,
+		DeveloperID:          req.DeveloperID,
+		ProjectURL:           req.ProjectURL,
+		ImageURL:             req.ImageURL,
+		Latitude:             req.Latitude,
+		Longitude:            req.Longitude,
+		Boundary:             req.Boundary,
+		MethodologyID:        req.MethodologyID,
+		CreditType:           req.CreditType,
+		SolutionType:         req.SolutionType,
+		PermanenceYears:      req.PermanenceYears,
+		BufferPoolPercent:    req.BufferPoolPercent,
+		AdditionalityNotes:   req.AdditionalityNotes,
+		SDGGoals:             req.SDGGoals,
+		Status:               models.StatusDraft,
 	}
-	// Implementation would use h.redis.Get() and JSON unmarshaling
-	return nil, redis.Nil
 }
 
-func (h *ProjectHandler) setCache(key string, value interface{}, expiration int) {
-	if h.redis == nil {
-		return
+// externalRef returns a project's external reference, nil when there is
+// none so projects without one do not collide on the unique index
+func externalRef(ref string) *string {
+	if ref == "" {
+		return nil
 	}
-	// Implementation would use h.redis.Set() with JSON marshaling
+	return &ref
 }
 
-func (h *ProjectHandler) invalidateProjectCache() {
-	if h.redis == nil {
-		return
+// cacheKey identifies a request by a hash of its JSON, so every field
+// takes part in the key
+func cacheKey(kind string, req interface{}) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
+
+// searchCacheKey is the cache key of a search. Filter values are sorted so
+// the same filters in another order share an entry.
+func searchCacheKey(req *models.ProjectSearchRequest) string {
+	canonical := *req
+	for _, values := range []*[]string{&canonical.Category, &canonical.Region, &canonical.Country, &canonical.VerificationStandard,
+		&canonical.MethodologyID, &canonical.CreditType, &canonical.SolutionType} {
+		*values = append([]string(nil), *values...)
+		sort.Strings(*values)
+	}
+	for _, values := range []*[]int{&canonical.Vintage, &canonical.SDGGoals} {
+		*values = append([]int(nil), *values...)
+		sort.Ints(*values)
 	}
-	// Implementation would delete cache keys with pattern "projects:*"
+	return cacheKey("search", &canonical)
 }
 
-func (h *ProjectHandler) invalidateProjectCacheByID(id string) {
-	if h.redis == nil {
-		return
+// geoFilters reads the bbox and radius query parameters of a search. The
+// radius filter takes lat, lon and radius_km together.
+func geoFilters(c echo.Context, req *models.ProjectSearchRequest) error {
+	if value := c.QueryParam("bbox"); value != "" {
+		parts := strings.Split(value, ",")
+		bounds := make([]float64, len(parts))
+		for i, part := range parts {
+			bound, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return problem.BadRequest("Invalid bbox, expected min_lon,min_lat,max_lon,max_lat")
+			}
+			bounds[i] = bound
+		}
+		if len(bounds) != 4 {
+			return problem.BadRequest("Invalid bbox, expected min_lon,min_lat,max_lon,max_lat")
+		}
+		req.BBox = &models.GeoBox{MinLon: bounds[0], MinLat: bounds[1], MaxLon: bounds[2], MaxLat: bounds[3]}
 	}
-	// Implementation would delete cache key "project:" + id
+
+	names := []string{"lat", "lon", "radius_km"}
+	values := make([]float64, len(names))
+	given := 0
+	for i, name := range names {
+		if c.QueryParam(name) == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(c.QueryParam(name), 64)
+		if err != nil {
+			return problem.BadRequest("Invalid " + name)
+		}
+		values[i] = value
+		given++
+	}
+	switch given {
+	case 0:
+	case len(names):
+		req.Near = &models.GeoCircle{Lat: values[0], Lon: values[1], RadiusKm: values[2]}
+	default:
+		return problem.BadRequest("The radius filter takes lat, lon and radius_km together")
+	}
+	return nil
 }
 
-func (h *ProjectHandler) generateSearchCacheKey(req *models.ProjectSearchRequest) string {
-	// Generate a cache key based on search parameters
-	// This is a simplified version - in production, you'd want a more sophisticated key generation
-	return "search:" + req.Query + ":" + strconv.Itoa(req.Limit) + ":" + strconv.Itoa(req.Offset)
+// resolveSort returns the requested sort order, or the default when none is
+// given. allowed lists the accepted orders, the default first.
+func resolveSort(name string, allowed []string) (string, error) {
+	if name == "" {
+		return allowed[0], nil
+	}
+	for _, s := range allowed {
+		if s == name {
+			return name, nil
+		}
+	}
+	return "", problem.BadRequest("Invalid sort, expected one of: " + strings.Join(allowed, ", "))
 }
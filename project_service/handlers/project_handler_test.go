@@ -0,0 +1,1060 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"reflect"
+	"shared/problem"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+func newTestHandler() (*ProjectHandler, *repositories.InMemoryProjectRepository) {
+	repo := repositories.NewInMemoryProjectRepository()
+	return NewProjectHandler(repo), repo
+}
+
+// serve runs a single handler against a JSON request. setup may set path
+// params on the context before the handler runs.
+func serve(t *testing.T, handler echo.HandlerFunc, method, target, body string, setup func(c echo.Context)) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if setup != nil {
+		setup(c)
+	}
+
+	if err := handler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func withID(id string) func(c echo.Context) {
+	return func(c echo.Context) {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+}
+
+// asAdmin runs setup and authenticates the request as the admin with the
+// user ID
+func asAdmin(userID uint, setup func(c echo.Context)) func(c echo.Context) {
+	return func(c echo.Context) {
+		if setup != nil {
+			setup(c)
+		}
+		c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{UserID: userID, Role: models.RoleAdmin}))
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+	}
+}
+
+func seedProjects(t *testing.T, repo *repositories.InMemoryProjectRepository) {
+	t.Helper()
+	projects := []models.Project{
+		{Title: "Amazon Rainforest Protection", Description: "REDD+ forest conservation", Category: "forestry",
+			Region: "South America", Country: "Brazil", VerificationStandard: "VCS", PricePerTonne: 12,
+			TotalCapacity: 1000, AvailableCapacity: 800},
+		{Title: "Kenya Clean Cookstoves", Description: "Efficient cookstoves for rural households", Category: "energy",
+			Region: "Africa", Country: "Kenya", VerificationStandard: "Gold Standard", PricePerTonne: 8,
+			TotalCapacity: 500, AvailableCapacity: 500},
+		{Title: "Gujarat Wind Farm", Description: "Grid-connected wind energy", Category: "energy",
+			Region: "Asia", Country: "India", VerificationStandard: "VCS", PricePerTonne: 5,
+			TotalCapacity: 2000, AvailableCapacity: 1500},
+	}
+	for i := range projects {
+		projects[i].Status = models.StatusPublished
+		if err := repo.Create(&projects[i], models.Change{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCreateProject(t *testing.T) {
+	h, repo := newTestHandler()
+
+	rec := serve(t, h.CreateProject, http.MethodPost, "/",
+		`{"title":"Wind Farm","description":"Wind","category":"energy","region":"Asia","country":"India",
+		"verification_standard":"VCS","price_per_tonne":5,"total_capacity":100,"available_capacity":100}`, asAdmin(1, nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var created models.Project
+	decode(t, rec, &created)
+	if created.ID == 0 || created.Status != models.StatusDraft {
+		t.Fatalf("unexpected project: %+v", created)
+	}
+	if _, err := repo.GetByID(created.ID); err != nil {
+		t.Errorf("project was not stored: %v", err)
+	}
+}
+
+func TestCreateProjectDuplicateExternalRef(t *testing.T) {
+	h, _ := newTestHandler()
+	body := `{"external_ref":"VCS-981","title":"Wind Farm","description":"Wind","category":"energy","region":"Asia",
+		"country":"India","verification_standard":"VCS","price_per_tonne":5}`
+
+	if rec := serve(t, h.CreateProject, http.MethodPost, "/", body, asAdmin(1, nil)); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(t, h.CreateProject, http.MethodPost, "/", body, asAdmin(1, nil)); rec.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a reused external reference, got %d", rec.Code)
+	}
+}
+
+func TestCreateProjectInvalidBody(t *testing.T) {
+	h, _ := newTestHandler()
+
+	rec := serve(t, h.CreateProject, http.MethodPost, "/", `{"title":`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestCreateProjectValidation(t *testing.T) {
+	h, _ := newTestHandler()
+
+	rec := serve(t, h.CreateProject, http.MethodPost, "/",
+		`{"title":"Wind Farm","description":"Wind","category":"energy","region":"Asia","country":"India",
+		"verification_standard":"VCS","price_per_tonne":-5,"total_capacity":100,"available_capacity":150,
+		"project_url":"not a url"}`, asAdmin(1, nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var p problem.Problem
+	decode(t, rec, &p)
+	want := []problem.FieldError{
+		{Field: "price_per_tonne", Message: "must be greater than 0"},
+		{Field: "available_capacity", Message: "must not exceed total_capacity"},
+		{Field: "project_url", Message: "must be a URL"},
+	}
+	if !reflect.DeepEqual(p.Errors, want) {
+		t.Errorf("expected field errors %+v, got %+v", want, p.Errors)
+	}
+}
+
+func TestCreateProjectLocationValidation(t *testing.T) {
+	h, _ := newTestHandler()
+
+	rec := serve(t, h.CreateProject, http.MethodPost, "/",
+		`{"title":"Wind Farm","description":"Wind","category":"energy","region":"Asia","country":"India",
+		"verification_standard":"VCS","price_per_tonne":5,"latitude":95,
+		"boundary":{"type":"Polygon","coordinates":[[[70,22],[71,22],[71,23]]]}}`, asAdmin(1, nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var p problem.Problem
+	decode(t, rec, &p)
+	want := []problem.FieldError{
+		{Field: "latitude", Message: "must be at most 90"},
+		{Field: "longitude", Message: "is required with latitude or boundary"},
+		{Field: "boundary.coordinates", Message: "must be closed rings of at least four [longitude, latitude] positions"},
+	}
+	if !reflect.DeepEqual(p.Errors, want) {
+		t.Errorf("expected field errors %+v, got %+v", want, p.Errors)
+	}
+}
+
+func TestCreateProjectQuality(t *testing.T) {
+	h, _ := newTestHandler()
+
+	rec := serve(t, h.CreateProject, http.MethodPost, "/",
+		`{"title":"Wind Farm","description":"Wind","category":"energy","region":"Asia","country":"India",
+		"verification_standard":"VCS","price_per_tonne":5,"credit_type":"reduction","solution_type":"nature_based",
+		"buffer_pool_percent":100,"sdg_goals":[7,7,18]}`, asAdmin(1, nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var p problem.Problem
+	decode(t, rec, &p)
+	want := []problem.FieldError{
+		{Field: "credit_type", Message: "must be one of: removal, avoidance"},
+		{Field: "buffer_pool_percent", Message: "must be less than 100"},
+		{Field: "sdg_goals", Message: "must not repeat a value"},
+	}
+	if !reflect.DeepEqual(p.Errors, want) {
+		t.Errorf("expected field errors %+v, got %+v", want, p.Errors)
+	}
+
+	rec = serve(t, h.CreateProject, http.MethodPost, "/",
+		`{"title":"Mangroves","description":"Blue carbon","category":"forestry","region":"Asia","country":"India",
+		"verification_standard":"VCS","price_per_tonne":5,"total_capacity":5000,"available_capacity":5000,
+		"methodology_id":"VM0033","credit_type":"removal","solution_type":"nature_based","permanence_years":40,
+		"buffer_pool_percent":15,"sdg_goals":[13,14]}`, asAdmin(1, nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var project models.Project
+	decode(t, rec, &project)
+	if project.BufferCapacity != 750 || project.AvailableCapacity != 4250 {
+		t.Errorf("expected 750 tonnes withheld and 4250 available, got %v and %v", project.BufferCapacity, project.AvailableCapacity)
+	}
+	if project.CreditType != models.CreditRemoval || !reflect.DeepEqual(project.SDGGoals, models.SDGGoals{13, 14}) {
+		t.Errorf("expected the quality metadata to be kept, got %+v", project)
+	}
+}
+
+func TestLinkProjectDeveloper(t *testing.T) {
+	h, repo := newTestHandler()
+
+	rec := serve(t, h.CreateProject, http.MethodPost, "/",
+		`{"title":"Mangroves","description":"Blue carbon","category":"forestry","region":"Asia","country":"India",
+		"verification_standard":"VCS","price_per_tonne":5,"developer_id":7}`, asAdmin(1, nil))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if page, _ := repo.GetByDeveloper(7, 10, 0); page.Total != 1 {
+		t.Fatalf("expected the project linked to developer 7, got %+v", page)
+	}
+
+	if rec := serve(t, h.UpdateProject, http.MethodPut, "/", `{"developer_id":8}`, asAdmin(1, withID("1"))); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	revisions, _ := repo.Revisions(1, 1, 0)
+	if change := revisions[0].Changes["developer_id"]; change.From != uint(7) || change.To != uint(8) {
+		t.Errorf("expected the new owner in the revision, got %+v", revisions[0].Changes)
+	}
+
+	// A null member unlinks the project
+	if rec := serve(t, h.PatchProject, http.MethodPatch, "/", `{"developer_id":null}`, asAdmin(1, withID("1"))); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if page, _ := repo.GetByDeveloper(8, 10, 0); page.Total != 0 {
+		t.Errorf("expected the project unlinked, got %+v", page)
+	}
+}
+
+func TestGetProject(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	tests := []struct {
+		name string
+		id   string
+		code int
+	}{
+		{"existing project", "2", http.StatusOK},
+		{"unknown project", "99", http.StatusNotFound},
+		{"invalid id", "abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h.GetProject, http.MethodGet, "/", "", withID(tt.id))
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireReleased(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+	developer := uint(7)
+	draft := &models.Project{Title: "Peatland Rewetting", Status: models.StatusDraft, DeveloperID: &developer}
+	if err := repo.Create(draft, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	draftID := strconv.FormatUint(uint64(draft.ID), 10)
+	asUser := func(userID uint, setup func(c echo.Context)) func(c echo.Context) {
+		return func(c echo.Context) {
+			setup(c)
+			c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{UserID: userID, Role: "user"}))
+		}
+	}
+
+	for _, tt := range []struct {
+		name  string
+		setup func(c echo.Context)
+		code  int
+	}{
+		{"published project", withID("2"), http.StatusNoContent},
+		{"anonymous caller", withID(draftID), http.StatusNotFound},
+		{"buyer", asUser(developer, withID(draftID)), http.StatusNotFound},
+		{"another developer", asDeveloper(8, withID(draftID)), http.StatusNotFound},
+		{"project's developer", asDeveloper(developer, withID(draftID)), http.StatusNoContent},
+		{"admin", asAdmin(1, withID(draftID)), http.StatusNoContent},
+		{"unknown project", asAdmin(1, withID("99")), http.StatusNotFound},
+		{"invalid id", withID("abc"), http.StatusBadRequest},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, h.RequireReleased(reached), http.MethodGet, "/", "", tt.setup); rec.Code != tt.code {
+				t.Errorf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetProjectNotFoundProblem(t *testing.T) {
+	h, _ := newTestHandler()
+
+	rec := serve(t, h.GetProject, http.MethodGet, "/api/v1/projects/99", "", withID("99"))
+	if ct := rec.Header().Get(echo.HeaderContentType); ct != problem.ContentType {
+		t.Fatalf("expected %s, got %q", problem.ContentType, ct)
+	}
+
+	var body problem.Problem
+	decode(t, rec, &body)
+	if body.Status != http.StatusNotFound || body.Code != problem.CodeNotFound || body.Title != "Not Found" {
+		t.Errorf("unexpected problem: %+v", body)
+	}
+	if body.Detail != "Project not found" || body.Instance != "/api/v1/projects/99" {
+		t.Errorf("unexpected problem detail: %+v", body)
+	}
+}
+
+func TestGetAllProjectsPagination(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	rec := serve(t, h.GetAllProjects, http.MethodGet, "/?limit=2&offset=1", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		Projects []models.Project `json:"projects"`
+		Limit    int              `json:"limit"`
+		Offset   int              `json:"offset"`
+	}
+	decode(t, rec, &body)
+	if body.Limit != 2 || body.Offset != 1 || len(body.Projects) != 2 {
+		t.Fatalf("unexpected page: %+v", body)
+	}
+	if body.Projects[0].ID != 2 {
+		t.Errorf("expected page to start at project 2, got %d", body.Projects[0].ID)
+	}
+}
+
+func TestGetAllProjectsSortAndCursor(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	type listing struct {
+		Projects   []models.Project `json:"projects"`
+		Total      int64            `json:"total"`
+		Sort       string           `json:"sort"`
+		NextCursor string           `json:"next_cursor"`
+	}
+
+	var first listing
+	decode(t, serve(t, h.GetAllProjects, http.MethodGet, "/?sort=price_asc&limit=2", "", nil), &first)
+	if first.Total != 3 || first.Sort != "price_asc" || len(first.Projects) != 2 || first.Projects[0].ID != 3 || first.Projects[1].ID != 2 {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+
+	var second listing
+	decode(t, serve(t, h.GetAllProjects, http.MethodGet, "/?sort=price_asc&limit=2&cursor="+first.NextCursor, "", nil), &second)
+	if len(second.Projects) != 1 || second.Projects[0].ID != 1 || second.NextCursor != "" {
+		t.Errorf("expected the last page to hold project 1, got %+v", second)
+	}
+}
+
+func TestGetAllProjectsRejectsBadSortAndCursor(t *testing.T) {
+	h, _ := newTestHandler()
+
+	for _, target := range []string{"/?sort=relevance", "/?sort=cheapest", "/?cursor=garbage"} {
+		if rec := serve(t, h.GetAllProjects, http.MethodGet, target, "", nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestUpdateProject(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	rec := serve(t, h.UpdateProject, http.MethodPut, "/", `{"price_per_tonne":15}`, asAdmin(1, withID("1")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	project, _ := repo.GetByID(1)
+	if project.PricePerTonne != 15 || project.Title != "Amazon Rainforest Protection" {
+		t.Errorf("expected only the price to change, got %+v", project)
+	}
+
+	rec = serve(t, h.UpdateProject, http.MethodPut, "/", `{"price_per_tonne":15}`, asAdmin(1, withID("99")))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown project, got %d", rec.Code)
+	}
+}
+
+func TestUpdateProjectCapacityExceedsTotal(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	rec := serve(t, h.UpdateProject, http.MethodPut, "/", `{"available_capacity":1200}`, asAdmin(1, withID("1")))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if project, _ := repo.GetByID(1); project.AvailableCapacity != 800 {
+		t.Errorf("expected the capacity unchanged, got %v", project.AvailableCapacity)
+	}
+
+	rec = serve(t, h.UpdateProject, http.MethodPut, "/", `{"price_per_tonne":-1}`, asAdmin(1, withID("1")))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a negative price, got %d", rec.Code)
+	}
+}
+
+func TestPatchProject(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	rec := serve(t, h.PatchProject, http.MethodPatch, "/?reason=sold+out",
+		`{"available_capacity":0,"project_url":"https://example.com/amazon","image_url":null}`, asAdmin(1, withID("1")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var patched models.Project
+	decode(t, rec, &patched)
+	if patched.AvailableCapacity != 0 || patched.ProjectURL != "https://example.com/amazon" ||
+		patched.TotalCapacity != 1000 || patched.Title != "Amazon Rainforest Protection" {
+		t.Errorf("expected only the patched fields to change, got %+v", patched)
+	}
+
+	revisions, _ := repo.Revisions(1, 1, 0)
+	if len(revisions) != 1 || revisions[0].Reason != "sold out" || revisions[0].Changes["available_capacity"].To != 0.0 {
+		t.Errorf("expected the patch recorded as a revision, got %+v", revisions)
+	}
+
+	tests := []struct {
+		name  string
+		id    string
+		body  string
+		code  int
+		field string
+	}{
+		{"capacity above total", "2", `{"available_capacity":600}`, http.StatusUnprocessableEntity, "available_capacity"},
+		{"cleared required field", "2", `{"title":null}`, http.StatusUnprocessableEntity, "title"},
+		{"negative price", "2", `{"price_per_tonne":-3}`, http.StatusUnprocessableEntity, "price_per_tonne"},
+		{"wrong type", "2", `{"total_capacity":"lots"}`, http.StatusUnprocessableEntity, "total_capacity"},
+		{"status", "2", `{"status":"retired"}`, http.StatusUnprocessableEntity, "status"},
+		{"unknown field", "2", `{"colour":"green"}`, http.StatusUnprocessableEntity, "colour"},
+		{"not an object", "2", `[1]`, http.StatusBadRequest, ""},
+		{"unknown project", "99", `{"title":"Gone"}`, http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h.PatchProject, http.MethodPatch, "/", tt.body, asAdmin(1, withID(tt.id)))
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+			if tt.field != "" {
+				var p problem.Problem
+				decode(t, rec, &p)
+				if len(p.Errors) != 1 || p.Errors[0].Field != tt.field {
+					t.Errorf("expected an error on %s, got %+v", tt.field, p.Errors)
+				}
+			}
+		})
+	}
+
+	if project, _ := repo.GetByID(2); project.AvailableCapacity != 500 || project.Title != "Kenya Clean Cookstoves" {
+		t.Errorf("expected rejected patches to change nothing, got %+v", project)
+	}
+}
+
+func TestDeleteProject(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	rec := serve(t, h.DeleteProject, http.MethodDelete, "/", "", asAdmin(1, withID("1")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	project, _ := repo.GetByID(1)
+	if project.Status != models.StatusRetired {
+		t.Errorf("expected project to be retired, got %q", project.Status)
+	}
+	if rec := serve(t, h.DeleteProject, http.MethodDelete, "/", "", asAdmin(1, withID("1"))); rec.Code != http.StatusConflict {
+		t.Errorf("expected retiring again to conflict, got %d", rec.Code)
+	}
+
+	page, _ := repo.GetAll(&models.ProjectListRequest{Limit: 10})
+	if len(page.Projects) != 2 || page.Total != 2 {
+		t.Errorf("expected deleted project to be hidden from listings, got %d projects", len(page.Projects))
+	}
+}
+
+func TestSearchProjects(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	tests := []struct {
+		name string
+		body string
+		want []uint
+	}{
+		{"text query", `{"query":"wind"}`, []uint{3}},
+		{"category filter", `{"category":["energy"]}`, []uint{2, 3}},
+		{"price range", `{"min_price":6,"max_price":12}`, []uint{1, 2}},
+		{"combined filters", `{"category":["energy"],"country":["Kenya"]}`, []uint{2}},
+		{"no match", `{"query":"geothermal"}`, []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h.SearchProjects, http.MethodPost, "/", tt.body, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			var body struct {
+				Projects []models.Project `json:"projects"`
+				Total    int64            `json:"total"`
+			}
+			decode(t, rec, &body)
+			if body.Total != int64(len(tt.want)) {
+				t.Errorf("expected total %d, got %d", len(tt.want), body.Total)
+			}
+
+			got := []uint{}
+			for _, p := range body.Projects {
+				got = append(got, p.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected projects %v, got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected projects %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+// seedLocations places the seeded projects at their sites and gives the
+// Amazon project a boundary
+func seedLocations(t *testing.T, h *ProjectHandler) {
+	t.Helper()
+	for id, patch := range map[string]string{
+		"1": `{"latitude":-3.47,"longitude":-62.22,"boundary":{"type":"Polygon","coordinates":[[[-63,-4],[-61,-4],[-61,-3],[-63,-3],[-63,-4]]]}}`,
+		"2": `{"latitude":-1.29,"longitude":36.82}`,
+		"3": `{"latitude":22.26,"longitude":71.19}`,
+	} {
+		if rec := serve(t, h.PatchProject, http.MethodPatch, "/", patch, asAdmin(1, withID(id))); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestSearchProjectsGeoFilters(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+	seedLocations(t, h)
+
+	tests := []struct {
+		name string
+		body string
+		want []uint
+	}{
+		{"radius", `{"near":{"lat":-1.3,"lon":36.8,"radius_km":100}}`, []uint{2}},
+		{"bounding box", `{"bbox":{"min_lon":30,"min_lat":-5,"max_lon":80,"max_lat":30}}`, []uint{2, 3}},
+		{"with other filters", `{"bbox":{"min_lon":30,"min_lat":-5,"max_lon":80,"max_lat":30},"country":["India"]}`, []uint{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h.SearchProjects, http.MethodPost, "/", tt.body, nil)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			var body struct {
+				Projects []models.Project `json:"projects"`
+			}
+			decode(t, rec, &body)
+			got := []uint{}
+			for _, p := range body.Projects {
+				got = append(got, p.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected projects %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	rec := serve(t, h.SearchProjects, http.MethodPost, "/", `{"near":{"lat":-1.3,"lon":36.8,"radius_km":0}}`, nil)
+	var p problem.Problem
+	decode(t, rec, &p)
+	if rec.Code != http.StatusUnprocessableEntity || len(p.Errors) != 1 || p.Errors[0].Field != "near.radius_km" {
+		t.Errorf("expected a 422 on near.radius_km, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetProjectsGeoJSON(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+	seedLocations(t, h)
+	unmapped := models.Project{Title: "Unmapped Project", Category: "energy", Status: models.StatusPublished}
+	if err := repo.Create(&unmapped, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+
+	features := func(target string) models.FeatureCollection {
+		t.Helper()
+		rec := serve(t, h.GetProjectsGeoJSON, http.MethodGet, target, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var collection models.FeatureCollection
+		decode(t, rec, &collection)
+		return collection
+	}
+
+	all := features("/")
+	if all.Type != "FeatureCollection" || len(all.Features) != 3 {
+		t.Fatalf("expected the three located projects, got %+v", all)
+	}
+	kenya := all.Features[1]
+	if kenya.Type != "Feature" || kenya.ID != 2 || kenya.Geometry.Type != "Point" ||
+		!reflect.DeepEqual(kenya.Geometry.Coordinates, []interface{}{36.82, -1.29}) ||
+		kenya.Properties.Title != "Kenya Clean Cookstoves" || kenya.Properties.PricePerTonne != 8 {
+		t.Errorf("unexpected feature: %+v", kenya)
+	}
+	if all.Features[0].Geometry.Type != "Point" {
+		t.Errorf("expected points unless boundaries are asked for, got %+v", all.Features[0].Geometry)
+	}
+
+	if boundaries := features("/?boundaries=true"); boundaries.Features[0].Geometry.Type != models.GeometryPolygon ||
+		boundaries.Features[1].Geometry.Type != "Point" {
+		t.Errorf("expected the Amazon boundary and the other points, got %+v", boundaries.Features)
+	}
+	if box := features("/?bbox=30,-5,80,30&category=energy"); len(box.Features) != 2 || box.Features[0].ID != 2 || box.Features[1].ID != 3 {
+		t.Errorf("expected the projects in the box, got %+v", box.Features)
+	}
+	if near := features("/?lat=-3&lon=-62&radius_km=200"); len(near.Features) != 1 || near.Features[0].ID != 1 {
+		t.Errorf("expected the project within the radius, got %+v", near.Features)
+	}
+	if paged := features("/?limit=2"); len(paged.Features) != 2 || paged.NextCursor == "" {
+		t.Errorf("expected a cursor to the rest, got %+v", paged)
+	} else if rest := features("/?cursor=" + url.QueryEscape(paged.NextCursor)); len(rest.Features) != 1 || rest.Features[0].ID != 3 {
+		t.Errorf("expected the last feature on the next page, got %+v", rest.Features)
+	}
+
+	for target, code := range map[string]int{
+		"/?bbox=30,-5,80":               http.StatusBadRequest,
+		"/?bbox=west,-5,80,30":          http.StatusBadRequest,
+		"/?lat=-3&lon=-62":              http.StatusBadRequest,
+		"/?lat=-3&lon=-62&radius_km=-1": http.StatusUnprocessableEntity,
+		"/?bbox=30,10,80,-5":            http.StatusUnprocessableEntity,
+		"/?cursor=nonsense":             http.StatusBadRequest,
+	} {
+		if rec := serve(t, h.GetProjectsGeoJSON, http.MethodGet, target, "", nil); rec.Code != code {
+			t.Errorf("%s: expected %d, got %d: %s", target, code, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestPatchProjectLocation(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+	seedLocations(t, h)
+
+	for body, field := range map[string]string{
+		`{"latitude":-91}`:     "latitude",
+		`{"longitude":null}`:   "longitude",
+		`{"latitude":"north"}`: "latitude",
+		`{"boundary":{"type":"Point","coordinates":[1,2]}}`: "boundary.type",
+	} {
+		rec := serve(t, h.PatchProject, http.MethodPatch, "/", body, asAdmin(1, withID("1")))
+		var p problem.Problem
+		decode(t, rec, &p)
+		if rec.Code != http.StatusUnprocessableEntity || len(p.Errors) != 1 || p.Errors[0].Field != field {
+			t.Errorf("%s: expected a 422 on %s, got %d: %s", body, field, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The boundary merges member by member, so new coordinates keep its type
+	rec := serve(t, h.PatchProject, http.MethodPatch, "/", `{"boundary":{"coordinates":[[[-64,-4],[-61,-4],[-61,-2],[-64,-4]]]}}`,
+		asAdmin(1, withID("1")))
+	var merged models.Project
+	decode(t, rec, &merged)
+	if rec.Code != http.StatusOK || merged.Boundary == nil || merged.Boundary.Type != "Polygon" ||
+		!strings.Contains(rec.Body.String(), `"coordinates":[[[-64,-4],[-61,-4],[-61,-2],[-64,-4]]]`) {
+		t.Errorf("expected the coordinates replaced within the polygon, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(t, h.PatchProject, http.MethodPatch, "/", `{"boundary":{"type":null}}`, asAdmin(1, withID("1"))); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a boundary without a type refused, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(t, h.PatchProject, http.MethodPatch, "/", `{"boundary":null}`, asAdmin(1, withID("1")))
+	var patched models.Project
+	decode(t, rec, &patched)
+	if rec.Code != http.StatusOK || patched.Boundary != nil || patched.Latitude == nil || *patched.Latitude != -3.47 {
+		t.Errorf("expected only the boundary cleared, got %d: %s", rec.Code, rec.Body.String())
+	}
+	revisions, _ := repo.Revisions(1, 1, 0)
+	if _, ok := revisions[0].Changes["boundary"]; !ok || len(revisions[0].Changes) != 1 {
+		t.Errorf("expected the revision to change only the boundary, got %+v", revisions[0].Changes)
+	}
+}
+
+func TestSearchProjectsTotalSpansPages(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	rec := serve(t, h.SearchProjects, http.MethodPost, "/", `{"limit":1,"offset":1}`, nil)
+
+	var body struct {
+		Projects []models.Project `json:"projects"`
+		Total    int64            `json:"total"`
+		Count    int              `json:"count"`
+	}
+	decode(t, rec, &body)
+	if body.Total != 3 || body.Count != 1 || body.Projects[0].ID != 2 {
+		t.Errorf("expected project 2 of 3, got %+v", body)
+	}
+}
+
+func TestSearchProjectsSortAndCursor(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	var body struct {
+		Projects   []models.Project `json:"projects"`
+		NextCursor string           `json:"next_cursor"`
+	}
+	decode(t, serve(t, h.SearchProjects, http.MethodPost, "/", `{"sort":"capacity_desc","limit":1}`, nil), &body)
+	if len(body.Projects) != 1 || body.Projects[0].ID != 3 || body.NextCursor == "" {
+		t.Fatalf("expected project 3 first by capacity, got %+v", body)
+	}
+
+	rec := serve(t, h.SearchProjects, http.MethodPost, "/", `{"sort":"capacity_desc","limit":1,"cursor":"`+body.NextCursor+`"}`, nil)
+	decode(t, rec, &body)
+	if len(body.Projects) != 1 || body.Projects[0].ID != 1 {
+		t.Errorf("expected project 1 on the second page, got %+v", body.Projects)
+	}
+
+	rec = serve(t, h.SearchProjects, http.MethodPost, "/", `{"sort":"price_asc","cursor":"`+body.NextCursor+`"}`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a cursor for another sort to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestSearchProjectsFacets(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	rec := serve(t, h.SearchProjects, http.MethodPost, "/",
+		`{"category":["energy"],"verification_standard":["VCS"]}`, nil)
+
+	var body struct {
+		Total  int64                `json:"total"`
+		Facets models.ProjectFacets `json:"facets"`
+	}
+	decode(t, rec, &body)
+	if body.Total != 1 {
+		t.Fatalf("expected 1 match, got %d", body.Total)
+	}
+
+	wantCategories := []models.FacetCount{{Value: "energy", Count: 1}, {Value: "forestry", Count: 1}}
+	if !reflect.DeepEqual(body.Facets.Category, wantCategories) {
+		t.Errorf("expected %+v, got %+v", wantCategories, body.Facets.Category)
+	}
+	wantStandards := []models.FacetCount{{Value: "Gold Standard", Count: 1}, {Value: "VCS", Count: 1}}
+	if !reflect.DeepEqual(body.Facets.VerificationStandard, wantStandards) {
+		t.Errorf("expected %+v, got %+v", wantStandards, body.Facets.VerificationStandard)
+	}
+	if got := body.Facets.AvailableCapacity; len(got) != len(models.CapacityRanges) || got[1].Key != "1k_to_10k" || got[1].Count != 1 {
+		t.Errorf("unexpected capacity ranges: %+v", got)
+	}
+}
+
+func TestGetProjectFilterValues(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	tests := []struct {
+		name    string
+		handler echo.HandlerFunc
+		key     string
+		want    []string
+	}{
+		{"categories", h.GetProjectCategories, "categories", []string{"energy", "forestry"}},
+		{"regions", h.GetProjectRegions, "regions", []string{"Africa", "Asia", "South America"}},
+		{"countries", h.GetProjectCountries, "countries", []string{"Brazil", "India", "Kenya"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, tt.handler, http.MethodGet, "/", "", nil)
+
+			var body map[string][]string
+			decode(t, rec, &body)
+			if strings.Join(body[tt.key], ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, body[tt.key])
+			}
+		})
+	}
+}
+
+func TestProjectCacheInvalidation(t *testing.T) {
+	h, repo := newTestHandler()
+	h.cache = cache.New(cache.NewMemoryStore())
+	seedProjects(t, repo)
+
+	get := func(handler echo.HandlerFunc, target string, setup func(c echo.Context)) *httptest.ResponseRecorder {
+		return serve(t, handler, http.MethodGet, target, "", setup)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		handler echo.HandlerFunc
+		target  string
+		setup   func(c echo.Context)
+	}{
+		{"detail", h.GetProject, "/", withID("1")},
+		{"listing", h.GetAllProjects, "/?limit=2", nil},
+		{"categories", h.GetProjectCategories, "/", nil},
+	} {
+		if rec := get(tt.handler, tt.target, tt.setup); rec.Header().Get("X-Cache") != "MISS" {
+			t.Errorf("%s: expected a miss first, got %q", tt.name, rec.Header().Get("X-Cache"))
+		}
+		if rec := get(tt.handler, tt.target, tt.setup); rec.Header().Get("X-Cache") != "HIT" {
+			t.Errorf("%s: expected a hit second, got %q", tt.name, rec.Header().Get("X-Cache"))
+		}
+	}
+
+	serve(t, h.UpdateProject, http.MethodPut, "/", `{"title":"Amazon Forest Guardians","category":"conservation"}`, asAdmin(1, withID("1")))
+
+	var project models.Project
+	rec := get(h.GetProject, "/", withID("1"))
+	decode(t, rec, &project)
+	if rec.Header().Get("X-Cache") != "MISS" || project.Title != "Amazon Forest Guardians" {
+		t.Errorf("expected the updated project after invalidation, got %q from %s", project.Title, rec.Header().Get("X-Cache"))
+	}
+
+	var categories struct {
+		Categories []string `json:"categories"`
+	}
+	decode(t, get(h.GetProjectCategories, "/", nil), &categories)
+	if !reflect.DeepEqual(categories.Categories, []string{"conservation", "energy"}) {
+		t.Errorf("expected the catalog to be invalidated, got %v", categories.Categories)
+	}
+}
+
+func TestSearchCacheKeyCoversFilters(t *testing.T) {
+	base := models.ProjectSearchRequest{Query: "forest", Category: []string{"energy", "forestry"},
+		Vintage: []int{2021, 2023}, SDGGoals: []int{7, 13}, Limit: 10}
+
+	reordered := base
+	reordered.Category = []string{"forestry", "energy"}
+	reordered.Vintage = []int{2023, 2021}
+	reordered.SDGGoals = []int{13, 7}
+	if searchCacheKey(&base) != searchCacheKey(&reordered) {
+		t.Error("expected filter order not to change the key")
+	}
+
+	variants := []func(r *models.ProjectSearchRequest){
+		func(r *models.ProjectSearchRequest) { r.Region = []string{"Asia"} },
+		func(r *models.ProjectSearchRequest) { r.Country = []string{"India"} },
+		func(r *models.ProjectSearchRequest) { r.VerificationStandard = []string{"VCS"} },
+		func(r *models.ProjectSearchRequest) { r.MinPrice = 5 },
+		func(r *models.ProjectSearchRequest) { r.MaxPrice = 20 },
+		func(r *models.ProjectSearchRequest) { r.Sort = models.SortPriceAsc },
+		func(r *models.ProjectSearchRequest) { r.Cursor = "abc" },
+		func(r *models.ProjectSearchRequest) { r.Offset = 10 },
+		func(r *models.ProjectSearchRequest) { r.Near = &models.GeoCircle{Lat: 1, Lon: 2, RadiusKm: 3} },
+		func(r *models.ProjectSearchRequest) {
+			r.BBox = &models.GeoBox{MinLon: 1, MinLat: 2, MaxLon: 3, MaxLat: 4}
+		},
+		func(r *models.ProjectSearchRequest) { r.MethodologyID = []string{"VM0033"} },
+		func(r *models.ProjectSearchRequest) { r.CreditType = []string{models.CreditRemoval} },
+		func(r *models.ProjectSearchRequest) { r.SolutionType = []string{models.SolutionNatureBased} },
+		func(r *models.ProjectSearchRequest) { r.SDGGoals = []int{13} },
+		func(r *models.ProjectSearchRequest) { r.Vintage = []int{2022} },
+		func(r *models.ProjectSearchRequest) { r.MinPermanenceYears = 100 },
+		func(r *models.ProjectSearchRequest) { r.MaxBufferPoolPercent = 20 },
+	}
+	for i, vary := range variants {
+		req := base
+		vary(&req)
+		if searchCacheKey(&req) == searchCacheKey(&base) {
+			t.Errorf("variant %d: expected a different key", i)
+		}
+	}
+	if reordered.Category[0] != "forestry" || reordered.Vintage[0] != 2023 || reordered.SDGGoals[0] != 13 {
+		t.Error("expected the request filters to be left unsorted")
+	}
+}
+
+func TestTransitionProject(t *testing.T) {
+	h, repo := newTestHandler()
+	h.SetCache(cache.New(cache.NewMemoryStore()))
+	repo.Create(&models.Project{Title: "Mangrove Restoration"}, models.Change{})
+
+	move := func(adminID uint, body string) *httptest.ResponseRecorder {
+		return serve(t, h.TransitionProject, http.MethodPost, "/", body, asAdmin(adminID, withID("1")))
+	}
+
+	if rec := serve(t, h.GetProject, http.MethodGet, "/", "", withID("1")); rec.Code != http.StatusNotFound {
+		t.Errorf("expected a draft hidden from the public, got %d", rec.Code)
+	}
+	if rec := serve(t, h.GetProjectAdmin, http.MethodGet, "/", "", withID("1")); rec.Code != http.StatusOK {
+		t.Errorf("expected admins to see a draft, got %d", rec.Code)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		admin  uint
+		body   string
+		status int
+		code   string
+	}{
+		{"unknown action", 1, `{"action":"launch"}`, http.StatusUnprocessableEntity, problem.CodeValidationFailed},
+		{"publish a draft", 1, `{"action":"publish"}`, http.StatusConflict, CodeInvalidTransition},
+		{"submit", 1, `{"action":"submit"}`, http.StatusOK, ""},
+		{"approve own submission", 1, `{"action":"approve"}`, http.StatusForbidden, CodeSelfReview},
+		{"reject without a reason", 2, `{"action":"reject","reason":"  "}`, http.StatusUnprocessableEntity, problem.CodeValidationFailed},
+		{"approve", 2, `{"action":"approve"}`, http.StatusOK, ""},
+		{"publish", 1, `{"action":"publish"}`, http.StatusOK, ""},
+	} {
+		rec := move(tt.admin, tt.body)
+		if rec.Code != tt.status {
+			t.Fatalf("%s: expected %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+		if tt.code != "" {
+			var p problem.Problem
+			decode(t, rec, &p)
+			if p.Code != tt.code {
+				t.Errorf("%s: expected code %s, got %q", tt.name, tt.code, p.Code)
+			}
+		}
+	}
+
+	// Warm the cached detail to check the next transition invalidates it
+	if rec := serve(t, h.GetProject, http.MethodGet, "/", "", withID("1")); rec.Code != http.StatusOK {
+		t.Fatalf("expected the published project public, got %d", rec.Code)
+	}
+	move(1, `{"action":"pause"}`)
+	var project models.Project
+	rec := serve(t, h.GetProject, http.MethodGet, "/", "", withID("1"))
+	decode(t, rec, &project)
+	if rec.Header().Get("X-Cache") != "MISS" || project.Status != models.StatusPaused {
+		t.Errorf("expected the paused project after invalidation, got %q from %s", project.Status, rec.Header().Get("X-Cache"))
+	}
+
+	var history struct {
+		Transitions []models.ProjectTransition `json:"transitions"`
+		Count       int                        `json:"count"`
+	}
+	decode(t, serve(t, h.GetProjectTransitions, http.MethodGet, "/", "", withID("1")), &history)
+	if history.Count != 4 || history.Transitions[1].ActorID != 2 || history.Transitions[3].ToStatus != models.StatusPaused {
+		t.Errorf("unexpected transitions: %+v", history.Transitions)
+	}
+
+	if rec := serve(t, h.TransitionProject, http.MethodPost, "/", `{"action":"resume"}`, withID("1")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", rec.Code)
+	}
+	if rec := serve(t, h.GetProjectTransitions, http.MethodGet, "/", "", withID("9")); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing project, got %d", rec.Code)
+	}
+}
+
+func TestProjectRevisions(t *testing.T) {
+	h, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	serve(t, h.UpdateProject, http.MethodPut, "/", `{"price_per_tonne":120,"reason":"registry reissue"}`, asAdmin(7, withID("1")))
+	serve(t, h.DeleteProject, http.MethodDelete, "/?reason=registry+withdrew+it", "", asAdmin(8, withID("1")))
+
+	var history struct {
+		Revisions []models.ProjectRevision `json:"revisions"`
+		Count     int                      `json:"count"`
+	}
+	decode(t, serve(t, h.GetProjectRevisions, http.MethodGet, "/", "", withID("1")), &history)
+	if history.Count != 3 {
+		t.Fatalf("expected create, update and delete revisions, got %+v", history.Revisions)
+	}
+	deleted, updated := history.Revisions[0], history.Revisions[1]
+	if updated.ActorID != 7 || updated.Reason != "registry reissue" || updated.Changes["price_per_tonne"].From != 12.0 {
+		t.Errorf("unexpected update revision: %+v", updated)
+	}
+	if deleted.Operation != models.RevisionDelete || deleted.ActorID != 8 || deleted.Reason != "registry withdrew it" {
+		t.Errorf("unexpected delete revision: %+v", deleted)
+	}
+
+	var asOf struct {
+		RevisionID uint                 `json:"revision_id"`
+		Project    models.ProjectFields `json:"project"`
+	}
+	at := url.QueryEscape(updated.CreatedAt.Format(time.RFC3339Nano))
+	decode(t, serve(t, h.GetProjectAsOf, http.MethodGet, "/?at="+at, "", withID("1")), &asOf)
+	if asOf.RevisionID != updated.ID || asOf.Project.PricePerTonne != 120 || asOf.Project.Status != models.StatusPublished {
+		t.Errorf("expected the project as the update left it, got %+v", asOf)
+	}
+
+	revert := func(revisionID string, body string) *httptest.ResponseRecorder {
+		return serve(t, h.RevertProject, http.MethodPost, "/", body, asAdmin(7, func(c echo.Context) {
+			c.SetParamNames("id", "revisionID")
+			c.SetParamValues("1", revisionID)
+		}))
+	}
+	var project models.Project
+	rec := revert(strconv.Itoa(int(history.Revisions[2].ID)), `{"reason":"price typo"}`)
+	decode(t, rec, &project)
+	if rec.Code != http.StatusOK || project.PricePerTonne != 12 || project.Status != models.StatusRetired {
+		t.Errorf("expected the original price back and the status kept, got %d %+v", rec.Code, project)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		rec    *httptest.ResponseRecorder
+		status int
+	}{
+		{"as-of without a time", serve(t, h.GetProjectAsOf, http.MethodGet, "/", "", withID("1")), http.StatusBadRequest},
+		{"as-of before the project", serve(t, h.GetProjectAsOf, http.MethodGet, "/?at=2000-01-01T00:00:00Z", "", withID("1")), http.StatusNotFound},
+		{"revert to a missing revision", revert("99", ""), http.StatusNotFound},
+		{"revisions of a missing project", serve(t, h.GetProjectRevisions, http.MethodGet, "/", "", withID("9")), http.StatusNotFound},
+	} {
+		if tt.rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, tt.rec.Code, tt.rec.Body.String())
+		}
+	}
+}
+
+func TestRevertProjectBelowSoldCapacity(t *testing.T) {
+	h, repo := newTestHandler()
+	repo.Create(&models.Project{Title: "Mangrove Restoration", Status: models.StatusPublished, PricePerTonne: 10, TotalCapacity: 100, AvailableCapacity: 100}, models.Change{})
+	created, _ := repo.RevisionAt(1, time.Now())
+	repo.Update(1, &models.UpdateProjectRequest{TotalCapacity: 500, AvailableCapacity: 500}, models.Change{})
+	repositories.NewInMemoryReservationRepository(repo).Reserve(1, 0, 150, "order-1", time.Hour)
+
+	rec := serve(t, h.RevertProject, http.MethodPost, "/", "", asAdmin(7, func(c echo.Context) {
+		c.SetParamNames("id", "revisionID")
+		c.SetParamValues("1", strconv.Itoa(int(created.ID)))
+	}))
+	var p problem.Problem
+	decode(t, rec, &p)
+	if rec.Code != http.StatusConflict || p.Code != CodeInsufficientCapacity {
+		t.Errorf("expected 409 %s, got %d %+v", CodeInsufficientCapacity, rec.Code, p)
+	}
+}
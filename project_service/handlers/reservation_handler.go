@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Problem codes for reservation conflicts
+const (
+	CodeInsufficientCapacity = "insufficient_capacity"
+	CodeReservationNotHeld   = "reservation_not_held"
+	CodeReservationExpired   = "reservation_expired"
+)
+
+type ReservationHandler struct {
+	repo       repositories.ReservationRepository
+	cache      *cache.Cache
+	defaultTTL time.Duration
+}
+
+// NewReservationHandler serves capacity reservations, held for defaultTTL
+// unless the request asks otherwise
+func NewReservationHandler(repo repositories.ReservationRepository, defaultTTL time.Duration) *ReservationHandler {
+	return &ReservationHandler{
+		repo:       repo,
+		defaultTTL: defaultTTL,
+	}
+}
+
+// SetCache sets the response cache invalidated when capacity changes
+func (h *ReservationHandler) SetCache(responses *cache.Cache) {
+	h.cache = responses
+}
+
+// ReserveCapacity holds tonnes of a project
+// @Summary Reserve project capacity
+// @Description Hold tonnes of a project's available capacity, typically for a checkout, until the reservation is committed, released or expires. The tonnes leave the available capacity at once, so concurrent reservations cannot oversell the project. While a reservation with the same reference is held or committed, that one is returned instead, so a reservation whose response was lost can be retried. A reservation with a vintage_id holds the tonnes of that vintage's capacity too.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param request body models.ReserveCapacityRequest true "Reservation"
+// @Success 201 {object} models.Reservation "Reservation held"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 404 {object} problem.Problem "Project or vintage not found"
+// @Failure 409 {object} problem.Problem "Insufficient available capacity"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to reserve capacity"
+// @Router /api/v1/projects/admin/reservations [post]
+func (h *ReservationHandler) ReserveCapacity(c echo.Context) error {
+	var req models.ReserveCapacityRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+
+	ttl := h.defaultTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	reservation, err := h.repo.Reserve(req.ProjectID, req.VintageID, req.Tonnes, req.Reference, ttl)
+	if err != nil {
+		return reservationProblem(err, "Failed to reserve capacity")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(uint64(req.ProjectID)))
+	return c.JSON(http.StatusCreated, reservation)
+}
+
+// GetReservation retrieves a reservation
+// @Summary Get reservation
+// @Description Retrieve a capacity reservation by its ID
+// @Tags reservations
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.Reservation "Reservation"
+// @Failure 404 {object} problem.Problem "Reservation not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve reservation"
+// @Router /api/v1/projects/admin/reservations/{id} [get]
+func (h *ReservationHandler) GetReservation(c echo.Context) error {
+	reservation, err := h.repo.Get(c.Param("id"))
+	if err != nil {
+		return reservationProblem(err, "Failed to retrieve reservation")
+	}
+	return c.JSON(http.StatusOK, reservation)
+}
+
+// CommitReservation makes a reservation permanent
+// @Summary Commit reservation
+// @Description Commit a held reservation once its order completes, keeping its tonnes out of the available capacity for good. Committing a committed reservation again is a no-op; a reservation past its expiry is expired and its tonnes returned.
+// @Tags reservations
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.Reservation "Reservation committed"
+// @Failure 404 {object} problem.Problem "Reservation not found"
+// @Failure 409 {object} problem.Problem "Reservation released or expired"
+// @Failure 500 {object} problem.Problem "Failed to commit reservation"
+// @Router /api/v1/projects/admin/reservations/{id}/commit [post]
+func (h *ReservationHandler) CommitReservation(c echo.Context) error {
+	reservation, err := h.repo.Commit(c.Param("id"))
+	if errors.Is(err, repositories.ErrReservationExpired) {
+		invalidate(c, h.cache, catalogNamespace, projectNamespace(uint64(reservation.ProjectID)))
+	}
+	if err != nil {
+		return reservationProblem(err, "Failed to commit reservation")
+	}
+	return c.JSON(http.StatusOK, reservation)
+}
+
+// CommitReservations makes reservations permanent together
+// @Summary Commit reservations
+// @Description Commit the held reservations of an order's lines in one go: all of them, or none when one is no longer held. Reservations past their expiry are expired and their tonnes returned, and none is committed. Committing committed reservations again is a no-op, so a commit whose response was lost can be retried.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param request body models.CommitReservationsRequest true "Reservations"
+// @Success 200 {object} map[string]interface{} "Reservations committed"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 404 {object} problem.Problem "Reservation not found"
+// @Failure 409 {object} problem.Problem "A reservation was released or expired"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to commit reservations"
+// @Router /api/v1/projects/admin/reservations/commit [post]
+func (h *ReservationHandler) CommitReservations(c echo.Context) error {
+	var req models.CommitReservationsRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+
+	reservations, err := h.repo.CommitAll(req.IDs)
+	if errors.Is(err, repositories.ErrReservationExpired) {
+		for _, reservation := range reservations {
+			invalidate(c, h.cache, catalogNamespace, projectNamespace(uint64(reservation.ProjectID)))
+		}
+	}
+	if err != nil {
+		return reservationProblem(err, "Failed to commit reservations")
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"reservations": reservations})
+}
+
+// ReleaseReservation returns a reservation's tonnes
+// @Summary Release reservation
+// @Description Release a held reservation, returning its tonnes to the project's available capacity. Releasing a released or expired reservation again is a no-op.
+// @Tags reservations
+// @Produce json
+// @Security AdminAuth
+// @Param id path string true "Reservation ID"
+// @Success 200 {object} models.Reservation "Reservation released"
+// @Failure 404 {object} problem.Problem "Reservation not found"
+// @Failure 409 {object} problem.Problem "Reservation already committed"
+// @Failure 500 {object} problem.Problem "Failed to release reservation"
+// @Router /api/v1/projects/admin/reservations/{id}/release [post]
+func (h *ReservationHandler) ReleaseReservation(c echo.Context) error {
+	reservation, err := h.repo.Release(c.Param("id"))
+	if err != nil {
+		return reservationProblem(err, "Failed to release reservation")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(uint64(reservation.ProjectID)))
+	return c.JSON(http.StatusOK, reservation)
+}
+
+// GetCapacityLedger lists a project's capacity changes
+// @Summary Get capacity ledger
+// @Description List the reservations, commits, releases and expiries that moved a project's available capacity, newest first
+// @Tags reservations
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param limit query int false "Limit number of results (max 100)" default(50)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} map[string]interface{} "Ledger entries"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 500 {object} problem.Problem "Failed to retrieve ledger"
+// @Router /api/v1/projects/admin/{id}/ledger [get]
+func (h *ReservationHandler) GetCapacityLedger(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	entries, err := h.repo.Ledger(uint(id), limit, offset)
+	if err != nil {
+		return problem.Internal("Failed to retrieve ledger", err)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"limit":   limit,
+		"offset":  offset,
+		"count":   len(entries),
+	})
+}
+
+// GetSales returns a project's sales
+// @Summary Get project sales
+// @Description List the committed reservations of a project, newest first, with the tonnes sold and the number of sales across all pages (admin, or the project's developer through /api/v1/projects/developer/{id}/sales)
+// @Tags reservations
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param limit query int false "Limit number of results (max 100)" default(50)
+// @Param offset query int false "Offset for pagination" default(0)
+// @Success 200 {object} models.ProjectSales "Sales of the project"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 500 {object} problem.Problem "Failed to retrieve sales"
+// @Router /api/v1/projects/admin/{id}/sales [get]
+func (h *ReservationHandler) GetSales(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.QueryParam("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+	offset := 0
+	if o, err := strconv.Atoi(c.QueryParam("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	sales, err := h.repo.Sales(uint(id), limit, offset)
+	if err != nil {
+		return problem.Internal("Failed to retrieve sales", err)
+	}
+
+	return c.JSON(http.StatusOK, sales)
+}
+
+// ExpireDue expires the reservations past their expiry, dropping the cached
+// responses of their projects, and returns how many it expired. Expiries
+// are counted even when a later one fails.
+func (h *ReservationHandler) ExpireDue(ctx context.Context, now time.Time) (int, error) {
+	expired, err := h.repo.ExpireDue(now)
+	if len(expired) > 0 {
+		namespaces := []string{catalogNamespace}
+		for _, reservation := range expired {
+			namespaces = append(namespaces, projectNamespace(uint64(reservation.ProjectID)))
+		}
+		if err := h.cache.Invalidate(ctx, namespaces...); err != nil {
+			log.Printf("Warning: failed to invalidate cache %v: %v", namespaces, err)
+		}
+	}
+	return len(expired), err
+}
+
+// reservationProblem maps a reservation repository error to a problem
+func reservationProblem(err error, detail string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.NotFound("Reservation or project not found")
+	case errors.Is(err, repositories.ErrInsufficientCapacity):
+		return problem.Conflict("Not enough available capacity").WithCode(CodeInsufficientCapacity)
+	case errors.Is(err, repositories.ErrReservationNotHeld):
+		return problem.Conflict("Reservation is no longer held").WithCode(CodeReservationNotHeld)
+	case errors.Is(err, repositories.ErrReservationExpired):
+		return problem.Conflict("Reservation has expired").WithCode(CodeReservationExpired)
+	default:
+		return problem.Internal(detail, err)
+	}
+}
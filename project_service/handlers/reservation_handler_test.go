@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newTestReservationHandler(t *testing.T) (*ReservationHandler, *ProjectHandler) {
+	t.Helper()
+	projects, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	responses := cache.New(cache.NewMemoryStore())
+	projects.SetCache(responses)
+	h := NewReservationHandler(repositories.NewInMemoryReservationRepository(repo), 15*time.Minute)
+	h.SetCache(responses)
+	return h, projects
+}
+
+func TestReserveCapacity(t *testing.T) {
+	h, projects := newTestReservationHandler(t)
+	// Warm the cached project to check the reservation invalidates it
+	serve(t, projects.GetProject, http.MethodGet, "/", "", withID("2"))
+
+	rec := serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":2,"tonnes":120,"reference":"cart-7","ttl_seconds":60}`, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var reservation models.Reservation
+	decode(t, rec, &reservation)
+	if reservation.Status != models.ReservationHeld || reservation.ExpiresAt.Sub(reservation.CreatedAt) != time.Minute {
+		t.Errorf("expected a reservation held for a minute, got %+v", reservation)
+	}
+
+	var project models.Project
+	rec = serve(t, projects.GetProject, http.MethodGet, "/", "", withID("2"))
+	decode(t, rec, &project)
+	if rec.Header().Get("X-Cache") != "MISS" || project.AvailableCapacity != 380 {
+		t.Errorf("expected 380 tonnes left after invalidation, got %v from %s", project.AvailableCapacity, rec.Header().Get("X-Cache"))
+	}
+
+	var p problem.Problem
+	rec = serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":2,"tonnes":400}`, nil)
+	decode(t, rec, &p)
+	if rec.Code != http.StatusConflict || p.Code != CodeInsufficientCapacity {
+		t.Errorf("expected 409 %s, got %d %q", CodeInsufficientCapacity, rec.Code, p.Code)
+	}
+	if rec := serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":9,"tonnes":1}`, nil); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing project, got %d", rec.Code)
+	}
+	if rec := serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":2,"tonnes":-1}`, nil); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for negative tonnes, got %d", rec.Code)
+	}
+}
+
+func TestReservationTransitions(t *testing.T) {
+	h, _ := newTestReservationHandler(t)
+
+	var reservation models.Reservation
+	decode(t, serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":1,"tonnes":50}`, nil), &reservation)
+
+	for _, tt := range []struct {
+		name    string
+		handler echo.HandlerFunc
+		id      string
+		status  int
+		code    string
+	}{
+		{"commit", h.CommitReservation, reservation.ID, http.StatusOK, ""},
+		{"commit again", h.CommitReservation, reservation.ID, http.StatusOK, ""},
+		{"release committed", h.ReleaseReservation, reservation.ID, http.StatusConflict, CodeReservationNotHeld},
+		{"get", h.GetReservation, reservation.ID, http.StatusOK, ""},
+		{"get missing", h.GetReservation, "missing", http.StatusNotFound, "not_found"},
+	} {
+		rec := serve(t, tt.handler, http.MethodPost, "/", "", withID(tt.id))
+		var p problem.Problem
+		if tt.code != "" {
+			decode(t, rec, &p)
+		}
+		if rec.Code != tt.status || p.Code != tt.code {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.status, tt.code, rec.Code, p.Code)
+		}
+	}
+
+	rec := serve(t, h.GetCapacityLedger, http.MethodGet, "/?limit=10", "", withID("1"))
+	var ledger struct {
+		Entries []models.CapacityLedgerEntry `json:"entries"`
+		Count   int                          `json:"count"`
+	}
+	decode(t, rec, &ledger)
+	if ledger.Count != 2 || ledger.Entries[0].Event != models.LedgerCommit || ledger.Entries[1].AvailableAfter != 750 {
+		t.Errorf("expected the reserve and commit in the ledger, got %+v", ledger)
+	}
+}
+
+func TestCommitReservations(t *testing.T) {
+	h, _ := newTestReservationHandler(t)
+
+	var first, second models.Reservation
+	decode(t, serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":1,"tonnes":50,"reference":"order-1"}`, nil), &first)
+	decode(t, serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":2,"tonnes":20,"reference":"order-2"}`, nil), &second)
+	body := `{"ids":["` + first.ID + `","` + second.ID + `"]}`
+
+	for i := 0; i < 2; i++ {
+		rec := serve(t, h.CommitReservations, http.MethodPost, "/", body, nil)
+		var committed struct {
+			Reservations []models.Reservation `json:"reservations"`
+		}
+		decode(t, rec, &committed)
+		if rec.Code != http.StatusOK || len(committed.Reservations) != 2 || committed.Reservations[1].Status != models.ReservationCommitted {
+			t.Errorf("commit %d: expected both committed, got %d %+v", i+1, rec.Code, committed)
+		}
+	}
+
+	var p problem.Problem
+	rec := serve(t, h.CommitReservations, http.MethodPost, "/", `{"ids":["missing"]}`, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing reservation, got %d", rec.Code)
+	}
+	rec = serve(t, h.CommitReservations, http.MethodPost, "/", `{"ids":[]}`, nil)
+	decode(t, rec, &p)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 without reservations, got %d %+v", rec.Code, p)
+	}
+}
+
+func TestGetSales(t *testing.T) {
+	h, _ := newTestReservationHandler(t)
+
+	var committed models.Reservation
+	decode(t, serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":2,"tonnes":40}`, nil), &committed)
+	serve(t, h.CommitReservation, http.MethodPost, "/", "", withID(committed.ID))
+	serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":2,"tonnes":10}`, nil)
+
+	rec := serve(t, h.GetSales, http.MethodGet, "/", "", withID("2"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var sales models.ProjectSales
+	decode(t, rec, &sales)
+	if sales.ProjectID != 2 || sales.TonnesSold != 40 || sales.SaleCount != 1 || sales.Sales[0].ID != committed.ID {
+		t.Errorf("expected the committed reservation as the only sale, got %+v", sales)
+	}
+
+	if rec := serve(t, h.GetSales, http.MethodGet, "/", "", withID("abc")); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid project ID, got %d", rec.Code)
+	}
+}
+
+func TestExpireDueReservations(t *testing.T) {
+	h, projects := newTestReservationHandler(t)
+
+	var reservation models.Reservation
+	decode(t, serve(t, h.ReserveCapacity, http.MethodPost, "/", `{"project_id":3,"tonnes":500,"ttl_seconds":1}`, nil), &reservation)
+	serve(t, projects.GetProject, http.MethodGet, "/", "", withID("3"))
+
+	expired, err := h.ExpireDue(context.Background(), time.Now().Add(time.Minute))
+	if err != nil || expired != 1 {
+		t.Fatalf("expected one reservation expired, got %d, %v", expired, err)
+	}
+
+	var project models.Project
+	decode(t, serve(t, projects.GetProject, http.MethodGet, "/", "", withID("3")), &project)
+	if project.AvailableCapacity != 1500 {
+		t.Errorf("expected the expired tonnes back in the cached project, got %v", project.AvailableCapacity)
+	}
+
+	var p problem.Problem
+	rec := serve(t, h.CommitReservation, http.MethodPost, "/", "", withID(reservation.ID))
+	decode(t, rec, &p)
+	if rec.Code != http.StatusConflict || p.Code != CodeReservationNotHeld {
+		t.Errorf("expected 409 %s committing an expired reservation, got %d %q", CodeReservationNotHeld, rec.Code, p.Code)
+	}
+}
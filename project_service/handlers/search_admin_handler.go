@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"project_service/search"
+	"shared/problem"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SearchAdminHandler struct {
+	index search.Maintainer
+}
+
+// NewSearchAdminHandler creates the search index admin handler. index is
+// nil when Elasticsearch is not configured.
+func NewSearchAdminHandler(index search.Maintainer) *SearchAdminHandler {
+	return &SearchAdminHandler{
+		index: index,
+	}
+}
+
+// ReindexProjects rebuilds the search index
+// @Summary Rebuild the search index
+// @Description Bulk index all published projects into a new versioned index and atomically swap the projects alias onto it (admin only)
+// @Tags search
+// @Produce json
+// @Security AdminAuth
+// @Success 200 {object} search.ReindexReport "Reindex report"
+// @Failure 500 {object} problem.Problem "Reindex failed"
+// @Failure 503 {object} problem.Problem "Elasticsearch is not configured"
+// @Router /api/v1/projects/admin/search/reindex [post]
+func (h *SearchAdminHandler) ReindexProjects(c echo.Context) error {
+	if h.index == nil {
+		return notConfigured()
+	}
+
+	// A reindex left half done would only leave an unused index behind, but
+	// finishing it is still better than abandoning it with the client
+	report, err := h.index.Reindex(context.WithoutCancel(c.Request().Context()))
+	if err != nil {
+		return problem.Internal("Reindex failed", err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// CheckSearchIndex compares the search index with the database
+// @Summary Check search index consistency
+// @Description Report published projects missing from or stale in the search index, and documents for projects that are no longer published (admin only)
+// @Tags search
+// @Produce json
+// @Security AdminAuth
+// @Success 200 {object} search.ConsistencyReport "Consistency report"
+// @Failure 500 {object} problem.Problem "Consistency check failed"
+// @Failure 503 {object} problem.Problem "Elasticsearch is not configured"
+// @Router /api/v1/projects/admin/search/consistency [get]
+func (h *SearchAdminHandler) CheckSearchIndex(c echo.Context) error {
+	if h.index == nil {
+		return notConfigured()
+	}
+
+	report, err := h.index.Check(c.Request().Context())
+	if err != nil {
+		return problem.Internal("Consistency check failed", err)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+func notConfigured() error {
+	return problem.New(http.StatusServiceUnavailable, "Elasticsearch is not configured")
+}
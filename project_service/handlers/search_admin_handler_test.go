@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"project_service/search"
+	"testing"
+)
+
+type stubMaintainer struct {
+	err error
+}
+
+func (s *stubMaintainer) Reindex(ctx context.Context) (*search.ReindexReport, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &search.ReindexReport{Index: "projects-v2", Indexed: 3, Consistency: &search.ConsistencyReport{}}, nil
+}
+
+func (s *stubMaintainer) Check(ctx context.Context) (*search.ConsistencyReport, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &search.ConsistencyReport{Index: "projects-v2", Projects: 3, Documents: 2, Missing: []uint{7}}, nil
+}
+
+func TestReindexProjects(t *testing.T) {
+	tests := []struct {
+		name  string
+		index search.Maintainer
+		want  int
+	}{
+		{"reindexed", &stubMaintainer{}, http.StatusOK},
+		{"failed", &stubMaintainer{err: errors.New("cluster unavailable")}, http.StatusInternalServerError},
+		{"not configured", nil, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewSearchAdminHandler(tt.index)
+			if rec := serve(t, h.ReindexProjects, http.MethodPost, "/", "", nil); rec.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestCheckSearchIndex(t *testing.T) {
+	h := NewSearchAdminHandler(&stubMaintainer{})
+
+	rec := serve(t, h.CheckSearchIndex, http.MethodGet, "/", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var report search.ConsistencyReport
+	decode(t, rec, &report)
+	if report.Documents != 2 || len(report.Missing) != 1 || report.Missing[0] != 7 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// Problem codes of serial blocks
+const (
+	CodeSerialOverlap       = "serial_overlap"
+	CodeInsufficientSerials = "insufficient_serials"
+)
+
+type SerialHandler struct {
+	repo repositories.SerialRepository
+}
+
+func NewSerialHandler(repo repositories.SerialRepository) *SerialHandler {
+	return &SerialHandler{repo: repo}
+}
+
+// IssueSerials records a block of serials issued for a vintage
+// @Summary Issue serial block
+// @Description Record a block of registry serial numbers, one per tonne, issued for a vintage of the project (admin only). Serials run from prefix-start to prefix-end inclusive and may not overlap a block already issued under the same prefix.
+// @Tags serials
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param request body models.IssueSerialsRequest true "Serial block"
+// @Success 201 {object} models.SerialBlockResponse "Serial block issued"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 404 {object} problem.Problem "Project or vintage not found"
+// @Failure 409 {object} problem.Problem "The block overlaps one already issued"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to issue serials"
+// @Router /api/v1/projects/admin/{id}/serials [post]
+func (h *SerialHandler) IssueSerials(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	var req models.IssueSerialsRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+
+	block := &models.SerialBlock{
+		VintageID: req.VintageID,
+		Registry:  req.Registry,
+		Prefix:    req.Prefix,
+		Start:     req.Start,
+		End:       req.End,
+	}
+	if err := h.repo.Issue(uint(projectID), block); err != nil {
+		return serialProblem(err, "Failed to issue serials")
+	}
+	return c.JSON(http.StatusCreated, models.NewSerialBlockResponse(*block))
+}
+
+// ListSerials lists the serial blocks of a project
+// @Summary List serial blocks
+// @Description List a project's serial blocks in serial order, with the order each allocated block went to (admin only)
+// @Tags serials
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param status query string false "Only available or allocated blocks" Enums(available, allocated)
+// @Success 200 {object} map[string]interface{} "Serial blocks"
+// @Failure 400 {object} problem.Problem "Invalid project ID or status"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve serials"
+// @Router /api/v1/projects/admin/{id}/serials [get]
+func (h *SerialHandler) ListSerials(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+	status := c.QueryParam("status")
+	if status != "" && status != models.SerialAvailable && status != models.SerialAllocated {
+		return problem.BadRequest("status must be available or allocated")
+	}
+
+	blocks, err := h.repo.List(uint(projectID), status)
+	if err != nil {
+		return serialProblem(err, "Failed to retrieve serials")
+	}
+
+	var available, allocated int64
+	responses := make([]models.SerialBlockResponse, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Status() == models.SerialAvailable {
+			available += block.Quantity()
+		} else {
+			allocated += block.Quantity()
+		}
+		responses = append(responses, models.NewSerialBlockResponse(block))
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"blocks":    responses,
+		"available": available,
+		"allocated": allocated,
+	})
+}
+
+// AllocateSerials allocates serials to a completed order
+// @Summary Allocate serials to an order
+// @Description Allocate one serial per tonne of a completed order, from the given vintage or else the oldest vintages first, lowest serials first (admin only). A block only partly needed is split and the rest stays available. Allocating for an order that already has serials returns them unchanged with 200.
+// @Tags serials
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param request body models.AllocateSerialsRequest true "Order to allocate serials to"
+// @Success 201 {object} models.SerialAllocation "Serials allocated"
+// @Success 200 {object} models.SerialAllocation "Serials the order already had"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 409 {object} problem.Problem "Not enough available serials"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to allocate serials"
+// @Router /api/v1/projects/admin/serials/allocations [post]
+func (h *SerialHandler) AllocateSerials(c echo.Context) error {
+	var req models.AllocateSerialsRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+	if req.Tonnes != math.Trunc(req.Tonnes) {
+		return problem.Validation(problem.FieldError{Field: "tonnes", Message: "must be a whole number, one serial per tonne"})
+	}
+
+	blocks, created, err := h.repo.Allocate(req.OrderID, req.ProjectID, req.VintageID, int64(req.Tonnes))
+	if err != nil {
+		return serialProblem(err, "Failed to allocate serials")
+	}
+
+	status := http.StatusCreated
+	if !created {
+		status = http.StatusOK
+	}
+	return c.JSON(status, models.NewSerialAllocation(req.OrderID, blocks))
+}
+
+// GetOrderSerials returns the serials allocated to an order
+// @Summary Get an order's serials
+// @Description Look up the registry serials allocated to an order: those allocated to the order ID itself and to each of its lines, allocated as <orderID>-<line>. Each block carries the line's order ID.
+// @Tags serials
+// @Produce json
+// @Param orderID path string true "Order ID"
+// @Success 200 {object} models.SerialAllocation "Serials of the order"
+// @Failure 404 {object} problem.Problem "No serials allocated to the order"
+// @Failure 500 {object} problem.Problem "Failed to retrieve serials"
+// @Router /api/v1/projects/serials/orders/{orderID} [get]
+func (h *SerialHandler) GetOrderSerials(c echo.Context) error {
+	orderID := c.Param("orderID")
+	blocks, err := h.repo.ByOrder(orderID)
+	if err != nil {
+		return problem.Internal("Failed to retrieve serials", err)
+	}
+	if len(blocks) == 0 {
+		return problem.NotFound("No serials allocated to the order")
+	}
+	return c.JSON(http.StatusOK, models.NewSerialAllocation(orderID, blocks))
+}
+
+// LookupSerial finds the block, and the order, of a serial
+// @Summary Look up a serial
+// @Description Find the block holding a registry serial, such as VCS-VCU-1234-2021-1001, and the order it was allocated to, if any
+// @Tags serials
+// @Produce json
+// @Param serial path string true "Serial number: the block prefix and the number"
+// @Success 200 {object} map[string]interface{} "The serial's block and order"
+// @Failure 400 {object} problem.Problem "Malformed serial"
+// @Failure 404 {object} problem.Problem "Serial not issued"
+// @Failure 500 {object} problem.Problem "Failed to look up serial"
+// @Router /api/v1/projects/serials/{serial} [get]
+func (h *SerialHandler) LookupSerial(c echo.Context) error {
+	serial := c.Param("serial")
+	prefix, number, err := models.ParseSerial(serial)
+	if err != nil {
+		return problem.BadRequest(err.Error())
+	}
+
+	block, err := h.repo.BySerial(prefix, number)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return problem.NotFound("Serial not issued")
+	}
+	if err != nil {
+		return problem.Internal("Failed to look up serial", err)
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"serial":   serial,
+		"order_id": block.OrderID,
+		"block":    models.NewSerialBlockResponse(*block),
+	})
+}
+
+// serialProblem maps a serial repository error to a problem
+func serialProblem(err error, detail string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.NotFound("Project or vintage not found")
+	case errors.Is(err, repositories.ErrSerialRange):
+		return problem.Validation(problem.FieldError{Field: "end", Message: "must not be below start"})
+	case errors.Is(err, repositories.ErrSerialOverlap):
+		return problem.Conflict("The block overlaps serials already issued under the prefix").WithCode(CodeSerialOverlap)
+	case errors.Is(err, repositories.ErrInsufficientSerials):
+		return problem.Conflict("The project has too few available serials").WithCode(CodeInsufficientSerials)
+	default:
+		return problem.Internal(detail, err)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newTestSerialHandler(t *testing.T) *SerialHandler {
+	t.Helper()
+	_, repo := newTestHandler()
+	seedProjects(t, repo)
+	if err := repositories.NewInMemoryVintageRepository(repo).Create(1, &models.Vintage{Year: 2021, PricePerTonne: 12}); err != nil {
+		t.Fatal(err)
+	}
+	return NewSerialHandler(repositories.NewInMemorySerialRepository(repo))
+}
+
+func withParam(name, value string) func(c echo.Context) {
+	return func(c echo.Context) {
+		c.SetParamNames(name)
+		c.SetParamValues(value)
+	}
+}
+
+func TestIssueSerials(t *testing.T) {
+	h := newTestSerialHandler(t)
+
+	body := `{"vintage_id":1,"registry":"Verra","prefix":"VCS-981-2021","start":1001,"end":1500}`
+	rec := serve(t, h.IssueSerials, http.MethodPost, "/", body, withID("1"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var block models.SerialBlockResponse
+	decode(t, rec, &block)
+	if block.Quantity != 500 || block.Status != models.SerialAvailable || block.Serials != "VCS-981-2021-1001-1500" {
+		t.Errorf("unexpected block: %+v", block)
+	}
+
+	var p problem.Problem
+	rec = serve(t, h.IssueSerials, http.MethodPost, "/", `{"vintage_id":1,"registry":"Verra","prefix":"VCS-981-2021","start":1500,"end":1600}`, withID("1"))
+	decode(t, rec, &p)
+	if rec.Code != http.StatusConflict || p.Code != CodeSerialOverlap {
+		t.Errorf("expected 409 %s, got %d %q", CodeSerialOverlap, rec.Code, p.Code)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		project string
+		body    string
+		status  int
+	}{
+		{"reversed range", "1", `{"vintage_id":1,"registry":"Verra","prefix":"VCS-981-2020","start":20,"end":10}`, http.StatusUnprocessableEntity},
+		{"prefix with a slash", "1", `{"vintage_id":1,"registry":"Verra","prefix":"VCS/981","start":1,"end":10}`, http.StatusUnprocessableEntity},
+		{"vintage of another project", "2", `{"vintage_id":1,"registry":"Verra","prefix":"GS-1","start":1,"end":10}`, http.StatusNotFound},
+	} {
+		if rec := serve(t, h.IssueSerials, http.MethodPost, "/", tt.body, withID(tt.project)); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestAllocateSerialsAndLookups(t *testing.T) {
+	h := newTestSerialHandler(t)
+	serve(t, h.IssueSerials, http.MethodPost, "/", `{"vintage_id":1,"registry":"Verra","prefix":"VCS-981-2021","start":1001,"end":1500}`, withID("1"))
+
+	body := `{"order_id":"65f1c0ffee","project_id":1,"tonnes":120}`
+	rec := serve(t, h.AllocateSerials, http.MethodPost, "/", body, nil)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var allocation models.SerialAllocation
+	decode(t, rec, &allocation)
+	if allocation.Quantity != 120 || len(allocation.Blocks) != 1 || allocation.Blocks[0].Serials != "VCS-981-2021-1001-1120" {
+		t.Fatalf("unexpected allocation: %+v", allocation)
+	}
+	if rec := serve(t, h.AllocateSerials, http.MethodPost, "/", body, nil); rec.Code != http.StatusOK {
+		t.Errorf("expected a repeated allocation to return 200, got %d", rec.Code)
+	}
+
+	var listed struct {
+		Blocks    []models.SerialBlockResponse `json:"blocks"`
+		Available int64                        `json:"available"`
+		Allocated int64                        `json:"allocated"`
+	}
+	decode(t, serve(t, h.ListSerials, http.MethodGet, "/", "", withID("1")), &listed)
+	if len(listed.Blocks) != 2 || listed.Available != 380 || listed.Allocated != 120 {
+		t.Errorf("expected the block split in two, got %+v", listed)
+	}
+
+	var found struct {
+		OrderID string                     `json:"order_id"`
+		Block   models.SerialBlockResponse `json:"block"`
+	}
+	decode(t, serve(t, h.LookupSerial, http.MethodGet, "/", "", withParam("serial", "VCS-981-2021-1050")), &found)
+	if found.OrderID != "65f1c0ffee" || found.Block.Start != 1001 {
+		t.Errorf("expected serial 1050 in the order's block, got %+v", found)
+	}
+	decode(t, serve(t, h.GetOrderSerials, http.MethodGet, "/", "", withParam("orderID", "65f1c0ffee")), &allocation)
+	if allocation.Quantity != 120 {
+		t.Errorf("expected the order's 120 serials, got %+v", allocation)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		handler echo.HandlerFunc
+		body    string
+		setup   func(c echo.Context)
+		status  int
+	}{
+		{"fractional tonnes", h.AllocateSerials, `{"order_id":"o-2","project_id":1,"tonnes":2.5}`, nil, http.StatusUnprocessableEntity},
+		{"too few serials", h.AllocateSerials, `{"order_id":"o-2","project_id":1,"tonnes":381}`, nil, http.StatusConflict},
+		{"missing project", h.AllocateSerials, `{"order_id":"o-2","project_id":9,"tonnes":1}`, nil, http.StatusNotFound},
+		{"malformed serial", h.LookupSerial, "", withParam("serial", "1050"), http.StatusBadRequest},
+		{"unissued serial", h.LookupSerial, "", withParam("serial", "VCS-981-2021-9999"), http.StatusNotFound},
+		{"order without serials", h.GetOrderSerials, "", withParam("orderID", "o-2"), http.StatusNotFound},
+	} {
+		if rec := serve(t, tt.handler, http.MethodPost, "/", tt.body, tt.setup); rec.Code != tt.status {
+			t.Errorf("%s: expected %d, got %d: %s", tt.name, tt.status, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"project_service/models"
+	"shared/problem"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON names
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	// A boundary's coordinates must make polygons of its type; any other
+	// type is reported by its own rule
+	v.RegisterStructValidation(func(sl validator.StructLevel) {
+		geometry := sl.Current().Interface().(models.Geometry)
+		polygonal := geometry.Type == models.GeometryPolygon || geometry.Type == models.GeometryMultiPolygon
+		if polygonal && !geometry.ValidPolygons() {
+			sl.ReportError(geometry.Coordinates, "coordinates", "Coordinates", "polygon", "")
+		}
+	}, models.Geometry{})
+
+	return v
+}
+
+// validateRequest validates a bound request and returns a 422 problem with
+// field-level details when it is invalid
+func validateRequest(request interface{}) error {
+	fieldErrors, err := fieldErrorsOf(request)
+	if err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if len(fieldErrors) > 0 {
+		return problem.Validation(fieldErrors...)
+	}
+	return nil
+}
+
+// fieldErrorsOf validates a request and returns why each invalid field was
+// rejected, or none when it is valid
+func fieldErrorsOf(request interface{}) ([]problem.FieldError, error) {
+	err := validate.Struct(request)
+	if err == nil {
+		return nil, nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil, err
+	}
+
+	// Nested fields are reported by their path below the request, such as
+	// boundary.coordinates
+	fieldErrors := make([]problem.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		field := fe.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		fieldErrors = append(fieldErrors, problem.FieldError{
+			Field:   field,
+			Message: fieldErrorMessage(fe),
+		})
+	}
+	return fieldErrors, nil
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_with":
+		names := strings.Fields(fe.Param())
+		for i, name := range names {
+			names[i] = snakeCase(name)
+		}
+		return "is required with " + strings.Join(names, " or ")
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", fe.Param())
+	case "gte", "min":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "ltefield":
+		return fmt.Sprintf("must not exceed %s", snakeCase(fe.Param()))
+	case "gtfield":
+		return fmt.Sprintf("must be after %s", snakeCase(fe.Param()))
+	case "gtefield":
+		return fmt.Sprintf("must be at least %s", snakeCase(fe.Param()))
+	case "polygon":
+		return "must be closed rings of at least four [longitude, latitude] positions"
+	case "unique":
+		return "must not repeat a value"
+	case "url":
+		return "must be a URL"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.Join(strings.Fields(fe.Param()), ", "))
+	default:
+		return "is invalid"
+	}
+}
+
+// snakeCase turns the Go name of a field a rule compares against into its
+// JSON name
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// CodeDuplicateVintage is the problem code for adding a second vintage of
+// the same year to a project
+const CodeDuplicateVintage = "duplicate_vintage"
+
+type VintageHandler struct {
+	repo  repositories.VintageRepository
+	cache *cache.Cache
+}
+
+func NewVintageHandler(repo repositories.VintageRepository) *VintageHandler {
+	return &VintageHandler{repo: repo}
+}
+
+// SetCache caches vintage listings and invalidates the project's cached
+// responses when its vintages change
+func (h *VintageHandler) SetCache(responses *cache.Cache) {
+	h.cache = responses
+}
+
+// ListVintages lists a project's vintages
+// @Summary List project vintages
+// @Description List the vintages of a project by year, each with its own price and capacity. Unreleased projects are not found unless the caller sends the token of an admin or the project's developer.
+// @Tags vintages
+// @Produce json
+// @Param id path int true "Project ID"
+// @Success 200 {object} map[string]interface{} "Project vintages"
+// @Failure 400 {object} problem.Problem "Invalid project ID"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve vintages"
+// @Router /api/v1/projects/{id}/vintages [get]
+func (h *VintageHandler) ListVintages(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	return cached(c, h.cache, projectNamespace(projectID), "vintages", projectTTL, func() (interface{}, error) {
+		vintages, err := h.repo.List(uint(projectID))
+		if err != nil {
+			return nil, vintageProblem(err, "Failed to retrieve vintages")
+		}
+		return map[string]interface{}{"vintages": vintages}, nil
+	})
+}
+
+// GetVintage retrieves a vintage of a project
+// @Summary Get project vintage
+// @Description Retrieve a vintage of a project by its ID
+// @Tags vintages
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param vintageID path int true "Vintage ID"
+// @Success 200 {object} models.Vintage "Vintage"
+// @Failure 400 {object} problem.Problem "Invalid project or vintage ID"
+// @Failure 404 {object} problem.Problem "Project or vintage not found"
+// @Failure 500 {object} problem.Problem "Failed to retrieve vintage"
+// @Router /api/v1/projects/admin/{id}/vintages/{vintageID} [get]
+func (h *VintageHandler) GetVintage(c echo.Context) error {
+	projectID, vintageID, err := vintageIDs(c)
+	if err != nil {
+		return err
+	}
+
+	vintage, err := h.repo.Get(projectID, vintageID)
+	if err != nil {
+		return vintageProblem(err, "Failed to retrieve vintage")
+	}
+	return c.JSON(http.StatusOK, vintage)
+}
+
+// CreateVintage adds a vintage to a project
+// @Summary Create project vintage
+// @Description Add the credits a project issued for a year, with their price and capacity. A project has at most one vintage per year.
+// @Tags vintages
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param request body models.CreateVintageRequest true "Vintage"
+// @Success 201 {object} models.Vintage "Vintage created"
+// @Failure 400 {object} problem.Problem "Invalid project ID or request body"
+// @Failure 404 {object} problem.Problem "Project not found"
+// @Failure 409 {object} problem.Problem "The project already has a vintage of that year"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to create vintage"
+// @Router /api/v1/projects/admin/{id}/vintages [post]
+func (h *VintageHandler) CreateVintage(c echo.Context) error {
+	projectID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return problem.BadRequest("Invalid project ID")
+	}
+
+	var req models.CreateVintageRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+
+	vintage := &models.Vintage{
+		Year:              req.Year,
+		PricePerTonne:     req.PricePerTonne,
+		TotalCapacity:     req.TotalCapacity,
+		AvailableCapacity: req.AvailableCapacity,
+		IssuanceDate:      req.IssuanceDate,
+	}
+	if err := h.repo.Create(uint(projectID), vintage); err != nil {
+		return vintageProblem(err, "Failed to create vintage")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(projectID))
+	return c.JSON(http.StatusCreated, vintage)
+}
+
+// UpdateVintage updates a vintage of a project
+// @Summary Update project vintage
+// @Description Update the price, capacity or issuance date of a vintage. Only the fields given change; the year cannot.
+// @Tags vintages
+// @Accept json
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param vintageID path int true "Vintage ID"
+// @Param request body models.UpdateVintageRequest true "Vintage fields to update"
+// @Success 200 {object} models.Vintage "Vintage updated"
+// @Failure 400 {object} problem.Problem "Invalid project or vintage ID, or request body"
+// @Failure 404 {object} problem.Problem "Project or vintage not found"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to update vintage"
+// @Router /api/v1/projects/admin/{id}/vintages/{vintageID} [put]
+func (h *VintageHandler) UpdateVintage(c echo.Context) error {
+	projectID, vintageID, err := vintageIDs(c)
+	if err != nil {
+		return err
+	}
+
+	var req models.UpdateVintageRequest
+	if err := c.Bind(&req); err != nil {
+		return problem.BadRequest("Invalid request body")
+	}
+	if err := validateRequest(&req); err != nil {
+		return err
+	}
+
+	vintage, err := h.repo.Update(projectID, vintageID, &req)
+	if err != nil {
+		return vintageProblem(err, "Failed to update vintage")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(uint64(projectID)))
+	return c.JSON(http.StatusOK, vintage)
+}
+
+// DeleteVintage removes a vintage from a project
+// @Summary Delete project vintage
+// @Description Remove a vintage from a project
+// @Tags vintages
+// @Produce json
+// @Security AdminAuth
+// @Param id path int true "Project ID"
+// @Param vintageID path int true "Vintage ID"
+// @Success 200 {object} map[string]string "Vintage deleted successfully"
+// @Failure 400 {object} problem.Problem "Invalid project or vintage ID"
+// @Failure 404 {object} problem.Problem "Project or vintage not found"
+// @Failure 500 {object} problem.Problem "Failed to delete vintage"
+// @Router /api/v1/projects/admin/{id}/vintages/{vintageID} [delete]
+func (h *VintageHandler) DeleteVintage(c echo.Context) error {
+	projectID, vintageID, err := vintageIDs(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.repo.Delete(projectID, vintageID); err != nil {
+		return vintageProblem(err, "Failed to delete vintage")
+	}
+
+	invalidate(c, h.cache, catalogNamespace, projectNamespace(uint64(projectID)))
+	return c.JSON(http.StatusOK, map[string]string{"message": "Vintage deleted successfully"})
+}
+
+// vintageIDs parses the project and vintage IDs of a vintage route
+func vintageIDs(c echo.Context) (projectID, vintageID uint, err error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, 0, problem.BadRequest("Invalid project ID")
+	}
+	vid, err := strconv.ParseUint(c.Param("vintageID"), 10, 32)
+	if err != nil {
+		return 0, 0, problem.BadRequest("Invalid vintage ID")
+	}
+	return uint(id), uint(vid), nil
+}
+
+// vintageProblem maps a vintage repository error to a problem
+func vintageProblem(err error, detail string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return problem.NotFound("Project or vintage not found")
+	case errors.Is(err, repositories.ErrDuplicateVintage):
+		return problem.Conflict("The project already has a vintage of that year").WithCode(CodeDuplicateVintage)
+	case errors.Is(err, repositories.ErrVintageCapacity):
+		return problem.Validation(problem.FieldError{Field: "available_capacity", Message: "must not exceed total_capacity"})
+	default:
+		return problem.Internal(detail, err)
+	}
+}
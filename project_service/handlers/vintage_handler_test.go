@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"project_service/cache"
+	"project_service/models"
+	"project_service/repositories"
+	"shared/problem"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newTestVintageHandler(t *testing.T) (*VintageHandler, *ProjectHandler) {
+	t.Helper()
+	projects, repo := newTestHandler()
+	seedProjects(t, repo)
+
+	responses := cache.New(cache.NewMemoryStore())
+	projects.SetCache(responses)
+	h := NewVintageHandler(repositories.NewInMemoryVintageRepository(repo))
+	h.SetCache(responses)
+	return h, projects
+}
+
+func withVintage(id, vintageID string) func(c echo.Context) {
+	return func(c echo.Context) {
+		c.SetParamNames("id", "vintageID")
+		c.SetParamValues(id, vintageID)
+	}
+}
+
+func TestCreateVintage(t *testing.T) {
+	h, projects := newTestVintageHandler(t)
+	// Warm the cached project to check the new vintage invalidates it
+	serve(t, projects.GetProject, http.MethodGet, "/", "", withID("1"))
+
+	body := `{"year":2021,"price_per_tonne":14,"total_capacity":300,"available_capacity":250}`
+	rec := serve(t, h.CreateVintage, http.MethodPost, "/", body, withID("1"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var vintage models.Vintage
+	decode(t, rec, &vintage)
+	if vintage.ID == 0 || vintage.ProjectID != 1 || vintage.Year != 2021 || vintage.PricePerTonne != 14 {
+		t.Errorf("unexpected vintage: %+v", vintage)
+	}
+
+	var project models.Project
+	rec = serve(t, projects.GetProject, http.MethodGet, "/", "", withID("1"))
+	decode(t, rec, &project)
+	if rec.Header().Get("X-Cache") != "MISS" || len(project.Vintages) != 1 {
+		t.Errorf("expected the project with its vintage after invalidation, got %+v from %s", project.Vintages, rec.Header().Get("X-Cache"))
+	}
+
+	var p problem.Problem
+	rec = serve(t, h.CreateVintage, http.MethodPost, "/", body, withID("1"))
+	decode(t, rec, &p)
+	if rec.Code != http.StatusConflict || p.Code != CodeDuplicateVintage {
+		t.Errorf("expected 409 %s, got %d %q", CodeDuplicateVintage, rec.Code, p.Code)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		id     string
+		body   string
+		status int
+	}{
+		{"missing project", "9", `{"year":2021,"price_per_tonne":14}`, http.StatusNotFound},
+		{"invalid project ID", "x", `{"year":2021,"price_per_tonne":14}`, http.StatusBadRequest},
+		{"year out of range", "1", `{"year":1800,"price_per_tonne":14}`, http.StatusUnprocessableEntity},
+		{"missing price", "1", `{"year":2022}`, http.StatusUnprocessableEntity},
+		{"available over total", "1", `{"year":2022,"price_per_tonne":14,"total_capacity":10,"available_capacity":20}`, http.StatusUnprocessableEntity},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, h.CreateVintage, http.MethodPost, "/", tt.body, withID(tt.id)); rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestVintageLifecycle(t *testing.T) {
+	h, _ := newTestVintageHandler(t)
+
+	var vintage models.Vintage
+	decode(t, serve(t, h.CreateVintage, http.MethodPost, "/", `{"year":2020,"price_per_tonne":9,"total_capacity":100,"available_capacity":100}`, withID("2")), &vintage)
+	vintageID := strconv.FormatUint(uint64(vintage.ID), 10)
+
+	// Warm the cached listing to check the update invalidates it
+	if rec := serve(t, h.ListVintages, http.MethodGet, "/", "", withID("2")); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	rec := serve(t, h.UpdateVintage, http.MethodPut, "/", `{"available_capacity":40}`, withVintage("2", vintageID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var listing struct {
+		Vintages []models.Vintage `json:"vintages"`
+	}
+	rec = serve(t, h.ListVintages, http.MethodGet, "/", "", withID("2"))
+	decode(t, rec, &listing)
+	if rec.Header().Get("X-Cache") != "MISS" || len(listing.Vintages) != 1 || listing.Vintages[0].AvailableCapacity != 40 {
+		t.Errorf("expected the updated vintage after invalidation, got %+v from %s", listing.Vintages, rec.Header().Get("X-Cache"))
+	}
+
+	for _, tt := range []struct {
+		name    string
+		handler echo.HandlerFunc
+		body    string
+		setup   func(c echo.Context)
+		status  int
+	}{
+		{"get", h.GetVintage, "", withVintage("2", vintageID), http.StatusOK},
+		{"get from another project", h.GetVintage, "", withVintage("1", vintageID), http.StatusNotFound},
+		{"get invalid ID", h.GetVintage, "", withVintage("2", "x"), http.StatusBadRequest},
+		{"update over total", h.UpdateVintage, `{"available_capacity":101}`, withVintage("2", vintageID), http.StatusUnprocessableEntity},
+		{"list missing project", h.ListVintages, "", withID("9"), http.StatusNotFound},
+		{"delete", h.DeleteVintage, "", withVintage("2", vintageID), http.StatusOK},
+		{"delete again", h.DeleteVintage, "", withVintage("2", vintageID), http.StatusNotFound},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(t, tt.handler, http.MethodGet, "/", tt.body, tt.setup); rec.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -1,15 +1,30 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"project_service/cache"
+	"project_service/catalog"
 	"project_service/config"
 	_ "project_service/docs"
 	"project_service/handlers"
+	"project_service/models"
+	"project_service/outbox"
 	"project_service/repositories"
 	"project_service/routes"
+	"project_service/search"
+	"project_service/storage"
+	"shared/problem"
+	"strconv"
+	"time"
 
+	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/labstack/echo/v4"
 	echoSwagger "github.com/swaggo/echo-swagger"
 )
@@ -33,9 +48,31 @@ import (
 // @name Authorization
 // @description Admin JWT token (format: Bearer <token>)
 
+// @securityDefinitions.apikey DeveloperAuth
+// @in header
+// @name Authorization
+// @description User JWT token of a developer account (format: Bearer <token>)
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(config.RunCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		os.Exit(reindexCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && (os.Args[1] == "import" || os.Args[1] == "export") {
+		os.Exit(catalogCommand(os.Args[1], os.Args[2:]))
+	}
+
+	// Load and validate configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Initialize Echo
 	e := echo.New()
+	problem.Install(e)
 
 	// Health check endpoint
 	e.GET("/", func(c echo.Context) error {
@@ -54,23 +91,14 @@ func main() {
 	})
 
 	// Initialize database
-	_, err := config.InitDB()
+	db, err := config.InitDB(cfg.DB)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 	log.Println("Successfully connected to database")
 
-	// Initialize Elasticsearch (optional)
-	esClient, err := config.InitElasticsearch()
-	if err != nil {
-		log.Printf("Warning: Failed to connect to Elasticsearch: %v", err)
-		log.Println("Continuing without Elasticsearch...")
-	} else {
-		log.Println("Successfully connected to Elasticsearch")
-	}
-
 	// Initialize Redis (optional)
-	redisClient, err := config.InitRedis()
+	redisClient, err := config.InitRedis(cfg.Redis)
 	if err != nil {
 		log.Printf("Warning: Failed to connect to Redis: %v", err)
 		log.Println("Continuing without Redis caching...")
@@ -79,28 +107,312 @@ func main() {
 	}
 
 	// Initialize repository and handler with external services
-	projectRepo := repositories.NewProjectRepository()
-	if esClient != nil {
-		projectRepo.SetElasticsearchClient(esClient)
+	projectRepo := repositories.NewPostgresProjectRepository(db)
+
+	// Initialize the search backend; Postgres full-text search is the default
+	var esClient *elasticsearch.Client
+	var searchBackend repositories.SearchBackend = repositories.NewPostgresSearchBackend(db)
+	switch cfg.Search.Backend {
+	case config.SearchElasticsearch:
+		esClient, err = config.InitElasticsearch(cfg.Elasticsearch)
+		if err != nil {
+			log.Printf("Warning: Failed to connect to Elasticsearch: %v", err)
+			log.Println("Continuing with Postgres full-text search...")
+		} else {
+			log.Println("Successfully connected to Elasticsearch")
+			searchBackend = repositories.NewElasticsearchSearchBackend(esClient)
+		}
+	case config.SearchEmbedded:
+		embedded := repositories.NewEmbeddedSearchBackend()
+		if err := embedded.LoadFrom(projectRepo); err != nil {
+			log.Fatalf("Failed to build the embedded search index: %v", err)
+		}
+		searchBackend = embedded
+		log.Println("Using the embedded search index")
+	default:
+		log.Println("Using Postgres full-text search")
 	}
+	projectRepo.SetSearchBackend(searchBackend)
 
-	projectHandler := handlers.NewProjectHandler()
+	reservationRepo := repositories.NewPostgresReservationRepository(db)
+	vintageRepo := repositories.NewPostgresVintageRepository(db)
+	pricingRepo := repositories.NewPostgresPricingRepository(db)
+	documentRepo := repositories.NewPostgresDocumentRepository(db)
+	serialRepo := repositories.NewPostgresSerialRepository(db)
+	monitoringRepo := repositories.NewPostgresMonitoringRepository(db)
+
+	// Initialize the document store; files stay on local disk by default
+	documentStore, err := newDocumentStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize document storage: %v", err)
+	}
+
+	projectHandler := handlers.NewProjectHandler(projectRepo)
+	reservationHandler := handlers.NewReservationHandler(reservationRepo, cfg.Reservations.TTL)
+	vintageHandler := handlers.NewVintageHandler(vintageRepo)
+	pricingHandler := handlers.NewPricingHandler(pricingRepo)
+	documentHandler := handlers.NewDocumentHandler(documentRepo, documentStore,
+		storage.NewURLSigner(cfg.Documents.URLSecret, cfg.Documents.URLTTL), int64(cfg.Documents.MaxMB)<<20)
+	documentHandler.SetBaseURL(cfg.Documents.URLBase)
+	catalogHandler := handlers.NewCatalogHandler(projectRepo)
+	developerHandler := handlers.NewDeveloperHandler(projectRepo)
+	serialHandler := handlers.NewSerialHandler(serialRepo)
+	monitoringHandler := handlers.NewMonitoringHandler(monitoringRepo)
 	if redisClient != nil {
-		projectHandler.SetRedisClient(redisClient)
+		responseCache := cache.New(cache.NewRedisStore(redisClient))
+		projectHandler.SetCache(responseCache)
+		reservationHandler.SetCache(responseCache)
+		vintageHandler.SetCache(responseCache)
+		pricingHandler.SetCache(responseCache)
+		catalogHandler.SetCache(responseCache)
+		developerHandler.SetCache(responseCache)
 	}
 
+	// Return the capacity of reservations nobody committed or released
+	go sweepReservations(reservationHandler, cfg.Reservations.SweepInterval)
+	// Put scheduled prices into effect
+	go sweepPriceSchedules(pricingHandler, cfg.Pricing.SweepInterval)
+
+	// Relay project events from the outbox: index them, drop the cached
+	// responses they make stale, then publish them
+	relayHandlers := []outbox.Handler{
+		outbox.NewSearchIndexer(projectRepo, searchBackend),
+		outbox.HandlerFunc(func(ctx context.Context, event models.ProjectEvent) error {
+			return projectHandler.ForgetProject(ctx, event.ProjectID)
+		}),
+	}
+	if cfg.RabbitMQ.URL != "" {
+		publisher := outbox.NewRabbitMQPublisher(cfg.RabbitMQ.URL, cfg.RabbitMQ.Exchange)
+		defer publisher.Close()
+		relayHandlers = append(relayHandlers, publisher)
+		log.Printf("Publishing project events to exchange %s", cfg.RabbitMQ.Exchange)
+	} else {
+		log.Println("RABBITMQ_URL is not set, project events are not published")
+	}
+	go relayOutbox(outbox.NewRelay(repositories.NewPostgresOutboxRepository(db), relayHandlers...),
+		cfg.Outbox.RelayInterval, cfg.Outbox.Retention)
+
+	var searchIndex search.Maintainer
+	if esClient != nil {
+		searchIndex = search.NewReindexer(projectRepo, esClient)
+	}
+	searchHandler := handlers.NewSearchAdminHandler(searchIndex)
+
 	// Set up routes
-	routes.ProjectRoute(e)
+	routes.ProjectRoute(e, projectHandler, searchHandler, reservationHandler, vintageHandler, pricingHandler, documentHandler, catalogHandler, serialHandler, developerHandler, monitoringHandler, cfg.JWT)
 
 	// Swagger documentation route
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
 	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8081" // Different port from user_service
+	log.Printf("Project Marketplace Service starting on port %d (profile %s)", cfg.Port, cfg.Profile)
+	e.Logger.Fatal(e.Start(":" + strconv.Itoa(cfg.Port)))
+}
+
+// sweepReservations expires due reservations every interval
+func sweepReservations(reservations *handlers.ReservationHandler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		expired, err := reservations.ExpireDue(context.Background(), now)
+		if err != nil {
+			log.Printf("Warning: reservation sweep failed: %v", err)
+		}
+		if expired > 0 {
+			log.Printf("Expired %d reservations", expired)
+		}
+	}
+}
+
+// sweepPriceSchedules applies due price schedules every interval
+func sweepPriceSchedules(pricing *handlers.PricingHandler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		applied, err := pricing.ApplyDue(context.Background(), now)
+		if err != nil {
+			log.Printf("Warning: price schedule sweep failed: %v", err)
+		}
+		if applied > 0 {
+			log.Printf("Applied %d price schedules", applied)
+		}
+	}
+}
+
+// relayOutbox relays due project events every interval and purges the ones
+// published longer than the retention ago every hour
+func relayOutbox(relay *outbox.Relay, interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var purged time.Time
+	for now := range ticker.C {
+		if _, err := relay.RelayDue(context.Background(), now); err != nil {
+			log.Printf("Warning: outbox relay failed: %v", err)
+		}
+		if now.Sub(purged) < time.Hour {
+			continue
+		}
+		purged = now
+		deleted, err := relay.Purge(now.Add(-retention))
+		if err != nil {
+			log.Printf("Warning: outbox purge failed: %v", err)
+		}
+		if deleted > 0 {
+			log.Printf("Purged %d published events", deleted)
+		}
+	}
+}
+
+// newDocumentStore opens the configured document store, creating the S3
+// bucket if it does not exist yet
+func newDocumentStore(cfg *config.Config) (storage.Store, error) {
+	if cfg.Documents.Storage != config.DocumentStorageS3 {
+		log.Printf("Storing documents under %s", cfg.Documents.Dir)
+		return storage.NewLocalStore(cfg.Documents.Dir)
+	}
+
+	store, err := storage.NewS3Store(storage.S3Config(cfg.S3))
+	if err != nil {
+		return nil, err
+	}
+	if err := store.EnsureBucket(context.Background()); err != nil {
+		return nil, err
+	}
+	log.Printf("Storing documents in bucket %s at %s", cfg.S3.Bucket, cfg.S3.Endpoint)
+	return store, nil
+}
+
+// reindexCommand runs "reindex" to rebuild the search index or
+// "reindex check" to only compare it with the database. The report is
+// printed as JSON; the exit code is 1 if the run failed or the index is
+// inconsistent.
+func reindexCommand(args []string) int {
+	if len(args) > 1 || (len(args) == 1 && args[0] != "check") {
+		fmt.Fprintln(os.Stderr, "usage: project_service reindex [check]")
+		return 2
 	}
 
-	log.Printf("Project Marketplace Service starting on port %s", port)
-	e.Logger.Fatal(e.Start(":" + port))
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	db, err := config.InitDB(cfg.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	esClient, err := config.InitElasticsearch(cfg.Elasticsearch)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to Elasticsearch: %v\n", err)
+		return 1
+	}
+
+	reindexer := search.NewReindexer(repositories.NewPostgresProjectRepository(db), esClient)
+	var report interface{}
+	var consistency *search.ConsistencyReport
+	if len(args) == 1 {
+		consistency, err = reindexer.Check(context.Background())
+		report = consistency
+	} else {
+		var reindexed *search.ReindexReport
+		if reindexed, err = reindexer.Reindex(context.Background()); err == nil {
+			report, consistency = reindexed, reindexed.Consistency
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if !consistency.Consistent() {
+		return 1
+	}
+	return 0
+}
+
+// catalogCommand runs "import [-dry-run] [-format csv|ndjson] FILE" to
+// import projects from a file, printing the report as JSON, or
+// "export [-format csv|ndjson]" to write the catalog to stdout. An import's
+// format defaults to the file's extension; its exit code is 1 if any row
+// failed.
+func catalogCommand(name string, args []string) int {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	format := flags.String("format", "", "file format, csv or ndjson")
+	dryRun := flags.Bool("dry-run", false, "only validate and report what would be done")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if name == "export" && (flags.NArg() != 0 || *dryRun) {
+		fmt.Fprintln(os.Stderr, "usage: project_service export [-format csv|ndjson]")
+		return 2
+	}
+	if name == "import" && flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: project_service import [-dry-run] [-format csv|ndjson] FILE")
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	db, err := config.InitDB(cfg.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	catalogHandler := handlers.NewCatalogHandler(repositories.NewPostgresProjectRepository(db))
+
+	if name == "export" {
+		if *format == "" {
+			*format = catalog.FormatCSV
+		}
+		if err := catalogHandler.Export(os.Stdout, *format); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	// Drop the server's cached responses for the imported projects
+	if redisClient, err := config.InitRedis(cfg.Redis); err == nil {
+		catalogHandler.SetCache(cache.New(cache.NewRedisStore(redisClient)))
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		switch filepath.Ext(path) {
+		case ".csv":
+			*format = catalog.FormatCSV
+		case ".ndjson", ".jsonl":
+			*format = catalog.FormatNDJSON
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+
+	// Command line imports have no actor
+	report, err := catalogHandler.Import(context.Background(), file, *format, *dryRun,
+		models.Change{Reason: handlers.ImportReason + " from " + filepath.Base(path)})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
 }
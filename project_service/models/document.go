@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Document kinds
+const (
+	DocumentDesign       = "project_design"
+	DocumentValidation   = "validation_report"
+	DocumentVerification = "verification_report"
+	DocumentImage        = "image"
+	DocumentMonitoring   = "monitoring_report"
+)
+
+// DocumentContentTypes are the content types each kind of document accepts
+var DocumentContentTypes = map[string][]string{
+	DocumentDesign:       {"application/pdf"},
+	DocumentValidation:   {"application/pdf"},
+	DocumentVerification: {"application/pdf"},
+	DocumentImage:        {"image/jpeg", "image/png", "image/webp"},
+	DocumentMonitoring:   {"application/pdf"},
+}
+
+// DeveloperDocuments are the kinds of document a project's developer may
+// upload: gallery images and monitoring reports. The design, validation and
+// verification documents come from the registry and auditors.
+var DeveloperDocuments = []string{DocumentImage, DocumentMonitoring}
+
+// Document is a file attached to a project: a design document, a
+// validation, verification or monitoring report, or an image for its
+// gallery. The file
+// itself is in blob storage under StorageKey.
+type Document struct {
+	ID          uint   `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID   uint   `json:"project_id" gorm:"column:project_id;not null;index"`
+	Kind        string `json:"kind" gorm:"column:kind;not null"`
+	Title       string `json:"title" gorm:"column:title"`
+	Filename    string `json:"filename" gorm:"column:filename;not null"`
+	ContentType string `json:"content_type" gorm:"column:content_type;not null"`
+	Size        int64  `json:"size" gorm:"column:size;not null"`
+	// Checksum is the hex SHA-256 of the file
+	Checksum   string    `json:"checksum_sha256" gorm:"column:checksum;not null"`
+	StorageKey string    `json:"-" gorm:"column:storage_key;not null;uniqueIndex"`
+	UploadedBy uint      `json:"uploaded_by" gorm:"column:uploaded_by"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+
+	// URL is a signed download link, set on responses
+	URL          string     `json:"url,omitempty" gorm:"-"`
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty" gorm:"-"`
+}
+
+// UploadDocumentRequest is the form sent with an uploaded file
+type UploadDocumentRequest struct {
+	Kind  string `form:"kind" json:"kind" validate:"required,oneof=project_design validation_report verification_report monitoring_report image"`
+	Title string `form:"title" json:"title" validate:"max=255"`
+}
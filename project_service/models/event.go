@@ -0,0 +1,92 @@
+package models
+
+import "time"
+
+// Project event types, also the routing keys events are published with
+const (
+	EventProjectCreated         = "project.created"
+	EventProjectUpdated         = "project.updated"
+	EventProjectPriceChanged    = "project.price_changed"
+	EventProjectCapacityChanged = "project.capacity_changed"
+	EventProjectRetired         = "project.retired"
+)
+
+// ProjectEvent is a change to a project as it is published. Events are
+// delivered at least once: ID is unique per event so consumers can drop
+// repeats, and Sequence grows with every event so they can tell which of
+// two events about a project is newer.
+type ProjectEvent struct {
+	ID         string                 `json:"id"`
+	Sequence   uint                   `json:"sequence"`
+	Type       string                 `json:"type"`
+	ProjectID  uint                   `json:"project_id"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"`
+	Project    ProjectFields          `json:"project"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+// OutboxEvent is a project event in the outbox. It is written in the
+// transaction of the change it describes and relayed after the commit
+// until it is published; a failed relay is retried from RetryAt on.
+type OutboxEvent struct {
+	ID          uint                   `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	EventID     string                 `json:"event_id" gorm:"column:event_id;uniqueIndex;not null"`
+	Type        string                 `json:"type" gorm:"column:type;not null"`
+	ProjectID   uint                   `json:"project_id" gorm:"column:project_id;not null;index"`
+	Changes     map[string]FieldChange `json:"changes,omitempty" gorm:"column:changes;serializer:json"`
+	Project     ProjectFields          `json:"project" gorm:"column:project;serializer:json"`
+	OccurredAt  time.Time              `json:"occurred_at" gorm:"column:occurred_at;not null"`
+	Attempts    int                    `json:"attempts" gorm:"column:attempts;not null;default:0"`
+	LastError   string                 `json:"last_error,omitempty" gorm:"column:last_error;type:text"`
+	RetryAt     time.Time              `json:"retry_at" gorm:"column:retry_at;not null;index:idx_outbox_events_pending,where:published_at IS NULL"`
+	PublishedAt *time.Time             `json:"published_at,omitempty" gorm:"column:published_at;index"`
+	CreatedAt   time.Time              `json:"created_at" gorm:"column:created_at"`
+}
+
+// Event returns the event as it is published
+func (e *OutboxEvent) Event() ProjectEvent {
+	return ProjectEvent{
+		ID:         e.EventID,
+		Sequence:   e.ID,
+		Type:       e.Type,
+		ProjectID:  e.ProjectID,
+		Changes:    e.Changes,
+		Project:    e.Project,
+		OccurredAt: e.OccurredAt,
+	}
+}
+
+// EventTypes returns the types of the events of a write that took a
+// project's fields from before, nil for a create, to after: none when
+// nothing changed, project.retired for a retirement, and otherwise
+// price_changed and capacity_changed for those fields and updated for any
+// other change
+func EventTypes(before *ProjectFields, after ProjectFields) []string {
+	if before == nil {
+		return []string{EventProjectCreated}
+	}
+	changes := Diff(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	if after.Status == StatusRetired && before.Status != StatusRetired {
+		return []string{EventProjectRetired}
+	}
+
+	var types []string
+	if _, ok := changes["price_per_tonne"]; ok {
+		types = append(types, EventProjectPriceChanged)
+		delete(changes, "price_per_tonne")
+	}
+	_, total := changes["total_capacity"]
+	_, available := changes["available_capacity"]
+	if total || available {
+		types = append(types, EventProjectCapacityChanged)
+		delete(changes, "total_capacity")
+		delete(changes, "available_capacity")
+	}
+	if len(changes) > 0 {
+		types = append([]string{EventProjectUpdated}, types...)
+	}
+	return types
+}
@@ -0,0 +1,89 @@
+package models
+
+// FacetSize is the largest number of values returned for a terms facet
+const FacetSize = 50
+
+// PriceHistogramInterval is the width of the price-per-tonne histogram
+// buckets
+const PriceHistogramInterval = 5.0
+
+// FacetRange is a range facet's range. From is inclusive, To exclusive and
+// zero for the open-ended last range.
+type FacetRange struct {
+	Key  string
+	From float64
+	To   float64
+}
+
+// CapacityRanges are the available-capacity facet ranges, in tonnes
+var CapacityRanges = []FacetRange{
+	{Key: "under_1k", From: 0, To: 1000},
+	{Key: "1k_to_10k", From: 1000, To: 10000},
+	{Key: "10k_to_100k", From: 10000, To: 100000},
+	{Key: "100k_and_over", From: 100000},
+}
+
+// PermanenceRanges are the permanence facet ranges, in years. Projects
+// that do not state a permanence fall in none of them.
+var PermanenceRanges = []FacetRange{
+	{Key: "under_40", From: 1, To: 40},
+	{Key: "40_to_100", From: 40, To: 100},
+	{Key: "100_and_over", From: 100},
+}
+
+// BufferPoolRanges are the buffer pool facet ranges, in percent of the
+// total capacity
+var BufferPoolRanges = []FacetRange{
+	{Key: "under_10", From: 0, To: 10},
+	{Key: "10_to_20", From: 10, To: 20},
+	{Key: "20_and_over", From: 20},
+}
+
+// Contains reports whether a value falls in the range
+func (r FacetRange) Contains(value float64) bool {
+	return value >= r.From && (r.To == 0 || value < r.To)
+}
+
+// FacetCount is the number of matching projects with a field value
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// HistogramBucket is the number of matching projects priced in [From, To)
+type HistogramBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int64   `json:"count"`
+}
+
+// RangeCount is the number of matching projects in a facet range. To is
+// omitted for the open-ended last range.
+type RangeCount struct {
+	Key   string   `json:"key"`
+	From  float64  `json:"from"`
+	To    *float64 `json:"to,omitempty"`
+	Count int64    `json:"count"`
+}
+
+// ProjectFacets summarise the projects matching a search. Each facet is
+// counted under the query and every filter except its own, so the counts
+// show what selecting another value of that facet would return. Terms
+// facets are ordered by count, then value; the price histogram covers the
+// lowest to the highest matching price without gaps. A project counts
+// towards every vintage year and SDG goal it has.
+type ProjectFacets struct {
+	Category             []FacetCount      `json:"category"`
+	Region               []FacetCount      `json:"region"`
+	Country              []FacetCount      `json:"country"`
+	VerificationStandard []FacetCount      `json:"verification_standard"`
+	Vintage              []FacetCount      `json:"vintage"`
+	PricePerTonne        []HistogramBucket `json:"price_per_tonne"`
+	AvailableCapacity    []RangeCount      `json:"available_capacity"`
+	MethodologyID        []FacetCount      `json:"methodology_id"`
+	CreditType           []FacetCount      `json:"credit_type"`
+	SolutionType         []FacetCount      `json:"solution_type"`
+	SDGGoals             []FacetCount      `json:"sdg_goals"`
+	PermanenceYears      []RangeCount      `json:"permanence_years"`
+	BufferPoolPercent    []RangeCount      `json:"buffer_pool_percent"`
+}
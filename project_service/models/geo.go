@@ -0,0 +1,248 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+)
+
+// EarthRadiusKm is the mean Earth radius distances are measured with, the
+// one Elasticsearch uses
+const EarthRadiusKm = 6371.0088
+
+// GeoJSON geometry types a project boundary may have
+const (
+	GeometryPolygon      = "Polygon"
+	GeometryMultiPolygon = "MultiPolygon"
+)
+
+// MaxFeatures is the largest page of the GeoJSON feed
+const MaxFeatures = 1000
+
+// Geometry is a GeoJSON (RFC 7946) geometry. A project boundary is a
+// Polygon or a MultiPolygon in [longitude, latitude] positions, kept as
+// decoded JSON so it compares equal however it was formatted.
+type Geometry struct {
+	Type        string      `json:"type" validate:"oneof=Polygon MultiPolygon"`
+	Coordinates interface{} `json:"coordinates" swaggertype:"array,number"`
+}
+
+// Value stores the geometry as JSON, also when it is written from a map of
+// columns
+func (g Geometry) Value() (driver.Value, error) {
+	data, err := json.Marshal(g)
+	return string(data), err
+}
+
+// ValidPolygons reports whether the coordinates are a Polygon or, for a
+// MultiPolygon, a list of them: closed linear rings of at least four
+// positions with a longitude and latitude in range
+func (g *Geometry) ValidPolygons() bool {
+	switch g.Type {
+	case GeometryPolygon:
+		return validPolygon(g.Coordinates)
+	case GeometryMultiPolygon:
+		polygons, ok := g.Coordinates.([]interface{})
+		if !ok || len(polygons) == 0 {
+			return false
+		}
+		for _, polygon := range polygons {
+			if !validPolygon(polygon) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func validPolygon(coordinates interface{}) bool {
+	rings, ok := coordinates.([]interface{})
+	if !ok || len(rings) == 0 {
+		return false
+	}
+	for _, ring := range rings {
+		positions, ok := ring.([]interface{})
+		if !ok || len(positions) < 4 {
+			return false
+		}
+		var first, last [2]float64
+		for i, position := range positions {
+			lon, lat, ok := positionOf(position)
+			if !ok {
+				return false
+			}
+			if i == 0 {
+				first = [2]float64{lon, lat}
+			}
+			last = [2]float64{lon, lat}
+		}
+		if first != last {
+			return false
+		}
+	}
+	return true
+}
+
+// positionOf reads a GeoJSON position, ignoring any altitude. It is not ok
+// unless it holds a longitude and a latitude in range.
+func positionOf(position interface{}) (lon, lat float64, ok bool) {
+	values, ok := position.([]interface{})
+	if !ok || len(values) < 2 {
+		return 0, 0, false
+	}
+	lon, okLon := values[0].(float64)
+	lat, okLat := values[1].(float64)
+	if !okLon || !okLat || lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	return lon, lat, true
+}
+
+// PointGeometry returns the GeoJSON Point of a position
+func PointGeometry(lat, lon float64) Geometry {
+	return Geometry{Type: "Point", Coordinates: []float64{lon, lat}}
+}
+
+// GeoBox is a bounding box in degrees. A box whose MinLon is greater than
+// its MaxLon crosses the antimeridian.
+type GeoBox struct {
+	MinLon float64 `json:"min_lon" validate:"min=-180,max=180"`
+	MinLat float64 `json:"min_lat" validate:"min=-90,max=90"`
+	MaxLon float64 `json:"max_lon" validate:"min=-180,max=180"`
+	MaxLat float64 `json:"max_lat" validate:"min=-90,max=90,gtefield=MinLat"`
+}
+
+// Spans returns the box as boxes that do not cross the antimeridian
+func (b GeoBox) Spans() []GeoBox {
+	if b.MinLon <= b.MaxLon {
+		return []GeoBox{b}
+	}
+	east, west := b, b
+	east.MaxLon = 180
+	west.MinLon = -180
+	return []GeoBox{east, west}
+}
+
+// Contains reports whether a position is inside the box, edges included
+func (b GeoBox) Contains(lat, lon float64) bool {
+	for _, span := range b.Spans() {
+		if lat >= span.MinLat && lat <= span.MaxLat && lon >= span.MinLon && lon <= span.MaxLon {
+			return true
+		}
+	}
+	return false
+}
+
+// GeoCircle is the area within a distance of a position
+type GeoCircle struct {
+	Lat      float64 `json:"lat" validate:"min=-90,max=90"`
+	Lon      float64 `json:"lon" validate:"min=-180,max=180"`
+	RadiusKm float64 `json:"radius_km" validate:"gt=0,max=20000"`
+}
+
+// Contains reports whether a position is within the circle
+func (c GeoCircle) Contains(lat, lon float64) bool {
+	return DistanceKm(c.Lat, c.Lon, lat, lon) <= c.RadiusKm
+}
+
+// Bounds returns the smallest box holding the circle. A circle reaching a
+// pole takes every longitude.
+func (c GeoCircle) Bounds() GeoBox {
+	angle := c.RadiusKm / EarthRadiusKm
+	box := GeoBox{
+		MinLat: c.Lat - degrees(angle),
+		MaxLat: c.Lat + degrees(angle),
+		MinLon: -180,
+		MaxLon: 180,
+	}
+	if box.MinLat <= -90 || box.MaxLat >= 90 {
+		box.MinLat, box.MaxLat = math.Max(box.MinLat, -90), math.Min(box.MaxLat, 90)
+		return box
+	}
+
+	spread := math.Sin(angle) / math.Cos(radians(c.Lat))
+	if spread >= 1 {
+		return box
+	}
+	delta := degrees(math.Asin(spread))
+	box.MinLon, box.MaxLon = c.Lon-delta, c.Lon+delta
+	if box.MinLon < -180 {
+		box.MinLon += 360
+	}
+	if box.MaxLon > 180 {
+		box.MaxLon -= 360
+	}
+	return box
+}
+
+// DistanceKm is the great-circle distance between two positions by the
+// haversine formula
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(h, 1)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// FeatureCollection is a GeoJSON FeatureCollection of projects for map
+// clients. NextCursor, a foreign member, continues the feed when there are
+// more projects than fit in one.
+type FeatureCollection struct {
+	Type       string    `json:"type"`
+	Features   []Feature `json:"features"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// Feature is a project on the map, placed at its location or drawn as its
+// boundary
+type Feature struct {
+	Type       string            `json:"type"`
+	ID         uint              `json:"id"`
+	Geometry   Geometry          `json:"geometry"`
+	Properties FeatureProperties `json:"properties"`
+}
+
+// FeatureProperties are what a map shows of a project
+type FeatureProperties struct {
+	Title                string  `json:"title"`
+	Category             string  `json:"category"`
+	Country              string  `json:"country"`
+	VerificationStandard string  `json:"verification_standard"`
+	PricePerTonne        float64 `json:"price_per_tonne"`
+	AvailableCapacity    float64 `json:"available_capacity"`
+	ImageURL             string  `json:"image_url,omitempty"`
+}
+
+// FeatureOf returns a located project's feature, drawn as its boundary when
+// it has one and boundaries are asked for
+func FeatureOf(project *Project, boundaries bool) Feature {
+	feature := Feature{
+		Type: "Feature",
+		ID:   project.ID,
+		Properties: FeatureProperties{
+			Title:                project.Title,
+			Category:             project.Category,
+			Country:              project.Country,
+			VerificationStandard: project.VerificationStandard,
+			PricePerTonne:        project.PricePerTonne,
+			AvailableCapacity:    project.AvailableCapacity,
+			ImageURL:             project.ImageURL,
+		},
+	}
+	if boundaries && project.Boundary != nil {
+		feature.Geometry = *project.Boundary
+	} else {
+		feature.Geometry = PointGeometry(*project.Latitude, *project.Longitude)
+	}
+	return feature
+}
@@ -0,0 +1,122 @@
+package models
+
+import "time"
+
+// Project statuses. A project is drafted, submitted for review and approved
+// or rejected; an approved project is published, and only published
+// projects are listed and searched publicly. A published project can be
+// paused, sell out, or be retired for good.
+const (
+	StatusDraft     = "draft"
+	StatusSubmitted = "submitted"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusPublished = "published"
+	StatusPaused    = "paused"
+	StatusSoldOut   = "sold_out"
+	StatusRetired   = "retired"
+)
+
+// Lifecycle actions
+const (
+	ActionSubmit  = "submit"
+	ActionApprove = "approve"
+	ActionReject  = "reject"
+	ActionRevise  = "revise"
+	ActionPublish = "publish"
+	ActionPause   = "pause"
+	ActionResume  = "resume"
+	ActionSellOut = "sell_out"
+	ActionRetire  = "retire"
+)
+
+// Roles. Admins run the whole catalog; developers hold user tokens and work
+// on the projects linked to their account.
+const (
+	RoleAdmin     = "admin"
+	RoleDeveloper = "developer"
+)
+
+// Actor is who makes a change, from their token
+type Actor struct {
+	ID   uint
+	Role string
+}
+
+// Transition is a move between project statuses
+type Transition struct {
+	Action string
+	From   []string
+	To     string
+	// Roles may make the transition
+	Roles []string
+	// Review transitions may not be made by whoever submitted the project
+	Review bool
+	// RequiresReason transitions must say why
+	RequiresReason bool
+}
+
+// Allows reports whether the transition leaves a status
+func (t *Transition) Allows(from string) bool {
+	for _, status := range t.From {
+		if status == from {
+			return true
+		}
+	}
+	return false
+}
+
+// Permits reports whether an actor may make the transition
+func (t *Transition) Permits(actor Actor) bool {
+	for _, role := range t.Roles {
+		if role == actor.Role {
+			return true
+		}
+	}
+	return false
+}
+
+// Transitions is the project lifecycle, by action
+var Transitions = map[string]Transition{
+	ActionSubmit:  {Action: ActionSubmit, From: []string{StatusDraft}, To: StatusSubmitted, Roles: []string{RoleAdmin, RoleDeveloper}},
+	ActionApprove: {Action: ActionApprove, From: []string{StatusSubmitted}, To: StatusApproved, Roles: []string{RoleAdmin}, Review: true},
+	ActionReject:  {Action: ActionReject, From: []string{StatusSubmitted}, To: StatusRejected, Roles: []string{RoleAdmin}, Review: true, RequiresReason: true},
+	ActionRevise:  {Action: ActionRevise, From: []string{StatusRejected}, To: StatusDraft, Roles: []string{RoleAdmin, RoleDeveloper}},
+	ActionPublish: {Action: ActionPublish, From: []string{StatusApproved}, To: StatusPublished, Roles: []string{RoleAdmin}},
+	ActionPause:   {Action: ActionPause, From: []string{StatusPublished}, To: StatusPaused, Roles: []string{RoleAdmin}},
+	ActionResume:  {Action: ActionResume, From: []string{StatusPaused, StatusSoldOut}, To: StatusPublished, Roles: []string{RoleAdmin}},
+	ActionSellOut: {Action: ActionSellOut, From: []string{StatusPublished, StatusPaused}, To: StatusSoldOut, Roles: []string{RoleAdmin}},
+	ActionRetire: {Action: ActionRetire, To: StatusRetired, Roles: []string{RoleAdmin}, From: []string{
+		StatusDraft, StatusSubmitted, StatusApproved, StatusRejected, StatusPublished, StatusPaused, StatusSoldOut,
+	}},
+}
+
+// Unreleased reports whether a status has never been published, so the
+// project is hidden from the public even by ID
+func Unreleased(status string) bool {
+	switch status {
+	case StatusDraft, StatusSubmitted, StatusApproved, StatusRejected:
+		return true
+	}
+	return false
+}
+
+// ProjectTransition records a status change of a project, who made it and
+// why
+type ProjectTransition struct {
+	ID         uint      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID  uint      `json:"project_id" gorm:"column:project_id;not null;index"`
+	Action     string    `json:"action" gorm:"column:action;not null"`
+	FromStatus string    `json:"from_status" gorm:"column:from_status;not null"`
+	ToStatus   string    `json:"to_status" gorm:"column:to_status;not null"`
+	Reason     string    `json:"reason,omitempty" gorm:"column:reason;type:text"`
+	ActorID    uint      `json:"actor_id" gorm:"column:actor_id"`
+	ActorRole  string    `json:"actor_role" gorm:"column:actor_role"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TransitionProjectRequest moves a project through its lifecycle
+type TransitionProjectRequest struct {
+	Action string `json:"action" validate:"required,oneof=submit approve reject revise publish pause resume sell_out retire"`
+	Reason string `json:"reason" validate:"max=2000"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// MonitoringPeriod is a stretch of a project's operation over which its
+// developer measured the tonnes it removed or avoided, and the third-party
+// verification of that measurement. Verified tonnes are issued as credits,
+// so the periods of a project are what it has actually delivered. A
+// project's periods do not overlap.
+type MonitoringPeriod struct {
+	ID             uint      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID      uint      `json:"project_id" gorm:"column:project_id;not null;index"`
+	PeriodStart    time.Time `json:"period_start" gorm:"column:period_start;not null"`
+	PeriodEnd      time.Time `json:"period_end" gorm:"column:period_end;not null"`
+	MeasuredTonnes float64   `json:"measured_tonnes" gorm:"column:measured_tonnes;not null"`
+	Verifier       string    `json:"verifier" gorm:"column:verifier;not null"`
+	VerifiedAt     time.Time `json:"verification_date" gorm:"column:verified_at;not null"`
+	// ReportID is the project's monitoring report document for the period
+	ReportID      *uint     `json:"report_id,omitempty" gorm:"column:report_id"`
+	SubmittedBy   uint      `json:"submitted_by" gorm:"column:submitted_by"`
+	SubmitterRole string    `json:"submitter_role" gorm:"column:submitter_role"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// Overlaps reports whether two periods share any time. A period may start
+// when the one before it ends.
+func (p *MonitoringPeriod) Overlaps(other *MonitoringPeriod) bool {
+	return p.PeriodStart.Before(other.PeriodEnd) && other.PeriodStart.Before(p.PeriodEnd)
+}
+
+// CreateMonitoringPeriodRequest submits a verified monitoring period. The
+// period ends after it starts and is verified no earlier than its end. The
+// report, when given, is a monitoring report document of the project.
+type CreateMonitoringPeriodRequest struct {
+	PeriodStart    time.Time `json:"period_start" validate:"required"`
+	PeriodEnd      time.Time `json:"period_end" validate:"required,gtfield=PeriodStart"`
+	MeasuredTonnes float64   `json:"measured_tonnes" validate:"required,gt=0"`
+	Verifier       string    `json:"verifier" validate:"required,max=200"`
+	VerifiedAt     time.Time `json:"verification_date" validate:"required,gtefield=PeriodEnd"`
+	ReportID       *uint     `json:"report_id"`
+}
+
+// TonnesAt is a quantity of tonnes that changed hands at a time
+type TonnesAt struct {
+	At     time.Time `json:"at" gorm:"column:at"`
+	Tonnes float64   `json:"tonnes" gorm:"column:tonnes"`
+}
+
+// MRVPoint is a verified monitoring period with where the project stood on
+// its verification date: the tonnes issued for every period verified by
+// then, and the tonnes sold and retired by then
+type MRVPoint struct {
+	PeriodID          uint      `json:"period_id"`
+	PeriodStart       time.Time `json:"period_start"`
+	PeriodEnd         time.Time `json:"period_end"`
+	MeasuredTonnes    float64   `json:"measured_tonnes"`
+	Verifier          string    `json:"verifier"`
+	VerifiedAt        time.Time `json:"verification_date"`
+	ReportID          *uint     `json:"report_id,omitempty"`
+	CumulativeIssued  float64   `json:"cumulative_issued"`
+	CumulativeSold    float64   `json:"cumulative_sold"`
+	CumulativeRetired float64   `json:"cumulative_retired"`
+}
+
+// MRVSeries is the monitoring, reporting and verification history of a
+// project as chart data: a point per period in order of verification, and
+// the tonnes issued, sold and retired to date
+type MRVSeries struct {
+	ProjectID uint       `json:"project_id"`
+	Points    []MRVPoint `json:"points"`
+	Issued    float64    `json:"issued"`
+	Sold      float64    `json:"sold"`
+	Retired   float64    `json:"retired"`
+}
+
+// NewMRVSeries charts a project's periods against its sales, the committed
+// reservations of order lines, and its retirements, the serials allocated
+// to those lines. A line is sold when its order is paid and retired once
+// certified, so no more is ever retired than sold.
+func NewMRVSeries(projectID uint, periods []MonitoringPeriod, sales, retirements []TonnesAt) *MRVSeries {
+	periods = append([]MonitoringPeriod(nil), periods...)
+	sort.SliceStable(periods, func(i, j int) bool {
+		if !periods[i].VerifiedAt.Equal(periods[j].VerifiedAt) {
+			return periods[i].VerifiedAt.Before(periods[j].VerifiedAt)
+		}
+		return periods[i].PeriodStart.Before(periods[j].PeriodStart)
+	})
+
+	series := &MRVSeries{ProjectID: projectID, Points: []MRVPoint{}}
+	for _, period := range periods {
+		series.Issued += period.MeasuredTonnes
+		series.Points = append(series.Points, MRVPoint{
+			PeriodID:          period.ID,
+			PeriodStart:       period.PeriodStart,
+			PeriodEnd:         period.PeriodEnd,
+			MeasuredTonnes:    period.MeasuredTonnes,
+			Verifier:          period.Verifier,
+			VerifiedAt:        period.VerifiedAt,
+			ReportID:          period.ReportID,
+			CumulativeIssued:  series.Issued,
+			CumulativeSold:    tonnesBy(sales, period.VerifiedAt),
+			CumulativeRetired: tonnesBy(retirements, period.VerifiedAt),
+		})
+	}
+	series.Sold = tonnesBy(sales, time.Time{})
+	series.Retired = tonnesBy(retirements, time.Time{})
+	return series
+}
+
+// tonnesBy sums the tonnes that changed hands no later than a time, or all
+// of them for the zero time
+func tonnesBy(moved []TonnesAt, t time.Time) float64 {
+	var tonnes float64
+	for _, m := range moved {
+		if t.IsZero() || !m.At.After(t) {
+			tonnes += m.Tonnes
+		}
+	}
+	return tonnes
+}
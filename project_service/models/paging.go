@@ -0,0 +1,39 @@
+package models
+
+// Sort orders for project listings and searches. Ties are broken by
+// ascending ID so every order is total and can be paged with a cursor.
+const (
+	SortRelevance    = "relevance"
+	SortNewest       = "newest"
+	SortPriceAsc     = "price_asc"
+	SortPriceDesc    = "price_desc"
+	SortCapacityAsc  = "capacity_asc"
+	SortCapacityDesc = "capacity_desc"
+)
+
+// SearchSorts are the orders a search accepts, the first being the default.
+// Relevance ranks text matches by score and orders filter-only searches by
+// ID.
+var SearchSorts = []string{SortRelevance, SortNewest, SortPriceAsc, SortPriceDesc, SortCapacityAsc, SortCapacityDesc}
+
+// ListSorts are the orders the project listing accepts, the first being the
+// default
+var ListSorts = SearchSorts[1:]
+
+// ProjectListRequest asks for a page of the active projects. A cursor from
+// a previous page continues after its last project and takes the place of
+// the offset.
+type ProjectListRequest struct {
+	Sort   string
+	Cursor string
+	Limit  int
+	Offset int
+}
+
+// ProjectPage is a page of projects, the number of active projects across
+// all pages and the cursor of the next page, empty on the last one
+type ProjectPage struct {
+	Projects   []Project
+	Total      int64
+	NextCursor string
+}
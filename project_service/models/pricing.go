@@ -0,0 +1,115 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// Price schedule states. A pending schedule takes effect once its
+// effective_from passes; it can be cancelled until then.
+const (
+	SchedulePending   = "pending"
+	ScheduleApplied   = "applied"
+	ScheduleCancelled = "cancelled"
+)
+
+// Price change sources
+const (
+	PriceChangeCreate   = "create"
+	PriceChangeUpdate   = "update"
+	PriceChangeTiers    = "tiers"
+	PriceChangeSchedule = "schedule"
+)
+
+// PriceTier prices purchases of at least MinTonnes. A project's tiers run
+// from their minimum up to the next tier's; purchases below the lowest tier
+// pay the project's base price.
+type PriceTier struct {
+	MinTonnes     float64 `json:"min_tonnes" validate:"gt=0"`
+	PricePerTonne float64 `json:"price_per_tonne" validate:"gt=0"`
+}
+
+// SortTiers orders tiers by their minimum tonnage and reports whether two
+// share one
+func SortTiers(tiers []PriceTier) bool {
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinTonnes < tiers[j].MinTonnes })
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].MinTonnes == tiers[i-1].MinTonnes {
+			return false
+		}
+	}
+	return true
+}
+
+// PriceFor returns the price per tonne of a purchase under a base price and
+// sorted tiers, and the tier that set it, nil for the base price
+func PriceFor(base float64, tiers []PriceTier, tonnes float64) (float64, *PriceTier) {
+	for i := len(tiers) - 1; i >= 0; i-- {
+		if tonnes >= tiers[i].MinTonnes {
+			tier := tiers[i]
+			return tier.PricePerTonne, &tier
+		}
+	}
+	return base, nil
+}
+
+// PriceSchedule changes a project's base price and tiers at a future
+// moment
+type PriceSchedule struct {
+	ID            uint        `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID     uint        `json:"project_id" gorm:"column:project_id;not null;index"`
+	PricePerTonne float64     `json:"price_per_tonne" gorm:"column:price_per_tonne;not null"`
+	Tiers         []PriceTier `json:"tiers" gorm:"column:tiers;serializer:json"`
+	EffectiveFrom time.Time   `json:"effective_from" gorm:"column:effective_from;not null;index:idx_price_schedules_status_effective"`
+	Status        string      `json:"status" gorm:"column:status;not null;index:idx_price_schedules_status_effective"`
+	AppliedAt     *time.Time  `json:"applied_at,omitempty" gorm:"column:applied_at"`
+	CreatedAt     time.Time   `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt     time.Time   `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// PriceChange records a project's base price and tiers from the moment
+// they took effect. ScheduleID is set for changes a schedule made.
+type PriceChange struct {
+	ID            uint        `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID     uint        `json:"project_id" gorm:"column:project_id;not null;index:idx_price_changes_project_effective"`
+	PricePerTonne float64     `json:"price_per_tonne" gorm:"column:price_per_tonne;not null"`
+	Tiers         []PriceTier `json:"tiers" gorm:"column:tiers;serializer:json"`
+	Source        string      `json:"source" gorm:"column:source;not null"`
+	ScheduleID    *uint       `json:"schedule_id,omitempty" gorm:"column:schedule_id"`
+	EffectiveFrom time.Time   `json:"effective_from" gorm:"column:effective_from;not null;index:idx_price_changes_project_effective"`
+	CreatedAt     time.Time   `json:"created_at" gorm:"column:created_at"`
+}
+
+// ProjectPricing is a project's current price list and the schedules still
+// to take effect
+type ProjectPricing struct {
+	ProjectID     uint            `json:"project_id"`
+	PricePerTonne float64         `json:"price_per_tonne"`
+	Tiers         []PriceTier     `json:"tiers"`
+	Schedules     []PriceSchedule `json:"schedules"`
+}
+
+// Quote is the price of a tonnage of a project at a moment
+type Quote struct {
+	ProjectID     uint       `json:"project_id"`
+	Tonnes        float64    `json:"tonnes"`
+	At            time.Time  `json:"at"`
+	PricePerTonne float64    `json:"price_per_tonne"`
+	Total         float64    `json:"total"`
+	Tier          *PriceTier `json:"tier,omitempty"`
+	ScheduleID    *uint      `json:"schedule_id,omitempty"`
+}
+
+// SetPricingRequest changes a project's tiers, and its base price when
+// given, straight away
+type SetPricingRequest struct {
+	PricePerTonne float64     `json:"price_per_tonne" validate:"min=0"`
+	Tiers         []PriceTier `json:"tiers" validate:"max=20,dive"`
+}
+
+// CreatePriceScheduleRequest schedules a new base price and tiers
+type CreatePriceScheduleRequest struct {
+	PricePerTonne float64     `json:"price_per_tonne" validate:"required,gt=0"`
+	Tiers         []PriceTier `json:"tiers" validate:"max=20,dive"`
+	EffectiveFrom time.Time   `json:"effective_from" validate:"required"`
+}
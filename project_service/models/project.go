@@ -3,64 +3,183 @@ package models
 import "time"
 
 type Project struct {
-	ID                   uint      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
-	Title                string    `json:"title" gorm:"column:title;not null"`
-	Description          string    `json:"description" gorm:"column:description;type:text"`
-	Category             string    `json:"category" gorm:"column:category;not null"`
-	Region               string    `json:"region" gorm:"column:region;not null"`
-	Country              string    `json:"country" gorm:"column:country;not null"`
-	VerificationStandard string    `json:"verification_standard" gorm:"column:verification_standard;not null"`
-	PricePerTonne        float64   `json:"price_per_tonne" gorm:"column:price_per_tonne;not null"`
-	TotalCapacity        float64   `json:"total_capacity" gorm:"column:total_capacity"`
-	AvailableCapacity    float64   `json:"available_capacity" gorm:"column:available_capacity"`
-	ProjectDeveloper     string    `json:"project_developer" gorm:"column:project_developer"`
-	ProjectURL           string    `json:"project_url" gorm:"column:project_url"`
-	ImageURL             string    `json:"image_url" gorm:"column:image_url"`
-	Status               string    `json:"status" gorm:"column:status;default:'active'"`
-	CreatedAt            time.Time `json:"created_at" gorm:"column:created_at"`
-	UpdatedAt            time.Time `json:"updated_at" gorm:"column:updated_at"`
+	ID                   uint        `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	Title                string      `json:"title" gorm:"column:title;not null"`
+	Description          string      `json:"description" gorm:"column:description;type:text"`
+	Category             string      `json:"category" gorm:"column:category;not null"`
+	Region               string      `json:"region" gorm:"column:region;not null"`
+	Country              string      `json:"country" gorm:"column:country;not null"`
+	VerificationStandard string      `json:"verification_standard" gorm:"column:verification_standard;not null"`
+	PricePerTonne        float64     `json:"price_per_tonne" gorm:"column:price_per_tonne;not null"`
+	PriceTiers           []PriceTier `json:"price_tiers,omitempty" gorm:"column:price_tiers;serializer:json"`
+	TotalCapacity        float64     `json:"total_capacity" gorm:"column:total_capacity"`
+	AvailableCapacity    float64     `json:"available_capacity" gorm:"column:available_capacity"`
+	ProjectDeveloper     string      `json:"project_developer" gorm:"column:project_developer"`
+	ProjectURL           string      `json:"project_url" gorm:"column:project_url"`
+	ImageURL             string      `json:"image_url" gorm:"column:image_url"`
+	Status               string      `json:"status" gorm:"column:status;default:'draft'"`
+	Vintages             []Vintage   `json:"vintages,omitempty" gorm:"foreignKey:ProjectID"`
+	CreatedAt            time.Time   `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt            time.Time   `json:"updated_at" gorm:"column:updated_at"`
+
+	// ExternalRef is the project's key in the registry or system it was
+	// imported from; bulk imports upsert by it
+	ExternalRef *string `json:"external_ref,omitempty" gorm:"column:external_ref;uniqueIndex"`
+
+	// DeveloperID is the developer account that owns the project, which may
+	// edit its description and media, submit it for review, upload its
+	// monitoring reports and see its sales
+	DeveloperID *uint `json:"developer_id,omitempty" gorm:"column:developer_id;index"`
+
+	// Latitude and Longitude locate the project on the map and are what the
+	// geo search filters match. Boundary is the optional outline of the
+	// project area, drawn on maps but not searched.
+	Latitude  *float64  `json:"latitude,omitempty" gorm:"column:latitude"`
+	Longitude *float64  `json:"longitude,omitempty" gorm:"column:longitude"`
+	Boundary  *Geometry `json:"boundary,omitempty" gorm:"column:boundary;type:jsonb;serializer:json"`
+
+	// Quality metadata: the crediting methodology, whether credits remove
+	// or avoid emissions through nature or technology, how many years the
+	// storage is expected to last, the share of issued tonnes withheld into
+	// the buffer pool, the case for additionality and the SDG co-benefits.
+	// BufferCapacity is the tonnes withheld, which cannot be sold.
+	MethodologyID      string   `json:"methodology_id" gorm:"column:methodology_id;index"`
+	CreditType         string   `json:"credit_type" gorm:"column:credit_type;index"`
+	SolutionType       string   `json:"solution_type" gorm:"column:solution_type;index"`
+	PermanenceYears    int      `json:"permanence_years" gorm:"column:permanence_years"`
+	BufferPoolPercent  float64  `json:"buffer_pool_percent" gorm:"column:buffer_pool_percent"`
+	BufferCapacity     float64  `json:"buffer_capacity" gorm:"column:buffer_capacity"`
+	AdditionalityNotes string   `json:"additionality_notes" gorm:"column:additionality_notes;type:text"`
+	SDGGoals           SDGGoals `json:"sdg_goals" gorm:"column:sdg_goals;type:jsonb;serializer:json"`
 }
 
+// CreateProjectRequest is a new project. The available capacity may not
+// exceed the total, and is cut to what the buffer pool leaves sellable; a
+// location takes both a latitude and a longitude and a boundary needs a
+// location.
 type CreateProjectRequest struct {
-	Title                string  `json:"title" validate:"required"`
-	Description          string  `json:"description" validate:"required"`
-	Category             string  `json:"category" validate:"required"`
-	Region               string  `json:"region" validate:"required"`
-	Country              string  `json:"country" validate:"required"`
-	VerificationStandard string  `json:"verification_standard" validate:"required"`
-	PricePerTonne        float64 `json:"price_per_tonne" validate:"required,gt=0"`
-	TotalCapacity        float64 `json:"total_capacity"`
-	AvailableCapacity    float64 `json:"available_capacity"`
-	ProjectDeveloper     string  `json:"project_developer"`
-	ProjectURL           string  `json:"project_url"`
-	ImageURL             string  `json:"image_url"`
+	ExternalRef          string    `json:"external_ref" validate:"max=100"`
+	Title                string    `json:"title" validate:"required,max=200"`
+	Description          string    `json:"description" validate:"required"`
+	Category             string    `json:"category" validate:"required"`
+	Region               string    `json:"region" validate:"required"`
+	Country              string    `json:"country" validate:"required"`
+	VerificationStandard string    `json:"verification_standard" validate:"required"`
+	PricePerTonne        float64   `json:"price_per_tonne" validate:"required,gt=0"`
+	TotalCapacity        float64   `json:"total_capacity" validate:"min=0"`
+	AvailableCapacity    float64   `json:"available_capacity" validate:"min=0,ltefield=TotalCapacity"`
+	ProjectDeveloper     string    `json:"project_developer"`
+	DeveloperID          *uint     `json:"developer_id"`
+	ProjectURL           string    `json:"project_url" validate:"omitempty,url"`
+	ImageURL             string    `json:"image_url" validate:"omitempty,url"`
+	Latitude             *float64  `json:"latitude" validate:"required_with=Longitude Boundary,omitempty,min=-90,max=90"`
+	Longitude            *float64  `json:"longitude" validate:"required_with=Latitude Boundary,omitempty,min=-180,max=180"`
+	Boundary             *Geometry `json:"boundary"`
+	MethodologyID        string    `json:"methodology_id" validate:"max=100"`
+	CreditType           string    `json:"credit_type" validate:"omitempty,oneof=removal avoidance"`
+	SolutionType         string    `json:"solution_type" validate:"omitempty,oneof=nature_based technological"`
+	PermanenceYears      int       `json:"permanence_years" validate:"min=0,max=10000"`
+	BufferPoolPercent    float64   `json:"buffer_pool_percent" validate:"min=0,lt=100"`
+	AdditionalityNotes   string    `json:"additionality_notes" validate:"max=5000"`
+	SDGGoals             SDGGoals  `json:"sdg_goals" validate:"unique,dive,min=1,max=17" swaggertype:"array,integer"`
 }
 
+// UpdateProjectRequest changes the non-zero fields of a project; a patch
+// can also clear them
 type UpdateProjectRequest struct {
-	Title                string  `json:"title"`
-	Description          string  `json:"description"`
-	Category             string  `json:"category"`
-	Region               string  `json:"region"`
-	Country              string  `json:"country"`
-	VerificationStandard string  `json:"verification_standard"`
-	PricePerTonne        float64 `json:"price_per_tonne"`
-	TotalCapacity        float64 `json:"total_capacity"`
-	AvailableCapacity    float64 `json:"available_capacity"`
-	ProjectDeveloper     string  `json:"project_developer"`
-	ProjectURL           string  `json:"project_url"`
-	ImageURL             string  `json:"image_url"`
-	Status               string  `json:"status"`
+	Title                string    `json:"title" validate:"max=200"`
+	Description          string    `json:"description"`
+	Category             string    `json:"category"`
+	Region               string    `json:"region"`
+	Country              string    `json:"country"`
+	VerificationStandard string    `json:"verification_standard"`
+	PricePerTonne        float64   `json:"price_per_tonne" validate:"min=0"`
+	TotalCapacity        float64   `json:"total_capacity" validate:"min=0"`
+	AvailableCapacity    float64   `json:"available_capacity" validate:"min=0"`
+	ProjectDeveloper     string    `json:"project_developer"`
+	DeveloperID          *uint     `json:"developer_id"`
+	ProjectURL           string    `json:"project_url" validate:"omitempty,url"`
+	ImageURL             string    `json:"image_url" validate:"omitempty,url"`
+	Latitude             *float64  `json:"latitude" validate:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude            *float64  `json:"longitude" validate:"required_with=Latitude,omitempty,min=-180,max=180"`
+	Boundary             *Geometry `json:"boundary"`
+	MethodologyID        string    `json:"methodology_id" validate:"max=100"`
+	CreditType           string    `json:"credit_type" validate:"omitempty,oneof=removal avoidance"`
+	SolutionType         string    `json:"solution_type" validate:"omitempty,oneof=nature_based technological"`
+	PermanenceYears      int       `json:"permanence_years" validate:"min=0,max=10000"`
+	BufferPoolPercent    float64   `json:"buffer_pool_percent" validate:"min=0,lt=100"`
+	AdditionalityNotes   string    `json:"additionality_notes" validate:"max=5000"`
+	SDGGoals             SDGGoals  `json:"sdg_goals" validate:"unique,dive,min=1,max=17" swaggertype:"array,integer"`
+	// Reason is kept with the update's revision, not on the project
+	Reason string `json:"reason" validate:"max=2000"`
+}
+
+// DeveloperUpdateRequest is the part of a project its developer may change:
+// the description and media. Empty values leave their fields unchanged.
+type DeveloperUpdateRequest struct {
+	Description string `json:"description"`
+	ImageURL    string `json:"image_url" validate:"omitempty,url"`
+	ProjectURL  string `json:"project_url" validate:"omitempty,url"`
+	Reason      string `json:"reason" validate:"max=2000"`
+}
+
+// ProjectUpdate is the update a developer's request makes
+func (r *DeveloperUpdateRequest) ProjectUpdate() *UpdateProjectRequest {
+	return &UpdateProjectRequest{
+		Description: r.Description,
+		ImageURL:    r.ImageURL,
+		ProjectURL:  r.ProjectURL,
+		Reason:      r.Reason,
+	}
 }
 
 type ProjectSearchRequest struct {
-	Query    string   `json:"query"`
-	Category []string `json:"category"`
-	Region   []string `json:"region"`
-	Country  []string `json:"country"`
-	MinPrice float64  `json:"min_price"`
-	MaxPrice float64  `json:"max_price"`
-	Limit    int      `json:"limit"`
-	Offset   int      `json:"offset"`
+	Query                string   `json:"query"`
+	Category             []string `json:"category"`
+	Region               []string `json:"region"`
+	Country              []string `json:"country"`
+	VerificationStandard []string `json:"verification_standard"`
+	Vintage              []int    `json:"vintage"`
+	MinPrice             float64  `json:"min_price"`
+	MaxPrice             float64  `json:"max_price"`
+	// The quality filters keep projects with one of the methodologies,
+	// credit types, solution types or SDG goals, storage lasting at least
+	// MinPermanenceYears and at most MaxBufferPoolPercent withheld
+	MethodologyID        []string `json:"methodology_id"`
+	CreditType           []string `json:"credit_type" validate:"dive,oneof=removal avoidance"`
+	SolutionType         []string `json:"solution_type" validate:"dive,oneof=nature_based technological"`
+	SDGGoals             []int    `json:"sdg_goals" validate:"dive,min=1,max=17"`
+	MinPermanenceYears   int      `json:"min_permanence_years" validate:"min=0"`
+	MaxBufferPoolPercent float64  `json:"max_buffer_pool_percent" validate:"min=0"`
+	// Near and BBox keep the projects located within a radius of a point
+	// or inside a bounding box
+	Near *GeoCircle `json:"near"`
+	BBox *GeoBox    `json:"bbox"`
+	// Located keeps only projects with a location, for the map feed
+	Located bool   `json:"-"`
+	Sort    string `json:"sort"`
+	Cursor  string `json:"cursor"`
+	Limit   int    `json:"limit"`
+	Offset  int    `json:"offset"`
+}
+
+// ProjectHit is a search result. Score and Highlights are only set when the
+// search engine ranks results, Highlights holding the matched title and
+// description fragments with the terms wrapped in <em> tags.
+type ProjectHit struct {
+	Project
+	Score      *float64            `json:"score,omitempty"`
+	Highlights map[string][]string `json:"highlights,omitempty"`
+}
+
+// ProjectSearchResult is a page of search hits, the total number of
+// projects matching the search, the facet counts and the cursor of the next
+// page, empty on the last one
+type ProjectSearchResult struct {
+	Hits       []ProjectHit
+	Total      int64
+	Facets     ProjectFacets
+	NextCursor string
 }
 
 type ProjectResponse struct {
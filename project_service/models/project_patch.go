@@ -0,0 +1,146 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ProjectPatch is a JSON Merge Patch (RFC 7396) of a project's editable
+// fields: a member sets its field, a null member clears it to its zero
+// value and an absent member leaves it unchanged. Object fields such as the
+// boundary are merged the same way, member by member.
+type ProjectPatch map[string]json.RawMessage
+
+// PatchError is why a member of a patch cannot be applied
+type PatchError struct {
+	Field   string
+	Message string
+}
+
+func (e *PatchError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Apply merges the patch into a project's editable fields, the ones a
+// revert restores. The status, which only moves through the lifecycle, the
+// external reference and anything that is not a field are a *PatchError.
+func (p ProjectPatch) Apply(project *Project) error {
+	document := FieldsOf(project).Revertible()
+	for field, value := range p {
+		if _, ok := document[field]; !ok {
+			return &PatchError{Field: field, Message: unpatchable(field)}
+		}
+		if string(value) == "null" {
+			delete(document, field)
+			continue
+		}
+		current, err := json.Marshal(document[field])
+		if err != nil {
+			return err
+		}
+		merged, err := mergePatch(current, value)
+		if err != nil {
+			return err
+		}
+		document[field] = merged
+	}
+
+	merged, err := json.Marshal(document)
+	if err != nil {
+		return err
+	}
+	var fields ProjectFields
+	if err := json.Unmarshal(merged, &fields); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &PatchError{Field: typeErr.Field, Message: "must be a " + kindName(typeErr.Type)}
+		}
+		return err
+	}
+	fields.Restore(project)
+	return nil
+}
+
+// mergePatch applies a merge patch to a JSON value: an object patch merges
+// into an object member by member, its null members deleting, and any other
+// patch replaces the value
+func mergePatch(target, patch json.RawMessage) (json.RawMessage, error) {
+	var members map[string]json.RawMessage
+	if json.Unmarshal(patch, &members) != nil || members == nil {
+		return patch, nil
+	}
+	var document map[string]json.RawMessage
+	if json.Unmarshal(target, &document) != nil || document == nil {
+		document = map[string]json.RawMessage{}
+	}
+	for name, value := range members {
+		if string(value) == "null" {
+			delete(document, name)
+			continue
+		}
+		merged, err := mergePatch(document[name], value)
+		if err != nil {
+			return nil, err
+		}
+		document[name] = merged
+	}
+	return json.Marshal(document)
+}
+
+// Restore sets a project's editable fields to these ones
+func (f ProjectFields) Restore(project *Project) {
+	project.Title = f.Title
+	project.Description = f.Description
+	project.Category = f.Category
+	project.Region = f.Region
+	project.Country = f.Country
+	project.VerificationStandard = f.VerificationStandard
+	project.PricePerTonne = f.PricePerTonne
+	project.TotalCapacity = f.TotalCapacity
+	project.AvailableCapacity = f.AvailableCapacity
+	project.ProjectDeveloper = f.ProjectDeveloper
+	project.DeveloperID = f.DeveloperID
+	project.ProjectURL = f.ProjectURL
+	project.ImageURL = f.ImageURL
+	project.Latitude = f.Latitude
+	project.Longitude = f.Longitude
+	project.Boundary = f.Boundary
+	project.MethodologyID = f.MethodologyID
+	project.CreditType = f.CreditType
+	project.SolutionType = f.SolutionType
+	project.PermanenceYears = f.PermanenceYears
+	project.BufferPoolPercent = f.BufferPoolPercent
+	project.AdditionalityNotes = f.AdditionalityNotes
+	project.SDGGoals = f.SDGGoals
+}
+
+// unpatchable explains why a patch may not set a field
+func unpatchable(field string) string {
+	switch field {
+	case "status":
+		return "only changes through transitions"
+	case "buffer_capacity":
+		return "follows the total capacity and buffer pool percentage"
+	case "external_ref", "id", "price_tiers", "vintages", "created_at", "updated_at":
+		return "cannot be patched"
+	default:
+		return "is not a project field"
+	}
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int64, reflect.Uint:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Struct:
+		return "JSON object"
+	case reflect.Slice:
+		return "JSON array"
+	default:
+		return fmt.Sprint(t.Kind())
+	}
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"math"
+)
+
+// Credit types: whether a project's credits remove carbon from the
+// atmosphere or avoid emitting it
+const (
+	CreditRemoval   = "removal"
+	CreditAvoidance = "avoidance"
+)
+
+// Solution types: whether a project works through natural systems or
+// engineered ones
+const (
+	SolutionNatureBased   = "nature_based"
+	SolutionTechnological = "technological"
+)
+
+// SDGGoals are the UN Sustainable Development Goals, numbered 1 to 17, a
+// project claims as co-benefits
+type SDGGoals []int
+
+// Value stores the goals as a JSON array, also when they are written from a
+// map of columns. No goals are stored as NULL.
+func (g SDGGoals) Value() (driver.Value, error) {
+	if len(g) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal([]int(g))
+	return string(data), err
+}
+
+// Has reports whether one of the goals is among these
+func (g SDGGoals) Has(goals []int) bool {
+	for _, have := range g {
+		for _, goal := range goals {
+			if have == goal {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BufferWithheld is the tonnes of a total capacity a buffer pool percentage
+// withholds against reversals
+func BufferWithheld(total, percent float64) float64 {
+	return total * percent / 100
+}
+
+// SellableCapacity is the total capacity less the tonnes withheld into the
+// buffer pool
+func (p *Project) SellableCapacity() float64 {
+	return p.TotalCapacity - p.BufferCapacity
+}
+
+// WithholdBuffer sets the tonnes the project withholds into its buffer pool
+// from its total capacity and buffer percentage, withheldBefore being what
+// it withheld before the write. Unless the write set the available capacity
+// itself, the change in withheld tonnes comes out of it, or goes back to
+// it, without going below zero. Either way it never exceeds the sellable
+// capacity.
+func (p *Project) WithholdBuffer(withheldBefore float64, availableSet bool) {
+	withheld := BufferWithheld(p.TotalCapacity, p.BufferPoolPercent)
+	if !availableSet {
+		p.AvailableCapacity = math.Max(0, p.AvailableCapacity-(withheld-withheldBefore))
+	}
+	p.BufferCapacity = withheld
+	p.AvailableCapacity = math.Min(p.AvailableCapacity, p.SellableCapacity())
+}
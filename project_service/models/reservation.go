@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// Reservation states. A held reservation has taken its tonnes out of the
+// project's available capacity; committing keeps them out for good, while
+// releasing or expiring returns them.
+const (
+	ReservationHeld      = "held"
+	ReservationCommitted = "committed"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Capacity ledger events. A revert of a project that moves its available
+// capacity has no reservation.
+const (
+	LedgerReserve = "reserve"
+	LedgerCommit  = "commit"
+	LedgerRelease = "release"
+	LedgerExpire  = "expire"
+	LedgerRevert  = "revert"
+)
+
+// Reservation holds tonnes of a project's capacity, typically for a
+// checkout, until it is committed, released or expires. A reservation with a
+// VintageID holds the tonnes of that vintage's capacity too.
+type Reservation struct {
+	ID          string     `json:"id" gorm:"column:id;primaryKey;size:36"`
+	ProjectID   uint       `json:"project_id" gorm:"column:project_id;not null;index"`
+	VintageID   uint       `json:"vintage_id,omitempty" gorm:"column:vintage_id;index"`
+	Tonnes      float64    `json:"tonnes" gorm:"column:tonnes;not null"`
+	Reference   string     `json:"reference,omitempty" gorm:"column:reference;index"`
+	Status      string     `json:"status" gorm:"column:status;not null;index:idx_reservations_status_expires"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"column:expires_at;not null;index:idx_reservations_status_expires"`
+	CommittedAt *time.Time `json:"committed_at,omitempty" gorm:"column:committed_at"`
+	ReleasedAt  *time.Time `json:"released_at,omitempty" gorm:"column:released_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// ProjectSales are the committed reservations of a project, newest first,
+// with the tonnes sold and the number of sales across all pages
+type ProjectSales struct {
+	ProjectID  uint          `json:"project_id"`
+	TonnesSold float64       `json:"tonnes_sold"`
+	SaleCount  int64         `json:"sale_count"`
+	Sales      []Reservation `json:"sales"`
+}
+
+// CapacityLedgerEntry records a change to a project's available capacity
+// or the commit of a reservation. Delta is the change in tonnes, zero for a
+// commit, and AvailableAfter the capacity once it applied.
+type CapacityLedgerEntry struct {
+	ID             uint      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID      uint      `json:"project_id" gorm:"column:project_id;not null;index"`
+	VintageID      uint      `json:"vintage_id,omitempty" gorm:"column:vintage_id"`
+	ReservationID  string    `json:"reservation_id" gorm:"column:reservation_id;size:36;index"`
+	Event          string    `json:"event" gorm:"column:event;not null"`
+	Delta          float64   `json:"delta" gorm:"column:delta;not null"`
+	AvailableAfter float64   `json:"available_after" gorm:"column:available_after;not null"`
+	CreatedAt      time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// ReserveCapacityRequest asks to hold tonnes of a project, and of one of its
+// vintages when VintageID is set. TTLSeconds defaults to the service's
+// reservation TTL.
+type ReserveCapacityRequest struct {
+	ProjectID  uint    `json:"project_id" validate:"required"`
+	VintageID  uint    `json:"vintage_id"`
+	Tonnes     float64 `json:"tonnes" validate:"required,gt=0"`
+	Reference  string  `json:"reference" validate:"max=255"`
+	TTLSeconds int     `json:"ttl_seconds" validate:"min=0,max=86400"`
+}
+
+// CommitReservationsRequest commits reservations together, such as the
+// lines of one order
+type CommitReservationsRequest struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=100,dive,required"`
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Revision operations
+const (
+	RevisionCreate = "create"
+	RevisionUpdate = "update"
+	RevisionStatus = "status"
+	RevisionDelete = "delete"
+	RevisionRevert = "revert"
+)
+
+// Change is who makes a write to a project and why. Changes made from the
+// command line have no actor.
+type Change struct {
+	Actor  Actor
+	Reason string
+}
+
+// ProjectFields are the fields of a project its revisions keep. Their
+// rules are the ones a project must meet after an update or a patch.
+type ProjectFields struct {
+	ExternalRef          *string   `json:"external_ref,omitempty"`
+	Title                string    `json:"title" validate:"required,max=200"`
+	Description          string    `json:"description" validate:"required"`
+	Category             string    `json:"category" validate:"required"`
+	Region               string    `json:"region" validate:"required"`
+	Country              string    `json:"country" validate:"required"`
+	VerificationStandard string    `json:"verification_standard" validate:"required"`
+	PricePerTonne        float64   `json:"price_per_tonne" validate:"gt=0"`
+	TotalCapacity        float64   `json:"total_capacity" validate:"min=0"`
+	AvailableCapacity    float64   `json:"available_capacity" validate:"min=0,ltefield=TotalCapacity"`
+	ProjectDeveloper     string    `json:"project_developer"`
+	DeveloperID          *uint     `json:"developer_id"`
+	ProjectURL           string    `json:"project_url" validate:"omitempty,url"`
+	ImageURL             string    `json:"image_url" validate:"omitempty,url"`
+	Latitude             *float64  `json:"latitude" validate:"required_with=Longitude Boundary,omitempty,min=-90,max=90"`
+	Longitude            *float64  `json:"longitude" validate:"required_with=Latitude Boundary,omitempty,min=-180,max=180"`
+	Boundary             *Geometry `json:"boundary"`
+	MethodologyID        string    `json:"methodology_id" validate:"max=100"`
+	CreditType           string    `json:"credit_type" validate:"omitempty,oneof=removal avoidance"`
+	SolutionType         string    `json:"solution_type" validate:"omitempty,oneof=nature_based technological"`
+	PermanenceYears      int       `json:"permanence_years" validate:"min=0,max=10000"`
+	BufferPoolPercent    float64   `json:"buffer_pool_percent" validate:"min=0,lt=100"`
+	AdditionalityNotes   string    `json:"additionality_notes" validate:"max=5000"`
+	SDGGoals             SDGGoals  `json:"sdg_goals" validate:"unique,dive,min=1,max=17"`
+	Status               string    `json:"status"`
+}
+
+// FieldsOf returns the kept fields of a project
+func FieldsOf(project *Project) ProjectFields {
+	return ProjectFields{
+		ExternalRef:          project.ExternalRef,
+		Title:                project.Title,
+		Description:          project.Description,
+		Category:             project.Category,
+		Region:               project.Region,
+		Country:              project.Country,
+		VerificationStandard: project.VerificationStandard,
+		PricePerTonne:        project.PricePerTonne,
+		TotalCapacity:        project.TotalCapacity,
+		AvailableCapacity:    project.AvailableCapacity,
+		ProjectDeveloper:     project.ProjectDeveloper,
+		DeveloperID:          project.DeveloperID,
+		ProjectURL:           project.ProjectURL,
+		ImageURL:             project.ImageURL,
+		Latitude:             project.Latitude,
+		Longitude:            project.Longitude,
+		Boundary:             project.Boundary,
+		MethodologyID:        project.MethodologyID,
+		CreditType:           project.CreditType,
+		SolutionType:         project.SolutionType,
+		PermanenceYears:      project.PermanenceYears,
+		BufferPoolPercent:    project.BufferPoolPercent,
+		AdditionalityNotes:   project.AdditionalityNotes,
+		SDGGoals:             project.SDGGoals,
+		Status:               project.Status,
+	}
+}
+
+// Revertible returns the columns a revert restores: every kept field but
+// the status, which only moves through the lifecycle, and the external
+// reference, which identifies the project
+func (f ProjectFields) Revertible() map[string]interface{} {
+	return map[string]interface{}{
+		"title":                 f.Title,
+		"description":           f.Description,
+		"category":              f.Category,
+		"region":                f.Region,
+		"country":               f.Country,
+		"verification_standard": f.VerificationStandard,
+		"price_per_tonne":       f.PricePerTonne,
+		"total_capacity":        f.TotalCapacity,
+		"available_capacity":    f.AvailableCapacity,
+		"project_developer":     f.ProjectDeveloper,
+		"developer_id":          f.DeveloperID,
+		"project_url":           f.ProjectURL,
+		"image_url":             f.ImageURL,
+		"latitude":              f.Latitude,
+		"longitude":             f.Longitude,
+		"boundary":              f.Boundary,
+		"methodology_id":        f.MethodologyID,
+		"credit_type":           f.CreditType,
+		"solution_type":         f.SolutionType,
+		"permanence_years":      f.PermanenceYears,
+		"buffer_pool_percent":   f.BufferPoolPercent,
+		"additionality_notes":   f.AdditionalityNotes,
+		"sdg_goals":             f.SDGGoals,
+	}
+}
+
+// FieldChange is a field's value before and after a change
+type FieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Diff returns the fields that differ between before and after by JSON
+// name. Every field of after is in the diff of a create, when before is
+// nil.
+func Diff(before *ProjectFields, after ProjectFields) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+	to := reflect.ValueOf(after)
+	for i := 0; i < to.NumField(); i++ {
+		name := strings.Split(to.Type().Field(i).Tag.Get("json"), ",")[0]
+		value := plain(to.Field(i))
+		if before == nil {
+			if value != nil {
+				changes[name] = FieldChange{To: value}
+			}
+			continue
+		}
+		if old := plain(reflect.ValueOf(*before).Field(i)); !reflect.DeepEqual(old, value) {
+			changes[name] = FieldChange{From: old, To: value}
+		}
+	}
+	return changes
+}
+
+// plain returns a field's value, dereferencing pointers, nil for nil ones
+// and empty slices
+func plain(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+	}
+	return v.Interface()
+}
+
+// ProjectRevision is an immutable record of one write to a project: who
+// made it, when and why, the fields it changed and the project's fields
+// after it. RevertedTo is the revision a revert restored.
+type ProjectRevision struct {
+	ID         uint                   `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID  uint                   `json:"project_id" gorm:"column:project_id;not null;index:idx_project_revisions_project_created"`
+	Operation  string                 `json:"operation" gorm:"column:operation;not null"`
+	ActorID    uint                   `json:"actor_id" gorm:"column:actor_id"`
+	ActorRole  string                 `json:"actor_role" gorm:"column:actor_role"`
+	Reason     string                 `json:"reason,omitempty" gorm:"column:reason;type:text"`
+	Changes    map[string]FieldChange `json:"changes" gorm:"column:changes;serializer:json"`
+	Snapshot   ProjectFields          `json:"snapshot" gorm:"column:snapshot;serializer:json"`
+	RevertedTo *uint                  `json:"reverted_to,omitempty" gorm:"column:reverted_to"`
+	CreatedAt  time.Time              `json:"created_at" gorm:"column:created_at;not null;index:idx_project_revisions_project_created"`
+}
+
+// NewRevision returns the revision of a write that took a project's fields
+// from before, nil for a create, to after. It is nil when nothing changed.
+func NewRevision(projectID uint, operation string, before *ProjectFields, after ProjectFields, change Change, at time.Time) *ProjectRevision {
+	changes := Diff(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	return &ProjectRevision{
+		ProjectID: projectID,
+		Operation: operation,
+		ActorID:   change.Actor.ID,
+		ActorRole: change.Actor.Role,
+		Reason:    change.Reason,
+		Changes:   changes,
+		Snapshot:  after,
+		CreatedAt: at,
+	}
+}
+
+// RevertProjectRequest restores a project's fields to a revision
+type RevertProjectRequest struct {
+	Reason string `json:"reason" validate:"max=2000"`
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Serial block statuses
+const (
+	SerialAvailable = "available"
+	SerialAllocated = "allocated"
+)
+
+// ErrInvalidSerial is returned for a serial number that is not a prefix and
+// a number joined by a hyphen
+var ErrInvalidSerial = errors.New("serial must be a registry prefix and a number, such as VCS-VCU-1234-2021-1001")
+
+// SerialBlock is a contiguous range of registry serial numbers, one per
+// tonne, issued for a vintage of a project. Serials are Prefix-Start to
+// Prefix-End inclusive. A block is available until it is allocated to an
+// order; allocating part of a block splits off the rest as a new available
+// block.
+type SerialBlock struct {
+	ID          uint       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID   uint       `json:"project_id" gorm:"column:project_id;not null;index"`
+	VintageID   uint       `json:"vintage_id" gorm:"column:vintage_id;not null;index"`
+	Registry    string     `json:"registry" gorm:"column:registry;not null"`
+	Prefix      string     `json:"prefix" gorm:"column:prefix;not null;index:idx_serial_blocks_prefix_range"`
+	Start       int64      `json:"start" gorm:"column:serial_start;not null;index:idx_serial_blocks_prefix_range"`
+	End         int64      `json:"end" gorm:"column:serial_end;not null"`
+	OrderID     string     `json:"order_id,omitempty" gorm:"column:order_id;index"`
+	AllocatedAt *time.Time `json:"allocated_at,omitempty" gorm:"column:allocated_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+// Quantity is the number of serials, and so tonnes, in the block
+func (b *SerialBlock) Quantity() int64 {
+	return b.End - b.Start + 1
+}
+
+// Status is whether the block is available or allocated
+func (b *SerialBlock) Status() string {
+	if b.OrderID == "" {
+		return SerialAvailable
+	}
+	return SerialAllocated
+}
+
+// Serials is the block as a registry range, Prefix-Start-End
+func (b *SerialBlock) Serials() string {
+	return fmt.Sprintf("%s-%d-%d", b.Prefix, b.Start, b.End)
+}
+
+// Contains reports whether a serial is in the block
+func (b *SerialBlock) Contains(prefix string, number int64) bool {
+	return b.Prefix == prefix && b.Start <= number && number <= b.End
+}
+
+// Overlaps reports whether two blocks share a serial
+func (b *SerialBlock) Overlaps(other *SerialBlock) bool {
+	return b.Prefix == other.Prefix && b.Start <= other.End && other.Start <= b.End
+}
+
+// ParseSerial splits a serial into its prefix and number at the last hyphen
+func ParseSerial(serial string) (string, int64, error) {
+	i := strings.LastIndex(serial, "-")
+	if i <= 0 {
+		return "", 0, ErrInvalidSerial
+	}
+	number, err := strconv.ParseInt(serial[i+1:], 10, 64)
+	if err != nil || number < 1 {
+		return "", 0, ErrInvalidSerial
+	}
+	return serial[:i], number, nil
+}
+
+// SerialBlockResponse is a serial block with its quantity, status and range
+type SerialBlockResponse struct {
+	SerialBlock
+	Quantity int64  `json:"quantity"`
+	Status   string `json:"status"`
+	Serials  string `json:"serials"`
+}
+
+// NewSerialBlockResponse returns a block's response
+func NewSerialBlockResponse(block SerialBlock) SerialBlockResponse {
+	return SerialBlockResponse{SerialBlock: block, Quantity: block.Quantity(), Status: block.Status(), Serials: block.Serials()}
+}
+
+// IssueSerialsRequest records a block of serials a registry issued for a
+// vintage of the project. End is inclusive and may not be below Start.
+type IssueSerialsRequest struct {
+	VintageID uint   `json:"vintage_id" validate:"required"`
+	Registry  string `json:"registry" validate:"required,max=100"`
+	Prefix    string `json:"prefix" validate:"required,max=100,excludesall= /?#"`
+	Start     int64  `json:"start" validate:"required,min=1"`
+	End       int64  `json:"end" validate:"required,min=1"`
+}
+
+// AllocateSerialsRequest allocates serials for the whole tonnes of a
+// completed order, from one vintage of the project when VintageID is set
+type AllocateSerialsRequest struct {
+	OrderID   string  `json:"order_id" validate:"required,max=64"`
+	ProjectID uint    `json:"project_id" validate:"required"`
+	VintageID uint    `json:"vintage_id"`
+	Tonnes    float64 `json:"tonnes" validate:"required,gt=0"`
+}
+
+// SerialAllocation is the serials allocated to an order
+type SerialAllocation struct {
+	OrderID  string                `json:"order_id"`
+	Quantity int64                 `json:"quantity"`
+	Blocks   []SerialBlockResponse `json:"blocks"`
+}
+
+// NewSerialAllocation returns the allocation of an order's blocks
+func NewSerialAllocation(orderID string, blocks []SerialBlock) *SerialAllocation {
+	allocation := &SerialAllocation{OrderID: orderID, Blocks: []SerialBlockResponse{}}
+	for _, block := range blocks {
+		allocation.Quantity += block.Quantity()
+		allocation.Blocks = append(allocation.Blocks, NewSerialBlockResponse(block))
+	}
+	return allocation
+}
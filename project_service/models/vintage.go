@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Vintage is the credits a project issued for one year. Buyers choose a
+// vintage, so each has its own price and capacity; the project's own price
+// and capacity remain its headline figures.
+type Vintage struct {
+	ID                uint       `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ProjectID         uint       `json:"project_id" gorm:"column:project_id;not null;uniqueIndex:idx_vintages_project_year"`
+	Year              int        `json:"year" gorm:"column:year;not null;uniqueIndex:idx_vintages_project_year;index"`
+	PricePerTonne     float64    `json:"price_per_tonne" gorm:"column:price_per_tonne;not null"`
+	TotalCapacity     float64    `json:"total_capacity" gorm:"column:total_capacity"`
+	AvailableCapacity float64    `json:"available_capacity" gorm:"column:available_capacity"`
+	IssuanceDate      *time.Time `json:"issuance_date,omitempty" gorm:"column:issuance_date"`
+	CreatedAt         time.Time  `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// CreateVintageRequest adds a vintage to a project. Available capacity may
+// not exceed the total.
+type CreateVintageRequest struct {
+	Year              int        `json:"year" validate:"required,min=1990,max=2100"`
+	PricePerTonne     float64    `json:"price_per_tonne" validate:"required,gt=0"`
+	TotalCapacity     float64    `json:"total_capacity" validate:"min=0"`
+	AvailableCapacity float64    `json:"available_capacity" validate:"min=0"`
+	IssuanceDate      *time.Time `json:"issuance_date"`
+}
+
+// UpdateVintageRequest changes the non-zero fields of a vintage. The year
+// identifies the vintage and cannot change.
+type UpdateVintageRequest struct {
+	PricePerTonne     float64    `json:"price_per_tonne" validate:"min=0"`
+	TotalCapacity     float64    `json:"total_capacity" validate:"min=0"`
+	AvailableCapacity float64    `json:"available_capacity" validate:"min=0"`
+	IssuanceDate      *time.Time `json:"issuance_date"`
+}
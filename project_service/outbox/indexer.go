@@ -0,0 +1,44 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"project_service/models"
+	"project_service/repositories"
+
+	"gorm.io/gorm"
+)
+
+// ProjectLoader reads a project as it is now
+type ProjectLoader interface {
+	GetByID(id uint) (*models.Project, error)
+}
+
+// SearchIndexer keeps a search backend in line with project events. It
+// indexes the project as it is when the event is handled, not as the
+// event describes it, so repeated and late events leave the right
+// document.
+type SearchIndexer struct {
+	projects ProjectLoader
+	backend  repositories.SearchBackend
+}
+
+func NewSearchIndexer(projects ProjectLoader, backend repositories.SearchBackend) *SearchIndexer {
+	return &SearchIndexer{projects: projects, backend: backend}
+}
+
+// Handle indexes the event's project if it is published and removes it
+// from the index otherwise
+func (s *SearchIndexer) Handle(ctx context.Context, event models.ProjectEvent) error {
+	project, err := s.projects.GetByID(event.ProjectID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return s.backend.Remove(event.ProjectID)
+	}
+	if err != nil {
+		return err
+	}
+	if project.Status == models.StatusPublished {
+		return s.backend.Index(project)
+	}
+	return s.backend.Remove(project.ID)
+}
@@ -0,0 +1,137 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"project_service/models"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrNotConfirmed is returned when the broker refuses a published event
+var ErrNotConfirmed = errors.New("broker did not confirm the event")
+
+// RabbitMQPublisher publishes events to a durable topic exchange, routed
+// by their type. Every event is persistent and carries its ID as the
+// message ID, and is only taken once the broker confirms it. The
+// connection is opened on the first event and reopened after a failure,
+// so the relay keeps retrying while the broker is down.
+type RabbitMQPublisher struct {
+	url      string
+	exchange string
+
+	mu       sync.Mutex
+	conn     *amqp.Connection
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+}
+
+func NewRabbitMQPublisher(url, exchange string) *RabbitMQPublisher {
+	return &RabbitMQPublisher{url: url, exchange: exchange}
+}
+
+// Handle publishes an event and waits for the broker's confirmation
+func (p *RabbitMQPublisher) Handle(ctx context.Context, event models.ProjectEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.connect(); err != nil {
+		return err
+	}
+	err = p.channel.Publish(
+		p.exchange, // exchange
+		event.Type, // routing key
+		false,      // mandatory
+		false,      // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			DeliveryMode: amqp.Persistent,
+			MessageId:    event.ID,
+			Type:         event.Type,
+			Timestamp:    event.OccurredAt,
+			Body:         body,
+		},
+	)
+	if err != nil {
+		p.reset()
+		return err
+	}
+
+	select {
+	case confirm, ok := <-p.confirms:
+		if !ok {
+			p.reset()
+			return amqp.ErrClosed
+		}
+		if !confirm.Ack {
+			return ErrNotConfirmed
+		}
+		return nil
+	case <-ctx.Done():
+		// A late confirmation would be taken for the next event's
+		p.reset()
+		return ctx.Err()
+	}
+}
+
+// Close closes the connection
+func (p *RabbitMQPublisher) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+}
+
+// connect opens a confirmed channel and declares the exchange, unless one
+// is open. The caller holds the lock.
+func (p *RabbitMQPublisher) connect() error {
+	if p.channel != nil {
+		return nil
+	}
+
+	conn, err := amqp.Dial(p.url)
+	if err != nil {
+		return err
+	}
+	channel, err := conn.Channel()
+	if err == nil {
+		err = channel.Confirm(false)
+	}
+	if err == nil {
+		err = channel.ExchangeDeclare(
+			p.exchange, // name
+			"topic",    // kind
+			true,       // durable
+			false,      // auto-delete
+			false,      // internal
+			false,      // no-wait
+			nil,        // args
+		)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	p.conn, p.channel = conn, channel
+	p.confirms = channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+	return nil
+}
+
+// reset closes the connection so the next event opens a new one. The
+// caller holds the lock.
+func (p *RabbitMQPublisher) reset() {
+	if p.channel != nil {
+		p.channel.Close()
+	}
+	if p.conn != nil {
+		p.conn.Close()
+	}
+	p.conn, p.channel, p.confirms = nil, nil, nil
+}
@@ -0,0 +1,119 @@
+// Package outbox relays the project events written to the outbox with each
+// project change. A relay hands every event to its handlers, which publish
+// it to RabbitMQ and bring the search index and response cache in line,
+// and marks it published once all of them took it. Delivery is at least
+// once: an event whose relay fails, or crashes before marking it, is handed
+// out again, so handlers must be idempotent.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"project_service/models"
+	"project_service/repositories"
+	"time"
+)
+
+// Relay defaults
+const (
+	// DefaultBatchSize is the number of events claimed at a time
+	DefaultBatchSize = 100
+	// lease is how long a claimed event is held before another relay may
+	// take it over
+	lease = time.Minute
+	// minBackoff and maxBackoff bound the wait before a failed event is
+	// retried, which doubles with every attempt
+	minBackoff = time.Second
+	maxBackoff = 5 * time.Minute
+)
+
+// Handler takes relayed events. It may see an event more than once.
+type Handler interface {
+	Handle(ctx context.Context, event models.ProjectEvent) error
+}
+
+// HandlerFunc is a function used as a Handler
+type HandlerFunc func(ctx context.Context, event models.ProjectEvent) error
+
+func (f HandlerFunc) Handle(ctx context.Context, event models.ProjectEvent) error {
+	return f(ctx, event)
+}
+
+// Relay hands the outbox's due events to its handlers in order
+type Relay struct {
+	store     repositories.OutboxRepository
+	handlers  []Handler
+	batchSize int
+}
+
+func NewRelay(store repositories.OutboxRepository, handlers ...Handler) *Relay {
+	return &Relay{store: store, handlers: handlers, batchSize: DefaultBatchSize}
+}
+
+// RelayDue relays the events due at now, batch by batch, and returns how
+// many were published. An event is published once every handler took it;
+// otherwise it is retried after a backoff, and the later events of its
+// project wait for it.
+func (r *Relay) RelayDue(ctx context.Context, now time.Time) (int, error) {
+	published := 0
+	for {
+		events, err := r.store.Claim(now, lease, r.batchSize)
+		if err != nil {
+			return published, err
+		}
+
+		batchPublished := 0
+		for i := range events {
+			if err := r.relay(ctx, &events[i]); err != nil {
+				log.Printf("Warning: failed to relay event %s (%s of project %d, attempt %d): %v",
+					events[i].EventID, events[i].Type, events[i].ProjectID, events[i].Attempts+1, err)
+				if err := r.store.MarkFailed(events[i].ID, err, now.Add(backoff(events[i].Attempts))); err != nil {
+					return published, err
+				}
+				continue
+			}
+			if err := r.store.MarkPublished(events[i].ID, time.Now()); err != nil {
+				return published, err
+			}
+			batchPublished++
+		}
+		published += batchPublished
+
+		// Publishing an event makes the next one of its project due, so
+		// claim again until a batch publishes nothing; failed events are
+		// not due again in this run
+		if batchPublished == 0 {
+			return published, nil
+		}
+	}
+}
+
+// Purge deletes the events published before a moment
+func (r *Relay) Purge(before time.Time) (int64, error) {
+	return r.store.Purge(before)
+}
+
+// relay hands an event to every handler, stopping at the first that fails
+func (r *Relay) relay(ctx context.Context, outboxEvent *models.OutboxEvent) error {
+	event := outboxEvent.Event()
+	for _, handler := range r.handlers {
+		if err := handler.Handle(ctx, event); err != nil {
+			return fmt.Errorf("%T: %w", handler, err)
+		}
+	}
+	return nil
+}
+
+// backoff is the wait before an event that failed attempts times before
+// is retried
+func backoff(attempts int) time.Duration {
+	wait := minBackoff
+	for i := 0; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		return maxBackoff
+	}
+	return wait
+}
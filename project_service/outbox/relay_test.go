@@ -0,0 +1,104 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"project_service/models"
+	"project_service/repositories"
+	"testing"
+	"time"
+)
+
+// recorder is a handler that keeps the events it takes and fails while
+// down is set
+type recorder struct {
+	events []models.ProjectEvent
+	down   bool
+}
+
+func (r *recorder) Handle(ctx context.Context, event models.ProjectEvent) error {
+	if r.down {
+		return errors.New("broker down")
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func TestRelayPublishesInOrderAndRetries(t *testing.T) {
+	repo := repositories.NewInMemoryProjectRepository()
+	repo.Create(&models.Project{Title: "Mangrove Restoration", PricePerTonne: 10}, models.Change{})
+	repo.Update(1, &models.UpdateProjectRequest{PricePerTonne: 12}, models.Change{})
+
+	broker := &recorder{down: true}
+	relay := NewRelay(repo, broker)
+	now := time.Now().Add(time.Second)
+
+	if published, err := relay.RelayDue(context.Background(), now); err != nil || published != 0 {
+		t.Fatalf("expected nothing published while the broker is down, got %d %v", published, err)
+	}
+	events := repo.Events()
+	if events[0].Attempts != 1 || events[0].LastError == "" || !events[0].RetryAt.Equal(now.Add(minBackoff)) {
+		t.Errorf("expected the failure recorded with a backoff, got %+v", events[0])
+	}
+
+	broker.down = false
+	if published, _ := relay.RelayDue(context.Background(), now); published != 0 {
+		t.Errorf("expected the failed event to wait for its backoff, got %d published", published)
+	}
+	published, err := relay.RelayDue(context.Background(), now.Add(minBackoff))
+	if err != nil || published != 2 {
+		t.Fatalf("expected both events published, got %d %v", published, err)
+	}
+	if len(broker.events) != 2 || broker.events[0].Type != models.EventProjectCreated ||
+		broker.events[1].Type != models.EventProjectPriceChanged || broker.events[0].Sequence >= broker.events[1].Sequence {
+		t.Errorf("expected the project's events in order, got %+v", broker.events)
+	}
+	for _, event := range repo.Events() {
+		if event.PublishedAt == nil {
+			t.Errorf("expected every event published, got %+v", event)
+		}
+	}
+}
+
+func TestSearchIndexerIsIdempotent(t *testing.T) {
+	repo := repositories.NewInMemoryProjectRepository()
+	repo.Create(&models.Project{Title: "Mangrove Restoration", PricePerTonne: 10, Status: models.StatusPublished}, models.Change{})
+	index := repositories.NewEmbeddedSearchBackend()
+	indexer := NewSearchIndexer(repo, index)
+
+	search := func() int64 {
+		t.Helper()
+		result, err := index.Search(&models.ProjectSearchRequest{Query: "mangrove", Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Total
+	}
+
+	event := repo.Events()[0].Event()
+	for i := 0; i < 2; i++ {
+		if err := indexer.Handle(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total := search(); total != 1 {
+		t.Fatalf("expected the project indexed once, got %d", total)
+	}
+
+	// A late event indexes the project as it is now, retired
+	if _, err := repo.Transition(1, models.Transitions[models.ActionRetire], models.Actor{ID: 1}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := indexer.Handle(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if total := search(); total != 0 {
+		t.Errorf("expected the retired project removed, got %d", total)
+	}
+}
+
+func TestBackoffDoublesUpToTheCap(t *testing.T) {
+	if backoff(0) != minBackoff || backoff(3) != 8*minBackoff || backoff(50) != maxBackoff {
+		t.Errorf("unexpected backoffs %v %v %v", backoff(0), backoff(3), backoff(50))
+	}
+}
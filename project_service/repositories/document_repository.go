@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"project_service/models"
+
+	"gorm.io/gorm"
+)
+
+// DocumentRepository persists the records of files attached to projects.
+// The files themselves are in blob storage. Missing projects and
+// documents, including a document of another project, return
+// gorm.ErrRecordNotFound.
+type DocumentRepository interface {
+	// List returns a project's documents, oldest first, only of the kind
+	// when one is given
+	List(projectID uint, kind string) ([]models.Document, error)
+	Get(projectID, documentID uint) (*models.Document, error)
+	Create(projectID uint, document *models.Document) error
+	// Delete removes a document's record and returns it, so its file can
+	// be removed too
+	Delete(projectID, documentID uint) (*models.Document, error)
+}
+
+// PostgresDocumentRepository keeps document records in their own table
+type PostgresDocumentRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresDocumentRepository(db *gorm.DB) *PostgresDocumentRepository {
+	return &PostgresDocumentRepository{db: db}
+}
+
+// List returns a project's documents, oldest first
+func (r *PostgresDocumentRepository) List(projectID uint, kind string) ([]models.Document, error) {
+	if err := projectExists(r.db, projectID); err != nil {
+		return nil, err
+	}
+	query := r.db.Where("project_id = ?", projectID)
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	documents := []models.Document{}
+	if err := query.Order("id").Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// Get retrieves a document of a project
+func (r *PostgresDocumentRepository) Get(projectID, documentID uint) (*models.Document, error) {
+	var document models.Document
+	if err := r.db.Where("project_id = ?", projectID).First(&document, documentID).Error; err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+// Create adds a document to a project
+func (r *PostgresDocumentRepository) Create(projectID uint, document *models.Document) error {
+	if err := projectExists(r.db, projectID); err != nil {
+		return err
+	}
+	document.ProjectID = projectID
+	return r.db.Create(document).Error
+}
+
+// Delete removes a document of a project
+func (r *PostgresDocumentRepository) Delete(projectID, documentID uint) (*models.Document, error) {
+	var document models.Document
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", projectID).First(&document, documentID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&document).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"project_service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryDocumentRepository is a DocumentRepository over an
+// InMemoryProjectRepository, which it checks projects against under its
+// lock
+type InMemoryDocumentRepository struct {
+	projects  *InMemoryProjectRepository
+	documents []models.Document
+	nextID    uint
+}
+
+func NewInMemoryDocumentRepository(projects *InMemoryProjectRepository) *InMemoryDocumentRepository {
+	return &InMemoryDocumentRepository{projects: projects, nextID: 1}
+}
+
+// List returns a project's documents, oldest first
+func (r *InMemoryDocumentRepository) List(projectID uint, kind string) ([]models.Document, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	documents := []models.Document{}
+	for _, document := range r.documents {
+		if document.ProjectID == projectID && (kind == "" || document.Kind == kind) {
+			documents = append(documents, document)
+		}
+	}
+	return documents, nil
+}
+
+// Get retrieves a document of a project
+func (r *InMemoryDocumentRepository) Get(projectID, documentID uint) (*models.Document, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	i := r.index(projectID, documentID)
+	if i < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	document := r.documents[i]
+	return &document, nil
+}
+
+// Create adds a document to a project
+func (r *InMemoryDocumentRepository) Create(projectID uint, document *models.Document) error {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	document.ID = r.nextID
+	document.ProjectID = projectID
+	document.CreatedAt = time.Now()
+	r.nextID++
+	r.documents = append(r.documents, *document)
+	return nil
+}
+
+// Delete removes a document of a project
+func (r *InMemoryDocumentRepository) Delete(projectID, documentID uint) (*models.Document, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	i := r.index(projectID, documentID)
+	if i < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	document := r.documents[i]
+	r.documents = append(r.documents[:i], r.documents[i+1:]...)
+	return &document, nil
+}
+
+// index finds a document of a project. The caller holds the lock.
+func (r *InMemoryDocumentRepository) index(projectID, documentID uint) int {
+	for i := range r.documents {
+		if r.documents[i].ID == documentID && r.documents[i].ProjectID == projectID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrMonitoringReport is returned for a monitoring period whose report
+	// is not a monitoring report document of the project
+	ErrMonitoringReport = errors.New("report is not a monitoring report of the project")
+	// ErrPeriodOverlap is returned for a monitoring period sharing time
+	// with one the project already has
+	ErrPeriodOverlap = errors.New("monitoring period overlaps one already submitted")
+)
+
+// MonitoringRepository records the verified monitoring periods of projects
+// and charts them against the tonnes sold and retired. Missing projects
+// return gorm.ErrRecordNotFound.
+type MonitoringRepository interface {
+	// Create adds a monitoring period to a project
+	Create(projectID uint, period *models.MonitoringPeriod) error
+	// List returns a project's periods, earliest first
+	List(projectID uint) ([]models.MonitoringPeriod, error)
+	// Series charts a released project's periods. Projects never published
+	// are not found.
+	Series(projectID uint) (*models.MRVSeries, error)
+}
+
+// PostgresMonitoringRepository keeps monitoring periods in their own table.
+// Creating a period locks the project row, so two overlapping periods
+// cannot both be added.
+type PostgresMonitoringRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresMonitoringRepository(db *gorm.DB) *PostgresMonitoringRepository {
+	return &PostgresMonitoringRepository{db: db}
+}
+
+// Create adds a monitoring period to a project
+func (r *PostgresMonitoringRepository) Create(projectID uint, period *models.MonitoringPeriod) error {
+	period.ProjectID = projectID
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Project{}, projectID).Error; err != nil {
+			return err
+		}
+
+		if period.ReportID != nil {
+			err := tx.Where("project_id = ? AND kind = ?", projectID, models.DocumentMonitoring).
+				Select("id").First(&models.Document{}, *period.ReportID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMonitoringReport
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		var overlapping int64
+		if err := tx.Model(&models.MonitoringPeriod{}).
+			Where("project_id = ? AND period_start < ? AND period_end > ?", projectID, period.PeriodEnd, period.PeriodStart).
+			Count(&overlapping).Error; err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return ErrPeriodOverlap
+		}
+		return tx.Create(period).Error
+	})
+}
+
+// List returns a project's periods, earliest first
+func (r *PostgresMonitoringRepository) List(projectID uint) ([]models.MonitoringPeriod, error) {
+	if err := projectExists(r.db, projectID); err != nil {
+		return nil, err
+	}
+	periods := []models.MonitoringPeriod{}
+	if err := r.db.Where("project_id = ?", projectID).Order("period_start, id").Find(&periods).Error; err != nil {
+		return nil, err
+	}
+	return periods, nil
+}
+
+// Series charts a released project's periods
+func (r *PostgresMonitoringRepository) Series(projectID uint) (*models.MRVSeries, error) {
+	var project models.Project
+	if err := r.db.Select("id", "status").First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+	if models.Unreleased(project.Status) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var periods []models.MonitoringPeriod
+	if err := r.db.Where("project_id = ?", projectID).Find(&periods).Error; err != nil {
+		return nil, err
+	}
+	var sales, retirements []models.TonnesAt
+	if err := r.db.Model(&models.Reservation{}).Select("committed_at AS at, tonnes").
+		Where("project_id = ? AND status = ?", projectID, models.ReservationCommitted).
+		Scan(&sales).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Model(&models.SerialBlock{}).Select("allocated_at AS at, serial_end - serial_start + 1 AS tonnes").
+		Where("project_id = ? AND order_id <> ''", projectID).
+		Scan(&retirements).Error; err != nil {
+		return nil, err
+	}
+	return models.NewMRVSeries(projectID, periods, sales, retirements), nil
+}
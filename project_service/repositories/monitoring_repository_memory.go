@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"project_service/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryMonitoringRepository is a MonitoringRepository over an
+// InMemoryProjectRepository and the in-memory repositories of its
+// documents, reservations and serials, all guarded by the project
+// repository's lock
+type InMemoryMonitoringRepository struct {
+	projects     *InMemoryProjectRepository
+	documents    *InMemoryDocumentRepository
+	reservations *InMemoryReservationRepository
+	serials      *InMemorySerialRepository
+	periods      []models.MonitoringPeriod
+	nextID       uint
+}
+
+func NewInMemoryMonitoringRepository(projects *InMemoryProjectRepository, documents *InMemoryDocumentRepository, reservations *InMemoryReservationRepository, serials *InMemorySerialRepository) *InMemoryMonitoringRepository {
+	return &InMemoryMonitoringRepository{
+		projects:     projects,
+		documents:    documents,
+		reservations: reservations,
+		serials:      serials,
+		nextID:       1,
+	}
+}
+
+// Create adds a monitoring period to a project
+func (r *InMemoryMonitoringRepository) Create(projectID uint, period *models.MonitoringPeriod) error {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	period.ProjectID = projectID
+	if period.ReportID != nil {
+		i := r.documents.index(projectID, *period.ReportID)
+		if i < 0 || r.documents.documents[i].Kind != models.DocumentMonitoring {
+			return ErrMonitoringReport
+		}
+	}
+	for i := range r.periods {
+		if r.periods[i].ProjectID == projectID && r.periods[i].Overlaps(period) {
+			return ErrPeriodOverlap
+		}
+	}
+
+	period.ID = r.nextID
+	period.CreatedAt = time.Now()
+	r.nextID++
+	r.periods = append(r.periods, *period)
+	return nil
+}
+
+// List returns a project's periods, earliest first
+func (r *InMemoryMonitoringRepository) List(projectID uint) ([]models.MonitoringPeriod, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	periods := r.list(projectID)
+	sort.SliceStable(periods, func(i, j int) bool {
+		return periods[i].PeriodStart.Before(periods[j].PeriodStart)
+	})
+	return periods, nil
+}
+
+// Series charts a released project's periods
+func (r *InMemoryMonitoringRepository) Series(projectID uint) (*models.MRVSeries, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok || models.Unreleased(project.Status) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var sales, retirements []models.TonnesAt
+	for _, reservation := range r.reservations.reservations {
+		if reservation.ProjectID == projectID && reservation.Status == models.ReservationCommitted && reservation.CommittedAt != nil {
+			sales = append(sales, models.TonnesAt{At: *reservation.CommittedAt, Tonnes: reservation.Tonnes})
+		}
+	}
+	for _, block := range r.serials.blocks {
+		if block.ProjectID == projectID && block.Status() == models.SerialAllocated && block.AllocatedAt != nil {
+			retirements = append(retirements, models.TonnesAt{At: *block.AllocatedAt, Tonnes: float64(block.Quantity())})
+		}
+	}
+	return models.NewMRVSeries(projectID, r.list(projectID), sales, retirements), nil
+}
+
+// list returns a project's periods in the order they were added. The
+// caller holds the lock.
+func (r *InMemoryMonitoringRepository) list(projectID uint) []models.MonitoringPeriod {
+	periods := []models.MonitoringPeriod{}
+	for _, period := range r.periods {
+		if period.ProjectID == projectID {
+			periods = append(periods, period)
+		}
+	}
+	return periods
+}
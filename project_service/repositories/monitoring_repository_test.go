@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// monitoringFixture is a monitoring repository over a published project
+// with the repositories it charts sales and retirements from, all on one
+// movable clock
+type monitoringFixture struct {
+	repo         *InMemoryMonitoringRepository
+	projects     *InMemoryProjectRepository
+	documents    *InMemoryDocumentRepository
+	reservations *InMemoryReservationRepository
+	serials      *InMemorySerialRepository
+	now          *time.Time
+}
+
+func newMonitoringFixture(t *testing.T) *monitoringFixture {
+	t.Helper()
+	reservations, projects, now := newReservationRepo(t)
+	if err := NewInMemoryVintageRepository(projects).Create(1, &models.Vintage{Year: 2025, PricePerTonne: 10}); err != nil {
+		t.Fatal(err)
+	}
+	serials := NewInMemorySerialRepository(projects)
+	serials.now = func() time.Time { return *now }
+	documents := NewInMemoryDocumentRepository(projects)
+	return &monitoringFixture{
+		repo:         NewInMemoryMonitoringRepository(projects, documents, reservations, serials),
+		projects:     projects,
+		documents:    documents,
+		reservations: reservations,
+		serials:      serials,
+		now:          now,
+	}
+}
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func period(start, end, verified time.Time, tonnes float64) *models.MonitoringPeriod {
+	return &models.MonitoringPeriod{PeriodStart: start, PeriodEnd: end, VerifiedAt: verified, MeasuredTonnes: tonnes, Verifier: "SCS Global"}
+}
+
+func TestMonitoringCreate(t *testing.T) {
+	f := newMonitoringFixture(t)
+	if err := f.repo.Create(1, period(day(2025, 1, 1), day(2025, 7, 1), day(2025, 8, 1), 40)); err != nil {
+		t.Fatal(err)
+	}
+	design := &models.Document{Kind: models.DocumentDesign}
+	report := &models.Document{Kind: models.DocumentMonitoring}
+	for _, document := range []*models.Document{design, report} {
+		if err := f.documents.Create(1, document); err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := uint(99)
+
+	for _, tt := range []struct {
+		name     string
+		project  uint
+		start    time.Time
+		end      time.Time
+		reportID *uint
+		want     error
+	}{
+		{"overlapping", 1, day(2025, 6, 1), day(2025, 12, 1), nil, ErrPeriodOverlap},
+		{"another kind of report", 1, day(2025, 7, 1), day(2026, 1, 1), &design.ID, ErrMonitoringReport},
+		{"missing report", 1, day(2025, 7, 1), day(2026, 1, 1), &missing, ErrMonitoringReport},
+		{"missing project", 9, day(2025, 7, 1), day(2026, 1, 1), nil, gorm.ErrRecordNotFound},
+		// A period may start the day the previous one ends
+		{"adjacent", 1, day(2025, 7, 1), day(2026, 1, 1), &report.ID, nil},
+	} {
+		p := period(tt.start, tt.end, tt.end, 10)
+		p.ReportID = tt.reportID
+		if err := f.repo.Create(tt.project, p); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	periods, err := f.repo.List(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 2 || periods[1].ReportID == nil || *periods[1].ReportID != report.ID {
+		t.Errorf("expected both periods, earliest first, got %+v", periods)
+	}
+	if _, err := f.repo.List(9); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing project not found, got %v", err)
+	}
+}
+
+func TestMonitoringSeries(t *testing.T) {
+	f := newMonitoringFixture(t)
+	// Submitted out of order; the chart follows the verification dates
+	if err := f.repo.Create(1, period(day(2026, 1, 1), day(2026, 7, 1), day(2026, 9, 1), 30)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.Create(1, period(day(2025, 1, 1), day(2026, 1, 1), day(2026, 3, 1), 50)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.serials.Issue(1, &models.SerialBlock{VintageID: 1, Registry: "Verra", Prefix: "VCS-981-2025", Start: 1, End: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sold 20 tonnes in April and retired 5 of them, then sold 10 more in
+	// October, after the last verification
+	*f.now = day(2026, 4, 1)
+	reservation, err := f.reservations.Reserve(1, 0, 20, "order-1", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.reservations.Commit(reservation.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.serials.Allocate("order-1", 1, 0, 5); err != nil {
+		t.Fatal(err)
+	}
+	*f.now = day(2026, 10, 1)
+	reservation, err = f.reservations.Reserve(1, 0, 10, "order-2", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.reservations.Commit(reservation.ID); err != nil {
+		t.Fatal(err)
+	}
+	// Held capacity is not sold
+	if _, err := f.reservations.Reserve(1, 0, 7, "order-3", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := f.repo.Series(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series.Points) != 2 {
+		t.Fatalf("expected a point per period, got %+v", series.Points)
+	}
+	first, second := series.Points[0], series.Points[1]
+	if first.MeasuredTonnes != 50 || first.CumulativeIssued != 50 || first.CumulativeSold != 0 || first.CumulativeRetired != 0 {
+		t.Errorf("unexpected first point: %+v", first)
+	}
+	if second.MeasuredTonnes != 30 || second.CumulativeIssued != 80 || second.CumulativeSold != 20 || second.CumulativeRetired != 5 {
+		t.Errorf("unexpected second point: %+v", second)
+	}
+	if series.Issued != 80 || series.Sold != 30 || series.Retired != 5 {
+		t.Errorf("unexpected totals: %+v", series)
+	}
+}
+
+func TestMonitoringSeriesHidesUnreleasedProjects(t *testing.T) {
+	f := newMonitoringFixture(t)
+	draft := &models.Project{Title: "Peatland Rewetting", Status: models.StatusDraft}
+	if err := f.projects.Create(draft, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.repo.Create(draft.ID, period(day(2025, 1, 1), day(2026, 1, 1), day(2026, 3, 1), 50)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.repo.Series(draft.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a draft not found, got %v", err)
+	}
+	if periods, err := f.repo.List(draft.ID); err != nil || len(periods) != 1 {
+		t.Errorf("expected the draft's periods listed, got %+v %v", periods, err)
+	}
+}
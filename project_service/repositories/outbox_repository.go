@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"project_service/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRepository hands the project events in the outbox to relays. The
+// events of a project are handed out one at a time, oldest first: an event
+// is only due once every earlier event of its project is published.
+type OutboxRepository interface {
+	// Claim returns up to limit due events, oldest first, and holds them
+	// for the lease so other relays skip them. An event neither published
+	// nor failed by the end of its lease is handed out again.
+	Claim(now time.Time, lease time.Duration, limit int) ([]models.OutboxEvent, error)
+	MarkPublished(id uint, at time.Time) error
+	// MarkFailed records a failed relay and when the event is due again
+	MarkFailed(id uint, cause error, retryAt time.Time) error
+	// Purge deletes the events published before a moment
+	Purge(before time.Time) (int64, error)
+}
+
+// PostgresOutboxRepository is the outbox table. Claims lock the rows they
+// take and skip locked ones, so concurrent relays never claim the same
+// event.
+type PostgresOutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresOutboxRepository(db *gorm.DB) *PostgresOutboxRepository {
+	return &PostgresOutboxRepository{db: db}
+}
+
+// Claim returns due events and holds them for the lease
+func (r *PostgresOutboxRepository) Claim(now time.Time, lease time.Duration, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND retry_at <= ?", now).
+			Where(`NOT EXISTS (SELECT 1 FROM outbox_events earlier WHERE earlier.project_id = outbox_events.project_id
+				AND earlier.published_at IS NULL AND earlier.id < outbox_events.id)`).
+			Order("id").Limit(limit).Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("retry_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// MarkPublished records that an event was relayed
+func (r *PostgresOutboxRepository) MarkPublished(id uint, at time.Time) error {
+	return r.db.Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"published_at": at,
+		"last_error":   "",
+	}).Error
+}
+
+// MarkFailed records a failed relay
+func (r *PostgresOutboxRepository) MarkFailed(id uint, cause error, retryAt time.Time) error {
+	return r.db.Model(&models.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": cause.Error(),
+		"retry_at":   retryAt,
+	}).Error
+}
+
+// Purge deletes the events published before a moment
+func (r *PostgresOutboxRepository) Purge(before time.Time) (int64, error) {
+	result := r.db.Where("published_at < ?", before).Delete(&models.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// recordEvents adds the events of a write that took a project's fields
+// from before, nil for a create, to after to the outbox, in the write's
+// transaction
+func recordEvents(tx *gorm.DB, projectID uint, before *models.ProjectFields, after models.ProjectFields, at time.Time) error {
+	changes := models.Diff(before, after)
+	for _, eventType := range models.EventTypes(before, after) {
+		if err := tx.Create(newOutboxEvent(eventType, projectID, changes, after, at)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordProjectEvent adds an event to the outbox for a write to rows a
+// project is made of, such as its vintages, price tiers or reservations.
+// The event carries the project as the transaction left it.
+func recordProjectEvent(tx *gorm.DB, eventType string, projectID uint, changes map[string]models.FieldChange, at time.Time) error {
+	var project models.Project
+	if err := tx.First(&project, projectID).Error; err != nil {
+		return err
+	}
+	return tx.Create(newOutboxEvent(eventType, projectID, changes, models.FieldsOf(&project), at)).Error
+}
+
+func newOutboxEvent(eventType string, projectID uint, changes map[string]models.FieldChange, project models.ProjectFields, at time.Time) *models.OutboxEvent {
+	return &models.OutboxEvent{
+		EventID:    newUUID(),
+		Type:       eventType,
+		ProjectID:  projectID,
+		Changes:    changes,
+		Project:    project,
+		OccurredAt: at,
+		RetryAt:    at,
+	}
+}
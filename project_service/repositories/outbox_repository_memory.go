@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"project_service/models"
+	"time"
+)
+
+// Claim returns due events and holds them for the lease
+func (r *InMemoryProjectRepository) Claim(now time.Time, lease time.Duration, limit int) ([]models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	events := []models.OutboxEvent{}
+	waiting := map[uint]bool{}
+	for i := range r.outbox {
+		event := &r.outbox[i]
+		if event.PublishedAt != nil {
+			continue
+		}
+		// Only the oldest unpublished event of a project is due
+		if waiting[event.ProjectID] {
+			continue
+		}
+		waiting[event.ProjectID] = true
+		if event.RetryAt.After(now) || len(events) == limit {
+			continue
+		}
+		event.RetryAt = now.Add(lease)
+		events = append(events, *event)
+	}
+	return events, nil
+}
+
+// MarkPublished records that an event was relayed
+func (r *InMemoryProjectRepository) MarkPublished(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event := r.outboxEvent(id); event != nil {
+		event.PublishedAt = &at
+		event.LastError = ""
+	}
+	return nil
+}
+
+// MarkFailed records a failed relay
+func (r *InMemoryProjectRepository) MarkFailed(id uint, cause error, retryAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if event := r.outboxEvent(id); event != nil {
+		event.Attempts++
+		event.LastError = cause.Error()
+		event.RetryAt = retryAt
+	}
+	return nil
+}
+
+// Purge deletes the events published before a moment
+func (r *InMemoryProjectRepository) Purge(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.outbox[:0]
+	for _, event := range r.outbox {
+		if event.PublishedAt == nil || !event.PublishedAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	purged := int64(len(r.outbox) - len(kept))
+	r.outbox = kept
+	return purged, nil
+}
+
+// Events returns every event in the outbox, oldest first
+func (r *InMemoryProjectRepository) Events() []models.OutboxEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.OutboxEvent{}, r.outbox...)
+}
+
+// recordEvents adds the events of a write that took a project's fields
+// from before to after to the outbox. The caller holds the lock.
+func (r *InMemoryProjectRepository) recordEvents(projectID uint, before *models.ProjectFields, after models.ProjectFields, at time.Time) {
+	changes := models.Diff(before, after)
+	for _, eventType := range models.EventTypes(before, after) {
+		r.appendEvent(newOutboxEvent(eventType, projectID, changes, after, at))
+	}
+}
+
+// recordEvent adds an event about a project to the outbox. The caller
+// holds the lock.
+func (r *InMemoryProjectRepository) recordEvent(eventType string, project *models.Project, changes map[string]models.FieldChange, at time.Time) {
+	r.appendEvent(newOutboxEvent(eventType, project.ID, changes, models.FieldsOf(project), at))
+}
+
+func (r *InMemoryProjectRepository) appendEvent(event *models.OutboxEvent) {
+	r.lastEventID++
+	event.ID = r.lastEventID
+	event.CreatedAt = time.Now()
+	r.outbox = append(r.outbox, *event)
+}
+
+func (r *InMemoryProjectRepository) outboxEvent(id uint) *models.OutboxEvent {
+	for i := range r.outbox {
+		if r.outbox[i].ID == id {
+			return &r.outbox[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func eventTypes(events []models.OutboxEvent) []string {
+	types := []string{}
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestOutboxRecordsEveryWrite(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	repo.Create(&models.Project{Title: "Mangrove Restoration", PricePerTonne: 10, TotalCapacity: 100, AvailableCapacity: 100,
+		Status: models.StatusPublished}, models.Change{Actor: author})
+	repo.Update(1, &models.UpdateProjectRequest{PricePerTonne: 12, Title: "Mangrove Restoration"}, models.Change{Actor: author})
+	repo.Update(1, &models.UpdateProjectRequest{Description: "Coastal replanting", TotalCapacity: 200}, models.Change{Actor: author})
+	// An update that changes nothing has no events
+	repo.Update(1, &models.UpdateProjectRequest{PricePerTonne: 12}, models.Change{Actor: author})
+	if _, err := NewInMemoryReservationRepository(repo).Reserve(1, 0, 30, "order-1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	NewInMemoryPricingRepository(repo).SetPricing(1, 0, []models.PriceTier{{MinTonnes: 50, PricePerTonne: 11}})
+	retire(t, repo, 1)
+
+	events := repo.Events()
+	want := []string{
+		models.EventProjectCreated,
+		models.EventProjectPriceChanged,
+		models.EventProjectUpdated, models.EventProjectCapacityChanged,
+		models.EventProjectCapacityChanged,
+		models.EventProjectPriceChanged,
+		models.EventProjectRetired,
+	}
+	if got := eventTypes(events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected events %v, got %v", want, got)
+	}
+	if events[1].Changes["price_per_tonne"] != (models.FieldChange{From: 10.0, To: 12.0}) {
+		t.Errorf("expected the price change in the event, got %+v", events[1].Changes)
+	}
+	if events[4].Project.AvailableCapacity != 70 || events[4].Changes["available_capacity"].To != 70.0 {
+		t.Errorf("expected the reserved capacity in the event, got %+v", events[4])
+	}
+	ids := map[string]bool{}
+	for i, event := range events {
+		if event.ID != uint(i+1) || event.EventID == "" || ids[event.EventID] {
+			t.Errorf("expected increasing IDs and unique event IDs, got %+v", event)
+		}
+		ids[event.EventID] = true
+	}
+}
+
+func TestOutboxClaimKeepsProjectOrder(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	repo.Create(&models.Project{Title: "Mangrove Restoration", PricePerTonne: 10}, models.Change{})
+	repo.Create(&models.Project{Title: "Kenya Clean Cookstoves", PricePerTonne: 8}, models.Change{})
+	repo.Update(1, &models.UpdateProjectRequest{PricePerTonne: 12}, models.Change{})
+
+	now := time.Now().Add(time.Second)
+	claimed, _ := repo.Claim(now, time.Minute, 10)
+	if len(claimed) != 2 || claimed[0].ID != 1 || claimed[1].ID != 2 {
+		t.Fatalf("expected the first event of each project, got %+v", claimed)
+	}
+	if again, _ := repo.Claim(now, time.Minute, 10); len(again) != 0 {
+		t.Errorf("expected claimed events to be held, got %+v", again)
+	}
+
+	// The project's next event waits until the failed one is published
+	repo.MarkFailed(1, errors.New("broker down"), now.Add(time.Second))
+	repo.MarkPublished(2, now)
+	if due, _ := repo.Claim(now, time.Minute, 10); len(due) != 0 {
+		t.Errorf("expected nothing due before the retry, got %+v", due)
+	}
+	retried, _ := repo.Claim(now.Add(time.Second), time.Minute, 10)
+	if len(retried) != 1 || retried[0].ID != 1 || retried[0].Attempts != 1 || retried[0].LastError != "broker down" {
+		t.Fatalf("expected the failed event retried, got %+v", retried)
+	}
+	repo.MarkPublished(1, now)
+	if next, _ := repo.Claim(now.Add(time.Second), time.Minute, 10); len(next) != 1 || next[0].ID != 3 {
+		t.Errorf("expected the project's next event, got %+v", next)
+	}
+
+	if purged, _ := repo.Purge(now.Add(time.Second)); purged != 2 || len(repo.Events()) != 1 {
+		t.Errorf("expected the published events purged, got %d", purged)
+	}
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"project_service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for a cursor that cannot be decoded or was
+// issued for a different sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the position of the last project of a page: its sort value and
+// ID. Pages continue with the projects ordered after it, so rows inserted,
+// edited or removed elsewhere in the order do not shift the next page.
+// Values are backend specific and cursors only make sense to the backend
+// that issued them.
+type cursor struct {
+	Sort  string  `json:"s"`
+	Value float64 `json:"v"`
+	ID    uint    `json:"id"`
+}
+
+// encode makes the cursor an opaque URL-safe token
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor token issued for the sort order, returning
+// nil for an empty token
+func decodeCursor(token, sort string) (*cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// projectSort is an order on a numeric project column, ties broken by
+// ascending ID
+type projectSort struct {
+	column string
+	desc   bool
+	// value is the sort value of a project as stored in cursors
+	value func(p *models.Project) float64
+	// arg converts a cursor value back into a query argument
+	arg func(v float64) interface{}
+}
+
+var projectSorts = map[string]projectSort{
+	models.SortNewest: {
+		column: "created_at",
+		desc:   true,
+		value:  func(p *models.Project) float64 { return float64(p.CreatedAt.UnixMicro()) },
+		arg:    func(v float64) interface{} { return time.UnixMicro(int64(v)).UTC() },
+	},
+	models.SortPriceAsc:     {column: "price_per_tonne", value: price},
+	models.SortPriceDesc:    {column: "price_per_tonne", desc: true, value: price},
+	models.SortCapacityAsc:  {column: "available_capacity", value: capacity},
+	models.SortCapacityDesc: {column: "available_capacity", desc: true, value: capacity},
+}
+
+func price(p *models.Project) float64    { return p.PricePerTonne }
+func capacity(p *models.Project) float64 { return p.AvailableCapacity }
+
+// sortFor returns the column order of a sort name, defaulting to newest
+func sortFor(name string) (string, projectSort) {
+	if s, ok := projectSorts[name]; ok {
+		return name, s
+	}
+	return models.SortNewest, projectSorts[models.SortNewest]
+}
+
+// searchSortFor returns the order of a search and whether it is a column
+// order. Relevance, the fallback for unknown sorts, ranks by descending
+// score.
+func searchSortFor(name string) (string, projectSort, bool) {
+	if s, ok := projectSorts[name]; ok {
+		return name, s, true
+	}
+	return models.SortRelevance, projectSort{desc: true}, false
+}
+
+// cursor returns the cursor positioned at a project
+func (s projectSort) cursor(name string, p *models.Project) string {
+	return cursor{Sort: name, Value: s.value(p), ID: p.ID}.encode()
+}
+
+// less orders two projects
+func (s projectSort) less(a, b *models.Project) bool {
+	return keyLess(s.value(a), a.ID, s.value(b), b.ID, s.desc)
+}
+
+// after reports whether a project is ordered after the cursor
+func (s projectSort) after(p *models.Project, c *cursor) bool {
+	return keyLess(c.Value, c.ID, s.value(p), p.ID, s.desc)
+}
+
+// apply orders a projects query and starts it after the cursor
+func (s projectSort) apply(query *gorm.DB, after *cursor) *gorm.DB {
+	direction := "ASC"
+	if s.desc {
+		direction = "DESC"
+	}
+	if after != nil {
+		query = keysetAfter(query, s.column, s.desc, s.sqlArg(after.Value), after.ID)
+	}
+	return query.Order(s.column + " " + direction + ", id")
+}
+
+func (s projectSort) sqlArg(v float64) interface{} {
+	if s.arg != nil {
+		return s.arg(v)
+	}
+	return v
+}
+
+// keysetAfter keeps the rows ordered after (value, id) by an expression and
+// ascending ID
+func keysetAfter(query *gorm.DB, expr string, desc bool, value interface{}, id uint, exprArgs ...interface{}) *gorm.DB {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	args := append([]interface{}{}, exprArgs...)
+	args = append(args, value)
+	args = append(args, exprArgs...)
+	args = append(args, value, id)
+	return query.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND id > ?))", expr, op, expr), args...)
+}
+
+// keyLess orders (value, id) keys by value in the given direction, then by
+// ascending ID
+func keyLess(av float64, aID uint, bv float64, bID uint, desc bool) bool {
+	if av != bv {
+		return (av < bv) != desc
+	}
+	return aID < bID
+}
+
+// pageOf cuts a page of up to limit items from the items after offset,
+// reporting whether any follow it
+func pageOf[T any](items []T, offset, limit int) ([]T, bool) {
+	if offset >= len(items) {
+		return items[:0], false
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		return items[:limit], true
+	}
+	return items, false
+}
@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"project_service/models"
+	"testing"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	token := cursor{Sort: models.SortNewest, Value: 1.5, ID: 3}.encode()
+
+	if c, err := decodeCursor(token, models.SortNewest); err != nil || c.ID != 3 || c.Value != 1.5 {
+		t.Errorf("expected the cursor to round-trip, got %+v, %v", c, err)
+	}
+	if c, err := decodeCursor("", models.SortNewest); c != nil || err != nil {
+		t.Errorf("expected no cursor for an empty token, got %+v, %v", c, err)
+	}
+	for _, tt := range []struct{ token, sort string }{
+		{token, models.SortPriceAsc},
+		{"not a cursor!", models.SortNewest},
+		{"bm90IGpzb24", models.SortNewest},
+	} {
+		if _, err := decodeCursor(tt.token, tt.sort); err != ErrInvalidCursor {
+			t.Errorf("expected %q for %s to be invalid, got %v", tt.token, tt.sort, err)
+		}
+	}
+}
+
+func TestInMemoryGetAllCursor(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	for _, price := range []float64{12, 5, 8, 5} {
+		repo.Create(&models.Project{Title: "Project", PricePerTonne: price, Status: models.StatusPublished}, models.Change{})
+	}
+
+	req := &models.ProjectListRequest{Sort: models.SortPriceAsc, Limit: 3}
+	page, err := repo.GetAll(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 4 || len(page.Projects) != 3 || page.Projects[0].ID != 2 || page.Projects[1].ID != 4 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v", page)
+	}
+
+	repo.Update(2, &models.UpdateProjectRequest{PricePerTonne: 30}, models.Change{})
+	req.Cursor = page.NextCursor
+	page, _ = repo.GetAll(req)
+	if len(page.Projects) != 2 || page.Projects[0].ID != 1 || page.Projects[1].ID != 2 || page.NextCursor != "" {
+		t.Errorf("expected the rest after the cursor, including the repriced project, got %+v", page.Projects)
+	}
+}
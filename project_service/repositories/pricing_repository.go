@@ -0,0 +1,330 @@
+package repositories
+
+import (
+	"errors"
+	"math"
+	"project_service/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrScheduleInPast is returned when scheduling a price change for a
+	// moment that has already passed
+	ErrScheduleInPast = errors.New("price schedule must take effect in the future")
+	// ErrScheduleNotPending is returned when cancelling a schedule that has
+	// already taken effect
+	ErrScheduleNotPending = errors.New("price schedule is not pending")
+	// ErrNoPriceAt is returned when quoting a moment before a project's
+	// first recorded price
+	ErrNoPriceAt = errors.New("no price recorded at that moment")
+)
+
+// applyBatchSize is the number of due schedules ApplyDue reads at a time
+const applyBatchSize = 100
+
+// PricingRepository keeps project price tiers, scheduled price changes and
+// the price history. Missing projects and schedules, including a schedule
+// of another project, return gorm.ErrRecordNotFound. Every change to a
+// project's base price or tiers is recorded in its history from the moment
+// it took effect, which is what quotes for past moments read.
+type PricingRepository interface {
+	// Pricing returns a project's current prices and pending schedules
+	Pricing(projectID uint) (*models.ProjectPricing, error)
+	// SetPricing replaces a project's tiers, and its base price when it is
+	// not zero, straight away
+	SetPricing(projectID uint, price float64, tiers []models.PriceTier) (*models.ProjectPricing, error)
+	Schedule(projectID uint, schedule *models.PriceSchedule) error
+	// Schedules returns every schedule of a project by effective time
+	Schedules(projectID uint) ([]models.PriceSchedule, error)
+	// CancelSchedule is idempotent for a cancelled schedule
+	CancelSchedule(projectID, scheduleID uint) (*models.PriceSchedule, error)
+	// ApplyDue applies the pending schedules whose time has come, oldest
+	// first, and returns them
+	ApplyDue(now time.Time) ([]models.PriceSchedule, error)
+	// History returns a page of a project's price changes, newest first
+	History(projectID uint, limit, offset int) ([]models.PriceChange, error)
+	// Quote prices tonnes of a published project at a moment, past or future
+	Quote(projectID uint, tonnes float64, at time.Time) (*models.Quote, error)
+}
+
+// PostgresPricingRepository keeps schedules and the price history in
+// Postgres. Price changes lock the project row, and applying a schedule
+// locks the schedule row, so concurrent sweeps apply each schedule once.
+type PostgresPricingRepository struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewPostgresPricingRepository(db *gorm.DB) *PostgresPricingRepository {
+	return &PostgresPricingRepository{db: db, now: time.Now}
+}
+
+// Pricing returns a project's current prices and pending schedules
+func (r *PostgresPricingRepository) Pricing(projectID uint) (*models.ProjectPricing, error) {
+	var project models.Project
+	if err := r.db.Select("id", "price_per_tonne", "price_tiers").First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+	schedules := []models.PriceSchedule{}
+	if err := r.db.Where("project_id = ? AND status = ?", projectID, models.SchedulePending).
+		Order("effective_from").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return pricingOf(&project, schedules), nil
+}
+
+// SetPricing replaces a project's tiers, and its base price when it is not
+// zero
+func (r *PostgresPricingRepository) SetPricing(projectID uint, price float64, tiers []models.PriceTier) (*models.ProjectPricing, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return setPrices(tx, projectID, price, tiers, models.PriceChangeTiers, nil, r.now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.Pricing(projectID)
+}
+
+// Schedule adds a pending price change to a project
+func (r *PostgresPricingRepository) Schedule(projectID uint, schedule *models.PriceSchedule) error {
+	if !schedule.EffectiveFrom.After(r.now()) {
+		return ErrScheduleInPast
+	}
+	if err := projectExists(r.db, projectID); err != nil {
+		return err
+	}
+	schedule.ProjectID = projectID
+	schedule.Status = models.SchedulePending
+	return r.db.Create(schedule).Error
+}
+
+// Schedules returns every schedule of a project by effective time
+func (r *PostgresPricingRepository) Schedules(projectID uint) ([]models.PriceSchedule, error) {
+	if err := projectExists(r.db, projectID); err != nil {
+		return nil, err
+	}
+	schedules := []models.PriceSchedule{}
+	if err := r.db.Where("project_id = ?", projectID).Order("effective_from, id").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// CancelSchedule cancels a pending schedule
+func (r *PostgresPricingRepository) CancelSchedule(projectID, scheduleID uint) (*models.PriceSchedule, error) {
+	var schedule *models.PriceSchedule
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if schedule, err = lockSchedule(tx.Where("project_id = ?", projectID), scheduleID); err != nil {
+			return err
+		}
+		switch schedule.Status {
+		case models.ScheduleCancelled:
+			return nil
+		case models.ScheduleApplied:
+			return ErrScheduleNotPending
+		}
+		schedule.Status = models.ScheduleCancelled
+		return tx.Save(schedule).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// ApplyDue applies due schedules, each in its own transaction so a failure
+// leaves the rest to the next sweep
+func (r *PostgresPricingRepository) ApplyDue(now time.Time) ([]models.PriceSchedule, error) {
+	var applied []models.PriceSchedule
+	for {
+		var due []models.PriceSchedule
+		err := r.db.Select("id").
+			Where("status = ? AND effective_from <= ?", models.SchedulePending, now).
+			Order("effective_from, id").Limit(applyBatchSize).Find(&due).Error
+		if err != nil {
+			return applied, err
+		}
+
+		for _, d := range due {
+			var schedule *models.PriceSchedule
+			err := r.db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				if schedule, err = lockSchedule(tx, d.ID); err != nil {
+					return err
+				}
+				// Another instance may have got there first
+				if schedule.Status != models.SchedulePending {
+					schedule = nil
+					return nil
+				}
+				return applySchedule(tx, schedule, now)
+			})
+			if err != nil {
+				return applied, err
+			}
+			if schedule != nil {
+				applied = append(applied, *schedule)
+			}
+		}
+
+		if len(due) < applyBatchSize {
+			return applied, nil
+		}
+	}
+}
+
+// History returns a page of a project's price changes, newest first
+func (r *PostgresPricingRepository) History(projectID uint, limit, offset int) ([]models.PriceChange, error) {
+	if err := projectExists(r.db, projectID); err != nil {
+		return nil, err
+	}
+	changes := []models.PriceChange{}
+	if err := r.db.Where("project_id = ?", projectID).
+		Order("effective_from DESC, id DESC").Limit(limit).Offset(offset).Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Quote prices tonnes of a published project at a moment
+func (r *PostgresPricingRepository) Quote(projectID uint, tonnes float64, at time.Time) (*models.Quote, error) {
+	var project models.Project
+	if err := r.db.Where("status = ?", models.StatusPublished).First(&project, projectID).Error; err != nil {
+		return nil, err
+	}
+
+	var schedules []models.PriceSchedule
+	if err := r.db.Where("project_id = ? AND status = ? AND effective_from <= ?", projectID, models.SchedulePending, at).
+		Order("effective_from DESC, id DESC").Limit(1).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	var changes []models.PriceChange
+	if err := r.db.Where("project_id = ? AND effective_from <= ?", projectID, at).
+		Order("effective_from DESC, id DESC").Limit(1).Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	var recorded int64
+	if len(changes) == 0 {
+		if err := r.db.Model(&models.PriceChange{}).Where("project_id = ?", projectID).Count(&recorded).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var schedule *models.PriceSchedule
+	if len(schedules) > 0 {
+		schedule = &schedules[0]
+	}
+	var change *models.PriceChange
+	if len(changes) > 0 {
+		change = &changes[0]
+	}
+	return quoteAt(&project, schedule, change, recorded > 0, tonnes, at)
+}
+
+// lockSchedule reads a schedule and locks its row until the transaction
+// ends
+func lockSchedule(tx *gorm.DB, id uint) (*models.PriceSchedule, error) {
+	var schedule models.PriceSchedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// applySchedule gives a project a schedule's prices and marks it applied.
+// The change is recorded from the schedule's effective time, not the
+// sweep's.
+func applySchedule(tx *gorm.DB, schedule *models.PriceSchedule, now time.Time) error {
+	if err := setPrices(tx, schedule.ProjectID, schedule.PricePerTonne, schedule.Tiers,
+		models.PriceChangeSchedule, &schedule.ID, schedule.EffectiveFrom); err != nil {
+		return err
+	}
+	schedule.Status = models.ScheduleApplied
+	schedule.AppliedAt = &now
+	return tx.Save(schedule).Error
+}
+
+// setPrices changes a project's tiers, and its base price when it is not
+// zero, and records the change and its price_changed event
+func setPrices(tx *gorm.DB, projectID uint, price float64, tiers []models.PriceTier, source string, scheduleID *uint, effective time.Time) error {
+	var project models.Project
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&project, projectID).Error; err != nil {
+		return err
+	}
+	changes := priceChanges(project.PricePerTonne, price)
+	setFloat(&project.PricePerTonne, price)
+	project.PriceTiers = tiers
+	project.UpdatedAt = time.Now()
+	if err := tx.Model(&project).Select("price_per_tonne", "price_tiers", "updated_at").Updates(&project).Error; err != nil {
+		return err
+	}
+	if err := recordPrice(tx, &project, source, scheduleID, effective); err != nil {
+		return err
+	}
+	return recordProjectEvent(tx, models.EventProjectPriceChanged, projectID, changes, project.UpdatedAt)
+}
+
+// priceChanges is the change of a price change event when a new base price
+// replaces the old one, nil when only the tiers change
+func priceChanges(from, to float64) map[string]models.FieldChange {
+	if to == 0 || to == from {
+		return nil
+	}
+	return map[string]models.FieldChange{"price_per_tonne": {From: from, To: to}}
+}
+
+// recordPrice adds a project's current prices to its history
+func recordPrice(tx *gorm.DB, project *models.Project, source string, scheduleID *uint, effective time.Time) error {
+	return tx.Create(&models.PriceChange{
+		ProjectID:     project.ID,
+		PricePerTonne: project.PricePerTonne,
+		Tiers:         project.PriceTiers,
+		Source:        source,
+		ScheduleID:    scheduleID,
+		EffectiveFrom: effective,
+	}).Error
+}
+
+func pricingOf(project *models.Project, schedules []models.PriceSchedule) *models.ProjectPricing {
+	tiers := project.PriceTiers
+	if tiers == nil {
+		tiers = []models.PriceTier{}
+	}
+	return &models.ProjectPricing{
+		ProjectID:     project.ID,
+		PricePerTonne: project.PricePerTonne,
+		Tiers:         tiers,
+		Schedules:     schedules,
+	}
+}
+
+// quoteAt prices tonnes at a moment under the later of the pending
+// schedule and the recorded change in effect then. A project without
+// recorded changes is priced at its current prices.
+func quoteAt(project *models.Project, schedule *models.PriceSchedule, change *models.PriceChange, recorded bool, tonnes float64, at time.Time) (*models.Quote, error) {
+	base, tiers := project.PricePerTonne, project.PriceTiers
+	var scheduleID *uint
+	switch {
+	case schedule != nil && (change == nil || !schedule.EffectiveFrom.Before(change.EffectiveFrom)):
+		base, tiers, scheduleID = schedule.PricePerTonne, schedule.Tiers, &schedule.ID
+	case change != nil:
+		base, tiers, scheduleID = change.PricePerTonne, change.Tiers, change.ScheduleID
+	case recorded:
+		return nil, ErrNoPriceAt
+	}
+
+	price, tier := models.PriceFor(base, tiers, tonnes)
+	return &models.Quote{
+		ProjectID:     project.ID,
+		Tonnes:        tonnes,
+		At:            at,
+		PricePerTonne: price,
+		Total:         math.Round(price*tonnes*100) / 100,
+		Tier:          tier,
+		ScheduleID:    scheduleID,
+	}, nil
+}
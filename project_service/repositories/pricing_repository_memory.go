@@ -0,0 +1,227 @@
+package repositories
+
+import (
+	"project_service/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryPricingRepository is a PricingRepository over an
+// InMemoryProjectRepository, recording price changes in the project
+// repository's history. Every operation holds its lock.
+type InMemoryPricingRepository struct {
+	projects  *InMemoryProjectRepository
+	schedules []models.PriceSchedule
+	now       func() time.Time
+}
+
+func NewInMemoryPricingRepository(projects *InMemoryProjectRepository) *InMemoryPricingRepository {
+	return &InMemoryPricingRepository{projects: projects, now: time.Now}
+}
+
+// Pricing returns a project's current prices and pending schedules
+func (r *InMemoryPricingRepository) Pricing(projectID uint) (*models.ProjectPricing, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	return r.pricing(projectID)
+}
+
+// SetPricing replaces a project's tiers, and its base price when it is not
+// zero
+func (r *InMemoryPricingRepository) SetPricing(projectID uint, price float64, tiers []models.PriceTier) (*models.ProjectPricing, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	if err := r.setPrices(projectID, price, tiers, models.PriceChangeTiers, nil, r.now()); err != nil {
+		return nil, err
+	}
+	return r.pricing(projectID)
+}
+
+// Schedule adds a pending price change to a project
+func (r *InMemoryPricingRepository) Schedule(projectID uint, schedule *models.PriceSchedule) error {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	now := r.now()
+	if !schedule.EffectiveFrom.After(now) {
+		return ErrScheduleInPast
+	}
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	schedule.ID = uint(len(r.schedules) + 1)
+	schedule.ProjectID = projectID
+	schedule.Status = models.SchedulePending
+	schedule.CreatedAt = now
+	schedule.UpdatedAt = now
+	r.schedules = append(r.schedules, *schedule)
+	return nil
+}
+
+// Schedules returns every schedule of a project by effective time
+func (r *InMemoryPricingRepository) Schedules(projectID uint) ([]models.PriceSchedule, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.find(func(s *models.PriceSchedule) bool { return s.ProjectID == projectID }), nil
+}
+
+// CancelSchedule cancels a pending schedule
+func (r *InMemoryPricingRepository) CancelSchedule(projectID, scheduleID uint) (*models.PriceSchedule, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	if scheduleID == 0 || int(scheduleID) > len(r.schedules) || r.schedules[scheduleID-1].ProjectID != projectID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	schedule := &r.schedules[scheduleID-1]
+	switch schedule.Status {
+	case models.ScheduleApplied:
+		return nil, ErrScheduleNotPending
+	case models.SchedulePending:
+		schedule.Status = models.ScheduleCancelled
+		schedule.UpdatedAt = r.now()
+	}
+	cancelled := *schedule
+	return &cancelled, nil
+}
+
+// ApplyDue applies due schedules, oldest first
+func (r *InMemoryPricingRepository) ApplyDue(now time.Time) ([]models.PriceSchedule, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	due := r.find(func(s *models.PriceSchedule) bool {
+		return s.Status == models.SchedulePending && !s.EffectiveFrom.After(now)
+	})
+	for i := range due {
+		schedule := &r.schedules[due[i].ID-1]
+		if err := r.setPrices(schedule.ProjectID, schedule.PricePerTonne, schedule.Tiers,
+			models.PriceChangeSchedule, &due[i].ID, schedule.EffectiveFrom); err != nil {
+			return due[:i], err
+		}
+		schedule.Status = models.ScheduleApplied
+		schedule.AppliedAt = &now
+		schedule.UpdatedAt = now
+		due[i] = *schedule
+	}
+	return due, nil
+}
+
+// History returns a page of a project's price changes, newest first
+func (r *InMemoryPricingRepository) History(projectID uint, limit, offset int) ([]models.PriceChange, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	changes := r.changes(projectID)
+	if offset > len(changes) {
+		offset = len(changes)
+	}
+	changes = changes[offset:]
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+// Quote prices tonnes of a published project at a moment
+func (r *InMemoryPricingRepository) Quote(projectID uint, tonnes float64, at time.Time) (*models.Quote, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok || project.Status != models.StatusPublished {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var schedule *models.PriceSchedule
+	pending := r.find(func(s *models.PriceSchedule) bool {
+		return s.ProjectID == projectID && s.Status == models.SchedulePending && !s.EffectiveFrom.After(at)
+	})
+	if len(pending) > 0 {
+		schedule = &pending[len(pending)-1]
+	}
+
+	var change *models.PriceChange
+	changes := r.changes(projectID)
+	for i := range changes {
+		if !changes[i].EffectiveFrom.After(at) {
+			change = &changes[i]
+			break
+		}
+	}
+	return quoteAt(&project, schedule, change, len(changes) > 0, tonnes, at)
+}
+
+// pricing returns a project's current prices and pending schedules. The
+// caller holds the lock.
+func (r *InMemoryPricingRepository) pricing(projectID uint) (*models.ProjectPricing, error) {
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	pending := r.find(func(s *models.PriceSchedule) bool {
+		return s.ProjectID == projectID && s.Status == models.SchedulePending
+	})
+	return pricingOf(&project, pending), nil
+}
+
+// setPrices changes a project's tiers, and its base price when it is not
+// zero, re-indexes it and records the change and its price_changed event.
+// The caller holds the lock.
+func (r *InMemoryPricingRepository) setPrices(projectID uint, price float64, tiers []models.PriceTier, source string, scheduleID *uint, effective time.Time) error {
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	changes := priceChanges(project.PricePerTonne, price)
+	setFloat(&project.PricePerTonne, price)
+	project.PriceTiers = tiers
+	project.UpdatedAt = time.Now()
+	r.projects.projects[projectID] = project
+	r.projects.recordPrice(&project, source, scheduleID, effective)
+	r.projects.recordEvent(models.EventProjectPriceChanged, &project, changes, project.UpdatedAt)
+	return r.projects.search.Index(&project)
+}
+
+// find returns copies of the matching schedules by effective time. The
+// caller holds the lock.
+func (r *InMemoryPricingRepository) find(match func(s *models.PriceSchedule) bool) []models.PriceSchedule {
+	found := []models.PriceSchedule{}
+	for i := range r.schedules {
+		if match(&r.schedules[i]) {
+			found = append(found, r.schedules[i])
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].EffectiveFrom.Before(found[j].EffectiveFrom) })
+	return found
+}
+
+// changes returns a project's price changes, newest first. The caller
+// holds the lock.
+func (r *InMemoryPricingRepository) changes(projectID uint) []models.PriceChange {
+	changes := []models.PriceChange{}
+	for _, change := range r.projects.prices {
+		if change.ProjectID == projectID {
+			changes = append(changes, change)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if !changes[i].EffectiveFrom.Equal(changes[j].EffectiveFrom) {
+			return changes[i].EffectiveFrom.After(changes[j].EffectiveFrom)
+		}
+		return changes[i].ID > changes[j].ID
+	})
+	return changes
+}
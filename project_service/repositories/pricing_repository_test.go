@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// newPricingRepo returns a pricing repository over one published project at
+// 10 per tonne, with a clock the test can move
+func newPricingRepo(t *testing.T) (*InMemoryPricingRepository, *InMemoryProjectRepository, *time.Time) {
+	t.Helper()
+	projects := NewInMemoryProjectRepository()
+	if err := projects.Create(&models.Project{Title: "Mangrove Restoration", PricePerTonne: 10, Status: models.StatusPublished}, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Add(time.Hour)
+	repo := NewInMemoryPricingRepository(projects)
+	repo.now = func() time.Time { return now }
+	return repo, projects, &now
+}
+
+var volumeTiers = []models.PriceTier{{MinTonnes: 100, PricePerTonne: 9}, {MinTonnes: 1000, PricePerTonne: 8}}
+
+func TestPriceFor(t *testing.T) {
+	for _, tt := range []struct {
+		tonnes float64
+		price  float64
+		tier   bool
+	}{
+		{1, 10, false},
+		{99.5, 10, false},
+		{100, 9, true},
+		{999, 9, true},
+		{1000, 8, true},
+		{50000, 8, true},
+	} {
+		price, tier := models.PriceFor(10, volumeTiers, tt.tonnes)
+		if price != tt.price || (tier != nil) != tt.tier {
+			t.Errorf("%v tonnes: expected %v (tier %v), got %v (%+v)", tt.tonnes, tt.price, tt.tier, price, tier)
+		}
+	}
+}
+
+func TestSortTiers(t *testing.T) {
+	tiers := []models.PriceTier{{MinTonnes: 1000, PricePerTonne: 8}, {MinTonnes: 100, PricePerTonne: 9}}
+	if !models.SortTiers(tiers) || tiers[0].MinTonnes != 100 {
+		t.Errorf("expected the tiers sorted, got %+v", tiers)
+	}
+	if models.SortTiers([]models.PriceTier{{MinTonnes: 100}, {MinTonnes: 100}}) {
+		t.Error("expected tiers sharing a minimum to be rejected")
+	}
+}
+
+func TestSetPricingQuotesAndRecords(t *testing.T) {
+	repo, projects, _ := newPricingRepo(t)
+
+	pricing, err := repo.SetPricing(1, 0, volumeTiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pricing.PricePerTonne != 10 || len(pricing.Tiers) != 2 {
+		t.Errorf("expected the base price kept and two tiers, got %+v", pricing)
+	}
+	if project, _ := projects.GetByID(1); len(project.PriceTiers) != 2 {
+		t.Errorf("expected the project to carry its tiers, got %+v", project.PriceTiers)
+	}
+
+	quote, err := repo.Quote(1, 250, time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quote.PricePerTonne != 9 || quote.Total != 2250 || quote.Tier == nil || quote.Tier.MinTonnes != 100 {
+		t.Errorf("expected 250 tonnes at the 100 tonne tier, got %+v", quote)
+	}
+
+	history, _ := repo.History(1, 10, 0)
+	if len(history) != 2 || history[0].Source != models.PriceChangeTiers || history[1].Source != models.PriceChangeCreate {
+		t.Fatalf("expected the tiers change over the creation, got %+v", history)
+	}
+
+	if _, err := repo.SetPricing(9, 0, nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing project, got %v", err)
+	}
+}
+
+func TestScheduledPrices(t *testing.T) {
+	repo, projects, now := newPricingRepo(t)
+
+	if err := repo.Schedule(1, &models.PriceSchedule{PricePerTonne: 12, EffectiveFrom: *now}); !errors.Is(err, ErrScheduleInPast) {
+		t.Errorf("expected a schedule in the past to be refused, got %v", err)
+	}
+
+	from := now.Add(24 * time.Hour)
+	schedule := &models.PriceSchedule{PricePerTonne: 12, Tiers: volumeTiers, EffectiveFrom: from}
+	if err := repo.Schedule(1, schedule); err != nil {
+		t.Fatal(err)
+	}
+	cancelled := &models.PriceSchedule{PricePerTonne: 20, EffectiveFrom: from.Add(time.Hour)}
+	repo.Schedule(1, cancelled)
+	if _, err := repo.CancelSchedule(1, cancelled.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CancelSchedule(1, cancelled.ID); err != nil {
+		t.Errorf("expected cancelling again to be a no-op, got %v", err)
+	}
+
+	// Quotes look ahead to pending schedules before they are applied
+	before, _ := repo.Quote(1, 10, from.Add(-time.Second))
+	after, _ := repo.Quote(1, 10, from.Add(2*time.Hour))
+	if before.PricePerTonne != 10 || after.PricePerTonne != 12 || after.ScheduleID == nil || *after.ScheduleID != schedule.ID {
+		t.Errorf("expected 10 before and 12 from the schedule, got %+v and %+v", before, after)
+	}
+
+	if applied, _ := repo.ApplyDue(from.Add(-time.Second)); len(applied) != 0 {
+		t.Errorf("expected nothing due yet, got %+v", applied)
+	}
+	applied, err := repo.ApplyDue(from.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0].Status != models.ScheduleApplied {
+		t.Fatalf("expected the schedule applied, got %+v", applied)
+	}
+	if project, _ := projects.GetByID(1); project.PricePerTonne != 12 || len(project.PriceTiers) != 2 {
+		t.Errorf("expected the scheduled prices on the project, got %+v", project)
+	}
+	if _, err := repo.CancelSchedule(1, schedule.ID); !errors.Is(err, ErrScheduleNotPending) {
+		t.Errorf("expected an applied schedule not to cancel, got %v", err)
+	}
+
+	// The history is recorded from the effective time, so past quotes
+	// still see the old price
+	history, _ := repo.History(1, 10, 0)
+	if history[0].Source != models.PriceChangeSchedule || !history[0].EffectiveFrom.Equal(from) {
+		t.Errorf("expected the schedule's change at %v, got %+v", from, history[0])
+	}
+	if quote, _ := repo.Quote(1, 10, from.Add(-time.Minute)); quote.PricePerTonne != 10 {
+		t.Errorf("expected the old price before the schedule, got %+v", quote)
+	}
+	if _, err := repo.Quote(1, 10, time.Now().Add(-24*time.Hour)); !errors.Is(err, ErrNoPriceAt) {
+		t.Errorf("expected no price before the project existed, got %v", err)
+	}
+}
+
+func TestProjectUpdateRecordsPrice(t *testing.T) {
+	repo, projects, _ := newPricingRepo(t)
+
+	projects.Update(1, &models.UpdateProjectRequest{Title: "Mangrove Restoration II"}, models.Change{})
+	projects.Update(1, &models.UpdateProjectRequest{PricePerTonne: 11}, models.Change{})
+
+	history, _ := repo.History(1, 10, 0)
+	if len(history) != 2 || history[0].Source != models.PriceChangeUpdate || history[0].PricePerTonne != 11 {
+		t.Errorf("expected only the price update recorded, got %+v", history)
+	}
+	if page, _ := repo.History(1, 1, 1); len(page) != 1 || page[0].Source != models.PriceChangeCreate {
+		t.Errorf("expected the second page to hold the creation, got %+v", page)
+	}
+}
@@ -1,286 +1,360 @@
 package repositories
 
 import (
-	"context"
-	"fmt"
-	"project_service/config"
+	"errors"
 	"project_service/models"
-	"strings"
+	"time"
 
-	"github.com/elastic/go-elasticsearch/v8"
-	"github.com/elastic/go-elasticsearch/v8/esapi"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type ProjectRepository struct {
-	db    *gorm.DB
-	es    *elasticsearch.Client
-	index string
-}
+var (
+	// ErrInvalidTransition is returned when a lifecycle action does not
+	// leave the project's current status
+	ErrInvalidTransition = errors.New("transition not allowed from the current status")
+	// ErrSelfReview is returned when whoever submitted a project tries to
+	// approve or reject it
+	ErrSelfReview = errors.New("a project may not be reviewed by its submitter")
+	// ErrCapacityExceedsTotal is returned when a write would leave a project
+	// with more available capacity than its total
+	ErrCapacityExceedsTotal = errors.New("available capacity exceeds total capacity")
+	// ErrCapacityTaken is returned when a revert would leave a project with
+	// less total capacity than it has sold or holds
+	ErrCapacityTaken = errors.New("total capacity is less than the tonnes sold and held")
+)
 
-func NewProjectRepository() *ProjectRepository {
-	return &ProjectRepository{
-		db:    config.DB,
-		index: "projects",
-	}
+// ProjectRepository persists projects and answers catalog queries. Every
+// create, update, transition and revert is recorded as a revision, and its
+// events are added to the outbox, in the same transaction.
+type ProjectRepository interface {
+	Create(project *models.Project, change models.Change) error
+	GetByID(id uint) (*models.Project, error)
+	GetByExternalRef(ref string) (*models.Project, error)
+	GetAll(listReq *models.ProjectListRequest) (*models.ProjectPage, error)
+	GetPublishedAfter(afterID uint, limit int) ([]models.Project, error)
+	GetAfter(afterID uint, limit int) ([]models.Project, error)
+	// GetByDeveloper returns a page of the projects a developer owns, in
+	// every status, newest first
+	GetByDeveloper(developerID uint, limit, offset int) (*models.ProjectPage, error)
+	Update(id uint, project *models.UpdateProjectRequest, change models.Change) error
+	// Patch merges a JSON Merge Patch into a project, so fields can be set
+	// to zero or cleared. A member that cannot be applied is a
+	// *models.PatchError.
+	Patch(id uint, patch models.ProjectPatch, change models.Change) (*models.Project, error)
+	Transition(id uint, transition models.Transition, actor models.Actor, reason string) (*models.Project, error)
+	Transitions(id uint) ([]models.ProjectTransition, error)
+	// Revisions returns a page of a project's revisions, newest first
+	Revisions(id uint, limit, offset int) ([]models.ProjectRevision, error)
+	// RevisionAt returns the revision a project was at at a moment,
+	// gorm.ErrRecordNotFound when it has none from before then
+	RevisionAt(id uint, at time.Time) (*models.ProjectRevision, error)
+	// Revert restores a project's fields, but its status, to one of its
+	// revisions and records the revert as a revision of its own
+	Revert(id, revisionID uint, change models.Change) (*models.Project, error)
+	Search(searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error)
+	GetCategories() ([]string, error)
+	GetRegions() ([]string, error)
+	GetCountries() ([]string, error)
 }
 
-// SetElasticsearchClient sets the Elasticsearch client
-func (r *ProjectRepository) SetElasticsearchClient(client *elasticsearch.Client) {
-	r.es = client
+// PostgresProjectRepository is the GORM-backed ProjectRepository. Searches
+// go to a SearchBackend, Postgres full-text search unless another is set;
+// the outbox relay keeps it in sync with project writes.
+type PostgresProjectRepository struct {
+	db     *gorm.DB
+	search SearchBackend
 }
 
-// Create creates a new project
-func (r *ProjectRepository) Create(project *models.Project) error {
-	if err := r.db.Create(project).Error; err != nil {
-		return err
+func NewPostgresProjectRepository(db *gorm.DB) *PostgresProjectRepository {
+	return &PostgresProjectRepository{
+		db:     db,
+		search: NewPostgresSearchBackend(db),
 	}
+}
 
-	// Index in Elasticsearch
-	if r.es != nil {
-		go r.indexProject(project)
-	}
+// SetSearchBackend sets the backend that answers searches
+func (r *PostgresProjectRepository) SetSearchBackend(backend SearchBackend) {
+	r.search = backend
+}
 
-	return nil
+// Create creates a new project and starts its price history and revisions
+func (r *PostgresProjectRepository) Create(project *models.Project, change models.Change) error {
+	project.WithholdBuffer(0, true)
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(project).Error; err != nil {
+			return err
+		}
+		if err := recordPrice(tx, project, models.PriceChangeCreate, nil, project.CreatedAt); err != nil {
+			return err
+		}
+		fields := models.FieldsOf(project)
+		if err := recordRevision(tx, models.NewRevision(project.ID, models.RevisionCreate, nil, fields, change, project.CreatedAt)); err != nil {
+			return err
+		}
+		return recordEvents(tx, project.ID, nil, fields, project.CreatedAt)
+	})
 }
 
 // GetByID retrieves a project by ID
-func (r *ProjectRepository) GetByID(id uint) (*models.Project, error) {
+func (r *PostgresProjectRepository) GetByID(id uint) (*models.Project, error) {
 	var project models.Project
-	if err := r.db.First(&project, id).Error; err != nil {
+	if err := r.db.Preload("Vintages", byYear).First(&project, id).Error; err != nil {
 		return nil, err
 	}
 	return &project, nil
 }
 
-// GetAll retrieves all projects with pagination
-func (r *ProjectRepository) GetAll(limit, offset int) ([]models.Project, error) {
-	var projects []models.Project
-	if err := r.db.Where("status = ?", "active").
-		Limit(limit).Offset(offset).Find(&projects).Error; err != nil {
+// GetByExternalRef retrieves a project by its external reference
+func (r *PostgresProjectRepository) GetByExternalRef(ref string) (*models.Project, error) {
+	var project models.Project
+	if err := r.db.Preload("Vintages", byYear).Where("external_ref = ?", ref).First(&project).Error; err != nil {
 		return nil, err
 	}
-	return projects, nil
+	return &project, nil
 }
 
-// Update updates a project
-func (r *ProjectRepository) Update(id uint, project *models.UpdateProjectRequest) error {
-	if err := r.db.Model(&models.Project{}).Where("id = ?", id).Updates(project).Error; err != nil {
-		return err
+// GetAll retrieves a sorted page of published projects, after the cursor if
+// one is given or else at the offset
+func (r *PostgresProjectRepository) GetAll(listReq *models.ProjectListRequest) (*models.ProjectPage, error) {
+	name, order := sortFor(listReq.Sort)
+	after, err := decodeCursor(listReq.Cursor, name)
+	if err != nil {
+		return nil, err
 	}
 
-	// Update in Elasticsearch
-	if r.es != nil {
-		go r.indexProjectByID(id)
-	}
+	query := r.db.Model(&models.Project{}).Where("status = ?", models.StatusPublished).Session(&gorm.Session{})
 
-	return nil
-}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
 
-// Delete soft deletes a project (sets status to inactive)
-func (r *ProjectRepository) Delete(id uint) error {
-	if err := r.db.Model(&models.Project{}).Where("id = ?", id).
-		Update("status", "inactive").Error; err != nil {
-		return err
+	offset := listReq.Offset
+	if after != nil {
+		offset = 0
 	}
 
-	// Remove from Elasticsearch
-	if r.es != nil {
-		go r.deleteFromElasticsearch(id)
+	// One extra row tells whether a next page exists
+	var projects []models.Project
+	if err := order.apply(query, after).Preload("Vintages", byYear).
+		Limit(listReq.Limit + 1).Offset(offset).Find(&projects).Error; err != nil {
+		return nil, err
 	}
 
-	return nil
+	page := &models.ProjectPage{Total: total}
+	var more bool
+	page.Projects, more = pageOf(projects, 0, listReq.Limit)
+	if more {
+		page.NextCursor = order.cursor(name, &page.Projects[len(page.Projects)-1])
+	}
+	return page, nil
 }
 
-// Search searches projects using Elasticsearch
-func (r *ProjectRepository) Search(searchReq *models.ProjectSearchRequest) ([]models.Project, error) {
-	if r.es == nil {
-		// Fallback to database search if Elasticsearch is not available
-		return r.databaseSearch(searchReq)
+// GetPublishedAfter retrieves up to limit published projects with an ID above
+// afterID, ordered by ID, so the whole catalog can be walked in batches
+func (r *PostgresProjectRepository) GetPublishedAfter(afterID uint, limit int) ([]models.Project, error) {
+	var projects []models.Project
+	if err := r.db.Preload("Vintages", byYear).Where("status = ? AND id > ?", models.StatusPublished, afterID).
+		Order("id").Limit(limit).Find(&projects).Error; err != nil {
+		return nil, err
 	}
+	return projects, nil
+}
 
-	query := map[string]interface{}{
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": []interface{}{},
-				"filter": []interface{}{
-					map[string]interface{}{
-						"term": map[string]interface{}{
-							"status": "active",
-						},
-					},
-				},
-			},
-		},
-		"from": searchReq.Offset,
-		"size": searchReq.Limit,
+// GetAfter is GetPublishedAfter for projects in every status
+func (r *PostgresProjectRepository) GetAfter(afterID uint, limit int) ([]models.Project, error) {
+	var projects []models.Project
+	if err := r.db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&projects).Error; err != nil {
+		return nil, err
 	}
+	return projects, nil
+}
 
-	// Add text search
-	if searchReq.Query != "" {
-		textQuery := map[string]interface{}{
-			"multi_match": map[string]interface{}{
-				"query":  searchReq.Query,
-				"fields": []string{"title^2", "description", "category", "region", "country"},
-			},
-		}
-		query["query"].(map[string]interface{})["bool"].(map[string]interface{})["must"] = append(
-			query["query"].(map[string]interface{})["bool"].(map[string]interface{})["must"].([]interface{}),
-			textQuery,
-		)
-	}
+// GetByDeveloper retrieves a page of a developer's projects, newest first
+func (r *PostgresProjectRepository) GetByDeveloper(developerID uint, limit, offset int) (*models.ProjectPage, error) {
+	query := r.db.Model(&models.Project{}).Where("developer_id = ?", developerID).Session(&gorm.Session{})
 
-	// Add category filter
-	if len(searchReq.Category) > 0 {
-		categoryFilter := map[string]interface{}{
-			"terms": map[string]interface{}{
-				"category": searchReq.Category,
-			},
-		}
-		query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"] = append(
-			query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{}),
-			categoryFilter,
-		)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	projects := []models.Project{}
+	if err := query.Preload("Vintages", byYear).Order("id DESC").
+		Limit(limit).Offset(offset).Find(&projects).Error; err != nil {
+		return nil, err
 	}
+	return &models.ProjectPage{Projects: projects, Total: total}, nil
+}
 
-	// Add region filter
-	if len(searchReq.Region) > 0 {
-		regionFilter := map[string]interface{}{
-			"terms": map[string]interface{}{
-				"region": searchReq.Region,
-			},
+// Update updates a project. The row is locked while the update is
+// compared with what it replaced.
+func (r *PostgresProjectRepository) Update(id uint, project *models.UpdateProjectRequest, change models.Change) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var before models.Project
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Project{}).Where("id = ?", id).Updates(project).Error; err != nil {
+			return err
+		}
+		var updated models.Project
+		if err := tx.First(&updated, id).Error; err != nil {
+			return err
+		}
+		if updated.AvailableCapacity > updated.TotalCapacity {
+			return ErrCapacityExceedsTotal
+		}
+		if err := withholdBuffer(tx, &updated, before.BufferCapacity, project.AvailableCapacity != 0); err != nil {
+			return err
 		}
-		query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"] = append(
-			query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{}),
-			regionFilter,
-		)
-	}
 
-	// Add country filter
-	if len(searchReq.Country) > 0 {
-		countryFilter := map[string]interface{}{
-			"terms": map[string]interface{}{
-				"country": searchReq.Country,
-			},
+		// A new base price goes into the price history
+		if project.PricePerTonne != 0 {
+			if err := recordPrice(tx, &updated, models.PriceChangeUpdate, nil, updated.UpdatedAt); err != nil {
+				return err
+			}
 		}
-		query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"] = append(
-			query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{}),
-			countryFilter,
-		)
-	}
+		fields, after := models.FieldsOf(&before), models.FieldsOf(&updated)
+		if err := recordRevision(tx, models.NewRevision(id, models.RevisionUpdate, &fields, after, change, updated.UpdatedAt)); err != nil {
+			return err
+		}
+		return recordEvents(tx, id, &fields, after, updated.UpdatedAt)
+	})
+}
 
-	// Add price range filter
-	if searchReq.MinPrice > 0 || searchReq.MaxPrice > 0 {
-		priceRange := map[string]interface{}{}
-		if searchReq.MinPrice > 0 {
-			priceRange["gte"] = searchReq.MinPrice
+// Patch merges a patch into a project. Every editable column is written,
+// so a patch can set one to its zero value.
+func (r *PostgresProjectRepository) Patch(id uint, patch models.ProjectPatch, change models.Change) (*models.Project, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var before models.Project
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, id).Error; err != nil {
+			return err
 		}
-		if searchReq.MaxPrice > 0 {
-			priceRange["lte"] = searchReq.MaxPrice
+		patched := before
+		if err := patch.Apply(&patched); err != nil {
+			return err
 		}
-		priceFilter := map[string]interface{}{
-			"range": map[string]interface{}{
-				"price_per_tonne": priceRange,
-			},
+		if patched.AvailableCapacity > patched.TotalCapacity {
+			return ErrCapacityExceedsTotal
 		}
-		query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"] = append(
-			query["query"].(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{}),
-			priceFilter,
-		)
-	}
-
-	// Execute search
-	req := esapi.SearchRequest{
-		Index: []string{r.index},
-		Body:  strings.NewReader(fmt.Sprintf("%v", query)),
-	}
+		_, availableSet := patch["available_capacity"]
+		patched.WithholdBuffer(before.BufferCapacity, availableSet)
+
+		now := time.Now()
+		columns := models.FieldsOf(&patched).Revertible()
+		columns["available_capacity"] = patched.AvailableCapacity
+		columns["buffer_capacity"] = patched.BufferCapacity
+		columns["updated_at"] = now
+		if err := tx.Model(&models.Project{}).Where("id = ?", id).Updates(columns).Error; err != nil {
+			return err
+		}
+		patched.UpdatedAt = now
 
-	res, err := req.Do(context.Background(), r.es)
+		if patched.PricePerTonne != before.PricePerTonne {
+			if err := recordPrice(tx, &patched, models.PriceChangeUpdate, nil, now); err != nil {
+				return err
+			}
+		}
+		fields, after := models.FieldsOf(&before), models.FieldsOf(&patched)
+		if err := recordRevision(tx, models.NewRevision(id, models.RevisionUpdate, &fields, after, change, now)); err != nil {
+			return err
+		}
+		return recordEvents(tx, id, &fields, after, now)
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
-
-	if res.IsError() {
-		return nil, fmt.Errorf("elasticsearch search error: %s", res.String())
-	}
-
-	// Parse response and convert to projects
-	// This is a simplified version - in production, you'd want proper JSON parsing
-	return r.parseSearchResponse(res)
+	return r.GetByID(id)
 }
 
-// databaseSearch is a fallback search method using database
-func (r *ProjectRepository) databaseSearch(searchReq *models.ProjectSearchRequest) ([]models.Project, error) {
-	query := r.db.Where("status = ?", "active")
-
-	if searchReq.Query != "" {
-		searchPattern := "%" + searchReq.Query + "%"
-		query = query.Where("title ILIKE ? OR description ILIKE ? OR category ILIKE ? OR region ILIKE ? OR country ILIKE ?",
-			searchPattern, searchPattern, searchPattern, searchPattern, searchPattern)
-	}
-
-	if len(searchReq.Category) > 0 {
-		query = query.Where("category IN ?", searchReq.Category)
-	}
-
-	if len(searchReq.Region) > 0 {
-		query = query.Where("region IN ?", searchReq.Region)
-	}
-
-	if len(searchReq.Country) > 0 {
-		query = query.Where("country IN ?", searchReq.Country)
-	}
-
-	if searchReq.MinPrice > 0 {
-		query = query.Where("price_per_tonne >= ?", searchReq.MinPrice)
-	}
-
-	if searchReq.MaxPrice > 0 {
-		query = query.Where("price_per_tonne <= ?", searchReq.MaxPrice)
-	}
-
-	var projects []models.Project
-	if err := query.Limit(searchReq.Limit).Offset(searchReq.Offset).Find(&projects).Error; err != nil {
-		return nil, err
-	}
-
-	return projects, nil
+// withholdBuffer withholds a written project's buffer pool and stores the
+// capacities it leaves
+func withholdBuffer(tx *gorm.DB, project *models.Project, withheldBefore float64, availableSet bool) error {
+	project.WithholdBuffer(withheldBefore, availableSet)
+	return tx.Model(&models.Project{}).Where("id = ?", project.ID).UpdateColumns(map[string]interface{}{
+		"available_capacity": project.AvailableCapacity,
+		"buffer_capacity":    project.BufferCapacity,
+	}).Error
 }
 
-// indexProject indexes a project in Elasticsearch
-func (r *ProjectRepository) indexProject(project *models.Project) {
-	// Implementation for indexing project in Elasticsearch
-	// This would involve creating/updating the document in the index
-}
+// Transition moves a project through its lifecycle and records who moved
+// it and why. The project row is locked so concurrent transitions see each
+// other's status.
+func (r *PostgresProjectRepository) Transition(id uint, transition models.Transition, actor models.Actor, reason string) (*models.Project, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&project, id).Error; err != nil {
+			return err
+		}
+		if !transition.Allows(project.Status) {
+			return ErrInvalidTransition
+		}
+		if transition.Review {
+			var submitted models.ProjectTransition
+			err := tx.Where("project_id = ? AND action = ?", id, models.ActionSubmit).Order("id DESC").First(&submitted).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil && submitted.ActorID == actor.ID {
+				return ErrSelfReview
+			}
+		}
 
-// indexProjectByID indexes a project by ID in Elasticsearch
-func (r *ProjectRepository) indexProjectByID(id uint) {
-	// Fetch project from database and index it
-	project, err := r.GetByID(id)
+		// Updates sets the new status on project too
+		now, from := time.Now(), project.Status
+		revision := transitionRevision(&project, transition, actor, reason, now)
+		before := models.FieldsOf(&project)
+		if err := tx.Model(&project).Updates(map[string]interface{}{
+			"status":     transition.To,
+			"updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(tx, revision); err != nil {
+			return err
+		}
+		if err := recordEvents(tx, id, &before, models.FieldsOf(&project), now); err != nil {
+			return err
+		}
+		return tx.Create(&models.ProjectTransition{
+			ProjectID:  id,
+			Action:     transition.Action,
+			FromStatus: from,
+			ToStatus:   transition.To,
+			Reason:     reason,
+			ActorID:    actor.ID,
+			ActorRole:  actor.Role,
+		}).Error
+	})
 	if err != nil {
-		return
+		return nil, err
 	}
-	r.indexProject(project)
+	return r.GetByID(id)
 }
 
-// deleteFromElasticsearch removes a project from Elasticsearch index
-func (r *ProjectRepository) deleteFromElasticsearch(id uint) {
-	// Implementation for deleting project from Elasticsearch
+// Transitions returns a project's lifecycle history, oldest first
+func (r *PostgresProjectRepository) Transitions(id uint) ([]models.ProjectTransition, error) {
+	if err := projectExists(r.db, id); err != nil {
+		return nil, err
+	}
+	var transitions []models.ProjectTransition
+	if err := r.db.Where("project_id = ?", id).Order("id").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+	return transitions, nil
 }
 
-// parseSearchResponse parses Elasticsearch search response
-func (r *ProjectRepository) parseSearchResponse(res *esapi.Response) ([]models.Project, error) {
-	// Implementation for parsing Elasticsearch response
-	// This is a placeholder - proper implementation would parse JSON response
-	return []models.Project{}, nil
+// Search searches projects through the search backend
+func (r *PostgresProjectRepository) Search(searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error) {
+	return r.search.Search(searchReq)
 }
 
 // GetCategories retrieves all unique categories
-func (r *ProjectRepository) GetCategories() ([]string, error) {
+func (r *PostgresProjectRepository) GetCategories() ([]string, error) {
 	var categories []string
 	if err := r.db.Model(&models.Project{}).
-		Where("status = ?", "active").
+		Where("status = ?", models.StatusPublished).
 		Distinct("category").
 		Pluck("category", &categories).Error; err != nil {
 		return nil, err
@@ -289,10 +363,10 @@ func (r *ProjectRepository) GetCategories() ([]string, error) {
 }
 
 // GetRegions retrieves all unique regions
-func (r *ProjectRepository) GetRegions() ([]string, error) {
+func (r *PostgresProjectRepository) GetRegions() ([]string, error) {
 	var regions []string
 	if err := r.db.Model(&models.Project{}).
-		Where("status = ?", "active").
+		Where("status = ?", models.StatusPublished).
 		Distinct("region").
 		Pluck("region", &regions).Error; err != nil {
 		return nil, err
@@ -301,10 +375,10 @@ func (r *ProjectRepository) GetRegions() ([]string, error) {
 }
 
 // GetCountries retrieves all unique countries
-func (r *ProjectRepository) GetCountries() ([]string, error) {
+func (r *PostgresProjectRepository) GetCountries() ([]string, error) {
 	var countries []string
 	if err := r.db.Model(&models.Project{}).
-		Where("status = ?", "active").
+		Where("status = ?", models.StatusPublished).
 		Distinct("country").
 		Pluck("country", &countries).Error; err != nil {
 		return nil, err
@@ -0,0 +1,422 @@
+package repositories
+
+import (
+	"project_service/models"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryProjectRepository is a ProjectRepository kept in process memory.
+// It follows the Postgres semantics handlers depend on: missing rows return
+// gorm.ErrRecordNotFound, updates skip zero-valued fields and lifecycle
+// transitions are checked and recorded the same way, as are revisions. Searches are ranked by an embedded search
+// index kept in step with every write. Price changes, the capacity ledger
+// and the outbox of project events are kept here too, so project updates
+// and the pricing, vintage and reservation repositories share one history,
+// one ledger and one outbox.
+type InMemoryProjectRepository struct {
+	mu       sync.RWMutex
+	projects map[uint]models.Project
+	nextID   uint
+	search   *EmbeddedSearchBackend
+	prices   []models.PriceChange
+	// transitions is every project's lifecycle history, oldest first
+	transitions []models.ProjectTransition
+	// revisions is every project's revisions, oldest first
+	revisions []models.ProjectRevision
+	// ledger is every project's capacity ledger, oldest first
+	ledger []models.CapacityLedgerEntry
+	// outbox is every project event not purged, oldest first
+	outbox      []models.OutboxEvent
+	lastEventID uint
+}
+
+func NewInMemoryProjectRepository() *InMemoryProjectRepository {
+	return &InMemoryProjectRepository{
+		projects: make(map[uint]models.Project),
+		nextID:   1,
+		search:   NewEmbeddedSearchBackend(),
+	}
+}
+
+// Create creates a new project
+func (r *InMemoryProjectRepository) Create(project *models.Project, change models.Change) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if project.ExternalRef != nil {
+		for _, existing := range r.projects {
+			if existing.ExternalRef != nil && *existing.ExternalRef == *project.ExternalRef {
+				return gorm.ErrDuplicatedKey
+			}
+		}
+	}
+
+	now := time.Now()
+	project.ID = r.nextID
+	project.CreatedAt = now
+	project.UpdatedAt = now
+	if project.Status == "" {
+		project.Status = models.StatusDraft
+	}
+	project.WithholdBuffer(0, true)
+	r.nextID++
+	r.projects[project.ID] = *project
+	r.recordPrice(project, models.PriceChangeCreate, nil, now)
+	r.recordRevision(models.NewRevision(project.ID, models.RevisionCreate, nil, models.FieldsOf(project), change, now))
+	r.recordEvents(project.ID, nil, models.FieldsOf(project), now)
+	return r.search.Index(project)
+}
+
+// GetByID retrieves a project by ID
+func (r *InMemoryProjectRepository) GetByID(id uint) (*models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	project, ok := r.projects[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &project, nil
+}
+
+// GetByExternalRef retrieves a project by its external reference
+func (r *InMemoryProjectRepository) GetByExternalRef(ref string) (*models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, project := range r.projects {
+		if project.ExternalRef != nil && *project.ExternalRef == ref {
+			return &project, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// GetAll retrieves a sorted page of published projects, after the cursor if
+// one is given or else at the offset
+func (r *InMemoryProjectRepository) GetAll(listReq *models.ProjectListRequest) (*models.ProjectPage, error) {
+	name, order := sortFor(listReq.Sort)
+	after, err := decodeCursor(listReq.Cursor, name)
+	if err != nil {
+		return nil, err
+	}
+
+	projects, total := r.find(func(p *models.Project) bool { return true }, 0, 0)
+	sort.SliceStable(projects, func(i, j int) bool { return order.less(&projects[i], &projects[j]) })
+
+	offset := listReq.Offset
+	if after != nil {
+		offset = 0
+		for offset < len(projects) && !order.after(&projects[offset], after) {
+			offset++
+		}
+	}
+
+	page := &models.ProjectPage{Total: int64(total)}
+	var more bool
+	page.Projects, more = pageOf(projects, offset, listReq.Limit)
+	if more {
+		page.NextCursor = order.cursor(name, &page.Projects[len(page.Projects)-1])
+	}
+	return page, nil
+}
+
+// GetPublishedAfter retrieves up to limit published projects with an ID above
+// afterID, ordered by ID
+func (r *InMemoryProjectRepository) GetPublishedAfter(afterID uint, limit int) ([]models.Project, error) {
+	projects, _ := r.find(func(p *models.Project) bool { return p.ID > afterID }, limit, 0)
+	return projects, nil
+}
+
+// GetAfter is GetPublishedAfter for projects in every status
+func (r *InMemoryProjectRepository) GetAfter(afterID uint, limit int) ([]models.Project, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	projects := []models.Project{}
+	for _, project := range r.projects {
+		if project.ID > afterID {
+			projects = append(projects, project)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+	if limit > 0 && limit < len(projects) {
+		projects = projects[:limit]
+	}
+	return projects, nil
+}
+
+// GetByDeveloper retrieves a page of a developer's projects, newest first
+func (r *InMemoryProjectRepository) GetByDeveloper(developerID uint, limit, offset int) (*models.ProjectPage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	projects := []models.Project{}
+	for _, project := range r.projects {
+		if project.DeveloperID != nil && *project.DeveloperID == developerID {
+			projects = append(projects, project)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID > projects[j].ID })
+
+	page := &models.ProjectPage{Total: int64(len(projects))}
+	page.Projects, _ = pageOf(projects, offset, limit)
+	return page, nil
+}
+
+// Update updates the non-zero fields of a project
+func (r *InMemoryProjectRepository) Update(id uint, req *models.UpdateProjectRequest, change models.Change) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	project, ok := r.projects[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	before := models.FieldsOf(&project)
+
+	setString(&project.Title, req.Title)
+	setString(&project.Description, req.Description)
+	setString(&project.Category, req.Category)
+	setString(&project.Region, req.Region)
+	setString(&project.Country, req.Country)
+	setString(&project.VerificationStandard, req.VerificationStandard)
+	setFloat(&project.PricePerTonne, req.PricePerTonne)
+	setFloat(&project.TotalCapacity, req.TotalCapacity)
+	setFloat(&project.AvailableCapacity, req.AvailableCapacity)
+	setString(&project.ProjectDeveloper, req.ProjectDeveloper)
+	if req.DeveloperID != nil {
+		project.DeveloperID = req.DeveloperID
+	}
+	setString(&project.ProjectURL, req.ProjectURL)
+	setString(&project.ImageURL, req.ImageURL)
+	if req.Latitude != nil {
+		project.Latitude, project.Longitude = req.Latitude, req.Longitude
+	}
+	if req.Boundary != nil {
+		project.Boundary = req.Boundary
+	}
+	setString(&project.MethodologyID, req.MethodologyID)
+	setString(&project.CreditType, req.CreditType)
+	setString(&project.SolutionType, req.SolutionType)
+	setInt(&project.PermanenceYears, req.PermanenceYears)
+	setFloat(&project.BufferPoolPercent, req.BufferPoolPercent)
+	setString(&project.AdditionalityNotes, req.AdditionalityNotes)
+	if req.SDGGoals != nil {
+		project.SDGGoals = req.SDGGoals
+	}
+	if project.AvailableCapacity > project.TotalCapacity {
+		return ErrCapacityExceedsTotal
+	}
+	project.WithholdBuffer(project.BufferCapacity, req.AvailableCapacity != 0)
+	project.UpdatedAt = time.Now()
+
+	r.projects[id] = project
+	if req.PricePerTonne != 0 {
+		r.recordPrice(&project, models.PriceChangeUpdate, nil, project.UpdatedAt)
+	}
+	r.recordRevision(models.NewRevision(id, models.RevisionUpdate, &before, models.FieldsOf(&project), change, project.UpdatedAt))
+	r.recordEvents(id, &before, models.FieldsOf(&project), project.UpdatedAt)
+	return r.search.Index(&project)
+}
+
+// Patch merges a patch into a project
+func (r *InMemoryProjectRepository) Patch(id uint, patch models.ProjectPatch, change models.Change) (*models.Project, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	before, ok := r.projects[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	project := before
+	if err := patch.Apply(&project); err != nil {
+		return nil, err
+	}
+	if project.AvailableCapacity > project.TotalCapacity {
+		return nil, ErrCapacityExceedsTotal
+	}
+	_, availableSet := patch["available_capacity"]
+	project.WithholdBuffer(before.BufferCapacity, availableSet)
+	project.UpdatedAt = time.Now()
+
+	r.projects[id] = project
+	if project.PricePerTonne != before.PricePerTonne {
+		r.recordPrice(&project, models.PriceChangeUpdate, nil, project.UpdatedAt)
+	}
+	fields := models.FieldsOf(&before)
+	r.recordRevision(models.NewRevision(id, models.RevisionUpdate, &fields, models.FieldsOf(&project), change, project.UpdatedAt))
+	r.recordEvents(id, &fields, models.FieldsOf(&project), project.UpdatedAt)
+	return &project, r.search.Index(&project)
+}
+
+// Transition moves a project through its lifecycle and records who moved
+// it and why
+func (r *InMemoryProjectRepository) Transition(id uint, transition models.Transition, actor models.Actor, reason string) (*models.Project, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	project, ok := r.projects[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if !transition.Allows(project.Status) {
+		return nil, ErrInvalidTransition
+	}
+	if transition.Review {
+		for i := len(r.transitions) - 1; i >= 0; i-- {
+			submitted := r.transitions[i]
+			if submitted.ProjectID == id && submitted.Action == models.ActionSubmit {
+				if submitted.ActorID == actor.ID {
+					return nil, ErrSelfReview
+				}
+				break
+			}
+		}
+	}
+
+	now := time.Now()
+	r.recordRevision(transitionRevision(&project, transition, actor, reason, now))
+	r.transitions = append(r.transitions, models.ProjectTransition{
+		ID:         uint(len(r.transitions) + 1),
+		ProjectID:  id,
+		Action:     transition.Action,
+		FromStatus: project.Status,
+		ToStatus:   transition.To,
+		Reason:     reason,
+		ActorID:    actor.ID,
+		ActorRole:  actor.Role,
+		CreatedAt:  now,
+	})
+	before := models.FieldsOf(&project)
+	project.Status = transition.To
+	project.UpdatedAt = now
+	r.projects[id] = project
+	r.recordEvents(id, &before, models.FieldsOf(&project), now)
+	return &project, r.search.Index(&project)
+}
+
+// Transitions returns a project's lifecycle history, oldest first
+func (r *InMemoryProjectRepository) Transitions(id uint) ([]models.ProjectTransition, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.projects[id]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	transitions := []models.ProjectTransition{}
+	for _, transition := range r.transitions {
+		if transition.ProjectID == id {
+			transitions = append(transitions, transition)
+		}
+	}
+	return transitions, nil
+}
+
+// Search ranks projects with the embedded search index
+func (r *InMemoryProjectRepository) Search(searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error) {
+	return r.search.Search(searchReq)
+}
+
+// GetCategories retrieves all unique categories
+func (r *InMemoryProjectRepository) GetCategories() ([]string, error) {
+	return r.distinct(func(p *models.Project) string { return p.Category }), nil
+}
+
+// GetRegions retrieves all unique regions
+func (r *InMemoryProjectRepository) GetRegions() ([]string, error) {
+	return r.distinct(func(p *models.Project) string { return p.Region }), nil
+}
+
+// GetCountries retrieves all unique countries
+func (r *InMemoryProjectRepository) GetCountries() ([]string, error) {
+	return r.distinct(func(p *models.Project) string { return p.Country }), nil
+}
+
+// find returns a page of published projects matching the predicate, ordered by
+// ID, and the number of matches across all pages
+func (r *InMemoryProjectRepository) find(match func(p *models.Project) bool, limit, offset int) ([]models.Project, int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	projects := []models.Project{}
+	for _, project := range r.projects {
+		if project.Status == models.StatusPublished && match(&project) {
+			projects = append(projects, project)
+		}
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].ID < projects[j].ID })
+
+	total := len(projects)
+	if offset >= total {
+		return []models.Project{}, total
+	}
+	projects = projects[offset:]
+	if limit > 0 && limit < len(projects) {
+		projects = projects[:limit]
+	}
+	return projects, total
+}
+
+func (r *InMemoryProjectRepository) distinct(field func(p *models.Project) string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := map[string]bool{}
+	values := []string{}
+	for _, project := range r.projects {
+		value := field(&project)
+		if project.Status == models.StatusPublished && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// recordPrice adds a project's current prices to its history. The caller
+// holds the lock.
+func (r *InMemoryProjectRepository) recordPrice(project *models.Project, source string, scheduleID *uint, effective time.Time) {
+	r.prices = append(r.prices, models.PriceChange{
+		ID:            uint(len(r.prices) + 1),
+		ProjectID:     project.ID,
+		PricePerTonne: project.PricePerTonne,
+		Tiers:         project.PriceTiers,
+		Source:        source,
+		ScheduleID:    scheduleID,
+		EffectiveFrom: effective,
+		CreatedAt:     time.Now(),
+	})
+}
+
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+func setFloat(dst *float64, value float64) {
+	if value != 0 {
+		*dst = value
+	}
+}
+
+func setInt(dst *int, value int) {
+	if value != 0 {
+		*dst = value
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
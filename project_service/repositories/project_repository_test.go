@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+var (
+	author   = models.Actor{ID: 1, Role: models.RoleAdmin}
+	reviewer = models.Actor{ID: 2, Role: models.RoleAdmin}
+)
+
+// retire takes a project off sale through its lifecycle
+func retire(t *testing.T, repo *InMemoryProjectRepository, id uint) {
+	t.Helper()
+	if _, err := repo.Transition(id, models.Transitions[models.ActionRetire], author, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func transition(repo *InMemoryProjectRepository, id uint, action string, actor models.Actor, reason string) (*models.Project, error) {
+	return repo.Transition(id, models.Transitions[action], actor, reason)
+}
+
+func TestTransitionLifecycle(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	project := &models.Project{Title: "Mangrove Restoration"}
+	if err := repo.Create(project, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	if project.Status != models.StatusDraft {
+		t.Fatalf("expected a new project to be a draft, got %q", project.Status)
+	}
+
+	listed := func() int64 {
+		page, _ := repo.GetAll(&models.ProjectListRequest{Limit: 10})
+		return page.Total
+	}
+
+	if _, err := transition(repo, 1, models.ActionPublish, author, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected a draft not to publish, got %v", err)
+	}
+	if _, err := transition(repo, 1, models.ActionSubmit, author, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := transition(repo, 1, models.ActionApprove, author, ""); !errors.Is(err, ErrSelfReview) {
+		t.Errorf("expected the submitter not to approve, got %v", err)
+	}
+	if _, err := transition(repo, 1, models.ActionReject, reviewer, "Missing registry link"); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []string{models.ActionRevise, models.ActionSubmit} {
+		if _, err := transition(repo, 1, action, author, ""); err != nil {
+			t.Fatalf("%s: %v", action, err)
+		}
+	}
+	if _, err := transition(repo, 1, models.ActionApprove, reviewer, ""); err != nil {
+		t.Fatal(err)
+	}
+	if listed() != 0 {
+		t.Error("expected an approved project to stay hidden until it is published")
+	}
+
+	published, err := transition(repo, 1, models.ActionPublish, author, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.Status != models.StatusPublished || listed() != 1 {
+		t.Errorf("expected the published project listed, got %q and %d listed", published.Status, listed())
+	}
+	if found, _ := repo.Search(&models.ProjectSearchRequest{Query: "mangrove", Limit: 10}); found.Total != 1 {
+		t.Errorf("expected the published project searchable, got %d hits", found.Total)
+	}
+
+	transition(repo, 1, models.ActionPause, author, "")
+	if found, _ := repo.Search(&models.ProjectSearchRequest{Query: "mangrove", Limit: 10}); found.Total != 0 || listed() != 0 {
+		t.Errorf("expected a paused project hidden, got %d hits", found.Total)
+	}
+	retire(t, repo, 1)
+	if _, err := transition(repo, 1, models.ActionResume, author, ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("expected a retired project to stay retired, got %v", err)
+	}
+
+	history, err := repo.Transitions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 8 {
+		t.Fatalf("expected eight transitions, got %+v", history)
+	}
+	rejected := history[1]
+	if rejected.Action != models.ActionReject || rejected.FromStatus != models.StatusSubmitted ||
+		rejected.ToStatus != models.StatusRejected || rejected.Reason != "Missing registry link" || rejected.ActorID != reviewer.ID {
+		t.Errorf("unexpected rejection record: %+v", rejected)
+	}
+	if last := history[len(history)-1]; last.FromStatus != models.StatusPaused || last.ToStatus != models.StatusRetired {
+		t.Errorf("expected the retirement last, got %+v", last)
+	}
+
+	if _, err := repo.Transitions(9); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing project, got %v", err)
+	}
+}
+
+func TestBufferPoolWithholdsCapacity(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	project := &models.Project{Title: "Peatland Rewetting", TotalCapacity: 1000, AvailableCapacity: 1000, BufferPoolPercent: 20}
+	if err := repo.Create(project, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	if project.BufferCapacity != 200 || project.AvailableCapacity != 800 {
+		t.Fatalf("expected 200 tonnes withheld and 800 available, got %v and %v", project.BufferCapacity, project.AvailableCapacity)
+	}
+
+	// Raising the buffer takes the extra tonnes out of what is available
+	if err := repo.Update(1, &models.UpdateProjectRequest{BufferPoolPercent: 25}, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := repo.GetByID(1)
+	if got.BufferCapacity != 250 || got.AvailableCapacity != 750 {
+		t.Errorf("expected 250 tonnes withheld and 750 available, got %v and %v", got.BufferCapacity, got.AvailableCapacity)
+	}
+
+	// An available capacity set with the write is kept within what is sellable
+	if err := repo.Update(1, &models.UpdateProjectRequest{AvailableCapacity: 900}, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = repo.GetByID(1)
+	if got.AvailableCapacity != 750 {
+		t.Errorf("expected the available capacity capped at 750, got %v", got.AvailableCapacity)
+	}
+
+	// Clearing the buffer returns its tonnes to sale
+	patched, err := repo.Patch(1, models.ProjectPatch{"buffer_pool_percent": []byte("null")}, models.Change{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.BufferCapacity != 0 || patched.AvailableCapacity != 1000 {
+		t.Errorf("expected nothing withheld and 1000 available, got %v and %v", patched.BufferCapacity, patched.AvailableCapacity)
+	}
+}
+
+func TestGetByDeveloper(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	developer, rival := uint(7), uint(8)
+	for _, owner := range []*uint{&developer, nil, &developer, &rival} {
+		if err := repo.Create(&models.Project{Title: "Project", DeveloperID: owner}, models.Change{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, err := repo.GetByDeveloper(developer, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Projects) != 2 || page.Projects[0].ID != 3 || page.Projects[1].ID != 1 {
+		t.Fatalf("expected the developer's drafts newest first, got %+v", page)
+	}
+	if page, _ := repo.GetByDeveloper(developer, 1, 1); page.Total != 2 || len(page.Projects) != 1 || page.Projects[0].ID != 1 {
+		t.Errorf("expected the oldest project on the second page, got %+v", page)
+	}
+
+	// Linking a project to another developer moves it out of the page
+	if err := repo.Update(3, &models.UpdateProjectRequest{DeveloperID: &rival}, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := repo.GetByDeveloper(developer, 10, 0); page.Total != 1 {
+		t.Errorf("expected one project left, got %+v", page)
+	}
+}
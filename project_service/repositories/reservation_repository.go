@@ -0,0 +1,419 @@
+package repositories
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"project_service/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInsufficientCapacity is returned when a project has fewer
+	// available tonnes than a reservation asks for
+	ErrInsufficientCapacity = errors.New("insufficient available capacity")
+	// ErrReservationNotHeld is returned when committing a reservation that
+	// was released or expired, or releasing one that was committed
+	ErrReservationNotHeld = errors.New("reservation is not held")
+	// ErrReservationExpired is returned when committing a reservation past
+	// its expiry; the reservation is expired and its tonnes returned
+	ErrReservationExpired = errors.New("reservation has expired")
+)
+
+// ReservationRepository holds project capacity for checkouts. Reserving
+// takes tonnes out of a project's available capacity straight away, so
+// concurrent reservations can never oversell it; committing keeps them out
+// and releasing or expiring returns them. Every change is recorded in the
+// project's capacity ledger.
+type ReservationRepository interface {
+	// Reserve holds tonnes of a project, and of one of its vintages when
+	// vintageID is set. It is idempotent per reference: while a reservation
+	// with the reference is held or committed, it returns that one.
+	Reserve(projectID, vintageID uint, tonnes float64, reference string, ttl time.Duration) (*models.Reservation, error)
+	Get(id string) (*models.Reservation, error)
+	// Commit is idempotent for a committed reservation
+	Commit(id string) (*models.Reservation, error)
+	// CommitAll commits reservations together: all of them, or none when
+	// one is no longer held. It is idempotent for committed reservations.
+	CommitAll(ids []string) ([]models.Reservation, error)
+	// Release is idempotent for a released or expired reservation
+	Release(id string) (*models.Reservation, error)
+	// ExpireDue expires the held reservations past their expiry and returns
+	// them
+	ExpireDue(now time.Time) ([]models.Reservation, error)
+	// Ledger returns a page of a project's ledger entries, newest first
+	Ledger(projectID uint, limit, offset int) ([]models.CapacityLedgerEntry, error)
+	// Sales returns a page of a project's committed reservations, newest
+	// first, with the tonnes and number of sales across all pages
+	Sales(projectID uint, limit, offset int) (*models.ProjectSales, error)
+}
+
+// expireBatchSize is the number of due reservations ExpireDue reads at a
+// time
+const expireBatchSize = 100
+
+// PostgresReservationRepository keeps reservations and the ledger in
+// Postgres. Capacity moves with conditional updates on the project row, and
+// reservation transitions lock the reservation row, so concurrent requests
+// and service instances cannot double-spend or double-return tonnes.
+type PostgresReservationRepository struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewPostgresReservationRepository(db *gorm.DB) *PostgresReservationRepository {
+	return &PostgresReservationRepository{db: db, now: time.Now}
+}
+
+// Reserve holds tonnes of a published project, and of the vintage when
+// vintageID is set
+func (r *PostgresReservationRepository) Reserve(projectID, vintageID uint, tonnes float64, reference string, ttl time.Duration) (*models.Reservation, error) {
+	now := r.now()
+	reservation := &models.Reservation{
+		ID:        newUUID(),
+		ProjectID: projectID,
+		VintageID: vintageID,
+		Tonnes:    tonnes,
+		Reference: reference,
+		Status:    models.ReservationHeld,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if reference != "" {
+			// Locking the project first serializes reservations of the
+			// reference, which is always for the same project
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Project{}, projectID).Error; err != nil {
+				return err
+			}
+			var existing models.Reservation
+			err := tx.Where("reference = ? AND status IN ?", reference, []string{models.ReservationHeld, models.ReservationCommitted}).
+				First(&existing).Error
+			if err == nil {
+				*reservation = existing
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+
+		// The update only matches while enough capacity is left
+		var project models.Project
+		result := tx.Model(&project).
+			Clauses(clause.Returning{Columns: []clause.Column{{Name: "available_capacity"}}}).
+			Where("id = ? AND status = ? AND available_capacity >= ?", projectID, models.StatusPublished, tonnes).
+			Update("available_capacity", gorm.Expr("available_capacity - ?", tonnes))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return unavailable(tx, projectID)
+		}
+		if vintageID != 0 {
+			var vintage models.Vintage
+			result := tx.Model(&vintage).
+				Clauses(clause.Returning{Columns: []clause.Column{{Name: "available_capacity"}}}).
+				Where("id = ? AND project_id = ? AND available_capacity >= ?", vintageID, projectID, tonnes).
+				Update("available_capacity", gorm.Expr("available_capacity - ?", tonnes))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return vintageUnavailable(tx, projectID, vintageID)
+			}
+		}
+
+		if err := tx.Create(reservation).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(ledgerEntry(reservation, models.LedgerReserve, -tonnes, project.AvailableCapacity)).Error; err != nil {
+			return err
+		}
+		return recordCapacityEvent(tx, projectID, project.AvailableCapacity+tonnes, project.AvailableCapacity, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// unavailable explains a failed reservation: the project is missing or
+// not published, or short of capacity
+func unavailable(tx *gorm.DB, projectID uint) error {
+	var project models.Project
+	if err := tx.Select("status").First(&project, projectID).Error; err != nil {
+		return err
+	}
+	if project.Status != models.StatusPublished {
+		return gorm.ErrRecordNotFound
+	}
+	return ErrInsufficientCapacity
+}
+
+// vintageUnavailable explains a failed reservation of a vintage: the vintage
+// is missing or not the project's, or short of capacity
+func vintageUnavailable(tx *gorm.DB, projectID, vintageID uint) error {
+	var vintage models.Vintage
+	if err := tx.Select("id").Where("project_id = ?", projectID).First(&vintage, vintageID).Error; err != nil {
+		return err
+	}
+	return ErrInsufficientCapacity
+}
+
+// Get retrieves a reservation by ID
+func (r *PostgresReservationRepository) Get(id string) (*models.Reservation, error) {
+	var reservation models.Reservation
+	if err := r.db.First(&reservation, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// Commit makes a held reservation permanent. A reservation past its expiry
+// is expired instead.
+func (r *PostgresReservationRepository) Commit(id string) (*models.Reservation, error) {
+	reservations, err := r.CommitAll([]string{id})
+	if len(reservations) == 0 {
+		return nil, err
+	}
+	return &reservations[0], err
+}
+
+// CommitAll makes held reservations permanent in one transaction. When one
+// was released, none is committed; when some are past their expiry, those
+// are expired and returned instead, and none is committed.
+func (r *PostgresReservationRepository) CommitAll(ids []string) ([]models.Reservation, error) {
+	var reservations, expired []models.Reservation
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Locking in ID order keeps concurrent commits from deadlocking
+		sorted := append([]string{}, ids...)
+		sort.Strings(sorted)
+		locked := make(map[string]*models.Reservation, len(ids))
+		var unique []*models.Reservation
+		for _, id := range sorted {
+			if locked[id] != nil {
+				continue
+			}
+			reservation, err := lockReservation(tx, id)
+			if err != nil {
+				return err
+			}
+			locked[id] = reservation
+			unique = append(unique, reservation)
+		}
+
+		now := r.now()
+		var due []*models.Reservation
+		for _, reservation := range unique {
+			switch {
+			case reservation.Status == models.ReservationCommitted:
+			case reservation.Status != models.ReservationHeld:
+				return ErrReservationNotHeld
+			case !now.Before(reservation.ExpiresAt):
+				due = append(due, reservation)
+			}
+		}
+		if len(due) > 0 {
+			for _, reservation := range due {
+				if err := restore(tx, reservation, models.ReservationExpired, models.LedgerExpire, now); err != nil {
+					return err
+				}
+				expired = append(expired, *reservation)
+			}
+			return nil
+		}
+
+		for _, id := range ids {
+			reservation := locked[id]
+			if reservation.Status == models.ReservationHeld {
+				reservation.Status = models.ReservationCommitted
+				reservation.CommittedAt = &now
+				if err := tx.Save(reservation).Error; err != nil {
+					return err
+				}
+
+				var project models.Project
+				if err := tx.Select("available_capacity").First(&project, reservation.ProjectID).Error; err != nil {
+					return err
+				}
+				if err := tx.Create(ledgerEntry(reservation, models.LedgerCommit, 0, project.AvailableCapacity)).Error; err != nil {
+					return err
+				}
+			}
+			reservations = append(reservations, *reservation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) > 0 {
+		return expired, ErrReservationExpired
+	}
+	return reservations, nil
+}
+
+// Release returns a held reservation's tonnes to the project
+func (r *PostgresReservationRepository) Release(id string) (*models.Reservation, error) {
+	var reservation *models.Reservation
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if reservation, err = lockReservation(tx, id); err != nil {
+			return err
+		}
+
+		switch reservation.Status {
+		case models.ReservationReleased, models.ReservationExpired:
+			return nil
+		case models.ReservationCommitted:
+			return ErrReservationNotHeld
+		}
+		return restore(tx, reservation, models.ReservationReleased, models.LedgerRelease, r.now())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// ExpireDue expires held reservations past their expiry, each in its own
+// transaction so a failure leaves the rest to the next sweep
+func (r *PostgresReservationRepository) ExpireDue(now time.Time) ([]models.Reservation, error) {
+	var expired []models.Reservation
+	for {
+		var due []models.Reservation
+		err := r.db.Select("id").
+			Where("status = ? AND expires_at <= ?", models.ReservationHeld, now).
+			Order("expires_at").Limit(expireBatchSize).Find(&due).Error
+		if err != nil {
+			return expired, err
+		}
+
+		for _, d := range due {
+			var reservation *models.Reservation
+			err := r.db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				if reservation, err = lockReservation(tx, d.ID); err != nil {
+					return err
+				}
+				// Another instance may have got there first
+				if reservation.Status != models.ReservationHeld {
+					reservation = nil
+					return nil
+				}
+				return restore(tx, reservation, models.ReservationExpired, models.LedgerExpire, now)
+			})
+			if err != nil {
+				return expired, err
+			}
+			if reservation != nil {
+				expired = append(expired, *reservation)
+			}
+		}
+
+		if len(due) < expireBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// Ledger returns a page of a project's ledger entries, newest first
+func (r *PostgresReservationRepository) Ledger(projectID uint, limit, offset int) ([]models.CapacityLedgerEntry, error) {
+	entries := []models.CapacityLedgerEntry{}
+	if err := r.db.Where("project_id = ?", projectID).
+		Order("id DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Sales returns a page of a project's committed reservations, newest first
+func (r *PostgresReservationRepository) Sales(projectID uint, limit, offset int) (*models.ProjectSales, error) {
+	query := r.db.Model(&models.Reservation{}).
+		Where("project_id = ? AND status = ?", projectID, models.ReservationCommitted).Session(&gorm.Session{})
+
+	sales := &models.ProjectSales{ProjectID: projectID, Sales: []models.Reservation{}}
+	row := query.Select("COALESCE(SUM(tonnes), 0), COUNT(*)").Row()
+	if err := row.Scan(&sales.TonnesSold, &sales.SaleCount); err != nil {
+		return nil, err
+	}
+	if err := query.Order("committed_at DESC, id").Limit(limit).Offset(offset).Find(&sales.Sales).Error; err != nil {
+		return nil, err
+	}
+	return sales, nil
+}
+
+// lockReservation reads a reservation and locks its row until the
+// transaction ends
+func lockReservation(tx *gorm.DB, id string) (*models.Reservation, error) {
+	var reservation models.Reservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// restore returns a held reservation's tonnes to its project, and its
+// vintage if it has one, and moves it to the released or expired status
+func restore(tx *gorm.DB, reservation *models.Reservation, status, event string, now time.Time) error {
+	var project models.Project
+	err := tx.Model(&project).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "available_capacity"}}}).
+		Where("id = ?", reservation.ProjectID).
+		Update("available_capacity", gorm.Expr("available_capacity + ?", reservation.Tonnes)).Error
+	if err != nil {
+		return err
+	}
+	if reservation.VintageID != 0 {
+		err := tx.Model(&models.Vintage{}).
+			Where("id = ?", reservation.VintageID).
+			Update("available_capacity", gorm.Expr("available_capacity + ?", reservation.Tonnes)).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	reservation.Status = status
+	reservation.ReleasedAt = &now
+	if err := tx.Save(reservation).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(ledgerEntry(reservation, event, reservation.Tonnes, project.AvailableCapacity)).Error; err != nil {
+		return err
+	}
+	return recordCapacityEvent(tx, reservation.ProjectID, project.AvailableCapacity-reservation.Tonnes, project.AvailableCapacity, now)
+}
+
+// recordCapacityEvent adds the capacity_changed event of a move of a
+// project's available capacity to the outbox
+func recordCapacityEvent(tx *gorm.DB, projectID uint, from, to float64, at time.Time) error {
+	changes := map[string]models.FieldChange{"available_capacity": {From: from, To: to}}
+	return recordProjectEvent(tx, models.EventProjectCapacityChanged, projectID, changes, at)
+}
+
+func ledgerEntry(reservation *models.Reservation, event string, delta, availableAfter float64) *models.CapacityLedgerEntry {
+	return &models.CapacityLedgerEntry{
+		ProjectID:      reservation.ProjectID,
+		VintageID:      reservation.VintageID,
+		ReservationID:  reservation.ID,
+		Event:          event,
+		Delta:          delta,
+		AvailableAfter: availableAfter,
+	}
+}
+
+// newUUID returns a random UUID (version 4)
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,252 @@
+package repositories
+
+import (
+	"project_service/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryReservationRepository is a ReservationRepository over an
+// InMemoryProjectRepository. Every operation holds the project
+// repository's lock, which makes it as atomic as the Postgres row locks.
+type InMemoryReservationRepository struct {
+	projects     *InMemoryProjectRepository
+	reservations map[string]models.Reservation
+	now          func() time.Time
+}
+
+func NewInMemoryReservationRepository(projects *InMemoryProjectRepository) *InMemoryReservationRepository {
+	return &InMemoryReservationRepository{
+		projects:     projects,
+		reservations: make(map[string]models.Reservation),
+		now:          time.Now,
+	}
+}
+
+// Reserve holds tonnes of a published project, and of the vintage when
+// vintageID is set
+func (r *InMemoryReservationRepository) Reserve(projectID, vintageID uint, tonnes float64, reference string, ttl time.Duration) (*models.Reservation, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok || project.Status != models.StatusPublished {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if reference != "" {
+		for _, existing := range r.reservations {
+			if existing.Reference == reference && (existing.Status == models.ReservationHeld || existing.Status == models.ReservationCommitted) {
+				return &existing, nil
+			}
+		}
+	}
+	if project.AvailableCapacity < tonnes {
+		return nil, ErrInsufficientCapacity
+	}
+	if vintageID != 0 {
+		i := vintageIndex(project.Vintages, vintageID)
+		if i < 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		if project.Vintages[i].AvailableCapacity < tonnes {
+			return nil, ErrInsufficientCapacity
+		}
+	}
+
+	now := r.now()
+	reservation := models.Reservation{
+		ID:        newUUID(),
+		ProjectID: projectID,
+		VintageID: vintageID,
+		Tonnes:    tonnes,
+		Reference: reference,
+		Status:    models.ReservationHeld,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.reservations[reservation.ID] = reservation
+	r.adjust(&reservation, models.LedgerReserve, -tonnes, now)
+	return &reservation, nil
+}
+
+// Get retrieves a reservation by ID
+func (r *InMemoryReservationRepository) Get(id string) (*models.Reservation, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	reservation, ok := r.reservations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &reservation, nil
+}
+
+// Commit makes a held reservation permanent. A reservation past its expiry
+// is expired instead.
+func (r *InMemoryReservationRepository) Commit(id string) (*models.Reservation, error) {
+	reservations, err := r.CommitAll([]string{id})
+	if len(reservations) == 0 {
+		return nil, err
+	}
+	return &reservations[0], err
+}
+
+// CommitAll makes held reservations permanent together. When one was
+// released, none is committed; when some are past their expiry, those are
+// expired and returned instead, and none is committed.
+func (r *InMemoryReservationRepository) CommitAll(ids []string) ([]models.Reservation, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	now := r.now()
+	var due []models.Reservation
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		reservation, ok := r.reservations[id]
+		switch {
+		case seen[id]:
+		case !ok:
+			return nil, gorm.ErrRecordNotFound
+		case reservation.Status == models.ReservationCommitted:
+		case reservation.Status != models.ReservationHeld:
+			return nil, ErrReservationNotHeld
+		case !now.Before(reservation.ExpiresAt):
+			due = append(due, reservation)
+		}
+		seen[id] = true
+	}
+	if len(due) > 0 {
+		for i := range due {
+			r.restore(&due[i], models.ReservationExpired, models.LedgerExpire, now)
+		}
+		return due, ErrReservationExpired
+	}
+
+	reservations := make([]models.Reservation, 0, len(ids))
+	for _, id := range ids {
+		reservation := r.reservations[id]
+		if reservation.Status == models.ReservationHeld {
+			reservation.Status = models.ReservationCommitted
+			reservation.CommittedAt = &now
+			reservation.UpdatedAt = now
+			r.reservations[id] = reservation
+			r.adjust(&reservation, models.LedgerCommit, 0, now)
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, nil
+}
+
+// Release returns a held reservation's tonnes to the project
+func (r *InMemoryReservationRepository) Release(id string) (*models.Reservation, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	reservation, ok := r.reservations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	switch reservation.Status {
+	case models.ReservationReleased, models.ReservationExpired:
+		return &reservation, nil
+	case models.ReservationCommitted:
+		return nil, ErrReservationNotHeld
+	}
+	r.restore(&reservation, models.ReservationReleased, models.LedgerRelease, r.now())
+	return &reservation, nil
+}
+
+// ExpireDue expires held reservations past their expiry
+func (r *InMemoryReservationRepository) ExpireDue(now time.Time) ([]models.Reservation, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	var due []models.Reservation
+	for _, reservation := range r.reservations {
+		if reservation.Status == models.ReservationHeld && !now.Before(reservation.ExpiresAt) {
+			due = append(due, reservation)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ExpiresAt.Before(due[j].ExpiresAt) })
+
+	for i := range due {
+		r.restore(&due[i], models.ReservationExpired, models.LedgerExpire, now)
+	}
+	return due, nil
+}
+
+// Ledger returns a page of a project's ledger entries, newest first
+func (r *InMemoryReservationRepository) Ledger(projectID uint, limit, offset int) ([]models.CapacityLedgerEntry, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	entries := []models.CapacityLedgerEntry{}
+	ledger := r.projects.ledger
+	for i := len(ledger) - 1; i >= 0; i-- {
+		if ledger[i].ProjectID == projectID {
+			entries = append(entries, ledger[i])
+		}
+	}
+	entries, _ = pageOf(entries, offset, limit)
+	return entries, nil
+}
+
+// Sales returns a page of a project's committed reservations, newest first
+func (r *InMemoryReservationRepository) Sales(projectID uint, limit, offset int) (*models.ProjectSales, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	sales := &models.ProjectSales{ProjectID: projectID}
+	committed := []models.Reservation{}
+	for _, reservation := range r.reservations {
+		if reservation.ProjectID == projectID && reservation.Status == models.ReservationCommitted {
+			committed = append(committed, reservation)
+			sales.TonnesSold += reservation.Tonnes
+		}
+	}
+	sort.Slice(committed, func(i, j int) bool {
+		if !committed[i].CommittedAt.Equal(*committed[j].CommittedAt) {
+			return committed[i].CommittedAt.After(*committed[j].CommittedAt)
+		}
+		return committed[i].ID < committed[j].ID
+	})
+	sales.SaleCount = int64(len(committed))
+	sales.Sales, _ = pageOf(committed, offset, limit)
+	return sales, nil
+}
+
+// restore returns a held reservation's tonnes to its project, and its
+// vintage if it has one, and moves it to the released or expired status
+func (r *InMemoryReservationRepository) restore(reservation *models.Reservation, status, event string, now time.Time) {
+	reservation.Status = status
+	reservation.ReleasedAt = &now
+	reservation.UpdatedAt = now
+	r.reservations[reservation.ID] = *reservation
+	r.adjust(reservation, event, reservation.Tonnes, now)
+}
+
+// adjust moves the available capacity of a reservation's project, and its
+// vintage if it has one, by delta and records it in the ledger. The caller
+// holds the lock.
+func (r *InMemoryReservationRepository) adjust(reservation *models.Reservation, event string, delta float64, now time.Time) {
+	project := r.projects.projects[reservation.ProjectID]
+	if delta != 0 {
+		changes := map[string]models.FieldChange{"available_capacity": {From: project.AvailableCapacity, To: project.AvailableCapacity + delta}}
+		project.AvailableCapacity += delta
+		if i := vintageIndex(project.Vintages, reservation.VintageID); i >= 0 {
+			// A fresh slice, so projects already handed out keep their vintages
+			project.Vintages = append([]models.Vintage{}, project.Vintages...)
+			project.Vintages[i].AvailableCapacity += delta
+		}
+		project.UpdatedAt = now
+		r.projects.projects[project.ID] = project
+		r.projects.recordEvent(models.EventProjectCapacityChanged, &project, changes, now)
+		r.projects.search.Index(&project)
+	}
+
+	r.projects.recordLedgerEntry(ledgerEntry(reservation, event, delta, project.AvailableCapacity), now)
+}
@@ -0,0 +1,312 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// newReservationRepo returns a reservation repository over one active
+// project with 100 available tonnes, with a clock the test can move
+func newReservationRepo(t *testing.T) (*InMemoryReservationRepository, *InMemoryProjectRepository, *time.Time) {
+	t.Helper()
+	projects := NewInMemoryProjectRepository()
+	if err := projects.Create(&models.Project{Title: "Mangrove Restoration", Status: models.StatusPublished, TotalCapacity: 100, AvailableCapacity: 100}, models.Change{}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := NewInMemoryReservationRepository(projects)
+	repo.now = func() time.Time { return now }
+	return repo, projects, &now
+}
+
+func availableOf(t *testing.T, projects *InMemoryProjectRepository) float64 {
+	t.Helper()
+	project, err := projects.GetByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project.AvailableCapacity
+}
+
+func TestReserveTakesCapacity(t *testing.T) {
+	repo, projects, _ := newReservationRepo(t)
+
+	reservation, err := repo.Reserve(1, 0, 30, "order-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reservation.Status != models.ReservationHeld || len(reservation.ID) != 36 {
+		t.Errorf("expected a held reservation with a UUID, got %+v", reservation)
+	}
+	if got := availableOf(t, projects); got != 70 {
+		t.Errorf("expected 70 tonnes left, got %v", got)
+	}
+
+	if _, err := repo.Reserve(1, 0, 71, "", time.Minute); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("expected insufficient capacity, got %v", err)
+	}
+	if _, err := repo.Reserve(2, 0, 1, "", time.Minute); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing project, got %v", err)
+	}
+	if got := availableOf(t, projects); got != 70 {
+		t.Errorf("expected failed reservations to leave 70 tonnes, got %v", got)
+	}
+}
+
+func TestReserveTakesVintageCapacity(t *testing.T) {
+	repo, projects, now := newReservationRepo(t)
+	vintage := &models.Vintage{Year: 2024, PricePerTonne: 12, TotalCapacity: 40, AvailableCapacity: 40}
+	if err := NewInMemoryVintageRepository(projects).Create(1, vintage); err != nil {
+		t.Fatal(err)
+	}
+	vintageAvailable := func() float64 {
+		t.Helper()
+		project, err := projects.GetByID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return project.Vintages[0].AvailableCapacity
+	}
+
+	released, err := repo.Reserve(1, vintage.ID, 30, "order-1", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.VintageID != vintage.ID {
+		t.Errorf("expected the reservation to hold vintage %d, got %d", vintage.ID, released.VintageID)
+	}
+	if _, err := repo.Reserve(1, vintage.ID, 11, "order-2", time.Minute); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("expected reserving more than the vintage holds to fail, got %v", err)
+	}
+	if _, err := repo.Reserve(1, vintage.ID+1, 1, "order-3", time.Minute); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing vintage, got %v", err)
+	}
+	if got, vintageGot := availableOf(t, projects), vintageAvailable(); got != 70 || vintageGot != 10 {
+		t.Errorf("expected 70 project and 10 vintage tonnes left, got %v and %v", got, vintageGot)
+	}
+
+	repo.Reserve(1, vintage.ID, 10, "order-4", time.Minute)
+	repo.Release(released.ID)
+	*now = now.Add(5 * time.Minute)
+	repo.ExpireDue(*now)
+	if got, vintageGot := availableOf(t, projects), vintageAvailable(); got != 100 || vintageGot != 40 {
+		t.Errorf("expected released and expired tonnes returned to both, got %v and %v", got, vintageGot)
+	}
+
+	entries, _ := repo.Ledger(1, 10, 0)
+	for _, entry := range entries {
+		if entry.VintageID != vintage.ID {
+			t.Errorf("expected every ledger entry to carry the vintage, got %+v", entry)
+		}
+	}
+}
+
+func TestCommitAndRelease(t *testing.T) {
+	repo, projects, _ := newReservationRepo(t)
+	committed, _ := repo.Reserve(1, 0, 30, "", time.Minute)
+	released, _ := repo.Reserve(1, 0, 20, "", time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if r, err := repo.Commit(committed.ID); err != nil || r.Status != models.ReservationCommitted {
+			t.Errorf("commit %d: expected committed, got %+v, %v", i+1, r, err)
+		}
+		if r, err := repo.Release(released.ID); err != nil || r.Status != models.ReservationReleased {
+			t.Errorf("release %d: expected released, got %+v, %v", i+1, r, err)
+		}
+	}
+	if got := availableOf(t, projects); got != 70 {
+		t.Errorf("expected only the committed tonnes taken, got %v left", got)
+	}
+
+	if _, err := repo.Release(committed.ID); !errors.Is(err, ErrReservationNotHeld) {
+		t.Errorf("expected releasing a committed reservation to fail, got %v", err)
+	}
+	if _, err := repo.Commit(released.ID); !errors.Is(err, ErrReservationNotHeld) {
+		t.Errorf("expected committing a released reservation to fail, got %v", err)
+	}
+	if _, err := repo.Commit("missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing reservation, got %v", err)
+	}
+}
+
+func TestReserveIsIdempotentPerReference(t *testing.T) {
+	repo, projects, _ := newReservationRepo(t)
+
+	first, _ := repo.Reserve(1, 0, 30, "order-1", time.Minute)
+	again, err := repo.Reserve(1, 0, 30, "order-1", time.Minute)
+	if err != nil || again.ID != first.ID {
+		t.Fatalf("expected the held reservation back, got %+v, %v", again, err)
+	}
+	repo.Commit(first.ID)
+	if again, _ := repo.Reserve(1, 0, 30, "order-1", time.Minute); again.ID != first.ID || again.Status != models.ReservationCommitted {
+		t.Errorf("expected the committed reservation back, got %+v", again)
+	}
+	if got := availableOf(t, projects); got != 70 {
+		t.Errorf("expected the reference to hold its tonnes once, got %v left", got)
+	}
+
+	released, _ := repo.Reserve(1, 0, 20, "order-2", time.Minute)
+	repo.Release(released.ID)
+	if again, _ := repo.Reserve(1, 0, 20, "order-2", time.Minute); again.ID == released.ID || again.Status != models.ReservationHeld {
+		t.Errorf("expected a new reservation for a released reference, got %+v", again)
+	}
+}
+
+func TestCommitAllCommitsAllOrNone(t *testing.T) {
+	repo, projects, now := newReservationRepo(t)
+	first, _ := repo.Reserve(1, 0, 30, "order-1", time.Minute)
+	second, _ := repo.Reserve(1, 0, 20, "order-2", time.Hour)
+	released, _ := repo.Reserve(1, 0, 10, "order-3", time.Hour)
+	repo.Release(released.ID)
+
+	if _, err := repo.CommitAll([]string{second.ID, released.ID}); !errors.Is(err, ErrReservationNotHeld) {
+		t.Errorf("expected a released reservation to fail the commit, got %v", err)
+	}
+	if r, _ := repo.Get(second.ID); r.Status != models.ReservationHeld {
+		t.Errorf("expected nothing committed, got %s", r.Status)
+	}
+
+	*now = now.Add(5 * time.Minute)
+	expired, err := repo.CommitAll([]string{first.ID, second.ID})
+	if !errors.Is(err, ErrReservationExpired) || len(expired) != 1 || expired[0].ID != first.ID {
+		t.Errorf("expected only the reservation past its expiry expired, got %+v, %v", expired, err)
+	}
+	if r, _ := repo.Get(second.ID); r.Status != models.ReservationHeld {
+		t.Errorf("expected nothing committed, got %s", r.Status)
+	}
+
+	again, _ := repo.Reserve(1, 0, 30, "order-1", time.Hour)
+	for i := 0; i < 2; i++ {
+		committed, err := repo.CommitAll([]string{again.ID, second.ID})
+		if err != nil || len(committed) != 2 || committed[0].Status != models.ReservationCommitted || committed[1].Status != models.ReservationCommitted {
+			t.Errorf("commit %d: expected both committed, got %+v, %v", i+1, committed, err)
+		}
+	}
+	if got := availableOf(t, projects); got != 50 {
+		t.Errorf("expected the committed tonnes taken, got %v left", got)
+	}
+}
+
+func TestReservationsExpire(t *testing.T) {
+	repo, projects, now := newReservationRepo(t)
+	late, _ := repo.Reserve(1, 0, 10, "", time.Minute)
+	swept, _ := repo.Reserve(1, 0, 20, "", 2*time.Minute)
+	kept, _ := repo.Reserve(1, 0, 30, "", time.Hour)
+
+	*now = now.Add(5 * time.Minute)
+
+	if r, err := repo.Commit(late.ID); !errors.Is(err, ErrReservationExpired) || r.Status != models.ReservationExpired {
+		t.Errorf("expected committing past expiry to expire, got %+v, %v", r, err)
+	}
+
+	expired, err := repo.ExpireDue(*now)
+	if err != nil || len(expired) != 1 || expired[0].ID != swept.ID {
+		t.Errorf("expected only the due reservation expired, got %+v, %v", expired, err)
+	}
+	if r, _ := repo.Get(kept.ID); r.Status != models.ReservationHeld {
+		t.Errorf("expected the reservation not yet due to be held, got %s", r.Status)
+	}
+	if r, err := repo.Release(swept.ID); err != nil || r.Status != models.ReservationExpired {
+		t.Errorf("expected releasing an expired reservation to be a no-op, got %+v, %v", r, err)
+	}
+	if got := availableOf(t, projects); got != 70 {
+		t.Errorf("expected the expired tonnes returned, got %v left", got)
+	}
+}
+
+func TestLedgerNewestFirst(t *testing.T) {
+	repo, _, _ := newReservationRepo(t)
+	first, _ := repo.Reserve(1, 0, 30, "", time.Minute)
+	second, _ := repo.Reserve(1, 0, 20, "", time.Minute)
+	repo.Commit(first.ID)
+	repo.Release(second.ID)
+
+	entries, err := repo.Ledger(1, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		event          string
+		delta, balance float64
+	}{
+		{models.LedgerRelease, 20, 70},
+		{models.LedgerCommit, 0, 50},
+		{models.LedgerReserve, -20, 50},
+		{models.LedgerReserve, -30, 70},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), entries)
+	}
+	for i, w := range want {
+		if e := entries[i]; e.Event != w.event || e.Delta != w.delta || e.AvailableAfter != w.balance {
+			t.Errorf("entry %d: expected %+v, got %+v", i, w, e)
+		}
+	}
+
+	if page, _ := repo.Ledger(1, 2, 3); len(page) != 1 || page[0].Event != models.LedgerReserve {
+		t.Errorf("expected the last entry on the second page, got %+v", page)
+	}
+}
+
+func TestSalesAreCommittedReservations(t *testing.T) {
+	repo, _, now := newReservationRepo(t)
+	first, _ := repo.Reserve(1, 0, 30, "order-1", time.Minute)
+	second, _ := repo.Reserve(1, 0, 20, "order-2", time.Minute)
+	released, _ := repo.Reserve(1, 0, 10, "order-3", time.Minute)
+	repo.Reserve(1, 0, 5, "order-4", time.Minute)
+	repo.Commit(first.ID)
+	*now = now.Add(time.Second)
+	repo.Commit(second.ID)
+	repo.Release(released.ID)
+
+	sales, err := repo.Sales(1, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sales.TonnesSold != 50 || sales.SaleCount != 2 || len(sales.Sales) != 2 {
+		t.Fatalf("expected the two committed reservations as sales, got %+v", sales)
+	}
+	if sales.Sales[0].ID != second.ID || sales.Sales[1].ID != first.ID {
+		t.Errorf("expected the newest sale first, got %+v", sales.Sales)
+	}
+
+	if page, _ := repo.Sales(1, 1, 1); len(page.Sales) != 1 || page.Sales[0].ID != first.ID || page.TonnesSold != 50 {
+		t.Errorf("expected the oldest sale on the second page with the totals of all, got %+v", page)
+	}
+	if other, _ := repo.Sales(2, 10, 0); other.SaleCount != 0 || len(other.Sales) != 0 {
+		t.Errorf("expected no sales of another project, got %+v", other)
+	}
+}
+
+func TestConcurrentReservationsNeverOversell(t *testing.T) {
+	repo, projects, _ := newReservationRepo(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	held := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.Reserve(1, 0, 7, "", time.Minute); err == nil {
+				mu.Lock()
+				held++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if held != 14 {
+		t.Errorf("expected 14 reservations of 7 tonnes to fit in 100, got %d", held)
+	}
+	if got := availableOf(t, projects); got != 2 {
+		t.Errorf("expected 2 tonnes left, got %v", got)
+	}
+}
@@ -0,0 +1,175 @@
+package repositories
+
+import (
+	"math"
+	"project_service/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevisionMigrations make project revisions immutable: the database
+// refuses to change or delete one once it is written
+var RevisionMigrations = []string{
+	`CREATE OR REPLACE FUNCTION project_revisions_immutable() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'project revisions are immutable';
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS project_revisions_immutable ON project_revisions`,
+	`CREATE TRIGGER project_revisions_immutable BEFORE UPDATE OR DELETE ON project_revisions
+	FOR EACH ROW EXECUTE FUNCTION project_revisions_immutable()`,
+}
+
+// Revisions returns a page of a project's revisions, newest first
+func (r *PostgresProjectRepository) Revisions(id uint, limit, offset int) ([]models.ProjectRevision, error) {
+	if err := projectExists(r.db, id); err != nil {
+		return nil, err
+	}
+	revisions := []models.ProjectRevision{}
+	if err := r.db.Where("project_id = ?", id).
+		Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// RevisionAt returns the latest revision of a project made at or before a
+// moment
+func (r *PostgresProjectRepository) RevisionAt(id uint, at time.Time) (*models.ProjectRevision, error) {
+	if err := projectExists(r.db, id); err != nil {
+		return nil, err
+	}
+	var revision models.ProjectRevision
+	if err := r.db.Where("project_id = ? AND created_at <= ?", id, at).
+		Order("created_at DESC, id DESC").First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Revert restores a project's fields to a revision. A revert that changes
+// nothing records no revision. The available capacity is not restored, so
+// tonnes sold or held since stay out of it; any shrinking of it to fit the
+// restored capacity goes in the capacity ledger.
+func (r *PostgresProjectRepository) Revert(id, revisionID uint, change models.Change) (*models.Project, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var before models.Project
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, id).Error; err != nil {
+			return err
+		}
+		var target models.ProjectRevision
+		if err := tx.Where("id = ? AND project_id = ?", revisionID, id).First(&target).Error; err != nil {
+			return err
+		}
+
+		taken, err := takenCapacity(tx, id)
+		if err != nil {
+			return err
+		}
+		reverted := before
+		target.Snapshot.Restore(&reverted)
+		if err := revertCapacity(&reverted, before.AvailableCapacity, before.BufferCapacity, taken); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		columns := target.Snapshot.Revertible()
+		columns["available_capacity"] = reverted.AvailableCapacity
+		columns["buffer_capacity"] = reverted.BufferCapacity
+		columns["updated_at"] = now
+		if err := tx.Model(&models.Project{}).Where("id = ?", id).Updates(columns).Error; err != nil {
+			return err
+		}
+		reverted.UpdatedAt = now
+		if delta := reverted.AvailableCapacity - before.AvailableCapacity; delta != 0 {
+			if err := tx.Create(revertLedgerEntry(id, delta, reverted.AvailableCapacity)).Error; err != nil {
+				return err
+			}
+		}
+
+		if reverted.PricePerTonne != before.PricePerTonne {
+			if err := recordPrice(tx, &reverted, models.PriceChangeUpdate, nil, now); err != nil {
+				return err
+			}
+		}
+		fields, after := models.FieldsOf(&before), models.FieldsOf(&reverted)
+		if err := recordRevision(tx, revertRevision(id, revisionID, &fields, after, change, now)); err != nil {
+			return err
+		}
+		return recordEvents(tx, id, &fields, after, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// revertCapacity sets the capacities of a project whose fields were just
+// restored from a snapshot, given what it had available and withheld before
+// and the tonnes its reservations sold or hold. The available capacity is
+// not restored with the rest, as that would put sold and held tonnes back
+// on sale; it only shrinks to what the restored capacity leaves. A snapshot
+// with less sellable capacity than is sold or held is ErrCapacityTaken.
+func revertCapacity(project *models.Project, available, withheldBefore, taken float64) error {
+	project.AvailableCapacity = available
+	project.WithholdBuffer(withheldBefore, false)
+	left := project.SellableCapacity() - taken
+	if left < 0 {
+		return ErrCapacityTaken
+	}
+	project.AvailableCapacity = math.Min(project.AvailableCapacity, left)
+	return nil
+}
+
+// takenCapacity is the tonnes of a project its held and committed
+// reservations take
+func takenCapacity(tx *gorm.DB, id uint) (float64, error) {
+	var taken float64
+	err := tx.Model(&models.Reservation{}).
+		Where("project_id = ? AND status IN ?", id, []string{models.ReservationHeld, models.ReservationCommitted}).
+		Select("COALESCE(SUM(tonnes), 0)").Row().Scan(&taken)
+	return taken, err
+}
+
+// revertLedgerEntry is the ledger entry of a revert that moved a project's
+// available capacity
+func revertLedgerEntry(id uint, delta, availableAfter float64) *models.CapacityLedgerEntry {
+	return &models.CapacityLedgerEntry{
+		ProjectID:      id,
+		Event:          models.LedgerRevert,
+		Delta:          delta,
+		AvailableAfter: availableAfter,
+	}
+}
+
+// recordRevision writes a revision, if there is one
+func recordRevision(tx *gorm.DB, revision *models.ProjectRevision) error {
+	if revision == nil {
+		return nil
+	}
+	return tx.Create(revision).Error
+}
+
+// transitionRevision is the revision of a lifecycle transition from the
+// project's current status. Retiring a project is its deletion.
+func transitionRevision(project *models.Project, transition models.Transition, actor models.Actor, reason string, at time.Time) *models.ProjectRevision {
+	operation := models.RevisionStatus
+	if transition.Action == models.ActionRetire {
+		operation = models.RevisionDelete
+	}
+	before := models.FieldsOf(project)
+	after := before
+	after.Status = transition.To
+	return models.NewRevision(project.ID, operation, &before, after, models.Change{Actor: actor, Reason: reason}, at)
+}
+
+// revertRevision is the revision of a revert to revisionID
+func revertRevision(id, revisionID uint, before *models.ProjectFields, after models.ProjectFields, change models.Change, at time.Time) *models.ProjectRevision {
+	revision := models.NewRevision(id, models.RevisionRevert, before, after, change, at)
+	if revision != nil {
+		revision.RevertedTo = &revisionID
+	}
+	return revision
+}
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"project_service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Revisions returns a page of a project's revisions, newest first
+func (r *InMemoryProjectRepository) Revisions(id uint, limit, offset int) ([]models.ProjectRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.projects[id]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	revisions := []models.ProjectRevision{}
+	for i := len(r.revisions) - 1; i >= 0; i-- {
+		if r.revisions[i].ProjectID == id {
+			revisions = append(revisions, r.revisions[i])
+		}
+	}
+	if offset > len(revisions) {
+		offset = len(revisions)
+	}
+	revisions = revisions[offset:]
+	if len(revisions) > limit {
+		revisions = revisions[:limit]
+	}
+	return revisions, nil
+}
+
+// RevisionAt returns the latest revision of a project made at or before a
+// moment
+func (r *InMemoryProjectRepository) RevisionAt(id uint, at time.Time) (*models.ProjectRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.projects[id]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	for i := len(r.revisions) - 1; i >= 0; i-- {
+		if revision := r.revisions[i]; revision.ProjectID == id && !revision.CreatedAt.After(at) {
+			return &revision, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Revert restores a project's fields to a revision
+func (r *InMemoryProjectRepository) Revert(id, revisionID uint, change models.Change) (*models.Project, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	project, ok := r.projects[id]
+	if !ok || revisionID == 0 || int(revisionID) > len(r.revisions) || r.revisions[revisionID-1].ProjectID != id {
+		return nil, gorm.ErrRecordNotFound
+	}
+	target := r.revisions[revisionID-1].Snapshot
+
+	before := models.FieldsOf(&project)
+	reverted := project
+	target.Restore(&reverted)
+	if err := revertCapacity(&reverted, project.AvailableCapacity, project.BufferCapacity, r.takenCapacity(id)); err != nil {
+		return nil, err
+	}
+	project = reverted
+	project.UpdatedAt = time.Now()
+
+	r.projects[id] = project
+	if delta := project.AvailableCapacity - before.AvailableCapacity; delta != 0 {
+		r.recordLedgerEntry(revertLedgerEntry(id, delta, project.AvailableCapacity), project.UpdatedAt)
+	}
+	if project.PricePerTonne != before.PricePerTonne {
+		r.recordPrice(&project, models.PriceChangeUpdate, nil, project.UpdatedAt)
+	}
+	r.recordRevision(revertRevision(id, revisionID, &before, models.FieldsOf(&project), change, project.UpdatedAt))
+	r.recordEvents(id, &before, models.FieldsOf(&project), project.UpdatedAt)
+	return &project, r.search.Index(&project)
+}
+
+// takenCapacity is the tonnes of a project its held and committed
+// reservations take: what their ledger entries took out of its available
+// capacity and did not put back. The caller holds the lock.
+func (r *InMemoryProjectRepository) takenCapacity(id uint) float64 {
+	var taken float64
+	for _, entry := range r.ledger {
+		if entry.ProjectID == id && entry.ReservationID != "" {
+			taken -= entry.Delta
+		}
+	}
+	return taken
+}
+
+// recordLedgerEntry adds an entry to the capacity ledger. The caller holds
+// the lock.
+func (r *InMemoryProjectRepository) recordLedgerEntry(entry *models.CapacityLedgerEntry, at time.Time) {
+	entry.ID = uint(len(r.ledger) + 1)
+	entry.CreatedAt = at
+	r.ledger = append(r.ledger, *entry)
+}
+
+// recordRevision adds a revision, if there is one. The caller holds the
+// lock.
+func (r *InMemoryProjectRepository) recordRevision(revision *models.ProjectRevision) {
+	if revision == nil {
+		return
+	}
+	revision.ID = uint(len(r.revisions) + 1)
+	r.revisions = append(r.revisions, *revision)
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestRevisionsRecordEveryWrite(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	project := &models.Project{Title: "Mangrove Restoration", Category: "forestry", PricePerTonne: 10}
+	if err := repo.Create(project, models.Change{Actor: author}); err != nil {
+		t.Fatal(err)
+	}
+	repo.Update(1, &models.UpdateProjectRequest{PricePerTonne: 12, Title: "Mangrove Restoration"}, models.Change{Actor: reviewer, Reason: "registry price"})
+	// An update that changes nothing is not a revision
+	repo.Update(1, &models.UpdateProjectRequest{PricePerTonne: 12}, models.Change{Actor: reviewer})
+	retire(t, repo, 1)
+
+	revisions, err := repo.Revisions(1, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %+v", revisions)
+	}
+	deleted, updated, created := revisions[0], revisions[1], revisions[2]
+	if created.Operation != models.RevisionCreate || created.ActorID != author.ID || created.Changes["title"].To != "Mangrove Restoration" {
+		t.Errorf("unexpected create revision: %+v", created)
+	}
+	if updated.Operation != models.RevisionUpdate || updated.ActorID != reviewer.ID || updated.Reason != "registry price" ||
+		len(updated.Changes) != 1 || updated.Changes["price_per_tonne"] != (models.FieldChange{From: 10.0, To: 12.0}) {
+		t.Errorf("expected only the price in the update's diff, got %+v", updated)
+	}
+	if deleted.Operation != models.RevisionDelete || deleted.Changes["status"].To != models.StatusRetired || deleted.Snapshot.PricePerTonne != 12 {
+		t.Errorf("unexpected delete revision: %+v", deleted)
+	}
+
+	if page, _ := repo.Revisions(1, 1, 1); len(page) != 1 || page[0].ID != updated.ID {
+		t.Errorf("expected the second newest revision, got %+v", page)
+	}
+	if _, err := repo.Revisions(9, 10, 0); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing project not found, got %v", err)
+	}
+}
+
+func TestRevisionAtAndRevert(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	repo.Create(&models.Project{Title: "Mangrove Restoration", PricePerTonne: 10, TotalCapacity: 100}, models.Change{Actor: author})
+	before := time.Now()
+	time.Sleep(time.Millisecond)
+	repo.Update(1, &models.UpdateProjectRequest{PricePerTonne: 25, TotalCapacity: 500}, models.Change{Actor: author})
+
+	revision, err := repo.RevisionAt(1, before)
+	if err != nil || revision.Snapshot.PricePerTonne != 10 {
+		t.Fatalf("expected the created project, got %+v %v", revision, err)
+	}
+	if _, err := repo.RevisionAt(1, before.Add(-time.Hour)); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected no revision before the project existed, got %v", err)
+	}
+
+	project, err := repo.Revert(1, revision.ID, models.Change{Actor: reviewer, Reason: "price typo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.PricePerTonne != 10 || project.TotalCapacity != 100 || project.Status != models.StatusDraft {
+		t.Errorf("expected the first revision's fields back, got %+v", project)
+	}
+	latest, _ := repo.RevisionAt(1, time.Now())
+	if latest.Operation != models.RevisionRevert || latest.RevertedTo == nil || *latest.RevertedTo != revision.ID ||
+		latest.Changes["price_per_tonne"] != (models.FieldChange{From: 25.0, To: 10.0}) {
+		t.Errorf("unexpected revert revision: %+v", latest)
+	}
+
+	repo.Create(&models.Project{Title: "Wind Farm"}, models.Change{})
+	if _, err := repo.Revert(2, revision.ID, models.Change{}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected another project's revision not found, got %v", err)
+	}
+}
+
+func TestRevertKeepsSoldAndHeldTonnesOut(t *testing.T) {
+	repo := NewInMemoryProjectRepository()
+	repo.Create(&models.Project{Title: "Mangrove Restoration", Status: models.StatusPublished, PricePerTonne: 10, TotalCapacity: 100, AvailableCapacity: 100}, models.Change{Actor: author})
+	created, _ := repo.RevisionAt(1, time.Now())
+	repo.Update(1, &models.UpdateProjectRequest{TotalCapacity: 500, AvailableCapacity: 500}, models.Change{Actor: author})
+	grown, _ := repo.RevisionAt(1, time.Now())
+
+	reservations := NewInMemoryReservationRepository(repo)
+	sold, _ := reservations.Reserve(1, 0, 60, "order-1", time.Hour)
+	reservations.Commit(sold.ID)
+	reservations.Reserve(1, 0, 20, "order-2", time.Hour)
+
+	// Back to 100 tonnes, of which 80 are sold or held
+	project, err := repo.Revert(1, created.ID, models.Change{Actor: reviewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.TotalCapacity != 100 || project.AvailableCapacity != 20 {
+		t.Errorf("expected 20 of 100 tonnes left available, got %v of %v", project.AvailableCapacity, project.TotalCapacity)
+	}
+	entries, _ := reservations.Ledger(1, 10, 0)
+	if len(entries) == 0 || entries[0].Event != models.LedgerRevert || entries[0].Delta != -400 || entries[0].AvailableAfter != 20 {
+		t.Errorf("expected the revert in the ledger, got %+v", entries)
+	}
+
+	// Growing the capacity back does not put the sold tonnes back on sale
+	if project, _ = repo.Revert(1, grown.ID, models.Change{Actor: reviewer}); project.TotalCapacity != 500 || project.AvailableCapacity != 20 {
+		t.Errorf("expected the available capacity kept, got %v of %v", project.AvailableCapacity, project.TotalCapacity)
+	}
+
+	repo.Update(1, &models.UpdateProjectRequest{TotalCapacity: 50}, models.Change{Actor: author})
+	shrunk, _ := repo.RevisionAt(1, time.Now())
+	repo.Revert(1, grown.ID, models.Change{Actor: reviewer})
+	if _, err := repo.Revert(1, shrunk.ID, models.Change{Actor: reviewer}); !errors.Is(err, ErrCapacityTaken) {
+		t.Errorf("expected a revert below the sold and held tonnes to fail, got %v", err)
+	}
+}
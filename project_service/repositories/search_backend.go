@@ -0,0 +1,13 @@
+package repositories
+
+import "project_service/models"
+
+// SearchBackend answers project searches. Only published projects are
+// searchable: the repository indexes a project when it is written as published
+// and removes it otherwise. Backends that read the projects table directly
+// can treat Index and Remove as no-ops.
+type SearchBackend interface {
+	Search(searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error)
+	Index(project *models.Project) error
+	Remove(id uint) error
+}
@@ -0,0 +1,389 @@
+package repositories
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"project_service/models"
+	"strconv"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// searchFields are the fields matched by the text query, title weighted
+// twice as heavily as the rest
+var searchFields = []string{"title^2", "description", "category", "region", "country", "additionality_notes"}
+
+// esBucket is a terms, histogram or range aggregation bucket. Key is a
+// string for terms and range buckets and a number for histogram and vintage
+// year buckets.
+type esBucket struct {
+	Key      interface{} `json:"key"`
+	DocCount int64       `json:"doc_count"`
+}
+
+// esSearchResponse is the part of an Elasticsearch search response the
+// repository reads
+type esSearchResponse struct {
+	Aggregations map[string]struct {
+		Values struct {
+			Buckets []esBucket `json:"buckets"`
+		} `json:"values"`
+	} `json:"aggregations"`
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []struct {
+			Score     *float64            `json:"_score"`
+			Source    models.Project      `json:"_source"`
+			Highlight map[string][]string `json:"highlight"`
+			Sort      []float64           `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// ElasticsearchSearchBackend searches and maintains the Elasticsearch
+// projects index, writing through the alias the reindexer swaps
+type ElasticsearchSearchBackend struct {
+	es    *elasticsearch.Client
+	index string
+}
+
+func NewElasticsearchSearchBackend(client *elasticsearch.Client) *ElasticsearchSearchBackend {
+	return &ElasticsearchSearchBackend{
+		es:    client,
+		index: "projects",
+	}
+}
+
+// Search runs the search against the project index
+func (b *ElasticsearchSearchBackend) Search(searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error) {
+	name, _, _ := searchSortFor(searchReq.Sort)
+	after, err := decodeCursor(searchReq.Cursor, name)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(buildSearchQuery(searchReq, after))
+	if err != nil {
+		return nil, err
+	}
+
+	req := esapi.SearchRequest{
+		Index: []string{b.index},
+		Body:  bytes.NewReader(body),
+	}
+
+	res, err := req.Do(context.Background(), b.es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("elasticsearch search error: %s", res.String())
+	}
+
+	return parseSearchResponse(res.Body, searchReq)
+}
+
+// buildSearchQuery translates a search request into an Elasticsearch query.
+// Text matches are highlighted and, sorted by relevance, ranked by score;
+// filter-only relevance searches are ordered by ID like the database
+// search. The filters are applied as a post filter so each facet
+// aggregation can leave out its own. A cursor continues with search_after
+// from the sort values of the previous page's last hit.
+func buildSearchQuery(searchReq *models.ProjectSearchRequest, after *cursor) map[string]interface{} {
+	must := []interface{}{}
+	if searchReq.Query != "" {
+		must = append(must, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  searchReq.Query,
+				"fields": searchFields,
+			},
+		})
+	}
+
+	// One extra hit tells whether a next page exists
+	query := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   must,
+				"filter": []interface{}{map[string]interface{}{"term": map[string]interface{}{"status": models.StatusPublished}}},
+			},
+		},
+		"post_filter":      esFilter(searchReq, filterNone),
+		"aggs":             esAggregations(searchReq),
+		"from":             searchReq.Offset,
+		"size":             searchReq.Limit + 1,
+		"track_total_hits": true,
+	}
+
+	byID := map[string]interface{}{"id": "asc"}
+	_, order, byColumn := searchSortFor(searchReq.Sort)
+	switch {
+	case byColumn:
+		direction := "asc"
+		if order.desc {
+			direction = "desc"
+		}
+		query["sort"] = []interface{}{map[string]interface{}{order.column: direction}, byID}
+		query["track_scores"] = searchReq.Query != ""
+	case searchReq.Query != "":
+		query["sort"] = []interface{}{"_score", byID}
+	default:
+		query["sort"] = []interface{}{byID}
+	}
+
+	if after != nil {
+		query["from"] = 0
+		if byColumn || searchReq.Query != "" {
+			query["search_after"] = []interface{}{after.Value, after.ID}
+		} else {
+			query["search_after"] = []interface{}{after.ID}
+		}
+	}
+
+	if searchReq.Query != "" {
+		query["highlight"] = map[string]interface{}{
+			"pre_tags":  []string{"<em>"},
+			"post_tags": []string{"</em>"},
+			"fields": map[string]interface{}{
+				"title":       map[string]interface{}{"number_of_fragments": 0},
+				"description": map[string]interface{}{"fragment_size": 150, "number_of_fragments": 3},
+			},
+		}
+	}
+	return query
+}
+
+// esFilter builds a bool filter of every search filter except one
+func esFilter(searchReq *models.ProjectSearchRequest, except string) map[string]interface{} {
+	filter := []interface{}{}
+
+	terms := []struct {
+		name   string
+		values []string
+	}{
+		{filterCategory, searchReq.Category},
+		{filterRegion, searchReq.Region},
+		{filterCountry, searchReq.Country},
+		{filterStandard, searchReq.VerificationStandard},
+		{filterMethodology, searchReq.MethodologyID},
+		{filterCreditType, searchReq.CreditType},
+		{filterSolution, searchReq.SolutionType},
+	}
+	for _, t := range terms {
+		if t.name != except && len(t.values) > 0 {
+			filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{t.name: t.values}})
+		}
+	}
+
+	if except != filterVintage && len(searchReq.Vintage) > 0 {
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"vintages.year": searchReq.Vintage}})
+	}
+
+	if except != filterPrice && (searchReq.MinPrice > 0 || searchReq.MaxPrice > 0) {
+		priceRange := map[string]interface{}{}
+		if searchReq.MinPrice > 0 {
+			priceRange["gte"] = searchReq.MinPrice
+		}
+		if searchReq.MaxPrice > 0 {
+			priceRange["lte"] = searchReq.MaxPrice
+		}
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{"price_per_tonne": priceRange}})
+	}
+
+	if except != filterSDG && len(searchReq.SDGGoals) > 0 {
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"sdg_goals": searchReq.SDGGoals}})
+	}
+
+	if except != filterPermanence && searchReq.MinPermanenceYears > 0 {
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{
+			"permanence_years": map[string]interface{}{"gte": searchReq.MinPermanenceYears},
+		}})
+	}
+
+	if except != filterBufferPool && searchReq.MaxBufferPoolPercent > 0 {
+		filter = append(filter, map[string]interface{}{"range": map[string]interface{}{
+			"buffer_pool_percent": map[string]interface{}{"lte": searchReq.MaxBufferPoolPercent},
+		}})
+	}
+
+	filter = append(filter, esGeoFilters(searchReq)...)
+
+	return map[string]interface{}{"bool": map[string]interface{}{"filter": filter}}
+}
+
+// esAggregations builds one filter aggregation per facet, each wrapping a
+// "values" aggregation under every filter except the facet's own
+func esAggregations(searchReq *models.ProjectSearchRequest) map[string]interface{} {
+	aggs := map[string]interface{}{}
+	facet := func(name, except string, values map[string]interface{}) {
+		aggs[name] = map[string]interface{}{
+			"filter": esFilter(searchReq, except),
+			"aggs":   map[string]interface{}{"values": values},
+		}
+	}
+
+	// The terms facets are named after their fields. Projects that leave
+	// an optional field empty are not a value of its facet.
+	for _, t := range termFacets {
+		facet(t.filter, t.filter, map[string]interface{}{
+			"terms": map[string]interface{}{"field": t.filter, "size": models.FacetSize, "exclude": []string{""}},
+		})
+	}
+
+	facet(filterVintage, filterVintage, map[string]interface{}{
+		"terms": map[string]interface{}{"field": "vintages.year", "size": models.FacetSize},
+	})
+
+	facet(filterSDG, filterSDG, map[string]interface{}{
+		"terms": map[string]interface{}{"field": "sdg_goals", "size": models.FacetSize},
+	})
+
+	facet("price_per_tonne", filterPrice, map[string]interface{}{
+		"histogram": map[string]interface{}{
+			"field":         "price_per_tonne",
+			"interval":      models.PriceHistogramInterval,
+			"min_doc_count": 0,
+		},
+	})
+
+	for _, r := range rangeFacets {
+		ranges := make([]interface{}, len(r.ranges))
+		for i, bounds := range r.ranges {
+			entry := map[string]interface{}{"key": bounds.Key, "from": bounds.From}
+			if bounds.To != 0 {
+				entry["to"] = bounds.To
+			}
+			ranges[i] = entry
+		}
+		facet(r.field, r.filter, map[string]interface{}{
+			"range": map[string]interface{}{"field": r.field, "ranges": ranges},
+		})
+	}
+
+	return aggs
+}
+
+// parseSearchResponse converts an Elasticsearch search response into a
+// page of hits, the cursor of the next page and the facets
+func parseSearchResponse(body io.Reader, searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error) {
+	var res esSearchResponse
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("error parsing elasticsearch response: %w", err)
+	}
+
+	page, more := pageOf(res.Hits.Hits, 0, searchReq.Limit)
+	hits := make([]models.ProjectHit, len(page))
+	for i, hit := range page {
+		hits[i] = models.ProjectHit{
+			Project:    hit.Source,
+			Score:      hit.Score,
+			Highlights: hit.Highlight,
+		}
+	}
+	facets := models.ProjectFacets{}
+	for _, t := range termFacets {
+		counts := []models.FacetCount{}
+		for _, bucket := range res.Aggregations[t.filter].Values.Buckets {
+			counts = append(counts, models.FacetCount{Value: fmt.Sprint(bucket.Key), Count: bucket.DocCount})
+		}
+		setTermFacet(&facets, t.filter, counts)
+	}
+
+	// Years and goals come back as numbers
+	facets.Vintage = []models.FacetCount{}
+	for _, bucket := range res.Aggregations[filterVintage].Values.Buckets {
+		facets.Vintage = append(facets.Vintage, models.FacetCount{Value: fmt.Sprint(bucket.Key), Count: bucket.DocCount})
+	}
+	facets.SDGGoals = []models.FacetCount{}
+	for _, bucket := range res.Aggregations[filterSDG].Values.Buckets {
+		facets.SDGGoals = append(facets.SDGGoals, models.FacetCount{Value: fmt.Sprint(bucket.Key), Count: bucket.DocCount})
+	}
+
+	prices := map[int64]int64{}
+	for _, bucket := range res.Aggregations["price_per_tonne"].Values.Buckets {
+		if key, ok := bucket.Key.(float64); ok && bucket.DocCount > 0 {
+			prices[priceBucket(key)] = bucket.DocCount
+		}
+	}
+	facets.PricePerTonne = histogram(prices)
+
+	for _, r := range rangeFacets {
+		counts := make([]int64, len(r.ranges))
+		for _, bucket := range res.Aggregations[r.field].Values.Buckets {
+			for i, bounds := range r.ranges {
+				if bucket.Key == bounds.Key {
+					counts[i] = bucket.DocCount
+				}
+			}
+		}
+		setRangeFacet(&facets, r.field, rangeCounts(r.ranges, counts))
+	}
+
+	result := &models.ProjectSearchResult{Hits: hits, Total: res.Hits.Total.Value, Facets: facets}
+	if more {
+		last := page[len(page)-1]
+		name, _, _ := searchSortFor(searchReq.Sort)
+		next := cursor{Sort: name, ID: last.Source.ID}
+		if len(last.Sort) == 2 {
+			next.Value = last.Sort[0]
+		}
+		result.NextCursor = next.encode()
+	}
+	return result, nil
+}
+
+// Index creates or replaces the project document
+func (b *ElasticsearchSearchBackend) Index(project *models.Project) error {
+	doc, err := json.Marshal(NewSearchDocument(project))
+	if err != nil {
+		return err
+	}
+
+	req := esapi.IndexRequest{
+		Index:      b.index,
+		DocumentID: strconv.FormatUint(uint64(project.ID), 10),
+		Body:       bytes.NewReader(doc),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(context.Background(), b.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("error indexing project %d: %s", project.ID, res.String())
+	}
+	return nil
+}
+
+// Remove deletes the project document. A document that is already gone is
+// not an error.
+func (b *ElasticsearchSearchBackend) Remove(id uint) error {
+	req := esapi.DeleteRequest{
+		Index:      b.index,
+		DocumentID: strconv.FormatUint(uint64(id), 10),
+		Refresh:    "true",
+	}
+
+	res, err := req.Do(context.Background(), b.es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("error deleting project %d: %s", id, res.String())
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package repositories
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"project_service/models"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// esRequest is a request received by the fake Elasticsearch server
+type esRequest struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// newFakeElasticsearch starts a server that records requests and answers
+// them with the given status and body
+func newFakeElasticsearch(t *testing.T, status int, response string) (*ElasticsearchSearchBackend, func() []esRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []esRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		req := esRequest{Method: r.Method, Path: r.URL.Path}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &req.Body); err != nil {
+				t.Errorf("request body is not JSON: %s", raw)
+			}
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewElasticsearchSearchBackend(client), func() []esRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]esRequest(nil), requests...)
+	}
+}
+
+func TestElasticsearchSearch(t *testing.T) {
+	backend, requests := newFakeElasticsearch(t, http.StatusOK, `{
+		"hits": {
+			"total": {"value": 42, "relation": "eq"},
+			"hits": [{
+				"_id": "7",
+				"_score": 3.5,
+				"_source": {"id": 7, "title": "Kenya Clean Cookstoves", "category": "energy", "status": "active"},
+				"highlight": {"title": ["Kenya Clean <em>Cookstoves</em>"]}
+			}]
+		}
+	}`)
+
+	result, err := backend.Search(&models.ProjectSearchRequest{
+		Query: "cookstoves", Category: []string{"energy"}, Vintage: []int{2021}, MinPrice: 5, Limit: 10, Offset: 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 42 || len(result.Hits) != 1 {
+		t.Fatalf("expected 1 hit of 42, got %d of %d", len(result.Hits), result.Total)
+	}
+	hit := result.Hits[0]
+	if hit.ID != 7 || hit.Title != "Kenya Clean Cookstoves" || hit.Score == nil || *hit.Score != 3.5 {
+		t.Errorf("unexpected hit: %+v", hit)
+	}
+	if got := hit.Highlights["title"]; len(got) != 1 || got[0] != "Kenya Clean <em>Cookstoves</em>" {
+		t.Errorf("expected the title highlight, got %v", hit.Highlights)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 || reqs[0].Path != "/projects/_search" {
+		t.Fatalf("expected one search request, got %+v", reqs)
+	}
+	body := reqs[0].Body
+	if body["from"] != float64(20) || body["size"] != float64(11) || body["track_total_hits"] != true {
+		t.Errorf("unexpected paging in %v", body)
+	}
+	if _, ok := body["highlight"]; !ok {
+		t.Errorf("expected a highlight clause for a text query: %v", body)
+	}
+	if filters := boolFilters(body["post_filter"]); len(filters) != 3 {
+		t.Errorf("expected category, vintage and price post filters, got %v", filters)
+	}
+
+	aggs := body["aggs"].(map[string]interface{})
+	for name, want := range map[string][]string{
+		"category":           {"terms", "range"},
+		"vintage":            {"terms", "range"},
+		"price_per_tonne":    {"terms", "terms"},
+		"available_capacity": {"terms", "terms", "range"},
+	} {
+		filters := boolFilters(aggs[name].(map[string]interface{})["filter"])
+		kinds := []string{}
+		for _, f := range filters {
+			for kind := range f.(map[string]interface{}) {
+				kinds = append(kinds, kind)
+			}
+		}
+		if !reflect.DeepEqual(kinds, want) {
+			t.Errorf("expected the %s facet to keep the filters %v, got %v", name, want, kinds)
+		}
+	}
+}
+
+func boolFilters(clause interface{}) []interface{} {
+	return clause.(map[string]interface{})["bool"].(map[string]interface{})["filter"].([]interface{})
+}
+
+func TestElasticsearchFacets(t *testing.T) {
+	backend, _ := newFakeElasticsearch(t, http.StatusOK, `{
+		"hits": {"total": {"value": 3}, "hits": []},
+		"aggregations": {
+			"category": {"doc_count": 3, "values": {"buckets": [{"key": "energy", "doc_count": 2}, {"key": "forestry", "doc_count": 1}]}},
+			"region": {"doc_count": 3, "values": {"buckets": []}},
+			"country": {"doc_count": 3, "values": {"buckets": [{"key": "India", "doc_count": 3}]}},
+			"verification_standard": {"doc_count": 3, "values": {"buckets": [{"key": "VCS", "doc_count": 3}]}},
+			"vintage": {"doc_count": 3, "values": {"buckets": [{"key": 2021, "doc_count": 2}]}},
+			"price_per_tonne": {"doc_count": 3, "values": {"buckets": [
+				{"key": 5.0, "doc_count": 1}, {"key": 10.0, "doc_count": 0}, {"key": 15.0, "doc_count": 2}
+			]}},
+			"available_capacity": {"doc_count": 3, "values": {"buckets": [
+				{"key": "under_1k", "from": 0, "to": 1000, "doc_count": 1},
+				{"key": "1k_to_10k", "from": 1000, "to": 10000, "doc_count": 2},
+				{"key": "10k_to_100k", "from": 10000, "to": 100000, "doc_count": 0},
+				{"key": "100k_and_over", "from": 100000, "doc_count": 0}
+			]}}
+		}
+	}`)
+
+	result, err := backend.Search(&models.ProjectSearchRequest{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	facets := result.Facets
+	if len(facets.Category) != 2 || facets.Category[0] != (models.FacetCount{Value: "energy", Count: 2}) {
+		t.Errorf("unexpected category facet: %+v", facets.Category)
+	}
+	if len(facets.Region) != 0 || len(facets.VerificationStandard) != 1 {
+		t.Errorf("unexpected region or standard facet: %+v %+v", facets.Region, facets.VerificationStandard)
+	}
+	want := []models.HistogramBucket{{From: 5, To: 10, Count: 1}, {From: 10, To: 15, Count: 0}, {From: 15, To: 20, Count: 2}}
+	if !reflect.DeepEqual(facets.PricePerTonne, want) {
+		t.Errorf("expected price histogram %+v, got %+v", want, facets.PricePerTonne)
+	}
+	if len(facets.AvailableCapacity) != 4 || facets.AvailableCapacity[1].Count != 2 || facets.AvailableCapacity[3].To != nil {
+		t.Errorf("unexpected capacity ranges: %+v", facets.AvailableCapacity)
+	}
+	if !reflect.DeepEqual(facets.Vintage, []models.FacetCount{{Value: "2021", Count: 2}}) {
+		t.Errorf("expected the vintage year as a string, got %+v", facets.Vintage)
+	}
+}
+
+func TestElasticsearchSearchWithoutQuery(t *testing.T) {
+	backend, requests := newFakeElasticsearch(t, http.StatusOK, `{"hits": {"total": {"value": 0}, "hits": []}}`)
+
+	result, err := backend.Search(&models.ProjectSearchRequest{Region: []string{"Asia"}, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 0 || len(result.Hits) != 0 {
+		t.Errorf("expected no hits, got %+v", result)
+	}
+	if _, ok := requests()[0].Body["highlight"]; ok {
+		t.Error("expected no highlight clause without a text query")
+	}
+}
+
+func TestElasticsearchSortAndCursor(t *testing.T) {
+	backend, requests := newFakeElasticsearch(t, http.StatusOK, `{
+		"hits": {
+			"total": {"value": 5},
+			"hits": [
+				{"_source": {"id": 4, "price_per_tonne": 8}, "sort": [8.0, 4]},
+				{"_source": {"id": 2, "price_per_tonne": 9}, "sort": [9.0, 2]},
+				{"_source": {"id": 6, "price_per_tonne": 9}, "sort": [9.0, 6]}
+			]
+		}
+	}`)
+
+	first := cursor{Sort: models.SortPriceAsc, Value: 5, ID: 1}
+	result, err := backend.Search(&models.ProjectSearchRequest{
+		Sort: models.SortPriceAsc, Cursor: first.encode(), Limit: 2, Offset: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := requests()[0].Body
+	wantSort := []interface{}{map[string]interface{}{"price_per_tonne": "asc"}, map[string]interface{}{"id": "asc"}}
+	if !reflect.DeepEqual(body["sort"], wantSort) {
+		t.Errorf("expected sort %v, got %v", wantSort, body["sort"])
+	}
+	if !reflect.DeepEqual(body["search_after"], []interface{}{float64(5), float64(1)}) || body["from"] != float64(0) {
+		t.Errorf("expected search_after [5 1] from 0, got %v from %v", body["search_after"], body["from"])
+	}
+
+	if len(result.Hits) != 2 || result.Total != 5 {
+		t.Fatalf("expected a page of 2 of 5, got %d of %d", len(result.Hits), result.Total)
+	}
+	next, err := decodeCursor(result.NextCursor, models.SortPriceAsc)
+	if err != nil || *next != (cursor{Sort: models.SortPriceAsc, Value: 9, ID: 2}) {
+		t.Errorf("expected the next cursor at the last hit, got %+v, %v", next, err)
+	}
+
+	if _, err := backend.Search(&models.ProjectSearchRequest{Cursor: first.encode(), Limit: 2}); err != ErrInvalidCursor {
+		t.Errorf("expected a cursor for another sort to be rejected, got %v", err)
+	}
+}
+
+func TestElasticsearchSearchError(t *testing.T) {
+	backend, _ := newFakeElasticsearch(t, http.StatusBadRequest, `{"error": {"type": "parsing_exception"}}`)
+
+	if _, err := backend.Search(&models.ProjectSearchRequest{Query: "wind", Limit: 10}); err == nil {
+		t.Fatal("expected an error for a rejected query")
+	}
+}
+
+func TestElasticsearchIndexAndRemove(t *testing.T) {
+	backend, requests := newFakeElasticsearch(t, http.StatusOK, `{"result": "created"}`)
+
+	if err := backend.Index(&models.Project{ID: 3, Title: "Gujarat Wind Farm", Status: models.StatusPublished}); err != nil {
+		t.Fatal(err)
+	}
+	backend.Remove(4)
+	backend.Remove(5)
+
+	reqs := requests()
+	if len(reqs) != 3 {
+		t.Fatalf("expected 3 requests, got %+v", reqs)
+	}
+	if reqs[0].Method != http.MethodPut || reqs[0].Path != "/projects/_doc/3" || reqs[0].Body["title"] != "Gujarat Wind Farm" {
+		t.Errorf("expected the active project to be indexed, got %+v", reqs[0])
+	}
+	for i, id := range []string{"4", "5"} {
+		if req := reqs[i+1]; req.Method != http.MethodDelete || req.Path != "/projects/_doc/"+id {
+			t.Errorf("expected project %s to be deleted, got %+v", id, req)
+		}
+	}
+}
+
+func TestElasticsearchDeleteMissingDocument(t *testing.T) {
+	backend, _ := newFakeElasticsearch(t, http.StatusNotFound, `{"result": "not_found"}`)
+
+	if err := backend.Remove(9); err != nil {
+		t.Errorf("expected a missing document to be ignored, got %v", err)
+	}
+}
+
+func TestElasticsearchGeoFilters(t *testing.T) {
+	backend, requests := newFakeElasticsearch(t, http.StatusOK, `{"hits": {"total": {"value": 0}, "hits": []}}`)
+
+	_, err := backend.Search(&models.ProjectSearchRequest{
+		Near:  &models.GeoCircle{Lat: -1.29, Lon: 36.82, RadiusKm: 50},
+		BBox:  &models.GeoBox{MinLon: 170, MinLat: -20, MaxLon: -170, MaxLat: -10},
+		Limit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filters := boolFilters(requests()[0].Body["post_filter"])
+	want := []interface{}{
+		map[string]interface{}{"geo_bounding_box": map[string]interface{}{"location": map[string]interface{}{
+			"top_left":     map[string]interface{}{"lat": float64(-10), "lon": float64(170)},
+			"bottom_right": map[string]interface{}{"lat": float64(-20), "lon": float64(-170)},
+		}}},
+		map[string]interface{}{"geo_distance": map[string]interface{}{
+			"distance": "50km",
+			"location": map[string]interface{}{"lat": -1.29, "lon": 36.82},
+		}},
+	}
+	if !reflect.DeepEqual(filters, want) {
+		t.Errorf("expected the geo filters %v, got %v", want, filters)
+	}
+}
+
+func TestElasticsearchIndexesLocation(t *testing.T) {
+	backend, requests := newFakeElasticsearch(t, http.StatusOK, `{"result": "created"}`)
+
+	lat, lon := -1.29, 36.82
+	backend.Index(&models.Project{ID: 1, Title: "Nairobi Cookstoves", Latitude: &lat, Longitude: &lon})
+	backend.Index(&models.Project{ID: 2, Title: "Unmapped Project"})
+
+	reqs := requests()
+	location := map[string]interface{}{"lat": lat, "lon": lon}
+	if got := reqs[0].Body["location"]; !reflect.DeepEqual(got, location) {
+		t.Errorf("expected the location %v, got %v", location, got)
+	}
+	if reqs[0].Body["latitude"] != lat || reqs[0].Body["title"] != "Nairobi Cookstoves" {
+		t.Errorf("expected the project fields next to the location, got %v", reqs[0].Body)
+	}
+	if _, ok := reqs[1].Body["location"]; ok {
+		t.Errorf("expected no location for a project without one, got %v", reqs[1].Body)
+	}
+}
+
+func TestElasticsearchQualityFilters(t *testing.T) {
+	backend, requests := newFakeElasticsearch(t, http.StatusOK, `{
+		"hits": {"total": {"value": 0}, "hits": []},
+		"aggregations": {
+			"sdg_goals": {"doc_count": 2, "values": {"buckets": [{"key": 13, "doc_count": 2}]}},
+			"permanence_years": {"doc_count": 2, "values": {"buckets": [
+				{"key": "under_40", "from": 1, "to": 40, "doc_count": 0},
+				{"key": "40_to_100", "from": 40, "to": 100, "doc_count": 1},
+				{"key": "100_and_over", "from": 100, "doc_count": 1}
+			]}}
+		}
+	}`)
+
+	result, err := backend.Search(&models.ProjectSearchRequest{
+		CreditType:           []string{models.CreditRemoval},
+		SDGGoals:             []int{13},
+		MinPermanenceYears:   100,
+		MaxBufferPoolPercent: 20,
+		Limit:                10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := requests()[0].Body
+	filters := boolFilters(body["post_filter"])
+	want := []interface{}{
+		map[string]interface{}{"terms": map[string]interface{}{"credit_type": []interface{}{"removal"}}},
+		map[string]interface{}{"terms": map[string]interface{}{"sdg_goals": []interface{}{float64(13)}}},
+		map[string]interface{}{"range": map[string]interface{}{"permanence_years": map[string]interface{}{"gte": float64(100)}}},
+		map[string]interface{}{"range": map[string]interface{}{"buffer_pool_percent": map[string]interface{}{"lte": float64(20)}}},
+	}
+	if !reflect.DeepEqual(filters, want) {
+		t.Errorf("expected the quality filters %v, got %v", want, filters)
+	}
+
+	aggs := body["aggs"].(map[string]interface{})
+	for _, name := range []string{"methodology_id", "credit_type", "solution_type", "sdg_goals", "permanence_years", "buffer_pool_percent"} {
+		if _, ok := aggs[name]; !ok {
+			t.Errorf("expected a %s facet", name)
+		}
+	}
+	own := boolFilters(aggs["permanence_years"].(map[string]interface{})["filter"])
+	if len(own) != 3 {
+		t.Errorf("expected the permanence facet without its own filter, got %v", own)
+	}
+
+	if !reflect.DeepEqual(result.Facets.SDGGoals, []models.FacetCount{{Value: "13", Count: 2}}) {
+		t.Errorf("expected the SDG goal as a string, got %+v", result.Facets.SDGGoals)
+	}
+	if got := result.Facets.PermanenceYears; len(got) != 3 || got[2].Count != 1 || got[2].To != nil {
+		t.Errorf("unexpected permanence ranges: %+v", got)
+	}
+}
@@ -0,0 +1,399 @@
+package repositories
+
+import (
+	"math"
+	"project_service/models"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BM25 parameters, the Elasticsearch defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// fragmentSize is the approximate length of a highlighted description
+// fragment, and maxFragments the number of fragments returned
+const (
+	fragmentSize = 150
+	maxFragments = 3
+)
+
+// embeddedField is a searched field and its weight, mirroring the
+// Elasticsearch multi_match fields
+type embeddedField struct {
+	weight float64
+	value  func(p *models.Project) string
+}
+
+var embeddedFields = []embeddedField{
+	{2, func(p *models.Project) string { return p.Title }},
+	{1, func(p *models.Project) string { return p.Description }},
+	{1, func(p *models.Project) string { return p.Category }},
+	{1, func(p *models.Project) string { return p.Region }},
+	{1, func(p *models.Project) string { return p.Country }},
+	{1, func(p *models.Project) string { return p.AdditionalityNotes }},
+}
+
+// embeddedDoc is an indexed project with the term frequencies and length
+// of each field
+type embeddedDoc struct {
+	project models.Project
+	terms   []map[string]int
+	lengths []int
+}
+
+// EmbeddedSearchBackend is an in-process inverted index ranked with BM25,
+// for development and CI where no search cluster runs. Each process holds
+// its own index, built by LoadFrom at startup and kept current through
+// Index and Remove, so it suits a single service instance.
+type EmbeddedSearchBackend struct {
+	mu       sync.RWMutex
+	docs     map[uint]*embeddedDoc
+	postings map[string]map[uint]struct{}
+	totalLen []int
+}
+
+func NewEmbeddedSearchBackend() *EmbeddedSearchBackend {
+	return &EmbeddedSearchBackend{
+		docs:     make(map[uint]*embeddedDoc),
+		postings: make(map[string]map[uint]struct{}),
+		totalLen: make([]int, len(embeddedFields)),
+	}
+}
+
+// LoadFrom indexes every published project in the repository
+func (b *EmbeddedSearchBackend) LoadFrom(repo ProjectRepository) error {
+	var afterID uint
+	for {
+		projects, err := repo.GetPublishedAfter(afterID, 500)
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 {
+			return nil
+		}
+		for i := range projects {
+			b.Index(&projects[i])
+		}
+		afterID = projects[len(projects)-1].ID
+	}
+}
+
+// Index adds or replaces a project
+func (b *EmbeddedSearchBackend) Index(project *models.Project) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(project.ID)
+	if project.Status != models.StatusPublished {
+		return nil
+	}
+
+	doc := &embeddedDoc{
+		project: *project,
+		terms:   make([]map[string]int, len(embeddedFields)),
+		lengths: make([]int, len(embeddedFields)),
+	}
+	for i, field := range embeddedFields {
+		doc.terms[i] = map[string]int{}
+		for _, term := range analyze(field.value(project)) {
+			doc.terms[i][term]++
+			doc.lengths[i]++
+			if b.postings[term] == nil {
+				b.postings[term] = map[uint]struct{}{}
+			}
+			b.postings[term][project.ID] = struct{}{}
+		}
+		b.totalLen[i] += doc.lengths[i]
+	}
+	b.docs[project.ID] = doc
+	return nil
+}
+
+// Remove drops a project from the index
+func (b *EmbeddedSearchBackend) Remove(id uint) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(id)
+	return nil
+}
+
+func (b *EmbeddedSearchBackend) remove(id uint) {
+	doc, ok := b.docs[id]
+	if !ok {
+		return
+	}
+	for i := range embeddedFields {
+		for term := range doc.terms[i] {
+			delete(b.postings[term], id)
+			if len(b.postings[term]) == 0 {
+				delete(b.postings, term)
+			}
+		}
+		b.totalLen[i] -= doc.lengths[i]
+	}
+	delete(b.docs, id)
+}
+
+// Search ranks the projects matching any query term by BM25, scoring each
+// field separately and keeping the best weighted field score like an
+// Elasticsearch best_fields multi_match. Filter-only searches sorted by
+// relevance are ordered by ID. Facets are counted over every project
+// matching the query.
+func (b *EmbeddedSearchBackend) Search(searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	name, order, byColumn := searchSortFor(searchReq.Sort)
+	after, err := decodeCursor(searchReq.Cursor, name)
+	if err != nil {
+		return nil, err
+	}
+
+	terms := unique(analyze(searchReq.Query))
+
+	type scored struct {
+		doc   *embeddedDoc
+		score float64
+	}
+	key := func(m scored) float64 {
+		if byColumn {
+			return order.value(&m.doc.project)
+		}
+		return m.score
+	}
+
+	candidates := b.candidates(terms)
+	projects := make([]*models.Project, len(candidates))
+	var matches []scored
+	for i, doc := range candidates {
+		projects[i] = &doc.project
+		if matchesFilters(&doc.project, searchReq) {
+			matches = append(matches, scored{doc: doc, score: b.score(doc, terms)})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return keyLess(key(matches[i]), matches[i].doc.project.ID, key(matches[j]), matches[j].doc.project.ID, order.desc)
+	})
+
+	total := len(matches)
+	offset := searchReq.Offset
+	if after != nil {
+		offset = 0
+		for offset < len(matches) && !keyLess(after.Value, after.ID, key(matches[offset]), matches[offset].doc.project.ID, order.desc) {
+			offset++
+		}
+	}
+	matches, more := pageOf(matches, offset, searchReq.Limit)
+
+	queryTerms := map[string]bool{}
+	for _, term := range terms {
+		queryTerms[term] = true
+	}
+	hits := make([]models.ProjectHit, len(matches))
+	for i, match := range matches {
+		hits[i] = models.ProjectHit{Project: match.doc.project}
+		if len(terms) > 0 {
+			score := match.score
+			hits[i].Score = &score
+			hits[i].Highlights = highlightProject(&match.doc.project, queryTerms)
+		}
+	}
+
+	result := &models.ProjectSearchResult{
+		Hits:   hits,
+		Total:  int64(total),
+		Facets: countFacets(projects, searchReq),
+	}
+	if more {
+		last := matches[len(matches)-1]
+		result.NextCursor = cursor{Sort: name, Value: key(last), ID: last.doc.project.ID}.encode()
+	}
+	return result, nil
+}
+
+// candidates returns the documents containing any of the terms, or every
+// document when there are none
+func (b *EmbeddedSearchBackend) candidates(terms []string) []*embeddedDoc {
+	if len(terms) == 0 {
+		docs := make([]*embeddedDoc, 0, len(b.docs))
+		for _, doc := range b.docs {
+			docs = append(docs, doc)
+		}
+		return docs
+	}
+
+	seen := map[uint]bool{}
+	var docs []*embeddedDoc
+	for _, term := range terms {
+		for id := range b.postings[term] {
+			if !seen[id] {
+				seen[id] = true
+				docs = append(docs, b.docs[id])
+			}
+		}
+	}
+	return docs
+}
+
+func (b *EmbeddedSearchBackend) score(doc *embeddedDoc, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+
+	n := float64(len(b.docs))
+	best := 0.0
+	for i, field := range embeddedFields {
+		avgLen := float64(b.totalLen[i]) / n
+		fieldScore := 0.0
+		for _, term := range terms {
+			tf := float64(doc.terms[i][term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(len(b.postings[term]))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - bm25B + bm25B*float64(doc.lengths[i])/avgLen
+			fieldScore += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+		best = math.Max(best, fieldScore*field.weight)
+	}
+	return best
+}
+
+// highlightProject returns the title and the description fragments that
+// contain query terms, with the terms wrapped in <em> tags
+func highlightProject(p *models.Project, terms map[string]bool) map[string][]string {
+	result := map[string][]string{}
+	if title, ok := highlight(p.Title, terms); ok {
+		result["title"] = []string{title}
+	}
+	for _, fragment := range fragments(p.Description, terms) {
+		if text, ok := highlight(fragment, terms); ok {
+			result["description"] = append(result["description"], text)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// highlight wraps the tokens of text that match terms in <em> tags
+func highlight(text string, terms map[string]bool) (string, bool) {
+	var sb strings.Builder
+	matched := false
+	last := 0
+	for _, span := range tokenSpans(text) {
+		if !terms[normalize(text[span[0]:span[1]])] {
+			continue
+		}
+		sb.WriteString(text[last:span[0]])
+		sb.WriteString("<em>")
+		sb.WriteString(text[span[0]:span[1]])
+		sb.WriteString("</em>")
+		last = span[1]
+		matched = true
+	}
+	sb.WriteString(text[last:])
+	return sb.String(), matched
+}
+
+// fragments cuts up to maxFragments windows of about fragmentSize bytes
+// around the matching tokens of a long text, on token boundaries
+func fragments(text string, terms map[string]bool) []string {
+	if len(text) <= fragmentSize {
+		return []string{text}
+	}
+
+	spans := tokenSpans(text)
+	var result []string
+	end := -1
+	for i, span := range spans {
+		if len(result) == maxFragments {
+			break
+		}
+		if span[0] < end || !terms[normalize(text[span[0]:span[1]])] {
+			continue
+		}
+
+		// Start a few tokens before the match and stop at the last token
+		// that fits in the fragment
+		first := i
+		for first > 0 && span[0]-spans[first-1][0] < fragmentSize/3 {
+			first--
+		}
+		last := first
+		for last+1 < len(spans) && spans[last+1][1]-spans[first][0] <= fragmentSize {
+			last++
+		}
+		result = append(result, text[spans[first][0]:spans[last][1]])
+		end = spans[last][1]
+	}
+	return result
+}
+
+// analyze splits text into normalized terms
+func analyze(text string) []string {
+	spans := tokenSpans(text)
+	terms := make([]string, len(spans))
+	for i, span := range spans {
+		terms[i] = normalize(text[span[0]:span[1]])
+	}
+	return terms
+}
+
+// tokenSpans returns the byte ranges of the runs of letters and digits
+func tokenSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isToken := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isToken && start < 0:
+			start = i
+		case !isToken && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// normalize lowercases a token and strips common plural endings so
+// "cookstoves" matches "cookstove" and "communities" matches "community"
+func normalize(token string) string {
+	term := strings.ToLower(token)
+	if utf8.RuneCountInString(term) <= 3 {
+		return term
+	}
+	switch {
+	case strings.HasSuffix(term, "ies"):
+		return strings.TrimSuffix(term, "ies") + "y"
+	case strings.HasSuffix(term, "s") && !strings.HasSuffix(term, "ss"):
+		return strings.TrimSuffix(term, "s")
+	}
+	return term
+}
+
+func unique(terms []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			result = append(result, term)
+		}
+	}
+	return result
+}
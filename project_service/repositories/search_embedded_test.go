@@ -0,0 +1,326 @@
+package repositories
+
+import (
+	"project_service/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newEmbeddedBackend(t *testing.T) *EmbeddedSearchBackend {
+	t.Helper()
+
+	repo := NewInMemoryProjectRepository()
+	projects := []models.Project{
+		{Title: "Gujarat Wind Farm", Description: "Grid-connected wind turbines replacing coal power",
+			Category: "energy", Region: "Asia", Country: "India", PricePerTonne: 5},
+		{Title: "Kenya Clean Cookstoves", Description: "Efficient cookstoves for rural households, reducing demand for wood and charcoal",
+			Category: "energy", Region: "Africa", Country: "Kenya", PricePerTonne: 8},
+		{Title: "Amazon Rainforest Protection", Description: "REDD+ conservation protecting forest communities from wind erosion",
+			Category: "forestry", Region: "South America", Country: "Brazil", PricePerTonne: 12},
+		{Title: "Retired Wind Project", Category: "energy", Region: "Europe", Country: "Spain", PricePerTonne: 4},
+	}
+	for i := range projects {
+		projects[i].Status = models.StatusPublished
+		if err := repo.Create(&projects[i], models.Change{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	retire(t, repo, 4)
+
+	backend := NewEmbeddedSearchBackend()
+	if err := backend.LoadFrom(repo); err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func hitIDs(result *models.ProjectSearchResult) []uint {
+	ids := []uint{}
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestEmbeddedSearchRanking(t *testing.T) {
+	backend := newEmbeddedBackend(t)
+
+	tests := []struct {
+		name string
+		req  models.ProjectSearchRequest
+		want []uint
+	}{
+		{"title outranks description", models.ProjectSearchRequest{Query: "wind"}, []uint{1, 3}},
+		{"plurals match", models.ProjectSearchRequest{Query: "cookstove household"}, []uint{2}},
+		{"case insensitive", models.ProjectSearchRequest{Query: "KENYA"}, []uint{2}},
+		{"filters", models.ProjectSearchRequest{Query: "wind", Category: []string{"forestry"}}, []uint{3}},
+		{"price range without query", models.ProjectSearchRequest{MinPrice: 6}, []uint{2, 3}},
+		{"no match", models.ProjectSearchRequest{Query: "geothermal"}, []uint{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := backend.Search(&tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hitIDs(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			if result.Total != int64(len(tt.want)) {
+				t.Errorf("expected total %d, got %d", len(tt.want), result.Total)
+			}
+		})
+	}
+}
+
+func TestEmbeddedSearchScoresAndHighlights(t *testing.T) {
+	backend := newEmbeddedBackend(t)
+
+	result, _ := backend.Search(&models.ProjectSearchRequest{Query: "wind"})
+	first, second := result.Hits[0], result.Hits[1]
+	if first.Score == nil || second.Score == nil || *first.Score <= *second.Score {
+		t.Fatalf("expected descending scores, got %v and %v", first.Score, second.Score)
+	}
+	if got := first.Highlights["title"]; len(got) != 1 || got[0] != "Gujarat <em>Wind</em> Farm" {
+		t.Errorf("unexpected title highlight: %v", first.Highlights)
+	}
+	if _, ok := second.Highlights["title"]; ok {
+		t.Errorf("expected no title highlight without a title match: %v", second.Highlights)
+	}
+	if got := second.Highlights["description"]; len(got) != 1 || !strings.Contains(got[0], "from <em>wind</em> erosion") {
+		t.Errorf("unexpected description highlight: %v", second.Highlights)
+	}
+
+	filtered, _ := backend.Search(&models.ProjectSearchRequest{Category: []string{"energy"}})
+	if filtered.Hits[0].Score != nil || filtered.Hits[0].Highlights != nil {
+		t.Errorf("expected no score or highlights without a query: %+v", filtered.Hits[0])
+	}
+}
+
+func TestEmbeddedSearchPaging(t *testing.T) {
+	backend := newEmbeddedBackend(t)
+
+	result, _ := backend.Search(&models.ProjectSearchRequest{Limit: 1, Offset: 1})
+	if result.Total != 3 || len(result.Hits) != 1 || result.Hits[0].ID != 2 {
+		t.Errorf("expected project 2 of 3, got %v of %d", hitIDs(result), result.Total)
+	}
+
+	result, _ = backend.Search(&models.ProjectSearchRequest{Limit: 10, Offset: 5})
+	if result.Total != 3 || len(result.Hits) != 0 {
+		t.Errorf("expected an empty page of 3, got %v of %d", hitIDs(result), result.Total)
+	}
+}
+
+func TestEmbeddedIndexAndRemove(t *testing.T) {
+	backend := newEmbeddedBackend(t)
+
+	backend.Index(&models.Project{ID: 1, Title: "Gujarat Solar Park", Status: models.StatusPublished})
+	if result, _ := backend.Search(&models.ProjectSearchRequest{Query: "wind"}); len(result.Hits) != 1 || result.Hits[0].ID != 3 {
+		t.Errorf("expected the re-indexed project to lose its old terms, got %v", hitIDs(result))
+	}
+	if result, _ := backend.Search(&models.ProjectSearchRequest{Query: "solar"}); len(result.Hits) != 1 || result.Hits[0].ID != 1 {
+		t.Errorf("expected the re-indexed project to match its new title, got %v", hitIDs(result))
+	}
+
+	backend.Remove(3)
+	backend.Index(&models.Project{ID: 2, Title: "Kenya Clean Cookstoves", Status: models.StatusRetired})
+	if result, _ := backend.Search(&models.ProjectSearchRequest{}); result.Total != 1 {
+		t.Errorf("expected removed and inactive projects to leave the index, got %v", hitIDs(result))
+	}
+}
+
+func TestEmbeddedFragments(t *testing.T) {
+	text := strings.Repeat("filler words here ", 20) + "a mangrove restoration site " + strings.Repeat("more filler ", 20)
+
+	got := fragments(text, map[string]bool{"mangrove": true})
+	if len(got) != 1 || len(got[0]) > fragmentSize || !strings.Contains(got[0], "mangrove restoration") {
+		t.Fatalf("expected one fragment around the match, got %q", got)
+	}
+	if highlighted, ok := highlight(got[0], map[string]bool{"mangrove": true}); !ok || !strings.Contains(highlighted, "<em>mangrove</em>") {
+		t.Errorf("expected the match to be highlighted, got %q", highlighted)
+	}
+}
+
+func TestEmbeddedFacets(t *testing.T) {
+	backend := newEmbeddedBackend(t)
+
+	result, _ := backend.Search(&models.ProjectSearchRequest{Category: []string{"energy"}, MinPrice: 6})
+	if result.Total != 1 || result.Hits[0].ID != 2 {
+		t.Fatalf("expected only project 2, got %v", hitIDs(result))
+	}
+
+	facets := result.Facets
+	wantCategories := []models.FacetCount{{Value: "energy", Count: 1}, {Value: "forestry", Count: 1}}
+	if !reflect.DeepEqual(facets.Category, wantCategories) {
+		t.Errorf("expected category counts without the category filter %+v, got %+v", wantCategories, facets.Category)
+	}
+	if !reflect.DeepEqual(facets.Country, []models.FacetCount{{Value: "Kenya", Count: 1}}) {
+		t.Errorf("expected country counts under every filter, got %+v", facets.Country)
+	}
+	if !reflect.DeepEqual(facets.PricePerTonne, []models.HistogramBucket{{From: 5, To: 10, Count: 2}}) {
+		t.Errorf("expected the price histogram without the price filter, got %+v", facets.PricePerTonne)
+	}
+	if facets.AvailableCapacity[0].Key != "under_1k" || facets.AvailableCapacity[0].Count != 1 {
+		t.Errorf("unexpected capacity ranges: %+v", facets.AvailableCapacity)
+	}
+
+	result, _ = backend.Search(&models.ProjectSearchRequest{Query: "wind"})
+	if !reflect.DeepEqual(result.Facets.Category, []models.FacetCount{{Value: "energy", Count: 1}, {Value: "forestry", Count: 1}}) {
+		t.Errorf("expected facets over the query matches only, got %+v", result.Facets.Category)
+	}
+}
+
+func TestHistogramFillsGaps(t *testing.T) {
+	got := histogram(map[int64]int64{1: 2, 4: 1})
+	if len(got) != 4 || got[0].From != 5 || got[3].To != 25 || got[1].Count != 0 || got[3].Count != 1 {
+		t.Errorf("unexpected histogram: %+v", got)
+	}
+}
+
+func TestEmbeddedSortAndCursor(t *testing.T) {
+	backend := newEmbeddedBackend(t)
+
+	req := models.ProjectSearchRequest{Sort: models.SortPriceDesc, Limit: 1}
+	var walked []uint
+	for page := 0; page < 5; page++ {
+		result, err := backend.Search(&req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		walked = append(walked, hitIDs(result)...)
+		if page == 0 {
+			if result.Total != 3 {
+				t.Errorf("expected total 3, got %d", result.Total)
+			}
+			// A project inserted before the cursor does not shift later pages
+			backend.Index(&models.Project{ID: 9, Title: "Late Addition", PricePerTonne: 20, Status: models.StatusPublished})
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		req.Cursor = result.NextCursor
+	}
+	if !reflect.DeepEqual(walked, []uint{3, 2, 1}) {
+		t.Errorf("expected projects by descending price, got %v", walked)
+	}
+
+	result, _ := backend.Search(&models.ProjectSearchRequest{Query: "wind", Limit: 1})
+	if result.NextCursor == "" {
+		t.Fatal("expected a cursor after the first relevance page")
+	}
+	result, _ = backend.Search(&models.ProjectSearchRequest{Query: "wind", Limit: 1, Cursor: result.NextCursor})
+	if got := hitIDs(result); !reflect.DeepEqual(got, []uint{3}) || result.NextCursor != "" {
+		t.Errorf("expected the second and last relevance page to hold project 3, got %v", got)
+	}
+}
+
+func TestEmbeddedGeoFilters(t *testing.T) {
+	at := func(lat, lon float64) (*float64, *float64) { return &lat, &lon }
+	backend := NewEmbeddedSearchBackend()
+	projects := []models.Project{
+		{ID: 1, Title: "Nairobi Cookstoves"},
+		{ID: 2, Title: "Mombasa Mangroves"},
+		{ID: 3, Title: "Fiji Reef Restoration"},
+		{ID: 4, Title: "Samoa Solar"},
+		{ID: 5, Title: "Unmapped Project"},
+	}
+	projects[0].Latitude, projects[0].Longitude = at(-1.29, 36.82)
+	projects[1].Latitude, projects[1].Longitude = at(-4.04, 39.67)
+	projects[2].Latitude, projects[2].Longitude = at(-17.71, 178.07)
+	projects[3].Latitude, projects[3].Longitude = at(-13.76, -172.1)
+	for i := range projects {
+		projects[i].Status = models.StatusPublished
+		backend.Index(&projects[i])
+	}
+
+	tests := []struct {
+		name string
+		req  models.ProjectSearchRequest
+		want []uint
+	}{
+		{"radius", models.ProjectSearchRequest{Near: &models.GeoCircle{Lat: -1.29, Lon: 36.82, RadiusKm: 100}}, []uint{1}},
+		{"wider radius", models.ProjectSearchRequest{Near: &models.GeoCircle{Lat: -1.29, Lon: 36.82, RadiusKm: 500}}, []uint{1, 2}},
+		{"radius across the antimeridian", models.ProjectSearchRequest{Near: &models.GeoCircle{Lat: -16, Lon: 179.9, RadiusKm: 1200}}, []uint{3, 4}},
+		{"bounding box", models.ProjectSearchRequest{BBox: &models.GeoBox{MinLon: 30, MinLat: -5, MaxLon: 40, MaxLat: 0}}, []uint{1, 2}},
+		{"box across the antimeridian", models.ProjectSearchRequest{BBox: &models.GeoBox{MinLon: 170, MinLat: -20, MaxLon: -170, MaxLat: -10}}, []uint{3, 4}},
+		{"located", models.ProjectSearchRequest{Located: true}, []uint{1, 2, 3, 4}},
+		{"no geo filter", models.ProjectSearchRequest{}, []uint{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Limit = 10
+			result, err := backend.Search(&tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hitIDs(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected projects %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEmbeddedQualityFilters(t *testing.T) {
+	backend := NewEmbeddedSearchBackend()
+	projects := []models.Project{
+		{ID: 1, Title: "Direct Air Capture", MethodologyID: "VM0049", CreditType: models.CreditRemoval,
+			SolutionType: models.SolutionTechnological, PermanenceYears: 1000, SDGGoals: models.SDGGoals{9, 13}},
+		{ID: 2, Title: "Mangrove Restoration", MethodologyID: "VM0033", CreditType: models.CreditRemoval,
+			SolutionType: models.SolutionNatureBased, PermanenceYears: 40, BufferPoolPercent: 20, SDGGoals: models.SDGGoals{13, 14}},
+		{ID: 3, Title: "Avoided Deforestation", MethodologyID: "VM0048", CreditType: models.CreditAvoidance,
+			SolutionType: models.SolutionNatureBased, PermanenceYears: 30, BufferPoolPercent: 15, SDGGoals: models.SDGGoals{15},
+			AdditionalityNotes: "Logging concessions were granted over the area"},
+		{ID: 4, Title: "Landfill Gas"},
+	}
+	for i := range projects {
+		projects[i].Status = models.StatusPublished
+		backend.Index(&projects[i])
+	}
+
+	tests := []struct {
+		name string
+		req  models.ProjectSearchRequest
+		want []uint
+	}{
+		{"credit type", models.ProjectSearchRequest{CreditType: []string{models.CreditRemoval}}, []uint{1, 2}},
+		{"solution type", models.ProjectSearchRequest{SolutionType: []string{models.SolutionNatureBased}}, []uint{2, 3}},
+		{"methodology", models.ProjectSearchRequest{MethodologyID: []string{"VM0048", "VM0049"}}, []uint{1, 3}},
+		{"any SDG goal", models.ProjectSearchRequest{SDGGoals: []int{14, 15}}, []uint{2, 3}},
+		{"permanence", models.ProjectSearchRequest{MinPermanenceYears: 40}, []uint{1, 2}},
+		{"buffer pool", models.ProjectSearchRequest{MaxBufferPoolPercent: 15}, []uint{1, 3, 4}},
+		{"additionality notes", models.ProjectSearchRequest{Query: "logging concessions"}, []uint{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Limit = 10
+			result, err := backend.Search(&tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hitIDs(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected projects %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	result, _ := backend.Search(&models.ProjectSearchRequest{CreditType: []string{models.CreditRemoval}, MinPermanenceYears: 100, Limit: 10})
+	facets := result.Facets
+	wantCredit := []models.FacetCount{{Value: models.CreditRemoval, Count: 1}}
+	if !reflect.DeepEqual(facets.CreditType, wantCredit) {
+		t.Errorf("expected credit type counts without the credit type filter %+v, got %+v", wantCredit, facets.CreditType)
+	}
+	if !reflect.DeepEqual(facets.SDGGoals, []models.FacetCount{{Value: "13", Count: 1}, {Value: "9", Count: 1}}) {
+		t.Errorf("expected a count per SDG goal, got %+v", facets.SDGGoals)
+	}
+	if got := facets.PermanenceYears; got[1].Count != 1 || got[2].Count != 1 {
+		t.Errorf("expected permanence counts without the permanence filter, got %+v", got)
+	}
+	if got := facets.BufferPoolPercent; got[0].Count != 1 || got[2].Count != 0 {
+		t.Errorf("unexpected buffer pool counts: %+v", got)
+	}
+	if len(facets.MethodologyID) != 1 || facets.MethodologyID[0].Value != "VM0049" {
+		t.Errorf("expected only the matching methodology, got %+v", facets.MethodologyID)
+	}
+}
@@ -0,0 +1,269 @@
+package repositories
+
+import (
+	"math"
+	"project_service/models"
+	"sort"
+	"strconv"
+)
+
+// Search filters. A facet is counted with every filter applied except its
+// own, so these name the filter a facet leaves out.
+const (
+	filterNone     = ""
+	filterCategory = "category"
+	filterRegion   = "region"
+	filterCountry  = "country"
+	filterStandard = "verification_standard"
+	filterVintage  = "vintage"
+	filterPrice    = "price"
+
+	filterMethodology = "methodology_id"
+	filterCreditType  = "credit_type"
+	filterSolution    = "solution_type"
+	filterSDG         = "sdg_goals"
+	filterPermanence  = "permanence_years"
+	filterBufferPool  = "buffer_pool_percent"
+)
+
+// termFacets maps each terms facet to its field and filter
+var termFacets = []struct {
+	filter string
+	value  func(p *models.Project) string
+}{
+	{filterCategory, func(p *models.Project) string { return p.Category }},
+	{filterRegion, func(p *models.Project) string { return p.Region }},
+	{filterCountry, func(p *models.Project) string { return p.Country }},
+	{filterStandard, func(p *models.Project) string { return p.VerificationStandard }},
+	{filterMethodology, func(p *models.Project) string { return p.MethodologyID }},
+	{filterCreditType, func(p *models.Project) string { return p.CreditType }},
+	{filterSolution, func(p *models.Project) string { return p.SolutionType }},
+}
+
+// rangeFacets maps each range facet to its field, the filter it leaves out
+// and its ranges. Available capacity has no filter of its own.
+var rangeFacets = []struct {
+	field  string
+	filter string
+	ranges []models.FacetRange
+	value  func(p *models.Project) float64
+}{
+	{"available_capacity", filterNone, models.CapacityRanges, func(p *models.Project) float64 { return p.AvailableCapacity }},
+	{"permanence_years", filterPermanence, models.PermanenceRanges, func(p *models.Project) float64 { return float64(p.PermanenceYears) }},
+	{"buffer_pool_percent", filterBufferPool, models.BufferPoolRanges, func(p *models.Project) float64 { return p.BufferPoolPercent }},
+}
+
+// setTermFacet stores the counts of a terms facet
+func setTermFacet(facets *models.ProjectFacets, filter string, counts []models.FacetCount) {
+	switch filter {
+	case filterCategory:
+		facets.Category = counts
+	case filterRegion:
+		facets.Region = counts
+	case filterCountry:
+		facets.Country = counts
+	case filterStandard:
+		facets.VerificationStandard = counts
+	case filterMethodology:
+		facets.MethodologyID = counts
+	case filterCreditType:
+		facets.CreditType = counts
+	case filterSolution:
+		facets.SolutionType = counts
+	}
+}
+
+// setRangeFacet stores the counts of a range facet
+func setRangeFacet(facets *models.ProjectFacets, field string, counts []models.RangeCount) {
+	switch field {
+	case "available_capacity":
+		facets.AvailableCapacity = counts
+	case "permanence_years":
+		facets.PermanenceYears = counts
+	case "buffer_pool_percent":
+		facets.BufferPoolPercent = counts
+	}
+}
+
+// matchesFilters applies the search filters
+func matchesFilters(p *models.Project, searchReq *models.ProjectSearchRequest) bool {
+	return matchesFiltersExcept(p, searchReq, filterNone)
+}
+
+// matchesFiltersExcept applies every search filter except one
+func matchesFiltersExcept(p *models.Project, searchReq *models.ProjectSearchRequest, except string) bool {
+	if except != filterCategory && len(searchReq.Category) > 0 && !contains(searchReq.Category, p.Category) {
+		return false
+	}
+	if except != filterRegion && len(searchReq.Region) > 0 && !contains(searchReq.Region, p.Region) {
+		return false
+	}
+	if except != filterCountry && len(searchReq.Country) > 0 && !contains(searchReq.Country, p.Country) {
+		return false
+	}
+	if except != filterStandard && len(searchReq.VerificationStandard) > 0 && !contains(searchReq.VerificationStandard, p.VerificationStandard) {
+		return false
+	}
+	if except != filterVintage && len(searchReq.Vintage) > 0 && !hasVintage(p, searchReq.Vintage) {
+		return false
+	}
+	if except != filterPrice && searchReq.MinPrice > 0 && p.PricePerTonne < searchReq.MinPrice {
+		return false
+	}
+	if except != filterPrice && searchReq.MaxPrice > 0 && p.PricePerTonne > searchReq.MaxPrice {
+		return false
+	}
+	if except != filterMethodology && len(searchReq.MethodologyID) > 0 && !contains(searchReq.MethodologyID, p.MethodologyID) {
+		return false
+	}
+	if except != filterCreditType && len(searchReq.CreditType) > 0 && !contains(searchReq.CreditType, p.CreditType) {
+		return false
+	}
+	if except != filterSolution && len(searchReq.SolutionType) > 0 && !contains(searchReq.SolutionType, p.SolutionType) {
+		return false
+	}
+	if except != filterSDG && len(searchReq.SDGGoals) > 0 && !p.SDGGoals.Has(searchReq.SDGGoals) {
+		return false
+	}
+	if except != filterPermanence && searchReq.MinPermanenceYears > 0 && p.PermanenceYears < searchReq.MinPermanenceYears {
+		return false
+	}
+	if except != filterBufferPool && searchReq.MaxBufferPoolPercent > 0 && p.BufferPoolPercent > searchReq.MaxBufferPoolPercent {
+		return false
+	}
+	return matchesGeo(p, searchReq)
+}
+
+// hasVintage reports whether a project has a vintage of one of the years
+func hasVintage(p *models.Project, years []int) bool {
+	for _, vintage := range p.Vintages {
+		for _, year := range years {
+			if vintage.Year == year {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countFacets computes the facets over the projects matching the query,
+// before any filter is applied
+func countFacets(projects []*models.Project, searchReq *models.ProjectSearchRequest) models.ProjectFacets {
+	facets := models.ProjectFacets{}
+
+	for _, facet := range termFacets {
+		counts := map[string]int64{}
+		for _, p := range projects {
+			if value := facet.value(p); value != "" && matchesFiltersExcept(p, searchReq, facet.filter) {
+				counts[value]++
+			}
+		}
+		setTermFacet(&facets, facet.filter, termCounts(counts))
+	}
+
+	// A project counts once towards each of its vintage years
+	vintages := map[string]int64{}
+	for _, p := range projects {
+		if matchesFiltersExcept(p, searchReq, filterVintage) {
+			for _, vintage := range p.Vintages {
+				vintages[strconv.Itoa(vintage.Year)]++
+			}
+		}
+	}
+	facets.Vintage = termCounts(vintages)
+
+	// A project counts once towards each of its SDG goals
+	goals := map[string]int64{}
+	for _, p := range projects {
+		if matchesFiltersExcept(p, searchReq, filterSDG) {
+			for _, goal := range p.SDGGoals {
+				goals[strconv.Itoa(goal)]++
+			}
+		}
+	}
+	facets.SDGGoals = termCounts(goals)
+
+	prices := map[int64]int64{}
+	for _, p := range projects {
+		if matchesFiltersExcept(p, searchReq, filterPrice) {
+			prices[priceBucket(p.PricePerTonne)]++
+		}
+	}
+	facets.PricePerTonne = histogram(prices)
+
+	for _, facet := range rangeFacets {
+		counts := make([]int64, len(facet.ranges))
+		for _, p := range projects {
+			if !matchesFiltersExcept(p, searchReq, facet.filter) {
+				continue
+			}
+			for i, r := range facet.ranges {
+				if r.Contains(facet.value(p)) {
+					counts[i]++
+				}
+			}
+		}
+		setRangeFacet(&facets, facet.field, rangeCounts(facet.ranges, counts))
+	}
+	return facets
+}
+
+// termCounts orders value counts by count, then value, keeping the first
+// FacetSize
+func termCounts(counts map[string]int64) []models.FacetCount {
+	result := make([]models.FacetCount, 0, len(counts))
+	for value, count := range counts {
+		result = append(result, models.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	if len(result) > models.FacetSize {
+		result = result[:models.FacetSize]
+	}
+	return result
+}
+
+// priceBucket is the index of the histogram bucket holding a price
+func priceBucket(price float64) int64 {
+	return int64(math.Floor(price / models.PriceHistogramInterval))
+}
+
+// histogram turns counts per bucket index into buckets from the lowest to
+// the highest index, including empty buckets in between
+func histogram(counts map[int64]int64) []models.HistogramBucket {
+	result := []models.HistogramBucket{}
+	if len(counts) == 0 {
+		return result
+	}
+
+	first, last := int64(math.MaxInt64), int64(math.MinInt64)
+	for bucket := range counts {
+		first = min(first, bucket)
+		last = max(last, bucket)
+	}
+	for bucket := first; bucket <= last; bucket++ {
+		result = append(result, models.HistogramBucket{
+			From:  float64(bucket) * models.PriceHistogramInterval,
+			To:    float64(bucket+1) * models.PriceHistogramInterval,
+			Count: counts[bucket],
+		})
+	}
+	return result
+}
+
+// rangeCounts pairs counts with their ranges, in range order
+func rangeCounts(ranges []models.FacetRange, counts []int64) []models.RangeCount {
+	result := make([]models.RangeCount, len(ranges))
+	for i, r := range ranges {
+		result[i] = models.RangeCount{Key: r.Key, From: r.From, Count: counts[i]}
+		if r.To != 0 {
+			to := r.To
+			result[i].To = &to
+		}
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"fmt"
+	"project_service/models"
+
+	"gorm.io/gorm"
+)
+
+// LocationMigrations add the GiST index the Postgres geo filters use. It
+// indexes the project's position as a point, longitude first, so bounding
+// boxes are answered from the index; radius filters check the box around
+// the circle there before measuring distances.
+var LocationMigrations = []string{
+	`CREATE INDEX IF NOT EXISTS idx_projects_location ON projects USING GIST (point(longitude, latitude))`,
+}
+
+// locationSQL is a project's position as the indexed point
+const locationSQL = "point(longitude, latitude)"
+
+// distanceSQL is the haversine distance in kilometres from a position,
+// given as latitude and longitude, to a project, like models.DistanceKm
+const distanceSQL = "2 * %g * asin(sqrt(least(1, power(sin(radians(latitude - ?) / 2), 2) + " +
+	"cos(radians(?)) * cos(radians(latitude)) * power(sin(radians(longitude - ?) / 2), 2))))"
+
+// SearchDocument is a project as it is indexed in Elasticsearch, its
+// position copied into the geo_point the geo filters match
+type SearchDocument struct {
+	*models.Project
+	Location *esGeoPoint `json:"location,omitempty"`
+}
+
+type esGeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// NewSearchDocument returns the search document of a project
+func NewSearchDocument(project *models.Project) SearchDocument {
+	doc := SearchDocument{Project: project}
+	if project.Latitude != nil && project.Longitude != nil {
+		doc.Location = &esGeoPoint{Lat: *project.Latitude, Lon: *project.Longitude}
+	}
+	return doc
+}
+
+// matchesGeo applies the geo filters. They are never left out of a facet.
+func matchesGeo(p *models.Project, searchReq *models.ProjectSearchRequest) bool {
+	if !searchReq.Located && searchReq.BBox == nil && searchReq.Near == nil {
+		return true
+	}
+	if p.Latitude == nil || p.Longitude == nil {
+		return false
+	}
+	if searchReq.BBox != nil && !searchReq.BBox.Contains(*p.Latitude, *p.Longitude) {
+		return false
+	}
+	if searchReq.Near != nil && !searchReq.Near.Contains(*p.Latitude, *p.Longitude) {
+		return false
+	}
+	return true
+}
+
+// esGeoFilters returns the Elasticsearch filters of the geo filters
+func esGeoFilters(searchReq *models.ProjectSearchRequest) []interface{} {
+	filters := []interface{}{}
+	if searchReq.Located {
+		filters = append(filters, map[string]interface{}{"exists": map[string]interface{}{"field": "location"}})
+	}
+	if box := searchReq.BBox; box != nil {
+		// A top left longitude east of the bottom right one crosses the
+		// antimeridian, as in the request
+		filters = append(filters, map[string]interface{}{
+			"geo_bounding_box": map[string]interface{}{
+				"location": map[string]interface{}{
+					"top_left":     esGeoPoint{Lat: box.MaxLat, Lon: box.MinLon},
+					"bottom_right": esGeoPoint{Lat: box.MinLat, Lon: box.MaxLon},
+				},
+			},
+		})
+	}
+	if near := searchReq.Near; near != nil {
+		filters = append(filters, map[string]interface{}{
+			"geo_distance": map[string]interface{}{
+				"distance": fmt.Sprintf("%gkm", near.RadiusKm),
+				"location": esGeoPoint{Lat: near.Lat, Lon: near.Lon},
+			},
+		})
+	}
+	return filters
+}
+
+// applyGeoFilters applies the geo filters to a projects query
+func applyGeoFilters(query *gorm.DB, searchReq *models.ProjectSearchRequest) *gorm.DB {
+	if searchReq.Located {
+		query = query.Where("latitude IS NOT NULL AND longitude IS NOT NULL")
+	}
+	if searchReq.BBox != nil {
+		query = withinBox(query, *searchReq.BBox)
+	}
+	if near := searchReq.Near; near != nil {
+		query = withinBox(query, near.Bounds()).
+			Where(fmt.Sprintf(distanceSQL, models.EarthRadiusKm)+" <= ?", near.Lat, near.Lat, near.Lon, near.RadiusKm)
+	}
+	return query
+}
+
+// withinBox keeps the projects inside a box, in either of its spans when
+// it crosses the antimeridian
+func withinBox(query *gorm.DB, box models.GeoBox) *gorm.DB {
+	spans := box.Spans()
+	within := query.Session(&gorm.Session{NewDB: true})
+	for i, span := range spans {
+		condition := locationSQL + " <@ box(point(?, ?), point(?, ?))"
+		if i == 0 {
+			within = within.Where(condition, span.MinLon, span.MinLat, span.MaxLon, span.MaxLat)
+		} else {
+			within = within.Or(condition, span.MinLon, span.MinLat, span.MaxLon, span.MaxLat)
+		}
+	}
+	return query.Where(within)
+}
@@ -0,0 +1,348 @@
+package repositories
+
+import (
+	"fmt"
+	"project_service/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SearchVectorMigrations add the weighted search_vector column the Postgres
+// search backend matches against, and its GIN index. Title terms rank
+// highest, then the description, then category, region and country, then
+// the additionality notes. A column generated before the notes were
+// searched is dropped first, as a generation expression cannot be altered.
+var SearchVectorMigrations = []string{
+	`DO $$ BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'projects'
+			AND column_name = 'search_vector' AND generation_expression NOT LIKE '%additionality_notes%') THEN
+			ALTER TABLE projects DROP COLUMN search_vector;
+		END IF;
+	END $$`,
+	`ALTER TABLE projects ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+		setweight(to_tsvector('simple', coalesce(category, '') || ' ' || coalesce(region, '') || ' ' || coalesce(country, '')), 'C') ||
+		setweight(to_tsvector('english', coalesce(additionality_notes, '')), 'D')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_projects_search_vector ON projects USING GIN (search_vector)`,
+}
+
+// ts_headline options that mark matches the way the Elasticsearch
+// highlighter does
+const (
+	titleHeadline       = "StartSel=<em>, StopSel=</em>, HighlightAll=true"
+	descriptionHeadline = "StartSel=<em>, StopSel=</em>, MaxFragments=3, MaxWords=25, MinWords=10"
+)
+
+// PostgresSearchBackend ranks projects with Postgres full-text search over
+// the generated search_vector column. It reads the projects table directly,
+// so there is nothing to keep in sync.
+type PostgresSearchBackend struct {
+	db *gorm.DB
+}
+
+func NewPostgresSearchBackend(db *gorm.DB) *PostgresSearchBackend {
+	return &PostgresSearchBackend{db: db}
+}
+
+// searchRow is a project with its rank and highlighted fragments
+type searchRow struct {
+	models.Project       `gorm:"embedded"`
+	Score                float64
+	TitleHighlight       string
+	DescriptionHighlight string
+}
+
+// rankSQL scores a project against a websearch query
+const rankSQL = "ts_rank_cd(search_vector, websearch_to_tsquery('english', ?))"
+
+// Search matches the query with websearch_to_tsquery, ranked by ts_rank_cd.
+// Filter-only searches sorted by relevance are ordered by ID.
+func (b *PostgresSearchBackend) Search(searchReq *models.ProjectSearchRequest) (*models.ProjectSearchResult, error) {
+	name, order, byColumn := searchSortFor(searchReq.Sort)
+	after, err := decodeCursor(searchReq.Cursor, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// matched holds the projects matching the query, before any filter;
+	// the page and each facet apply their own filters to it
+	matched := b.db.Model(&models.Project{}).Where("status = ?", models.StatusPublished)
+	if searchReq.Query != "" {
+		matched = matched.Where("search_vector @@ websearch_to_tsquery('english', ?)", searchReq.Query)
+	}
+	matched = matched.Session(&gorm.Session{})
+
+	facets, err := b.facets(matched, searchReq)
+	if err != nil {
+		return nil, err
+	}
+
+	query := applySearchFilters(matched, searchReq, filterNone).Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	page := query
+	if searchReq.Query != "" {
+		page = page.Select("projects.*, "+
+			"ts_rank_cd(search_vector, websearch_to_tsquery('english', @q)) AS score, "+
+			"ts_headline('english', title, websearch_to_tsquery('english', @q), @title) AS title_highlight, "+
+			"ts_headline('english', coalesce(description, ''), websearch_to_tsquery('english', @q), @description) AS description_highlight",
+			map[string]interface{}{"q": searchReq.Query, "title": titleHeadline, "description": descriptionHeadline})
+	}
+
+	switch {
+	case byColumn:
+		page = order.apply(page, after)
+	case searchReq.Query != "":
+		if after != nil {
+			page = keysetAfter(page, rankSQL, true, after.Value, after.ID, searchReq.Query)
+		}
+		page = page.Order("score DESC, id")
+	default:
+		if after != nil {
+			page = page.Where("id > ?", after.ID)
+		}
+		page = page.Order("id")
+	}
+
+	offset := searchReq.Offset
+	if after != nil {
+		offset = 0
+	}
+
+	// One extra row tells whether a next page exists
+	var rows []searchRow
+	if err := page.Limit(searchReq.Limit + 1).Offset(offset).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	rows, more := pageOf(rows, 0, searchReq.Limit)
+
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	vintages, err := vintagesOf(b.db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]models.ProjectHit, len(rows))
+	for i, row := range rows {
+		hits[i] = models.ProjectHit{Project: row.Project}
+		hits[i].Vintages = vintages[row.ID]
+		if searchReq.Query != "" {
+			score := row.Score
+			hits[i].Score = &score
+			hits[i].Highlights = highlights(row.TitleHighlight, row.DescriptionHighlight)
+		}
+	}
+
+	result := &models.ProjectSearchResult{Hits: hits, Total: total, Facets: facets}
+	if more {
+		last := rows[len(rows)-1]
+		next := cursor{Sort: name, Value: last.Score, ID: last.ID}
+		if byColumn {
+			next.Value = order.value(&last.Project)
+		}
+		result.NextCursor = next.encode()
+	}
+	return result, nil
+}
+
+// facets counts each facet over the matched projects with every filter
+// except its own
+func (b *PostgresSearchBackend) facets(matched *gorm.DB, searchReq *models.ProjectSearchRequest) (models.ProjectFacets, error) {
+	facets := models.ProjectFacets{}
+
+	// The terms facet filters are named after their columns
+	for _, facet := range termFacets {
+		counts := []models.FacetCount{}
+		err := applySearchFilters(matched, searchReq, facet.filter).
+			Where(facet.filter + " <> ''").
+			Select(facet.filter + " AS value, count(*) AS count").
+			Group(facet.filter).
+			Order("count DESC, value").
+			Limit(models.FacetSize).
+			Find(&counts).Error
+		if err != nil {
+			return facets, err
+		}
+		setTermFacet(&facets, facet.filter, counts)
+	}
+
+	// A vintage year counts the matching projects with a vintage of it
+	vintages := []models.FacetCount{}
+	err := b.db.Model(&models.Vintage{}).
+		Select("year::text AS value, count(*) AS count").
+		Where("project_id IN (?)", applySearchFilters(matched, searchReq, filterVintage).Select("id")).
+		Group("year").
+		Order("count DESC, value").
+		Limit(models.FacetSize).
+		Find(&vintages).Error
+	if err != nil {
+		return facets, err
+	}
+	facets.Vintage = vintages
+
+	var prices []struct {
+		Bucket int64
+		Count  int64
+	}
+	err = applySearchFilters(matched, searchReq, filterPrice).
+		Select("floor(price_per_tonne / ?)::bigint AS bucket, count(*) AS count", models.PriceHistogramInterval).
+		Group("bucket").
+		Find(&prices).Error
+	if err != nil {
+		return facets, err
+	}
+	priceCounts := map[int64]int64{}
+	for _, price := range prices {
+		priceCounts[price.Bucket] = price.Count
+	}
+	facets.PricePerTonne = histogram(priceCounts)
+
+	// An SDG goal counts the matching projects claiming it
+	goals := []models.FacetCount{}
+	err = b.db.Table("(?) AS matched, jsonb_array_elements_text(matched.sdg_goals) AS goal",
+		applySearchFilters(matched, searchReq, filterSDG).Select("sdg_goals")).
+		Select("goal AS value, count(*) AS count").
+		Group("goal").
+		Order("count DESC, value").
+		Limit(models.FacetSize).
+		Find(&goals).Error
+	if err != nil {
+		return facets, err
+	}
+	facets.SDGGoals = goals
+
+	for _, facet := range rangeFacets {
+		var buckets []struct {
+			Bucket *int
+			Count  int64
+		}
+		err = applySearchFilters(matched, searchReq, facet.filter).
+			Select(rangeBucketSQL(facet.field, facet.ranges) + " AS bucket, count(*) AS count").
+			Group("bucket").
+			Find(&buckets).Error
+		if err != nil {
+			return facets, err
+		}
+		counts := make([]int64, len(facet.ranges))
+		for _, bucket := range buckets {
+			if bucket.Bucket != nil {
+				counts[*bucket.Bucket] = bucket.Count
+			}
+		}
+		setRangeFacet(&facets, facet.field, rangeCounts(facet.ranges, counts))
+	}
+
+	return facets, nil
+}
+
+// rangeBucketSQL numbers the range holding a column's value, or is NULL
+// outside every range
+func rangeBucketSQL(column string, ranges []models.FacetRange) string {
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	for i, r := range ranges {
+		fmt.Fprintf(&sb, " WHEN %s >= %g", column, r.From)
+		if r.To != 0 {
+			fmt.Fprintf(&sb, " AND %s < %g", column, r.To)
+		}
+		fmt.Fprintf(&sb, " THEN %d", i)
+	}
+	sb.WriteString(" END")
+	return sb.String()
+}
+
+// Index is a no-op: search_vector is generated by Postgres
+func (b *PostgresSearchBackend) Index(project *models.Project) error {
+	return nil
+}
+
+// Remove is a no-op: unpublished projects are filtered by status
+func (b *PostgresSearchBackend) Remove(id uint) error {
+	return nil
+}
+
+// applySearchFilters applies every search filter except one to a projects
+// query
+func applySearchFilters(query *gorm.DB, searchReq *models.ProjectSearchRequest, except string) *gorm.DB {
+	if except != filterCategory && len(searchReq.Category) > 0 {
+		query = query.Where("category IN ?", searchReq.Category)
+	}
+
+	if except != filterRegion && len(searchReq.Region) > 0 {
+		query = query.Where("region IN ?", searchReq.Region)
+	}
+
+	if except != filterCountry && len(searchReq.Country) > 0 {
+		query = query.Where("country IN ?", searchReq.Country)
+	}
+
+	if except != filterStandard && len(searchReq.VerificationStandard) > 0 {
+		query = query.Where("verification_standard IN ?", searchReq.VerificationStandard)
+	}
+
+	if except != filterVintage && len(searchReq.Vintage) > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM vintages WHERE vintages.project_id = projects.id AND vintages.year IN ?)", searchReq.Vintage)
+	}
+
+	if except != filterPrice && searchReq.MinPrice > 0 {
+		query = query.Where("price_per_tonne >= ?", searchReq.MinPrice)
+	}
+
+	if except != filterPrice && searchReq.MaxPrice > 0 {
+		query = query.Where("price_per_tonne <= ?", searchReq.MaxPrice)
+	}
+
+	if except != filterMethodology && len(searchReq.MethodologyID) > 0 {
+		query = query.Where("methodology_id IN ?", searchReq.MethodologyID)
+	}
+
+	if except != filterCreditType && len(searchReq.CreditType) > 0 {
+		query = query.Where("credit_type IN ?", searchReq.CreditType)
+	}
+
+	if except != filterSolution && len(searchReq.SolutionType) > 0 {
+		query = query.Where("solution_type IN ?", searchReq.SolutionType)
+	}
+
+	if except != filterSDG && len(searchReq.SDGGoals) > 0 {
+		query = query.Where("EXISTS (SELECT 1 FROM jsonb_array_elements_text(sdg_goals) AS goal WHERE goal::int IN ?)", searchReq.SDGGoals)
+	}
+
+	if except != filterPermanence && searchReq.MinPermanenceYears > 0 {
+		query = query.Where("permanence_years >= ?", searchReq.MinPermanenceYears)
+	}
+
+	if except != filterBufferPool && searchReq.MaxBufferPoolPercent > 0 {
+		query = query.Where("buffer_pool_percent <= ?", searchReq.MaxBufferPoolPercent)
+	}
+
+	return applyGeoFilters(query, searchReq)
+}
+
+// highlights keeps the title and description headlines that contain a
+// match. ts_headline separates description fragments with " ... ".
+func highlights(title, description string) map[string][]string {
+	result := map[string][]string{}
+	if strings.Contains(title, "<em>") {
+		result["title"] = []string{title}
+	}
+	for _, fragment := range strings.Split(description, " ... ") {
+		if strings.Contains(fragment, "<em>") {
+			result["description"] = append(result["description"], fragment)
+		}
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
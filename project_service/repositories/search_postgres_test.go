@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"context"
+	"project_service/models"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder is a GORM logger that records the SQL of each statement
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRunDB builds SQL for Postgres without connecting to a database
+func dryRunDB(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, recorder
+}
+
+func TestPostgresSearchSQL(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	_, err := NewPostgresSearchBackend(db).Search(&models.ProjectSearchRequest{
+		Query: "wind farm", Country: []string{"India"}, Limit: 10, Offset: 20,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Thirteen facet queries, then the count and the page
+	if len(recorder.statements) != 15 {
+		t.Fatalf("expected 15 queries, got %q", recorder.statements)
+	}
+	count, page := recorder.statements[13], recorder.statements[14]
+	for _, sql := range recorder.statements {
+		if !strings.Contains(sql, "status = 'published'") || !strings.Contains(sql, "search_vector @@ websearch_to_tsquery('english', 'wind farm')") {
+			t.Errorf("expected the query to be matched in %s", sql)
+		}
+	}
+	if !strings.Contains(count, "count(*)") {
+		t.Errorf("expected a count query, got %s", count)
+	}
+	for _, want := range []string{"ts_rank_cd(search_vector", "ts_headline('english', title", "ORDER BY score DESC, id", "LIMIT 11 OFFSET 20"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in %s", want, page)
+		}
+	}
+}
+
+func TestPostgresFacetSQL(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	NewPostgresSearchBackend(db).Search(&models.ProjectSearchRequest{
+		Country: []string{"India"}, Vintage: []int{2021}, MinPrice: 5, CreditType: []string{"removal"},
+		SDGGoals: []int{13, 15}, MinPermanenceYears: 100, MaxBufferPoolPercent: 20, Limit: 10,
+	})
+
+	tests := []struct {
+		name       string
+		sql        string
+		want       []string
+		withoutOwn string
+	}{
+		{"category", recorder.statements[0], []string{"GROUP BY \"category\"", "ORDER BY count DESC, value LIMIT 50", "country IN ('India')"}, ""},
+		{"country", recorder.statements[2], []string{"GROUP BY \"country\"", "price_per_tonne >= 5"}, "country IN"},
+		{"methodology", recorder.statements[4], []string{"GROUP BY \"methodology_id\"", "methodology_id <> ''", "credit_type IN ('removal')"}, ""},
+		{"credit type", recorder.statements[5], []string{"GROUP BY \"credit_type\"", "permanence_years >= 100"}, "credit_type IN"},
+		{"vintage", recorder.statements[7], []string{`FROM "vintages" WHERE project_id IN (SELECT "id" FROM "projects"`, `GROUP BY "year"`, "price_per_tonne >= 5"}, "vintages.year IN"},
+		{"price", recorder.statements[8], []string{"floor(price_per_tonne / 5)", "vintages.year IN (2021)"}, "price_per_tonne >="},
+		{"sdg", recorder.statements[9], []string{"jsonb_array_elements_text(matched.sdg_goals) AS goal", `GROUP BY "goal"`, "buffer_pool_percent <= 20"}, "goal::int IN"},
+		{"capacity", recorder.statements[10], []string{"WHEN available_capacity >= 100000 THEN 3", "price_per_tonne >= 5", "goal::int IN (13,15)"}, ""},
+		{"permanence", recorder.statements[11], []string{"WHEN permanence_years >= 100 THEN 2", "buffer_pool_percent <= 20"}, "AND permanence_years >= 100"},
+		{"buffer pool", recorder.statements[12], []string{"WHEN buffer_pool_percent >= 20 THEN 2", "permanence_years >= 100"}, "buffer_pool_percent <="},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, want := range tt.want {
+				if !strings.Contains(tt.sql, want) {
+					t.Errorf("expected %q in %s", want, tt.sql)
+				}
+			}
+			if tt.withoutOwn != "" && strings.Contains(tt.sql, tt.withoutOwn) {
+				t.Errorf("expected the facet's own filter to be left out of %s", tt.sql)
+			}
+		})
+	}
+}
+
+func TestPostgresSearchWithoutQuery(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	NewPostgresSearchBackend(db).Search(&models.ProjectSearchRequest{Category: []string{"energy"}, Limit: 10})
+
+	page := recorder.statements[len(recorder.statements)-1]
+	if strings.Contains(page, "search_vector") || !strings.Contains(page, `ORDER BY id`) {
+		t.Errorf("expected a filter-only query ordered by ID, got %s", page)
+	}
+}
+
+func TestPostgresSearchCursorSQL(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   models.ProjectSearchRequest
+		after cursor
+		want  []string
+	}{
+		{
+			"relevance",
+			models.ProjectSearchRequest{Query: "wind", Limit: 10, Offset: 20},
+			cursor{Sort: models.SortRelevance, Value: 0.5, ID: 7},
+			[]string{"(ts_rank_cd(search_vector, websearch_to_tsquery('english', 'wind')) < 0.5 OR (ts_rank_cd(search_vector, websearch_to_tsquery('english', 'wind')) = 0.5 AND id > 7))", "ORDER BY score DESC, id LIMIT 11"},
+		},
+		{
+			"price",
+			models.ProjectSearchRequest{Sort: models.SortPriceAsc, Limit: 10},
+			cursor{Sort: models.SortPriceAsc, Value: 12.5, ID: 3},
+			[]string{"(price_per_tonne > 12.5 OR (price_per_tonne = 12.5 AND id > 3))", "ORDER BY price_per_tonne ASC, id LIMIT 11"},
+		},
+		{
+			"filter-only relevance",
+			models.ProjectSearchRequest{Limit: 10},
+			cursor{Sort: models.SortRelevance, ID: 3},
+			[]string{"id > 3", "ORDER BY id LIMIT 11"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, recorder := dryRunDB(t)
+			tt.req.Cursor = tt.after.encode()
+			if _, err := NewPostgresSearchBackend(db).Search(&tt.req); err != nil {
+				t.Fatal(err)
+			}
+
+			page := recorder.statements[len(recorder.statements)-1]
+			for _, want := range tt.want {
+				if !strings.Contains(page, want) {
+					t.Errorf("expected %q in %s", want, page)
+				}
+			}
+			if strings.Contains(page, "OFFSET") {
+				t.Errorf("expected the cursor to replace the offset in %s", page)
+			}
+		})
+	}
+}
+
+func TestPostgresListSQL(t *testing.T) {
+	db, recorder := dryRunDB(t)
+	createdAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	after := cursor{Sort: models.SortNewest, Value: float64(createdAt.UnixMicro()), ID: 4}
+
+	_, err := NewPostgresProjectRepository(db).GetAll(&models.ProjectListRequest{Cursor: after.encode(), Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.statements) != 2 || !strings.Contains(recorder.statements[0], "count(*)") {
+		t.Fatalf("expected a count and a page query, got %q", recorder.statements)
+	}
+	page := recorder.statements[1]
+	for _, want := range []string{"status = 'published'", "(created_at < '2026-03-01 12:00:00' OR (created_at = '2026-03-01 12:00:00' AND id > 4))", "ORDER BY created_at DESC, id LIMIT 6"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in %s", want, page)
+		}
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	got := highlights("Gujarat <em>Wind</em> Farm", "no match here ... turbines driven by <em>wind</em>")
+	if len(got["title"]) != 1 || len(got["description"]) != 1 || got["description"][0] != "turbines driven by <em>wind</em>" {
+		t.Errorf("unexpected highlights: %v", got)
+	}
+	if highlights("Gujarat Wind Farm", "Grid-connected") != nil {
+		t.Error("expected no highlights without matches")
+	}
+}
+
+func TestPostgresGeoFilterSQL(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	NewPostgresSearchBackend(db).Search(&models.ProjectSearchRequest{
+		BBox:    &models.GeoBox{MinLon: 170, MinLat: -20, MaxLon: -170, MaxLat: -10},
+		Near:    &models.GeoCircle{Lat: 0, Lon: 36.8, RadiusKm: 111.19508},
+		Located: true,
+		Limit:   10,
+	})
+
+	page := recorder.statements[len(recorder.statements)-1]
+	for _, want := range []string{
+		"latitude IS NOT NULL AND longitude IS NOT NULL",
+		"(point(longitude, latitude) <@ box(point(170, -20), point(180, -10)) OR point(longitude, latitude) <@ box(point(-180, -20), point(-170, -10)))",
+		"point(longitude, latitude) <@ box(point(35.8",
+		"2 * 6371.0088 * asin(sqrt(",
+		"<= 111.19508",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected %q in %s", want, page)
+		}
+	}
+}
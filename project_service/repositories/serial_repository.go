@@ -0,0 +1,210 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSerialRange is returned for a serial block that ends before it
+	// starts
+	ErrSerialRange = errors.New("serial block ends before it starts")
+	// ErrSerialOverlap is returned for a serial block sharing serials with
+	// one already issued under the same prefix
+	ErrSerialOverlap = errors.New("serials overlap a block already issued")
+	// ErrInsufficientSerials is returned when a project has fewer
+	// available serials than an allocation asks for
+	ErrInsufficientSerials = errors.New("not enough available serials")
+)
+
+// SerialRepository records the registry serial blocks issued for project
+// vintages and allocates them to orders. Missing projects and vintages,
+// including a vintage of another project, return gorm.ErrRecordNotFound.
+type SerialRepository interface {
+	// Issue records a block of serials for a vintage of a project
+	Issue(projectID uint, block *models.SerialBlock) error
+	// List returns a project's blocks in serial order, only those in the
+	// status when one is given
+	List(projectID uint, status string) ([]models.SerialBlock, error)
+	// Allocate allocates quantity serials of a project, of one vintage
+	// when vintageID is not zero, to an order: oldest vintage and lowest
+	// serials first, splitting the last block it takes from. It is
+	// idempotent, returning the blocks an order already has and false.
+	Allocate(orderID string, projectID, vintageID uint, quantity int64) ([]models.SerialBlock, bool, error)
+	// ByOrder returns the blocks allocated to an order, none if it has
+	// none. An order's lines are allocated under <orderID>-<line>, so these
+	// are the blocks of orderID and of each of its lines.
+	ByOrder(orderID string) ([]models.SerialBlock, error)
+	// BySerial returns the block holding a serial
+	BySerial(prefix string, number int64) (*models.SerialBlock, error)
+}
+
+// PostgresSerialRepository keeps serial blocks in their own table.
+// Allocations lock the project row, so concurrent allocations for a project
+// take turns and a serial is never allocated twice.
+type PostgresSerialRepository struct {
+	db  *gorm.DB
+	now func() time.Time
+}
+
+func NewPostgresSerialRepository(db *gorm.DB) *PostgresSerialRepository {
+	return &PostgresSerialRepository{db: db, now: time.Now}
+}
+
+// Issue records a block of serials for a vintage of a project
+func (r *PostgresSerialRepository) Issue(projectID uint, block *models.SerialBlock) error {
+	block.ProjectID = projectID
+	if block.End < block.Start {
+		return ErrSerialRange
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := getVintage(tx, projectID, block.VintageID); err != nil {
+			return err
+		}
+
+		// Blocks under one prefix are issued one at a time, so two
+		// overlapping blocks cannot both pass the check
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", block.Prefix).Error; err != nil {
+			return err
+		}
+		var overlapping int64
+		if err := tx.Model(&models.SerialBlock{}).
+			Where("prefix = ? AND serial_start <= ? AND serial_end >= ?", block.Prefix, block.End, block.Start).
+			Count(&overlapping).Error; err != nil {
+			return err
+		}
+		if overlapping > 0 {
+			return ErrSerialOverlap
+		}
+		return tx.Create(block).Error
+	})
+}
+
+// List returns a project's blocks in serial order
+func (r *PostgresSerialRepository) List(projectID uint, status string) ([]models.SerialBlock, error) {
+	if err := projectExists(r.db, projectID); err != nil {
+		return nil, err
+	}
+
+	query := r.db.Where("project_id = ?", projectID)
+	switch status {
+	case models.SerialAvailable:
+		query = query.Where("order_id = ''")
+	case models.SerialAllocated:
+		query = query.Where("order_id <> ''")
+	}
+	blocks := []models.SerialBlock{}
+	if err := query.Order("prefix, serial_start").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// Allocate allocates serials of a project to an order
+func (r *PostgresSerialRepository) Allocate(orderID string, projectID, vintageID uint, quantity int64) ([]models.SerialBlock, bool, error) {
+	var allocated []models.SerialBlock
+	created := true
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&project, projectID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("order_id = ?", orderID).Order("prefix, serial_start").Find(&allocated).Error; err != nil {
+			return err
+		}
+		if len(allocated) > 0 {
+			created = false
+			return nil
+		}
+
+		query := tx.Select("serial_blocks.*").
+			Joins("JOIN vintages ON vintages.id = serial_blocks.vintage_id").
+			Where("serial_blocks.project_id = ? AND serial_blocks.order_id = ''", projectID)
+		if vintageID != 0 {
+			query = query.Where("serial_blocks.vintage_id = ?", vintageID)
+		}
+		var available []models.SerialBlock
+		if err := query.Order("vintages.year, serial_blocks.prefix, serial_blocks.serial_start").
+			Find(&available).Error; err != nil {
+			return err
+		}
+
+		var remainder *models.SerialBlock
+		var err error
+		if allocated, remainder, err = takeSerials(available, orderID, quantity, r.now()); err != nil {
+			return err
+		}
+		for i := range allocated {
+			if err := tx.Save(&allocated[i]).Error; err != nil {
+				return err
+			}
+		}
+		if remainder != nil {
+			return tx.Create(remainder).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return allocated, created, nil
+}
+
+// ByOrder returns the blocks allocated to an order and its lines
+func (r *PostgresSerialRepository) ByOrder(orderID string) ([]models.SerialBlock, error) {
+	blocks := []models.SerialBlock{}
+	lines := likeEscaper.Replace(orderID) + "-%"
+	if err := r.db.Where("order_id = ? OR order_id LIKE ?", orderID, lines).Order("prefix, serial_start").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// BySerial returns the block holding a serial
+func (r *PostgresSerialRepository) BySerial(prefix string, number int64) (*models.SerialBlock, error) {
+	var block models.SerialBlock
+	if err := r.db.Where("prefix = ? AND serial_start <= ? AND serial_end >= ?", prefix, number, number).
+		First(&block).Error; err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// takeSerials allocates quantity serials from the available blocks, in
+// order, to an order. It returns the allocated blocks and, when the last
+// one was only partly needed, the rest of it split off as a new available
+// block.
+func takeSerials(available []models.SerialBlock, orderID string, quantity int64, now time.Time) ([]models.SerialBlock, *models.SerialBlock, error) {
+	var allocated []models.SerialBlock
+	var remainder *models.SerialBlock
+	for _, block := range available {
+		if quantity == 0 {
+			break
+		}
+		if block.Quantity() > quantity {
+			rest := block
+			rest.ID = 0
+			rest.Start = block.Start + quantity
+			remainder = &rest
+			block.End = block.Start + quantity - 1
+		}
+		quantity -= block.Quantity()
+		block.OrderID = orderID
+		block.AllocatedAt = &now
+		allocated = append(allocated, block)
+	}
+	if quantity > 0 {
+		return nil, nil, ErrInsufficientSerials
+	}
+	return allocated, remainder, nil
+}
@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"project_service/models"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemorySerialRepository is a SerialRepository over an
+// InMemoryProjectRepository, whose lock also guards the blocks
+type InMemorySerialRepository struct {
+	projects *InMemoryProjectRepository
+	blocks   []models.SerialBlock
+	nextID   uint
+	now      func() time.Time
+}
+
+func NewInMemorySerialRepository(projects *InMemoryProjectRepository) *InMemorySerialRepository {
+	return &InMemorySerialRepository{projects: projects, nextID: 1, now: time.Now}
+}
+
+// Issue records a block of serials for a vintage of a project
+func (r *InMemorySerialRepository) Issue(projectID uint, block *models.SerialBlock) error {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok || vintageIndex(project.Vintages, block.VintageID) < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	block.ProjectID = projectID
+	if block.End < block.Start {
+		return ErrSerialRange
+	}
+	for i := range r.blocks {
+		if r.blocks[i].Overlaps(block) {
+			return ErrSerialOverlap
+		}
+	}
+
+	block.ID = r.nextID
+	block.CreatedAt = r.now()
+	r.nextID++
+	r.blocks = append(r.blocks, *block)
+	return nil
+}
+
+// List returns a project's blocks in serial order
+func (r *InMemorySerialRepository) List(projectID uint, status string) ([]models.SerialBlock, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	if _, ok := r.projects.projects[projectID]; !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return r.find(func(b *models.SerialBlock) bool {
+		return b.ProjectID == projectID && (status == "" || b.Status() == status)
+	}), nil
+}
+
+// Allocate allocates serials of a project to an order
+func (r *InMemorySerialRepository) Allocate(orderID string, projectID, vintageID uint, quantity int64) ([]models.SerialBlock, bool, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return nil, false, gorm.ErrRecordNotFound
+	}
+	if allocated := r.find(func(b *models.SerialBlock) bool { return b.OrderID == orderID }); len(allocated) > 0 {
+		return allocated, false, nil
+	}
+
+	years := map[uint]int{}
+	for _, vintage := range project.Vintages {
+		years[vintage.ID] = vintage.Year
+	}
+	available := r.find(func(b *models.SerialBlock) bool {
+		return b.ProjectID == projectID && b.OrderID == "" && (vintageID == 0 || b.VintageID == vintageID)
+	})
+	sort.SliceStable(available, func(i, j int) bool { return years[available[i].VintageID] < years[available[j].VintageID] })
+
+	allocated, remainder, err := takeSerials(available, orderID, quantity, r.now())
+	if err != nil {
+		return nil, false, err
+	}
+	for _, block := range allocated {
+		for i := range r.blocks {
+			if r.blocks[i].ID == block.ID {
+				r.blocks[i] = block
+			}
+		}
+	}
+	if remainder != nil {
+		remainder.ID = r.nextID
+		r.nextID++
+		r.blocks = append(r.blocks, *remainder)
+	}
+	return allocated, true, nil
+}
+
+// ByOrder returns the blocks allocated to an order and its lines
+func (r *InMemorySerialRepository) ByOrder(orderID string) ([]models.SerialBlock, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	return r.find(func(b *models.SerialBlock) bool {
+		return b.OrderID == orderID || strings.HasPrefix(b.OrderID, orderID+"-")
+	}), nil
+}
+
+// BySerial returns the block holding a serial
+func (r *InMemorySerialRepository) BySerial(prefix string, number int64) (*models.SerialBlock, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	blocks := r.find(func(b *models.SerialBlock) bool { return b.Contains(prefix, number) })
+	if len(blocks) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &blocks[0], nil
+}
+
+// find returns copies of the matching blocks in serial order. The caller
+// holds the lock.
+func (r *InMemorySerialRepository) find(match func(b *models.SerialBlock) bool) []models.SerialBlock {
+	blocks := []models.SerialBlock{}
+	for i := range r.blocks {
+		if match(&r.blocks[i]) {
+			blocks = append(blocks, r.blocks[i])
+		}
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Prefix != blocks[j].Prefix {
+			return blocks[i].Prefix < blocks[j].Prefix
+		}
+		return blocks[i].Start < blocks[j].Start
+	})
+	return blocks
+}
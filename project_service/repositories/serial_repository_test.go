@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newSerialRepo returns a serial repository over a project with a 2020 and
+// a 2021 vintage, the 2021 one created first
+func newSerialRepo(t *testing.T) *InMemorySerialRepository {
+	t.Helper()
+	repo, projects := newVintageRepo(t)
+	for _, year := range []int{2021, 2020} {
+		if err := repo.Create(1, &models.Vintage{Year: year, PricePerTonne: 10}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewInMemorySerialRepository(projects)
+}
+
+func issue(t *testing.T, repo *InMemorySerialRepository, vintageID uint, prefix string, start, end int64) {
+	t.Helper()
+	if err := repo.Issue(1, &models.SerialBlock{VintageID: vintageID, Registry: "Verra", Prefix: prefix, Start: start, End: end}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSerialIssue(t *testing.T) {
+	repo := newSerialRepo(t)
+	issue(t, repo, 1, "VCS-981-2021", 1001, 1100)
+
+	for _, tt := range []struct {
+		name  string
+		block models.SerialBlock
+		want  error
+	}{
+		{"overlapping", models.SerialBlock{VintageID: 2, Prefix: "VCS-981-2021", Start: 1100, End: 1200}, ErrSerialOverlap},
+		{"reversed", models.SerialBlock{VintageID: 2, Prefix: "VCS-981-2020", Start: 20, End: 10}, ErrSerialRange},
+		{"another project's vintage", models.SerialBlock{VintageID: 9, Prefix: "VCS-981-2020", Start: 1, End: 10}, gorm.ErrRecordNotFound},
+	} {
+		if err := repo.Issue(1, &tt.block); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+	// The same numbers under another prefix are other serials
+	issue(t, repo, 2, "VCS-981-2020", 1001, 1100)
+}
+
+func TestSerialAllocateSplitsBlocks(t *testing.T) {
+	repo := newSerialRepo(t)
+	issue(t, repo, 1, "VCS-981-2021", 1, 100)
+	issue(t, repo, 2, "VCS-981-2020", 1, 30)
+
+	// The 2020 vintage goes first, then part of the 2021 block
+	blocks, created, err := repo.Allocate("order-1", 1, 0, 50)
+	if err != nil || !created {
+		t.Fatalf("expected an allocation, got %v", err)
+	}
+	if len(blocks) != 2 || blocks[0].Serials() != "VCS-981-2020-1-30" || blocks[1].Serials() != "VCS-981-2021-1-20" {
+		t.Fatalf("unexpected blocks: %+v", blocks)
+	}
+
+	available, _ := repo.List(1, models.SerialAvailable)
+	if len(available) != 1 || available[0].Serials() != "VCS-981-2021-21-100" {
+		t.Fatalf("expected the rest of the 2021 block split off, got %+v", available)
+	}
+
+	// Allocating again for the order returns what it has
+	again, created, err := repo.Allocate("order-1", 1, 0, 50)
+	if err != nil || created || len(again) != 2 {
+		t.Errorf("expected the order's blocks back, got %+v %v %v", again, created, err)
+	}
+
+	if _, _, err := repo.Allocate("order-2", 1, 2, 1); !errors.Is(err, ErrInsufficientSerials) {
+		t.Errorf("expected the used-up vintage to have no serials, got %v", err)
+	}
+	if _, _, err := repo.Allocate("order-2", 1, 0, 81); !errors.Is(err, ErrInsufficientSerials) {
+		t.Errorf("expected too few serials, got %v", err)
+	}
+	if available, _ := repo.List(1, models.SerialAvailable); len(available) != 1 || available[0].Quantity() != 80 {
+		t.Errorf("expected a failed allocation to take nothing, got %+v", available)
+	}
+}
+
+func TestSerialLookups(t *testing.T) {
+	repo := newSerialRepo(t)
+	issue(t, repo, 1, "VCS-981-2021", 1, 100)
+	repo.Allocate("order-1", 1, 0, 10)
+
+	block, err := repo.BySerial("VCS-981-2021", 7)
+	if err != nil || block.OrderID != "order-1" {
+		t.Errorf("expected serial 7 in order-1, got %+v %v", block, err)
+	}
+	if block, _ := repo.BySerial("VCS-981-2021", 11); block.OrderID != "" {
+		t.Errorf("expected serial 11 available, got %+v", block)
+	}
+	if _, err := repo.BySerial("VCS-981-2021", 101); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected an unissued serial not found, got %v", err)
+	}
+
+	blocks, _ := repo.ByOrder("order-1")
+	if len(blocks) != 1 || blocks[0].Quantity() != 10 {
+		t.Errorf("expected the order's 10 serials, got %+v", blocks)
+	}
+	if blocks, _ := repo.ByOrder("order-9"); len(blocks) != 0 {
+		t.Errorf("expected no serials for an unknown order, got %+v", blocks)
+	}
+
+	// An order's lines are allocated under <orderID>-<line>
+	repo.Allocate("order-2-1", 1, 0, 5)
+	repo.Allocate("order-2-2", 1, 0, 3)
+	repo.Allocate("order-20-1", 1, 0, 1)
+	if blocks, _ := repo.ByOrder("order-2"); len(blocks) != 2 || blocks[0].OrderID != "order-2-1" || blocks[1].OrderID != "order-2-2" {
+		t.Errorf("expected the blocks of both lines of order-2, got %+v", blocks)
+	}
+}
@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrDuplicateVintage is returned when a project already has a vintage
+	// of the year
+	ErrDuplicateVintage = errors.New("project already has a vintage of that year")
+	// ErrVintageCapacity is returned when a vintage would have more
+	// available than total tonnes
+	ErrVintageCapacity = errors.New("available capacity exceeds total capacity")
+)
+
+// VintageRepository persists the vintages of projects. Missing projects
+// and vintages, including a vintage of another project, return
+// gorm.ErrRecordNotFound. Every write touches the project and re-indexes
+// it, so search documents carry its vintages.
+type VintageRepository interface {
+	// List returns a project's vintages by year
+	List(projectID uint) ([]models.Vintage, error)
+	Get(projectID, vintageID uint) (*models.Vintage, error)
+	Create(projectID uint, vintage *models.Vintage) error
+	// Update changes the non-zero fields of a vintage
+	Update(projectID, vintageID uint, req *models.UpdateVintageRequest) (*models.Vintage, error)
+	Delete(projectID, vintageID uint) error
+}
+
+// byYear orders preloaded vintages
+func byYear(db *gorm.DB) *gorm.DB {
+	return db.Order("year")
+}
+
+// PostgresVintageRepository keeps vintages in their own table
+type PostgresVintageRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresVintageRepository(db *gorm.DB) *PostgresVintageRepository {
+	return &PostgresVintageRepository{db: db}
+}
+
+// List returns a project's vintages by year
+func (r *PostgresVintageRepository) List(projectID uint) ([]models.Vintage, error) {
+	if err := projectExists(r.db, projectID); err != nil {
+		return nil, err
+	}
+	vintages := []models.Vintage{}
+	if err := byYear(r.db).Where("project_id = ?", projectID).Find(&vintages).Error; err != nil {
+		return nil, err
+	}
+	return vintages, nil
+}
+
+// Get retrieves a vintage of a project
+func (r *PostgresVintageRepository) Get(projectID, vintageID uint) (*models.Vintage, error) {
+	return getVintage(r.db, projectID, vintageID)
+}
+
+// Create adds a vintage to a project
+func (r *PostgresVintageRepository) Create(projectID uint, vintage *models.Vintage) error {
+	vintage.ProjectID = projectID
+	if vintage.AvailableCapacity > vintage.TotalCapacity {
+		return ErrVintageCapacity
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := touchProject(tx, projectID); err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&models.Vintage{}).
+			Where("project_id = ? AND year = ?", projectID, vintage.Year).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrDuplicateVintage
+		}
+		return tx.Create(vintage).Error
+	})
+}
+
+// Update changes the non-zero fields of a vintage
+func (r *PostgresVintageRepository) Update(projectID, vintageID uint, req *models.UpdateVintageRequest) (*models.Vintage, error) {
+	var vintage *models.Vintage
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if vintage, err = getVintage(tx, projectID, vintageID); err != nil {
+			return err
+		}
+		applyVintageUpdate(vintage, req)
+		if vintage.AvailableCapacity > vintage.TotalCapacity {
+			return ErrVintageCapacity
+		}
+		if err := tx.Save(vintage).Error; err != nil {
+			return err
+		}
+		return touchProject(tx, projectID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vintage, nil
+}
+
+// Delete removes a vintage of a project
+func (r *PostgresVintageRepository) Delete(projectID, vintageID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("project_id = ?", projectID).Delete(&models.Vintage{}, vintageID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return touchProject(tx, projectID)
+	})
+}
+
+func getVintage(db *gorm.DB, projectID, vintageID uint) (*models.Vintage, error) {
+	var vintage models.Vintage
+	if err := db.Where("project_id = ?", projectID).First(&vintage, vintageID).Error; err != nil {
+		return nil, err
+	}
+	return &vintage, nil
+}
+
+// projectExists returns gorm.ErrRecordNotFound for a missing project
+func projectExists(db *gorm.DB, projectID uint) error {
+	var project models.Project
+	return db.Select("id").First(&project, projectID).Error
+}
+
+// touchProject bumps a project's updated_at, so a search document without
+// the change shows as stale, and adds its updated event to the outbox
+func touchProject(tx *gorm.DB, projectID uint) error {
+	now := time.Now()
+	result := tx.Model(&models.Project{}).Where("id = ?", projectID).Update("updated_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return recordProjectEvent(tx, models.EventProjectUpdated, projectID, nil, now)
+}
+
+func applyVintageUpdate(vintage *models.Vintage, req *models.UpdateVintageRequest) {
+	setFloat(&vintage.PricePerTonne, req.PricePerTonne)
+	setFloat(&vintage.TotalCapacity, req.TotalCapacity)
+	setFloat(&vintage.AvailableCapacity, req.AvailableCapacity)
+	if req.IssuanceDate != nil {
+		vintage.IssuanceDate = req.IssuanceDate
+	}
+}
+
+// vintagesOf loads the vintages of projects by project ID, each by year
+func vintagesOf(db *gorm.DB, projectIDs []uint) (map[uint][]models.Vintage, error) {
+	result := map[uint][]models.Vintage{}
+	if len(projectIDs) == 0 {
+		return result, nil
+	}
+	var vintages []models.Vintage
+	if err := byYear(db).Where("project_id IN ?", projectIDs).Find(&vintages).Error; err != nil {
+		return nil, err
+	}
+	for _, vintage := range vintages {
+		result[vintage.ProjectID] = append(result[vintage.ProjectID], vintage)
+	}
+	return result, nil
+}
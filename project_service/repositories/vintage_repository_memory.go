@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"project_service/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryVintageRepository is a VintageRepository over an
+// InMemoryProjectRepository, keeping each project's vintages on the
+// project itself
+type InMemoryVintageRepository struct {
+	projects *InMemoryProjectRepository
+	nextID   uint
+}
+
+func NewInMemoryVintageRepository(projects *InMemoryProjectRepository) *InMemoryVintageRepository {
+	return &InMemoryVintageRepository{projects: projects, nextID: 1}
+}
+
+// List returns a project's vintages by year
+func (r *InMemoryVintageRepository) List(projectID uint) ([]models.Vintage, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return append([]models.Vintage{}, project.Vintages...), nil
+}
+
+// Get retrieves a vintage of a project
+func (r *InMemoryVintageRepository) Get(projectID, vintageID uint) (*models.Vintage, error) {
+	r.projects.mu.RLock()
+	defer r.projects.mu.RUnlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	i := vintageIndex(project.Vintages, vintageID)
+	if i < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	vintage := project.Vintages[i]
+	return &vintage, nil
+}
+
+// Create adds a vintage to a project
+func (r *InMemoryVintageRepository) Create(projectID uint, vintage *models.Vintage) error {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if vintage.AvailableCapacity > vintage.TotalCapacity {
+		return ErrVintageCapacity
+	}
+	for _, existing := range project.Vintages {
+		if existing.Year == vintage.Year {
+			return ErrDuplicateVintage
+		}
+	}
+
+	now := time.Now()
+	vintage.ID = r.nextID
+	vintage.ProjectID = projectID
+	vintage.CreatedAt = now
+	vintage.UpdatedAt = now
+	r.nextID++
+
+	vintages := append(append([]models.Vintage{}, project.Vintages...), *vintage)
+	sort.Slice(vintages, func(i, j int) bool { return vintages[i].Year < vintages[j].Year })
+	return r.save(project, vintages)
+}
+
+// Update changes the non-zero fields of a vintage
+func (r *InMemoryVintageRepository) Update(projectID, vintageID uint, req *models.UpdateVintageRequest) (*models.Vintage, error) {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	i := vintageIndex(project.Vintages, vintageID)
+	if i < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	vintage := project.Vintages[i]
+	applyVintageUpdate(&vintage, req)
+	if vintage.AvailableCapacity > vintage.TotalCapacity {
+		return nil, ErrVintageCapacity
+	}
+	vintage.UpdatedAt = time.Now()
+
+	vintages := append([]models.Vintage{}, project.Vintages...)
+	vintages[i] = vintage
+	return &vintage, r.save(project, vintages)
+}
+
+// Delete removes a vintage of a project
+func (r *InMemoryVintageRepository) Delete(projectID, vintageID uint) error {
+	r.projects.mu.Lock()
+	defer r.projects.mu.Unlock()
+
+	project, ok := r.projects.projects[projectID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	i := vintageIndex(project.Vintages, vintageID)
+	if i < 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	vintages := append(append([]models.Vintage{}, project.Vintages[:i]...), project.Vintages[i+1:]...)
+	return r.save(project, vintages)
+}
+
+// save stores a project's new vintages, touching and re-indexing it and
+// adding its updated event to the outbox. The caller holds the lock, and passes a fresh slice so projects already
+// handed out keep their vintages.
+func (r *InMemoryVintageRepository) save(project models.Project, vintages []models.Vintage) error {
+	project.Vintages = vintages
+	project.UpdatedAt = time.Now()
+	r.projects.projects[project.ID] = project
+	r.projects.recordEvent(models.EventProjectUpdated, &project, nil, project.UpdatedAt)
+	return r.projects.search.Index(&project)
+}
+
+func vintageIndex(vintages []models.Vintage, id uint) int {
+	for i := range vintages {
+		if vintages[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"errors"
+	"project_service/models"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// newVintageRepo returns a vintage repository over two active projects
+func newVintageRepo(t *testing.T) (*InMemoryVintageRepository, *InMemoryProjectRepository) {
+	t.Helper()
+	projects := NewInMemoryProjectRepository()
+	for _, title := range []string{"Mangrove Restoration", "Peatland Rewetting"} {
+		if err := projects.Create(&models.Project{Title: title, Category: "forestry", Status: models.StatusPublished}, models.Change{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewInMemoryVintageRepository(projects), projects
+}
+
+func TestVintageCreate(t *testing.T) {
+	repo, projects := newVintageRepo(t)
+
+	for _, year := range []int{2022, 2020} {
+		if err := repo.Create(1, &models.Vintage{Year: year, PricePerTonne: 10, TotalCapacity: 100, AvailableCapacity: 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.Create(1, &models.Vintage{Year: 2020, PricePerTonne: 10}); !errors.Is(err, ErrDuplicateVintage) {
+		t.Errorf("expected a duplicate vintage, got %v", err)
+	}
+	if err := repo.Create(1, &models.Vintage{Year: 2021, TotalCapacity: 10, AvailableCapacity: 11}); !errors.Is(err, ErrVintageCapacity) {
+		t.Errorf("expected a capacity error, got %v", err)
+	}
+	if err := repo.Create(9, &models.Vintage{Year: 2021}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a missing project, got %v", err)
+	}
+	// Another project may have a vintage of the same year
+	if err := repo.Create(2, &models.Vintage{Year: 2020, PricePerTonne: 20}); err != nil {
+		t.Errorf("expected the second project's 2020 vintage, got %v", err)
+	}
+
+	vintages, err := repo.List(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vintages) != 2 || vintages[0].Year != 2020 || vintages[1].Year != 2022 || vintages[0].ProjectID != 1 {
+		t.Errorf("expected the 2020 and 2022 vintages by year, got %+v", vintages)
+	}
+
+	project, _ := projects.GetByID(1)
+	if len(project.Vintages) != 2 {
+		t.Errorf("expected the project to carry its vintages, got %+v", project.Vintages)
+	}
+}
+
+func TestVintageUpdateAndDelete(t *testing.T) {
+	repo, projects := newVintageRepo(t)
+	vintage := &models.Vintage{Year: 2021, PricePerTonne: 10, TotalCapacity: 100, AvailableCapacity: 50}
+	if err := repo.Create(1, vintage); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := projects.GetByID(1)
+
+	updated, err := repo.Update(1, vintage.ID, &models.UpdateVintageRequest{PricePerTonne: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.PricePerTonne != 14 || updated.AvailableCapacity != 50 || updated.Year != 2021 {
+		t.Errorf("expected only the price to change, got %+v", updated)
+	}
+	if before.Vintages[0].PricePerTonne != 10 {
+		t.Errorf("expected a project handed out earlier to keep its vintages, got %+v", before.Vintages)
+	}
+
+	if _, err := repo.Update(1, vintage.ID, &models.UpdateVintageRequest{AvailableCapacity: 101}); !errors.Is(err, ErrVintageCapacity) {
+		t.Errorf("expected a capacity error, got %v", err)
+	}
+	if _, err := repo.Get(2, vintage.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected another project's vintage to be missing, got %v", err)
+	}
+
+	if err := repo.Delete(1, vintage.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(1, vintage.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected a second delete to miss, got %v", err)
+	}
+	if vintages, _ := repo.List(1); len(vintages) != 0 {
+		t.Errorf("expected no vintages left, got %+v", vintages)
+	}
+}
+
+func TestVintageSearch(t *testing.T) {
+	repo, projects := newVintageRepo(t)
+	repo.Create(1, &models.Vintage{Year: 2020, PricePerTonne: 10})
+	repo.Create(1, &models.Vintage{Year: 2021, PricePerTonne: 10})
+	repo.Create(2, &models.Vintage{Year: 2021, PricePerTonne: 10})
+
+	result, err := projects.Search(&models.ProjectSearchRequest{Vintage: []int{2020}, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hitIDs(result), []uint{1}) {
+		t.Errorf("expected only project 1 to have a 2020 vintage, got %v", hitIDs(result))
+	}
+	if len(result.Hits[0].Vintages) != 2 {
+		t.Errorf("expected the hit to carry its vintages, got %+v", result.Hits[0].Vintages)
+	}
+
+	// The vintage facet leaves out its own filter and counts each project
+	// once per year
+	want := []models.FacetCount{{Value: "2021", Count: 2}, {Value: "2020", Count: 1}}
+	if !reflect.DeepEqual(result.Facets.Vintage, want) {
+		t.Errorf("expected vintage counts %+v, got %+v", want, result.Facets.Vintage)
+	}
+}
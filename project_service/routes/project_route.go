@@ -1,8 +1,10 @@
 package routes
 
 import (
-	"os"
+	"fmt"
+	"project_service/config"
 	"project_service/handlers"
+	"project_service/models"
 
 	"github.com/golang-jwt/jwt/v5"
 	echojwt "github.com/labstack/echo-jwt/v4"
@@ -10,16 +12,7 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
-type JwtCustomClaims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
-	jwt.RegisteredClaims
-}
-
-func ProjectRoute(e *echo.Echo) {
-	// Create handler instance
-	projectHandler := handlers.NewProjectHandler()
-
+func ProjectRoute(e *echo.Echo, projectHandler *handlers.ProjectHandler, searchHandler *handlers.SearchAdminHandler, reservationHandler *handlers.ReservationHandler, vintageHandler *handlers.VintageHandler, pricingHandler *handlers.PricingHandler, documentHandler *handlers.DocumentHandler, catalogHandler *handlers.CatalogHandler, serialHandler *handlers.SerialHandler, developerHandler *handlers.DeveloperHandler, monitoringHandler *handlers.MonitoringHandler, jwtConfig config.JWTConfig) {
 	// Middleware
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
@@ -31,24 +24,129 @@ func ProjectRoute(e *echo.Echo) {
 	// Project routes
 	projects := api.Group("/projects")
 
-	// Public routes (no authentication required)
-	projects.GET("", projectHandler.GetAllProjects)                  // Browse Projects
-	projects.GET("/:id", projectHandler.GetProject)                  // View Project Details
-	projects.POST("/search", projectHandler.SearchProjects)          // Filter & Search Projects
-	projects.GET("/categories", projectHandler.GetProjectCategories) // Get available categories
-	projects.GET("/regions", projectHandler.GetProjectRegions)       // Get available regions
-	projects.GET("/countries", projectHandler.GetProjectCountries)   // Get available countries
+	// Public routes (no authentication required). The routes under a
+	// project answer for an unreleased one only to an admin or the
+	// project's developer, who may send their token along. A download is
+	// authorized by its signed URL, which only they can list.
+	released := []echo.MiddlewareFunc{echojwt.WithConfig(optionalCaller(jwtConfig)), projectHandler.RequireReleased}
+
+	projects.GET("", projectHandler.GetAllProjects)                                       // Browse Projects
+	projects.GET("/:id", projectHandler.GetProject)                                       // View Project Details
+	projects.GET("/:id/vintages", vintageHandler.ListVintages, released...)               // View Project Vintages
+	projects.GET("/:id/pricing", pricingHandler.GetPricing, released...)                  // View Project Pricing
+	projects.GET("/:id/quote", pricingHandler.GetQuote, released...)                      // Price a tonnage
+	projects.GET("/:id/documents", documentHandler.ListDocuments, released...)            // View Project Documents
+	projects.GET("/:id/documents/:documentID/download", documentHandler.DownloadDocument) // Signed download
+	projects.GET("/:id/mrv", monitoringHandler.GetMRVSeries)                              // MRV chart data
+	projects.GET("/serials/orders/:orderID", serialHandler.GetOrderSerials)               // Serials of an order
+	projects.GET("/serials/:serial", serialHandler.LookupSerial)                          // Block and order of a serial
+	projects.POST("/search", projectHandler.SearchProjects)                               // Filter & Search Projects
+	projects.GET("/geojson", projectHandler.GetProjectsGeoJSON)                           // Projects on a map
+	projects.GET("/categories", projectHandler.GetProjectCategories)                      // Get available categories
+	projects.GET("/regions", projectHandler.GetProjectRegions)                            // Get available regions
+	projects.GET("/countries", projectHandler.GetProjectCountries)                        // Get available countries
 
 	// Admin routes (authentication required)
 	admin := projects.Group("/admin")
 	admin.Use(echojwt.WithConfig(echojwt.Config{
-		SigningKey: []byte(os.Getenv("ADMIN_JWT_SECRET")),
+		SigningKey: []byte(jwtConfig.AdminSecret),
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
-			return new(JwtCustomClaims)
+			return new(handlers.JwtCustomClaims)
 		},
 	}))
 
-	admin.POST("", projectHandler.CreateProject)       // Create new project
-	admin.PUT("/:id", projectHandler.UpdateProject)    // Update project
-	admin.DELETE("/:id", projectHandler.DeleteProject) // Delete project
+	admin.POST("", projectHandler.CreateProject)                                  // Create new project
+	admin.GET("/:id", projectHandler.GetProjectAdmin)                             // View project in any status
+	admin.PUT("/:id", projectHandler.UpdateProject)                               // Update project
+	admin.PATCH("/:id", projectHandler.PatchProject)                              // Merge patch project fields
+	admin.DELETE("/:id", projectHandler.DeleteProject)                            // Retire project
+	admin.POST("/:id/transitions", projectHandler.TransitionProject)              // Move project through its lifecycle
+	admin.GET("/:id/transitions", projectHandler.GetProjectTransitions)           // Lifecycle history of a project
+	admin.GET("/:id/revisions", projectHandler.GetProjectRevisions)               // Change history of a project
+	admin.GET("/:id/as-of", projectHandler.GetProjectAsOf)                        // Project as it was at a time
+	admin.POST("/:id/revisions/:revisionID/revert", projectHandler.RevertProject) // Restore a revision
+
+	admin.POST("/import", catalogHandler.ImportProjects) // Bulk create or update projects
+	admin.GET("/export", catalogHandler.ExportProjects)  // Stream the whole catalog
+
+	admin.POST("/search/reindex", searchHandler.ReindexProjects)     // Rebuild the search index
+	admin.GET("/search/consistency", searchHandler.CheckSearchIndex) // Compare the index with Postgres
+
+	admin.POST("/:id/vintages", vintageHandler.CreateVintage)              // Add a vintage
+	admin.GET("/:id/vintages/:vintageID", vintageHandler.GetVintage)       // View a vintage
+	admin.PUT("/:id/vintages/:vintageID", vintageHandler.UpdateVintage)    // Update a vintage
+	admin.DELETE("/:id/vintages/:vintageID", vintageHandler.DeleteVintage) // Delete a vintage
+
+	admin.PUT("/:id/pricing", pricingHandler.SetPricing)                                   // Set tiers and base price
+	admin.POST("/:id/pricing/schedules", pricingHandler.SchedulePrice)                     // Schedule a price change
+	admin.GET("/:id/pricing/schedules", pricingHandler.ListPriceSchedules)                 // View price schedules
+	admin.DELETE("/:id/pricing/schedules/:scheduleID", pricingHandler.CancelPriceSchedule) // Cancel a price schedule
+	admin.GET("/:id/pricing/history", pricingHandler.GetPriceHistory)                      // Price changes of a project
+
+	admin.POST("/:id/documents", documentHandler.UploadDocument)               // Upload a document or image
+	admin.DELETE("/:id/documents/:documentID", documentHandler.DeleteDocument) // Delete a document
+
+	admin.POST("/:id/serials", serialHandler.IssueSerials)            // Record an issued serial block
+	admin.GET("/:id/serials", serialHandler.ListSerials)              // Serial blocks of a project
+	admin.POST("/serials/allocations", serialHandler.AllocateSerials) // Allocate serials to an order
+
+	admin.POST("/:id/monitoring", monitoringHandler.SubmitPeriod) // Submit a monitoring period
+	admin.GET("/:id/monitoring", monitoringHandler.ListPeriods)   // Monitoring periods of a project
+
+	admin.POST("/reservations", reservationHandler.ReserveCapacity)                // Hold project capacity
+	admin.POST("/reservations/commit", reservationHandler.CommitReservations)      // Keep the capacity of several reservations
+	admin.GET("/reservations/:id", reservationHandler.GetReservation)              // View a reservation
+	admin.POST("/reservations/:id/commit", reservationHandler.CommitReservation)   // Keep the held capacity
+	admin.POST("/reservations/:id/release", reservationHandler.ReleaseReservation) // Return the held capacity
+	admin.GET("/:id/ledger", reservationHandler.GetCapacityLedger)                 // Capacity changes of a project
+	admin.GET("/:id/sales", reservationHandler.GetSales)                           // Committed sales of a project
+
+	// Developer routes (user token with the developer role required). A
+	// developer only reaches the projects linked to their account.
+	developer := projects.Group("/developer")
+	developer.Use(echojwt.WithConfig(echojwt.Config{
+		SigningKey: []byte(jwtConfig.UserSecret),
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(handlers.JwtCustomClaims)
+		},
+	}), developerHandler.RequireDeveloper)
+
+	developer.GET("", developerHandler.ListProjects)   // Own projects in every status
+	developer.POST("", developerHandler.CreateProject) // Draft an own project
+
+	owned := developer.Group("/:id", developerHandler.RequireOwner)
+	owned.GET("", projectHandler.GetProjectAdmin)                   // View own project in any status
+	owned.PUT("", developerHandler.UpdateProject)                   // Update description and media
+	owned.POST("/transitions", projectHandler.TransitionProject)    // Submit for review or revise
+	owned.GET("/transitions", projectHandler.GetProjectTransitions) // Lifecycle history of own project
+	owned.POST("/documents", documentHandler.UploadDocument)        // Upload an image or monitoring report
+	owned.GET("/documents", documentHandler.ListDocuments)          // Documents of own project
+	owned.GET("/sales", reservationHandler.GetSales)                // Sales of own project
+	owned.POST("/monitoring", monitoringHandler.SubmitPeriod)       // Submit a monitoring period
+	owned.GET("/monitoring", monitoringHandler.ListPeriods)         // Monitoring periods of own project
+}
+
+// optionalCaller identifies the caller of a public route from an admin or
+// user token when one is sent, and lets the request through without one
+func optionalCaller(jwtConfig config.JWTConfig) echojwt.Config {
+	return echojwt.Config{
+		NewClaimsFunc: func(c echo.Context) jwt.Claims {
+			return new(handlers.JwtCustomClaims)
+		},
+		// The role picks the secret the token must be signed with, so a
+		// user token cannot pass for an admin one
+		KeyFunc: func(token *jwt.Token) (interface{}, error) {
+			if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			if claims, ok := token.Claims.(*handlers.JwtCustomClaims); ok && claims.Role == models.RoleAdmin {
+				return []byte(jwtConfig.AdminSecret), nil
+			}
+			return []byte(jwtConfig.UserSecret), nil
+		},
+		ContinueOnIgnoredError: true,
+		ErrorHandler: func(c echo.Context, err error) error {
+			return nil
+		},
+	}
 }
@@ -0,0 +1,523 @@
+// Package search maintains the Elasticsearch projects index. Searches and
+// writes go through the "projects" alias, which always points at a single
+// versioned index so the catalog can be rebuilt without downtime.
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"project_service/models"
+	"project_service/repositories"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Alias is the name searches and writes use for the live index
+const Alias = "projects"
+
+// batchSize is the number of projects read from Postgres and sent to the
+// _bulk API at a time
+const batchSize = 500
+
+// Mapping is the index definition for project documents. The geo filters
+// match location; boundary is mapped as a geo_shape for shape filters but
+// nothing queries it yet.
+const Mapping = `{
+	"mappings": {
+		"properties": {
+			"id": {"type": "integer"},
+			"title": {"type": "text", "analyzer": "standard"},
+			"description": {"type": "text", "analyzer": "standard"},
+			"category": {"type": "keyword"},
+			"region": {"type": "keyword"},
+			"country": {"type": "keyword"},
+			"verification_standard": {"type": "keyword"},
+			"price_per_tonne": {"type": "float"},
+			"price_tiers": {
+				"properties": {
+					"min_tonnes": {"type": "float"},
+					"price_per_tonne": {"type": "float"}
+				}
+			},
+			"total_capacity": {"type": "float"},
+			"available_capacity": {"type": "float"},
+			"project_developer": {"type": "keyword"},
+			"project_url": {"type": "keyword"},
+			"image_url": {"type": "keyword"},
+			"status": {"type": "keyword"},
+			"latitude": {"type": "float"},
+			"longitude": {"type": "float"},
+			"location": {"type": "geo_point"},
+			"boundary": {"type": "geo_shape", "ignore_malformed": true},
+			"methodology_id": {"type": "keyword"},
+			"credit_type": {"type": "keyword"},
+			"solution_type": {"type": "keyword"},
+			"permanence_years": {"type": "integer"},
+			"buffer_pool_percent": {"type": "float"},
+			"buffer_capacity": {"type": "float"},
+			"additionality_notes": {"type": "text", "analyzer": "standard"},
+			"sdg_goals": {"type": "integer"},
+			"vintages": {
+				"properties": {
+					"id": {"type": "integer"},
+					"project_id": {"type": "integer"},
+					"year": {"type": "integer"},
+					"price_per_tonne": {"type": "float"},
+					"total_capacity": {"type": "float"},
+					"available_capacity": {"type": "float"},
+					"issuance_date": {"type": "date"},
+					"created_at": {"type": "date"},
+					"updated_at": {"type": "date"}
+				}
+			},
+			"created_at": {"type": "date"},
+			"updated_at": {"type": "date"}
+		}
+	},
+	"settings": {
+		"number_of_shards": 1,
+		"number_of_replicas": 0
+	}
+}`
+
+// Maintainer rebuilds the search index and checks it against Postgres
+type Maintainer interface {
+	Reindex(ctx context.Context) (*ReindexReport, error)
+	Check(ctx context.Context) (*ConsistencyReport, error)
+}
+
+// ReindexReport describes a completed reindex
+type ReindexReport struct {
+	Index           string             `json:"index"`
+	PreviousIndices []string           `json:"previous_indices"`
+	Indexed         int                `json:"indexed"`
+	DurationMS      int64              `json:"duration_ms"`
+	Consistency     *ConsistencyReport `json:"consistency"`
+}
+
+// ConsistencyReport compares the published projects in Postgres with the
+// documents behind the alias. Missing projects have no document, stale
+// documents hold an older version of the project and orphaned documents
+// belong to projects that are no longer published.
+type ConsistencyReport struct {
+	Index     string `json:"index"`
+	Projects  int    `json:"projects"`
+	Documents int    `json:"documents"`
+	Missing   []uint `json:"missing"`
+	Stale     []uint `json:"stale"`
+	Orphaned  []uint `json:"orphaned"`
+}
+
+// Consistent reports whether the index matches Postgres
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.Missing) == 0 && len(r.Stale) == 0 && len(r.Orphaned) == 0
+}
+
+// Reindexer rebuilds the projects index from the project repository
+type Reindexer struct {
+	repo repositories.ProjectRepository
+	es   *elasticsearch.Client
+	now  func() time.Time
+
+	// mu serialises reindexes started from this process
+	mu sync.Mutex
+}
+
+func NewReindexer(repo repositories.ProjectRepository, es *elasticsearch.Client) *Reindexer {
+	return &Reindexer{
+		repo: repo,
+		es:   es,
+		now:  time.Now,
+	}
+}
+
+// EnsureIndex creates a versioned index behind the alias when neither the
+// alias nor an index of that name exists yet
+func EnsureIndex(ctx context.Context, es *elasticsearch.Client) error {
+	res, err := esapi.IndicesExistsRequest{Index: []string{Alias}}.Do(ctx, es)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(Mapping), &body); err != nil {
+		return err
+	}
+	body["aliases"] = map[string]interface{}{Alias: map[string]interface{}{}}
+	return do(ctx, es, esapi.IndicesCreateRequest{
+		Index: versionedName(time.Now()),
+		Body:  jsonBody(body),
+	}, nil)
+}
+
+// Reindex copies every published project into a new versioned index, swaps the
+// alias onto it in one atomic update and drops the indices it replaced.
+// Writes that land on the old index while the copy runs are caught up
+// afterwards from a consistency check.
+func (r *Reindexer) Reindex(ctx context.Context) (*ReindexReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	started := r.now()
+	index := versionedName(started)
+	if err := do(ctx, r.es, esapi.IndicesCreateRequest{Index: index, Body: strings.NewReader(Mapping)}, nil); err != nil {
+		return nil, fmt.Errorf("error creating index %s: %w", index, err)
+	}
+
+	indexed, err := r.copyProjects(ctx, index)
+	if err == nil {
+		err = do(ctx, r.es, esapi.IndicesRefreshRequest{Index: []string{index}}, nil)
+	}
+	var previous []string
+	if err == nil {
+		previous, err = r.swapAlias(ctx, index)
+	}
+	if err != nil {
+		// Nothing points at the new index yet, so it can simply be dropped
+		r.deleteIndices(context.Background(), []string{index})
+		return nil, err
+	}
+
+	if err := r.deleteIndices(ctx, previous); err != nil {
+		return nil, err
+	}
+
+	consistency, err := r.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !consistency.Consistent() {
+		if err := r.repair(ctx, consistency); err != nil {
+			return nil, err
+		}
+		if consistency, err = r.Check(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ReindexReport{
+		Index:           index,
+		PreviousIndices: previous,
+		Indexed:         indexed,
+		DurationMS:      r.now().Sub(started).Milliseconds(),
+		Consistency:     consistency,
+	}, nil
+}
+
+// Check compares the published projects in Postgres with the indexed documents
+func (r *Reindexer) Check(ctx context.Context) (*ConsistencyReport, error) {
+	indices, err := r.aliasIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := r.indexedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ConsistencyReport{
+		Index:     strings.Join(indices, ","),
+		Documents: len(documents),
+		Missing:   []uint{},
+		Stale:     []uint{},
+		Orphaned:  []uint{},
+	}
+	err = r.eachBatch(func(projects []models.Project) error {
+		for _, project := range projects {
+			report.Projects++
+			updatedAt, ok := documents[project.ID]
+			switch {
+			case !ok:
+				report.Missing = append(report.Missing, project.ID)
+			case !sameInstant(updatedAt, project.UpdatedAt):
+				report.Stale = append(report.Stale, project.ID)
+			}
+			delete(documents, project.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for id := range documents {
+		report.Orphaned = append(report.Orphaned, id)
+	}
+	sort.Slice(report.Orphaned, func(i, j int) bool { return report.Orphaned[i] < report.Orphaned[j] })
+	return report, nil
+}
+
+// copyProjects bulk indexes every published project into index
+func (r *Reindexer) copyProjects(ctx context.Context, index string) (int, error) {
+	indexed := 0
+	err := r.eachBatch(func(projects []models.Project) error {
+		if err := r.bulk(ctx, index, projects, nil); err != nil {
+			return err
+		}
+		indexed += len(projects)
+		return nil
+	})
+	return indexed, err
+}
+
+// repair re-indexes missing and stale projects and deletes orphaned
+// documents through the alias
+func (r *Reindexer) repair(ctx context.Context, report *ConsistencyReport) error {
+	var projects []models.Project
+	for _, id := range append(append([]uint{}, report.Missing...), report.Stale...) {
+		project, err := r.repo.GetByID(id)
+		if err != nil {
+			return err
+		}
+		projects = append(projects, *project)
+	}
+	return r.bulk(ctx, Alias, projects, report.Orphaned)
+}
+
+// bulk sends index actions for projects and delete actions for deleted IDs
+// in a single _bulk request and fails if any action failed
+func (r *Reindexer) bulk(ctx context.Context, index string, projects []models.Project, deleted []uint) error {
+	if len(projects) == 0 && len(deleted) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range projects {
+		action := map[string]interface{}{"index": map[string]interface{}{"_index": index, "_id": docID(projects[i].ID)}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+		if err := enc.Encode(repositories.NewSearchDocument(&projects[i])); err != nil {
+			return err
+		}
+	}
+	for _, id := range deleted {
+		action := map[string]interface{}{"delete": map[string]interface{}{"_index": index, "_id": docID(id)}}
+		if err := enc.Encode(action); err != nil {
+			return err
+		}
+	}
+
+	var res struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	req := esapi.BulkRequest{Body: &buf}
+	if index == Alias {
+		// Repairs to the live index should be visible to the next check;
+		// a new index is refreshed once after the copy instead
+		req.Refresh = "true"
+	}
+	if err := do(ctx, r.es, req, &res); err != nil {
+		return fmt.Errorf("error bulk indexing projects: %w", err)
+	}
+	if !res.Errors {
+		return nil
+	}
+
+	for _, item := range res.Items {
+		for action, result := range item {
+			if len(result.Error) > 0 && !(action == "delete" && result.Status == http.StatusNotFound) {
+				return fmt.Errorf("error bulk indexing project %s: %s", result.ID, result.Error)
+			}
+		}
+	}
+	return nil
+}
+
+// swapAlias points the alias at index and returns the indices it replaced.
+// A concrete index named like the alias, left over from before versioned
+// indices, is removed in the same atomic update.
+func (r *Reindexer) swapAlias(ctx context.Context, index string) ([]string, error) {
+	previous, err := r.aliasIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	actions := []interface{}{}
+	for _, old := range previous {
+		actions = append(actions, map[string]interface{}{"remove": map[string]interface{}{"index": old, "alias": Alias}})
+	}
+	actions = append(actions, map[string]interface{}{"add": map[string]interface{}{"index": index, "alias": Alias}})
+
+	if len(previous) == 0 {
+		res, err := esapi.IndicesExistsRequest{Index: []string{Alias}}.Do(ctx, r.es)
+		if err != nil {
+			return nil, err
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			actions = append(actions, map[string]interface{}{"remove_index": map[string]interface{}{"index": Alias}})
+			previous = []string{Alias}
+		}
+	}
+
+	req := esapi.IndicesUpdateAliasesRequest{Body: jsonBody(map[string]interface{}{"actions": actions})}
+	if err := do(ctx, r.es, req, nil); err != nil {
+		return nil, fmt.Errorf("error swapping alias %s to %s: %w", Alias, index, err)
+	}
+	return previous, nil
+}
+
+// aliasIndices returns the indices the alias currently points at
+func (r *Reindexer) aliasIndices(ctx context.Context) ([]string, error) {
+	res, err := esapi.IndicesGetAliasRequest{Name: []string{Alias}}.Do(ctx, r.es)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("error reading alias %s: %s", Alias, res.String())
+	}
+
+	var aliases map[string]json.RawMessage
+	if err := json.NewDecoder(res.Body).Decode(&aliases); err != nil {
+		return nil, err
+	}
+	indices := make([]string, 0, len(aliases))
+	for index := range aliases {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// deleteIndices drops replaced versioned indices. The legacy index is
+// skipped because the alias update has already removed it.
+func (r *Reindexer) deleteIndices(ctx context.Context, indices []string) error {
+	var drop []string
+	for _, index := range indices {
+		if index != Alias {
+			drop = append(drop, index)
+		}
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+	if err := do(ctx, r.es, esapi.IndicesDeleteRequest{Index: drop}, nil); err != nil {
+		return fmt.Errorf("error deleting indices %s: %w", strings.Join(drop, ","), err)
+	}
+	return nil
+}
+
+// indexedVersions returns the updated_at of every document behind the
+// alias, paging through the index in ID order
+func (r *Reindexer) indexedVersions(ctx context.Context) (map[uint]time.Time, error) {
+	documents := map[uint]time.Time{}
+	var after []interface{}
+	for {
+		query := map[string]interface{}{
+			"size":    batchSize,
+			"_source": []string{"id", "updated_at"},
+			"query":   map[string]interface{}{"match_all": map[string]interface{}{}},
+			"sort":    []interface{}{map[string]interface{}{"id": "asc"}},
+		}
+		if after != nil {
+			query["search_after"] = after
+		}
+
+		var res struct {
+			Hits struct {
+				Hits []struct {
+					Source struct {
+						ID        uint      `json:"id"`
+						UpdatedAt time.Time `json:"updated_at"`
+					} `json:"_source"`
+					Sort []interface{} `json:"sort"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		req := esapi.SearchRequest{Index: []string{Alias}, Body: jsonBody(query)}
+		if err := do(ctx, r.es, req, &res); err != nil {
+			return nil, fmt.Errorf("error reading indexed projects: %w", err)
+		}
+
+		for _, hit := range res.Hits.Hits {
+			documents[hit.Source.ID] = hit.Source.UpdatedAt
+		}
+		if len(res.Hits.Hits) < batchSize {
+			return documents, nil
+		}
+		after = res.Hits.Hits[len(res.Hits.Hits)-1].Sort
+	}
+}
+
+// eachBatch walks the published projects in ID order
+func (r *Reindexer) eachBatch(fn func(projects []models.Project) error) error {
+	var afterID uint
+	for {
+		projects, err := r.repo.GetPublishedAfter(afterID, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(projects) == 0 {
+			return nil
+		}
+		if err := fn(projects); err != nil {
+			return err
+		}
+		afterID = projects[len(projects)-1].ID
+	}
+}
+
+// do runs an Elasticsearch request and decodes the response into v when it
+// is not nil
+func do(ctx context.Context, es *elasticsearch.Client, req esapi.Request, v interface{}) error {
+	res, err := req.Do(ctx, es)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("elasticsearch error: %s", res.String())
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func jsonBody(v interface{}) *bytes.Reader {
+	body, _ := json.Marshal(v)
+	return bytes.NewReader(body)
+}
+
+func versionedName(t time.Time) string {
+	return Alias + "-v" + strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+func docID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// sameInstant compares timestamps at the microsecond precision Postgres
+// stores, since documents indexed straight after a write carry the
+// nanosecond time Go generated
+func sameInstant(a, b time.Time) bool {
+	diff := a.Sub(b)
+	return diff > -time.Microsecond && diff < time.Microsecond
+}
@@ -0,0 +1,345 @@
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"project_service/models"
+	"project_service/repositories"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// fakeCluster emulates the index, alias, bulk and search APIs the
+// reindexer uses, keeping documents in memory
+type fakeCluster struct {
+	mu       sync.Mutex
+	indices  map[string]map[string]map[string]interface{}
+	aliases  map[string]string
+	failBulk bool
+}
+
+func newFakeCluster(t *testing.T) (*fakeCluster, *elasticsearch.Client) {
+	t.Helper()
+
+	f := &fakeCluster{
+		indices: map[string]map[string]map[string]interface{}{},
+		aliases: map[string]string{},
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+func (f *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+	body, _ := io.ReadAll(r.Body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		f.bulk(w, body)
+	case r.Method == http.MethodPost && r.URL.Path == "/_aliases":
+		f.updateAliases(w, body)
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[0] == "_alias":
+		f.getAlias(w, parts[1])
+	case len(parts) == 2 && parts[1] == "_search":
+		f.search(w, parts[0], body)
+	case len(parts) == 2 && parts[1] == "_refresh":
+		reply(w, http.StatusOK, `{}`)
+	case r.Method == http.MethodHead && len(parts) == 1:
+		if _, ok := f.resolve(parts[0]); ok {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.Method == http.MethodPut && len(parts) == 1:
+		f.createIndex(w, parts[0], body)
+	case r.Method == http.MethodDelete && len(parts) == 1:
+		for _, index := range strings.Split(parts[0], ",") {
+			delete(f.indices, index)
+		}
+		reply(w, http.StatusOK, `{"acknowledged": true}`)
+	default:
+		reply(w, http.StatusBadRequest, `{"error": "unsupported request"}`)
+	}
+}
+
+func (f *fakeCluster) resolve(name string) (string, bool) {
+	if index, ok := f.aliases[name]; ok {
+		return index, true
+	}
+	_, ok := f.indices[name]
+	return name, ok
+}
+
+func (f *fakeCluster) createIndex(w http.ResponseWriter, name string, body []byte) {
+	if _, ok := f.resolve(name); ok {
+		reply(w, http.StatusBadRequest, `{"error": "resource_already_exists_exception"}`)
+		return
+	}
+	var req struct {
+		Aliases map[string]interface{} `json:"aliases"`
+	}
+	json.Unmarshal(body, &req)
+
+	f.indices[name] = map[string]map[string]interface{}{}
+	for alias := range req.Aliases {
+		f.aliases[alias] = name
+	}
+	reply(w, http.StatusOK, `{"acknowledged": true}`)
+}
+
+func (f *fakeCluster) getAlias(w http.ResponseWriter, alias string) {
+	index, ok := f.aliases[alias]
+	if !ok {
+		reply(w, http.StatusNotFound, `{"error": "alias missing"}`)
+		return
+	}
+	res, _ := json.Marshal(map[string]interface{}{index: map[string]interface{}{"aliases": map[string]interface{}{alias: map[string]interface{}{}}}})
+	reply(w, http.StatusOK, string(res))
+}
+
+func (f *fakeCluster) updateAliases(w http.ResponseWriter, body []byte) {
+	var req struct {
+		Actions []map[string]struct {
+			Index string `json:"index"`
+			Alias string `json:"alias"`
+		} `json:"actions"`
+	}
+	json.Unmarshal(body, &req)
+
+	for _, action := range req.Actions {
+		for kind, a := range action {
+			switch kind {
+			case "add":
+				f.aliases[a.Alias] = a.Index
+			case "remove":
+				delete(f.aliases, a.Alias)
+			case "remove_index":
+				delete(f.indices, a.Index)
+			}
+		}
+	}
+	reply(w, http.StatusOK, `{"acknowledged": true}`)
+}
+
+func (f *fakeCluster) bulk(w http.ResponseWriter, body []byte) {
+	if f.failBulk {
+		reply(w, http.StatusOK, `{"errors": true, "items": [{"index": {"_id": "1", "status": 400, "error": {"type": "mapper_parsing_exception"}}}]}`)
+		return
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		json.Unmarshal(scanner.Bytes(), &action)
+		for kind, meta := range action {
+			index, _ := f.resolve(meta.Index)
+			if kind == "delete" {
+				delete(f.indices[index], meta.ID)
+				continue
+			}
+			scanner.Scan()
+			var doc map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &doc)
+			f.indices[index][meta.ID] = doc
+		}
+	}
+	reply(w, http.StatusOK, `{"errors": false, "items": []}`)
+}
+
+func (f *fakeCluster) search(w http.ResponseWriter, name string, body []byte) {
+	index, ok := f.resolve(name)
+	if !ok {
+		reply(w, http.StatusNotFound, `{"error": "index_not_found_exception"}`)
+		return
+	}
+	var req struct {
+		Size        int       `json:"size"`
+		SearchAfter []float64 `json:"search_after"`
+	}
+	json.Unmarshal(body, &req)
+
+	docs := []map[string]interface{}{}
+	for _, doc := range f.indices[index] {
+		if len(req.SearchAfter) == 0 || doc["id"].(float64) > req.SearchAfter[0] {
+			docs = append(docs, doc)
+		}
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i]["id"].(float64) < docs[j]["id"].(float64) })
+	if len(docs) > req.Size {
+		docs = docs[:req.Size]
+	}
+
+	hits := []interface{}{}
+	for _, doc := range docs {
+		hits = append(hits, map[string]interface{}{"_source": doc, "sort": []interface{}{doc["id"]}})
+	}
+	res, _ := json.Marshal(map[string]interface{}{"hits": map[string]interface{}{"hits": hits}})
+	reply(w, http.StatusOK, string(res))
+}
+
+// documents returns the IDs indexed behind name
+func (f *fakeCluster) documents(name string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	index, _ := f.resolve(name)
+	ids := []string{}
+	for id := range f.indices[index] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func reply(w http.ResponseWriter, status int, body string) {
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+func seed(t *testing.T, repo *repositories.InMemoryProjectRepository, titles ...string) {
+	t.Helper()
+	for _, title := range titles {
+		if err := repo.Create(&models.Project{Title: title, Category: "energy", Status: models.StatusPublished}, models.Change{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// newReindexer returns a reindexer whose clock advances a second per call,
+// so each reindex gets a distinct index name
+func newReindexer(repo repositories.ProjectRepository, es *elasticsearch.Client) *Reindexer {
+	r := NewReindexer(repo, es)
+	clock := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return r
+}
+
+func TestReindexReplacesLegacyIndex(t *testing.T) {
+	cluster, es := newFakeCluster(t)
+	cluster.indices[Alias] = map[string]map[string]interface{}{"99": {"id": float64(99)}}
+
+	repo := repositories.NewInMemoryProjectRepository()
+	seed(t, repo, "Wind Farm", `Cookstoves "Kenya"`, "Mangroves")
+	repo.Transition(3, models.Transitions[models.ActionRetire], models.Actor{Role: models.RoleAdmin}, "")
+
+	report, err := newReindexer(repo, es).Reindex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Indexed != 2 || !report.Consistency.Consistent() {
+		t.Errorf("unexpected report: %+v %+v", report, report.Consistency)
+	}
+	if len(report.PreviousIndices) != 1 || report.PreviousIndices[0] != Alias {
+		t.Errorf("expected the legacy index to be replaced, got %v", report.PreviousIndices)
+	}
+	if cluster.aliases[Alias] != report.Index || !strings.HasPrefix(report.Index, "projects-v") {
+		t.Errorf("expected the alias to point at %s, got %q", report.Index, cluster.aliases[Alias])
+	}
+	if got := strings.Join(cluster.documents(Alias), ","); got != "1,2" {
+		t.Errorf("expected projects 1 and 2 to be indexed, got %s", got)
+	}
+	if title := cluster.indices[report.Index]["2"]["title"]; title != `Cookstoves "Kenya"` {
+		t.Errorf("expected quotes to survive indexing, got %v", title)
+	}
+}
+
+func TestReindexDropsPreviousVersion(t *testing.T) {
+	cluster, es := newFakeCluster(t)
+	if err := EnsureIndex(context.Background(), es); err != nil {
+		t.Fatal(err)
+	}
+	initial := cluster.aliases[Alias]
+
+	repo := repositories.NewInMemoryProjectRepository()
+	seed(t, repo, "Wind Farm")
+
+	report, err := newReindexer(repo, es).Reindex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.PreviousIndices) != 1 || report.PreviousIndices[0] != initial {
+		t.Errorf("expected %s to be replaced, got %v", initial, report.PreviousIndices)
+	}
+	if _, ok := cluster.indices[initial]; ok {
+		t.Errorf("expected %s to be deleted", initial)
+	}
+	if err := EnsureIndex(context.Background(), es); err != nil || len(cluster.indices) != 1 {
+		t.Errorf("expected EnsureIndex to keep the existing alias, got %v and %d indices", err, len(cluster.indices))
+	}
+}
+
+func TestReindexBulkFailureKeepsLiveIndex(t *testing.T) {
+	cluster, es := newFakeCluster(t)
+	EnsureIndex(context.Background(), es)
+	live := cluster.aliases[Alias]
+	cluster.failBulk = true
+
+	repo := repositories.NewInMemoryProjectRepository()
+	seed(t, repo, "Wind Farm")
+
+	if _, err := newReindexer(repo, es).Reindex(context.Background()); err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Fatalf("expected the bulk error, got %v", err)
+	}
+	if cluster.aliases[Alias] != live || len(cluster.indices) != 1 {
+		t.Errorf("expected the alias to stay on %s and the new index to be dropped, got %v", live, cluster.indices)
+	}
+}
+
+func TestCheckReportsDrift(t *testing.T) {
+	_, es := newFakeCluster(t)
+	repo := repositories.NewInMemoryProjectRepository()
+	seed(t, repo, "Wind Farm", "Cookstoves", "Mangroves")
+
+	reindexer := newReindexer(repo, es)
+	if _, err := reindexer.Reindex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes made without going through the index
+	seed(t, repo, "Solar Park")
+	time.Sleep(time.Millisecond)
+	repo.Update(1, &models.UpdateProjectRequest{Title: "Offshore Wind Farm"}, models.Change{})
+	repo.Transition(3, models.Transitions[models.ActionRetire], models.Actor{Role: models.RoleAdmin}, "")
+
+	report, err := reindexer.Check(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Projects != 3 || report.Documents != 3 || report.Consistent() {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	for name, got := range map[string][]uint{"missing": report.Missing, "stale": report.Stale, "orphaned": report.Orphaned} {
+		want := map[string]uint{"missing": 4, "stale": 1, "orphaned": 3}[name]
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("expected %s [%d], got %v", name, want, got)
+		}
+	}
+}
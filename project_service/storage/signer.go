@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrURLExpired is returned for a signed URL past its expiry
+	ErrURLExpired = errors.New("signed URL has expired")
+	// ErrURLInvalid is returned for a URL whose signature does not match
+	ErrURLInvalid = errors.New("signed URL is invalid")
+)
+
+// URLSigner signs download paths so they can be handed out without a
+// token. A signature covers the path and its expiry, so it cannot be
+// reused for another file or extended.
+type URLSigner struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func NewURLSigner(secret string, ttl time.Duration) *URLSigner {
+	return &URLSigner{secret: []byte(secret), ttl: ttl, now: time.Now}
+}
+
+// Sign returns the path with its expiry and signature in the query, and
+// when it expires
+func (s *URLSigner) Sign(path string) (string, time.Time) {
+	expires := s.now().Add(s.ttl).Truncate(time.Second)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", s.signature(path, expires.Unix()))
+	return path + "?" + query.Encode(), expires
+}
+
+// Verify checks the expiry and signature given for a path
+func (s *URLSigner) Verify(path, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrURLInvalid
+	}
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrURLInvalid
+	}
+	want, _ := hex.DecodeString(s.signature(path, unix))
+	if !hmac.Equal(given, want) {
+		return ErrURLInvalid
+	}
+	if s.now().Unix() > unix {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *URLSigner) signature(path string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
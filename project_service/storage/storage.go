@@ -0,0 +1,26 @@
+// Package storage keeps uploaded project files as blobs. The local store
+// writes them under a directory; the S3 store talks to any S3-compatible
+// service such as MinIO. Downloads go through the service with signed,
+// expiring URLs whatever the store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when no blob is stored under a key
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps blobs by key
+type Store interface {
+	// Put stores size bytes read from body under key, replacing any blob
+	// already there
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the blob stored under key. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing blob is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+}
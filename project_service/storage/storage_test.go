@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTrip puts, reads back and deletes a blob
+func roundTrip(t *testing.T, store Store, key string) {
+	t.Helper()
+	ctx := context.Background()
+	body := []byte("%PDF-1.7 project design")
+
+	if err := store.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, _ := io.ReadAll(blob)
+	blob.Close()
+	if !bytes.Equal(read, body) {
+		t.Errorf("expected %q back, got %q", body, read)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the deleted blob missing, got %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("expected deleting a missing blob to succeed, got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(root, "documents"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, store, "projects/1/abc")
+
+	// Keys cannot climb out of the root
+	store.Put(context.Background(), "../../escaped", strings.NewReader("x"), 1, "text/plain")
+	if _, err := os.Stat(filepath.Join(root, "escaped")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the blob kept under the root, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "documents", "escaped")); err != nil {
+		t.Errorf("expected the blob under the root: %v", err)
+	}
+}
+
+// fakeS3 is a bucket that checks requests are path-style and signed
+type fakeS3 struct {
+	t       *testing.T
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=minio/20240301/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		f.t.Errorf("unexpected authorization %q", auth)
+	}
+	if r.Header.Get("X-Amz-Date") != "20240301T120000Z" {
+		f.t.Errorf("unexpected date %q", r.Header.Get("X-Amz-Date"))
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		if !f.buckets[bucket] {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case key == "" && r.Method == http.MethodPut:
+		f.buckets[bucket] = true
+	case !f.buckets[bucket]:
+		w.WriteHeader(http.StatusNotFound)
+	case r.Method == http.MethodPut:
+		if r.Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+			f.t.Errorf("expected an unsigned payload, got %q", r.Header.Get("X-Amz-Content-Sha256"))
+		}
+		f.objects[r.URL.Path], _ = io.ReadAll(r.Body)
+	case r.Method == http.MethodGet:
+		body, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{t: t, buckets: map[string]bool{}, objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{Endpoint: server.URL, Region: "us-east-1", Bucket: "documents", AccessKey: "minio", SecretKey: "minio-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	if err := store.EnsureBucket(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !fake.buckets["documents"] {
+		t.Fatal("expected the bucket created")
+	}
+	if err := store.EnsureBucket(context.Background()); err != nil {
+		t.Errorf("expected an existing bucket to be kept, got %v", err)
+	}
+	roundTrip(t, store, "projects/1/abc")
+
+	if _, err := NewS3Store(S3Config{Endpoint: "minio:9000"}); err == nil {
+		t.Error("expected an endpoint without a scheme to be refused")
+	}
+}
+
+func TestS3SignatureIsDeterministic(t *testing.T) {
+	store, _ := NewS3Store(S3Config{Endpoint: "http://minio:9000", Region: "us-east-1", Bucket: "documents", AccessKey: "minio", SecretKey: "minio-secret"})
+	store.now = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	sign := func(secret, path string) string {
+		store.secretKey = secret
+		req := httptest.NewRequest(http.MethodGet, "http://minio:9000"+path, nil)
+		store.sign(req, emptyPayloadHash)
+		return req.Header.Get("Authorization")
+	}
+	first := sign("minio-secret", "/documents/projects/1/abc")
+	if first != sign("minio-secret", "/documents/projects/1/abc") {
+		t.Error("expected the same request to sign the same way")
+	}
+	if first == sign("other-secret", "/documents/projects/1/abc") || first == sign("minio-secret", "/documents/projects/1/abd") {
+		t.Error("expected the signature to cover the secret and the path")
+	}
+}
+
+func TestURLSigner(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	signer := NewURLSigner("document-signing-secret", 15*time.Minute)
+	signer.now = func() time.Time { return now }
+
+	signed, expires := signer.Sign("/api/v1/projects/1/documents/2/download")
+	if !expires.Equal(now.Add(15 * time.Minute)) {
+		t.Errorf("expected the URL to expire in 15 minutes, got %v", expires)
+	}
+	path, query, _ := strings.Cut(signed, "?")
+	values, _ := url.ParseQuery(query)
+	expiry, signature := values.Get("expires"), values.Get("signature")
+
+	if err := signer.Verify(path, expiry, signature); err != nil {
+		t.Errorf("expected the signed URL to verify, got %v", err)
+	}
+	if err := signer.Verify("/api/v1/projects/1/documents/3/download", expiry, signature); !errors.Is(err, ErrURLInvalid) {
+		t.Errorf("expected another document's path to be refused, got %v", err)
+	}
+	if err := signer.Verify(path, "9999999999", signature); !errors.Is(err, ErrURLInvalid) {
+		t.Errorf("expected an extended expiry to be refused, got %v", err)
+	}
+	if err := signer.Verify(path, expiry, "zz"); !errors.Is(err, ErrURLInvalid) {
+		t.Errorf("expected a malformed signature to be refused, got %v", err)
+	}
+
+	now = now.Add(16 * time.Minute)
+	if err := signer.Verify(path, expiry, signature); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("expected the URL to expire, got %v", err)
+	}
+}
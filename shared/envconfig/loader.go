@@ -0,0 +1,298 @@
+// Package envconfig loads service configuration from the environment and an
+// optional config file into structs tagged with `env`, `default`, `validate`
+// and `secret`, and checks it for insecure values outside the dev profile.
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+)
+
+// Profiles select how strictly the configuration is checked. Insecure
+// defaults are only accepted in the dev profile.
+const (
+	ProfileDev        = "dev"
+	ProfileTest       = "test"
+	ProfileStaging    = "staging"
+	ProfileProduction = "production"
+)
+
+// DefaultFile is read when CONFIG_FILE is not set. Unlike an explicit
+// CONFIG_FILE it may be missing.
+const DefaultFile = ".env"
+
+// insecureValues are development defaults and placeholders that must never
+// be used as secrets outside the dev profile
+var insecureValues = []string{
+	"user-secret-key", "admin-secret-key", "secret", "changeme", "password",
+	"postgres", "admin", "admin123", "redis123", "guest", "document-url-secret",
+	"minioadmin",
+}
+
+// Error lists every problem found while loading the configuration
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Source resolves keys from the environment first and the config file second
+type Source struct {
+	file map[string]string
+	path string
+}
+
+// NewSource reads the file named by CONFIG_FILE, or DefaultFile when it is
+// not set. The error is an *Error.
+func NewSource() (*Source, error) {
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = DefaultFile
+	}
+
+	values, err := godotenv.Read(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return &Source{}, nil
+		}
+		return nil, &Error{Problems: []string{fmt.Sprintf("CONFIG_FILE: cannot read %s: %v", path, err)}}
+	}
+	return &Source{file: values, path: path}, nil
+}
+
+// Lookup returns the value of key and whether it is set
+func (s *Source) Lookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
+	}
+	value, ok := s.file[key]
+	return value, ok
+}
+
+// Load fills the fields of cfg tagged with `env` from the source, falling
+// back to their `default` tag, and returns every value that could not be parsed
+func (s *Source) Load(cfg interface{}) []string {
+	var problems []string
+	walk(reflect.ValueOf(cfg).Elem(), func(field reflect.StructField, value reflect.Value) {
+		key := field.Tag.Get("env")
+		raw, ok := s.Lookup(key)
+		if !ok {
+			raw = field.Tag.Get("default")
+		}
+		if err := set(value, strings.TrimSpace(raw)); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %q %v", key, raw, err))
+		}
+	})
+	return problems
+}
+
+// walk calls fn for every field tagged with `env`, descending into nested structs
+func walk(v reflect.Value, fn func(field reflect.StructField, value reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+		if field.Tag.Get("env") == "" {
+			if value.Kind() == reflect.Struct {
+				walk(value, fn)
+			}
+			continue
+		}
+		fn(field, value)
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func set(value reflect.Value, raw string) error {
+	if raw == "" {
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+
+	switch {
+	case value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return errors.New("is not a valid duration")
+		}
+		value.SetInt(int64(d))
+	case value.Kind() == reflect.String:
+		value.SetString(raw)
+	case value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("is not a valid integer")
+		}
+		value.SetInt(int64(n))
+	case value.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("is not a valid number")
+		}
+		value.SetFloat(f)
+	case value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("is not a valid boolean")
+		}
+		value.SetBool(b)
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("has unsupported type %s", value.Type())
+	}
+	return nil
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their environment variable
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return field.Tag.Get("env")
+	})
+
+	return v
+}
+
+// Check validates the `validate` tags of cfg
+func Check(cfg interface{}) []string {
+	err := validate.Struct(cfg)
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	problems := make([]string, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		problems = append(problems, fe.Field()+" "+problemMessage(fe))
+	}
+	return problems
+}
+
+func problemMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "url":
+		return "must be a URL"
+	case "hostname_port":
+		return "must be host:port"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		return "is invalid"
+	}
+}
+
+// CheckSecret reports a secret that is missing, a well-known value or too short
+func CheckSecret(key, value string, minLength int) []string {
+	switch {
+	case value == "":
+		return []string{key + " must be set outside the dev profile"}
+	case isInsecure(value):
+		return []string{key + " uses an insecure default or placeholder value"}
+	case len(value) < minLength:
+		return []string{fmt.Sprintf("%s must be at least %d characters outside the dev profile", key, minLength)}
+	}
+	return nil
+}
+
+// CheckURLCredentials reports a URL whose password is a well-known value
+func CheckURLCredentials(key, raw string) []string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return nil
+	}
+	if password, ok := u.User.Password(); ok && isInsecure(password) {
+		return []string{key + " uses default credentials"}
+	}
+	return nil
+}
+
+func isInsecure(value string) bool {
+	value = strings.ToLower(value)
+	if strings.HasPrefix(value, "your-") {
+		return true
+	}
+	for _, insecure := range insecureValues {
+		if value == insecure {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints every setting as KEY=value. Secrets and passwords embedded in
+// URLs are redacted.
+func Write(w io.Writer, cfg interface{}) {
+	walk(reflect.ValueOf(cfg).Elem(), func(field reflect.StructField, value reflect.Value) {
+		fmt.Fprintf(w, "%s=%s\n", field.Tag.Get("env"), display(field, value))
+	})
+}
+
+func display(field reflect.StructField, value reflect.Value) string {
+	var s string
+	switch {
+	case value.Type() == durationType:
+		s = time.Duration(value.Int()).String()
+	case value.Kind() == reflect.Slice:
+		s = strings.Join(value.Interface().([]string), ",")
+	default:
+		s = fmt.Sprint(value.Interface())
+	}
+
+	if s != "" && field.Tag.Get("secret") == "true" {
+		return "<redacted>"
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return s
+}
+
+// RunCommand runs a "config" subcommand with the service's load function and
+// returns the process exit code
+func RunCommand[T any](args []string, stdout, stderr io.Writer, load func() (*T, error)) int {
+	if len(args) != 1 || args[0] != "print" {
+		fmt.Fprintln(stderr, "usage: config print")
+		return 2
+	}
+
+	cfg, err := load()
+	if cfg != nil {
+		Write(stdout, cfg)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package envconfig
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type settings struct {
+	Name    string        `env:"TEST_NAME" default:"orders" validate:"required"`
+	Workers int           `env:"TEST_WORKERS" default:"4" validate:"min=1"`
+	Ratio   float64       `env:"TEST_RATIO" default:"0.5"`
+	Debug   bool          `env:"TEST_DEBUG" default:"false"`
+	Timeout time.Duration `env:"TEST_TIMEOUT" default:"5s"`
+	Nested  struct {
+		Hosts  []string `env:"TEST_HOSTS" default:"a, b,,c"`
+		Secret string   `env:"TEST_SECRET" secret:"true"`
+	}
+}
+
+// withFile points CONFIG_FILE at a temporary file with the given contents
+func withFile(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "service.env")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func load() (*settings, error) {
+	src, err := NewSource()
+	if err != nil {
+		return nil, err
+	}
+	cfg := &settings{}
+	problems := append(src.Load(cfg), Check(cfg)...)
+	if len(problems) > 0 {
+		return cfg, &Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func TestLoadDefaultsFileAndEnvironment(t *testing.T) {
+	withFile(t, "TEST_WORKERS=8\nTEST_DEBUG=true\n")
+	t.Setenv("TEST_WORKERS", "16")
+
+	cfg, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "orders" || cfg.Workers != 16 || cfg.Ratio != 0.5 || !cfg.Debug || cfg.Timeout != 5*time.Second {
+		t.Errorf("unexpected settings: %+v", cfg)
+	}
+	if strings.Join(cfg.Nested.Hosts, ",") != "a,b,c" {
+		t.Errorf("expected the nested list split and trimmed, got %q", cfg.Nested.Hosts)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	withFile(t, "TEST_WORKERS=many\nTEST_TIMEOUT=soon\nTEST_NAME=\n")
+
+	_, err := load()
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a configuration error, got %v", err)
+	}
+	problems := strings.Join(cfgErr.Problems, "\n")
+	for _, want := range []string{"TEST_WORKERS: \"many\" is not a valid integer", "TEST_TIMEOUT: \"soon\" is not a valid duration", "TEST_NAME is required"} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("expected %q, got:\n%s", want, problems)
+		}
+	}
+}
+
+func TestNewSourceRequiresAnExplicitFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.env"))
+	if _, err := NewSource(); err == nil || !strings.Contains(err.Error(), "CONFIG_FILE: cannot read") {
+		t.Errorf("expected a missing CONFIG_FILE refused, got %v", err)
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  string
+	}{
+		{"", "must be set"},
+		{"admin-secret-key", "insecure default"},
+		{"your-jwt-secret", "insecure default"},
+		{"short", "at least 32 characters"},
+		{strings.Repeat("k", 32), ""},
+	} {
+		problems := strings.Join(CheckSecret("JWT_SECRET", tt.value, 32), "\n")
+		if (tt.want == "") != (problems == "") || !strings.Contains(problems, tt.want) {
+			t.Errorf("%q: expected %q, got %q", tt.value, tt.want, problems)
+		}
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	withFile(t, "TEST_SECRET=hunter2\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := RunCommand([]string{"print"}, &stdout, &stderr, load); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if out := stdout.String(); strings.Contains(out, "hunter2") || !strings.Contains(out, "TEST_SECRET=<redacted>") || !strings.Contains(out, "TEST_TIMEOUT=5s") {
+		t.Errorf("expected the settings printed with the secret redacted:\n%s", out)
+	}
+
+	stdout.Reset()
+	if code := RunCommand([]string{"show"}, &stdout, &stderr, load); code != 2 || stdout.Len() != 0 {
+		t.Errorf("expected usage with exit code 2, got %d", code)
+	}
+}
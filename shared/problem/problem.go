@@ -0,0 +1,215 @@
+// Package problem renders errors as RFC 7807 problem details
+// (application/problem+json). Handlers return a *Problem as their error and
+// HTTPErrorHandler writes it with the request ID attached; any other error is
+// reported as a generic internal error so its details never reach the client.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// ContentType is the media type of problem responses
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes the code to form the problem type URI
+const TypeBase = "https://carbonclear.com/problems/"
+
+// Machine-readable problem codes shared by every service
+const (
+	CodeBadRequest           = "bad_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal_error"
+	CodeBadGateway           = "bad_gateway"
+	CodeServiceUnavailable   = "service_unavailable"
+	CodeGatewayTimeout       = "gateway_timeout"
+)
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            CodeBadRequest,
+	http.StatusUnprocessableEntity:   CodeValidationFailed,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusMethodNotAllowed:      CodeMethodNotAllowed,
+	http.StatusConflict:              CodeConflict,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusBadGateway:            CodeBadGateway,
+	http.StatusServiceUnavailable:    CodeServiceUnavailable,
+	http.StatusGatewayTimeout:        CodeGatewayTimeout,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object
+type Problem struct {
+	Type      string       `json:"type"`
+	Code      string       `json:"code"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+
+	cause error
+}
+
+// New creates a problem for the status with a code derived from it
+func New(status int, detail string) *Problem {
+	code, ok := statusCodes[status]
+	if !ok {
+		if status >= http.StatusInternalServerError {
+			code = CodeInternal
+		} else {
+			code = CodeBadRequest
+		}
+	}
+	return &Problem{
+		Type:   TypeBase + code,
+		Code:   code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// BadRequest reports a malformed request
+func BadRequest(detail string) *Problem {
+	return New(http.StatusBadRequest, detail)
+}
+
+// Unauthorized reports missing or invalid credentials
+func Unauthorized(detail string) *Problem {
+	return New(http.StatusUnauthorized, detail)
+}
+
+// Forbidden reports a caller who may not make the request
+func Forbidden(detail string) *Problem {
+	return New(http.StatusForbidden, detail)
+}
+
+// NotFound reports a missing resource
+func NotFound(detail string) *Problem {
+	return New(http.StatusNotFound, detail)
+}
+
+// Conflict reports a request that clashes with existing state
+func Conflict(detail string) *Problem {
+	return New(http.StatusConflict, detail)
+}
+
+// Internal reports a server-side failure. The cause is logged, never returned.
+func Internal(detail string, cause error) *Problem {
+	return New(http.StatusInternalServerError, detail).Wrap(cause)
+}
+
+// Validation reports request fields that failed validation
+func Validation(errs ...FieldError) *Problem {
+	p := New(http.StatusUnprocessableEntity, "Validation failed")
+	p.Errors = errs
+	return p
+}
+
+// WithCode replaces the generic code with a more specific one
+func (p *Problem) WithCode(code string) *Problem {
+	p.Code = code
+	p.Type = TypeBase + code
+	return p
+}
+
+// Wrap records the underlying error for logging
+func (p *Problem) Wrap(cause error) *Problem {
+	p.cause = cause
+	return p
+}
+
+func (p *Problem) Error() string {
+	msg := fmt.Sprintf("%d %s", p.Status, p.Code)
+	if p.Detail != "" {
+		msg += ": " + p.Detail
+	}
+	if p.cause != nil {
+		msg += ": " + p.cause.Error()
+	}
+	return msg
+}
+
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+// From converts any handler error into a problem. Echo HTTP errors keep their
+// status and message; unknown errors become an opaque internal error.
+func From(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		copied := *p
+		return &copied
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		detail, _ := he.Message.(string)
+		if detail == http.StatusText(he.Code) {
+			detail = ""
+		}
+		return New(he.Code, detail).Wrap(he.Internal)
+	}
+
+	return Internal("An unexpected error occurred", err)
+}
+
+// HTTPErrorHandler is an echo.HTTPErrorHandler that writes problem+json
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	p := From(err)
+	p.Instance = c.Request().URL.Path
+	p.RequestID = c.Response().Header().Get(echo.HeaderXRequestID)
+
+	if p.Status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		var body []byte
+		body, err = json.Marshal(p)
+		if err == nil {
+			err = c.Blob(p.Status, ContentType, body)
+		}
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// Install assigns request IDs and renders every error as problem+json
+func Install(e *echo.Echo) {
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(middleware.RequestID())
+}
@@ -0,0 +1,150 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// decode reads a problem+json response
+func decode(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	if ct := rec.Header().Get(echo.HeaderContentType); ct != ContentType {
+		t.Fatalf("expected %s, got %q", ContentType, ct)
+	}
+	var p Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNewDerivesTheCode(t *testing.T) {
+	for status, code := range map[int]string{
+		http.StatusNotFound:              CodeNotFound,
+		http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+		http.StatusTeapot:                CodeBadRequest,
+		http.StatusInsufficientStorage:   CodeInternal,
+	} {
+		if p := New(status, ""); p.Code != code || p.Type != TypeBase+code || p.Title != http.StatusText(status) {
+			t.Errorf("expected %d to be %s, got %+v", status, code, p)
+		}
+	}
+}
+
+func TestFrom(t *testing.T) {
+	cause := errors.New("connection refused")
+
+	t.Run("problem", func(t *testing.T) {
+		original := Conflict("Already taken").WithCode("taken")
+		p := From(fmt.Errorf("creating: %w", original))
+		if p.Status != http.StatusConflict || p.Code != "taken" || p.Detail != "Already taken" {
+			t.Fatalf("expected the wrapped problem, got %+v", p)
+		}
+		p.Instance = "/things"
+		if original.Instance != "" {
+			t.Error("expected a copy of the problem, not the problem itself")
+		}
+	})
+
+	t.Run("echo error", func(t *testing.T) {
+		p := From(echo.NewHTTPError(http.StatusRequestEntityTooLarge, "Too big").SetInternal(cause))
+		if p.Status != http.StatusRequestEntityTooLarge || p.Code != CodePayloadTooLarge || p.Detail != "Too big" || !errors.Is(p, cause) {
+			t.Errorf("expected the echo error's status and message, got %+v", p)
+		}
+		if p := From(echo.ErrMethodNotAllowed); p.Code != CodeMethodNotAllowed || p.Detail != "" {
+			t.Errorf("expected the status text dropped from the detail, got %+v", p)
+		}
+	})
+
+	t.Run("other error", func(t *testing.T) {
+		p := From(cause)
+		if p.Status != http.StatusInternalServerError || p.Code != CodeInternal || p.Detail != "An unexpected error occurred" || !errors.Is(p, cause) {
+			t.Errorf("expected an opaque internal error, got %+v", p)
+		}
+	})
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	serve := func(method string, err error) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/projects/7", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Response().Header().Set(echo.HeaderXRequestID, "req-1")
+		HTTPErrorHandler(err, c)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, Validation(FieldError{Field: "title", Message: "is required"}))
+	p := decode(t, rec)
+	if rec.Code != http.StatusUnprocessableEntity || p.Code != CodeValidationFailed || p.Instance != "/projects/7" || p.RequestID != "req-1" {
+		t.Errorf("unexpected problem: %d %+v", rec.Code, p)
+	}
+	if len(p.Errors) != 1 || p.Errors[0].Field != "title" {
+		t.Errorf("expected the field errors, got %+v", p.Errors)
+	}
+
+	rec = serve(http.MethodGet, echo.ErrNotFound)
+	if p := decode(t, rec); rec.Code != http.StatusNotFound || p.Code != CodeNotFound || p.Type != TypeBase+CodeNotFound {
+		t.Errorf("expected echo's 404 as a problem, got %d %+v", rec.Code, p)
+	}
+
+	rec = serve(http.MethodGet, errors.New("pq: password authentication failed"))
+	if p := decode(t, rec); rec.Code != http.StatusInternalServerError || p.Detail != "An unexpected error occurred" {
+		t.Errorf("expected the error's details kept from the client, got %d %+v", rec.Code, p)
+	}
+
+	if rec = serve(http.MethodHead, NotFound("Project not found")); rec.Code != http.StatusNotFound || rec.Body.Len() != 0 {
+		t.Errorf("expected a bare 404 for HEAD, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// A response already sent is left alone
+	rec = httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+	if err := c.String(http.StatusOK, "partial"); err != nil {
+		t.Fatal(err)
+	}
+	HTTPErrorHandler(BadRequest("too late"), c)
+	if rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+		t.Errorf("expected the committed response untouched, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestInstall(t *testing.T) {
+	e := echo.New()
+	Install(e)
+	e.GET("/projects/:id", func(c echo.Context) error {
+		return NotFound("Project not found")
+	})
+
+	for _, tt := range []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/projects/7", http.StatusNotFound, CodeNotFound},
+		{"/nowhere", http.StatusNotFound, CodeNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		p := decode(t, rec)
+		if rec.Code != tt.status || p.Code != tt.code || p.Instance != tt.path {
+			t.Errorf("%s: unexpected problem: %d %+v", tt.path, rec.Code, p)
+		}
+		if id := rec.Header().Get(echo.HeaderXRequestID); id == "" || p.RequestID != id {
+			t.Errorf("%s: expected the request ID %q in the problem, got %q", tt.path, id, p.RequestID)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/projects/7", nil))
+	if p := decode(t, rec); rec.Code != http.StatusMethodNotAllowed || p.Code != CodeMethodNotAllowed {
+		t.Errorf("expected a 405 problem, got %d %+v", rec.Code, p)
+	}
+}
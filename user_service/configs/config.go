@@ -0,0 +1,80 @@
+package configs
+
+import (
+	"fmt"
+	"io"
+	"shared/envconfig"
+)
+
+// jwtSecretMinLength is the shortest JWT secret accepted outside the dev profile
+const jwtSecretMinLength = 32
+
+// Config is the user service configuration
+type Config struct {
+	Profile string `env:"APP_ENV" default:"production" validate:"oneof=dev test staging production"`
+	Port    int    `env:"PORT" default:"8080" validate:"min=1,max=65535"`
+
+	DB  DatabaseConfig
+	JWT JWTConfig
+}
+
+// DatabaseConfig is the Postgres connection
+type DatabaseConfig struct {
+	Host     string `env:"DB_HOST" default:"localhost" validate:"required"`
+	Port     int    `env:"DB_PORT" default:"5432" validate:"min=1,max=65535"`
+	User     string `env:"DB_USER" default:"postgres" validate:"required"`
+	Password string `env:"DB_PASSWORD" secret:"true"`
+	Name     string `env:"DB_NAME" default:"carbon_clear_users" validate:"required"`
+	SSLMode  string `env:"DB_SSLMODE" default:"disable" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+}
+
+// DSN returns the connection string for the Postgres driver
+func (c DatabaseConfig) DSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		c.Host, c.User, c.Password, c.Name, c.Port, c.SSLMode)
+}
+
+// JWTConfig holds the secrets that sign user and admin tokens. They must
+// match the API gateway.
+type JWTConfig struct {
+	UserSecret  string `env:"USER_JWT_SECRET" default:"user-secret-key" secret:"true" validate:"required"`
+	AdminSecret string `env:"ADMIN_JWT_SECRET" default:"admin-secret-key" secret:"true" validate:"required"`
+}
+
+// Load reads the configuration from the environment and the optional config
+// file and validates it. The config is returned even when it is invalid so
+// it can still be printed.
+func Load() (*Config, error) {
+	src, err := envconfig.NewSource()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	problems := src.Load(cfg)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return cfg, &envconfig.Error{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() []string {
+	problems := envconfig.Check(c)
+	if c.Profile == envconfig.ProfileDev {
+		return problems
+	}
+
+	problems = append(problems, envconfig.CheckSecret("USER_JWT_SECRET", c.JWT.UserSecret, jwtSecretMinLength)...)
+	problems = append(problems, envconfig.CheckSecret("ADMIN_JWT_SECRET", c.JWT.AdminSecret, jwtSecretMinLength)...)
+	if c.JWT.UserSecret != "" && c.JWT.UserSecret == c.JWT.AdminSecret {
+		problems = append(problems, "USER_JWT_SECRET and ADMIN_JWT_SECRET must differ")
+	}
+	problems = append(problems, envconfig.CheckSecret("DB_PASSWORD", c.DB.Password, 1)...)
+	return problems
+}
+
+// RunCommand runs a "config" subcommand and returns the process exit code
+func RunCommand(args []string, stdout, stderr io.Writer) int {
+	return envconfig.RunCommand(args, stdout, stderr, Load)
+}
@@ -0,0 +1,116 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"shared/envconfig"
+	"strings"
+	"testing"
+)
+
+// withFile points CONFIG_FILE at a temporary file with the given contents
+func withFile(t *testing.T, contents string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "service.env")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+}
+
+func problemsOf(t *testing.T, err error) []string {
+	t.Helper()
+	var cfgErr *envconfig.Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("expected a configuration error, got %v", err)
+	}
+	return cfgErr.Problems
+}
+
+func TestLoadDevDefaults(t *testing.T) {
+	withFile(t, "APP_ENV=dev\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("expected dev defaults to be accepted: %v", err)
+	}
+	if cfg.Port != 8080 || cfg.DB.Host != "localhost" || cfg.JWT.UserSecret != "user-secret-key" {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestLoadEnvironmentOverridesFile(t *testing.T) {
+	withFile(t, "APP_ENV=dev\nPORT=9000\nDB_HOST=db.internal\n")
+	t.Setenv("PORT", "9100")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9100 || cfg.DB.Host != "db.internal" {
+		t.Errorf("expected PORT from the environment and DB_HOST from the file, got %+v", cfg)
+	}
+}
+
+func TestLoadRejectsInsecureDefaultsOutsideDev(t *testing.T) {
+	withFile(t, "")
+
+	_, err := Load()
+	problems := strings.Join(problemsOf(t, err), "\n")
+	for _, key := range []string{"USER_JWT_SECRET", "ADMIN_JWT_SECRET", "DB_PASSWORD"} {
+		if !strings.Contains(problems, key) {
+			t.Errorf("expected a problem for %s, got:\n%s", key, problems)
+		}
+	}
+}
+
+func TestLoadAcceptsStrongSecrets(t *testing.T) {
+	withFile(t, "APP_ENV=production\nDB_PASSWORD=Zp4-kq8s-Lw2e\n"+
+		"USER_JWT_SECRET=0123456789abcdef0123456789abcdef-user\n"+
+		"ADMIN_JWT_SECRET=0123456789abcdef0123456789abcdef-admin\n")
+
+	if _, err := Load(); err != nil {
+		t.Fatalf("expected strong secrets to be accepted: %v", err)
+	}
+}
+
+func TestLoadReportsInvalidValues(t *testing.T) {
+	withFile(t, "APP_ENV=local\nPORT=http\nDB_SSLMODE=maybe\n")
+
+	_, err := Load()
+	problems := strings.Join(problemsOf(t, err), "\n")
+	for _, want := range []string{"PORT: \"http\" is not a valid integer", "APP_ENV must be one of", "DB_SSLMODE must be one of"} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("expected %q, got:\n%s", want, problems)
+		}
+	}
+}
+
+func TestLoadMissingExplicitFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.env"))
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "CONFIG_FILE") {
+		t.Fatalf("expected an error naming CONFIG_FILE, got %v", err)
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	withFile(t, "APP_ENV=dev\nDB_PASSWORD=hunter2\n")
+
+	var stdout, stderr bytes.Buffer
+	if code := RunCommand([]string{"print"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "user-secret-key") {
+		t.Errorf("expected secrets to be redacted:\n%s", out)
+	}
+	for _, want := range []string{"DB_PASSWORD=<redacted>", "USER_JWT_SECRET=<redacted>", "DB_HOST=localhost", "APP_ENV=dev"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+}
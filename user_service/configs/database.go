@@ -2,34 +2,15 @@ package configs
 
 import (
 	"fmt"
-	"log"
-	"os"
 	"user_service/models"
 
-	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-var DB *gorm.DB
-
-func InitDB() (*gorm.DB, error) {
-	var err error
-
-	// Initiate secrets and credentials (optional - only for local development)
-	err = godotenv.Load()
-	if err != nil {
-		log.Println("No .env file found, using environment variables from system")
-	}
-
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_PORT"),
-	)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func InitDB(cfg DatabaseConfig) (*gorm.DB, error) {
+	// TranslateError maps unique violations to gorm.ErrDuplicatedKey
+	db, err := gorm.Open(postgres.Open(cfg.DSN()), &gorm.Config{TranslateError: true})
 	if err != nil {
 		return nil, err
 	}
@@ -46,12 +27,16 @@ func InitDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	DB = db
-
 	err = db.AutoMigrate(&models.User{})
 	if err != nil {
 		return nil, err
 	}
 
-	return DB, nil
+	// Emails are unique regardless of case
+	err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_lower ON users (LOWER(email))").Error
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
 }
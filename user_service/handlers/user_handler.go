@@ -1,16 +1,19 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
-	"os"
+	"shared/problem"
 	"strconv"
 	"time"
+	"user_service/configs"
 	"user_service/models"
 	"user_service/repositories"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type JwtCustomClaims struct {
@@ -19,6 +22,15 @@ type JwtCustomClaims struct {
 	jwt.RegisteredClaims
 }
 
+type UserHandler struct {
+	repo repositories.UserRepository
+	jwt  configs.JWTConfig
+}
+
+func NewUserHandler(repo repositories.UserRepository, jwt configs.JWTConfig) *UserHandler {
+	return &UserHandler{repo: repo, jwt: jwt}
+}
+
 // RegisterUser godoc
 // @Summary Register a new user
 // @Description Register a new user account
@@ -27,23 +39,30 @@ type JwtCustomClaims struct {
 // @Produce json
 // @Param request body models.RegisterRequest true "User registration details"
 // @Success 200 {object} map[string]string "User registered successfully"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 500 {object} map[string]string "Failed to create user"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 409 {object} problem.Problem "Email is already registered"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to create user"
 // @Router /api/users/register [post]
-func RegisterUser(c echo.Context) error {
+func (h *UserHandler) RegisterUser(c echo.Context) error {
 	var request models.RegisterRequest
 	err := c.Bind(&request)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
+	}
+
+	request.Email = normalizeEmail(request.Email)
+	if err := validateRequest(&request); err != nil {
+		return err
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to hash password"})
+		return problem.Internal("Failed to hash password", err)
 	}
 	request.Password = string(hashedPassword)
 
-	err = repositories.CreateUser(&models.User{
+	err = h.repo.CreateUser(&models.User{
 		Name:      request.Name,
 		Email:     request.Email,
 		Password:  request.Password,
@@ -52,7 +71,10 @@ func RegisterUser(c echo.Context) error {
 		UpdatedAt: time.Now(),
 	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to create user"})
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return duplicateEmail()
+		}
+		return problem.Internal("Failed to create user", err)
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{"message": "User registered successfully"})
@@ -60,35 +82,46 @@ func RegisterUser(c echo.Context) error {
 
 // LoginUser godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate a user or developer and return a user JWT token carrying their role
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param request body models.LoginRequest true "Login credentials"
 // @Success 200 {object} map[string]interface{} "Login successful with token"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 401 {object} map[string]string "Invalid credentials or role"
-// @Failure 500 {object} map[string]string "Failed to get user or sign token"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Invalid credentials or role"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to get user or sign token"
 // @Router /api/users/login [post]
-func LoginUser(c echo.Context) error {
+func (h *UserHandler) LoginUser(c echo.Context) error {
 	var request models.LoginRequest
 	err := c.Bind(&request)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
+	}
+
+	request.Email = normalizeEmail(request.Email)
+	if err := validateRequest(&request); err != nil {
+		return err
 	}
 
-	user, err := repositories.GetUserByEmail(request.Email)
+	user, err := h.repo.GetUserByEmail(request.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to get user"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.Unauthorized("Invalid email or password").WithCode(codeInvalidCredentials)
+		}
+		return problem.Internal("Failed to get user", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password))
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid password"})
+		return problem.Unauthorized("Invalid password").WithCode(codeInvalidCredentials)
 	}
 
-	if user.Role != "user" {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid role"})
+	// Developers sign in as users; their role scopes them to their own
+	// projects in the project service
+	if user.Role != "user" && user.Role != "developer" {
+		return problem.Unauthorized("Invalid role").WithCode(codeInvalidCredentials)
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
@@ -96,9 +129,9 @@ func LoginUser(c echo.Context) error {
 		Role:   user.Role,
 	})
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("USER_JWT_SECRET")))
+	tokenString, err := token.SignedString([]byte(h.jwt.UserSecret))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to sign token"})
+		return problem.Internal("Failed to sign token", err)
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{"message": "Login successful", "token": tokenString})
@@ -112,23 +145,30 @@ func LoginUser(c echo.Context) error {
 // @Produce json
 // @Param request body models.RegisterRequest true "Admin registration details"
 // @Success 200 {object} map[string]string "Admin registered successfully"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 500 {object} map[string]string "Failed to create admin"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 409 {object} problem.Problem "Email is already registered"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to create admin"
 // @Router /admin/users/register [post]
-func RegisterAdmin(c echo.Context) error {
+func (h *UserHandler) RegisterAdmin(c echo.Context) error {
 	var request models.RegisterRequest
 	err := c.Bind(&request)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
+	}
+
+	request.Email = normalizeEmail(request.Email)
+	if err := validateRequest(&request); err != nil {
+		return err
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to hash password"})
+		return problem.Internal("Failed to hash password", err)
 	}
 	request.Password = string(hashedPassword)
 
-	err = repositories.CreateUser(&models.User{
+	err = h.repo.CreateUser(&models.User{
 		Name:      request.Name,
 		Email:     request.Email,
 		Password:  request.Password,
@@ -137,7 +177,10 @@ func RegisterAdmin(c echo.Context) error {
 		UpdatedAt: time.Now(),
 	})
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to create user"})
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return duplicateEmail()
+		}
+		return problem.Internal("Failed to create user", err)
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{"message": "User registered successfully"})
@@ -151,29 +194,38 @@ func RegisterAdmin(c echo.Context) error {
 // @Produce json
 // @Param request body models.LoginRequest true "Login credentials"
 // @Success 200 {object} map[string]interface{} "Login successful with token"
-// @Failure 400 {object} map[string]string "Invalid request body"
-// @Failure 401 {object} map[string]string "Invalid credentials or role"
-// @Failure 500 {object} map[string]string "Failed to get user or sign token"
+// @Failure 400 {object} problem.Problem "Invalid request body"
+// @Failure 401 {object} problem.Problem "Invalid credentials or role"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to get user or sign token"
 // @Router /admin/users/login [post]
-func LoginAdmin(c echo.Context) error {
+func (h *UserHandler) LoginAdmin(c echo.Context) error {
 	var request models.LoginRequest
 	err := c.Bind(&request)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
 	}
 
-	user, err := repositories.GetUserByEmail(request.Email)
+	request.Email = normalizeEmail(request.Email)
+	if err := validateRequest(&request); err != nil {
+		return err
+	}
+
+	user, err := h.repo.GetUserByEmail(request.Email)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to get user"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.Unauthorized("Invalid email or password").WithCode(codeInvalidCredentials)
+		}
+		return problem.Internal("Failed to get user", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password))
 	if err != nil {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid password"})
+		return problem.Unauthorized("Invalid password").WithCode(codeInvalidCredentials)
 	}
 
 	if user.Role != "admin" {
-		return c.JSON(http.StatusUnauthorized, echo.Map{"message": "Invalid role"})
+		return problem.Unauthorized("Invalid role").WithCode(codeInvalidCredentials)
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{
@@ -181,9 +233,9 @@ func LoginAdmin(c echo.Context) error {
 		Role:   user.Role,
 	})
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("ADMIN_JWT_SECRET")))
+	tokenString, err := token.SignedString([]byte(h.jwt.AdminSecret))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to sign token"})
+		return problem.Internal("Failed to sign token", err)
 	}
 
 	return c.JSON(http.StatusOK, echo.Map{"message": "Login successful", "token": tokenString})
@@ -197,12 +249,12 @@ func LoginAdmin(c echo.Context) error {
 // @Produce json
 // @Security AdminAuth
 // @Success 200 {object} map[string]interface{} "Users fetched successfully"
-// @Failure 500 {object} map[string]string "Failed to get users"
+// @Failure 500 {object} problem.Problem "Failed to get users"
 // @Router /admin/users [get]
-func GetAllUsers(c echo.Context) error {
-	users, err := repositories.GetAllUsers()
+func (h *UserHandler) GetAllUsers(c echo.Context) error {
+	users, err := h.repo.GetAllUsers()
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to get users"})
+		return problem.Internal("Failed to get users", err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{"message": "Users fetched successfully", "users": users})
 }
@@ -216,18 +268,22 @@ func GetAllUsers(c echo.Context) error {
 // @Security AdminAuth
 // @Param id path int true "User ID"
 // @Success 200 {object} map[string]interface{} "User fetched successfully"
-// @Failure 400 {object} map[string]string "Invalid ID"
-// @Failure 500 {object} map[string]string "Failed to get user"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 404 {object} problem.Problem "User not found"
+// @Failure 500 {object} problem.Problem "Failed to get user"
 // @Router /admin/users/{id} [get]
-func GetUserByID(c echo.Context) error {
+func (h *UserHandler) GetUserByID(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid ID"})
+		return problem.BadRequest("Invalid ID")
 	}
 
-	user, err := repositories.GetUserByID(id)
+	user, err := h.repo.GetUserByID(id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to get user"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return problem.NotFound("User not found")
+		}
+		return problem.Internal("Failed to get user", err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{"message": "User fetched successfully", "user": user})
 }
@@ -242,24 +298,29 @@ func GetUserByID(c echo.Context) error {
 // @Param id path int true "User ID"
 // @Param request body models.UpdateUserRequest true "User update details"
 // @Success 200 {object} map[string]string "User updated successfully"
-// @Failure 400 {object} map[string]string "Invalid ID or request body"
-// @Failure 500 {object} map[string]string "Failed to update user"
+// @Failure 400 {object} problem.Problem "Invalid ID or request body"
+// @Failure 422 {object} problem.Problem "Validation failed"
+// @Failure 500 {object} problem.Problem "Failed to update user"
 // @Router /admin/users/{id} [put]
-func UpdateUser(c echo.Context) error {
+func (h *UserHandler) UpdateUser(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid ID"})
+		return problem.BadRequest("Invalid ID")
 	}
 
 	var request models.UpdateUserRequest
 	err = c.Bind(&request)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid request body"})
+		return problem.BadRequest("Invalid request body")
+	}
+
+	if err := validateRequest(&request); err != nil {
+		return err
 	}
 
-	err = repositories.UpdateUser(id, &request)
+	err = h.repo.UpdateUser(id, &request)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to update user"})
+		return problem.Internal("Failed to update user", err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{"message": "User updated successfully"})
 }
@@ -273,18 +334,18 @@ func UpdateUser(c echo.Context) error {
 // @Security AdminAuth
 // @Param id path int true "User ID"
 // @Success 200 {object} map[string]string "User deleted successfully"
-// @Failure 400 {object} map[string]string "Invalid ID"
-// @Failure 500 {object} map[string]string "Failed to delete user"
+// @Failure 400 {object} problem.Problem "Invalid ID"
+// @Failure 500 {object} problem.Problem "Failed to delete user"
 // @Router /admin/users/{id} [delete]
-func DeleteUser(c echo.Context) error {
+func (h *UserHandler) DeleteUser(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, echo.Map{"message": "Invalid ID"})
+		return problem.BadRequest("Invalid ID")
 	}
 
-	err = repositories.DeleteUser(id)
+	err = h.repo.DeleteUser(id)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to delete user"})
+		return problem.Internal("Failed to delete user", err)
 	}
 	return c.JSON(http.StatusOK, echo.Map{"message": "User deleted successfully"})
 }
@@ -297,18 +358,18 @@ func DeleteUser(c echo.Context) error {
 // @Produce json
 // @Security UserAuth
 // @Success 200 {object} map[string]interface{} "Profile fetched successfully"
-// @Failure 500 {object} map[string]string "Failed to get user profile"
+// @Failure 500 {object} problem.Problem "Failed to get user profile"
 // @Router /api/users/profile [get]
-func GetProfile(c echo.Context) error {
+func (h *UserHandler) GetProfile(c echo.Context) error {
 	// Get user ID from JWT token
 	user := c.Get("user").(*jwt.Token)
 	claims := user.Claims.(*JwtCustomClaims)
 	userID := claims.UserID
 
 	// Get user from database
-	userData, err := repositories.GetUserByID(int(userID))
+	userData, err := h.repo.GetUserByID(int(userID))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, echo.Map{"message": "Failed to get user profile"})
+		return problem.Internal("Failed to get user profile", err)
 	}
 
 	// Remove password from response
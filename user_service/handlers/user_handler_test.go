@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"shared/problem"
+	"strings"
+	"testing"
+	"user_service/configs"
+	"user_service/models"
+	"user_service/repositories"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testUserSecret  = "test-user-secret"
+	testAdminSecret = "test-admin-secret"
+)
+
+func newTestHandler(t *testing.T) (*UserHandler, *repositories.InMemoryUserRepository) {
+	t.Helper()
+
+	repo := repositories.NewInMemoryUserRepository()
+	return NewUserHandler(repo, configs.JWTConfig{UserSecret: testUserSecret, AdminSecret: testAdminSecret}), repo
+}
+
+// serve runs a single handler against a JSON request. setup may set path
+// params or the JWT token on the context before the handler runs.
+func serve(t *testing.T, handler echo.HandlerFunc, method, body string, setup func(c echo.Context)) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	if setup != nil {
+		setup(c)
+	}
+
+	if err := handler(c); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+	return rec
+}
+
+func withID(id string) func(c echo.Context) {
+	return func(c echo.Context) {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+}
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON response %q: %v", rec.Body.String(), err)
+	}
+	return body
+}
+
+func seedUser(t *testing.T, repo *repositories.InMemoryUserRepository, email, password, role string) models.User {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Name: "Seeded User", Email: email, Password: string(hashed), Role: role}
+	if err := repo.CreateUser(&user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestRegisterUser(t *testing.T) {
+	h, repo := newTestHandler(t)
+
+	rec := serve(t, h.RegisterUser, http.MethodPost,
+		`{"name":"Jane Doe","email":"  Jane@Example.com ","password":"s3cretpass"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	user, err := repo.GetUserByEmail("jane@example.com")
+	if err != nil {
+		t.Fatalf("user was not stored: %v", err)
+	}
+	if user.Email != "jane@example.com" {
+		t.Errorf("expected normalized email, got %q", user.Email)
+	}
+	if user.Role != "user" {
+		t.Errorf("expected role user, got %q", user.Role)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("s3cretpass")) != nil {
+		t.Error("expected password to be stored as a bcrypt hash")
+	}
+}
+
+func TestRegisterUserValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"missing name", `{"email":"a@example.com","password":"s3cretpass"}`, "name"},
+		{"short name", `{"name":"A","email":"a@example.com","password":"s3cretpass"}`, "name"},
+		{"malformed email", `{"name":"Jane","email":"not-an-email","password":"s3cretpass"}`, "email"},
+		{"short password", `{"name":"Jane","email":"a@example.com","password":"a1"}`, "password"},
+		{"password without digit", `{"name":"Jane","email":"a@example.com","password":"onlyletters"}`, "password"},
+		{"password without letter", `{"name":"Jane","email":"a@example.com","password":"1234567890"}`, "password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHandler(t)
+
+			rec := serve(t, h.RegisterUser, http.MethodPost, tt.body, nil)
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected 422, got %d: %s", rec.Code, rec.Body.String())
+			}
+
+			errs, _ := decode(t, rec)["errors"].([]interface{})
+			if len(errs) != 1 {
+				t.Fatalf("expected exactly one field error, got %v", errs)
+			}
+			if field := errs[0].(map[string]interface{})["field"]; field != tt.field {
+				t.Errorf("expected error on %q, got %v", tt.field, field)
+			}
+		})
+	}
+}
+
+func TestValidateRequestReturnsProblem(t *testing.T) {
+	if err := validateRequest(&models.RegisterRequest{Name: "Jane", Email: "jane@example.com", Password: "s3cretpass"}); err != nil {
+		t.Fatalf("expected a valid request to pass, got %v", err)
+	}
+
+	err := validateRequest(&models.RegisterRequest{Name: "Jane", Email: "not-an-email", Password: "s3cretpass"})
+	p, ok := err.(*problem.Problem)
+	if !ok {
+		t.Fatalf("expected a *problem.Problem, got %T", err)
+	}
+	if p.Status != http.StatusUnprocessableEntity || len(p.Errors) != 1 || p.Errors[0].Field != "email" {
+		t.Errorf("expected a 422 problem for the email, got %+v", p)
+	}
+}
+
+func TestRegisterUserInvalidBody(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	rec := serve(t, h.RegisterUser, http.MethodPost, `{"name":`, nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestRegisterUserDuplicateEmail(t *testing.T) {
+	h, repo := newTestHandler(t)
+	seedUser(t, repo, "jane@example.com", "s3cretpass", "user")
+
+	rec := serve(t, h.RegisterUser, http.MethodPost,
+		`{"name":"Jane Again","email":"JANE@example.com","password":"s3cretpass"}`, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get(echo.HeaderContentType); ct != problem.ContentType {
+		t.Errorf("expected %s, got %q", problem.ContentType, ct)
+	}
+
+	body := decode(t, rec)
+	if body["code"] != "email_taken" || body["type"] != problem.TypeBase+"email_taken" || body["status"] != float64(409) {
+		t.Errorf("unexpected problem: %v", body)
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	h, repo := newTestHandler(t)
+	user := seedUser(t, repo, "jane@example.com", "s3cretpass", "user")
+
+	rec := serve(t, h.LoginUser, http.MethodPost, `{"email":"Jane@example.com","password":"s3cretpass"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tokenString, _ := decode(t, rec)["token"].(string)
+	claims := &JwtCustomClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testUserSecret), nil
+	})
+	if err != nil {
+		t.Fatalf("token is not signed with the user secret: %v", err)
+	}
+	if claims.UserID != user.ID || claims.Role != "user" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestLoginDeveloper(t *testing.T) {
+	h, repo := newTestHandler(t)
+	developer := seedUser(t, repo, "dev@example.com", "s3cretpass", "developer")
+
+	rec := serve(t, h.LoginUser, http.MethodPost, `{"email":"dev@example.com","password":"s3cretpass"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tokenString, _ := decode(t, rec)["token"].(string)
+	claims := &JwtCustomClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testUserSecret), nil
+	})
+	if err != nil {
+		t.Fatalf("token is not signed with the user secret: %v", err)
+	}
+	if claims.UserID != developer.ID || claims.Role != "developer" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestLoginUserRejectsInvalidCredentials(t *testing.T) {
+	h, repo := newTestHandler(t)
+	seedUser(t, repo, "jane@example.com", "s3cretpass", "user")
+	seedUser(t, repo, "admin@example.com", "s3cretpass", "admin")
+
+	tests := []struct {
+		name string
+		body string
+		code int
+	}{
+		{"wrong password", `{"email":"jane@example.com","password":"wrongpass1"}`, http.StatusUnauthorized},
+		{"unknown email", `{"email":"nobody@example.com","password":"s3cretpass"}`, http.StatusUnauthorized},
+		{"admin account", `{"email":"admin@example.com","password":"s3cretpass"}`, http.StatusUnauthorized},
+		{"malformed email", `{"email":"jane","password":"s3cretpass"}`, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, h.LoginUser, http.MethodPost, tt.body, nil)
+			if rec.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRegisterAndLoginAdmin(t *testing.T) {
+	h, repo := newTestHandler(t)
+
+	rec := serve(t, h.RegisterAdmin, http.MethodPost,
+		`{"name":"Admin","email":"admin@example.com","password":"s3cretpass"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	admin, err := repo.GetUserByEmail("admin@example.com")
+	if err != nil || admin.Role != "admin" {
+		t.Fatalf("expected stored admin, got %+v (%v)", admin, err)
+	}
+
+	rec = serve(t, h.LoginAdmin, http.MethodPost, `{"email":"admin@example.com","password":"s3cretpass"}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tokenString, _ := decode(t, rec)["token"].(string)
+	_, err = jwt.ParseWithClaims(tokenString, &JwtCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testAdminSecret), nil
+	})
+	if err != nil {
+		t.Fatalf("token is not signed with the admin secret: %v", err)
+	}
+}
+
+func TestGetProfile(t *testing.T) {
+	h, repo := newTestHandler(t)
+	user := seedUser(t, repo, "jane@example.com", "s3cretpass", "user")
+
+	rec := serve(t, h.GetProfile, http.MethodGet, "", func(c echo.Context) {
+		c.Set("user", jwt.NewWithClaims(jwt.SigningMethodHS256, &JwtCustomClaims{UserID: user.ID, Role: "user"}))
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	profile, _ := decode(t, rec)["user"].(map[string]interface{})
+	if profile["email"] != "jane@example.com" {
+		t.Errorf("unexpected profile: %v", profile)
+	}
+	if profile["password"] != "" {
+		t.Error("expected password to be removed from the profile")
+	}
+}
+
+func TestAdminUserManagement(t *testing.T) {
+	h, repo := newTestHandler(t)
+	user := seedUser(t, repo, "jane@example.com", "s3cretpass", "user")
+	seedUser(t, repo, "john@example.com", "s3cretpass", "user")
+
+	rec := serve(t, h.GetAllUsers, http.MethodGet, "", nil)
+	if users, _ := decode(t, rec)["users"].([]interface{}); len(users) != 2 {
+		t.Fatalf("expected 2 users, got %s", rec.Body.String())
+	}
+
+	rec = serve(t, h.GetUserByID, http.MethodGet, "", withID("abc"))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-numeric ID, got %d", rec.Code)
+	}
+
+	rec = serve(t, h.GetUserByID, http.MethodGet, "", withID("99"))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown user, got %d", rec.Code)
+	}
+
+	rec = serve(t, h.UpdateUser, http.MethodPut, `{"name":"Jane","role":"superuser"}`, withID("1"))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an unknown role, got %d", rec.Code)
+	}
+
+	rec = serve(t, h.UpdateUser, http.MethodPut, `{"name":"Jane","role":"developer"}`, withID("1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected a user to be made a developer, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = serve(t, h.UpdateUser, http.MethodPut, `{"name":"Jane Admin","role":"admin"}`, withID("1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	updated, _ := repo.GetUserByID(int(user.ID))
+	if updated.Name != "Jane Admin" || updated.Role != "admin" {
+		t.Errorf("update was not applied: %+v", updated)
+	}
+
+	rec = serve(t, h.DeleteUser, http.MethodDelete, "", withID("1"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := repo.GetUserByID(int(user.ID)); err == nil {
+		t.Error("expected user to be deleted")
+	}
+}
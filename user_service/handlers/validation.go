@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"shared/problem"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	passwordMinLength = 8
+	// bcrypt silently ignores everything past 72 bytes
+	passwordMaxLength = 72
+)
+
+// Problem codes specific to the user service
+const (
+	codeEmailTaken         = "email_taken"
+	codeInvalidCredentials = "invalid_credentials"
+)
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// Report fields by their JSON name so clients can map errors back to inputs
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	v.RegisterValidation("password", validatePassword)
+
+	return v
+}
+
+// validatePassword enforces the password policy: 8-72 characters with at
+// least one letter and one digit
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < passwordMinLength || len(password) > passwordMaxLength {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	return hasLetter && hasDigit
+}
+
+// validateRequest validates a bound request and returns a 422 problem with
+// field-level details when it is invalid
+func validateRequest(request interface{}) error {
+	err := validate.Struct(request)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return problem.BadRequest("Invalid request body")
+	}
+
+	fieldErrors := make([]problem.FieldError, 0, len(validationErrors))
+	for _, fe := range validationErrors {
+		fieldErrors = append(fieldErrors, problem.FieldError{
+			Field:   fe.Field(),
+			Message: fieldErrorMessage(fe),
+		})
+	}
+	return problem.Validation(fieldErrors...)
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		return fmt.Sprintf("must be at least %s characters", fe.Param())
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.Join(strings.Fields(fe.Param()), ", "))
+	case "password":
+		return fmt.Sprintf("must be %d-%d characters and contain at least one letter and one digit",
+			passwordMinLength, passwordMaxLength)
+	default:
+		return "is invalid"
+	}
+}
+
+// duplicateEmail is the 409 problem for an email that is already registered
+func duplicateEmail() error {
+	p := problem.Conflict("Email is already registered").WithCode(codeEmailTaken)
+	p.Errors = []problem.FieldError{{Field: "email", Message: "is already registered"}}
+	return p
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
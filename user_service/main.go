@@ -4,8 +4,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"shared/problem"
+	"strconv"
 	"user_service/configs"
 	_ "user_service/docs"
+	"user_service/handlers"
+	"user_service/repositories"
 	"user_service/routes"
 
 	"github.com/labstack/echo/v4"
@@ -37,24 +41,31 @@ import (
 // @description Admin JWT token (format: Bearer <token>)
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(configs.RunCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	cfg, err := configs.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	e := echo.New()
+	problem.Install(e)
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Health Check!")
 	})
 
-	_, err := configs.InitDB()
+	db, err := configs.InitDB(cfg.DB)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	routes.UserRoute(e)
+	userHandler := handlers.NewUserHandler(repositories.NewPostgresUserRepository(db), cfg.JWT)
+	routes.UserRoute(e, userHandler, cfg.JWT)
 
 	// Swagger documentation route
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-	e.Logger.Fatal(e.Start(":" + port))
+	e.Logger.Fatal(e.Start(":" + strconv.Itoa(cfg.Port)))
 }
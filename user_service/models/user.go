@@ -13,17 +13,20 @@ type User struct {
 }
 
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,min=2,max=100"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,password"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
 
+// UpdateUserRequest changes a user's name and role. Making a user a
+// developer lets them manage the projects linked to their account in the
+// project service.
 type UpdateUserRequest struct {
-	Name string `json:"name"`
-	Role string `json:"role"`
+	Name string `json:"name" validate:"required,min=2,max=100"`
+	Role string `json:"role" validate:"required,oneof=user admin developer"`
 }
@@ -2,48 +2,68 @@ package repositories
 
 import (
 	"time"
-	"user_service/configs"
 	"user_service/models"
+
+	"gorm.io/gorm"
 )
 
-func CreateUser(payload *models.User) error {
-	err := configs.DB.Create(payload).Error
+// UserRepository persists user accounts
+type UserRepository interface {
+	CreateUser(payload *models.User) error
+	GetAllUsers() ([]models.User, error)
+	GetUserByID(id int) (models.User, error)
+	GetUserByEmail(email string) (models.User, error)
+	UpdateUser(id int, payload *models.UpdateUserRequest) error
+	DeleteUser(id int) error
+}
+
+// PostgresUserRepository is the GORM-backed UserRepository
+type PostgresUserRepository struct {
+	db *gorm.DB
+}
+
+func NewPostgresUserRepository(db *gorm.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+func (r *PostgresUserRepository) CreateUser(payload *models.User) error {
+	err := r.db.Create(payload).Error
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func GetAllUsers() ([]models.User, error) {
+func (r *PostgresUserRepository) GetAllUsers() ([]models.User, error) {
 	var users []models.User
-	err := configs.DB.Find(&users).Error
+	err := r.db.Find(&users).Error
 	if err != nil {
 		return nil, err
 	}
 	return users, nil
 }
 
-func GetUserByID(id int) (models.User, error) {
+func (r *PostgresUserRepository) GetUserByID(id int) (models.User, error) {
 	var user models.User
-	err := configs.DB.Where("id = ?", id).First(&user).Error
+	err := r.db.Where("id = ?", id).First(&user).Error
 	if err != nil {
 		return user, err
 	}
 	return user, nil
 }
 
-func GetUserByEmail(email string) (models.User, error) {
+func (r *PostgresUserRepository) GetUserByEmail(email string) (models.User, error) {
 	var user models.User
-	err := configs.DB.Where("email = ?", email).First(&user).Error
+	err := r.db.Where("LOWER(email) = LOWER(?)", email).First(&user).Error
 	if err != nil {
 		return user, err
 	}
 	return user, nil
 }
 
-func UpdateUser(id int, payload *models.UpdateUserRequest) error {
+func (r *PostgresUserRepository) UpdateUser(id int, payload *models.UpdateUserRequest) error {
 	var user models.User
-	err := configs.DB.Where("id = ?", id).First(&user).Error
+	err := r.db.Where("id = ?", id).First(&user).Error
 	if err != nil {
 		return err
 	}
@@ -52,21 +72,21 @@ func UpdateUser(id int, payload *models.UpdateUserRequest) error {
 	user.Role = payload.Role
 	user.UpdatedAt = time.Now()
 
-	err = configs.DB.Save(&user).Error
+	err = r.db.Save(&user).Error
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func DeleteUser(id int) error {
+func (r *PostgresUserRepository) DeleteUser(id int) error {
 	var user models.User
-	err := configs.DB.Where("id = ?", id).First(&user).Error
+	err := r.db.Where("id = ?", id).First(&user).Error
 	if err != nil {
 		return err
 	}
 
-	err = configs.DB.Delete(&user).Error
+	err = r.db.Delete(&user).Error
 	if err != nil {
 		return err
 	}
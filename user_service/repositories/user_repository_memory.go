@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"user_service/models"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryUserRepository is a UserRepository kept in process memory. It
+// mirrors the Postgres behaviour that handlers rely on: missing rows return
+// gorm.ErrRecordNotFound and emails are unique case-insensitively.
+type InMemoryUserRepository struct {
+	mu     sync.RWMutex
+	users  map[uint]models.User
+	nextID uint
+}
+
+func NewInMemoryUserRepository() *InMemoryUserRepository {
+	return &InMemoryUserRepository{
+		users:  make(map[uint]models.User),
+		nextID: 1,
+	}
+}
+
+func (r *InMemoryUserRepository) CreateUser(payload *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, payload.Email) {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+
+	payload.ID = r.nextID
+	r.nextID++
+	r.users[payload.ID] = *payload
+	return nil
+}
+
+func (r *InMemoryUserRepository) GetAllUsers() ([]models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]models.User, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (r *InMemoryUserRepository) GetUserByID(id int) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[uint(id)]
+	if !ok {
+		return models.User{}, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (r *InMemoryUserRepository) GetUserByEmail(email string) (models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return models.User{}, gorm.ErrRecordNotFound
+}
+
+func (r *InMemoryUserRepository) UpdateUser(id int, payload *models.UpdateUserRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[uint(id)]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	user.Name = payload.Name
+	user.Role = payload.Role
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *InMemoryUserRepository) DeleteUser(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[uint(id)]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.users, uint(id))
+	return nil
+}
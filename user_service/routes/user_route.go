@@ -1,7 +1,7 @@
 package routes
 
 import (
-	"os"
+	"user_service/configs"
 	"user_service/handlers"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,32 +9,32 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
-func UserRoute(e *echo.Echo) {
-	e.POST("/api/users/register", handlers.RegisterUser)
-	e.POST("/api/users/login", handlers.LoginUser)
+func UserRoute(e *echo.Echo, h *handlers.UserHandler, jwtConfig configs.JWTConfig) {
+	e.POST("/api/users/register", h.RegisterUser)
+	e.POST("/api/users/login", h.LoginUser)
 
 	// User routes with JWT authentication
 	user := e.Group("/api/users")
 	user.Use(echojwt.WithConfig(echojwt.Config{
-		SigningKey: []byte(os.Getenv("USER_JWT_SECRET")),
+		SigningKey: []byte(jwtConfig.UserSecret),
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return new(handlers.JwtCustomClaims)
 		},
 	}))
-	user.GET("/profile", handlers.GetProfile)
+	user.GET("/profile", h.GetProfile)
 
-	e.POST("/api/admin/users/register", handlers.RegisterAdmin)
-	e.POST("/api/admin/users/login", handlers.LoginAdmin)
+	e.POST("/api/admin/users/register", h.RegisterAdmin)
+	e.POST("/api/admin/users/login", h.LoginAdmin)
 	admin := e.Group("/api/admin")
 	admin.Use(echojwt.WithConfig(echojwt.Config{
-		SigningKey: []byte(os.Getenv("ADMIN_JWT_SECRET")),
+		SigningKey: []byte(jwtConfig.AdminSecret),
 		NewClaimsFunc: func(c echo.Context) jwt.Claims {
 			return new(handlers.JwtCustomClaims)
 		},
 	}))
-	admin.GET("/users", handlers.GetAllUsers)
-	admin.POST("/users", handlers.RegisterUser)
-	admin.GET("/users/:id", handlers.GetUserByID)
-	admin.PUT("/users/:id", handlers.UpdateUser)
-	admin.DELETE("/users/:id", handlers.DeleteUser)
+	admin.GET("/users", h.GetAllUsers)
+	admin.POST("/users", h.RegisterUser)
+	admin.GET("/users/:id", h.GetUserByID)
+	admin.PUT("/users/:id", h.UpdateUser)
+	admin.DELETE("/users/:id", h.DeleteUser)
 }